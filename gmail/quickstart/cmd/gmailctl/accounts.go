@@ -0,0 +1,222 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+	"google.golang.org/api/gmail/v1"
+)
+
+// allAccounts is set by the -all-accounts global flag.
+var allAccounts bool
+
+func init() {
+	register(&command{
+		name:    "accounts",
+		usage:   "",
+		summary: "List the accounts configured as profiles and whether they are authorized.",
+		run:     runAccounts,
+	})
+}
+
+// An account is one mailbox a command runs against.
+type account struct {
+	name string
+	user string
+	srv  *gmail.Service
+	http *http.Client // authorized like srv, for other Google APIs
+}
+
+// multiAccount reports whether commands run against several mailboxes.
+func multiAccount() bool {
+	return allAccounts || allUsers
+}
+
+// openAccounts returns the mailboxes selected on the command line: every
+// configured profile with -all-accounts, every user of the domain with
+// -all-users, otherwise just the current one.
+func openAccounts(ctx context.Context) ([]*account, error) {
+	if allUsers {
+		return openDomainAccounts(ctx)
+	}
+	if !allAccounts {
+		a, err := currentAccount(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []*account{a}, nil
+	}
+
+	names := cfg.ProfileNames()
+	if len(names) == 0 {
+		return nil, fmt.Errorf("-all-accounts: no profiles configured")
+	}
+	var accounts []*account
+	for _, name := range names {
+		a, err := openProfileAccount(ctx, cfg.Profiles[name])
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", name, err)
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
+
+// currentAccount returns the mailbox selected by the global flags and
+// profile.
+func currentAccount(ctx context.Context) (*account, error) {
+	name := profile.Name
+	if name == "" {
+		name = "default"
+	}
+	return openAccount(ctx, name, globalAuthOptions())
+}
+
+// openAccount authorizes the mailbox o.user as the account called name.
+func openAccount(ctx context.Context, name string, o authOptions) (*account, error) {
+	client, err := openClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := gmailclient.NewServiceFromClient(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return &account{name: name, user: o.user, srv: srv, http: client}, nil
+}
+
+// client returns the GmailService for a, backed by the message cache when
+// -cache is set.
+func (a *account) client() (gmailclient.GmailService, error) {
+	return withCache(gmailclient.NewClient(a.srv), a)
+}
+
+// api returns the API client for a without the cache, for the calls that
+// change the mailbox or its settings.
+func (a *account) api() *gmailclient.Client {
+	return gmailclient.NewClient(a.srv)
+}
+
+// forEachAccount runs fn for every account. With several accounts it reports
+// progress on stderr and carries on past failures, so that one broken mailbox
+// does not stop the others; the error then only counts the failures.
+func forEachAccount(ctx context.Context, accounts []*account, fn func(a *account) error) error {
+	if len(accounts) == 1 {
+		if err := fn(accounts[0]); err != nil {
+			return fmt.Errorf("account %s: %w", accounts[0].name, err)
+		}
+		return nil
+	}
+	failed := 0
+	for i, a := range accounts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", i+1, len(accounts), a.name)
+		if err := fn(a); err != nil {
+			fmt.Fprintf(os.Stderr, "account %s: %v\n", a.name, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d accounts failed", failed, len(accounts))
+	}
+	return nil
+}
+
+// openProfileAccount builds the service for p, falling back to the global
+// options for settings the profile leaves empty.
+func openProfileAccount(ctx context.Context, p *config.Profile) (*account, error) {
+	o, err := globalAuthOptions().forProfile(p)
+	if err != nil {
+		return nil, err
+	}
+	return openAccount(ctx, p.Name, o)
+}
+
+func runAccounts(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["accounts"])
+	fs.Parse(args)
+
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+		store, err := profileTokenStore(p)
+		if err != nil {
+			return err
+		}
+		status := tokenStatus(store)
+		marker := " "
+		if name == profile.Name {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\t%s\t%s\n", marker, name, status, describeStore(store))
+	}
+	return nil
+}
+
+// profileTokenStore returns the token store of p, which may override the
+// -token-store flag with its own token_store setting.
+func profileTokenStore(p *config.Profile) (gmailclient.TokenStore, error) {
+	o, err := globalAuthOptions().forProfile(p)
+	if err != nil {
+		return nil, err
+	}
+	return newTokenStore(o.tokenStore, o.account, o.token)
+}
+
+// tokenStatus reports whether store holds a token, without asking for the
+// passphrase of encrypted stores.
+func tokenStatus(store gmailclient.TokenStore) string {
+	var err error
+	if s, ok := store.(*gmailclient.EncryptedFileTokenStore); ok {
+		if _, err = os.Stat(s.Path); os.IsNotExist(err) {
+			err = gmailclient.ErrNoToken
+		}
+	} else {
+		_, err = store.Load()
+	}
+	switch {
+	case errors.Is(err, gmailclient.ErrNoToken):
+		return "not authorized"
+	case err != nil:
+		return err.Error()
+	}
+	return "authorized"
+}
+
+// describeStore returns where store keeps its token.
+func describeStore(store gmailclient.TokenStore) string {
+	switch s := store.(type) {
+	case *gmailclient.FileTokenStore:
+		return s.Path
+	case *gmailclient.EncryptedFileTokenStore:
+		return s.Path + " (encrypted)"
+	case *gmailclient.KeyringTokenStore:
+		return "keyring:" + s.Service + "/" + s.Account
+	case *gmailclient.EnvTokenStore:
+		return "$" + s.Name
+	}
+	return fmt.Sprintf("%T", store)
+}
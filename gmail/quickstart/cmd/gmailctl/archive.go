@@ -0,0 +1,244 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"google.golang.org/api/iterator"
+)
+
+func init() {
+	register(&command{
+		name:    "archive",
+		usage:   "[-query q] [-max-results n] [-dir path] [-title t]",
+		summary: "Render matching messages as a static HTML site with a searchable index.",
+		run:     runArchive,
+	})
+}
+
+func runArchive(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["archive"])
+	query := fs.String("query", profile.Query, "Gmail search query")
+	max := fs.Int64("max-results", 0, "maximum number of messages to archive, 0 for all")
+	dir := fs.String("dir", outputDir("archive"), "output directory")
+	title := fs.String("title", "", "title of the index page (default the query)")
+	fs.Parse(args)
+	if *title == "" {
+		*title = *query
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		d := *dir
+		if multiAccount() {
+			d = filepath.Join(d, a.name)
+		}
+		return archiveAccount(ctx, a, *query, *max, d, *title)
+	})
+}
+
+// archiveEntry is one row of the archive index.
+type archiveEntry struct {
+	File    string
+	Date    time.Time
+	From    string
+	Subject string
+}
+
+// archiveAccount writes every matching message of a to <dir>/<id>.html and
+// then writes <dir>/index.html listing them, newest first.
+func archiveAccount(ctx context.Context, a *account, query string, max int64, dir, title string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	opts := gmailclient.FetchOptions{Query: query, MaxResults: max, Concurrency: concurrency}
+	it := gmailclient.Messages(ctx, client, a.user, opts)
+	defer it.Close()
+
+	var entries []archiveEntry
+	for {
+		m, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to fetch messages: %w", err)
+		}
+		parsed, err := gmailclient.ParseMessage(ctx, client, m, a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to parse message %v: %w", m.Id, err)
+		}
+		var body template.HTML
+		if b, mimeType := parsed.Body("html"); mimeType == "text/html" {
+			if b, err = inlineImages(ctx, client, a.user, m, b, dir, "data"); err != nil {
+				return fmt.Errorf("Unable to fetch inline images of %v: %w", m.Id, err)
+			}
+			clean, err := gmailclient.SanitizeHTML(b)
+			if err != nil {
+				return err
+			}
+			body = template.HTML(clean)
+		} else {
+			body = template.HTML("<pre>" + template.HTMLEscapeString(b) + "</pre>")
+		}
+
+		e := archiveEntry{
+			File:    m.Id + ".html",
+			Date:    parsed.Date,
+			From:    gmailclient.FormatAddressList(parsed.From),
+			Subject: parsed.Subject,
+		}
+		var buf bytes.Buffer
+		err = archiveMessageTmpl.Execute(&buf, struct {
+			*gmailclient.Message
+			Body template.HTML
+		}{parsed, body})
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, e.File)
+		if err := atomicfile.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Println(path)
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.After(entries[j].Date) })
+	var buf bytes.Buffer
+	err = archiveIndexTmpl.Execute(&buf, struct {
+		Title   string
+		Entries []archiveEntry
+	}{title, entries})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "index.html")
+	if err := atomicfile.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+var archiveFuncs = template.FuncMap{
+	"addresses": gmailclient.FormatAddressList,
+}
+
+var archiveMessageTmpl = template.Must(template.New("message").Funcs(archiveFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="Content-Security-Policy" content="default-src 'none'; img-src data: https: http:; style-src 'unsafe-inline'">
+<title>{{.Subject}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; }
+.headers th { text-align: right; padding-right: 1em; color: #555; vertical-align: top; }
+.body { border-top: 1px solid #ccc; margin-top: 1em; padding-top: 1em; }
+</style>
+</head>
+<body>
+<p><a href="index.html">&larr; Index</a></p>
+<table class="headers">
+<tr><th>From</th><td>{{addresses .From}}</td></tr>
+<tr><th>To</th><td>{{addresses .To}}</td></tr>
+{{- if .Cc}}
+<tr><th>Cc</th><td>{{addresses .Cc}}</td></tr>
+{{- end}}
+<tr><th>Date</th><td>{{.Date.Format "Mon, 2 Jan 2006 15:04 MST"}}</td></tr>
+<tr><th>Subject</th><td>{{.Subject}}</td></tr>
+{{- if .Attachments}}
+<tr><th>Attachments</th><td>{{range $i, $a := .Attachments}}{{if $i}}, {{end}}{{$a.Filename}}{{end}}</td></tr>
+{{- end}}
+</table>
+<div class="body">
+{{.Body}}
+</div>
+</body>
+</html>
+`))
+
+var archiveIndexTmpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #eee; }
+th { cursor: pointer; user-select: none; }
+td.date { white-space: nowrap; }
+#filter { width: 30em; padding: 0.3em; margin-bottom: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<input id="filter" type="search" placeholder="Filter by sender or subject">
+<table>
+<thead><tr><th data-key="date">Date</th><th data-key="from">From</th><th data-key="subject">Subject</th></tr></thead>
+<tbody>
+{{- range .Entries}}
+<tr data-date="{{.Date.UTC.Format "2006-01-02T15:04:05Z"}}"><td class="date">{{.Date.Format "2006-01-02 15:04"}}</td><td>{{.From}}</td><td><a href="{{.File}}">{{.Subject}}</a></td></tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+(function () {
+  var tbody = document.querySelector("tbody");
+  var rows = Array.prototype.slice.call(tbody.rows);
+  document.getElementById("filter").addEventListener("input", function () {
+    var q = this.value.toLowerCase();
+    rows.forEach(function (r) {
+      r.style.display = r.textContent.toLowerCase().indexOf(q) < 0 ? "none" : "";
+    });
+  });
+  var order = {date: -1};
+  document.querySelectorAll("th").forEach(function (th, col) {
+    th.addEventListener("click", function () {
+      var key = th.dataset.key, dir = order[key] = -(order[key] || 1);
+      rows.sort(function (a, b) {
+        var x = key === "date" ? a.dataset.date : a.cells[col].textContent.toLowerCase();
+        var y = key === "date" ? b.dataset.date : b.cells[col].textContent.toLowerCase();
+        return x < y ? -dir : x > y ? dir : 0;
+      });
+      rows.forEach(function (r) { tbody.appendChild(r); });
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`))
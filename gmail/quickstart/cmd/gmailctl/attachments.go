@@ -0,0 +1,326 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/iterator"
+)
+
+func init() {
+	register(&command{
+		name:    "attachments",
+		usage:   "<message-id> | download [-query q] [-max-results n] [-dir path] [-name glob] [-type mime/type] [-min-size n[K|M|G]] [-parallel n] [-file-retries n] [-dedup] [-to drive://folder|s3://bucket/prefix|gs://bucket/prefix] [-clamd addr] [-infected quarantine|skip]",
+		summary: "List the attachments of a message, or download those of matching messages.",
+		run:     runAttachments,
+	})
+}
+
+func runAttachments(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["attachments"])
+	fs.Parse(args)
+	if fs.Arg(0) == "download" {
+		return runAttachmentsDownload(ctx, fs.Args()[1:])
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("attachments: expected exactly one message ID")
+	}
+	id := fs.Arg(0)
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	msg, err := client.GetMessage(ctx, a.user, id, "full")
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve message %v: %w", id, err)
+	}
+	for _, att := range gmailclient.ListAttachments(msg.Payload) {
+		fmt.Printf("%s\t%s\t%d\n", att.Filename, att.MimeType, att.Size)
+	}
+	return nil
+}
+
+// byteSize is a flag.Value for a size in bytes with an optional K, M or G
+// suffix, as in Gmail's larger: operator.
+type byteSize int64
+
+func (s *byteSize) String() string { return strconv.FormatInt(int64(*s), 10) }
+
+func (s *byteSize) Set(v string) error {
+	if v == "" {
+		return fmt.Errorf("empty size")
+	}
+	mult := int64(1)
+	switch strings.ToUpper(v[len(v)-1:]) {
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", v)
+	}
+	*s = byteSize(n * mult)
+	return nil
+}
+
+// attachmentsOptions are the flags of attachments download.
+type attachmentsOptions struct {
+	query    string
+	max      int64
+	dir      string
+	filter   gmailclient.AttachmentFilter
+	parallel int
+	retries  int
+	dedup    bool
+	to       string
+	scanner  gmailclient.Scanner
+	infected string
+}
+
+func runAttachmentsDownload(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["attachments"])
+	var o attachmentsOptions
+	fs.StringVar(&o.query, "query", profile.Query, "Gmail search query")
+	fs.Int64Var(&o.max, "max-results", 0, "maximum number of messages to look at, 0 for all")
+	fs.StringVar(&o.dir, "dir", outputDir("attachments"), "output directory; each message gets a subdirectory named by its ID")
+	fs.StringVar(&o.filter.Name, "name", "", "only download attachments whose filename matches this glob, e.g. '*.pdf'")
+	fs.StringVar(&o.filter.MimeType, "type", "", "only download attachments of this MIME type, e.g. application/pdf or image/*")
+	var minSize byteSize
+	fs.Var(&minSize, "min-size", "only download attachments of at least this size, e.g. 100K or 5M")
+	fs.IntVar(&o.parallel, "parallel", 4, "number of attachments to download at once")
+	fs.IntVar(&o.retries, "file-retries", 3, "times to retry a failed download of one attachment")
+	fs.StringVar(&o.to, "to", "", "upload to drive://Folder (in subfolders by sender and date), s3://bucket/prefix or gs://bucket/prefix instead of saving in -dir")
+	fs.BoolVar(&o.dedup, "dedup", false, "store each distinct attachment once in <dir>/blobs, hard-linked into the message directories and listed in <dir>/manifest.tsv")
+	clamd := fs.String("clamd", "", "scan attachments with the ClamAV daemon at unix:///path or tcp://host:port before storing them")
+	fs.StringVar(&o.infected, "infected", "quarantine", "what to do with attachments -clamd flags: quarantine (save to <dir>/quarantine) or skip")
+	fs.Parse(args)
+	o.filter.MinSize = int64(minSize)
+	switch o.infected {
+	case "quarantine", "skip":
+	default:
+		return fmt.Errorf("invalid -infected %q (want quarantine or skip)", o.infected)
+	}
+	if *clamd != "" {
+		c, err := gmailclient.NewClamd(*clamd)
+		if err != nil {
+			return err
+		}
+		o.scanner = c
+	}
+	if o.parallel < 1 {
+		return fmt.Errorf("invalid -parallel %d (want at least 1)", o.parallel)
+	}
+	if o.to != "" && o.dedup {
+		return fmt.Errorf("-dedup only applies to downloads to -dir, not -to")
+	}
+
+	// Only messages with attachments can match, so let Gmail skip the rest.
+	o.query = strings.TrimSpace(o.query + " has:attachment")
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		o := o
+		if multiAccount() {
+			o.dir = filepath.Join(o.dir, a.name)
+		}
+		return downloadAttachments(ctx, a, o)
+	})
+}
+
+// downloadAttachments saves the attachments of a's messages matching
+// o.query that pass o.filter to the sink chosen by o. Up to o.parallel
+// attachments are downloaded at once, each retried o.retries times; the
+// first download that still fails stops the others.
+func downloadAttachments(ctx context.Context, a *account, o attachmentsOptions) error {
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	policy := retryPolicy()
+	policy.MaxRetries = o.retries
+	sink, err := newAttachmentSink(ctx, a, o)
+	if err != nil {
+		return err
+	}
+	defer sink.close()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, o.parallel)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	download := func(m *gmail.Message, att *gmailclient.Attachment, name string) {
+		defer wg.Done()
+		defer func() { <-sem }()
+		var where string
+		err := policy.Do(ctx, func() error {
+			write := func(w io.Writer) error {
+				_, err := gmailclient.WriteAttachment(ctx, client, a.user, m, att, w)
+				return err
+			}
+			var err error
+			if o.scanner != nil {
+				where, err = scanAndSave(ctx, sink, o, m, att, name, write)
+			} else {
+				where, err = sink.save(ctx, m, att, name, write)
+			}
+			return err
+		})
+		if err != nil {
+			fail(fmt.Errorf("Unable to download %s of message %v: %w", att.Filename, m.Id, err))
+			return
+		}
+		fmt.Println(where)
+	}
+
+	it := gmailclient.Messages(ctx, client, a.user, gmailclient.FetchOptions{Query: o.query, MaxResults: o.max, Format: "full", Concurrency: concurrency})
+	defer it.Close()
+	for ctx.Err() == nil {
+		m, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			fail(fmt.Errorf("Unable to fetch messages: %w", err))
+			break
+		}
+		used := make(map[string]bool)
+		for _, att := range gmailclient.ListAttachments(m.Payload) {
+			if !o.filter.Match(att) {
+				continue
+			}
+			name := attachmentFilename(att)
+			if used[name] {
+				name = att.PartId + "-" + name
+			}
+			used[name] = true
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go download(m, att, name)
+		}
+	}
+	wg.Wait()
+	if err := sink.close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// attachmentFilename returns a safe local file name for att: the last
+// element of its filename, so that names like "../x" stay in the message
+// directory.
+func attachmentFilename(att *gmailclient.Attachment) string {
+	name := filepath.Base(strings.Replace(att.Filename, "\\", "/", -1))
+	if name == "." || name == "/" || name == ".." {
+		return "part-" + att.PartId
+	}
+	return name
+}
+
+// scanAndSave downloads att to a temporary file and scans it with
+// o.scanner. A clean file is then passed to sink; an infected one is moved
+// to <dir>/quarantine/<message id>/<name>, or dropped with -infected skip.
+func scanAndSave(ctx context.Context, sink attachmentSink, o attachmentsOptions, m *gmail.Message, att *gmailclient.Attachment, name string, write func(io.Writer) error) (string, error) {
+	tmp, err := ioutil.TempFile("", "gmailctl-scan-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := write(tmp); err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	res, err := o.scanner.Scan(ctx, tmp)
+	if err != nil {
+		return "", fmt.Errorf("scan: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	copyTmp := func(w io.Writer) error {
+		_, err := io.Copy(w, tmp)
+		return err
+	}
+
+	if !res.Infected {
+		return sink.save(ctx, m, att, name, copyTmp)
+	}
+	label := fmt.Sprintf("%s/%s: infected with %s", m.Id, name, res.Signature)
+	if o.infected == "skip" {
+		return label + ", skipped", nil
+	}
+	dir := filepath.Join(o.dir, "quarantine", m.Id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := saveAttachment(path, copyTmp); err != nil {
+		return "", err
+	}
+	os.Chmod(path, 0600)
+	return label + ", quarantined in " + path, nil
+}
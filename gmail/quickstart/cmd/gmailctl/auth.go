@@ -0,0 +1,169 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/term"
+)
+
+func init() {
+	register(&command{
+		name:    "auth",
+		usage:   "[status | revoke]",
+		summary: "Authorize and save a new token, show the token's status, or revoke it.",
+		run:     runAuth,
+	})
+}
+
+// authFlowName is set by the -auth-flow global flag.
+var authFlowName string
+
+// authFlow returns the flow selected with -auth-flow.
+func authFlow() (gmailclient.AuthFlow, error) {
+	switch authFlowName {
+	case "", "loopback":
+		return gmailclient.GetTokenFromLoopback, nil
+	case "manual":
+		return gmailclient.GetTokenFromWeb, nil
+	case "device":
+		return gmailclient.GetTokenFromDevice, nil
+	case "none":
+		return gmailclient.NoAuthFlow, nil
+	default:
+		return nil, fmt.Errorf("unknown auth flow %q (want loopback, manual, device or none)", authFlowName)
+	}
+}
+
+// defaultAuthFlow is "loopback" when gmailctl runs in a terminal and "none"
+// otherwise, so that cron jobs fail with a clear error instead of waiting for
+// a browser.
+func defaultAuthFlow() string {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return "loopback"
+	}
+	return "none"
+}
+
+func runAuth(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["auth"])
+	fs.Parse(args)
+
+	switch fs.Arg(0) {
+	case "":
+		return authorizeAccount(ctx)
+	case "status":
+		return authStatus(ctx)
+	case "revoke":
+		return authRevoke(ctx)
+	default:
+		return fmt.Errorf("unknown auth command %q (want status or revoke)", fs.Arg(0))
+	}
+}
+
+// authorizeAccount runs the authorization flow and saves the new token.
+func authorizeAccount(ctx context.Context) error {
+	config, err := gmailclient.ConfigFromFile(credentialsFile, scopes...)
+	if err != nil {
+		return err
+	}
+	flow, err := authFlow()
+	if err != nil {
+		return err
+	}
+	tok, err := flow(ctx, config)
+	if err != nil {
+		return err
+	}
+	store, err := newTokenStore(tokenStoreKind, profile.Name, tokenFile)
+	if err != nil {
+		return err
+	}
+	return store.Save(tok)
+}
+
+// authStatus reports whether the saved token still works, and its granted
+// scopes and expiry.
+func authStatus(ctx context.Context) error {
+	store, err := newTokenStore(tokenStoreKind, profile.Name, tokenFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("store:\t%s\n", describeStore(store))
+	tok, err := store.Load()
+	if errors.Is(err, gmailclient.ErrNoToken) {
+		fmt.Printf("status:\tnot authorized\n")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	config, err := gmailclient.ConfigFromFile(credentialsFile, scopes...)
+	if err != nil {
+		return err
+	}
+	fresh, err := config.TokenSource(ctx, tok).Token()
+	if gmailclient.IsInvalidGrant(err) {
+		fmt.Printf("status:\texpired or revoked\n")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Unable to refresh token: %w", err)
+	}
+	info, err := gmailclient.LookupToken(ctx, fresh)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("status:\tvalid\n")
+	fmt.Printf("email:\t%s\n", info.Email)
+	fmt.Printf("scopes:\t%s\n", strings.Join(info.Scopes, " "))
+	fmt.Printf("expiry:\t%s\n", info.Expiry.Format(time.RFC3339))
+	if missing := gmailclient.MissingScopes(gmailclient.WithScopes(fresh, info.Scopes), scopes); len(missing) > 0 {
+		fmt.Printf("missing:\t%s\n", strings.Join(missing, " "))
+	}
+	return nil
+}
+
+// authRevoke revokes the saved token at Google and deletes it locally.
+func authRevoke(ctx context.Context) error {
+	store, err := newTokenStore(tokenStoreKind, profile.Name, tokenFile)
+	if err != nil {
+		return err
+	}
+	tok, err := store.Load()
+	if errors.Is(err, gmailclient.ErrNoToken) {
+		fmt.Println("No token to revoke.")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := gmailclient.RevokeToken(ctx, tok); err != nil {
+		return fmt.Errorf("Unable to revoke token: %w", err)
+	}
+	if err := store.Delete(); err != nil {
+		return fmt.Errorf("Unable to delete token: %w", err)
+	}
+	fmt.Printf("Revoked and deleted the token in %s.\n", describeStore(store))
+	return nil
+}
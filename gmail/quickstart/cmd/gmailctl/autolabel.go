@@ -0,0 +1,168 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func init() {
+	register(&command{
+		name:    "autolabel",
+		usage:   "[-query q] [-max-results n] [-subject 'regexp=Label']... [-by-domain] [-prefix p] [-full-domain] [-min-messages n] [-dry-run]",
+		summary: "Label matching messages by subject pattern or sender domain, creating the labels (needs the gmail.modify scope).",
+		run:     runAutolabel,
+	})
+}
+
+// autolabelOptions are the flags of autolabel.
+type autolabelOptions struct {
+	query       string
+	max         int64
+	subjects    []*gmailclient.SubjectRule
+	byDomain    bool
+	prefix      string
+	fullDomain  bool
+	minMessages int
+	dryRun      bool
+}
+
+func runAutolabel(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["autolabel"])
+	var o autolabelOptions
+	var subjects stringList
+	fs.StringVar(&o.query, "query", profile.Query, "Gmail search query")
+	fs.Int64Var(&o.max, "max-results", 0, "maximum number of messages to look at, 0 for all")
+	fs.Var(&subjects, "subject", "label messages whose subject matches regexp, as regexp=Label; $1 in the label stands for a submatch (repeatable, first match wins)")
+	fs.BoolVar(&o.byDomain, "by-domain", false, "label the messages no -subject matched by sender domain")
+	fs.StringVar(&o.prefix, "prefix", "Senders", "with -by-domain, the label to nest the domain labels under; empty for none")
+	fs.BoolVar(&o.fullDomain, "full-domain", false, "with -by-domain, keep subdomains, e.g. mail.example.com instead of example.com")
+	fs.IntVar(&o.minMessages, "min-messages", 1, "only apply labels that at least this many messages get")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "print the labels and message counts without changing anything")
+	fs.Parse(args)
+	for _, s := range subjects {
+		r, err := gmailclient.ParseSubjectRule(s)
+		if err != nil {
+			return fmt.Errorf("invalid -subject: %w", err)
+		}
+		o.subjects = append(o.subjects, r)
+	}
+	if len(o.subjects) == 0 && !o.byDomain {
+		fs.Usage()
+		return fmt.Errorf("autolabel: expected -subject or -by-domain")
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		return autolabelAccount(ctx, a, o)
+	})
+}
+
+// autolabelAccount classifies a's messages matching o.query by their
+// headers alone, then labels each group of messages with one batchModify
+// per thousand, creating the labels that are missing.
+func autolabelAccount(ctx context.Context, a *account, o autolabelOptions) error {
+	groups := map[string][]string{}
+	opts := gmailclient.FetchOptions{
+		Query:           o.query,
+		MaxResults:      o.max,
+		MetadataHeaders: []string{"From", "Subject"},
+		Fields:          []googleapi.Field{"id", "payload/headers"},
+		Concurrency:     concurrency,
+	}
+	err := gmailclient.FetchMessages(ctx, gmailclient.NewClient(a.srv), a.user, opts, func(msg *gmail.Message) error {
+		m, err := gmailclient.ParseMetadata(msg)
+		if err != nil {
+			return err
+		}
+		label := gmailclient.ClassifySubject(o.subjects, m.Subject)
+		if label == "" && o.byDomain {
+			if domain := gmailclient.SenderDomain(m, o.fullDomain); domain != "" {
+				label = domain
+				if o.prefix != "" {
+					label = o.prefix + "/" + domain
+				}
+			}
+		}
+		if label != "" {
+			groups[label] = append(groups[label], m.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to fetch messages: %w", err)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name, ids := range groups {
+		if len(ids) >= o.minMessages {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if n, m := len(groups[names[i]]), len(groups[names[j]]); n != m {
+			return n > m
+		}
+		return names[i] < names[j]
+	})
+	if o.dryRun {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "LABEL\tMESSAGES")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%d\n", name, len(groups[name]))
+		}
+		return w.Flush()
+	}
+
+	labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to list labels: %w", err)
+	}
+	for _, name := range names {
+		l, err := gmailclient.FindLabel(labels, name)
+		if err != nil {
+			if l, err = gmailclient.CreateLabel(ctx, a.api(), a.user, &gmail.Label{Name: name}, true); err != nil {
+				return fmt.Errorf("Unable to create label %s (is gmail.modify among -scopes?): %w", name, err)
+			}
+			// Creating the parents may have added more than one label.
+			if labels, err = gmailclient.ListLabels(ctx, a.api(), a.user); err != nil {
+				return fmt.Errorf("Unable to list labels: %w", err)
+			}
+		}
+		ids := groups[name]
+		if err := gmailclient.BatchModify(ctx, a.api(), a.user, ids, []string{l.Id}, nil, nil); err != nil {
+			return fmt.Errorf("Unable to label messages %s (is gmail.modify among -scopes?): %w", name, err)
+		}
+		if err := uncacheMessages(ctx, a, ids...); err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%d\n", name, len(ids))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/cache"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+)
+
+func init() {
+	register(&command{
+		name:    "cache",
+		usage:   "purge [-older-than duration] [-account name]",
+		summary: "Remove messages from the local message cache.",
+		run:     runCache,
+	})
+}
+
+// cachePath is set by the -cache global flag. Empty disables the cache.
+var cachePath string
+
+var (
+	cacheOnce sync.Once
+	msgCache  *cache.Cache
+	cacheErr  error
+)
+
+// openCache opens the message cache on first use. It returns nil if no cache
+// is configured.
+func openCache() (*cache.Cache, error) {
+	if cachePath == "" {
+		return nil, nil
+	}
+	cacheOnce.Do(func() {
+		msgCache, cacheErr = cache.Open(config.ExpandHome(cachePath))
+	})
+	return msgCache, cacheErr
+}
+
+// closeCache closes the message cache if it was opened.
+func closeCache() {
+	if msgCache != nil {
+		msgCache.Close()
+	}
+}
+
+// cacheAccount is the key of a's messages in the cache: the mailbox address
+// for delegated and impersonated mailboxes, otherwise the account name.
+func cacheAccount(a *account) string {
+	if a.user != "" && a.user != "me" {
+		return a.user
+	}
+	return a.name
+}
+
+// withCache returns srv made cache-aware for a when -cache is set.
+func withCache(srv gmailclient.GmailService, a *account) (gmailclient.GmailService, error) {
+	c, err := openCache()
+	if c == nil || err != nil {
+		return srv, err
+	}
+	return cache.Wrap(srv, c, cacheAccount(a)), nil
+}
+
+// uncache drops changed messages of a from the cache after a sync. A full
+// sync cannot tell which messages changed, so it drops all of them.
+func uncache(ctx context.Context, a *account, c *gmailclient.Changes) error {
+	mc, err := openCache()
+	if mc == nil || err != nil {
+		return err
+	}
+	if c.Full {
+		_, err := mc.Purge(ctx, cacheAccount(a), time.Time{})
+		return err
+	}
+	if err := mc.Delete(ctx, cacheAccount(a), c.Deleted...); err != nil {
+		return err
+	}
+	return mc.Delete(ctx, cacheAccount(a), c.LabelsChanged...)
+}
+
+// uncacheMessages drops messages of a from the cache after a command changed
+// their labels or deleted them, so that later runs do not see the old labels.
+func uncacheMessages(ctx context.Context, a *account, ids ...string) error {
+	mc, err := openCache()
+	if mc == nil || err != nil {
+		return err
+	}
+	return mc.Delete(ctx, cacheAccount(a), ids...)
+}
+
+// uncacheThreads is uncacheMessages for every message of the threads.
+func uncacheThreads(ctx context.Context, a *account, threadIDs ...string) error {
+	mc, err := openCache()
+	if mc == nil || err != nil {
+		return err
+	}
+	return mc.DeleteThreads(ctx, cacheAccount(a), threadIDs...)
+}
+
+func runCache(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["cache"])
+	fs.Parse(args)
+	if fs.Arg(0) != "purge" {
+		fs.Usage()
+		return fmt.Errorf("cache: unknown command %q (want purge)", fs.Arg(0))
+	}
+
+	pfs := newFlagSet(commands["cache"])
+	olderThan := pfs.Duration("older-than", 0, "only remove messages cached longer ago than this, e.g. 720h")
+	accountName := pfs.String("account", "", "only remove messages of this account: a profile name, or the address of a -user mailbox")
+	pfs.Parse(fs.Args()[1:])
+
+	c, err := openCache()
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("cache purge: no cache configured; set -cache or GMAIL_CACHE")
+	}
+	var before time.Time
+	if *olderThan > 0 {
+		before = time.Now().Add(-*olderThan)
+	}
+	n, err := c.Purge(ctx, *accountName, before)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d cached messages.\n", n)
+	return nil
+}
@@ -0,0 +1,145 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name:    "contacts",
+		usage:   "[-query q] [-max-results n] [-output text|csv|json|ndjson]",
+		summary: "List the contact cards (vCards) sent in matching messages.",
+		run:     runContacts,
+	})
+}
+
+func runContacts(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["contacts"])
+	query := fs.String("query", profile.Query, "Gmail search query")
+	max := fs.Int64("max-results", 0, "maximum number of messages to look at, 0 for all")
+	output := fs.String("output", "text", "output format: text, csv, json (one array) or ndjson (one object per line)")
+	fs.Parse(args)
+	switch *output {
+	case "text", "csv", "json", "ndjson":
+	default:
+		return fmt.Errorf("invalid -output %q (want text, csv, json or ndjson)", *output)
+	}
+
+	// Cards are almost always attached as .vcf files, which Gmail can
+	// search for.
+	q := strings.TrimSpace(*query + " filename:vcf")
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	var write func(account string, c *gmailclient.Contact) error
+	var done func() error
+	switch *output {
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		header := gmailclient.ContactCSVHeader
+		if multiAccount() {
+			header = append([]string{"account"}, header...)
+		}
+		w.Write(header)
+		write = func(account string, c *gmailclient.Contact) error {
+			record := gmailclient.ContactCSVRecord(c)
+			if multiAccount() {
+				record = append([]string{account}, record...)
+			}
+			return w.Write(record)
+		}
+		done = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	case "json", "ndjson":
+		j := newJSONWriter(os.Stdout, *output)
+		write = func(account string, c *gmailclient.Contact) error {
+			if multiAccount() {
+				return j.write(struct {
+					Account string `json:"account"`
+					*gmailclient.Contact
+				}{account, c})
+			}
+			return j.write(c)
+		}
+		done = j.close
+	default:
+		write = func(account string, c *gmailclient.Contact) error {
+			if multiAccount() {
+				fmt.Printf("%s\t", account)
+			}
+			fmt.Printf("%s\t%s\t%s\n", c.Name, strings.Join(c.Emails, ", "), strings.Join(c.Phones, ", "))
+			return nil
+		}
+		done = func() error { return nil }
+	}
+
+	err = forEachAccount(ctx, accounts, func(a *account) error {
+		return listContacts(ctx, a, q, *max, func(c *gmailclient.Contact) error {
+			return write(a.name, c)
+		})
+	})
+	if derr := done(); err == nil {
+		err = derr
+	}
+	return err
+}
+
+// listContacts calls fn for every contact card in a's messages matching
+// query. A card sent several times, as in a thread, is reported once.
+func listContacts(ctx context.Context, a *account, query string, max int64, fn func(*gmailclient.Contact) error) error {
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	opts := gmailclient.FetchOptions{Query: query, MaxResults: max, Format: "full", Concurrency: concurrency}
+	err = gmailclient.FetchMessages(ctx, client, a.user, opts, func(m *gmail.Message) error {
+		contacts, err := gmailclient.MessageContacts(ctx, client, m, a.user)
+		if err != nil {
+			return err
+		}
+		for _, c := range contacts {
+			key := strings.Join(gmailclient.ContactCSVRecord(c), "\x00")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to fetch messages: %w", err)
+	}
+	return nil
+}
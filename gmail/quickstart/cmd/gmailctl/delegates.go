@@ -0,0 +1,103 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func runDelegates(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("delegates", args, "list", "add", "remove")
+	if err != nil {
+		return err
+	}
+	var output *string
+	if action == "list" {
+		output = outputFlag(fs)
+	}
+	fs.Parse(args)
+	switch {
+	case action == "list" && fs.NArg() != 0:
+		fs.Usage()
+		return fmt.Errorf("settings delegates list: unexpected arguments")
+	case action != "list" && fs.NArg() == 0:
+		fs.Usage()
+		return fmt.Errorf("settings delegates %s: expected delegate addresses", action)
+	}
+	if output != nil {
+		if err := checkOutput(*output); err != nil {
+			return err
+		}
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	out := "text"
+	if output != nil {
+		out = *output
+	}
+	return delegatesAction(ctx, a.api(), a.user, action, fs.Args(), out)
+}
+
+// delegatesAction runs settings delegates action on user's mailbox, adding
+// or removing the delegates emails.
+func delegatesAction(ctx context.Context, srv gmailclient.DelegateService, user, action string, emails []string, output string) error {
+	switch action {
+	case "list":
+		delegates, err := gmailclient.ListDelegates(ctx, srv, user)
+		if err != nil {
+			return fmt.Errorf("Unable to list delegates (delegation needs -service-account): %w", err)
+		}
+		if output != "text" {
+			j := newJSONWriter(os.Stdout, output)
+			for _, d := range delegates {
+				if err := j.write(d); err != nil {
+					return err
+				}
+			}
+			return j.close()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "DELEGATE\tSTATUS")
+		for _, d := range delegates {
+			fmt.Fprintf(w, "%s\t%s\n", d.DelegateEmail, d.VerificationStatus)
+		}
+		return w.Flush()
+	case "add":
+		for _, email := range emails {
+			if _, err := gmailclient.CreateDelegate(ctx, srv, user, email); err != nil {
+				return fmt.Errorf("Unable to add delegate %s (is gmail.settings.sharing among -scopes, with -service-account?): %w", email, err)
+			}
+			fmt.Printf("%s added as a delegate.\n", email)
+		}
+	case "remove":
+		for _, email := range emails {
+			if err := gmailclient.DeleteDelegate(ctx, srv, user, email); err != nil {
+				return fmt.Errorf("Unable to remove delegate %s (is gmail.settings.sharing among -scopes, with -service-account?): %w", email, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestDelegatesAction(t *testing.T) {
+	ctx := context.Background()
+	s := gmailclienttest.New()
+	emails := func() []string {
+		var emails []string
+		for _, d := range s.Delegates {
+			emails = append(emails, d.DelegateEmail)
+		}
+		return emails
+	}
+
+	if err := delegatesAction(ctx, s, "me", "add", []string{"ana@example.com", "bo@example.com", "cy@example.com"}, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if got := emails(); len(got) != 3 || got[2] != "cy@example.com" {
+		t.Fatalf("delegates after add = %q", got)
+	}
+	if err := delegatesAction(ctx, s, "me", "list", nil, "text"); err != nil {
+		t.Error(err)
+	}
+	if err := delegatesAction(ctx, s, "me", "remove", []string{"ana@example.com", "CY@example.com"}, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if got := emails(); len(got) != 1 || got[0] != "bo@example.com" {
+		t.Errorf("delegates after remove = %q, want bo@example.com", got)
+	}
+
+	// Removal stops at the first address that is not a delegate.
+	err := delegatesAction(ctx, s, "me", "remove", []string{"dee@example.com", "bo@example.com"}, "text")
+	if err == nil {
+		t.Error("removing a missing delegate succeeded")
+	}
+	if got := emails(); len(got) != 1 {
+		t.Errorf("delegates after a failed remove = %q, want bo@example.com", got)
+	}
+	s.Delegates = append(s.Delegates, &gmail.Delegate{DelegateEmail: "ed@example.com", VerificationStatus: "pending"})
+	if err := delegatesAction(ctx, s, "me", "list", nil, "json"); err != nil {
+		t.Error(err)
+	}
+}
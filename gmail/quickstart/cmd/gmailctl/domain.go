@@ -0,0 +1,75 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+// Options of the -all-users global flag.
+var (
+	allUsers  bool
+	domain    string
+	adminUser string
+)
+
+// openDomainAccounts returns one account per active user of the Workspace
+// domain, each impersonated with the service account or Application Default
+// Credentials.
+func openDomainAccounts(ctx context.Context) ([]*account, error) {
+	o := globalAuthOptions()
+	if o.serviceAccount == "" && !o.adc {
+		return nil, fmt.Errorf("-all-users needs -service-account or -adc with domain-wide delegation")
+	}
+	if adminUser == "" {
+		return nil, fmt.Errorf("-all-users needs -admin set to an administrator to list users as")
+	}
+	var client *http.Client
+	var err error
+	if o.adc {
+		client, err = gmailclient.DefaultClient(ctx, adminUser, gmailclient.DirectoryScope)
+	} else {
+		client, err = gmailclient.ServiceAccountClient(ctx, o.serviceAccount, adminUser, gmailclient.DirectoryScope)
+	}
+	if err != nil {
+		return nil, err
+	}
+	users, err := gmailclient.DomainUsers(ctx, gmailclient.WithRetry(client, retryPolicy()), domain)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list domain users: %w", err)
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("-all-users: no active users found")
+	}
+
+	var accounts []*account
+	for _, u := range users {
+		o.account = u
+		o.user = u
+		a, err := openAccount(ctx, u, o)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", u, err)
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
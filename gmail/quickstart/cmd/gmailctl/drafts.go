@@ -0,0 +1,218 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func init() {
+	register(&command{
+		name:    "drafts",
+		usage:   "list [-query q] | create <compose flags> | show <id> | edit <id> | update <compose flags> <id> | send <id> | delete <id>",
+		summary: "Create, review, edit and send drafts (needs the gmail.compose scope).",
+		run:     runDrafts,
+	})
+}
+
+func runDrafts(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["drafts"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("drafts: expected a subcommand")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+
+	fs = newFlagSet(commands["drafts"])
+	var c composeFlags
+	query := ""
+	switch sub {
+	case "list":
+		fs.StringVar(&query, "query", "", "only list drafts matching this Gmail search query")
+	case "create", "update":
+		c.register(fs)
+	case "show", "edit", "send", "delete":
+	default:
+		fs.Usage()
+		return fmt.Errorf("drafts: unknown subcommand %q", sub)
+	}
+	fs.Parse(args)
+	id := ""
+	switch {
+	case sub == "list" || sub == "create":
+		if fs.NArg() != 0 {
+			return fmt.Errorf("drafts %s: unexpected arguments %v", sub, fs.Args())
+		}
+	case fs.NArg() != 1:
+		return fmt.Errorf("drafts %s: expected exactly one draft ID", sub)
+	default:
+		id = fs.Arg(0)
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "list":
+		return listDrafts(ctx, a.api(), a.user, query)
+	case "create", "update":
+		m, err := c.message()
+		if err != nil {
+			return err
+		}
+		raw, err := m.Bytes()
+		if err != nil {
+			return err
+		}
+		if sub == "update" {
+			return updateDraft(ctx, a.api(), a.user, id, func([]byte) ([]byte, error) { return raw, nil })
+		}
+		d, err := gmailclient.CreateDraft(ctx, a.api(), a.user, raw, "")
+		if err != nil {
+			return fmt.Errorf("Unable to create draft (is gmail.compose among -scopes?): %w", err)
+		}
+		fmt.Println(d.Id)
+		return nil
+	case "show":
+		raw, _, err := draftRaw(ctx, a.api(), a.user, id)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(raw)
+		return err
+	case "edit":
+		return updateDraft(ctx, a.api(), a.user, id, editRaw)
+	case "send":
+		m, err := gmailclient.SendDraft(ctx, a.api(), a.user, id)
+		if err != nil {
+			return fmt.Errorf("Unable to send draft %s: %w", id, err)
+		}
+		fmt.Println(m.Id)
+		return nil
+	}
+	if err := gmailclient.DeleteDraft(ctx, a.api(), a.user, id); err != nil {
+		return fmt.Errorf("Unable to delete draft %s: %w", id, err)
+	}
+	return nil
+}
+
+// listDrafts prints the ID, recipients and subject of user's drafts
+// matching query.
+func listDrafts(ctx context.Context, srv gmailclient.DraftService, user, query string) error {
+	drafts, err := gmailclient.ListDrafts(ctx, srv, user, query)
+	if err != nil {
+		return fmt.Errorf("Unable to list drafts (is gmail.compose among -scopes?): %w", err)
+	}
+	for _, d := range drafts {
+		full, err := gmailclient.GetDraft(ctx, srv, user, d.Id, "metadata")
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve draft %s: %w", d.Id, err)
+		}
+		m, err := gmailclient.ParseMetadata(full.Message)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%s\t%s\n", d.Id, gmailclient.FormatAddressList(m.To), m.Subject)
+	}
+	return nil
+}
+
+// draftRaw returns the raw message of draft id and its thread ID.
+func draftRaw(ctx context.Context, srv gmailclient.DraftService, user, id string) ([]byte, string, error) {
+	d, err := gmailclient.GetDraft(ctx, srv, user, id, "raw")
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to retrieve draft %s: %w", id, err)
+	}
+	raw, err := gmailclient.RawMessage(d.Message)
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, d.Message.ThreadId, nil
+}
+
+// updateDraft replaces the message of draft id with what change makes of
+// it, keeping it in its thread.
+func updateDraft(ctx context.Context, srv gmailclient.DraftService, user, id string, change func(raw []byte) ([]byte, error)) error {
+	raw, threadId, err := draftRaw(ctx, srv, user, id)
+	if err != nil {
+		return err
+	}
+	changed, err := change(raw)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(changed, raw) {
+		fmt.Fprintln(os.Stderr, "draft unchanged")
+		return nil
+	}
+	d, err := gmailclient.UpdateDraft(ctx, srv, user, id, changed, threadId)
+	if err != nil {
+		return fmt.Errorf("Unable to update draft %s: %w", id, err)
+	}
+	fmt.Println(d.Id)
+	return nil
+}
+
+// editRaw opens raw in $VISUAL or $EDITOR, vi by default, and returns the
+// edited message.
+func editRaw(raw []byte) ([]byte, error) {
+	f, err := ioutil.TempFile("", "gmailctl-draft-*.eml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(bytes.Replace(raw, []byte("\r\n"), []byte("\n"), -1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), f.Name())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Unable to run editor %s: %w", editor, err)
+	}
+	edited, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(edited, bytes.Replace(raw, []byte("\r\n"), []byte("\n"), -1)) {
+		return raw, nil
+	}
+	return edited, nil
+}
@@ -0,0 +1,62 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+)
+
+func TestUpdateDraft(t *testing.T) {
+	ctx := context.Background()
+	s := gmailclienttest.New()
+	d, err := gmailclient.CreateDraft(ctx, s, "me", []byte("Subject: Re: plans\r\n\r\nSee you"), "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(raw []byte) ([]byte, error) {
+		return bytes.Replace(raw, []byte("See you"), []byte("See you at 6"), 1), nil
+	}
+	if err := updateDraft(ctx, s, "me", d.Id, edit); err != nil {
+		t.Fatal(err)
+	}
+	raw, threadId, err := draftRaw(ctx, s, "me", d.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "Subject: Re: plans\r\n\r\nSee you at 6" {
+		t.Errorf("updated draft = %q", raw)
+	}
+	if threadId != "t1" {
+		t.Errorf("updated draft is in thread %q, want t1", threadId)
+	}
+
+	unchanged := func(raw []byte) ([]byte, error) { return raw, nil }
+	if err := updateDraft(ctx, s, "me", d.Id, unchanged); err != nil {
+		t.Errorf("updateDraft without changes = %v", err)
+	}
+
+	if err := updateDraft(ctx, s, "me", "missing", edit); err == nil {
+		t.Error("updateDraft of a missing draft succeeded")
+	}
+}
@@ -0,0 +1,242 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/objstore"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"google.golang.org/api/iterator"
+)
+
+func init() {
+	register(&command{
+		name:    "export",
+		usage:   "[-query q] " + queryFlagsUsage + " [-max-results n] [-dir path] [-format body|mbox|maildir|eml|csv|pdf|markdown|parquet|bigquery] [-pdf-font file.ttf] [-bigquery-table id] [-bodies] [-extract-text] [-prefer auto|plain|html] [-inline data|files|none] [-resume] [-conversations] [-to s3://bucket/prefix|gs://bucket/prefix]",
+		summary: "Write every matching message to a directory.",
+		run:     runExport,
+	})
+}
+
+// exportOptions are the settings of an export.
+type exportOptions struct {
+	query   string
+	max     int64
+	dir     string
+	format  string
+	prefer  string
+	inline  string
+	pdfFont string
+	resume  bool
+
+	conversations bool
+
+	bodies        bool
+	extractText   bool
+	bigQueryTable string
+	to            string
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["export"])
+	var o exportOptions
+	fs.StringVar(&o.query, "query", profile.Query, "Gmail search query")
+	fs.Int64Var(&o.max, "max-results", 0, "maximum number of messages to export, 0 for all")
+	fs.Int64Var(&o.max, "max", 0, "alias for -max-results")
+	fs.StringVar(&o.dir, "dir", outputDir("export"), "output directory")
+	fs.StringVar(&o.format, "format", "body", "output format: body (one .html or .txt file per message), mbox (messages.mbox), maildir (Maildir/), eml (one raw .eml file per message), csv (messages.csv, metadata only), pdf (one .pdf file per message), markdown (one .md note per message), parquet (messages-<n>.parquet) or bigquery (rows streamed to -bigquery-table)")
+	prefer := preferFlag(fs)
+	fs.StringVar(&o.inline, "inline", "data", "inline images in HTML bodies: embed as data URIs, save as files next to the message, or leave as cid: links (none)")
+	fs.StringVar(&o.pdfFont, "pdf-font", "", "TrueType font for -format pdf, needed for scripts beyond Western European ones")
+	fs.StringVar(&o.bigQueryTable, "bigquery-table", "", "BigQuery table for -format bigquery, as project.dataset.table; created if missing")
+	fs.StringVar(&o.to, "to", "", "also upload the output directory to s3://bucket/prefix or gs://bucket/prefix, skipping files already there")
+	fs.BoolVar(&o.bodies, "bodies", false, "include the plain-text and HTML bodies in -format parquet and bigquery")
+	fs.BoolVar(&o.bodies, "parquet-bodies", false, "alias for -bodies")
+	fs.BoolVar(&o.extractText, "extract-text", false, "extract the text of PDF, DOCX and XLSX attachments into the attachment_text column of -format parquet and bigquery")
+	fs.BoolVar(&o.resume, "resume", false, "continue an interrupted export from the checkpoint in the output directory")
+	fs.BoolVar(&o.conversations, "conversations", false, "also write conversations.json, linking each message to its parent, replies and conversation root by Message-ID, In-Reply-To and References")
+	qf := addQueryFlags(fs)
+	fs.Parse(args)
+	q, err := qf.apply(o.query)
+	if err != nil {
+		return err
+	}
+	o.query = q
+	o.prefer = *prefer
+	if err := checkPrefer(o.prefer); err != nil {
+		return err
+	}
+	switch o.inline {
+	case "data", "files", "none":
+	default:
+		return fmt.Errorf("invalid -inline %q (want data, files or none)", o.inline)
+	}
+	if _, ok := exportFormats[o.format]; !ok {
+		return fmt.Errorf("invalid -format %q (want %s)", o.format, strings.Join(exportFormatNames(), ", "))
+	}
+	if o.conversations && o.resume {
+		return fmt.Errorf("-conversations cannot be used with -resume")
+	}
+	if o.to != "" {
+		if _, _, _, err := objstore.ParseURL(o.to); err != nil {
+			return err
+		}
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		o := o
+		if multiAccount() {
+			o.dir = filepath.Join(o.dir, a.name)
+		}
+		if err := exportAccount(ctx, a, o); err != nil {
+			return err
+		}
+		if o.to == "" {
+			return nil
+		}
+		to := o.to
+		if multiAccount() {
+			to = strings.TrimSuffix(to, "/") + "/" + a.name
+		}
+		return uploadDir(ctx, o.dir, to)
+	})
+}
+
+// checkpointFile is the name of the export checkpoint in the output
+// directory.
+const checkpointFile = ".gmailctl-checkpoint.json"
+
+// exportAccount writes the matching messages of one account to o.dir in
+// o.format. With -all-accounts or -all-users each account gets its own
+// subdirectory.
+//
+// Progress is checkpointed in the directory after every message that is
+// written for good, and the checkpoint is removed when the export completes.
+// Exporters that buffer messages only let the checkpoint advance once they
+// have flushed them. With o.resume an existing checkpoint is picked up,
+// skipping the pages and messages already exported; without one the export
+// starts over as if o.resume were not set.
+func exportAccount(ctx context.Context, a *account, o exportOptions) (err error) {
+	dir, query := o.dir, o.query
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	cpPath := filepath.Join(dir, checkpointFile)
+	cp := &gmailclient.Checkpoint{Query: query}
+	if o.resume {
+		c, err := gmailclient.LoadCheckpoint(cpPath)
+		switch {
+		case os.IsNotExist(err):
+			fmt.Fprintf(os.Stderr, "No checkpoint in %s; starting from the beginning.\n", dir)
+			// Nothing an earlier run left in dir counts as exported, so
+			// the exporters replace it rather than append to it.
+			o.resume = false
+		case err != nil:
+			return err
+		case c.Query != query:
+			return fmt.Errorf("the checkpoint in %s is for the query %q, not %q", dir, c.Query, query)
+		default:
+			cp = c
+		}
+	}
+
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	ex, err := exportFormats[o.format](ctx, client, a, o)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// close keeps the messages exported before an error, so once it
+		// has succeeded the checkpoint can cover all of them.
+		if cerr := ex.close(); cerr == nil && err != nil {
+			if serr := cp.Save(cpPath); serr != nil {
+				fmt.Fprintf(os.Stderr, "Unable to save checkpoint: %v\n", serr)
+			}
+		}
+	}()
+	opts := gmailclient.FetchOptions{Query: query, MaxResults: o.max, Format: ex.format(), Concurrency: concurrency, PageToken: cp.PageToken}
+	if fe, ok := ex.(fieldsExporter); ok {
+		opts.Fields = fe.fields()
+	}
+	var convs *gmailclient.Conversations
+	if o.conversations {
+		convs = gmailclient.NewConversations()
+	}
+	it := gmailclient.Messages(ctx, client, a.user, opts)
+	defer it.Close()
+	for {
+		m, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to fetch messages: %w", err)
+		}
+		if cp.IsDone(m.Id) {
+			continue
+		}
+		where, err := ex.export(ctx, m)
+		if err != nil {
+			return fmt.Errorf("Unable to export message %v: %w", m.Id, err)
+		}
+		fmt.Println(where)
+		if convs != nil {
+			h, err := gmailclient.MessageHeader(m)
+			if err != nil {
+				return err
+			}
+			convs.Add(m.Id, m.ThreadId, h)
+		}
+
+		cp.MarkDone(it.PageToken(), m.Id)
+		if be, ok := ex.(bufferingExporter); ok && !be.durable() {
+			continue
+		}
+		if err := cp.Save(cpPath); err != nil {
+			return fmt.Errorf("Unable to save checkpoint: %w", err)
+		}
+	}
+	if err := ex.close(); err != nil {
+		return err
+	}
+	if convs != nil {
+		b, err := json.MarshalIndent(convs.Links(), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := atomicfile.WriteFile(filepath.Join(dir, "conversations.json"), append(b, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return gmailclient.RemoveCheckpoint(cpPath)
+}
@@ -0,0 +1,163 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// batchExporter buffers message IDs and writes them to *written in batches,
+// failing on the message failOn and, with failClose, in close.
+type batchExporter struct {
+	batch     int
+	failOn    string
+	failClose bool
+	buf       []string
+	written   *[]string
+}
+
+func (e *batchExporter) format() string { return "minimal" }
+
+func (e *batchExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	if m.Id == e.failOn {
+		return "", errors.New("export failed")
+	}
+	e.buf = append(e.buf, m.Id)
+	if len(e.buf) >= e.batch {
+		e.flush()
+	}
+	return m.Id, nil
+}
+
+func (e *batchExporter) flush() {
+	*e.written = append(*e.written, e.buf...)
+	e.buf = nil
+}
+
+func (e *batchExporter) durable() bool { return len(e.buf) == 0 }
+
+func (e *batchExporter) close() error {
+	if e.failClose {
+		return errors.New("close failed")
+	}
+	e.flush()
+	return nil
+}
+
+// testAccount returns an account whose mailbox holds the messages ids.
+func testAccount(t *testing.T, ids ...string) *account {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const list = "/users/me/messages"
+		switch {
+		case strings.HasSuffix(r.URL.Path, list):
+			resp := &gmail.ListMessagesResponse{}
+			for _, id := range ids {
+				resp.Messages = append(resp.Messages, &gmail.Message{Id: id, ThreadId: id})
+			}
+			json.NewEncoder(w).Encode(resp)
+		case strings.Contains(r.URL.Path, list+"/"):
+			id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			json.NewEncoder(w).Encode(&gmail.Message{Id: id, ThreadId: id, Payload: &gmail.MessagePart{
+				Headers: []*gmail.MessagePartHeader{{Name: "Subject", Value: "Message " + id}},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(ts.URL), option.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &account{name: "test", user: "me", srv: srv}
+}
+
+func TestExportResumeWithoutCheckpoint(t *testing.T) {
+	a := testAccount(t, "m1", "m2")
+	o := exportOptions{dir: t.TempDir(), format: "csv", resume: true}
+	path := filepath.Join(o.dir, csvFile)
+	if err := ioutil.WriteFile(path, []byte("left,by,an,earlier,run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := exportAccount(context.Background(), a, o); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 3 || strings.HasPrefix(lines[0], "left") {
+		t.Errorf("%s = %q, want a header and 2 rows", csvFile, b)
+	}
+}
+
+func TestExportResumeAfterFailedBatch(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		failClose bool
+		resumed   []string
+	}{
+		// m3 is still buffered when m4 fails and is lost with the
+		// failed close, so the resumed run has to export it again.
+		{"close fails", true, []string{"m3", "m4", "m5"}},
+		// close writes m3 out, so the checkpoint may then cover it.
+		{"close succeeds", false, []string{"m4", "m5"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAccount(t, "m1", "m2", "m3", "m4", "m5")
+			o := exportOptions{dir: t.TempDir(), format: "test"}
+			var written []string
+			ex := &batchExporter{batch: 2, failOn: "m4", failClose: tt.failClose, written: &written}
+			exportFormats["test"] = func(context.Context, gmailclient.GmailService, *account, exportOptions) (exporter, error) {
+				return ex, nil
+			}
+			defer delete(exportFormats, "test")
+
+			if err := exportAccount(context.Background(), a, o); err == nil {
+				t.Fatal("export succeeded, want the error from m4")
+			}
+			first := len(written)
+
+			ex = &batchExporter{batch: 2, written: &written}
+			o.resume = true
+			if err := exportAccount(context.Background(), a, o); err != nil {
+				t.Fatal(err)
+			}
+			if got := written[first:]; !reflect.DeepEqual(got, tt.resumed) {
+				t.Errorf("resumed export wrote %q, want %q", got, tt.resumed)
+			}
+			if _, err := os.Stat(filepath.Join(o.dir, checkpointFile)); !os.IsNotExist(err) {
+				t.Errorf("checkpoint left after the export completed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/parquet"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/pdf"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// An exporter writes messages in one export format.
+type exporter interface {
+	// format is the Gmail message format the exporter needs.
+	format() string
+
+	// export writes m and returns where it went, for printing.
+	export(ctx context.Context, m *gmail.Message) (string, error)
+
+	// close finishes the export. It may be called more than once.
+	close() error
+}
+
+// exportFormats maps the -format values of export to their exporters.
+var exportFormats = map[string]func(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error){
+	"body":     newBodyExporter,
+	"mbox":     newMboxExporter,
+	"maildir":  newMaildirExporter,
+	"eml":      newEMLExporter,
+	"csv":      newCSVExporter,
+	"pdf":      newPDFExporter,
+	"markdown": newMarkdownExporter,
+	"parquet":  newParquetExporter,
+	"bigquery": newBigQueryExporter,
+}
+
+// A bufferingExporter holds exported messages back, in memory or in a file
+// not yet moved into place, before writing them for good. The export
+// checkpoint only advances when durable reports that every message exported
+// so far has been written.
+type bufferingExporter interface {
+	durable() bool
+}
+
+// A fieldsExporter needs only some fields of each message.
+type fieldsExporter interface {
+	fields() []googleapi.Field
+}
+
+// exportFormatNames returns the -format values in sorted order.
+func exportFormatNames() []string {
+	names := make([]string, 0, len(exportFormats))
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bodyExporter writes the body of each message to <id>.html or <id>.txt,
+// depending on -prefer and the message.
+type bodyExporter struct {
+	srv  gmailclient.GmailService
+	user string
+	o    exportOptions
+}
+
+func newBodyExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	return &bodyExporter{srv: srv, user: a.user, o: o}, nil
+}
+
+func (e *bodyExporter) format() string { return "full" }
+
+func (e *bodyExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	parsed, err := gmailclient.ParseMessage(ctx, e.srv, m, e.user)
+	if err != nil {
+		return "", err
+	}
+	body, mimeType := parsed.Body(e.o.prefer)
+	ext := ".html"
+	if mimeType == "text/plain" {
+		ext = ".txt"
+	} else if e.o.inline != "none" {
+		if body, err = inlineImages(ctx, e.srv, e.user, m, body, e.o.dir, e.o.inline); err != nil {
+			return "", fmt.Errorf("inline images: %w", err)
+		}
+	}
+	path := filepath.Join(e.o.dir, m.Id+ext)
+	return path, atomicfile.WriteFile(path, []byte(body), 0644)
+}
+
+func (e *bodyExporter) close() error { return nil }
+
+// mboxFile is the name of the mbox file in the output directory.
+const mboxFile = "messages.mbox"
+
+// mboxExporter appends the raw messages to a single mbox file. A resumed
+// export appends to the existing file; otherwise it is replaced.
+type mboxExporter struct {
+	path string
+	f    *os.File
+	w    *gmailclient.MboxWriter
+}
+
+func newMboxExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	path := filepath.Join(o.dir, mboxFile)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !o.resume {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &mboxExporter{path: path, f: f, w: gmailclient.NewMboxWriter(f)}, nil
+}
+
+func (e *mboxExporter) format() string { return "raw" }
+
+func (e *mboxExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	return e.path + ": " + m.Id, e.w.WriteMessage(m)
+}
+
+func (e *mboxExporter) close() error {
+	if e.f == nil {
+		return nil
+	}
+	err := e.f.Close()
+	e.f = nil
+	return err
+}
+
+// maildirExporter stores the raw messages in <dir>/Maildir.
+type maildirExporter struct {
+	d *gmailclient.Maildir
+}
+
+func newMaildirExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	d, err := gmailclient.OpenMaildir(filepath.Join(o.dir, "Maildir"))
+	if err != nil {
+		return nil, err
+	}
+	return &maildirExporter{d: d}, nil
+}
+
+func (e *maildirExporter) format() string { return "raw" }
+
+func (e *maildirExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	return e.d.WriteMessage(m)
+}
+
+func (e *maildirExporter) close() error { return nil }
+
+// emlExporter writes each raw message unchanged to its own .eml file.
+type emlExporter struct {
+	dir string
+}
+
+func newEMLExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	return &emlExporter{dir: o.dir}, nil
+}
+
+func (e *emlExporter) format() string { return "raw" }
+
+func (e *emlExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	raw, err := gmailclient.RawMessage(m)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(e.dir, gmailclient.EMLFilename(m))
+	return path, atomicfile.WriteFile(path, raw, 0644)
+}
+
+func (e *emlExporter) close() error { return nil }
+
+// csvFile is the name of the CSV file in the output directory.
+const csvFile = "messages.csv"
+
+// csvExporter writes one row of metadata per message to messages.csv,
+// without fetching any bodies. A resumed export appends to the existing file.
+type csvExporter struct {
+	path string
+	f    *os.File
+	w    *csv.Writer
+}
+
+func newCSVExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	path := filepath.Join(o.dir, csvFile)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !o.resume {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	e := &csvExporter{path: path, f: f, w: csv.NewWriter(f)}
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		e.w.Write(gmailclient.CSVHeader)
+	}
+	return e, nil
+}
+
+func (e *csvExporter) format() string { return "full" }
+
+func (e *csvExporter) fields() []googleapi.Field { return gmailclient.StructureFields }
+
+func (e *csvExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	parsed, err := gmailclient.ParseMetadata(m)
+	if err != nil {
+		return "", err
+	}
+	e.w.Write(gmailclient.CSVRecord(parsed))
+	e.w.Flush()
+	return e.path + ": " + m.Id, e.w.Error()
+}
+
+func (e *csvExporter) close() error {
+	if e.f == nil {
+		return nil
+	}
+	err := e.f.Close()
+	e.f = nil
+	return err
+}
+
+// pdfExporter renders each message to <id>.pdf.
+type pdfExporter struct {
+	srv  gmailclient.GmailService
+	user string
+	dir  string
+	opts pdf.Options
+}
+
+func newPDFExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	return &pdfExporter{
+		srv:  srv,
+		user: a.user,
+		dir:  o.dir,
+		opts: pdf.Options{Prefer: o.prefer, FontFile: config.ExpandHome(o.pdfFont)},
+	}, nil
+}
+
+func (e *pdfExporter) format() string { return "full" }
+
+func (e *pdfExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	parsed, err := gmailclient.ParseMessage(ctx, e.srv, m, e.user)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := pdf.Render(&buf, parsed, e.opts); err != nil {
+		return "", err
+	}
+	path := filepath.Join(e.dir, m.Id+".pdf")
+	return path, atomicfile.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func (e *pdfExporter) close() error { return nil }
+
+// markdownExporter writes each message as a Markdown note, <id>.md.
+type markdownExporter struct {
+	srv  gmailclient.GmailService
+	user string
+	o    exportOptions
+}
+
+func newMarkdownExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	return &markdownExporter{srv: srv, user: a.user, o: o}, nil
+}
+
+func (e *markdownExporter) format() string { return "full" }
+
+func (e *markdownExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	parsed, err := gmailclient.ParseMessage(ctx, e.srv, m, e.user)
+	if err != nil {
+		return "", err
+	}
+	md, err := markdownDocument(parsed, e.o.prefer)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(e.o.dir, m.Id+".md")
+	return path, atomicfile.WriteFile(path, []byte(md), 0644)
+}
+
+func (e *markdownExporter) close() error { return nil }
+
+// parquetExporter writes one row per message to messages-<n>.parquet. A
+// Parquet file cannot be appended to, so a resumed export writes the next
+// part; a fresh export removes the parts of earlier runs.
+type parquetExporter struct {
+	srv     gmailclient.GmailService
+	user    string
+	bodies  bool
+	extract bool
+	path    string
+	f       *atomicfile.File
+	w       *parquet.Writer
+}
+
+func newParquetExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	parts, err := filepath.Glob(filepath.Join(o.dir, "messages-*.parquet"))
+	if err != nil {
+		return nil, err
+	}
+	if !o.resume {
+		for _, p := range parts {
+			if err := os.Remove(p); err != nil {
+				return nil, err
+			}
+		}
+		parts = nil
+	}
+	path := filepath.Join(o.dir, fmt.Sprintf("messages-%03d.parquet", len(parts)+1))
+	f, err := atomicfile.Create(path, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w, err := parquet.NewWriter(f, o.bodies)
+	if err != nil {
+		f.Abort()
+		return nil, err
+	}
+	return &parquetExporter{srv: srv, user: a.user, bodies: o.bodies, extract: o.extractText, path: path, f: f, w: w}, nil
+}
+
+func (e *parquetExporter) format() string { return "full" }
+
+func (e *parquetExporter) fields() []googleapi.Field {
+	if e.bodies || e.extract {
+		return nil
+	}
+	return gmailclient.StructureFields
+}
+
+func (e *parquetExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	parsed, err := parseRow(ctx, e.srv, e.user, m, e.bodies, e.extract)
+	if err != nil {
+		return "", err
+	}
+	return e.path + ": " + m.Id, e.w.Write(parsed)
+}
+
+// durable reports false: the rows are only kept once close has moved the
+// file into place.
+func (e *parquetExporter) durable() bool { return false }
+
+// close writes the footer and moves the file into place, so the rows
+// written before an error are kept.
+func (e *parquetExporter) close() error {
+	if e.f == nil {
+		return nil
+	}
+	f := e.f
+	e.f = nil
+	if err := e.w.Close(); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// parseRow parses m for a row of the parquet and bigquery formats: in full
+// if the bodies or attachment text are needed, else just its metadata.
+func parseRow(ctx context.Context, srv gmailclient.GmailService, user string, m *gmail.Message, bodies, extract bool) (*gmailclient.Message, error) {
+	if !bodies && !extract {
+		return gmailclient.ParseMetadata(m)
+	}
+	parsed, err := gmailclient.ParseMessage(ctx, srv, m, user)
+	if err != nil {
+		return nil, err
+	}
+	if extract {
+		if err := gmailclient.ExtractAttachmentText(ctx, srv, user, m, parsed); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// bigQueryBatch is how many rows bigQueryExporter streams per request.
+const bigQueryBatch = 500
+
+// bigQueryExporter streams one row per message into the -bigquery-table
+// table, in batches. It authorizes with Application Default Credentials,
+// since the table usually lives in a project of its own.
+type bigQueryExporter struct {
+	srv     gmailclient.GmailService
+	account *account
+	bodies  bool
+	extract bool
+	id      string
+	t       *gmailclient.BigQueryTable
+	rows    []map[string]bigquery.JsonValue
+}
+
+func newBigQueryExporter(ctx context.Context, srv gmailclient.GmailService, a *account, o exportOptions) (exporter, error) {
+	if o.bigQueryTable == "" {
+		return nil, fmt.Errorf("-format bigquery needs -bigquery-table project.dataset.table")
+	}
+	client, err := gmailclient.DefaultClient(ctx, "", gmailclient.BigQueryScope)
+	if err != nil {
+		return nil, err
+	}
+	t, err := gmailclient.OpenBigQueryTable(ctx, gmailclient.WithRetry(client, retryPolicy()), o.bigQueryTable)
+	if err != nil {
+		return nil, err
+	}
+	return &bigQueryExporter{srv: srv, account: a, bodies: o.bodies, extract: o.extractText, id: o.bigQueryTable, t: t}, nil
+}
+
+func (e *bigQueryExporter) format() string { return "full" }
+
+func (e *bigQueryExporter) fields() []googleapi.Field {
+	if e.bodies || e.extract {
+		return nil
+	}
+	return gmailclient.StructureFields
+}
+
+func (e *bigQueryExporter) export(ctx context.Context, m *gmail.Message) (string, error) {
+	parsed, err := parseRow(ctx, e.srv, e.account.user, m, e.bodies, e.extract)
+	if err != nil {
+		return "", err
+	}
+	// Rows name their mailbox the way the cache does, so several accounts
+	// can share a table.
+	e.rows = append(e.rows, gmailclient.BigQueryRow(cacheAccount(e.account), parsed, e.bodies))
+	if len(e.rows) >= bigQueryBatch {
+		if err := e.flush(ctx); err != nil {
+			// m is not exported, so only the rows of the messages
+			// before it are left for close.
+			e.rows = e.rows[:len(e.rows)-1]
+			return "", err
+		}
+	}
+	return e.id + ": " + m.Id, nil
+}
+
+// flush streams the buffered rows. They are kept if the insert fails, for
+// close to try again.
+func (e *bigQueryExporter) flush(ctx context.Context) error {
+	if err := e.t.Insert(ctx, e.rows); err != nil {
+		return err
+	}
+	e.rows = nil
+	return nil
+}
+
+// durable reports whether every buffered row has been streamed.
+func (e *bigQueryExporter) durable() bool { return len(e.rows) == 0 }
+
+// close streams the rows still buffered, so the messages exported before an
+// error are kept.
+func (e *bigQueryExporter) close() error {
+	return e.flush(context.Background())
+}
+
+// inlineImages rewrites the cid: links in the HTML body of m. With mode
+// "data" the images are embedded as data URIs; with "files" they are saved
+// in <dir>/<id>_files and linked by relative path.
+func inlineImages(ctx context.Context, srv gmailclient.GmailService, user string, m *gmail.Message, body, dir, mode string) (string, error) {
+	if !strings.Contains(strings.ToLower(body), "cid:") {
+		return body, nil
+	}
+	parts, err := gmailclient.GetInlineParts(ctx, srv, m, user)
+	if err != nil || len(parts) == 0 {
+		return body, err
+	}
+	if mode == "data" {
+		return gmailclient.ResolveCIDs(body, parts, (*gmailclient.InlinePart).DataURI), nil
+	}
+
+	filesDir := m.Id + "_files"
+	if err := os.MkdirAll(filepath.Join(dir, filesDir), 0755); err != nil {
+		return "", err
+	}
+	links := make(map[*gmailclient.InlinePart]string)
+	used := make(map[string]bool)
+	n := 0
+	for _, p := range parts {
+		n++
+		name := filepath.Base(p.Filename)
+		if p.Filename == "" || name == "." || name == string(filepath.Separator) {
+			name = fmt.Sprintf("inline-%d", n)
+			if exts, _ := mime.ExtensionsByType(p.MimeType); len(exts) > 0 {
+				name += exts[0]
+			}
+		}
+		if used[name] {
+			name = fmt.Sprintf("%d-%s", n, name)
+		}
+		used[name] = true
+		if err := atomicfile.WriteFile(filepath.Join(dir, filesDir, name), p.Data, 0644); err != nil {
+			return "", err
+		}
+		links[p] = filesDir + "/" + url.PathEscape(name)
+	}
+	return gmailclient.ResolveCIDs(body, parts, func(p *gmailclient.InlinePart) string {
+		return links[p]
+	}), nil
+}
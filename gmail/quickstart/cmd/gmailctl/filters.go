@@ -0,0 +1,376 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name: "filters",
+		usage: "list [-output text|json|ndjson] | create [filter flags] [-create-labels] | delete <id>... | " +
+			"export [-file path] | import [-file path] [-delete] [-create-labels] [-dry-run]",
+		summary: "List, create and delete filters, or keep them in a YAML file (needs the gmail.settings.basic scope).",
+		run:     runFilters,
+	})
+}
+
+// filterFlags are the flags that describe a filter to create.
+type filterFlags struct {
+	spec        gmailclient.FilterSpec
+	add, remove stringList
+	larger      int64
+	smaller     int64
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.spec.From, "from", "", "match the sender")
+	fs.StringVar(&f.spec.To, "to", "", "match the recipients")
+	fs.StringVar(&f.spec.Subject, "subject", "", "match words in the subject")
+	fs.StringVar(&f.spec.Query, "query", "", "match a Gmail search query")
+	fs.StringVar(&f.spec.NegatedQuery, "negated-query", "", "match messages not matching a Gmail search query")
+	fs.BoolVar(&f.spec.HasAttachment, "has-attachment", false, "match messages with attachments")
+	fs.BoolVar(&f.spec.ExcludeChats, "exclude-chats", false, "do not match chats")
+	fs.Int64Var(&f.larger, "larger", 0, "match messages larger than this many bytes")
+	fs.Int64Var(&f.smaller, "smaller", 0, "match messages smaller than this many bytes")
+	fs.Var(&f.add, "add-label", "label to add, by name or ID (repeatable); remove INBOX to archive")
+	fs.Var(&f.remove, "remove-label", "label to remove, by name or ID (repeatable)")
+	fs.StringVar(&f.spec.Forward, "forward", "", "forward to this verified forwarding address (needs gmail.settings.sharing)")
+}
+
+// filterSpec returns the filter the flags describe.
+func (f *filterFlags) filterSpec() (*gmailclient.FilterSpec, error) {
+	s := f.spec
+	s.AddLabels, s.RemoveLabels = f.add, f.remove
+	switch {
+	case f.larger > 0 && f.smaller > 0:
+		return nil, fmt.Errorf("-larger and -smaller cannot be used together")
+	case f.larger > 0:
+		s.Size, s.SizeComparison = f.larger, "larger"
+	case f.smaller > 0:
+		s.Size, s.SizeComparison = f.smaller, "smaller"
+	}
+	return &s, nil
+}
+
+func runFilters(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["filters"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("filters: expected a subcommand")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+
+	fs = newFlagSet(commands["filters"])
+	var ff filterFlags
+	var output *string
+	var file string
+	var createLabels, prune, dryRun bool
+	switch sub {
+	case "list":
+		output = outputFlag(fs)
+	case "create":
+		ff.register(fs)
+		fs.BoolVar(&createLabels, "create-labels", false, "create labels given to -add-label that do not exist")
+	case "delete":
+	case "export":
+		fs.StringVar(&file, "file", "", "write the filters to this file instead of stdout")
+	case "import":
+		fs.StringVar(&file, "file", "", "read the filters from this file instead of stdin")
+		fs.BoolVar(&prune, "delete", false, "also delete the filters missing from the file")
+		fs.BoolVar(&createLabels, "create-labels", false, "create labels the filters add that do not exist")
+		fs.BoolVar(&dryRun, "dry-run", false, "only print the filters that would be created and deleted")
+	default:
+		fs.Usage()
+		return fmt.Errorf("filters: unknown subcommand %q", sub)
+	}
+	fs.Parse(args)
+	if sub == "delete" && fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("filters delete: expected filter IDs")
+	}
+	if sub != "delete" && fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("filters %s: unexpected arguments", sub)
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "list":
+		if err := checkOutput(*output); err != nil {
+			return err
+		}
+		return listFilters(ctx, a, *output)
+	case "create":
+		s, err := ff.filterSpec()
+		if err != nil {
+			return err
+		}
+		labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to list labels: %w", err)
+		}
+		specs := []*gmailclient.FilterSpec{s}
+		if createLabels {
+			if labels, err = createMissingLabels(ctx, a, labels, specs, false); err != nil {
+				return err
+			}
+		}
+		return createFilters(ctx, a, specs, labels, false)
+	case "delete":
+		for _, id := range fs.Args() {
+			if err := gmailclient.DeleteFilter(ctx, a.api(), a.user, id); err != nil {
+				return fmt.Errorf("Unable to delete filter %s: %w", id, err)
+			}
+		}
+		return nil
+	case "export":
+		return exportFilters(ctx, a, file)
+	}
+	return importFilters(ctx, a, file, prune, createLabels, dryRun)
+}
+
+// filterSpecs returns the filters of a as specs, with label names.
+func filterSpecs(ctx context.Context, a *account) ([]*gmailclient.FilterSpec, []*gmail.Filter, []*gmail.Label, error) {
+	filters, err := gmailclient.ListFilters(ctx, a.api(), a.user)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to list filters (is gmail.settings.basic among -scopes?): %w", err)
+	}
+	labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to list labels: %w", err)
+	}
+	specs := make([]*gmailclient.FilterSpec, len(filters))
+	for i, f := range filters {
+		specs[i] = gmailclient.NewFilterSpec(f, labels)
+	}
+	return specs, filters, labels, nil
+}
+
+// listFilters prints the filters of a, one per line with their criteria
+// and actions.
+func listFilters(ctx context.Context, a *account, output string) error {
+	specs, _, _, err := filterSpecs(ctx, a)
+	if err != nil {
+		return err
+	}
+	if output != "text" {
+		j := newJSONWriter(os.Stdout, output)
+		for _, s := range specs {
+			if err := j.write(s); err != nil {
+				return err
+			}
+		}
+		return j.close()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCRITERIA\tACTIONS")
+	for _, s := range specs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.ID, describeCriteria(s), describeActions(s))
+	}
+	return w.Flush()
+}
+
+// describeCriteria returns the criteria of s in Gmail search syntax.
+func describeCriteria(s *gmailclient.FilterSpec) string {
+	var terms []string
+	add := func(format, v string) {
+		if v != "" {
+			terms = append(terms, fmt.Sprintf(format, v))
+		}
+	}
+	add("from:(%s)", s.From)
+	add("to:(%s)", s.To)
+	add("subject:(%s)", s.Subject)
+	add("%s", s.Query)
+	add("-(%s)", s.NegatedQuery)
+	if s.HasAttachment {
+		terms = append(terms, "has:attachment")
+	}
+	if s.ExcludeChats {
+		terms = append(terms, "-in:chats")
+	}
+	if s.Size > 0 {
+		terms = append(terms, fmt.Sprintf("%s:%d", s.SizeComparison, s.Size))
+	}
+	return strings.Join(terms, " ")
+}
+
+// describeActions returns the actions of s, labels added as +name and
+// removed as -name.
+func describeActions(s *gmailclient.FilterSpec) string {
+	var actions []string
+	for _, l := range s.AddLabels {
+		actions = append(actions, "+"+l)
+	}
+	for _, l := range s.RemoveLabels {
+		actions = append(actions, "-"+l)
+	}
+	if s.Forward != "" {
+		actions = append(actions, "forward:"+s.Forward)
+	}
+	return strings.Join(actions, " ")
+}
+
+// exportFilters writes the filters of a as a filters file to path, or to
+// stdout if path is empty.
+func exportFilters(ctx context.Context, a *account, path string) error {
+	specs, _, _, err := filterSpecs(ctx, a)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return gmailclient.WriteFilterSpecs(os.Stdout, specs)
+	}
+	var buf bytes.Buffer
+	if err := gmailclient.WriteFilterSpecs(&buf, specs); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// importFilters makes the filters of a match the filters file at path, or
+// stdin if path is empty: it creates the filters that are missing and, if
+// prune is set, deletes those not in the file.
+func importFilters(ctx context.Context, a *account, path string, prune, createLabels, dryRun bool) error {
+	var r io.Reader = os.Stdin
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Unable to read filters: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	want, err := gmailclient.ReadFilterSpecs(r)
+	if err != nil {
+		return fmt.Errorf("Unable to read filters: %w", err)
+	}
+	return applyFilters(ctx, a, want, prune, createLabels, dryRun)
+}
+
+// applyFilters makes the filters of a match want: it creates the filters
+// that are missing and, if prune is set, deletes the others.
+func applyFilters(ctx context.Context, a *account, want []*gmailclient.FilterSpec, prune, createLabels, dryRun bool) error {
+	_, have, labels, err := filterSpecs(ctx, a)
+	if err != nil {
+		return err
+	}
+	if createLabels {
+		if labels, err = createMissingLabels(ctx, a, labels, want, dryRun); err != nil {
+			return err
+		}
+	}
+	wantFilters := make([]*gmail.Filter, len(want))
+	byFilter := map[*gmail.Filter]*gmailclient.FilterSpec{}
+	for i, s := range want {
+		f, err := s.Filter(labels)
+		if errors.Is(err, gmailclient.ErrLabelNotFound) {
+			return fmt.Errorf("filter %d: %w (use -create-labels to create it)", i+1, err)
+		}
+		if err != nil {
+			return fmt.Errorf("filter %d: %w", i+1, err)
+		}
+		wantFilters[i] = f
+		byFilter[f] = s
+	}
+
+	create, remove := gmailclient.PlanFilters(have, wantFilters)
+	if !prune {
+		remove = nil
+	}
+	for _, f := range remove {
+		s := gmailclient.NewFilterSpec(f, labels)
+		fmt.Printf("delete\t%s\t%s\t%s\n", f.Id, describeCriteria(s), describeActions(s))
+		if dryRun {
+			continue
+		}
+		if err := gmailclient.DeleteFilter(ctx, a.api(), a.user, f.Id); err != nil {
+			return fmt.Errorf("Unable to delete filter %s: %w", f.Id, err)
+		}
+	}
+	var specs []*gmailclient.FilterSpec
+	for _, f := range create {
+		specs = append(specs, byFilter[f])
+	}
+	return createFilters(ctx, a, specs, labels, dryRun)
+}
+
+// createFilters creates the filters specs describe in a, looking their
+// labels up in labels, and prints each.
+func createFilters(ctx context.Context, a *account, specs []*gmailclient.FilterSpec, labels []*gmail.Label, dryRun bool) error {
+	for _, s := range specs {
+		if dryRun {
+			fmt.Printf("create\t\t%s\t%s\n", describeCriteria(s), describeActions(s))
+			continue
+		}
+		f, err := s.Filter(labels)
+		if err != nil {
+			return err
+		}
+		f.Id = ""
+		created, err := gmailclient.CreateFilter(ctx, a.api(), a.user, f)
+		if err != nil {
+			return fmt.Errorf("Unable to create filter %s: %w", describeCriteria(s), err)
+		}
+		fmt.Printf("create\t%s\t%s\t%s\n", created.Id, describeCriteria(s), describeActions(s))
+	}
+	return nil
+}
+
+// createMissingLabels creates the labels specs add that are not among
+// labels, and returns labels with them. With dryRun it only reports them,
+// and stands them in with their names as IDs.
+func createMissingLabels(ctx context.Context, a *account, labels []*gmail.Label, specs []*gmailclient.FilterSpec, dryRun bool) ([]*gmail.Label, error) {
+	for _, s := range specs {
+		for _, name := range s.AddLabels {
+			if _, err := gmailclient.FindLabel(labels, name); err == nil {
+				continue
+			}
+			if dryRun {
+				fmt.Fprintf(os.Stderr, "Would create label %s.\n", name)
+				labels = append(labels, &gmail.Label{Id: name, Name: name})
+				continue
+			}
+			l, err := gmailclient.CreateLabel(ctx, a.api(), a.user, &gmail.Label{Name: name}, true)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to create label %s (is gmail.labels among -scopes?): %w", name, err)
+			}
+			fmt.Fprintf(os.Stderr, "Created label %s.\n", l.Name)
+			labels = append(labels, l)
+		}
+	}
+	return labels, nil
+}
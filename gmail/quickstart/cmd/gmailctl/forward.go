@@ -0,0 +1,121 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func init() {
+	register(&command{
+		name:    "forward",
+		usage:   "-to addrs [-cc addrs] [-bcc addrs] [-as inline|attachment] [-body text|-body-file path] [-html text|-html-file path] [-attach file]... [-dry-run] <message-id>",
+		summary: "Forward a message with its attachments (needs the gmail.send scope).",
+		run:     runForward,
+	})
+}
+
+func runForward(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["forward"])
+	var c composeFlags
+	c.register(fs)
+	as := fs.String("as", "inline", "inline: quote the message and carry its attachments along, as Gmail does; attachment: attach the original as a .eml file")
+	dryRun := fs.Bool("dry-run", false, "print the message instead of sending it")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("forward: expected exactly one message ID")
+	}
+	id := fs.Arg(0)
+	if *as != "inline" && *as != "attachment" {
+		return fmt.Errorf("invalid -as %q (want inline or attachment)", *as)
+	}
+	f, err := c.message()
+	if err != nil {
+		return err
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	msg, err := client.GetMessage(ctx, a.user, id, "full")
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve message %v: %w", id, err)
+	}
+	orig, err := gmailclient.ParseMessage(ctx, client, msg, a.user)
+	if err != nil {
+		return err
+	}
+	if f.Subject == "" {
+		f.Subject = gmailclient.NewForward(orig).Subject
+	}
+
+	if *as == "attachment" {
+		rawMsg, err := client.GetMessage(ctx, a.user, id, "raw")
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve message %v: %w", id, err)
+		}
+		raw, err := gmailclient.RawMessage(rawMsg)
+		if err != nil {
+			return err
+		}
+		f.AttachMessage(orig, raw)
+	} else {
+		f.Text = joinBody(f.Text, gmailclient.ForwardedText(orig))
+		if f.HTML != "" || orig.BodyHtml != "" {
+			f.HTML = joinBody(f.HTML, gmailclient.ForwardedHTML(orig))
+		}
+		atts, err := gmailclient.ForwardAttachments(ctx, client, a.user, msg, orig)
+		if err != nil {
+			return fmt.Errorf("Unable to download attachments: %w", err)
+		}
+		f.Attachments = append(atts, f.Attachments...)
+	}
+
+	raw, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		_, err := os.Stdout.Write(raw)
+		return err
+	}
+	sent, err := gmailclient.SendMessage(ctx, a.api(), a.user, raw, "")
+	if err != nil {
+		return fmt.Errorf("Unable to send message (is gmail.send among -scopes?): %w", err)
+	}
+	fmt.Println(sent.Id)
+	return nil
+}
+
+// joinBody puts the forwarded message below the sender's own text.
+func joinBody(own, forwarded string) string {
+	if own == "" {
+		return forwarded
+	}
+	return own + "\n\n" + forwarded
+}
@@ -0,0 +1,147 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func runForwarding(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("forwarding", args, "list", "get", "add", "delete", "enable", "disable")
+	if err != nil {
+		return err
+	}
+	var output *string
+	var disposition string
+	switch action {
+	case "list", "get":
+		output = outputFlag(fs)
+	case "enable":
+		fs.StringVar(&disposition, "disposition", "leaveInInbox", "what to do with forwarded messages: "+strings.Join(gmailclient.ForwardingDispositions, ", "))
+	}
+	fs.Parse(args)
+	var email string
+	switch action {
+	case "add", "delete", "enable":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return fmt.Errorf("settings forwarding %s: expected one address", action)
+		}
+		email = fs.Arg(0)
+	default:
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return fmt.Errorf("settings forwarding %s: unexpected arguments", action)
+		}
+	}
+	if output != nil {
+		if err := checkOutput(*output); err != nil {
+			return err
+		}
+	}
+	if action == "enable" && !oneOf(gmailclient.ForwardingDispositions, disposition) {
+		return fmt.Errorf("invalid -disposition %q (want one of %s)", disposition, strings.Join(gmailclient.ForwardingDispositions, ", "))
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	out := "text"
+	if output != nil {
+		out = *output
+	}
+	return forwardingAction(ctx, a.api(), a.user, action, email, disposition, out)
+}
+
+// forwardingAction runs settings forwarding action on user's mailbox: email
+// is the address to add, delete or forward to, and disposition what enable
+// has Gmail do with the forwarded messages.
+func forwardingAction(ctx context.Context, srv gmailclient.ForwardingService, user, action, email, disposition, output string) error {
+	switch action {
+	case "list":
+		addrs, err := gmailclient.ListForwardingAddresses(ctx, srv, user)
+		if err != nil {
+			return fmt.Errorf("Unable to list forwarding addresses: %w", err)
+		}
+		if output != "text" {
+			j := newJSONWriter(os.Stdout, output)
+			for _, f := range addrs {
+				if err := j.write(f); err != nil {
+					return err
+				}
+			}
+			return j.close()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ADDRESS\tVERIFICATION")
+		for _, f := range addrs {
+			fmt.Fprintf(w, "%s\t%s\n", f.ForwardingEmail, f.VerificationStatus)
+		}
+		return w.Flush()
+	case "get":
+		f, err := gmailclient.GetAutoForwarding(ctx, srv, user)
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve forwarding settings: %w", err)
+		}
+		if output != "text" {
+			j := newJSONWriter(os.Stdout, output)
+			if err := j.write(f); err != nil {
+				return err
+			}
+			return j.close()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Enabled:\t%s\n", yesNo(f.Enabled))
+		fmt.Fprintf(w, "Address:\t%s\n", f.EmailAddress)
+		fmt.Fprintf(w, "Disposition:\t%s\n", f.Disposition)
+		return w.Flush()
+	case "add":
+		f, err := gmailclient.CreateForwardingAddress(ctx, srv, user, email)
+		if err != nil {
+			return fmt.Errorf("Unable to add forwarding address (is gmail.settings.sharing among -scopes?): %w", err)
+		}
+		if f.VerificationStatus == "pending" {
+			fmt.Printf("%s added; Gmail sent it a confirmation link to follow before forwarding can be enabled.\n", f.ForwardingEmail)
+		} else {
+			fmt.Printf("%s added.\n", f.ForwardingEmail)
+		}
+		return nil
+	case "delete":
+		if err := gmailclient.DeleteForwardingAddress(ctx, srv, user, email); err != nil {
+			return fmt.Errorf("Unable to delete forwarding address (is gmail.settings.sharing among -scopes?): %w", err)
+		}
+		return nil
+	}
+
+	f := &gmail.AutoForwarding{Enabled: false, ForceSendFields: []string{"Enabled"}}
+	if action == "enable" {
+		f = &gmail.AutoForwarding{Enabled: true, EmailAddress: email, Disposition: disposition}
+	}
+	if _, err := gmailclient.UpdateAutoForwarding(ctx, srv, user, f); err != nil {
+		return fmt.Errorf("Unable to update forwarding settings (is gmail.settings.sharing among -scopes?): %w", err)
+	}
+	return nil
+}
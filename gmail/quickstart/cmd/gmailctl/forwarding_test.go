@@ -0,0 +1,63 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestForwardingAction(t *testing.T) {
+	ctx := context.Background()
+	s := gmailclienttest.New()
+	for _, email := range []string{"archive@example.com", "ops@example.com"} {
+		if err := forwardingAction(ctx, s, "me", "add", email, "", "text"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.ForwardingAddresses) != 2 || s.ForwardingAddresses[1].ForwardingEmail != "ops@example.com" {
+		t.Fatalf("forwarding addresses after add = %+v", s.ForwardingAddresses)
+	}
+
+	if err := forwardingAction(ctx, s, "me", "enable", "archive@example.com", "archive", "text"); err != nil {
+		t.Fatal(err)
+	}
+	want := gmail.AutoForwarding{Enabled: true, EmailAddress: "archive@example.com", Disposition: "archive"}
+	if f := s.AutoForwarding; f == nil || f.Enabled != want.Enabled || f.EmailAddress != want.EmailAddress || f.Disposition != want.Disposition {
+		t.Errorf("auto-forwarding after enable = %+v, want %+v", f, want)
+	}
+	if err := forwardingAction(ctx, s, "me", "disable", "", "", "text"); err != nil {
+		t.Fatal(err)
+	}
+	if f := s.AutoForwarding; f.Enabled || !oneOf(f.ForceSendFields, "Enabled") {
+		t.Errorf("auto-forwarding after disable = %+v, want Enabled sent as false", f)
+	}
+
+	if err := forwardingAction(ctx, s, "me", "delete", "ops@example.com", "", "text"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.ForwardingAddresses) != 1 || s.ForwardingAddresses[0].ForwardingEmail != "archive@example.com" {
+		t.Errorf("forwarding addresses after delete = %+v", s.ForwardingAddresses)
+	}
+	if err := forwardingAction(ctx, s, "me", "delete", "ops@example.com", "", "text"); err == nil {
+		t.Error("deleting a missing forwarding address succeeded")
+	}
+}
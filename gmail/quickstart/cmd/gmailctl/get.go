@@ -0,0 +1,217 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func init() {
+	register(&command{
+		name:    "get",
+		usage:   "[-prefer auto|plain|html] [-output text|json|markdown] [-extract-text] [-show-events] [-verify [-smime-roots file]] <message-id>",
+		summary: "Print the headers and body of a single message.",
+		run:     runGet,
+	})
+}
+
+func runGet(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["get"])
+	prefer := preferFlag(fs)
+	output := fs.String("output", "text", "output format: text, json, or markdown for notes")
+	fs.StringVar(output, "format", "text", "alias for -output")
+	extract := fs.Bool("extract-text", false, "extract the text of PDF, DOCX and XLSX attachments")
+	showEvents := fs.Bool("show-events", false, "print the calendar events of invitations after the headers")
+	verify := fs.Bool("verify", false, "check the S/MIME signature of signed messages")
+	smimeRoots := fs.String("smime-roots", "", "PEM file of CA certificates to trust for -verify besides the system roots")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("get: expected exactly one message ID")
+	}
+	id := fs.Arg(0)
+	if err := checkPrefer(*prefer); err != nil {
+		return err
+	}
+	switch *output {
+	case "text", "json", "markdown":
+	default:
+		return fmt.Errorf("invalid -output %q (want text, json or markdown)", *output)
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	msg, err := client.GetMessage(ctx, a.user, id, "full")
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve message %v: %w", id, err)
+	}
+	m, err := gmailclient.ParseMessage(ctx, client, msg, a.user)
+	if err != nil {
+		return err
+	}
+	if *extract {
+		if err := gmailclient.ExtractAttachmentText(ctx, client, a.user, msg, m); err != nil {
+			return fmt.Errorf("Unable to extract attachment text: %w", err)
+		}
+	}
+	if *verify && m.Signature != nil && m.Signature.Protocol == "S/MIME" {
+		if m.Signature, err = verifySMIME(ctx, client, a.user, id, *smimeRoots); err != nil {
+			return err
+		}
+	}
+	switch *output {
+	case "markdown":
+		md, err := markdownDocument(m, *prefer)
+		if err != nil {
+			return err
+		}
+		fmt.Print(md)
+		return nil
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}
+
+	body, _ := m.Body(*prefer)
+	fmt.Printf("From: %s\nTo: %s\n", gmailclient.FormatAddressList(m.From), gmailclient.FormatAddressList(m.To))
+	if len(m.Cc) > 0 {
+		fmt.Printf("Cc: %s\n", gmailclient.FormatAddressList(m.Cc))
+	}
+	fmt.Printf("Date: %s\nSubject: %s\n", m.Date.Format(time.RFC1123Z), m.Subject)
+	if m.Signature != nil {
+		fmt.Printf("Signature: %s\n", describeSignature(m.Signature))
+	}
+	if *showEvents {
+		for _, e := range m.Events {
+			printEvent(e)
+		}
+	}
+	fmt.Printf("\n%s\n", body)
+	for _, att := range m.Attachments {
+		if att.Text != "" {
+			fmt.Printf("\n--- %s ---\n%s\n", att.Filename, att.Text)
+		}
+	}
+	return nil
+}
+
+// verifySMIME downloads message id and checks its S/MIME signature against
+// the system roots and those in the PEM file roots, if not empty.
+func verifySMIME(ctx context.Context, client gmailclient.GmailService, user, id, roots string) (*gmailclient.Signature, error) {
+	var pool *x509.CertPool
+	if roots != "" {
+		b, err := ioutil.ReadFile(roots)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read -smime-roots: %w", err)
+		}
+		if pool, err = x509.SystemCertPool(); err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("-smime-roots %s holds no certificates", roots)
+		}
+	}
+	msg, err := client.GetMessage(ctx, user, id, "raw")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve message %v: %w", id, err)
+	}
+	raw, err := gmailclient.RawMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	s, err := gmailclient.VerifySMIME(raw, pool)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to verify signature: %w", err)
+	}
+	return s, nil
+}
+
+// describeSignature returns a one-line summary of s.
+func describeSignature(s *gmailclient.Signature) string {
+	switch {
+	case !s.Verified:
+		return s.Protocol + ", not verified"
+	case !s.Valid:
+		return fmt.Sprintf("%s, INVALID: %s", s.Protocol, s.Error)
+	case s.SignedAt.IsZero():
+		return fmt.Sprintf("%s, valid, signed by %s", s.Protocol, s.Signer)
+	}
+	return fmt.Sprintf("%s, valid, signed by %s, signed at %s (claimed)", s.Protocol, s.Signer, s.SignedAt.Format(time.RFC1123Z))
+}
+
+// printEvent prints a calendar event as an indented block of headers.
+func printEvent(e *gmailclient.Event) {
+	when := e.Start.Format("Mon, 2 Jan 2006 15:04") + " - " + e.End.Format("15:04 MST")
+	if e.AllDay {
+		when = e.Start.Format("Mon, 2 Jan 2006") + " (all day)"
+		if days := int(e.End.Sub(e.Start).Hours() / 24); days > 1 {
+			when = fmt.Sprintf("%s (%d days)", e.Start.Format("Mon, 2 Jan 2006"), days)
+		}
+	}
+	method := e.Method
+	if method == "" {
+		method = "PUBLISH"
+	}
+	fmt.Printf("Event: %s [%s]\n  When: %s\n", e.Summary, method, when)
+	if e.Location != "" {
+		fmt.Printf("  Where: %s\n", e.Location)
+	}
+	if e.Organizer != nil {
+		fmt.Printf("  Organizer: %s\n", e.Organizer)
+	}
+	for _, a := range e.Attendees {
+		status := a.Status
+		if status == "" {
+			status = "NEEDS-ACTION"
+		}
+		fmt.Printf("  Attendee: %s (%s)\n", a.Address, strings.ToLower(status))
+	}
+}
+
+// preferFlag adds the -prefer flag choosing between plain-text and HTML
+// bodies to fs.
+func preferFlag(fs *flag.FlagSet) *string {
+	return fs.String("prefer", "auto", "body to use: plain, html, or auto for the one the sender ranked best")
+}
+
+// checkPrefer validates a -prefer value.
+func checkPrefer(prefer string) error {
+	switch prefer {
+	case "auto", "plain", "html":
+		return nil
+	}
+	return fmt.Errorf("invalid -prefer %q (want auto, plain or html)", prefer)
+}
@@ -0,0 +1,188 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name:    "grep",
+		usage:   "[-query q] " + queryFlagsUsage + " [-max-results n] [-i] [-C n] [-headers] [-html] [-l] [-count] [-output text|json|ndjson] <regexp>",
+		summary: "Search the decoded bodies of the messages matching a Gmail query with a Go regular expression.",
+		run:     runGrep,
+	})
+}
+
+// grepResult is a message with matching lines in JSON output.
+type grepResult struct {
+	Account string                   `json:"account,omitempty"`
+	Id      string                   `json:"id"`
+	Date    time.Time                `json:"date"`
+	From    string                   `json:"from"`
+	Subject string                   `json:"subject"`
+	Matches []*gmailclient.GrepMatch `json:"matches"`
+}
+
+func runGrep(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["grep"])
+	query := fs.String("query", profile.Query, "Gmail search query selecting the messages to search")
+	max := fs.Int64("max-results", 100, "maximum number of messages to search, 0 for all")
+	ignoreCase := fs.Bool("i", false, "ignore case")
+	var opts gmailclient.GrepOptions
+	fs.IntVar(&opts.Context, "C", 0, "print this many lines of context around each match")
+	fs.BoolVar(&opts.Headers, "headers", false, "also search the header values")
+	fs.BoolVar(&opts.HTML, "html", false, "search the HTML source of HTML bodies, links included, instead of the text")
+	filesOnly := fs.Bool("l", false, "only print the IDs of the messages that match")
+	count := fs.Bool("count", false, "only print the number of matching lines of each message that matches")
+	qf := addQueryFlags(fs)
+	output := outputFlag(fs)
+	// Flags may follow the pattern, as in grep 'AB-\d+' -query label:receipts.
+	var rest []string
+	for fs.Parse(args); fs.NArg() > 0; fs.Parse(args) {
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(rest) != 1 {
+		fs.Usage()
+		return fmt.Errorf("grep: expected exactly one regular expression")
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	q, err := qf.apply(*query)
+	if err != nil {
+		return err
+	}
+	*query = q
+	pattern := rest[0]
+	if *ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	var j *jsonWriter
+	if *output != "text" {
+		j = newJSONWriter(os.Stdout, *output)
+	}
+	found := 0
+	err = forEachAccount(ctx, accounts, func(a *account) error {
+		client, err := a.client()
+		if err != nil {
+			return err
+		}
+		fetch := gmailclient.FetchOptions{Query: *query, MaxResults: *max, Concurrency: concurrency}
+		err = gmailclient.ParseMessages(ctx, client, a.user, fetch, func(_ *gmail.Message, m *gmailclient.Message) error {
+			matches := gmailclient.GrepMessage(m, re, opts)
+			if len(matches) == 0 {
+				return nil
+			}
+			found++
+			if j != nil {
+				r := &grepResult{Id: m.Id, Date: m.Date, From: gmailclient.FormatAddressList(m.From), Subject: m.Subject, Matches: matches}
+				if multiAccount() {
+					r.Account = a.name
+				}
+				return j.write(r)
+			}
+			prefix := m.Id
+			if multiAccount() {
+				prefix = a.name + "\t" + prefix
+			}
+			switch {
+			case *filesOnly:
+				fmt.Println(prefix)
+			case *count:
+				fmt.Printf("%s\t%d\n", prefix, len(matches))
+			default:
+				printGrepMatches(prefix, m, matches)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to fetch messages: %w", err)
+		}
+		return nil
+	})
+	if j != nil {
+		if cerr := j.close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil && found == 0 {
+		fmt.Fprintln(os.Stderr, "No matches.")
+	}
+	return err
+}
+
+// printGrepMatches prints the matching lines of m below a line naming it,
+// grep style: matching lines as line: text, context lines as line- text and
+// "--" between separate groups.
+func printGrepMatches(prefix string, m *gmailclient.Message, matches []*gmailclient.GrepMatch) {
+	fmt.Printf("%s\t%s\t%s\t%s\n", prefix, m.Date.Local().Format("2006-01-02 15:04"), gmailclient.FormatAddressList(m.From), m.Subject)
+	lines := map[int]string{}
+	matched := map[int]bool{}
+	var numbers []int
+	add := func(n int, text string) {
+		if _, ok := lines[n]; !ok {
+			lines[n] = text
+			numbers = append(numbers, n)
+		}
+	}
+	for _, g := range matches {
+		if g.Part != "body" {
+			fmt.Printf("  %s: %s\n", g.Part, g.Text)
+			continue
+		}
+		for i, line := range g.Before {
+			add(g.Line-len(g.Before)+i, line)
+		}
+		add(g.Line, g.Text)
+		matched[g.Line] = true
+		for i, line := range g.After {
+			add(g.Line+1+i, line)
+		}
+	}
+	sort.Ints(numbers)
+	for i, n := range numbers {
+		if i > 0 && n > numbers[i-1]+1 {
+			fmt.Println("  --")
+		}
+		sep := "-"
+		if matched[n] {
+			sep = ":"
+		}
+		fmt.Printf("  %d%s %s\n", n, sep, lines[n])
+	}
+}
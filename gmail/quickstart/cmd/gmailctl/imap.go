@@ -0,0 +1,280 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+// Values the IMAP and POP settings take, as the API spells them.
+var (
+	expungeBehaviors = []string{"archive", "trash", "deleteForever"}
+	imapFolderSizes  = []int64{0, 1000, 2000, 5000, 10000}
+	popAccessWindows = []string{"fromNowOn", "allMail"}
+)
+
+// imapFlags are the flags of settings imap enable. The settings whose flag
+// is not given are left as they are.
+type imapFlags struct {
+	autoExpunge bool
+	expunge     string
+	folderSize  int64
+	set         map[string]bool
+}
+
+func (f *imapFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.autoExpunge, "auto-expunge", false, "expunge messages as soon as a client marks them deleted (default unchanged)")
+	fs.StringVar(&f.expunge, "expunge-behavior", "", "what happens to expunged messages: "+strings.Join(expungeBehaviors, ", ")+" (default unchanged)")
+	fs.Int64Var(&f.folderSize, "max-folder-size", 0, "most messages a folder shows to clients: 1000, 2000, 5000 or 10000; 0 for no limit (default unchanged)")
+}
+
+// parsed checks the values of the flags once fs is parsed and records which
+// were given.
+func (f *imapFlags) parsed(fs *flag.FlagSet) error {
+	if f.expunge != "" && !oneOf(expungeBehaviors, f.expunge) {
+		return fmt.Errorf("invalid -expunge-behavior %q (want one of %s)", f.expunge, strings.Join(expungeBehaviors, ", "))
+	}
+	validSize := false
+	for _, n := range imapFolderSizes {
+		validSize = validSize || f.folderSize == n
+	}
+	if !validSize {
+		return fmt.Errorf("invalid -max-folder-size %d (want 0, 1000, 2000, 5000 or 10000)", f.folderSize)
+	}
+	f.set = setFlags(fs)
+	return nil
+}
+
+func runImap(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("imap", args, "get", "enable", "disable")
+	if err != nil {
+		return err
+	}
+	var output *string
+	var f imapFlags
+	switch action {
+	case "get":
+		output = outputFlag(fs)
+	case "enable":
+		f.register(fs)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("settings imap %s: unexpected arguments", action)
+	}
+	out := "text"
+	if output != nil {
+		out = *output
+		if err := checkOutput(out); err != nil {
+			return err
+		}
+	}
+	if err := f.parsed(fs); err != nil {
+		return err
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	j := newJSONWriter(os.Stdout, out)
+	err = forEachAccount(ctx, accounts, func(a *account) error {
+		return imapAccount(ctx, a.api(), a.user, action, &f, out, j)
+	})
+	if action == "get" && out != "text" {
+		if cerr := j.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// imapAccount runs settings imap action on user's mailbox, writing get's
+// JSON output to j.
+func imapAccount(ctx context.Context, srv gmailclient.ImapPopService, user, action string, f *imapFlags, out string, j *jsonWriter) error {
+	s, err := gmailclient.GetImap(ctx, srv, user)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve IMAP settings: %w", err)
+	}
+	switch action {
+	case "get":
+		if out != "text" {
+			return j.write(s)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Enabled:\t%s\n", yesNo(s.Enabled))
+		fmt.Fprintf(w, "Auto-expunge:\t%s\n", yesNo(s.AutoExpunge))
+		fmt.Fprintf(w, "Expunge behavior:\t%s\n", s.ExpungeBehavior)
+		fmt.Fprintf(w, "Max folder size:\t%s\n", folderSizeString(s.MaxFolderSize))
+		return w.Flush()
+	case "enable":
+		s.Enabled = true
+		if f.set["auto-expunge"] {
+			s.AutoExpunge = f.autoExpunge
+		}
+		if f.expunge != "" {
+			s.ExpungeBehavior = f.expunge
+		}
+		if f.set["max-folder-size"] {
+			s.MaxFolderSize = f.folderSize
+		}
+	case "disable":
+		s.Enabled = false
+	}
+	s.ForceSendFields = []string{"Enabled", "AutoExpunge", "MaxFolderSize"}
+	if _, err := gmailclient.UpdateImap(ctx, srv, user, s); err != nil {
+		return fmt.Errorf("Unable to update IMAP settings (is gmail.settings.basic among -scopes?): %w", err)
+	}
+	return nil
+}
+
+// popFlags are the flags of settings pop enable. The settings whose flag is
+// not given are left as they are.
+type popFlags struct {
+	window      string
+	disposition string
+}
+
+func (f *popFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.window, "access-window", "", "which messages clients can download: fromNowOn or allMail (default unchanged, or fromNowOn)")
+	fs.StringVar(&f.disposition, "disposition", "", "what happens to messages once downloaded: "+strings.Join(gmailclient.ForwardingDispositions, ", ")+" (default unchanged)")
+}
+
+// check checks the values of the flags once they are parsed.
+func (f *popFlags) check() error {
+	if f.window != "" && !oneOf(popAccessWindows, f.window) {
+		return fmt.Errorf("invalid -access-window %q (want fromNowOn or allMail)", f.window)
+	}
+	if f.disposition != "" && !oneOf(gmailclient.ForwardingDispositions, f.disposition) {
+		return fmt.Errorf("invalid -disposition %q (want one of %s)", f.disposition, strings.Join(gmailclient.ForwardingDispositions, ", "))
+	}
+	return nil
+}
+
+func runPop(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("pop", args, "get", "enable", "disable")
+	if err != nil {
+		return err
+	}
+	var output *string
+	var f popFlags
+	switch action {
+	case "get":
+		output = outputFlag(fs)
+	case "enable":
+		f.register(fs)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("settings pop %s: unexpected arguments", action)
+	}
+	out := "text"
+	if output != nil {
+		out = *output
+		if err := checkOutput(out); err != nil {
+			return err
+		}
+	}
+	if err := f.check(); err != nil {
+		return err
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	j := newJSONWriter(os.Stdout, out)
+	err = forEachAccount(ctx, accounts, func(a *account) error {
+		return popAccount(ctx, a.api(), a.user, action, &f, out, j)
+	})
+	if action == "get" && out != "text" {
+		if cerr := j.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// popAccount runs settings pop action on user's mailbox, writing get's JSON
+// output to j.
+func popAccount(ctx context.Context, srv gmailclient.ImapPopService, user, action string, f *popFlags, out string, j *jsonWriter) error {
+	s, err := gmailclient.GetPop(ctx, srv, user)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve POP settings: %w", err)
+	}
+	switch action {
+	case "get":
+		if out != "text" {
+			return j.write(s)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Access window:\t%s\n", s.AccessWindow)
+		fmt.Fprintf(w, "Disposition:\t%s\n", s.Disposition)
+		return w.Flush()
+	case "enable":
+		switch {
+		case f.window != "":
+			s.AccessWindow = f.window
+		case s.AccessWindow == "disabled" || s.AccessWindow == "":
+			s.AccessWindow = "fromNowOn"
+		}
+		if f.disposition != "" {
+			s.Disposition = f.disposition
+		}
+	case "disable":
+		s.AccessWindow = "disabled"
+	}
+	if _, err := gmailclient.UpdatePop(ctx, srv, user, s); err != nil {
+		return fmt.Errorf("Unable to update POP settings (is gmail.settings.basic among -scopes?): %w", err)
+	}
+	return nil
+}
+
+// setFlags returns the names of the flags given on the command line, to
+// tell a flag left alone from one set to its default.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// oneOf reports whether s is among values.
+func oneOf(values []string, s string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func folderSizeString(n int64) string {
+	if n == 0 {
+		return "no limit"
+	}
+	return fmt.Sprintf("%d messages", n)
+}
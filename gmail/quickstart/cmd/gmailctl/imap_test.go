@@ -0,0 +1,129 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestImapAccount(t *testing.T) {
+	for _, tt := range []struct {
+		action string
+		args   []string
+		want   gmail.ImapSettings
+	}{
+		{"enable", nil, gmail.ImapSettings{Enabled: true, AutoExpunge: true, ExpungeBehavior: "archive", MaxFolderSize: 1000}},
+		{"enable", []string{"-auto-expunge=false"}, gmail.ImapSettings{Enabled: true, ExpungeBehavior: "archive", MaxFolderSize: 1000}},
+		{"enable", []string{"-expunge-behavior", "trash"}, gmail.ImapSettings{Enabled: true, AutoExpunge: true, ExpungeBehavior: "trash", MaxFolderSize: 1000}},
+		{"enable", []string{"-max-folder-size", "0"}, gmail.ImapSettings{Enabled: true, AutoExpunge: true, ExpungeBehavior: "archive"}},
+		{"disable", nil, gmail.ImapSettings{AutoExpunge: true, ExpungeBehavior: "archive", MaxFolderSize: 1000}},
+	} {
+		t.Run(tt.action+" "+strings.Join(tt.args, " "), func(t *testing.T) {
+			s := gmailclienttest.New()
+			s.Imap = &gmail.ImapSettings{Enabled: tt.action == "disable", AutoExpunge: true, ExpungeBehavior: "archive", MaxFolderSize: 1000}
+			fs := flag.NewFlagSet("imap", flag.ContinueOnError)
+			var f imapFlags
+			f.register(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.parsed(fs); err != nil {
+				t.Fatal(err)
+			}
+			if err := imapAccount(context.Background(), s, "me", tt.action, &f, "text", nil); err != nil {
+				t.Fatal(err)
+			}
+			got := s.Imap
+			if got.Enabled != tt.want.Enabled || got.AutoExpunge != tt.want.AutoExpunge ||
+				got.ExpungeBehavior != tt.want.ExpungeBehavior || got.MaxFolderSize != tt.want.MaxFolderSize {
+				t.Errorf("IMAP settings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	fs := flag.NewFlagSet("imap", flag.ContinueOnError)
+	var f imapFlags
+	f.register(fs)
+	fs.Parse([]string{"-max-folder-size", "3000"})
+	if err := f.parsed(fs); err == nil {
+		t.Error("-max-folder-size 3000 was accepted")
+	}
+}
+
+func TestPopAccount(t *testing.T) {
+	for _, tt := range []struct {
+		action string
+		before string // access window
+		args   []string
+		want   gmail.PopSettings
+	}{
+		{"enable", "disabled", nil, gmail.PopSettings{AccessWindow: "fromNowOn", Disposition: "archive"}},
+		{"enable", "allMail", nil, gmail.PopSettings{AccessWindow: "allMail", Disposition: "archive"}},
+		{"enable", "disabled", []string{"-access-window", "allMail"}, gmail.PopSettings{AccessWindow: "allMail", Disposition: "archive"}},
+		{"enable", "allMail", []string{"-disposition", "trash"}, gmail.PopSettings{AccessWindow: "allMail", Disposition: "trash"}},
+		{"disable", "allMail", nil, gmail.PopSettings{AccessWindow: "disabled", Disposition: "archive"}},
+	} {
+		t.Run(tt.action+" "+tt.before+" "+strings.Join(tt.args, " "), func(t *testing.T) {
+			s := gmailclienttest.New()
+			s.Pop = &gmail.PopSettings{AccessWindow: tt.before, Disposition: "archive"}
+			fs := flag.NewFlagSet("pop", flag.ContinueOnError)
+			var f popFlags
+			f.register(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.check(); err != nil {
+				t.Fatal(err)
+			}
+			if err := popAccount(context.Background(), s, "me", tt.action, &f, "text", nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Pop; got.AccessWindow != tt.want.AccessWindow || got.Disposition != tt.want.Disposition {
+				t.Errorf("POP settings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImapPopGet(t *testing.T) {
+	s := gmailclienttest.New()
+	s.Imap = &gmail.ImapSettings{Enabled: true, ExpungeBehavior: "trash"}
+	s.Pop = &gmail.PopSettings{AccessWindow: "allMail"}
+	var buf bytes.Buffer
+	j := newJSONWriter(&buf, "ndjson")
+	if err := imapAccount(context.Background(), s, "me", "get", &imapFlags{}, "ndjson", j); err != nil {
+		t.Fatal(err)
+	}
+	if err := popAccount(context.Background(), s, "me", "get", &popFlags{}, "ndjson", j); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\"enabled\":true,\"expungeBehavior\":\"trash\"}\n{\"accessWindow\":\"allMail\"}\n"
+	if buf.String() != want {
+		t.Errorf("get wrote %q, want %q", buf.String(), want)
+	}
+}
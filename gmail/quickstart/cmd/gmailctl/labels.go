@@ -0,0 +1,233 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name: "labels",
+		usage: "list [-counts] [-output text|json|ndjson] | create [-parents] [label flags] <name> | " +
+			"update [label flags] <name> | rename <name> <new-name> | delete [-r] <name>",
+		summary: "List, create, rename, recolor and delete labels (changes need the gmail.labels scope).",
+		run:     runLabels,
+	})
+}
+
+// labelFlags are the flags that set label colors and visibility.
+type labelFlags struct {
+	bg, fg     string
+	listVis    string
+	messageVis string
+}
+
+func (f *labelFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.bg, "bg", "", "background color as #rrggbb, one of Gmail's label palette")
+	fs.StringVar(&f.fg, "fg", "", "text color as #rrggbb, one of Gmail's label palette")
+	fs.StringVar(&f.listVis, "in-label-list", "", "visibility in the label list: show, unread (only with unread messages) or hide")
+	fs.StringVar(&f.messageVis, "in-message-list", "", "visibility on messages in the message list: show or hide")
+}
+
+// label returns the settings the flags change.
+func (f *labelFlags) label() (*gmail.Label, error) {
+	l := &gmail.Label{}
+	switch f.listVis {
+	case "":
+	case "show":
+		l.LabelListVisibility = gmailclient.LabelShow
+	case "unread":
+		l.LabelListVisibility = gmailclient.LabelShowIfUnread
+	case "hide":
+		l.LabelListVisibility = gmailclient.LabelHide
+	default:
+		return nil, fmt.Errorf("invalid -in-label-list %q (want show, unread or hide)", f.listVis)
+	}
+	switch f.messageVis {
+	case "", "show", "hide":
+		l.MessageListVisibility = f.messageVis
+	default:
+		return nil, fmt.Errorf("invalid -in-message-list %q (want show or hide)", f.messageVis)
+	}
+	if f.bg != "" || f.fg != "" {
+		if f.bg == "" || f.fg == "" {
+			return nil, fmt.Errorf("-bg and -fg must be set together")
+		}
+		l.Color = &gmail.LabelColor{BackgroundColor: f.bg, TextColor: f.fg}
+	}
+	return l, nil
+}
+
+func runLabels(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["labels"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("labels: expected a subcommand")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+
+	fs = newFlagSet(commands["labels"])
+	var lf labelFlags
+	var counts, parents, recursive bool
+	var output *string
+	nargs := 1
+	switch sub {
+	case "list":
+		fs.BoolVar(&counts, "counts", false, "show the number of messages and unread messages of each label")
+		output = outputFlag(fs)
+		nargs = 0
+	case "create", "update":
+		lf.register(fs)
+		if sub == "create" {
+			fs.BoolVar(&parents, "parents", false, "create the missing labels above a nested name such as Projects/Apollo")
+		}
+	case "rename":
+		nargs = 2
+	case "delete":
+		fs.BoolVar(&recursive, "r", false, "also delete the labels nested under it")
+	default:
+		fs.Usage()
+		return fmt.Errorf("labels: unknown subcommand %q", sub)
+	}
+	fs.Parse(args)
+	if fs.NArg() != nargs {
+		fs.Usage()
+		return fmt.Errorf("labels %s: expected %d arguments, got %d", sub, nargs, fs.NArg())
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "list":
+		if err := checkOutput(*output); err != nil {
+			return err
+		}
+		return listLabels(ctx, a, counts, *output)
+	case "create":
+		l, err := lf.label()
+		if err != nil {
+			return err
+		}
+		l.Name = fs.Arg(0)
+		created, err := gmailclient.CreateLabel(ctx, a.api(), a.user, l, parents)
+		if err != nil {
+			return fmt.Errorf("Unable to create label (is gmail.labels among -scopes?): %w", err)
+		}
+		fmt.Println(created.Id)
+		return nil
+	case "update":
+		change, err := lf.label()
+		if err != nil {
+			return err
+		}
+		labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to list labels: %w", err)
+		}
+		l, err := gmailclient.FindLabel(labels, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if _, err := gmailclient.UpdateLabel(ctx, a.api(), a.user, l.Id, change); err != nil {
+			return fmt.Errorf("Unable to update label %s: %w", l.Name, err)
+		}
+		return nil
+	case "rename":
+		if err := gmailclient.RenameLabel(ctx, a.api(), a.user, fs.Arg(0), fs.Arg(1)); err != nil {
+			return fmt.Errorf("Unable to rename label (is gmail.labels among -scopes?): %w", err)
+		}
+		return nil
+	}
+	if err := gmailclient.DeleteLabel(ctx, a.api(), a.user, fs.Arg(0), recursive); err != nil {
+		return fmt.Errorf("Unable to delete label (is gmail.labels among -scopes?): %w", err)
+	}
+	return nil
+}
+
+// listLabels prints a's labels, user labels indented under their parents,
+// with message counts if counts is set.
+func listLabels(ctx context.Context, a *account, counts bool, output string) error {
+	labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to list labels: %w", err)
+	}
+	if counts {
+		for i, l := range labels {
+			if labels[i], err = gmailclient.GetLabel(ctx, a.api(), a.user, l.Id); err != nil {
+				return fmt.Errorf("Unable to retrieve label %s: %w", l.Name, err)
+			}
+		}
+	}
+	if output != "text" {
+		j := newJSONWriter(os.Stdout, output)
+		for _, l := range labels {
+			if err := j.write(l); err != nil {
+				return err
+			}
+		}
+		return j.close()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := "NAME\tID\tTYPE\tCOLOR\tVISIBILITY"
+	if counts {
+		header += "\tMESSAGES\tUNREAD"
+	}
+	fmt.Fprintln(w, header)
+	names := map[string]bool{}
+	for _, l := range labels {
+		names[strings.ToLower(l.Name)] = true
+	}
+	for _, l := range labels {
+		// Show nested labels under their parents, by their last level;
+		// those whose parent is missing keep their full name.
+		name := l.Name
+		if i := strings.LastIndex(name, "/"); i > 0 && l.Type != "system" && names[strings.ToLower(name[:i])] {
+			name = strings.Repeat("  ", strings.Count(name, "/")) + name[i+1:]
+		}
+		color := "-"
+		if l.Color != nil && l.Color.BackgroundColor != "" {
+			color = l.Color.BackgroundColor + "/" + l.Color.TextColor
+		}
+		vis := l.LabelListVisibility
+		if vis == "" {
+			vis = gmailclient.LabelShow
+		}
+		if l.MessageListVisibility == gmailclient.MessageListHide {
+			vis += ",hidden-on-messages"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s", name, l.Id, l.Type, color, vis)
+		if counts {
+			fmt.Fprintf(w, "\t%d\t%d", l.MessagesTotal, l.MessagesUnread)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,76 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func runLanguage(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("language", args, "get", "set")
+	if err != nil {
+		return err
+	}
+	fs.Parse(args)
+	var language string
+	switch {
+	case action == "get" && fs.NArg() != 0:
+		fs.Usage()
+		return fmt.Errorf("settings language get: unexpected arguments")
+	case action == "set" && fs.NArg() != 1:
+		fs.Usage()
+		return fmt.Errorf("settings language set: expected a language tag, e.g. en-GB")
+	case action == "set":
+		language = fs.Arg(0)
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		return languageAccount(ctx, a.api(), a.user, action, language)
+	})
+}
+
+// languageAccount prints the display language of user's mailbox, or with
+// action set, changes it to language.
+func languageAccount(ctx context.Context, srv gmailclient.LanguageService, user, action, language string) error {
+	cur, err := gmailclient.GetLanguage(ctx, srv, user)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve language settings: %w", err)
+	}
+	if action == "get" {
+		fmt.Println(cur)
+		return nil
+	}
+	if cur == language {
+		return nil
+	}
+	got, err := gmailclient.UpdateLanguage(ctx, srv, user, language)
+	if err != nil {
+		return fmt.Errorf("Unable to update language settings (is gmail.settings.basic among -scopes?): %w", err)
+	}
+	if got != language {
+		fmt.Printf("%s is not available; set to %s instead.\n", language, got)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+// regionalMailbox is a mailbox that only offers the languages in
+// available, falling back to en, and counts the updates.
+type regionalMailbox struct {
+	*gmailclienttest.Service
+	available map[string]bool
+	updates   int
+}
+
+func (m *regionalMailbox) UpdateLanguage(ctx context.Context, user string, l *gmail.LanguageSettings) (*gmail.LanguageSettings, error) {
+	m.updates++
+	if !m.available[l.DisplayLanguage] {
+		l = &gmail.LanguageSettings{DisplayLanguage: "en"}
+	}
+	return m.Service.UpdateLanguage(ctx, user, l)
+}
+
+func TestLanguageAccount(t *testing.T) {
+	ctx := context.Background()
+	m := &regionalMailbox{Service: gmailclienttest.New(), available: map[string]bool{"en": true, "en-GB": true, "de": true}}
+	m.Language = &gmail.LanguageSettings{DisplayLanguage: "en"}
+
+	if err := languageAccount(ctx, m, "me", "get", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		language, want string
+		updates        int
+	}{
+		{"en-GB", "en-GB", 1},
+		{"en-GB", "en-GB", 1}, // already set, so not updated again
+		{"de", "de", 2},
+		{"xx-YY", "en", 3},
+	} {
+		if err := languageAccount(ctx, m, "me", "set", tt.language); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Language.DisplayLanguage; got != tt.want || m.updates != tt.updates {
+			t.Errorf("set %s: language %s after %d updates, want %s after %d", tt.language, got, m.updates, tt.want, tt.updates)
+		}
+	}
+}
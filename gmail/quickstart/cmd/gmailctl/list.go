@@ -0,0 +1,277 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func init() {
+	register(&command{
+		name:    "list",
+		usage:   "[-query q] [-saved name [-param name=value]...] " + queryFlagsUsage + " [-starred] [-important] [-max-results n] [-headers list] [-count-only [-exact]] [-output text|json|ndjson]",
+		summary: "List messages matching a Gmail search query.",
+		run:     runList,
+	})
+}
+
+func runList(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["list"])
+	query := fs.String("query", profile.Query, "Gmail search query")
+	max := fs.Int64("max-results", 100, "maximum number of messages to list, 0 for all")
+	fs.Int64Var(max, "max", 100, "alias for -max-results")
+	starred := fs.Bool("starred", false, "only list starred messages")
+	important := fs.Bool("important", false, "only list messages marked important")
+	headers := fs.String("headers", "From,Subject", "comma-separated headers to print after the message ID")
+	saved := fs.String("saved", "", "run the saved search of this name from the configuration file, ANDed with -query if that is given too")
+	var params stringList
+	fs.Var(&params, "param", "a name=value parameter of the saved search (repeatable)")
+	qf := addQueryFlags(fs)
+	countOnly := fs.Bool("count-only", false, "only print how many messages match, as estimated by Gmail, without fetching any")
+	exact := fs.Bool("exact", false, "with -count-only, count the matches by listing their IDs, up to -max-results if given")
+	output := outputFlag(fs)
+	fs.Parse(args)
+	if *exact && !*countOnly {
+		return fmt.Errorf("-exact needs -count-only")
+	}
+	if *saved != "" {
+		q, err := savedSearch(*saved, params)
+		if err != nil {
+			return err
+		}
+		if setFlags(fs)["query"] {
+			q = andQuery(*query, q)
+		}
+		*query = q
+	} else if len(params) > 0 {
+		return fmt.Errorf("-param needs -saved")
+	}
+	q, err := qf.apply(*query)
+	if err != nil {
+		return err
+	}
+	*query = starredQuery(q, *starred, *important)
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	if *countOnly {
+		// -max-results only caps an exact count when given explicitly; its
+		// default is meant for listing.
+		limit := int64(0)
+		if setFlags(fs)["max-results"] || setFlags(fs)["max"] {
+			limit = *max
+		}
+		return countMessages(ctx, accounts, *query, *exact, limit, *output)
+	}
+	if *output != "text" {
+		j := newJSONWriter(os.Stdout, *output)
+		err := forEachAccount(ctx, accounts, func(a *account) error {
+			return listAccountJSON(ctx, a, *query, *max, splitList(*headers), j)
+		})
+		if cerr := j.close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		return listAccount(ctx, a, *query, *max, splitList(*headers))
+	})
+}
+
+// listAccount prints the given headers of the messages matching query,
+// tagged with the account name when running against several accounts. Only
+// those headers are fetched, never the bodies.
+func listAccount(ctx context.Context, a *account, query string, max int64, headers []string) error {
+	opts := gmailclient.FetchOptions{
+		Query:           query,
+		MaxResults:      max,
+		MetadataHeaders: headers,
+		Fields:          []googleapi.Field{"id", "payload/headers"},
+		Concurrency:     concurrency,
+	}
+	err := gmailclient.FetchMessages(ctx, gmailclient.NewClient(a.srv), a.user, opts, func(msg *gmail.Message) error {
+		if multiAccount() {
+			fmt.Printf("%s\t", a.name)
+		}
+		fmt.Print(msg.Id)
+		for _, h := range headers {
+			fmt.Printf("\t%s", gmailclient.FindHeader(msg.Payload, h))
+		}
+		fmt.Println()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to fetch messages: %w", err)
+	}
+	return nil
+}
+
+// listMessage is a listed message in JSON output, tagged with its account
+// when listing several.
+type listMessage struct {
+	Account string
+	*gmailclient.Message
+}
+
+func (lm listMessage) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(lm.Message)
+	if err != nil || lm.Account == "" {
+		return b, err
+	}
+	account, err := json.Marshal(lm.Account)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(`{"account":`), account...)
+	return append(append(out, ','), b[1:]...), nil
+}
+
+// listAccountJSON writes the messages matching query to j as parsed
+// messages with just the requested headers, their labels, sizes and dates.
+func listAccountJSON(ctx context.Context, a *account, query string, max int64, headers []string, j *jsonWriter) error {
+	opts := gmailclient.FetchOptions{
+		Query:           query,
+		MaxResults:      max,
+		MetadataHeaders: headers,
+		Fields:          []googleapi.Field{"id", "threadId", "labelIds", "sizeEstimate", "internalDate", "payload/headers"},
+		Concurrency:     concurrency,
+	}
+	err := gmailclient.FetchMessages(ctx, gmailclient.NewClient(a.srv), a.user, opts, func(msg *gmail.Message) error {
+		m, err := gmailclient.ParseMetadata(msg)
+		if err != nil {
+			return err
+		}
+		lm := listMessage{Message: m}
+		if multiAccount() {
+			lm.Account = a.name
+		}
+		return j.write(lm)
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to fetch messages: %w", err)
+	}
+	return nil
+}
+
+// messageCount is the number of messages matching a query in JSON output.
+type messageCount struct {
+	Account string `json:"account,omitempty"`
+	Query   string `json:"query"`
+	*gmailclient.MessageCount
+}
+
+// countMessages prints how many messages match query in each account: Gmail's
+// estimate, or with exact the number counted by paging through the matches,
+// up to max if above zero. Nothing but message IDs is fetched, so it is a
+// cheap dry run before an export or a destructive command.
+func countMessages(ctx context.Context, accounts []*account, query string, exact bool, max int64, output string) error {
+	var j *jsonWriter
+	if output != "text" {
+		j = newJSONWriter(os.Stdout, output)
+	}
+	err := forEachAccount(ctx, accounts, func(a *account) error {
+		c, err := gmailclient.CountMessages(ctx, gmailclient.NewClient(a.srv), a.user, query, exact, max)
+		if err != nil {
+			return fmt.Errorf("Unable to count messages: %w", err)
+		}
+		if j != nil {
+			mc := &messageCount{Query: query, MessageCount: c}
+			if multiAccount() {
+				mc.Account = a.name
+			}
+			return j.write(mc)
+		}
+		if multiAccount() {
+			fmt.Printf("%s\t", a.name)
+		}
+		switch {
+		case !exact:
+			fmt.Printf("about %d messages (Gmail's estimate)\n", c.Estimate)
+		case c.Capped:
+			fmt.Printf("at least %d messages (stopped counting at -max-results)\n", c.Count)
+		default:
+			fmt.Printf("%d messages (counted over %d pages; Gmail estimated %d)\n", c.Count, c.Pages, c.Estimate)
+		}
+		return nil
+	})
+	if j != nil {
+		if cerr := j.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// savedSearch returns the query of the saved search name of the profile,
+// with params, given as name=value, and dates substituted.
+func savedSearch(name string, params []string) (string, error) {
+	values := map[string]string{}
+	for _, p := range params {
+		i := strings.Index(p, "=")
+		if i <= 0 {
+			return "", fmt.Errorf("invalid -param %q (want name=value)", p)
+		}
+		values[p[:i]] = p[i+1:]
+	}
+	return profile.Search(name, values, time.Now())
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// starredQuery narrows query to starred messages if starred is set, and to
+// those marked important if important is.
+func starredQuery(query string, starred, important bool) string {
+	if starred {
+		query = andQuery(query, "is:starred")
+	}
+	if important {
+		query = andQuery(query, "is:important")
+	}
+	return query
+}
+
+// andQuery narrows the Gmail search query to messages also matching term.
+func andQuery(query, term string) string {
+	if query == "" {
+		return term
+	}
+	return "(" + query + ") " + term
+}
@@ -0,0 +1,38 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "testing"
+
+func TestStarredQuery(t *testing.T) {
+	for _, tt := range []struct {
+		query              string
+		starred, important bool
+		want               string
+	}{
+		{"from:ana", false, false, "from:ana"},
+		{"", true, false, "is:starred"},
+		{"", false, true, "is:important"},
+		{"from:ana OR from:bo", true, false, "(from:ana OR from:bo) is:starred"},
+		{"from:ana", true, true, "((from:ana) is:starred) is:important"},
+	} {
+		if got := starredQuery(tt.query, tt.starred, tt.important); got != tt.want {
+			t.Errorf("starredQuery(%q, %v, %v) = %q, want %q", tt.query, tt.starred, tt.important, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command gmailctl is a command-line client for the Gmail API built on the
+// gmailclient package.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Global options, set from flags or the environment before the subcommand
+// runs.
+var (
+	credentialsFile    string
+	tokenFile          string
+	serviceAccountFile string
+	useADC             bool
+	user               string
+	scopes             []string
+	concurrency        int
+	retries            int
+	quota              float64
+)
+
+// A command is a gmailctl subcommand. run receives the arguments that follow
+// the subcommand name and a context that is cancelled on SIGINT or SIGTERM.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]*command{}
+
+func register(c *command) {
+	commands[c.name] = c
+}
+
+// newFlagSet returns a flag set for c that prints c's usage on error.
+func newFlagSet(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gmailctl %s %s\n\n%s\n\n", c.name, c.usage, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// signalContext returns a context that is cancelled on the first SIGINT or
+// SIGTERM. A second signal exits immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			log.Print("interrupted, cleaning up (interrupt again to force exit)")
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+		<-sig
+		os.Exit(130)
+	}()
+	return ctx, func() {
+		signal.Stop(sig)
+		cancel()
+	}
+}
+
+// envOr returns the value of the environment variable key, or def if it is
+// unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt is envOr for integer settings. Malformed values are ignored.
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return def
+}
+
+// envFloat is envOr for floating-point settings. Malformed values are ignored.
+func envFloat(key string, def float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return f
+	}
+	return def
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gmailctl [global flags] <command> [flags] [args]\n\nglobal flags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gmailctl: ")
+
+	flag.StringVar(&credentialsFile, "credentials", envOr("GMAIL_CREDENTIALS", "credentials.json"),
+		"OAuth client secret file (env GMAIL_CREDENTIALS)")
+	flag.StringVar(&tokenFile, "token", envOr("GMAIL_TOKEN", "token.json"),
+		"cached OAuth token file (env GMAIL_TOKEN)")
+	flag.StringVar(&tokenStoreKind, "token-store", envOr("GMAIL_TOKEN_STORE", "file"),
+		"where to keep the OAuth token: file, encrypted, keyring, or env to read it from $"+tokenEnv+" (env GMAIL_TOKEN_STORE)")
+	flag.StringVar(&tokenKeySource, "token-key", envOr("GMAIL_TOKEN_KEY", "prompt"),
+		"passphrase source of the encrypted token store when $"+passphraseEnv+" is unset: prompt or keyring (env GMAIL_TOKEN_KEY)")
+	flag.StringVar(&authFlowName, "auth-flow", envOr("GMAIL_AUTH_FLOW", defaultAuthFlow()),
+		"how to authorize new tokens: loopback (browser redirect to localhost), manual (paste the code), "+
+			"device (enter a code on another device) or none (fail instead; the default without a terminal) (env GMAIL_AUTH_FLOW)")
+	flag.StringVar(&serviceAccountFile, "service-account", os.Getenv("GMAIL_SERVICE_ACCOUNT"),
+		"service account key file; impersonates -user with domain-wide delegation instead of using OAuth (env GMAIL_SERVICE_ACCOUNT)")
+	flag.BoolVar(&useADC, "adc", os.Getenv("GMAIL_ADC") == "1",
+		"authorize with Application Default Credentials instead of -credentials (env GMAIL_ADC=1)")
+	flag.StringVar(&user, "user", envOr("GMAIL_USER", "me"),
+		"mailbox to operate on; \"me\" is the authenticated user (env GMAIL_USER)")
+	scopeList := flag.String("scopes", envOr("GMAIL_SCOPES", gmail.GmailReadonlyScope),
+		"comma-separated OAuth scopes, full URLs or short names like gmail.modify (env GMAIL_SCOPES)")
+	flag.IntVar(&concurrency, "concurrency", envInt("GMAIL_CONCURRENCY", 8),
+		"number of messages fetched in parallel (env GMAIL_CONCURRENCY)")
+	flag.IntVar(&retries, "retries", envInt("GMAIL_RETRIES", gmailclient.DefaultRetryPolicy.MaxRetries),
+		"retries of API calls failing with 429, 5xx or a rate limit error, with exponential backoff (env GMAIL_RETRIES)")
+	flag.Float64Var(&quota, "quota", envFloat("GMAIL_QUOTA", gmailclient.DefaultQuota),
+		"Gmail quota units to spend per second and mailbox, 0 for no limit (env GMAIL_QUOTA)")
+	flag.StringVar(&cachePath, "cache", os.Getenv("GMAIL_CACHE"),
+		"SQLite database caching fetched messages, e.g. ~/.cache/gmailtool/messages.db (env GMAIL_CACHE; default no cache)")
+	flag.StringVar(&outboxPath, "outbox", os.Getenv("GMAIL_OUTBOX"),
+		"SQLite database of messages scheduled with send -at (env GMAIL_OUTBOX; default $XDG_CONFIG_HOME/gmailtool/outbox.db)")
+	configPath := flag.String("config", os.Getenv("GMAIL_CONFIG"),
+		"configuration file (env GMAIL_CONFIG; default $XDG_CONFIG_HOME/gmailtool/config.yaml)")
+	profileName := flag.String("profile", os.Getenv("GMAIL_PROFILE"),
+		"configuration profile to use (env GMAIL_PROFILE; default is default_profile)")
+	flag.BoolVar(&allAccounts, "all-accounts", false,
+		"run list and export against every configured profile")
+	flag.BoolVar(&allUsers, "all-users", false,
+		"run list and export against every active user of the Workspace domain, impersonated with -service-account or -adc")
+	flag.StringVar(&domain, "domain", os.Getenv("GMAIL_DOMAIN"),
+		"domain whose users -all-users lists (env GMAIL_DOMAIN; default all domains of the account)")
+	flag.StringVar(&adminUser, "admin", os.Getenv("GMAIL_ADMIN"),
+		"Workspace administrator to impersonate when listing users for -all-users (env GMAIL_ADMIN)")
+	flag.Usage = usage
+	flag.Parse()
+	if concurrency < 1 {
+		log.Fatalf("invalid -concurrency %d (want at least 1)", concurrency)
+	}
+
+	p, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	profile = p
+	if err := applyProfile(profile, scopeList); err != nil {
+		log.Fatal(err)
+	}
+	scopes = gmailclient.ExpandScopes(*scopeList)
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	c, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	ctx, cancel := signalContext()
+	err = c.run(ctx, flag.Args()[1:])
+	cancel()
+	closeCache()
+	switch {
+	case errors.Is(err, gmailclient.ErrAuthRequired), errors.Is(err, gmailclient.ErrTokenRevoked),
+		errors.Is(err, gmailclient.ErrInsufficientScopes), gmailclient.IsInvalidGrant(err):
+		log.Fatalf("%v\nThe account needs to be authorized again: run \"gmailctl auth\" in a terminal.", err)
+	case err != nil:
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,165 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/mail"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func init() {
+	register(&command{
+		name:    "merge",
+		usage:   "-csv file -template file [-html-template file] [-to-column name] [-delay d] [-report file] [compose flags] [-dry-run]",
+		summary: "Send one message per CSV row, rendered from templates (needs the gmail.send scope).",
+		run:     runMerge,
+	})
+}
+
+// mergeReportHeader names the columns of the merge report.
+var mergeReportHeader = []string{"line", "to", "status", "message_id", "error"}
+
+func runMerge(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["merge"])
+	var c composeFlags
+	c.register(fs)
+	csvFile := fs.String("csv", "", "CSV file with a header row naming the template variables and one row per message")
+	toColumn := fs.String("to-column", "email", "column holding the recipient when the template sets no To")
+	delay := fs.Duration("delay", time.Second, "pause between messages, to stay within Gmail's sending limits")
+	report := fs.String("report", "", "write the outcome of every row to this CSV file (default stdout)")
+	dryRun := fs.Bool("dry-run", false, "render every message and print them instead of sending")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("merge: unexpected arguments %v", fs.Args())
+	}
+	if *csvFile == "" || (c.template == "" && c.htmlTemplate == "") {
+		fs.Usage()
+		return fmt.Errorf("merge: -csv and -template or -html-template are required")
+	}
+	if c.data != "" || c.bodyFile == "-" || c.htmlFile == "-" {
+		return fmt.Errorf("merge: -data and reading a body from stdin cannot be used; the rows come from -csv")
+	}
+
+	f, err := os.Open(*csvFile)
+	if err != nil {
+		return fmt.Errorf("Unable to open -csv: %w", err)
+	}
+	rows, err := gmailclient.ReadMergeCSV(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return err
+	}
+
+	msgs, recipients, err := c.renderMerge(tmpl, rows, *toColumn)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		for _, raw := range msgs {
+			if _, err := os.Stdout.Write(raw); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	// The report is written as the rows are sent rather than atomically, so
+	// that it shows how far an interrupted merge got.
+	out := os.Stdout
+	if *report != "" {
+		if out, err = os.Create(*report); err != nil {
+			return fmt.Errorf("Unable to create report: %w", err)
+		}
+		defer out.Close()
+	}
+	w := csv.NewWriter(out)
+	w.Write(mergeReportHeader)
+
+	failed := 0
+	for i, raw := range msgs {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(*delay):
+			}
+		}
+		status, id, errText := "sent", "", ""
+		if err := ctx.Err(); err != nil {
+			status, errText = "skipped", err.Error()
+		} else if sent, err := gmailclient.SendMessage(ctx, a.api(), a.user, raw, ""); err != nil {
+			status, errText = "failed", err.Error()
+			failed++
+		} else {
+			id = sent.Id
+		}
+		w.Write([]string{strconv.Itoa(rows[i].Line), recipients[i], status, id, errText})
+		w.Flush()
+	}
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("Unable to write report: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d messages failed (is gmail.send among -scopes?)", failed, len(msgs))
+	}
+	return nil
+}
+
+// renderMerge renders the message of every row before any is sent, so that a
+// broken row does not leave the merge half done. The recipient comes from
+// toColumn when neither the templates nor -to set one. It returns the
+// messages and their recipients, for the report.
+func (c *composeFlags) renderMerge(tmpl *gmailclient.MessageTemplate, rows []gmailclient.MergeRow, toColumn string) ([][]byte, []string, error) {
+	msgs := make([][]byte, len(rows))
+	recipients := make([]string, len(rows))
+	for i, row := range rows {
+		m, err := c.render(tmpl, row.Values)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", row.Line, err)
+		}
+		if len(m.To) == 0 && c.to == "" && row.Values[toColumn] != "" {
+			if m.To, err = mail.ParseAddressList(row.Values[toColumn]); err != nil {
+				return nil, nil, fmt.Errorf("line %d: invalid %s %q: %w", row.Line, toColumn, row.Values[toColumn], err)
+			}
+		}
+		if msgs[i], err = m.Bytes(); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", row.Line, err)
+		}
+		recipients[i] = gmailclient.FormatAddressList(m.To)
+	}
+	return msgs, recipients, nil
+}
@@ -0,0 +1,57 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestRenderMerge(t *testing.T) {
+	c := &composeFlags{subject: "Hello", body: "Hi."}
+	rows, err := gmailclient.ReadMergeCSV(strings.NewReader("email\nana@example.com\n\"Bo <bo@example.com>, cy@example.com\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, recipients, err := c.renderMerge(nil, rows, "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || recipients[0] != "ana@example.com" || recipients[1] != "Bo <bo@example.com>, cy@example.com" {
+		t.Errorf("recipients = %q", recipients)
+	}
+
+	// A line break in the column must not add header fields.
+	for _, to := range []string{
+		"\"ana@example.com\r\nBcc: eve@example.com\"",
+		"\"ana@example.com\nBcc: eve@example.com\"",
+		"\"Ana\r\n <ana@example.com>\"",
+		"not an address",
+	} {
+		rows, err := gmailclient.ReadMergeCSV(strings.NewReader("email,name\nbo@example.com,Bo\n" + to + ",Ana\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs, _, err := c.renderMerge(nil, rows, "email")
+		if err == nil || !strings.HasPrefix(err.Error(), "line 3: ") {
+			t.Errorf("to %q: rendered %q, %v; want an error for line 3", to, msgs, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func init() {
+	register(&command{
+		name:    "messages",
+		usage:   "archive|read|unread|star|unstar|important|unimportant|trash|untrash|delete -query q " + queryFlagsUsage + " [-max-results n] [-dry-run] [-yes]",
+		summary: "Archive, mark, star, trash or permanently delete the messages matching a query.",
+		run:     runMessages,
+	})
+}
+
+// messageAction is what a messages subcommand does: add and remove labels
+// with batchModify, or else trash, untrash or delete the messages.
+type messageAction struct {
+	verb        string // what happened to the messages, for reports
+	confirm     bool   // whether -yes is needed
+	add, remove []string
+}
+
+var messageActions = map[string]messageAction{
+	"archive":     {verb: "archived", confirm: true, remove: []string{"INBOX"}},
+	"read":        {verb: "marked as read", remove: []string{"UNREAD"}},
+	"unread":      {verb: "marked as unread", add: []string{"UNREAD"}},
+	"star":        {verb: "starred", add: []string{"STARRED"}},
+	"unstar":      {verb: "unstarred", remove: []string{"STARRED"}},
+	"important":   {verb: "marked important", add: []string{"IMPORTANT"}},
+	"unimportant": {verb: "marked not important", remove: []string{"IMPORTANT"}},
+	"trash":       {verb: "moved to the trash", confirm: true},
+	"untrash":     {verb: "moved out of the trash", confirm: true},
+	"delete":      {verb: "permanently deleted", confirm: true},
+}
+
+func runMessages(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["messages"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("messages: expected a subcommand")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+	if _, ok := messageActions[sub]; !ok {
+		fs.Usage()
+		return fmt.Errorf("messages: unknown subcommand %q", sub)
+	}
+
+	fs = newFlagSet(commands["messages"])
+	query := fs.String("query", profile.Query, "Gmail search query; untrash needs in:trash")
+	max := fs.Int64("max-results", 0, "maximum number of messages, 0 for all")
+	dryRun := fs.Bool("dry-run", false, "list the matching messages without changing them")
+	yes := fs.Bool("yes", false, "confirm archive, trash, untrash and delete")
+	qf := addQueryFlags(fs)
+	fs.Parse(args)
+	q, err := qf.apply(*query)
+	if err != nil {
+		return err
+	}
+	if *query = q; *query == "" {
+		fs.Usage()
+		return fmt.Errorf("messages %s: -query or a flag such as -since or -category is required", sub)
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		return messagesAccount(ctx, a, sub, *query, *max, *dryRun, *yes)
+	})
+}
+
+// messagesAccount applies the messages subcommand sub to the messages of a
+// matching query. With dryRun it lists them instead, and without yes it
+// refuses the actions that need confirming.
+func messagesAccount(ctx context.Context, a *account, sub, query string, max int64, dryRun, yes bool) error {
+	if dryRun {
+		if err := listAccount(ctx, a, query, max, []string{"Date", "From", "Subject"}); err != nil {
+			return err
+		}
+	}
+	ids, err := matchingIDs(ctx, a, query, max)
+	if err != nil {
+		return err
+	}
+	action := messageActions[sub]
+	if dryRun {
+		fmt.Printf("%d messages would be %s.\n", len(ids), action.verb)
+		return nil
+	}
+	if len(ids) == 0 {
+		fmt.Println("No messages match.")
+		return nil
+	}
+	if action.confirm && !yes {
+		return fmt.Errorf("%d messages would be %s; check them with -dry-run and rerun with -yes", len(ids), action.verb)
+	}
+
+	p := progress(len(ids))
+	switch sub {
+	case "trash":
+		err = gmailclient.TrashMessages(ctx, a.api(), a.user, ids, p)
+	case "untrash":
+		err = gmailclient.UntrashMessages(ctx, a.api(), a.user, ids, p)
+	case "delete":
+		err = gmailclient.BatchDelete(ctx, a.api(), a.user, ids, p)
+	default:
+		err = gmailclient.BatchModify(ctx, a.api(), a.user, ids, action.add, action.remove, p)
+	}
+	if err != nil {
+		scope := "gmail.modify"
+		if sub == "delete" {
+			scope = "https://mail.google.com/"
+		}
+		return fmt.Errorf("Unable to %s messages (is %s among -scopes?): %w", sub, scope, err)
+	}
+	if err := uncacheMessages(ctx, a, ids...); err != nil {
+		return err
+	}
+	fmt.Printf("%d messages %s.\n", len(ids), action.verb)
+	return nil
+}
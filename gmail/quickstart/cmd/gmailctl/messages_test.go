@@ -0,0 +1,194 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// labelMailbox is a fake mailbox that keeps the labels of its messages and
+// serves the calls the messages and modify commands make.
+type labelMailbox struct {
+	mu        sync.Mutex
+	labels    map[string]map[string]bool // by message ID
+	labelList []*gmail.Label
+}
+
+func (m *labelMailbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if strings.HasSuffix(r.URL.Path, "/users/me/labels") {
+		m.serveLabels(w, r)
+		return
+	}
+	path := r.URL.Path[strings.Index(r.URL.Path, "/users/me/messages")+len("/users/me/messages"):]
+	switch {
+	case r.Method == "GET" && path == "":
+		resp := &gmail.ListMessagesResponse{}
+		for _, id := range m.ids() {
+			resp.Messages = append(resp.Messages, &gmail.Message{Id: id, ThreadId: id})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.Method == "GET":
+		json.NewEncoder(w).Encode(&gmail.Message{Id: path[1:], Payload: &gmail.MessagePart{}})
+	case path == "/batchModify":
+		var req gmail.BatchModifyMessagesRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, id := range req.Ids {
+			m.change(id, req.AddLabelIds, req.RemoveLabelIds)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/batchDelete":
+		var req gmail.BatchDeleteMessagesRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, id := range req.Ids {
+			delete(m.labels, id)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(path, "/trash"):
+		id := strings.TrimSuffix(path[1:], "/trash")
+		m.change(id, []string{"TRASH"}, []string{"INBOX"})
+		json.NewEncoder(w).Encode(&gmail.Message{Id: id})
+	case strings.HasSuffix(path, "/untrash"):
+		id := strings.TrimSuffix(path[1:], "/untrash")
+		m.change(id, []string{"INBOX"}, []string{"TRASH"})
+		json.NewEncoder(w).Encode(&gmail.Message{Id: id})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveLabels lists the labels, or creates one with the ID Label_<name>.
+func (m *labelMailbox) serveLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var l gmail.Label
+		json.NewDecoder(r.Body).Decode(&l)
+		l.Id = "Label_" + l.Name
+		m.labelList = append(m.labelList, &l)
+		json.NewEncoder(w).Encode(&l)
+		return
+	}
+	json.NewEncoder(w).Encode(&gmail.ListLabelsResponse{Labels: m.labelList})
+}
+
+func (m *labelMailbox) ids() []string {
+	var ids []string
+	for id := range m.labels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *labelMailbox) change(id string, add, remove []string) {
+	for _, l := range add {
+		m.labels[id][l] = true
+	}
+	for _, l := range remove {
+		delete(m.labels[id], l)
+	}
+}
+
+// String lists the messages with their labels, as "m1:INBOX,UNREAD m2:".
+func (m *labelMailbox) String() string {
+	var s []string
+	for _, id := range m.ids() {
+		var labels []string
+		for l := range m.labels[id] {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		s = append(s, id+":"+strings.Join(labels, ","))
+	}
+	return strings.Join(s, " ")
+}
+
+func newLabelMailbox(t *testing.T, labels map[string]string) (*labelMailbox, *account) {
+	m := &labelMailbox{labels: map[string]map[string]bool{}}
+	for id, list := range labels {
+		m.labels[id] = map[string]bool{}
+		for _, l := range strings.Split(list, ",") {
+			m.labels[id][l] = true
+		}
+	}
+	ts := httptest.NewServer(m)
+	t.Cleanup(ts.Close)
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(ts.URL), option.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, &account{name: "test", user: "me", srv: srv}
+}
+
+func TestMessagesLabels(t *testing.T) {
+	for _, tt := range []struct {
+		sub     string
+		dryRun  bool
+		yes     bool
+		want    string
+		wantErr bool
+	}{
+		{sub: "read", want: "m1:INBOX m2:IMPORTANT,INBOX,STARRED"},
+		{sub: "unread", want: "m1:INBOX,UNREAD m2:IMPORTANT,INBOX,STARRED,UNREAD"},
+		{sub: "star", want: "m1:INBOX,STARRED,UNREAD m2:IMPORTANT,INBOX,STARRED"},
+		{sub: "unstar", want: "m1:INBOX,UNREAD m2:IMPORTANT,INBOX"},
+		{sub: "important", want: "m1:IMPORTANT,INBOX,UNREAD m2:IMPORTANT,INBOX,STARRED"},
+		{sub: "unimportant", want: "m1:INBOX,UNREAD m2:INBOX,STARRED"},
+		{sub: "archive", yes: true, want: "m1:UNREAD m2:IMPORTANT,STARRED"},
+		{sub: "trash", yes: true, want: "m1:TRASH,UNREAD m2:IMPORTANT,STARRED,TRASH"},
+		{sub: "delete", yes: true, want: ""},
+
+		// Nothing changes without -yes where it is needed, or with
+		// -dry-run.
+		{sub: "archive", wantErr: true, want: "m1:INBOX,UNREAD m2:IMPORTANT,INBOX,STARRED"},
+		{sub: "trash", wantErr: true, want: "m1:INBOX,UNREAD m2:IMPORTANT,INBOX,STARRED"},
+		{sub: "delete", wantErr: true, want: "m1:INBOX,UNREAD m2:IMPORTANT,INBOX,STARRED"},
+		{sub: "star", dryRun: true, want: "m1:INBOX,UNREAD m2:IMPORTANT,INBOX,STARRED"},
+		{sub: "delete", dryRun: true, yes: true, want: "m1:INBOX,UNREAD m2:IMPORTANT,INBOX,STARRED"},
+	} {
+		t.Run(fmt.Sprintf("%s dry-run=%v yes=%v", tt.sub, tt.dryRun, tt.yes), func(t *testing.T) {
+			m, a := newLabelMailbox(t, map[string]string{"m1": "INBOX,UNREAD", "m2": "INBOX,IMPORTANT,STARRED"})
+			err := messagesAccount(context.Background(), a, tt.sub, "in:inbox", 0, tt.dryRun, tt.yes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("messages %s = %v, want error %v", tt.sub, err, tt.wantErr)
+			}
+			if got := m.String(); got != tt.want {
+				t.Errorf("messages %s left %q, want %q", tt.sub, got, tt.want)
+			}
+		})
+	}
+
+	m, a := newLabelMailbox(t, map[string]string{"m1": "TRASH"})
+	if err := messagesAccount(context.Background(), a, "untrash", "in:trash", 0, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.String(); got != "m1:INBOX" {
+		t.Errorf("messages untrash left %q, want m1:INBOX", got)
+	}
+}
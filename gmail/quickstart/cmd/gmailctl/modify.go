@@ -0,0 +1,147 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name:    "modify",
+		usage:   "-query q " + queryFlagsUsage + " [-max-results n] [-add-label name]... [-remove-label name]... [-create] [-dry-run]",
+		summary: "Add and remove labels on every message matching a query (needs the gmail.modify scope).",
+		run:     runModify,
+	})
+}
+
+func runModify(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["modify"])
+	query := fs.String("query", profile.Query, "Gmail search query")
+	max := fs.Int64("max-results", 0, "maximum number of messages to change, 0 for all")
+	var add, remove stringList
+	fs.Var(&add, "add-label", "label to add, by name or ID (repeatable)")
+	fs.Var(&remove, "remove-label", "label to remove, by name or ID (repeatable)")
+	create := fs.Bool("create", false, "create labels given to -add-label that do not exist")
+	dryRun := fs.Bool("dry-run", false, "only count the matching messages")
+	qf := addQueryFlags(fs)
+	fs.Parse(args)
+	q, err := qf.apply(*query)
+	if err != nil {
+		return err
+	}
+	if *query = q; *query == "" {
+		fs.Usage()
+		return fmt.Errorf("modify: -query or a flag such as -since or -category is required")
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		fs.Usage()
+		return fmt.Errorf("modify: expected -add-label or -remove-label")
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		return modifyAccount(ctx, a, *query, *max, add, remove, *create, *dryRun)
+	})
+}
+
+// modifyAccount adds the labels add to and removes the labels remove from
+// the messages of a matching query, a thousand messages per request.
+func modifyAccount(ctx context.Context, a *account, query string, max int64, add, remove []string, create, dryRun bool) error {
+	labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to list labels: %w", err)
+	}
+	var addIDs []string
+	for _, name := range add {
+		l, err := gmailclient.FindLabel(labels, name)
+		if errors.Is(err, gmailclient.ErrLabelNotFound) && create && dryRun {
+			fmt.Fprintf(os.Stderr, "Would create label %s.\n", name)
+			continue
+		}
+		if errors.Is(err, gmailclient.ErrLabelNotFound) && create {
+			l, err = gmailclient.CreateLabel(ctx, a.api(), a.user, &gmail.Label{Name: name}, true)
+			if err != nil {
+				return fmt.Errorf("Unable to create label %s: %w", name, err)
+			}
+			fmt.Fprintf(os.Stderr, "Created label %s.\n", l.Name)
+		}
+		if err != nil {
+			return err
+		}
+		addIDs = append(addIDs, l.Id)
+	}
+	removeIDs, err := gmailclient.LabelIDs(labels, remove)
+	if err != nil {
+		return err
+	}
+
+	ids, err := matchingIDs(ctx, a, query, max)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("%d messages would be changed.\n", len(ids))
+		return nil
+	}
+	err = gmailclient.BatchModify(ctx, a.api(), a.user, ids, addIDs, removeIDs, progress(len(ids)))
+	if err != nil {
+		return fmt.Errorf("Unable to modify messages (is gmail.modify among -scopes?): %w", err)
+	}
+	if err := uncacheMessages(ctx, a, ids...); err != nil {
+		return err
+	}
+	fmt.Printf("Changed %d messages.\n", len(ids))
+	return nil
+}
+
+// matchingIDs returns the IDs of at most max messages of a matching query.
+func matchingIDs(ctx context.Context, a *account, query string, max int64) ([]string, error) {
+	c, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	err = gmailclient.ListAllMessages(ctx, c, a.user, query, max, func(m *gmail.Message) error {
+		ids = append(ids, m.Id)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list messages: %w", err)
+	}
+	return ids, nil
+}
+
+// progress returns a progress func for the gmailclient batch functions that
+// reports on stderr how many of total messages are done.
+func progress(total int) func(done int) {
+	return func(done int) {
+		if done < total && done%gmailclient.MaxBatchModify == 0 {
+			fmt.Fprintf(os.Stderr, "%d/%d\n", done, total)
+		}
+	}
+}
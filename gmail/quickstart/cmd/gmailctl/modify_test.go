@@ -0,0 +1,52 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestModifyCreate(t *testing.T) {
+	m, a := newLabelMailbox(t, map[string]string{"m1": "INBOX", "m2": "INBOX"})
+	m.labelList = []*gmail.Label{{Id: "INBOX", Name: "INBOX", Type: "system"}, {Id: "Label_Receipts", Name: "Receipts"}}
+
+	// A dry run reports the label it would create and changes nothing.
+	if err := modifyAccount(context.Background(), a, "in:inbox", 0, []string{"Receipts", "Travel"}, []string{"INBOX"}, true, true); err != nil {
+		t.Fatalf("modify -dry-run -create = %v", err)
+	}
+	if len(m.labelList) != 2 || m.String() != "m1:INBOX m2:INBOX" {
+		t.Errorf("modify -dry-run -create left labels %v and messages %q", m.labelList, m.String())
+	}
+
+	if err := modifyAccount(context.Background(), a, "in:inbox", 0, []string{"Travel"}, nil, false, true); err == nil {
+		t.Error("modify -dry-run without -create accepted a missing label")
+	}
+
+	if err := modifyAccount(context.Background(), a, "in:inbox", 0, []string{"Receipts", "Travel"}, []string{"INBOX"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.labelList) != 3 || m.labelList[2].Name != "Travel" {
+		t.Fatalf("labels after modify -create = %v, want Travel created", m.labelList)
+	}
+	if got := m.String(); got != "m1:Label_Receipts,Label_Travel m2:Label_Receipts,Label_Travel" {
+		t.Errorf("modify -create left %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/outbox"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+)
+
+func init() {
+	register(&command{
+		name:    "outbox",
+		usage:   "list [-status s] | cancel <id> | clean | run [-interval d] [-once]",
+		summary: "Manage and dispatch the messages scheduled with send -at.",
+		run:     runOutbox,
+	})
+}
+
+// outboxPath is set by the -outbox global flag.
+var outboxPath string
+
+// openOutbox opens the queue of scheduled messages.
+func openOutbox() (*outbox.Queue, error) {
+	path := config.ExpandHome(outboxPath)
+	if path == "" {
+		var err error
+		if path, err = config.OutboxPath(); err != nil {
+			return nil, err
+		}
+	}
+	q, err := outbox.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open outbox: %w", err)
+	}
+	q.MaxAttempts = retries + 1
+	return q, nil
+}
+
+// sendTimeLayouts are the formats accepted by send -at, in local time unless
+// they carry a zone.
+var sendTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// parseSendTime parses the value of send -at.
+func parseSendTime(s string) (time.Time, error) {
+	for _, layout := range sendTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want e.g. 2024-07-01T09:00", s)
+}
+
+// scheduleSend queues raw to be sent from the current account at t.
+func scheduleSend(ctx context.Context, t time.Time, raw []byte) error {
+	q, err := openOutbox()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	id, err := q.Add(ctx, profileName(), t, raw, "")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d\tscheduled for %s\n", id, t.Format(time.RFC1123))
+	return nil
+}
+
+func runOutbox(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["outbox"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("outbox: expected a subcommand")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+
+	fs = newFlagSet(commands["outbox"])
+	status := fs.String("status", "", "only list messages with this status: pending, sent or failed")
+	interval := fs.Duration("interval", time.Minute, "how often run looks for due messages")
+	once := fs.Bool("once", false, "send the due messages and exit instead of running until interrupted")
+	fs.Parse(args)
+
+	q, err := openOutbox()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	switch sub {
+	case "list":
+		return listOutbox(ctx, q, *status)
+	case "cancel":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("outbox cancel: expected exactly one message number")
+		}
+		id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("outbox cancel: invalid message number %q", fs.Arg(0))
+		}
+		return q.Cancel(ctx, id)
+	case "clean":
+		n, err := q.Remove(ctx, outbox.Sent)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d sent messages.\n", n)
+		return nil
+	case "run":
+		d := &dispatcher{accounts: map[string]*account{}}
+		if *once {
+			tried, err := q.Dispatch(ctx, time.Now(), d.send)
+			reportOutbox(tried)
+			return err
+		}
+		return q.Run(ctx, *interval, d.send, reportOutbox)
+	}
+	fs.Usage()
+	return fmt.Errorf("outbox: unknown subcommand %q", sub)
+}
+
+// listOutbox prints the queued messages with the given status, or all.
+func listOutbox(ctx context.Context, q *outbox.Queue, status string) error {
+	entries, err := q.List(ctx, status)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tACCOUNT\tSEND AT\tSTATUS\tTO\tSUBJECT\tERROR")
+	for _, e := range entries {
+		to, subject := "", ""
+		if m, err := mail.ReadMessage(bytes.NewReader(e.Raw)); err == nil {
+			to = gmailclient.FormatAddressList(gmailclient.ParseAddressList(m.Header.Get("To")))
+			subject = gmailclient.DecodeHeader(m.Header.Get("Subject"))
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.Account,
+			e.SendAt.Format("2006-01-02 15:04"), e.Status, to, subject, e.LastError)
+	}
+	return w.Flush()
+}
+
+// reportOutbox logs the outcome of sending the tried messages.
+func reportOutbox(tried []*outbox.Entry) {
+	for _, e := range tried {
+		switch e.Status {
+		case outbox.Sent:
+			fmt.Fprintf(os.Stderr, "%d: sent from %s as %s\n", e.ID, e.Account, e.MessageID)
+		case outbox.Failed:
+			fmt.Fprintf(os.Stderr, "%d: giving up after %d attempts: %s\n", e.ID, e.Attempts, e.LastError)
+		default:
+			fmt.Fprintf(os.Stderr, "%d: attempt %d failed, retrying at %s: %s\n",
+				e.ID, e.Attempts, e.NextAttempt.Format("15:04:05"), e.LastError)
+		}
+	}
+}
+
+// A dispatcher sends queued messages, authorizing each account once.
+type dispatcher struct {
+	accounts map[string]*account
+}
+
+func (d *dispatcher) send(ctx context.Context, e *outbox.Entry) (string, error) {
+	a, ok := d.accounts[e.Account]
+	if !ok {
+		var err error
+		if a, err = openNamedAccount(ctx, e.Account); err != nil {
+			return "", err
+		}
+		d.accounts[e.Account] = a
+	}
+	m, err := gmailclient.SendMessage(ctx, a.api(), a.user, e.Raw, e.ThreadID)
+	if err != nil {
+		return "", err
+	}
+	return m.Id, nil
+}
+
+// profileName returns the name under which the outbox and the snooze
+// database record the current account: its profile, or "default".
+func profileName() string {
+	if profile.Name == "" {
+		return "default"
+	}
+	return profile.Name
+}
+
+// openNamedAccount opens the account recorded under name by profileName.
+func openNamedAccount(ctx context.Context, name string) (*account, error) {
+	if p, ok := cfg.Profiles[name]; ok {
+		return openProfileAccount(ctx, p)
+	}
+	if name == "default" {
+		return currentAccount(ctx)
+	}
+	return nil, fmt.Errorf("no profile %q", name)
+}
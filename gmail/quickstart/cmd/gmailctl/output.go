@@ -0,0 +1,190 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/expr"
+	"gopkg.in/yaml.v3"
+)
+
+// The -where and -select flags of JSON output. A command has one output, so
+// they are kept here for newJSONWriter rather than passed down.
+var (
+	whereFlag, selectFlag string
+
+	outputWhere  *expr.Expr
+	outputSelect []*expr.Expr
+)
+
+// outputFlag adds the -output flag selecting text or JSON output to fs,
+// with the -where and -select flags that filter and project JSON output.
+func outputFlag(fs *flag.FlagSet) *string {
+	fs.StringVar(&whereFlag, "where", "", "only output the objects for which this expression is true, e.g. 'from contains \"@example.com\" && size > 100000' (json and ndjson)")
+	fs.StringVar(&selectFlag, "select", "", "comma-separated fields or expressions to output instead of whole objects, e.g. id,subject,from[0].address (json and ndjson)")
+	return fs.String("output", "text", "output format: text, json (one array) or ndjson (one object per line)")
+}
+
+// checkOutput validates an -output value and the -where and -select
+// expressions.
+func checkOutput(output string) error {
+	switch output {
+	case "text", "json", "ndjson":
+	default:
+		return fmt.Errorf("invalid -output %q (want text, json or ndjson)", output)
+	}
+	if whereFlag == "" && selectFlag == "" {
+		return nil
+	}
+	if output == "text" {
+		return fmt.Errorf("-where and -select need -output json or ndjson")
+	}
+	var err error
+	if whereFlag != "" {
+		if outputWhere, err = expr.Parse(whereFlag); err != nil {
+			return fmt.Errorf("invalid -where: %w", err)
+		}
+	}
+	if selectFlag != "" {
+		if outputSelect, err = expr.ParseList(selectFlag); err != nil {
+			return fmt.Errorf("invalid -select: %w", err)
+		}
+	}
+	return nil
+}
+
+// jsonWriter streams values as a JSON array, or as newline-delimited JSON
+// when ndjson is set, without holding them all in memory. Values for which
+// where is false are left out, and with select each value is replaced by an
+// object of the selected fields.
+type jsonWriter struct {
+	w      io.Writer
+	ndjson bool
+	n      int
+
+	where   *expr.Expr
+	selects []*expr.Expr
+}
+
+func newJSONWriter(w io.Writer, output string) *jsonWriter {
+	return &jsonWriter{w: w, ndjson: output == "ndjson", where: outputWhere, selects: outputSelect}
+}
+
+func (j *jsonWriter) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if j.where != nil || j.selects != nil {
+		var ok bool
+		if b, ok, err = j.project(b); err != nil || !ok {
+			return err
+		}
+	}
+	switch {
+	case j.ndjson:
+	case j.n == 0:
+		io.WriteString(j.w, "[\n")
+	default:
+		io.WriteString(j.w, ",\n")
+	}
+	j.n++
+	_, err = fmt.Fprintf(j.w, "%s", b)
+	if j.ndjson {
+		_, err = io.WriteString(j.w, "\n")
+	}
+	return err
+}
+
+// project applies where and select to the JSON value b, reporting false if
+// where leaves it out. Selected fields keep the order they were given in.
+func (j *jsonWriter) project(b []byte) ([]byte, bool, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, false, err
+	}
+	if j.where != nil && !j.where.Match(v) {
+		return nil, false, nil
+	}
+	if j.selects == nil {
+		return b, true, nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range j.selects {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(e.String())
+		if err != nil {
+			return nil, false, err
+		}
+		x, err := json.Marshal(e.Eval(v))
+		if err != nil {
+			return nil, false, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(x)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), true, nil
+}
+
+// close ends the JSON array.
+func (j *jsonWriter) close() error {
+	if j.ndjson {
+		return nil
+	}
+	if j.n == 0 {
+		_, err := io.WriteString(j.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n]\n")
+	return err
+}
+
+// markdownDocument renders m as a Markdown note: YAML front matter with the
+// subject, sender, date and message ID, then the body, converted from HTML
+// unless prefer selects the plain-text body.
+func markdownDocument(m *gmailclient.Message, prefer string) (string, error) {
+	body, mimeType := m.Body(prefer)
+	if mimeType == "text/html" {
+		var err error
+		if body, err = gmailclient.HTMLToMarkdown(body); err != nil {
+			return "", err
+		}
+	}
+	front, err := yaml.Marshal(struct {
+		Title string    `yaml:"title"`
+		From  string    `yaml:"from"`
+		Date  time.Time `yaml:"date"`
+		ID    string    `yaml:"gmail_id"`
+	}{m.Subject, gmailclient.FormatAddressList(m.From), m.Date, m.Id})
+	if err != nil {
+		return "", err
+	}
+	return "---\n" + string(front) + "---\n\n" + body, nil
+}
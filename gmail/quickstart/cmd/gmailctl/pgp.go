@@ -0,0 +1,88 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/term"
+)
+
+// pgpPassphraseEnv is the environment variable holding the passphrase of
+// the -pgp-key secret key.
+const pgpPassphraseEnv = "GMAIL_PGP_PASSPHRASE"
+
+// loadPGP reads the keys for -sign and -encrypt-to, asking for the
+// passphrase of the secret key if it has one.
+func (c *composeFlags) loadPGP() (*gmailclient.PGP, error) {
+	p := &gmailclient.PGP{}
+	if c.sign {
+		if c.pgpKey == "" {
+			return nil, fmt.Errorf("-sign needs a secret key in -pgp-key")
+		}
+		keys, err := gmailclient.ReadPGPKeys(c.pgpKey)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read -pgp-key: %w", err)
+		}
+		for _, e := range keys {
+			if e.PrivateKey != nil {
+				p.Signer = e
+				break
+			}
+		}
+		if p.Signer == nil {
+			return nil, fmt.Errorf("-pgp-key %s holds no secret key", c.pgpKey)
+		}
+		if gmailclient.PGPKeyEncrypted(p.Signer) {
+			passphrase, err := pgpPassphrase(p.Signer)
+			if err != nil {
+				return nil, err
+			}
+			if err := gmailclient.DecryptPGPKey(p.Signer, []byte(passphrase)); err != nil {
+				return nil, fmt.Errorf("Unable to unlock -pgp-key: %w", err)
+			}
+		}
+	}
+	for _, path := range c.encryptTo {
+		keys, err := gmailclient.ReadPGPKeys(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read -encrypt-to key: %w", err)
+		}
+		p.Recipients = append(p.Recipients, keys...)
+	}
+	return p, nil
+}
+
+// pgpPassphrase returns $GMAIL_PGP_PASSPHRASE, or asks for the passphrase
+// of e on the terminal.
+func pgpPassphrase(e *openpgp.Entity) (string, error) {
+	if p := os.Getenv(pgpPassphraseEnv); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to read the PGP key passphrase from; set $%s", pgpPassphraseEnv)
+	}
+	fmt.Fprintf(os.Stderr, "Passphrase for PGP key %s: ", e.PrimaryKey.KeyIdString())
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
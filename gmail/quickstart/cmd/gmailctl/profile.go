@@ -0,0 +1,97 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+)
+
+var (
+	// cfg is the loaded configuration file, empty if there is none.
+	cfg = &config.Config{}
+
+	// profile is the configuration profile selected with -profile. It is
+	// never nil once main has parsed the global flags.
+	profile = &config.Profile{}
+)
+
+// loadProfile reads the configuration file into cfg and selects the named
+// profile. A missing file is only an error if it was asked for explicitly,
+// with -config or -profile.
+func loadProfile(path, name string) (*config.Profile, error) {
+	explicit := path != "" || name != ""
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	c, err := config.Load(path)
+	if os.IsNotExist(err) && !explicit {
+		return &config.Profile{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cfg = c
+	return cfg.Profile(name)
+}
+
+// applyProfile fills in the global options that were set neither on the
+// command line nor in the environment from p. A named profile without a token
+// setting uses its default token file.
+func applyProfile(p *config.Profile, scopeList *string) error {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	override := func(dst *string, name, env, value string) {
+		if value != "" && !set[name] && os.Getenv(env) == "" {
+			*dst = value
+		}
+	}
+	override(&credentialsFile, "credentials", "GMAIL_CREDENTIALS", p.Credentials)
+	if p.Name != "" {
+		tok, err := p.TokenFile()
+		if err != nil {
+			return err
+		}
+		override(&tokenFile, "token", "GMAIL_TOKEN", tok)
+	}
+	override(&tokenStoreKind, "token-store", "GMAIL_TOKEN_STORE", p.TokenStore)
+	override(&serviceAccountFile, "service-account", "GMAIL_SERVICE_ACCOUNT", p.ServiceAccount)
+	if p.ADC && !set["adc"] && os.Getenv("GMAIL_ADC") == "" {
+		useADC = true
+	}
+	override(&user, "user", "GMAIL_USER", p.User)
+	override(scopeList, "scopes", "GMAIL_SCOPES", joinScopes(p.Scopes))
+	return nil
+}
+
+// outputDir returns the profile's output directory, or def if it has none.
+func outputDir(def string) string {
+	if profile.OutputDir != "" {
+		return profile.OutputDir
+	}
+	return def
+}
+
+func joinScopes(scopes []string) string {
+	return strings.Join(scopes, ",")
+}
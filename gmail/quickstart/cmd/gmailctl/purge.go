@@ -0,0 +1,132 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/term"
+)
+
+func init() {
+	register(&command{
+		name:    "purge",
+		usage:   "trash|spam [-older-than age] [-dry-run] [-yes]",
+		summary: "Permanently delete the messages in the trash or spam (needs the https://mail.google.com/ scope).",
+		run:     runPurge,
+	})
+}
+
+// purgeFolders are the purge subcommands, by the search that finds their
+// messages.
+var purgeFolders = map[string]string{
+	"trash": "in:trash",
+	"spam":  "in:spam",
+}
+
+func runPurge(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["purge"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("purge: expected trash or spam")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+	query, ok := purgeFolders[sub]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("purge: unknown subcommand %q", sub)
+	}
+
+	fs = newFlagSet(commands["purge"])
+	olderThan := fs.String("older-than", "", "only delete messages older than this, as in Gmail's older_than:, e.g. 30d, 2w, 6m or 1y")
+	dryRun := fs.Bool("dry-run", false, "only count the messages that would be deleted")
+	yes := fs.Bool("yes", false, "delete without asking")
+	fs.Parse(args)
+	if *olderThan != "" {
+		n, unit, err := gmailclient.ParseAge(*olderThan)
+		if err != nil {
+			return fmt.Errorf("-older-than: %w", err)
+		}
+		query += " older_than:" + strconv.Itoa(n) + unit
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		return purgeAccount(ctx, a, sub, query, *dryRun, *yes)
+	})
+}
+
+// purgeAccount permanently deletes the messages of a matching query, after
+// asking unless yes is set.
+func purgeAccount(ctx context.Context, a *account, folder, query string, dryRun, yes bool) error {
+	ids, err := matchingIDs(ctx, a, query, 0)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("%d messages would be deleted from the %s.\n", len(ids), folder)
+		return nil
+	}
+	if len(ids) == 0 {
+		fmt.Printf("The %s is empty.\n", folder)
+		return nil
+	}
+	if !yes {
+		ok, err := confirm(fmt.Sprintf("Permanently delete %d messages from the %s of %s?", len(ids), folder, a.name))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("purge %s: cancelled", folder)
+		}
+	}
+	if err := gmailclient.BatchDelete(ctx, a.api(), a.user, ids, progress(len(ids))); err != nil {
+		return fmt.Errorf("Unable to delete messages (is https://mail.google.com/ among -scopes?): %w", err)
+	}
+	if err := uncacheMessages(ctx, a, ids...); err != nil {
+		return err
+	}
+	fmt.Printf("%d messages deleted from the %s.\n", len(ids), folder)
+	return nil
+}
+
+// confirm asks question on the terminal and reports whether the answer was
+// yes. Without a terminal it fails, asking for -yes instead. It is a variable
+// so that tests can answer.
+var confirm = func(question string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("no terminal to confirm on; rerun with -yes")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
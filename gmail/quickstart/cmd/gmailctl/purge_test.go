@@ -0,0 +1,99 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// purgeTestAccount returns an account whose trash holds the messages ids,
+// recording the IDs of batch deletes in *deleted.
+func purgeTestAccount(t *testing.T, deleted *[]string, ids ...string) *account {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/users/me/messages"):
+			resp := &gmail.ListMessagesResponse{}
+			if r.URL.Query().Get("q") == "in:trash older_than:30d" {
+				for _, id := range ids {
+					resp.Messages = append(resp.Messages, &gmail.Message{Id: id, ThreadId: id})
+				}
+			}
+			json.NewEncoder(w).Encode(resp)
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/users/me/messages/batchDelete"):
+			var req gmail.BatchDeleteMessagesRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			*deleted = append(*deleted, req.Ids...)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(ts.URL), option.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &account{name: "test", user: "me", srv: srv}
+}
+
+func TestPurge(t *testing.T) {
+	defer func(f func(string) (bool, error)) { confirm = f }(confirm)
+	const query = "in:trash older_than:30d"
+	for _, tt := range []struct {
+		name    string
+		dryRun  bool
+		yes     bool
+		answer  bool // to the confirmation, if asked
+		wantErr bool
+		deleted []string
+	}{
+		{name: "dry run", dryRun: true, yes: true},
+		{name: "declined", answer: false, wantErr: true},
+		{name: "confirmed", answer: true, deleted: []string{"m1", "m2"}},
+		{name: "yes", yes: true, deleted: []string{"m1", "m2"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			asked := false
+			confirm = func(question string) (bool, error) {
+				asked = true
+				return tt.answer, nil
+			}
+			var deleted []string
+			a := purgeTestAccount(t, &deleted, "m1", "m2")
+			err := purgeAccount(context.Background(), a, "trash", query, tt.dryRun, tt.yes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("purgeAccount = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(deleted, tt.deleted) {
+				t.Errorf("deleted %q, want %q", deleted, tt.deleted)
+			}
+			if want := !tt.dryRun && !tt.yes; asked != want {
+				t.Errorf("asked for confirmation: %v, want %v", asked, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+// queryFlagsUsage is the usage of the flags addQueryFlags defines.
+const queryFlagsUsage = "[-since age|date] [-before age|date] [-larger size] [-category name] [-unread]"
+
+// queryFlags are flags that narrow -query with the Gmail search operators
+// people use most, so they need not remember the syntax.
+type queryFlags struct {
+	since, before string
+	larger        string
+	category      string
+	unread        bool
+}
+
+// addQueryFlags defines the query flags on fs.
+func addQueryFlags(fs *flag.FlagSet) *queryFlags {
+	f := &queryFlags{}
+	fs.StringVar(&f.since, "since", "", "only messages newer than an age such as 30d, 2w, 6m or 1y, or received on or after a date such as 2024-01-01")
+	fs.StringVar(&f.before, "before", "", "only messages older than an age such as 1y, or received before a date such as 2024-01-01")
+	fs.StringVar(&f.larger, "larger", "", "only messages larger than a size such as 500K or 5M")
+	fs.StringVar(&f.category, "category", "", "only messages in an inbox category: "+strings.Join(gmailclient.Categories, ", "))
+	fs.BoolVar(&f.unread, "unread", false, "only unread messages")
+	return f
+}
+
+// apply returns query ANDed with the Gmail search operators of the flags
+// that are set.
+func (f *queryFlags) apply(query string) (string, error) {
+	q := gmailclient.NewQuery()
+	if f.since != "" {
+		if err := queryTime(q, "since", f.since, "newer_than:", q.AfterDate); err != nil {
+			return "", err
+		}
+	}
+	if f.before != "" {
+		if err := queryTime(q, "before", f.before, "older_than:", q.BeforeDate); err != nil {
+			return "", err
+		}
+	}
+	if f.larger != "" {
+		size, err := gmailclient.ParseSize(f.larger)
+		if err != nil {
+			return "", fmt.Errorf("-larger: %w", err)
+		}
+		q.Larger(size)
+	}
+	if f.category != "" {
+		category := strings.ToLower(f.category)
+		if !oneOf(gmailclient.Categories, category) {
+			return "", fmt.Errorf("-category: unknown category %q (want one of %s)", f.category, strings.Join(gmailclient.Categories, ", "))
+		}
+		q.Category(category)
+	}
+	if f.unread {
+		q.Is("unread")
+	}
+	if terms := q.String(); terms != "" {
+		return andQuery(query, terms), nil
+	}
+	return query, nil
+}
+
+// queryTime adds the term of the -since or -before value v to q: age
+// followed by an age such as 30d, or date applied to a date in the local
+// time zone, given as YYYY-MM-DD or YYYY/MM/DD.
+func queryTime(q *gmailclient.Query, name, v, age string, date func(time.Time) *gmailclient.Query) error {
+	for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			date(t)
+			return nil
+		}
+	}
+	n, unit, err := gmailclient.ParseAge(v)
+	if err != nil {
+		return fmt.Errorf("-%s: invalid age or date %q (want an age such as 30d, 2w, 6m or 1y, or a date such as 2024-01-01)", name, v)
+	}
+	q.Term(age + strconv.Itoa(n) + unit)
+	return nil
+}
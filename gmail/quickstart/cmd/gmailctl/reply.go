@@ -0,0 +1,107 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func init() {
+	register(&command{
+		name:    "reply",
+		usage:   "[-all] [-body text|-body-file path] [-html text|-html-file path] [-to addrs] [-cc addrs] [-bcc addrs] [-attach file]... [-no-quote] [-dry-run] <message-id>",
+		summary: "Reply to a message in its thread (needs the gmail.send scope).",
+		run:     runReply,
+	})
+}
+
+func runReply(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["reply"])
+	var c composeFlags
+	c.register(fs)
+	all := fs.Bool("all", false, "reply to all recipients, not just the sender")
+	noQuote := fs.Bool("no-quote", false, "do not quote the original message")
+	dryRun := fs.Bool("dry-run", false, "print the reply instead of sending it")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("reply: expected exactly one message ID")
+	}
+	id := fs.Arg(0)
+	extra, err := c.message()
+	if err != nil {
+		return err
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	me, err := client.GetProfile(ctx, a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve profile: %w", err)
+	}
+	msg, err := client.GetMessage(ctx, a.user, id, "full")
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve message %v: %w", id, err)
+	}
+	orig, err := gmailclient.ParseMessage(ctx, client, msg, a.user)
+	if err != nil {
+		return err
+	}
+
+	r := gmailclient.NewReply(orig, me.EmailAddress, *all)
+	r.From = extra.From
+	r.To = append(r.To, extra.To...)
+	r.Cc = append(r.Cc, extra.Cc...)
+	r.Bcc = extra.Bcc
+	if extra.Subject != "" {
+		r.Subject = extra.Subject
+	}
+	r.Attachments = extra.Attachments
+	r.Wrapper = extra.Wrapper
+	r.Text, r.HTML = extra.Text, extra.HTML
+	if !*noQuote {
+		r.Text += "\n\n" + gmailclient.QuoteText(orig)
+		if r.HTML != "" {
+			r.HTML += "\n<br>\n" + gmailclient.QuoteHTML(orig)
+		}
+	}
+	raw, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		_, err := os.Stdout.Write(raw)
+		return err
+	}
+	sent, err := gmailclient.SendMessage(ctx, a.api(), a.user, raw, orig.ThreadId)
+	if err != nil {
+		return fmt.Errorf("Unable to send reply (is gmail.send among -scopes?): %w", err)
+	}
+	fmt.Println(sent.Id)
+	return nil
+}
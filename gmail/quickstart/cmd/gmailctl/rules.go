@@ -0,0 +1,340 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func init() {
+	register(&command{
+		name:    "apply-rules",
+		usage:   "[-rules path] [-query q] [-max-results n] [-dry-run] | -watch interval [-state path] [-dry-run]",
+		summary: "Run the rules of a rules file on matching messages, or on new mail as it arrives.",
+		run:     runApplyRules,
+	})
+}
+
+func runApplyRules(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["apply-rules"])
+	rulesFile := fs.String("rules", "", "rules file (default $XDG_CONFIG_HOME/gmailtool/rules.yaml)")
+	query := fs.String("query", profile.Query, "Gmail search query of the messages to run the rules on")
+	max := fs.Int64("max-results", 100, "maximum number of messages to run the rules on, 0 for all")
+	dryRun := fs.Bool("dry-run", false, "print the rules each message matches without running their actions")
+	watch := fs.Duration("watch", 0, "instead of -query, run the rules on new mail, checking this often")
+	state := fs.String("state", "", "with -watch, the state file (default $XDG_CONFIG_HOME/gmailtool/rules/<account>.json)")
+	fs.Parse(args)
+
+	path := *rulesFile
+	if path == "" {
+		var err error
+		if path, err = config.RulesPath(); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(config.ExpandHome(path))
+	if err != nil {
+		return fmt.Errorf("Unable to read rules: %w", err)
+	}
+	rules, err := gmailclient.ReadRules(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("Unable to read rules: %w", err)
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	if *state != "" && len(accounts) > 1 {
+		return fmt.Errorf("-state cannot be used with several accounts")
+	}
+	runners := make(map[*account]*rulesRunner, len(accounts))
+	for _, a := range accounts {
+		runners[a] = &rulesRunner{a: a, rules: rules, dryRun: *dryRun}
+	}
+	if *watch == 0 {
+		return forEachAccount(ctx, accounts, func(a *account) error {
+			ids, err := matchingIDs(ctx, a, *query, *max)
+			if err != nil {
+				return err
+			}
+			return runners[a].run(ctx, ids)
+		})
+	}
+
+	ticker := time.NewTicker(*watch)
+	defer ticker.Stop()
+	for {
+		err := forEachAccount(ctx, accounts, func(a *account) error {
+			path := *state
+			if path == "" {
+				var err error
+				if path, err = config.RulesStatePath(a.name); err != nil {
+					return err
+				}
+			}
+			return runners[a].runNew(ctx, path)
+		})
+		if err != nil {
+			// Keep watching; failures have been reported.
+			fmt.Fprintln(os.Stderr, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// A rulesRunner runs rules on the messages of an account.
+type rulesRunner struct {
+	a      *account
+	rules  []*gmailclient.Rule
+	dryRun bool
+}
+
+// webhookClient posts to rule webhooks.
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// runNew runs the rules on the messages that arrived since the history ID
+// saved in path, and saves the new one. The first run only records where
+// the mailbox is, so that rules are not run on old mail. Messages the rules
+// failed on are not retried, so that the actions that did succeed, such as
+// forwarding, are not repeated.
+func (r *rulesRunner) runNew(ctx context.Context, path string) error {
+	s, err := gmailclient.LoadSyncState(path)
+	if err != nil {
+		return err
+	}
+	client := gmailclient.NewClient(r.a.srv)
+	if s.HistoryId == 0 {
+		p, err := client.GetProfile(ctx, r.a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve profile: %w", err)
+		}
+		s.HistoryId = p.HistoryId
+		return gmailclient.SaveSyncState(path, s)
+	}
+	c, err := gmailclient.Sync(ctx, client, r.a.user, s.HistoryId)
+	if err != nil {
+		return fmt.Errorf("Unable to sync: %w", err)
+	}
+	if c.Full {
+		fmt.Fprintln(os.Stderr, "The sync history has expired; rules skip the mail that arrived meanwhile.")
+	} else {
+		err = r.run(ctx, c.Added)
+		if ctx.Err() != nil {
+			return err // interrupted: leave the rest for the next run
+		}
+	}
+	s.HistoryId = c.HistoryId
+	if serr := gmailclient.SaveSyncState(path, s); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// run runs the rules on the messages ids, skipping drafts and sent mail. It
+// carries on past failures, reporting them on stderr, and returns an error
+// counting them.
+func (r *rulesRunner) run(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	labels, err := gmailclient.ListLabels(ctx, r.a.api(), r.a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to list labels: %w", err)
+	}
+	client, err := r.a.client()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.runMessage(ctx, client, labels, id); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("rules failed on %d of %d messages", failed, len(ids))
+	}
+	return nil
+}
+
+// runMessage runs the rules on message id.
+func (r *rulesRunner) runMessage(ctx context.Context, client gmailclient.GmailService, labels []*gmail.Label, id string) error {
+	msg, err := client.GetMessage(ctx, r.a.user, id, "full")
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+		return nil // deleted since
+	} else if err != nil {
+		return fmt.Errorf("Unable to retrieve message: %w", err)
+	}
+	for _, l := range msg.LabelIds {
+		if l == "DRAFT" || l == "SENT" {
+			return nil
+		}
+	}
+	m, err := gmailclient.ParseMessage(ctx, client, msg, r.a.user)
+	if err != nil {
+		return err
+	}
+	for _, rule := range gmailclient.MatchRules(r.rules, m, labels) {
+		if multiAccount() {
+			fmt.Printf("%s\t", r.a.name)
+		}
+		fmt.Printf("%s\t%s\t%s\n", id, rule.Name, m.Subject)
+		if r.dryRun {
+			continue
+		}
+		if err := r.act(ctx, client, labels, rule, msg, m); err != nil {
+			return fmt.Errorf("%s: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// act runs the actions of rule on a message, trashing it last.
+func (r *rulesRunner) act(ctx context.Context, client gmailclient.GmailService, labels []*gmail.Label, rule *gmailclient.Rule, msg *gmail.Message, m *gmailclient.Message) error {
+	if rule.SaveAttachments != "" {
+		if err := r.saveAttachments(ctx, client, config.ExpandHome(rule.SaveAttachments), msg, m); err != nil {
+			return err
+		}
+	}
+	if rule.Webhook != "" {
+		if err := r.postWebhook(ctx, rule, m); err != nil {
+			return err
+		}
+	}
+	if rule.Forward != "" {
+		if err := r.forward(ctx, client, rule.ForwardTo(), msg, m); err != nil {
+			return err
+		}
+	}
+	addNames, removeNames := rule.LabelChanges()
+	add, err := gmailclient.LabelIDs(labels, addNames)
+	if err != nil {
+		return err
+	}
+	remove, err := gmailclient.LabelIDs(labels, removeNames)
+	if err != nil {
+		return err
+	}
+	if err := gmailclient.BatchModify(ctx, r.a.api(), r.a.user, []string{m.Id}, add, remove, nil); err != nil {
+		return fmt.Errorf("Unable to modify message (is gmail.modify among -scopes?): %w", err)
+	}
+	if rule.Trash {
+		if err := gmailclient.TrashMessages(ctx, r.a.api(), r.a.user, []string{m.Id}, nil); err != nil {
+			return fmt.Errorf("Unable to trash message (is gmail.modify among -scopes?): %w", err)
+		}
+	}
+	return uncacheMessages(ctx, r.a, m.Id)
+}
+
+// saveAttachments saves the attachments of a message to <dir>/<id>/.
+func (r *rulesRunner) saveAttachments(ctx context.Context, client gmailclient.GmailService, dir string, msg *gmail.Message, m *gmailclient.Message) error {
+	dir = filepath.Join(dir, m.Id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, att := range m.Attachments {
+		f, err := atomicfile.Create(filepath.Join(dir, attachmentFilename(att)), 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := gmailclient.WriteAttachment(ctx, client, r.a.user, msg, att, f); err != nil {
+			f.Abort()
+			return fmt.Errorf("Unable to download attachment %s: %w", att.Filename, err)
+		}
+		if err := f.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postWebhook posts the parsed message as JSON to the rule's webhook, with
+// the rule and account names.
+func (r *rulesRunner) postWebhook(ctx context.Context, rule *gmailclient.Rule, m *gmailclient.Message) error {
+	body, err := json.Marshal(struct {
+		Rule    string               `json:"rule"`
+		Account string               `json:"account"`
+		Message *gmailclient.Message `json:"message"`
+	}{rule.Name, r.a.name, m})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", rule.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("Unable to call webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Unable to call webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// forward forwards a message inline, with its attachments, to addrs.
+func (r *rulesRunner) forward(ctx context.Context, client gmailclient.GmailService, addrs []*mail.Address, msg *gmail.Message, orig *gmailclient.Message) error {
+	f := gmailclient.NewForward(orig)
+	f.To = addrs
+	f.Text = gmailclient.ForwardedText(orig)
+	if orig.BodyHtml != "" {
+		f.HTML = gmailclient.ForwardedHTML(orig)
+	}
+	atts, err := gmailclient.ForwardAttachments(ctx, client, r.a.user, msg, orig)
+	if err != nil {
+		return fmt.Errorf("Unable to download attachments: %w", err)
+	}
+	f.Attachments = atts
+	raw, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	if _, err := gmailclient.SendMessage(ctx, r.a.api(), r.a.user, raw, ""); err != nil {
+		return fmt.Errorf("Unable to forward message (is gmail.send among -scopes?): %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/index"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func init() {
+	register(&command{
+		name:    "search",
+		usage:   "accounts [-max-results n] [-output text|json|ndjson] <query> | index [-index path] [-account name] [path...] | local [-index path] [-max-results n] [-output text|json|ndjson] <query>",
+		summary: "Search every configured account at once, or index cached and exported messages and search them offline.",
+		run:     runSearch,
+	})
+}
+
+func runSearch(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["search"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("search: expected accounts, index or local")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+
+	fs = newFlagSet(commands["search"])
+	if sub == "accounts" {
+		return runSearchAccounts(ctx, fs, args)
+	}
+	db := fs.String("index", os.Getenv("GMAIL_SEARCH_INDEX"), "full-text index (env GMAIL_SEARCH_INDEX; default $XDG_CONFIG_HOME/gmailtool/search.db)")
+	switch sub {
+	case "index":
+		return runSearchIndex(ctx, fs, db, args)
+	case "local":
+		return runSearchLocal(ctx, fs, db, args)
+	}
+	fs.Usage()
+	return fmt.Errorf("search: unknown subcommand %q", sub)
+}
+
+// openIndex opens the full-text index at path, or at the default location.
+func openIndex(path string) (*index.Index, error) {
+	path = config.ExpandHome(path)
+	if path == "" {
+		var err error
+		if path, err = config.SearchIndexPath(); err != nil {
+			return nil, err
+		}
+	}
+	ix, err := index.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open search index: %w", err)
+	}
+	return ix, nil
+}
+
+func runSearchIndex(ctx context.Context, fs *flag.FlagSet, db *string, args []string) error {
+	account := fs.String("account", "", "only index the cached messages of this account")
+	fs.Parse(args)
+	mc, err := openCache()
+	if err != nil {
+		return err
+	}
+	if mc == nil && fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("search index: nothing to index; set -cache or name exported files or directories")
+	}
+	ix, err := openIndex(*db)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	var added, skipped int
+	add := func(d *index.Document) error {
+		if err := ix.Add(ctx, d); err != nil {
+			return err
+		}
+		added++
+		return nil
+	}
+	if mc != nil {
+		err := mc.Each(ctx, *account, func(acct string, msg *gmail.Message) error {
+			m, err := gmailclient.ParseMessage(ctx, offlineService{}, msg, "")
+			if err != nil {
+				// Bodies kept as attachments are not in the cache.
+				if m, err = gmailclient.ParseMetadata(msg); err != nil {
+					fmt.Fprintf(os.Stderr, "Skipping cached message %s: %v\n", msg.Id, err)
+					skipped++
+					return nil
+				}
+			}
+			return add(index.NewDocument(acct, "", m))
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to index the message cache: %w", err)
+		}
+	}
+	for _, root := range fs.Args() {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			n, err := indexFile(ctx, path, add)
+			skipped += n
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to index %s: %w", root, err)
+		}
+	}
+	fmt.Printf("Indexed %d messages", added)
+	if skipped > 0 {
+		fmt.Printf(", skipped %d", skipped)
+	}
+	fmt.Println(".")
+	return nil
+}
+
+// indexFile passes the messages of an exported .eml, .mbox or Maildir file
+// to add, and returns how many it skipped as unreadable. Other files are
+// ignored.
+func indexFile(ctx context.Context, path string, add func(*index.Document) error) (skipped int, err error) {
+	parse := func(source string, raw []byte) error {
+		m, err := gmailclient.ParseRawMessage(ctx, "", raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", source, err)
+			skipped++
+			return nil
+		}
+		return add(index.NewDocument("", source, m))
+	}
+	dir := filepath.Base(filepath.Dir(path))
+	switch {
+	case strings.HasSuffix(path, ".eml"), dir == "cur" || dir == "new":
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return skipped, err
+		}
+		return skipped, parse(path, raw)
+	case strings.HasSuffix(path, ".mbox"):
+		f, err := os.Open(path)
+		if err != nil {
+			return skipped, err
+		}
+		defer f.Close()
+		r := gmailclient.NewMboxReader(f)
+		for i := 1; ; i++ {
+			raw, err := r.Next()
+			if err == io.EOF {
+				return skipped, nil
+			}
+			if err != nil {
+				return skipped, err
+			}
+			if err := parse(fmt.Sprintf("%s:%d", path, i), raw); err != nil {
+				return skipped, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+// errOffline is returned for the attachments offlineService is asked for.
+var errOffline = errors.New("not available offline")
+
+// offlineService answers nothing, so that cached messages are parsed from
+// what the cache holds.
+type offlineService struct {
+	gmailclient.GmailService
+}
+
+func (offlineService) GetAttachment(ctx context.Context, user, messageID, id string) (*gmail.MessagePartBody, error) {
+	return nil, errOffline
+}
+
+func runSearchLocal(ctx context.Context, fs *flag.FlagSet, db *string, args []string) error {
+	max := fs.Int("max-results", 20, "maximum number of messages to list, 0 for all")
+	output := outputFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("search local: expected a query")
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	ix, err := openIndex(*db)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+	results, err := ix.Search(ctx, strings.Join(fs.Args(), " "), *max)
+	if err != nil {
+		return err
+	}
+
+	if *output != "text" {
+		j := newJSONWriter(os.Stdout, *output)
+		for _, r := range results {
+			if err := j.write(r); err != nil {
+				return err
+			}
+		}
+		return j.close()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MESSAGE\tDATE\tFROM\tSUBJECT\tSNIPPET")
+	for _, r := range results {
+		where := r.Source
+		if r.ID != "" {
+			where = r.Account + "/" + r.ID
+		}
+		snippet := strings.Join(strings.Fields(r.Snippet), " ")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", where, r.Date.Local().Format("2006-01-02"), r.From, r.Subject, snippet)
+	}
+	return w.Flush()
+}
+
+func runSearchAccounts(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	max := fs.Int64("max-results", 20, "maximum number of messages to list from each account, 0 for all")
+	output := outputFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("search accounts: expected a Gmail search query")
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	// Search every configured profile, unless -all-users picked the
+	// users of a domain instead.
+	if !allUsers {
+		if len(cfg.ProfileNames()) == 0 {
+			return fmt.Errorf("search accounts: no profiles configured")
+		}
+		allAccounts = true
+	}
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	api := func(a *account) gmailclient.GmailService { return a.api() }
+	hits, err := searchAccounts(ctx, accounts, api, strings.Join(fs.Args(), " "), *max)
+
+	if *output != "text" {
+		j := newJSONWriter(os.Stdout, *output)
+		for _, h := range hits {
+			if werr := j.write(h); werr != nil {
+				return werr
+			}
+		}
+		if cerr := j.close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tACCOUNT\tMESSAGE\tFROM\tSUBJECT")
+	for _, h := range hits {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", h.Date.Local().Format("2006-01-02 15:04"), h.Account, h.Id,
+			gmailclient.FormatAddressList(h.From), h.Subject)
+	}
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// searchAccounts runs query against all accounts at once, through the
+// client service returns for each, and returns the messages found, newest
+// first, each tagged with its account. Accounts that fail are reported on
+// stderr; the others' messages are still returned, with an error counting
+// the failures.
+func searchAccounts(ctx context.Context, accounts []*account, service func(*account) gmailclient.GmailService, query string, max int64) ([]listMessage, error) {
+	found := make([][]listMessage, len(accounts))
+	errs := make([]error, len(accounts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, a := range accounts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, a *account) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			opts := gmailclient.FetchOptions{
+				Query:           query,
+				MaxResults:      max,
+				MetadataHeaders: []string{"From", "To", "Subject", "Date"},
+				Fields:          []googleapi.Field{"id", "threadId", "labelIds", "sizeEstimate", "internalDate", "payload/headers"},
+				Concurrency:     concurrency,
+			}
+			errs[i] = gmailclient.FetchMessages(ctx, service(a), a.user, opts, func(msg *gmail.Message) error {
+				m, err := gmailclient.ParseMetadata(msg)
+				if err != nil {
+					return err
+				}
+				found[i] = append(found[i], listMessage{Account: a.name, Message: m})
+				return nil
+			})
+		}(i, a)
+	}
+	wg.Wait()
+
+	var hits []listMessage
+	failed := 0
+	for i, a := range accounts {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "account %s: %v\n", a.name, errs[i])
+			failed++
+		}
+		hits = append(hits, found[i]...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Date.After(hits[j].Date) })
+	if failed > 0 {
+		return hits, fmt.Errorf("%d of %d accounts failed", failed, len(accounts))
+	}
+	return hits, nil
+}
@@ -0,0 +1,98 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+// failingMailbox is a mailbox whose listing fails.
+type failingMailbox struct {
+	*gmailclienttest.Service
+}
+
+func (failingMailbox) ListMessages(ctx context.Context, user, query, pageToken string, maxResults int64) (*gmail.ListMessagesResponse, error) {
+	return nil, errors.New("quota exceeded")
+}
+
+// datedMailbox returns a fake mailbox holding a message with each id and
+// Date header in dates.
+func datedMailbox(dates map[string]string) *gmailclienttest.Service {
+	s := gmailclienttest.New()
+	for id, date := range dates {
+		s.AddMessage(&gmail.Message{Id: id, ThreadId: id, Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{{Name: "Date", Value: date}, {Name: "Subject", Value: id}},
+		}})
+	}
+	return s
+}
+
+func TestSearchAccounts(t *testing.T) {
+	defer func(n int) { concurrency = n }(concurrency)
+	concurrency = 2
+	mailboxes := map[string]gmailclient.GmailService{
+		"work": datedMailbox(map[string]string{
+			"w1": "Mon, 4 Mar 2024 09:00:00 +0000",
+			"w2": "Wed, 6 Mar 2024 09:00:00 +0000",
+		}),
+		"home": datedMailbox(map[string]string{
+			"h1": "Tue, 5 Mar 2024 09:00:00 +0000",
+			"h2": "Thu, 7 Mar 2024 08:00:00 +0100",
+		}),
+		"broken": failingMailbox{gmailclienttest.New()},
+	}
+	service := func(a *account) gmailclient.GmailService { return mailboxes[a.name] }
+	open := func(names ...string) []*account {
+		var accounts []*account
+		for _, name := range names {
+			accounts = append(accounts, &account{name: name, user: "me"})
+		}
+		return accounts
+	}
+	ids := func(hits []listMessage) []string {
+		var ids []string
+		for _, h := range hits {
+			ids = append(ids, h.Account+"/"+h.Id)
+		}
+		return ids
+	}
+
+	hits, err := searchAccounts(context.Background(), open("work", "home"), service, "in:inbox", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"home/h2", "work/w2", "home/h1", "work/w1"}
+	if got := ids(hits); !reflect.DeepEqual(got, want) {
+		t.Errorf("searchAccounts = %q, want %q", got, want)
+	}
+
+	hits, err = searchAccounts(context.Background(), open("work", "broken", "home"), service, "in:inbox", 0)
+	if err == nil || err.Error() != "1 of 3 accounts failed" {
+		t.Errorf("searchAccounts with a failing account = %v, want 1 of 3 accounts failed", err)
+	}
+	if got := ids(hits); !reflect.DeepEqual(got, want) {
+		t.Errorf("searchAccounts with a failing account = %q, want the others' %q", got, want)
+	}
+}
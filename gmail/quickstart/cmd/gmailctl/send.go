@@ -0,0 +1,272 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func init() {
+	register(&command{
+		name:    "send",
+		usage:   "-to addrs [-cc addrs] [-bcc addrs] [-from addr] -subject s [-body text|-body-file path] [-html text|-html-file path] [-template file [-html-template file] -data file.json] [-attach file]... [-at time] [-dry-run]",
+		summary: "Compose and send a message (needs the gmail.send scope).",
+		run:     runSend,
+	})
+}
+
+// composeFlags are the flags that build an OutgoingMessage.
+type composeFlags struct {
+	to, cc, bcc, from string
+	subject           string
+	body, bodyFile    string
+	html, htmlFile    string
+	template          string
+	htmlTemplate      string
+	data              string
+	attach            stringList
+	sign              bool
+	pgpKey            string
+	encryptTo         stringList
+	smime             bool
+	smimeCert         string
+
+	// Loaded on first use, so that merge asks for a passphrase once.
+	pgp     *gmailclient.PGP
+	smimeID *gmailclient.SMIME
+}
+
+// stringList is a flag.Value collecting the values of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func (c *composeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.to, "to", "", "comma-separated recipients")
+	fs.StringVar(&c.cc, "cc", "", "comma-separated Cc recipients")
+	fs.StringVar(&c.bcc, "bcc", "", "comma-separated Bcc recipients")
+	fs.StringVar(&c.from, "from", "", "sender address, one of the account's send-as addresses; default the account's own")
+	fs.StringVar(&c.subject, "subject", "", "subject")
+	fs.StringVar(&c.body, "body", "", "plain-text body")
+	fs.StringVar(&c.bodyFile, "body-file", "", "read the plain-text body from this file, - for stdin")
+	fs.StringVar(&c.html, "html", "", "HTML body")
+	fs.StringVar(&c.htmlFile, "html-file", "", "read the HTML body from this file, - for stdin")
+	fs.StringVar(&c.template, "template", "", "render headers and plain-text body from this text/template file")
+	fs.StringVar(&c.htmlTemplate, "html-template", "", "render the HTML body from this html/template file")
+	fs.StringVar(&c.data, "data", "", "JSON file with the values for -template and -html-template")
+	fs.Var(&c.attach, "attach", "attach a file; repeat for several (25 MB in total at most)")
+	fs.BoolVar(&c.sign, "sign", false, "sign the message with PGP/MIME using the secret key in -pgp-key")
+	fs.StringVar(&c.pgpKey, "pgp-key", os.Getenv("GMAIL_PGP_KEY"), "OpenPGP secret key file for -sign (env GMAIL_PGP_KEY)")
+	fs.Var(&c.encryptTo, "encrypt-to", "encrypt the message with PGP/MIME to the public key in this file; repeat for several recipients")
+	fs.BoolVar(&c.smime, "smime", false, "sign the message with S/MIME using the certificate in -smime-cert")
+	fs.StringVar(&c.smimeCert, "smime-cert", os.Getenv("GMAIL_SMIME_CERT"), "PKCS#12 (.p12) or PEM file with the S/MIME certificate and key for -smime (env GMAIL_SMIME_CERT)")
+}
+
+// message builds the message the flags describe.
+func (c *composeFlags) message() (*gmailclient.OutgoingMessage, error) {
+	tmpl, err := c.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if c.data != "" {
+		if tmpl == nil {
+			return nil, fmt.Errorf("-data needs -template or -html-template")
+		}
+		b, err := ioutil.ReadFile(c.data)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read template data: %w", err)
+		}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, fmt.Errorf("Unable to parse template data %s: %w", c.data, err)
+		}
+	}
+	return c.render(tmpl, data)
+}
+
+// render builds the message the flags describe, with the headers and bodies
+// rendered from tmpl with data if tmpl is not nil.
+func (c *composeFlags) render(tmpl *gmailclient.MessageTemplate, data interface{}) (*gmailclient.OutgoingMessage, error) {
+	m := &gmailclient.OutgoingMessage{}
+	var err error
+	if tmpl != nil {
+		if m, err = tmpl.Execute(data); err != nil {
+			return nil, fmt.Errorf("Unable to render template: %w", err)
+		}
+	}
+	// Flags take precedence over what the templates render.
+	for _, f := range []struct {
+		name, value string
+		list        *[]*mail.Address
+	}{{"to", c.to, &m.To}, {"cc", c.cc, &m.Cc}, {"bcc", c.bcc, &m.Bcc}} {
+		if f.value == "" {
+			continue
+		}
+		if *f.list, err = mail.ParseAddressList(f.value); err != nil {
+			return nil, fmt.Errorf("invalid -%s %q: %w", f.name, f.value, err)
+		}
+	}
+	if c.subject != "" {
+		m.Subject = c.subject
+	}
+	if c.body != "" {
+		m.Text = c.body
+	}
+	if c.html != "" {
+		m.HTML = c.html
+	}
+	if c.from != "" {
+		from, err := mail.ParseAddress(c.from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -from %q: %w", c.from, err)
+		}
+		m.From = from
+	}
+	if c.bodyFile == "-" && c.htmlFile == "-" {
+		return nil, fmt.Errorf("only one of -body-file and -html-file can read stdin")
+	}
+	if c.bodyFile != "" {
+		if m.Text, err = readBodyFile(c.bodyFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.htmlFile != "" {
+		if m.HTML, err = readBodyFile(c.htmlFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range c.attach {
+		a, err := gmailclient.AttachFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to attach %s: %w", path, err)
+		}
+		m.Attachments = append(m.Attachments, a)
+	}
+	if c.smime && (c.sign || len(c.encryptTo) > 0) {
+		return nil, fmt.Errorf("-smime cannot be combined with PGP -sign or -encrypt-to")
+	}
+	if c.smime {
+		if c.smimeID == nil {
+			if c.smimeID, err = c.loadSMIME(); err != nil {
+				return nil, err
+			}
+		}
+		m.Wrapper = c.smimeID
+	}
+	if c.sign || len(c.encryptTo) > 0 {
+		if c.pgp == nil {
+			if c.pgp, err = c.loadPGP(); err != nil {
+				return nil, err
+			}
+		}
+		m.Wrapper = c.pgp
+	}
+	return m, nil
+}
+
+// parseTemplate parses -template and -html-template. It returns nil if
+// neither is set.
+func (c *composeFlags) parseTemplate() (*gmailclient.MessageTemplate, error) {
+	if c.template == "" && c.htmlTemplate == "" {
+		return nil, nil
+	}
+	tmpl, err := gmailclient.ParseMessageTemplateFiles(c.template, c.htmlTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// readBodyFile reads a body from path, or from stdin for "-".
+func readBodyFile(path string) (string, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Unable to read body: %w", err)
+	}
+	return string(b), nil
+}
+
+func runSend(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["send"])
+	var c composeFlags
+	c.register(fs)
+	dryRun := fs.Bool("dry-run", false, "print the message instead of sending it")
+	at := fs.String("at", "", "queue the message in the outbox to be sent at this local time, such as 2024-07-01T09:00, by outbox run")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("send: unexpected arguments %v", fs.Args())
+	}
+	m, err := c.message()
+	if err != nil {
+		return err
+	}
+	var sendAt time.Time
+	if *at != "" {
+		if sendAt, err = parseSendTime(*at); err != nil {
+			return err
+		}
+		if sendAt.Before(time.Now()) {
+			return fmt.Errorf("-at %s is in the past", *at)
+		}
+		m.Date = sendAt
+	}
+	raw, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		_, err := os.Stdout.Write(raw)
+		return err
+	}
+	if !sendAt.IsZero() {
+		return scheduleSend(ctx, sendAt, raw)
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	sent, err := gmailclient.SendMessage(ctx, a.api(), a.user, raw, "")
+	if err != nil {
+		return fmt.Errorf("Unable to send message (is gmail.send among -scopes?): %w", err)
+	}
+	fmt.Println(sent.Id)
+	return nil
+}
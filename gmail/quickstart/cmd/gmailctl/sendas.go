@@ -0,0 +1,324 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func runSendAs(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("sendas", args, "list", "create", "delete", "verify")
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "create":
+		return createSendAs(ctx, fs, args)
+	case "delete", "verify":
+		return changeSendAs(ctx, action, fs, args)
+	}
+	output := outputFlag(fs)
+	fs.Parse(args)
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	j := newJSONWriter(os.Stdout, *output)
+	err = forEachAccount(ctx, accounts, func(a *account) error {
+		aliases, err := gmailclient.ListSendAs(ctx, a.api(), a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to list send-as aliases: %w", err)
+		}
+		if *output != "text" {
+			for _, s := range aliases {
+				if err := j.write(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ADDRESS\tNAME\tPRIMARY\tDEFAULT\tVERIFICATION\tREPLY-TO\tSMTP\tSIGNATURE")
+		for _, s := range aliases {
+			verification := s.VerificationStatus
+			if verification == "" {
+				verification = "-"
+			}
+			smtp := "-"
+			if s.SmtpMsa != nil {
+				smtp = fmt.Sprintf("%s:%d", s.SmtpMsa.Host, s.SmtpMsa.Port)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.SendAsEmail, s.DisplayName,
+				yesNo(s.IsPrimary), yesNo(s.IsDefault), verification, s.ReplyToAddress, smtp, yesNo(s.Signature != ""))
+		}
+		return w.Flush()
+	})
+	if *output != "text" {
+		if cerr := j.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// smtpSecurityModes are the values of -smtp-security, as the API spells them.
+var smtpSecurityModes = []string{"none", "ssl", "starttls"}
+
+// createSendAs adds a send-as alias to the current account and, with -wait,
+// waits for its address to be verified.
+func createSendAs(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	name := fs.String("name", "", "name shown in the From header")
+	replyTo := fs.String("reply-to", "", "Reply-To address of the alias's mail")
+	isDefault := fs.Bool("default", false, "make the alias the default From address")
+	treatAsAlias := fs.Bool("treat-as-alias", true, "have Gmail treat the address as one of the account's own")
+	host := fs.String("smtp-host", "", "send through this SMTP server instead of Gmail's")
+	port := fs.Int64("smtp-port", 587, "port of -smtp-host")
+	smtpUser := fs.String("smtp-user", "", "user name for -smtp-host")
+	passwordFile := fs.String("smtp-password-file", "", "file with the password for -smtp-user (default env GMAIL_SMTP_PASSWORD)")
+	security := fs.String("smtp-security", "starttls", "how to secure the connection to -smtp-host: "+strings.Join(smtpSecurityModes, ", "))
+	wait := fs.Duration("wait", 0, "wait this long for the address to be verified, e.g. 1h")
+	interval := fs.Duration("interval", 30*time.Second, "how often -wait checks the verification")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("settings sendas create: expected one address")
+	}
+	if !oneOf(smtpSecurityModes, *security) {
+		return fmt.Errorf("invalid -smtp-security %q (want one of %s)", *security, strings.Join(smtpSecurityModes, ", "))
+	}
+	s := &gmail.SendAs{
+		SendAsEmail:     fs.Arg(0),
+		DisplayName:     *name,
+		ReplyToAddress:  *replyTo,
+		IsDefault:       *isDefault,
+		TreatAsAlias:    *treatAsAlias,
+		ForceSendFields: []string{"TreatAsAlias"},
+	}
+	if *host != "" {
+		password := os.Getenv("GMAIL_SMTP_PASSWORD")
+		if *passwordFile != "" {
+			b, err := ioutil.ReadFile(*passwordFile)
+			if err != nil {
+				return fmt.Errorf("Unable to read SMTP password: %w", err)
+			}
+			password = strings.TrimRight(string(b), "\r\n")
+		}
+		s.SmtpMsa = &gmail.SmtpMsa{Host: *host, Port: *port, Username: *smtpUser, Password: password, SecurityMode: *security}
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	if s, err = gmailclient.CreateSendAs(ctx, a.api(), a.user, s); err != nil {
+		return fmt.Errorf("Unable to create send-as alias (is gmail.settings.sharing among -scopes?): %w", err)
+	}
+	if s.VerificationStatus != "pending" {
+		fmt.Printf("%s created.\n", s.SendAsEmail)
+		return nil
+	}
+	fmt.Printf("%s created; Gmail sent it a verification link.\n", s.SendAsEmail)
+	return waitSendAs(ctx, a, s.SendAsEmail, *wait, *interval)
+}
+
+// changeSendAs deletes a send-as alias of the current account, or mails it
+// a new verification link and, with -wait, waits for it to be followed.
+func changeSendAs(ctx context.Context, action string, fs *flag.FlagSet, args []string) error {
+	var wait, interval time.Duration
+	if action == "verify" {
+		fs.DurationVar(&wait, "wait", 0, "wait this long for the address to be verified, e.g. 1h")
+		fs.DurationVar(&interval, "interval", 30*time.Second, "how often -wait checks the verification")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("settings sendas %s: expected one address", action)
+	}
+	email := fs.Arg(0)
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	if action == "delete" {
+		if err := gmailclient.DeleteSendAs(ctx, a.api(), a.user, email); err != nil {
+			return fmt.Errorf("Unable to delete send-as alias (is gmail.settings.sharing among -scopes?): %w", err)
+		}
+		return nil
+	}
+	if err := gmailclient.VerifySendAs(ctx, a.api(), a.user, email); err != nil {
+		return fmt.Errorf("Unable to send verification link (is gmail.settings.sharing among -scopes?): %w", err)
+	}
+	fmt.Printf("Verification link sent to %s.\n", email)
+	return waitSendAs(ctx, a, email, wait, interval)
+}
+
+// waitSendAs waits up to wait for the send-as alias email of a to be
+// verified. It returns straight away if wait is 0.
+func waitSendAs(ctx context.Context, a *account, email string, wait, interval time.Duration) error {
+	if wait <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	fmt.Fprintf(os.Stderr, "Waiting for %s to be verified...\n", email)
+	s, err := gmailclient.WaitSendAsVerified(ctx, a.api(), a.user, email, interval)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s still not verified after %v", email, wait)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to check verification: %w", err)
+	}
+	if s.VerificationStatus != "accepted" {
+		return fmt.Errorf("%s verification %s", email, s.VerificationStatus)
+	}
+	fmt.Printf("%s verified.\n", email)
+	return nil
+}
+
+// signatureData are the values a -template signature is rendered with.
+// Data holds the -data entry of the address, if any.
+type signatureData struct {
+	Email string
+	Name  string
+	Data  map[string]interface{}
+}
+
+func runSignature(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("signature", args, "get", "set", "clear")
+	if err != nil {
+		return err
+	}
+	alias := fs.String("alias", "", "send-as address whose signature to use (default the primary address)")
+	var html, htmlFile, templateFile, dataFile string
+	var dryRun bool
+	if action == "set" {
+		fs.StringVar(&html, "html", "", "HTML signature")
+		fs.StringVar(&htmlFile, "html-file", "", "read the HTML signature from this file, - for stdin")
+		fs.StringVar(&templateFile, "template", "", "render the signature of each account from this html/template file, with {{.Email}}, {{.Name}} and {{.Data.key}}")
+		fs.StringVar(&dataFile, "data", "", "JSON file of -template values by address, as {\"ana@example.com\": {\"title\": \"CTO\"}}")
+		fs.BoolVar(&dryRun, "dry-run", false, "print the signatures without setting them")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("settings signature %s: unexpected arguments", action)
+	}
+
+	var tmpl *gmailclient.MessageTemplate
+	data := map[string]map[string]interface{}{}
+	if action == "set" {
+		if htmlFile != "" {
+			if html, err = readBodyFile(htmlFile); err != nil {
+				return err
+			}
+		}
+		switch {
+		case templateFile != "" && html != "":
+			return fmt.Errorf("-template cannot be used with -html or -html-file")
+		case templateFile != "":
+			if tmpl, err = gmailclient.ParseMessageTemplateFiles("", templateFile); err != nil {
+				return fmt.Errorf("Unable to parse signature template: %w", err)
+			}
+		case html == "":
+			return fmt.Errorf("settings signature set: expected -html, -html-file or -template")
+		}
+		if dataFile != "" {
+			if tmpl == nil {
+				return fmt.Errorf("-data needs -template")
+			}
+			b, err := ioutil.ReadFile(dataFile)
+			if err != nil {
+				return fmt.Errorf("Unable to read template data: %w", err)
+			}
+			var byAddress map[string]map[string]interface{}
+			if err := json.Unmarshal(b, &byAddress); err != nil {
+				return fmt.Errorf("Unable to parse template data %s: %w", dataFile, err)
+			}
+			for addr, d := range byAddress {
+				data[strings.ToLower(addr)] = d
+			}
+		}
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		aliases, err := gmailclient.ListSendAs(ctx, a.api(), a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to list send-as aliases: %w", err)
+		}
+		s, err := gmailclient.FindSendAs(aliases, *alias)
+		if err != nil {
+			return err
+		}
+		signature := html
+		switch action {
+		case "get":
+			if s.Signature != "" {
+				fmt.Println(strings.TrimRight(s.Signature, "\n"))
+			}
+			return nil
+		case "clear":
+			signature = ""
+		case "set":
+			if tmpl != nil {
+				if signature, err = renderSignature(tmpl, s, data); err != nil {
+					return err
+				}
+			}
+		}
+		if dryRun {
+			fmt.Printf("%s:\n%s\n", s.SendAsEmail, strings.TrimRight(signature, "\n"))
+			return nil
+		}
+		if err := gmailclient.SetSignature(ctx, a.api(), a.user, s.SendAsEmail, signature); err != nil {
+			return fmt.Errorf("Unable to set the signature of %s (is gmail.settings.basic among -scopes?): %w", s.SendAsEmail, err)
+		}
+		return nil
+	})
+}
+
+// renderSignature renders the signature of the send-as alias s from tmpl.
+func renderSignature(tmpl *gmailclient.MessageTemplate, s *gmail.SendAs, data map[string]map[string]interface{}) (string, error) {
+	d := signatureData{Email: s.SendAsEmail, Name: s.DisplayName, Data: data[strings.ToLower(s.SendAsEmail)]}
+	if d.Data == nil {
+		d.Data = map[string]interface{}{}
+	}
+	m, err := tmpl.Execute(d)
+	if err != nil {
+		return "", fmt.Errorf("Unable to render signature of %s: %w", s.SendAsEmail, err)
+	}
+	return m.HTML, nil
+}
@@ -0,0 +1,145 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+)
+
+// authOptions describes how to authorize access to one mailbox.
+type authOptions struct {
+	account        string
+	credentials    string
+	token          string
+	tokenStore     string
+	serviceAccount string
+	adc            bool
+	user           string
+	scopes         []string
+}
+
+// globalAuthOptions returns the options set by the global flags and the
+// selected profile.
+func globalAuthOptions() authOptions {
+	return authOptions{
+		account:        profile.Name,
+		credentials:    credentialsFile,
+		token:          tokenFile,
+		tokenStore:     tokenStoreKind,
+		serviceAccount: serviceAccountFile,
+		adc:            useADC,
+		user:           user,
+		scopes:         scopes,
+	}
+}
+
+// forProfile returns o with the settings p defines replacing its own. A
+// profile without a token setting uses its default token file, except for the
+// selected profile, whose token applyProfile has already resolved against
+// -token.
+func (o authOptions) forProfile(p *config.Profile) (authOptions, error) {
+	o.account = p.Name
+	if p.Credentials != "" {
+		o.credentials = p.Credentials
+	}
+	if p.Token != "" || p.Name != profile.Name {
+		tok, err := p.TokenFile()
+		if err != nil {
+			return o, err
+		}
+		o.token = tok
+	}
+	if p.TokenStore != "" {
+		o.tokenStore = p.TokenStore
+	}
+	if p.ServiceAccount != "" {
+		o.serviceAccount = p.ServiceAccount
+	}
+	if p.ADC {
+		o.adc = true
+	}
+	if p.User != "" {
+		o.user = p.User
+	}
+	if len(p.Scopes) > 0 {
+		o.scopes = gmailclient.ExpandScopes(joinScopes(p.Scopes))
+	}
+	return o, nil
+}
+
+// openClient returns an HTTP client authorized for o whose calls are
+// throttled to -quota and retried according to -retries. Accounts build their
+// Gmail service on it and use it for the other Google APIs.
+func openClient(ctx context.Context, o authOptions) (*http.Client, error) {
+	client, err := authClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	if quota > 0 {
+		client = gmailclient.WithRateLimit(client, quota)
+	}
+	return gmailclient.WithRetry(client, retryPolicy()), nil
+}
+
+// authClient returns an HTTP client authorized for o, either impersonating
+// o.user with a service account, with Application Default Credentials or with
+// the user's own OAuth token.
+func authClient(ctx context.Context, o authOptions) (*http.Client, error) {
+	if o.adc {
+		subject := o.user
+		if subject == "me" {
+			subject = ""
+		}
+		return gmailclient.DefaultClient(ctx, subject, o.scopes...)
+	}
+	if o.serviceAccount != "" {
+		if o.user == "" || o.user == "me" {
+			return nil, fmt.Errorf("a service account needs -user set to the address to impersonate")
+		}
+		return gmailclient.ServiceAccountClient(ctx, o.serviceAccount, o.user, o.scopes...)
+	}
+	store, err := newTokenStore(o.tokenStore, o.account, o.token)
+	if err != nil {
+		return nil, err
+	}
+	flow, err := authFlow()
+	if err != nil {
+		return nil, err
+	}
+	config, err := gmailclient.ConfigFromFile(o.credentials, o.scopes...)
+	if err != nil {
+		return nil, err
+	}
+	return gmailclient.GetClient(ctx, config, store, gmailclient.AuthOptions{
+		Flow:   flow,
+		Notify: func(msg string) { fmt.Fprintln(os.Stderr, msg) },
+	})
+}
+
+// retryPolicy returns the retry policy set with -retries.
+func retryPolicy() gmailclient.RetryPolicy {
+	p := gmailclient.DefaultRetryPolicy
+	p.MaxRetries = retries
+	return p
+}
@@ -0,0 +1,252 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name:    "settings",
+		usage:   "vacation|sendas|signature|smime|forwarding|imap|pop|delegates|language <action> [flags] | export [-file f] | import [-file f] [-delete-filters] [-dry-run]",
+		summary: "Show and change mailbox settings (changes need the gmail.settings.basic scope, forwarding gmail.settings.sharing).",
+		run:     runSettings,
+	})
+}
+
+// settingsSections are the settings subcommands. Each gets the arguments
+// after its name, starting with its own action.
+var settingsSections = map[string]func(ctx context.Context, args []string) error{
+	"vacation":   runVacation,
+	"forwarding": runForwarding,
+	"delegates":  runDelegates,
+	"language":   runLanguage,
+	"smime":      runSmimeInfo,
+	"export":     runSettingsExport,
+	"import":     runSettingsImport,
+	"imap":       runImap,
+	"pop":        runPop,
+	"sendas":     runSendAs,
+	"signature":  runSignature,
+}
+
+func runSettings(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["settings"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("settings: expected one of %s", strings.Join(settingsNames(), ", "))
+	}
+	run, ok := settingsSections[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("settings: unknown section %q", fs.Arg(0))
+	}
+	return run(ctx, fs.Args()[1:])
+}
+
+// settingsNames returns the settings sections in order.
+func settingsNames() []string {
+	var names []string
+	for name := range settingsSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// settingsAction splits the action off the arguments of a settings
+// section, checking it is one of actions, and returns a flag set for it.
+func settingsAction(section string, args []string, actions ...string) (string, []string, *flag.FlagSet, error) {
+	fs := newFlagSet(commands["settings"])
+	if len(args) > 0 {
+		for _, a := range actions {
+			if args[0] == a {
+				return a, args[1:], fs, nil
+			}
+		}
+	}
+	fs.Usage()
+	return "", nil, nil, fmt.Errorf("settings %s: expected %s", section, strings.Join(actions, ", "))
+}
+
+// vacationDateLayout is the date-only format -start and -end take besides
+// those of send -at.
+const vacationDateLayout = "2006-01-02"
+
+// parseVacationTime parses -start or -end. A date alone is the start of the
+// day, or with end set, the end of it.
+func parseVacationTime(s string, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(vacationDateLayout, s, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return parseSendTime(s)
+}
+
+func runVacation(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("vacation", args, "get", "set", "clear", "calendar")
+	if err != nil {
+		return err
+	}
+	if action == "calendar" {
+		return runVacationCalendar(ctx, fs, args)
+	}
+	var output *string
+	var subject, body, bodyFile, html, htmlFile, start, end string
+	var contactsOnly, domainOnly bool
+	switch action {
+	case "get":
+		output = outputFlag(fs)
+	case "set":
+		fs.StringVar(&subject, "subject", "", "subject of the automatic reply")
+		fs.StringVar(&body, "body", "", "plain-text body of the reply")
+		fs.StringVar(&bodyFile, "body-file", "", "read the plain-text body from this file, - for stdin")
+		fs.StringVar(&html, "html", "", "HTML body of the reply; replaces -body")
+		fs.StringVar(&htmlFile, "html-file", "", "read the HTML body from this file, - for stdin")
+		fs.StringVar(&start, "start", "", "first day or time to reply, e.g. 2024-07-01 or 2024-07-01T09:00 (default now)")
+		fs.StringVar(&end, "end", "", "last day or time to reply, e.g. 2024-07-14 (default until cleared)")
+		fs.BoolVar(&contactsOnly, "contacts-only", false, "only reply to people in the contacts")
+		fs.BoolVar(&domainOnly, "domain-only", false, "only reply to people in the same Google Workspace domain")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("settings vacation %s: unexpected arguments", action)
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	v, err := gmailclient.GetVacation(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve vacation settings: %w", err)
+	}
+	switch action {
+	case "get":
+		if err := checkOutput(*output); err != nil {
+			return err
+		}
+		return printVacation(v, *output)
+	case "clear":
+		v.EnableAutoReply = false
+		v.ForceSendFields = []string{"EnableAutoReply"}
+	case "set":
+		if bodyFile != "" {
+			if body, err = readBodyFile(bodyFile); err != nil {
+				return err
+			}
+		}
+		if htmlFile != "" {
+			if html, err = readBodyFile(htmlFile); err != nil {
+				return err
+			}
+		}
+		if body == "" && html == "" {
+			return fmt.Errorf("settings vacation set: expected -body or -html")
+		}
+		var from, until time.Time
+		if start != "" {
+			if from, err = parseVacationTime(start, false); err != nil {
+				return err
+			}
+		}
+		if end != "" {
+			if until, err = parseVacationTime(end, true); err != nil {
+				return err
+			}
+			if !from.IsZero() && !until.After(from) {
+				return fmt.Errorf("-end %s is not after -start %s", end, start)
+			}
+		}
+		v = &gmail.VacationSettings{
+			EnableAutoReply:       true,
+			ResponseSubject:       subject,
+			ResponseBodyPlainText: body,
+			ResponseBodyHtml:      html,
+			RestrictToContacts:    contactsOnly,
+			RestrictToDomain:      domainOnly,
+			StartTime:             gmailclient.VacationTime(from),
+			EndTime:               gmailclient.VacationTime(until),
+		}
+	}
+	if _, err := gmailclient.UpdateVacation(ctx, a.api(), a.user, v); err != nil {
+		return fmt.Errorf("Unable to update vacation settings (is gmail.settings.basic among -scopes?): %w", err)
+	}
+	return nil
+}
+
+// printVacation prints the vacation responder settings v.
+func printVacation(v *gmail.VacationSettings, output string) error {
+	if output != "text" {
+		j := newJSONWriter(os.Stdout, output)
+		if err := j.write(v); err != nil {
+			return err
+		}
+		return j.close()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Enabled:\t%s\n", yesNo(v.EnableAutoReply))
+	fmt.Fprintf(w, "Start:\t%s\n", vacationTimeString(v.StartTime, "now"))
+	fmt.Fprintf(w, "End:\t%s\n", vacationTimeString(v.EndTime, "until cleared"))
+	fmt.Fprintf(w, "Contacts only:\t%s\n", yesNo(v.RestrictToContacts))
+	fmt.Fprintf(w, "Domain only:\t%s\n", yesNo(v.RestrictToDomain))
+	fmt.Fprintf(w, "Subject:\t%s\n", v.ResponseSubject)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	body := v.ResponseBodyPlainText
+	if v.ResponseBodyHtml != "" {
+		body = v.ResponseBodyHtml
+	}
+	if body != "" {
+		fmt.Printf("\n%s\n", strings.TrimRight(body, "\n"))
+	}
+	return nil
+}
+
+// vacationTimeString formats a vacation start or end time, or returns none
+// if it is not set.
+func vacationTimeString(ms int64, none string) string {
+	t := gmailclient.VacationTimeOf(ms)
+	if t.IsZero() {
+		return none
+	}
+	return t.Local().Format("2006-01-02 15:04 MST")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
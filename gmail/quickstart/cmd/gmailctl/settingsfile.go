@@ -0,0 +1,333 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"google.golang.org/api/gmail/v1"
+)
+
+func runSettingsExport(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["settings"])
+	file := fs.String("file", "", "write the settings to this file instead of stdout")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("settings export: unexpected arguments")
+	}
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := mailboxSettings(ctx, a)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		return gmailclient.WriteMailboxSettings(os.Stdout, s)
+	}
+	var buf bytes.Buffer
+	if err := gmailclient.WriteMailboxSettings(&buf, s); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(*file, buf.Bytes(), 0644)
+}
+
+// mailboxSettings reads the labels, filters, forwarding, vacation, send-as
+// and language settings of a.
+func mailboxSettings(ctx context.Context, a *account) (*gmailclient.MailboxSettings, error) {
+	s := &gmailclient.MailboxSettings{}
+	specs, _, labels, err := filterSpecs(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range labels {
+		if l.Type == "user" {
+			s.Labels = append(s.Labels, gmailclient.NewLabelSpec(l))
+		}
+	}
+	for _, f := range specs {
+		// Filter IDs only mean something in the mailbox they come from.
+		f.ID = ""
+	}
+	s.Filters = specs
+
+	addrs, err := gmailclient.ListForwardingAddresses(ctx, a.api(), a.user)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list forwarding addresses: %w", err)
+	}
+	f, err := gmailclient.GetAutoForwarding(ctx, a.api(), a.user)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve forwarding settings: %w", err)
+	}
+	s.Forwarding = &gmailclient.ForwardingSpec{Enabled: f.Enabled, To: f.EmailAddress, Disposition: f.Disposition}
+	for _, addr := range addrs {
+		s.Forwarding.Addresses = append(s.Forwarding.Addresses, addr.ForwardingEmail)
+	}
+
+	v, err := gmailclient.GetVacation(ctx, a.api(), a.user)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve vacation settings: %w", err)
+	}
+	s.Vacation = gmailclient.NewVacationSpec(v)
+
+	aliases, err := gmailclient.ListSendAs(ctx, a.api(), a.user)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list send-as aliases: %w", err)
+	}
+	for _, alias := range aliases {
+		s.SendAs = append(s.SendAs, gmailclient.NewSendAsSpec(alias))
+	}
+
+	if s.Language, err = gmailclient.GetLanguage(ctx, a.api(), a.user); err != nil {
+		return nil, fmt.Errorf("Unable to retrieve language settings: %w", err)
+	}
+	return s, nil
+}
+
+func runSettingsImport(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["settings"])
+	file := fs.String("file", "", "read the settings from this file instead of stdin")
+	prune := fs.Bool("delete-filters", false, "also delete the filters missing from the file")
+	dryRun := fs.Bool("dry-run", false, "only print the changes that would be made")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("settings import: unexpected arguments")
+	}
+	var r io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("Unable to read settings: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	s, err := gmailclient.ReadMailboxSettings(r)
+	if err != nil {
+		return fmt.Errorf("Unable to read settings: %w", err)
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		return applySettings(ctx, a, s, *prune, *dryRun)
+	})
+}
+
+// applySettings makes the settings of a match s, printing each change. It
+// creates and updates labels and send-as addresses but never deletes them;
+// filters are deleted only with prune.
+func applySettings(ctx context.Context, a *account, s *gmailclient.MailboxSettings, prune, dryRun bool) error {
+	if err := applyLabels(ctx, a, s.Labels, dryRun); err != nil {
+		return err
+	}
+	if s.Filters != nil || prune {
+		if err := applyFilters(ctx, a, s.Filters, prune, true, dryRun); err != nil {
+			return err
+		}
+	}
+	if s.Forwarding != nil {
+		if err := applyForwarding(ctx, a, s.Forwarding, dryRun); err != nil {
+			return err
+		}
+	}
+	if s.Vacation != nil {
+		v, err := gmailclient.GetVacation(ctx, a.api(), a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve vacation settings: %w", err)
+		}
+		if !sameJSON(gmailclient.NewVacationSpec(v).Settings(), s.Vacation.Settings()) {
+			fmt.Printf("update\tvacation\tenabled: %s\n", yesNo(s.Vacation.Enabled))
+			if !dryRun {
+				if _, err := gmailclient.UpdateVacation(ctx, a.api(), a.user, s.Vacation.Settings()); err != nil {
+					return fmt.Errorf("Unable to update vacation settings (is gmail.settings.basic among -scopes?): %w", err)
+				}
+			}
+		}
+	}
+	if s.Language != "" {
+		cur, err := gmailclient.GetLanguage(ctx, a.api(), a.user)
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve language settings: %w", err)
+		}
+		if cur != s.Language {
+			fmt.Printf("update\tlanguage\t%s\n", s.Language)
+			if !dryRun {
+				if _, err := gmailclient.UpdateLanguage(ctx, a.api(), a.user, s.Language); err != nil {
+					return fmt.Errorf("Unable to update language settings (is gmail.settings.basic among -scopes?): %w", err)
+				}
+			}
+		}
+	}
+	return applySendAs(ctx, a, s.SendAs, dryRun)
+}
+
+// applyLabels creates the labels of specs missing from a and updates the
+// colors and visibility of those that differ.
+func applyLabels(ctx context.Context, a *account, specs []*gmailclient.LabelSpec, dryRun bool) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to list labels: %w", err)
+	}
+	for _, spec := range specs {
+		l, err := gmailclient.FindLabel(labels, spec.Name)
+		if err != nil {
+			fmt.Printf("create\tlabel\t%s\n", spec.Name)
+			if dryRun {
+				continue
+			}
+			if _, err := gmailclient.CreateLabel(ctx, a.api(), a.user, spec.Label(), true); err != nil {
+				return fmt.Errorf("Unable to create label %s (is gmail.labels among -scopes?): %w", spec.Name, err)
+			}
+			continue
+		}
+		have := gmailclient.NewLabelSpec(l)
+		have.Name = spec.Name
+		if *have == *spec {
+			continue
+		}
+		fmt.Printf("update\tlabel\t%s\n", l.Name)
+		if dryRun {
+			continue
+		}
+		change := spec.Label()
+		change.Name = ""
+		if _, err := gmailclient.UpdateLabel(ctx, a.api(), a.user, l.Id, change); err != nil {
+			return fmt.Errorf("Unable to update label %s (is gmail.labels among -scopes?): %w", l.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyForwarding adds the forwarding addresses of f missing from a, then
+// sets automatic forwarding as f describes.
+func applyForwarding(ctx context.Context, a *account, f *gmailclient.ForwardingSpec, dryRun bool) error {
+	addrs, err := gmailclient.ListForwardingAddresses(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to list forwarding addresses: %w", err)
+	}
+	have := map[string]bool{}
+	for _, addr := range addrs {
+		have[strings.ToLower(addr.ForwardingEmail)] = true
+	}
+	for _, email := range f.Addresses {
+		if have[strings.ToLower(email)] {
+			continue
+		}
+		fmt.Printf("create\tforwarding address\t%s\n", email)
+		if dryRun {
+			continue
+		}
+		if _, err := gmailclient.CreateForwardingAddress(ctx, a.api(), a.user, email); err != nil {
+			return fmt.Errorf("Unable to add forwarding address %s (is gmail.settings.sharing among -scopes?): %w", email, err)
+		}
+	}
+
+	cur, err := gmailclient.GetAutoForwarding(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve forwarding settings: %w", err)
+	}
+	want := &gmail.AutoForwarding{Enabled: false, ForceSendFields: []string{"Enabled"}}
+	if f.Enabled {
+		want = &gmail.AutoForwarding{Enabled: true, EmailAddress: f.To, Disposition: f.Disposition}
+		if cur.Enabled && strings.EqualFold(cur.EmailAddress, f.To) && cur.Disposition == f.Disposition {
+			return nil
+		}
+		fmt.Printf("update\tforwarding\tto %s, %s\n", f.To, f.Disposition)
+	} else {
+		if !cur.Enabled {
+			return nil
+		}
+		fmt.Printf("update\tforwarding\toff\n")
+	}
+	if dryRun {
+		return nil
+	}
+	if _, err := gmailclient.UpdateAutoForwarding(ctx, a.api(), a.user, want); err != nil {
+		return fmt.Errorf("Unable to update forwarding settings (is gmail.settings.sharing among -scopes?): %w", err)
+	}
+	return nil
+}
+
+// applySendAs creates the send-as addresses of specs missing from a and
+// updates those that differ.
+func applySendAs(ctx context.Context, a *account, specs []*gmailclient.SendAsSpec, dryRun bool) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	aliases, err := gmailclient.ListSendAs(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to list send-as aliases: %w", err)
+	}
+	for _, spec := range specs {
+		cur, err := gmailclient.FindSendAs(aliases, spec.Email)
+		if err != nil {
+			fmt.Printf("create\tsend-as\t%s\n", spec.Email)
+			if dryRun {
+				continue
+			}
+			if _, err := gmailclient.CreateSendAs(ctx, a.api(), a.user, spec.SendAs()); err != nil {
+				return fmt.Errorf("Unable to create send-as alias %s (is gmail.settings.sharing among -scopes?): %w", spec.Email, err)
+			}
+			continue
+		}
+		have := gmailclient.NewSendAsSpec(cur)
+		have.Email = spec.Email
+		if cur.IsPrimary {
+			// Gmail always treats the primary address as the account's own.
+			have.TreatAsAlias = spec.TreatAsAlias
+		}
+		if *have == *spec {
+			continue
+		}
+		fmt.Printf("update\tsend-as\t%s\n", cur.SendAsEmail)
+		if dryRun {
+			continue
+		}
+		if _, err := gmailclient.UpdateSendAs(ctx, a.api(), a.user, cur.SendAsEmail, spec.SendAs()); err != nil {
+			return fmt.Errorf("Unable to update send-as alias %s (is gmail.settings.basic among -scopes?): %w", cur.SendAsEmail, err)
+		}
+	}
+	return nil
+}
+
+// sameJSON reports whether a and b encode to the same JSON.
+func sameJSON(a, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	return err == nil && bytes.Equal(x, y)
+}
@@ -0,0 +1,308 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/objstore"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"google.golang.org/api/gmail/v1"
+)
+
+// An attachmentSink stores the attachments attachments download fetches.
+// save may be called from several goroutines at once.
+type attachmentSink interface {
+	// save stores att of m under name, with the data write produces, and
+	// returns where it went, for printing.
+	save(ctx context.Context, m *gmail.Message, att *gmailclient.Attachment, name string, write func(io.Writer) error) (string, error)
+
+	// close finishes the download. It may be called more than once.
+	close() error
+}
+
+// newAttachmentSink returns the sink for o: the -to URL if set, otherwise
+// -dir, deduplicated with -dedup.
+func newAttachmentSink(ctx context.Context, a *account, o attachmentsOptions) (attachmentSink, error) {
+	if o.to == "" {
+		if o.dedup {
+			return openDedupSink(o.dir)
+		}
+		return &localSink{dir: o.dir}, nil
+	}
+	u, err := url.Parse(o.to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -to %q: %w", o.to, err)
+	}
+	switch u.Scheme {
+	case "drive":
+		return openDriveSink(ctx, a, u.Host+u.Path)
+	case "s3", "gs":
+		b, prefix, err := openBucket(ctx, o.to)
+		if err != nil {
+			return nil, err
+		}
+		return &bucketSink{b: b, url: o.to, prefix: prefix}, nil
+	}
+	return nil, fmt.Errorf("invalid -to %q (want drive://folder, s3://bucket/prefix or gs://bucket/prefix)", o.to)
+}
+
+// localSink saves attachments to <dir>/<message id>/<name>.
+type localSink struct {
+	dir string
+}
+
+func (s *localSink) save(ctx context.Context, m *gmail.Message, att *gmailclient.Attachment, name string, write func(io.Writer) error) (string, error) {
+	dir := filepath.Join(s.dir, m.Id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	return path, saveAttachment(path, write)
+}
+
+func (s *localSink) close() error { return nil }
+
+// saveAttachment streams the data write produces to path, which only
+// appears once the download is complete.
+func saveAttachment(path string, write func(io.Writer) error) error {
+	f, err := atomicfile.Create(path, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if err := write(f); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// manifestFile is the name of the -dedup manifest in the output directory.
+const manifestFile = "manifest.tsv"
+
+// dedupSink saves attachments once per distinct content in a BlobStore,
+// hard-linked to <dir>/<message id>/<name>, and records every attachment in
+// a tab-separated manifest: message ID, filename, path, SHA-256 and size.
+// The path is that of the hard link, or empty if the link could not be made.
+type dedupSink struct {
+	dir   string
+	blobs *gmailclient.BlobStore
+
+	mu sync.Mutex // guards f and w
+	f  *os.File
+	w  *csv.Writer
+}
+
+func openDedupSink(dir string) (*dedupSink, error) {
+	blobs, err := gmailclient.OpenBlobStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, manifestFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d := &dedupSink{dir: dir, blobs: blobs, f: f, w: csv.NewWriter(f)}
+	d.w.Comma = '\t'
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		d.w.Write([]string{"message_id", "filename", "path", "sha256", "size"})
+		d.w.Flush()
+	}
+	return d, nil
+}
+
+func (d *dedupSink) save(ctx context.Context, m *gmail.Message, att *gmailclient.Attachment, name string, write func(io.Writer) error) (string, error) {
+	sum, size, isNew, err := d.blobs.Store(write)
+	if err != nil {
+		return "", err
+	}
+	where := d.blobs.BlobPath(sum)
+	link := ""
+	dir := filepath.Join(d.dir, m.Id)
+	if err := os.MkdirAll(dir, 0755); err == nil {
+		path := filepath.Join(dir, name)
+		if err := d.blobs.Link(sum, path); err == nil {
+			where = path
+			link = filepath.Join(m.Id, name)
+		}
+	}
+	if !isNew {
+		where += " (duplicate)"
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.w.Write([]string{m.Id, att.Filename, link, sum, strconv.FormatInt(size, 10)})
+	d.w.Flush()
+	return where, d.w.Error()
+}
+
+func (d *dedupSink) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.f == nil {
+		return nil
+	}
+	err := d.f.Close()
+	d.f = nil
+	return err
+}
+
+// driveSink uploads attachments to a Drive folder, into subfolders by sender
+// address and date: Folder/hi@vimtricks.com/2021-05-03/invoice.pdf.
+type driveSink struct {
+	folder *gmailclient.DriveFolder
+	path   string
+}
+
+func openDriveSink(ctx context.Context, a *account, path string) (*driveSink, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, fmt.Errorf("-to drive:// needs a folder, e.g. drive://Mail/Attachments")
+	}
+	folder, err := gmailclient.OpenDriveFolder(ctx, a.http, path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open Drive folder %s (is %s among -scopes?): %w", path, gmailclient.DriveScope, err)
+	}
+	return &driveSink{folder: folder, path: path}, nil
+}
+
+func (s *driveSink) save(ctx context.Context, m *gmail.Message, att *gmailclient.Attachment, name string, write func(io.Writer) error) (string, error) {
+	sender, date := "unknown sender", "unknown date"
+	if parsed, err := gmailclient.ParseMetadata(m); err == nil {
+		if len(parsed.From) > 0 {
+			sender = strings.ToLower(parsed.From[0].Address)
+		}
+		if !parsed.Date.IsZero() {
+			date = parsed.Date.UTC().Format("2006-01-02")
+		}
+	}
+
+	// Stream the decoded attachment into the upload.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw))
+	}()
+	_, err := s.folder.Upload(ctx, []string{sender, date}, name, att.MimeType, pr)
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("drive://%s/%s/%s/%s", s.path, sender, date, name), nil
+}
+
+func (s *driveSink) close() error { return nil }
+
+// openBucket opens the bucket of an s3:// or gs:// URL. Cloud Storage is
+// reached with Application Default Credentials, like BigQuery, and S3 with
+// the AWS_* environment variables.
+func openBucket(ctx context.Context, rawurl string) (objstore.Bucket, string, error) {
+	var client *http.Client
+	if strings.HasPrefix(rawurl, "gs://") {
+		c, err := gmailclient.DefaultClient(ctx, "", objstore.GCSScope)
+		if err != nil {
+			return nil, "", err
+		}
+		client = gmailclient.WithRetry(c, retryPolicy())
+	}
+	return objstore.Open(ctx, rawurl, client)
+}
+
+// bucketSink uploads attachments to <prefix><message id>/<name> in a bucket.
+// Objects that already exist with the attachment's size are skipped, so an
+// interrupted download can simply be run again.
+type bucketSink struct {
+	b      objstore.Bucket
+	url    string
+	prefix string
+}
+
+func (s *bucketSink) save(ctx context.Context, m *gmail.Message, att *gmailclient.Attachment, name string, write func(io.Writer) error) (string, error) {
+	key := s.prefix + m.Id + "/" + name
+	where := strings.TrimSuffix(s.url, "/") + "/" + m.Id + "/" + name
+	if size, ok, err := s.b.Size(ctx, key); err != nil {
+		return "", err
+	} else if ok && size == att.Size {
+		return where + " (exists)", nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw))
+	}()
+	err := s.b.Put(ctx, key, att.MimeType, pr)
+	pr.CloseWithError(io.ErrClosedPipe)
+	return where, err
+}
+
+func (s *bucketSink) close() error { return nil }
+
+// uploadDir copies the files under dir to the bucket of the s3:// or gs://
+// URL to, keeping their relative paths. Files whose object already exists
+// with the same size are skipped, so an interrupted upload resumes where it
+// stopped. Export checkpoints and temporary files are left out.
+func uploadDir(ctx context.Context, dir, to string) error {
+	b, prefix, err := openBucket(ctx, to)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		if fi.Name() == checkpointFile || strings.Contains(fi.Name(), ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		key := prefix + filepath.ToSlash(rel)
+		if size, ok, err := b.Size(ctx, key); err != nil {
+			return err
+		} else if ok && size == fi.Size() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if err := b.Put(ctx, key, contentType, f); err != nil {
+			return fmt.Errorf("Unable to upload %s: %w", rel, err)
+		}
+		fmt.Println(strings.TrimSuffix(to, "/") + "/" + filepath.ToSlash(rel))
+		return nil
+	})
+}
@@ -0,0 +1,141 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	drive "google.golang.org/api/drive/v3"
+	"google.golang.org/api/gmail/v1"
+)
+
+// driveAccount returns an account whose HTTP client reaches a Drive server
+// that has no folders yet and records the folders and files created, by
+// name, as "parent/name". With failUpload set, uploads fail.
+func driveAccount(t *testing.T, created *[]string, failUpload bool) *account {
+	names := map[string]string{"root": ""}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file := &drive.File{}
+		switch {
+		case r.Method == "GET":
+			json.NewEncoder(w).Encode(&drive.FileList{})
+			return
+		case r.URL.Path == "/drive/v3/files":
+			json.NewDecoder(r.Body).Decode(file)
+		case failUpload:
+			http.Error(w, `{"error": {"code": 403, "message": "storage quota exceeded"}}`, http.StatusForbidden)
+			return
+		case r.URL.Query().Get("uploadType") == "resumable":
+			// The client library starts a resumable upload when reading
+			// the first chunk fails, and then gives up with the error.
+			w.Header().Set("Location", "https://www.googleapis.com/upload/session")
+			return
+		default:
+			_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			mr := multipart.NewReader(r.Body, params["boundary"])
+			p, _ := mr.NextPart()
+			json.NewDecoder(p).Decode(file)
+			p, _ = mr.NextPart()
+			b, _ := ioutil.ReadAll(p)
+			file.Name += "=" + string(b)
+		}
+		id := fmt.Sprint(len(names))
+		names[id] = names[file.Parents[0]] + "/" + file.Name
+		*created = append(*created, names[id])
+		json.NewEncoder(w).Encode(&drive.File{Id: id})
+	}))
+	t.Cleanup(ts.Close)
+	u, _ := url.Parse(ts.URL)
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host = u.Scheme, u.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	return &account{name: "test", user: "me", http: client}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestDriveSink(t *testing.T) {
+	ctx := context.Background()
+	m := &gmail.Message{Id: "m1", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+		{Name: "From", Value: "Bo <Bo@Example.com>"},
+		{Name: "Date", Value: "Mon, 3 May 2021 23:30:00 -0700"},
+	}}}
+	att := &gmailclient.Attachment{Filename: "a.pdf", MimeType: "application/pdf"}
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "%PDF")
+		return err
+	}
+
+	var created []string
+	s, err := openDriveSink(ctx, driveAccount(t, &created, false), "/Mail/Attachments/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	where, err := s.save(ctx, m, att, "a.pdf", write)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "drive://Mail/Attachments/bo@example.com/2021-05-04/a.pdf"; where != want {
+		t.Errorf("save = %q, want %q", where, want)
+	}
+	want := []string{"/Mail", "/Mail/Attachments", "/Mail/Attachments/bo@example.com",
+		"/Mail/Attachments/bo@example.com/2021-05-04", "/Mail/Attachments/bo@example.com/2021-05-04/a.pdf=%PDF"}
+	if got := strings.Join(created, " "); got != strings.Join(want, " ") {
+		t.Errorf("created %s\nwant %s", got, strings.Join(want, " "))
+	}
+
+	// The upload fails, and so does the attachment that could not be written.
+	created = nil
+	s, err = openDriveSink(ctx, driveAccount(t, &created, true), "Mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.save(ctx, m, att, "a.pdf", write); err == nil || !strings.Contains(err.Error(), "storage quota exceeded") {
+		t.Errorf("save with a failing upload: %v", err)
+	}
+	created = nil
+	s, err = openDriveSink(ctx, driveAccount(t, &created, false), "Mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := func(w io.Writer) error { return errors.New("bad base64") }
+	if _, err := s.save(ctx, m, att, "a.pdf", broken); err == nil || !strings.Contains(err.Error(), "bad base64") {
+		t.Errorf("save with a failing download: %v", err)
+	}
+
+	if _, err := openDriveSink(ctx, driveAccount(t, &created, false), "/"); err == nil {
+		t.Error("openDriveSink accepted an empty folder")
+	}
+}
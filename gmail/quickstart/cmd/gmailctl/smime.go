@@ -0,0 +1,68 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/crypto/pkcs12"
+	"golang.org/x/term"
+)
+
+// smimePasswordEnv is the environment variable holding the password of the
+// -smime-cert PKCS#12 file.
+const smimePasswordEnv = "GMAIL_SMIME_PASSWORD"
+
+// loadSMIME reads the -smime-cert identity, asking for the password of a
+// PKCS#12 file unless it is in $GMAIL_SMIME_PASSWORD or empty.
+func (c *composeFlags) loadSMIME() (*gmailclient.SMIME, error) {
+	if c.smimeCert == "" {
+		return nil, fmt.Errorf("-smime needs a certificate in -smime-cert")
+	}
+	id, _, err := loadSMIMEFile(c.smimeCert, "-smime-cert")
+	return id, err
+}
+
+// loadSMIMEFile reads the S/MIME identity in path, named by flag in errors,
+// asking for the password of a PKCS#12 file unless it is in
+// $GMAIL_SMIME_PASSWORD or empty. It also returns the password.
+func loadSMIMEFile(path, flag string) (*gmailclient.SMIME, string, error) {
+	password := os.Getenv(smimePasswordEnv)
+	id, err := gmailclient.LoadSMIME(path, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) && password == "" {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return nil, "", fmt.Errorf("no terminal to read the %s password from; set $%s", flag, smimePasswordEnv)
+		}
+		fmt.Fprintf(os.Stderr, "Password for %s: ", path)
+		b, rerr := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if rerr != nil {
+			return nil, "", rerr
+		}
+		password = string(b)
+		id, err = gmailclient.LoadSMIME(path, password)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to load %s: %w", flag, err)
+	}
+	return id, password, nil
+}
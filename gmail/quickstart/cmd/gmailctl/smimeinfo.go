@@ -0,0 +1,153 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func runSmimeInfo(ctx context.Context, args []string) error {
+	action, args, fs, err := settingsAction("smime", args, "list", "insert", "default", "delete")
+	if err != nil {
+		return err
+	}
+	alias := fs.String("alias", "", "send-as address whose certificates to manage (default the primary address)")
+	var output *string
+	var makeDefault bool
+	switch action {
+	case "list":
+		output = outputFlag(fs)
+	case "insert":
+		fs.BoolVar(&makeDefault, "default", false, "sign the address's mail with the new certificate")
+	}
+	fs.Parse(args)
+	switch {
+	case action == "list" && fs.NArg() != 0:
+		fs.Usage()
+		return fmt.Errorf("settings smime list: unexpected arguments")
+	case action == "insert" && fs.NArg() != 1:
+		fs.Usage()
+		return fmt.Errorf("settings smime insert: expected one PKCS#12 file")
+	case action != "list" && action != "insert" && fs.NArg() != 1:
+		fs.Usage()
+		return fmt.Errorf("settings smime %s: expected one certificate ID", action)
+	}
+	if output != nil {
+		if err := checkOutput(*output); err != nil {
+			return err
+		}
+	}
+	var p12 []byte
+	var password string
+	if action == "insert" {
+		path := fs.Arg(0)
+		if p12, err = ioutil.ReadFile(path); err != nil {
+			return fmt.Errorf("Unable to read certificate: %w", err)
+		}
+		if bytes.Contains(p12, []byte("-----BEGIN ")) {
+			return fmt.Errorf("%s is PEM; Gmail only takes PKCS#12 (.p12 or .pfx) files", path)
+		}
+		// Check the file and its password here rather than with a less
+		// helpful error from the API.
+		if _, password, err = loadSMIMEFile(path, path); err != nil {
+			return err
+		}
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	out := "text"
+	if output != nil {
+		out = *output
+	}
+	return smimeAction(ctx, a.api(), a.user, *alias, action, fs.Arg(0), p12, password, makeDefault, out)
+}
+
+// smimeService is what settings smime needs of a mailbox.
+type smimeService interface {
+	gmailclient.SendAsService
+	gmailclient.SmimeService
+}
+
+// smimeAction runs settings smime action on the certificates of the send-as
+// address alias of user, the primary one if alias is empty. id is the
+// certificate to make the default or delete; insert uploads p12 instead.
+func smimeAction(ctx context.Context, srv smimeService, user, alias, action, id string, p12 []byte, password string, makeDefault bool, output string) error {
+	aliases, err := gmailclient.ListSendAs(ctx, srv, user)
+	if err != nil {
+		return fmt.Errorf("Unable to list send-as aliases: %w", err)
+	}
+	s, err := gmailclient.FindSendAs(aliases, alias)
+	if err != nil {
+		return err
+	}
+	email := s.SendAsEmail
+	switch action {
+	case "list":
+		infos, err := gmailclient.ListSmimeInfo(ctx, srv, user, email)
+		if err != nil {
+			return fmt.Errorf("Unable to list S/MIME certificates: %w", err)
+		}
+		if output != "text" {
+			j := newJSONWriter(os.Stdout, output)
+			for _, info := range infos {
+				if err := j.write(info); err != nil {
+					return err
+				}
+			}
+			return j.close()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDEFAULT\tISSUER\tEXPIRES")
+		for _, info := range infos {
+			expires := time.Unix(0, info.Expiration*int64(time.Millisecond))
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Id, yesNo(info.IsDefault), info.IssuerCn, expires.Format("2006-01-02"))
+		}
+		return w.Flush()
+	case "insert":
+		info, err := gmailclient.InsertSmimeInfo(ctx, srv, user, email, p12, password)
+		if err != nil {
+			return fmt.Errorf("Unable to upload S/MIME certificate (is gmail.settings.basic among -scopes?): %w", err)
+		}
+		fmt.Printf("%s\n", info.Id)
+		if makeDefault {
+			if err := gmailclient.SetDefaultSmimeInfo(ctx, srv, user, email, info.Id); err != nil {
+				return fmt.Errorf("Unable to make the certificate the default: %w", err)
+			}
+		}
+	case "default":
+		if err := gmailclient.SetDefaultSmimeInfo(ctx, srv, user, email, id); err != nil {
+			return fmt.Errorf("Unable to make the certificate the default (is gmail.settings.basic among -scopes?): %w", err)
+		}
+	case "delete":
+		if err := gmailclient.DeleteSmimeInfo(ctx, srv, user, email, id); err != nil {
+			return fmt.Errorf("Unable to delete S/MIME certificate (is gmail.settings.basic among -scopes?): %w", err)
+		}
+	}
+	return nil
+}
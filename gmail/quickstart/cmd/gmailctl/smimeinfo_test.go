@@ -0,0 +1,89 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestSmimeAction(t *testing.T) {
+	ctx := context.Background()
+	s := gmailclienttest.New()
+	s.SendAs = []*gmail.SendAs{
+		{SendAsEmail: "ana@example.com", IsPrimary: true},
+		{SendAsEmail: "support@example.com"},
+	}
+	ids := func(email string) (ids []string, def string) {
+		for _, info := range s.SmimeInfo[email] {
+			ids = append(ids, info.Id)
+			if info.IsDefault {
+				def = info.Id
+			}
+		}
+		return ids, def
+	}
+
+	if err := smimeAction(ctx, s, "me", "", "insert", "", []byte("p12"), "secret", false, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if err := smimeAction(ctx, s, "me", "", "insert", "", []byte("p12"), "secret", true, "text"); err != nil {
+		t.Fatal(err)
+	}
+	got, def := ids("ana@example.com")
+	if len(got) != 2 || def != got[1] {
+		t.Fatalf("primary address certificates = %q with default %q, want 2 with the second the default", got, def)
+	}
+	if info := s.SmimeInfo["ana@example.com"][0]; info.EncryptedKeyPassword != "secret" || info.Pkcs12 == "" {
+		t.Errorf("uploaded certificate = %+v", info)
+	}
+	if err := smimeAction(ctx, s, "me", "", "list", "", nil, "", false, "json"); err != nil {
+		t.Error(err)
+	}
+
+	if err := smimeAction(ctx, s, "me", "", "default", got[0], nil, "", false, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if _, def := ids("ana@example.com"); def != got[0] {
+		t.Errorf("default after setdefault = %q, want %q", def, got[0])
+	}
+	if err := smimeAction(ctx, s, "me", "", "delete", got[1], nil, "", false, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := ids("ana@example.com"); len(left) != 1 || left[0] != got[0] {
+		t.Errorf("certificates after delete = %q, want %q", left, got[:1])
+	}
+
+	// -alias picks the address, and the certificates of one address are
+	// not those of another.
+	if err := smimeAction(ctx, s, "me", "Support@example.com", "insert", "", []byte("p12"), "", false, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ids("support@example.com"); len(got) != 1 {
+		t.Errorf("alias certificates = %q, want one", got)
+	}
+	if err := smimeAction(ctx, s, "me", "support@example.com", "delete", got[0], nil, "", false, "text"); err == nil {
+		t.Error("deleting another address's certificate succeeded")
+	}
+	if err := smimeAction(ctx, s, "me", "sales@example.com", "list", "", nil, "", false, "text"); err == nil {
+		t.Error("listing the certificates of an unknown alias succeeded")
+	}
+}
@@ -0,0 +1,230 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/snooze"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name:    "snooze",
+		usage:   "-until t|-for d [-label name] <message-id>... | list | wake <message-id>... | run [-interval d] [-once]",
+		summary: "Snooze messages out of the inbox until a given time, and bring them back (needs the gmail.modify scope).",
+		run:     runSnooze,
+	})
+}
+
+// snoozeLabel is the default label snoozed threads wait under.
+const snoozeLabel = "Snoozed"
+
+func runSnooze(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["snooze"])
+	until := fs.String("until", "", "when the messages return to the inbox, e.g. 2024-07-01T09:00")
+	after := fs.Duration("for", 0, "snooze for this long instead of -until, e.g. 3h")
+	label := fs.String("label", snoozeLabel, "label snoozed threads wait under")
+	db := fs.String("db", os.Getenv("GMAIL_SNOOZE_DB"), "database of snoozed messages (env GMAIL_SNOOZE_DB; default $XDG_CONFIG_HOME/gmailtool/snooze.db)")
+	interval := fs.Duration("interval", time.Minute, "how often run looks for messages to wake")
+	once := fs.Bool("once", false, "wake the due messages and exit instead of running until interrupted")
+	// Flags may follow the message IDs, as in snooze <id> -until t.
+	var rest []string
+	for fs.Parse(args); fs.NArg() > 0; fs.Parse(args) {
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(rest) == 0 {
+		fs.Usage()
+		return fmt.Errorf("snooze: expected message IDs or a subcommand")
+	}
+
+	path := config.ExpandHome(*db)
+	if path == "" {
+		var err error
+		if path, err = config.SnoozePath(); err != nil {
+			return err
+		}
+	}
+	s, err := snooze.Open(path)
+	if err != nil {
+		return fmt.Errorf("Unable to open snooze database: %w", err)
+	}
+	defer s.Close()
+
+	w := &waker{label: *label, accounts: map[string]*account{}, labels: map[string]string{}}
+	switch rest[0] {
+	case "list":
+		return listSnoozed(ctx, s)
+	case "wake":
+		for _, id := range rest[1:] {
+			e, err := s.Get(ctx, profileName(), id)
+			if err != nil {
+				return err
+			}
+			if e == nil {
+				return fmt.Errorf("message %s is not snoozed", id)
+			}
+			if err := w.wake(ctx, e); err != nil {
+				return fmt.Errorf("Unable to wake message %s: %w", id, err)
+			}
+			if err := s.Remove(ctx, e.Account, e.MessageID); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "run":
+		if *once {
+			tried, err := s.Wake(ctx, time.Now(), w.wake)
+			reportSnoozed(tried)
+			return err
+		}
+		return s.Run(ctx, *interval, w.wake, reportSnoozed)
+	}
+
+	var t time.Time
+	switch {
+	case *until != "" && *after != 0:
+		return fmt.Errorf("-until and -for cannot be used together")
+	case *until != "":
+		if t, err = parseSendTime(*until); err != nil {
+			return err
+		}
+	case *after > 0:
+		t = time.Now().Add(*after)
+	default:
+		return fmt.Errorf("snooze: expected -until or -for")
+	}
+	if t.Before(time.Now()) {
+		return fmt.Errorf("-until %s is in the past", *until)
+	}
+	return snoozeMessages(ctx, s, *label, rest, t)
+}
+
+// snoozeMessages moves the threads of the messages ids out of the inbox
+// under label, creating it if needed, and records when they return.
+func snoozeMessages(ctx context.Context, s *snooze.Store, label string, ids []string, t time.Time) error {
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	labelID, err := snoozeLabelID(ctx, a, label)
+	if err != nil {
+		return err
+	}
+	client := gmailclient.NewClient(a.srv)
+	for _, id := range ids {
+		msg, err := client.GetMessage(ctx, a.user, id, "minimal")
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve message %v: %w", id, err)
+		}
+		// Record the snooze first: a snoozed thread nobody wakes is lost
+		// from sight, while waking one that failed to snooze is harmless.
+		if err := s.Add(ctx, profileName(), id, msg.ThreadId, t); err != nil {
+			return err
+		}
+		if err := gmailclient.Snooze(ctx, a.api(), a.user, msg.ThreadId, labelID); err != nil {
+			return fmt.Errorf("Unable to snooze message %v (is gmail.modify among -scopes?): %w", id, err)
+		}
+		if err := uncacheThreads(ctx, a, msg.ThreadId); err != nil {
+			return err
+		}
+		fmt.Printf("%s\tsnoozed until %s\n", id, t.Format(time.RFC1123))
+	}
+	return nil
+}
+
+// snoozeLabelID returns the ID of the label called name in a, creating the
+// label if it does not exist.
+func snoozeLabelID(ctx context.Context, a *account, name string) (string, error) {
+	labels, err := gmailclient.ListLabels(ctx, a.api(), a.user)
+	if err != nil {
+		return "", fmt.Errorf("Unable to list labels: %w", err)
+	}
+	if l, err := gmailclient.FindLabel(labels, name); err == nil {
+		return l.Id, nil
+	}
+	l, err := gmailclient.CreateLabel(ctx, a.api(), a.user, &gmail.Label{Name: name}, true)
+	if err != nil {
+		return "", fmt.Errorf("Unable to create label %s (is gmail.modify among -scopes?): %w", name, err)
+	}
+	return l.Id, nil
+}
+
+// listSnoozed prints the snoozed messages of every account.
+func listSnoozed(ctx context.Context, s *snooze.Store) error {
+	entries, err := s.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tMESSAGE\tUNTIL\tERROR")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Account, e.MessageID, e.Until.Format("2006-01-02 15:04"), e.LastError)
+	}
+	return w.Flush()
+}
+
+// reportSnoozed logs the outcome of waking the tried messages.
+func reportSnoozed(tried []*snooze.Entry) {
+	for _, e := range tried {
+		if e.LastError != "" {
+			fmt.Fprintf(os.Stderr, "%s: waking failed (attempt %d), retrying: %s\n", e.MessageID, e.Attempts, e.LastError)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: back in the inbox of %s\n", e.MessageID, e.Account)
+		}
+	}
+}
+
+// A waker moves snoozed messages back to the inbox, authorizing each
+// account and looking its label up once.
+type waker struct {
+	label    string
+	accounts map[string]*account
+	labels   map[string]string // label ID by account
+}
+
+func (w *waker) wake(ctx context.Context, e *snooze.Entry) error {
+	a, ok := w.accounts[e.Account]
+	if !ok {
+		var err error
+		if a, err = openNamedAccount(ctx, e.Account); err != nil {
+			return err
+		}
+		w.accounts[e.Account] = a
+	}
+	labelID, ok := w.labels[e.Account]
+	if !ok {
+		var err error
+		if labelID, err = snoozeLabelID(ctx, a, w.label); err != nil {
+			return err
+		}
+		w.labels[e.Account] = labelID
+	}
+	if err := gmailclient.Unsnooze(ctx, a.api(), a.user, e.ThreadID, e.MessageID, labelID); err != nil {
+		return err
+	}
+	return uncacheThreads(ctx, a, e.ThreadID)
+}
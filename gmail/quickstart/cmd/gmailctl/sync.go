@@ -0,0 +1,98 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+)
+
+func init() {
+	register(&command{
+		name:    "sync",
+		usage:   "[-state path] [-full]",
+		summary: "Print the messages added, deleted or relabeled since the last sync.",
+		run:     runSync,
+	})
+}
+
+func runSync(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["sync"])
+	state := fs.String("state", "", "sync state file (default $XDG_CONFIG_HOME/gmailtool/sync/<account>.json)")
+	full := fs.Bool("full", false, "list every message instead of the changes since the last sync")
+	fs.Parse(args)
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	if *state != "" && len(accounts) > 1 {
+		return fmt.Errorf("-state cannot be used with several accounts")
+	}
+	return forEachAccount(ctx, accounts, func(a *account) error {
+		path := *state
+		if path == "" {
+			var err error
+			if path, err = config.SyncPath(a.name); err != nil {
+				return err
+			}
+		}
+		return syncAccount(ctx, a, path, *full)
+	})
+}
+
+// syncAccount prints the changes to one account since the history ID saved
+// in path, one message per line prefixed with +, - or ~ for added, deleted
+// and relabeled messages, and saves the new history ID.
+func syncAccount(ctx context.Context, a *account, path string, full bool) error {
+	s, err := gmailclient.LoadSyncState(path)
+	if err != nil {
+		return err
+	}
+	if full {
+		s.HistoryId = 0
+	}
+	c, err := gmailclient.Sync(ctx, gmailclient.NewClient(a.srv), a.user, s.HistoryId)
+	if err != nil {
+		return fmt.Errorf("Unable to sync: %w", err)
+	}
+	if c.Full && s.HistoryId != 0 {
+		fmt.Fprintln(os.Stderr, "The sync history has expired; listing every message.")
+	}
+	printIDs := func(mark string, ids []string) {
+		for _, id := range ids {
+			if multiAccount() {
+				fmt.Printf("%s\t", a.name)
+			}
+			fmt.Printf("%s\t%s\n", mark, id)
+		}
+	}
+	printIDs("+", c.Added)
+	printIDs("-", c.Deleted)
+	printIDs("~", c.LabelsChanged)
+
+	if err := uncache(ctx, a, c); err != nil {
+		return err
+	}
+	s.HistoryId = c.HistoryId
+	return gmailclient.SaveSyncState(path, s)
+}
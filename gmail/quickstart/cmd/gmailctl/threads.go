@@ -0,0 +1,228 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func init() {
+	register(&command{
+		name:    "threads",
+		usage:   "list [-query q] [-max-results n] [-output text|json|ndjson] | get [-prefer auto|plain|html] [-output text|json] <thread-id>",
+		summary: "List conversations, or print one whole conversation oldest message first.",
+		run:     runThreads,
+	})
+}
+
+// threadSummary is a line of threads list.
+type threadSummary struct {
+	Id       string    `json:"id"`
+	Messages int       `json:"messages"`
+	From     string    `json:"from"`
+	Subject  string    `json:"subject"`
+	Date     time.Time `json:"date"` // of the latest message
+	Snippet  string    `json:"snippet"`
+}
+
+func runThreads(ctx context.Context, args []string) error {
+	fs := newFlagSet(commands["threads"])
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("threads: expected list or get")
+	}
+	sub, args := fs.Arg(0), fs.Args()[1:]
+
+	fs = newFlagSet(commands["threads"])
+	var query string
+	var max int64
+	var prefer *string
+	output := outputFlag(fs)
+	switch sub {
+	case "list":
+		fs.StringVar(&query, "query", profile.Query, "Gmail search query")
+		fs.Int64Var(&max, "max-results", 20, "maximum number of threads to list, 0 for all")
+	case "get":
+		prefer = preferFlag(fs)
+	default:
+		fs.Usage()
+		return fmt.Errorf("threads: unknown subcommand %q", sub)
+	}
+	fs.Parse(args)
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+
+	a, err := currentAccount(ctx)
+	if err != nil {
+		return err
+	}
+	if sub == "list" {
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return fmt.Errorf("threads list: unexpected arguments")
+		}
+		return listThreads(ctx, a, query, max, *output)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("threads get: expected exactly one thread ID")
+	}
+	if err := checkPrefer(*prefer); err != nil {
+		return err
+	}
+	if *output == "ndjson" {
+		return fmt.Errorf("invalid -output ndjson for threads get (want text or json)")
+	}
+	return printThread(ctx, a, fs.Arg(0), *prefer, *output)
+}
+
+// listThreads prints the threads matching query, fetching the headers of
+// their messages concurrently.
+func listThreads(ctx context.Context, a *account, query string, max int64, output string) error {
+	var threads []*gmail.Thread
+	err := gmailclient.ListThreads(ctx, a.api(), a.user, query, max, func(t *gmail.Thread) error {
+		threads = append(threads, t)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to list threads: %w", err)
+	}
+
+	summaries := make([]*threadSummary, len(threads))
+	errs := make([]error, len(threads))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, t := range threads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, t *gmail.Thread) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			summaries[i], errs[i] = summarizeThread(ctx, a, t)
+		}(i, t)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("Unable to retrieve thread %s: %w", threads[i].Id, err)
+		}
+	}
+
+	if output != "text" {
+		j := newJSONWriter(os.Stdout, output)
+		for _, s := range summaries {
+			if err := j.write(s); err != nil {
+				return err
+			}
+		}
+		return j.close()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "THREAD\tMESSAGES\tDATE\tFROM\tSUBJECT")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", s.Id, s.Messages, s.Date.Local().Format("2006-01-02 15:04"), s.From, s.Subject)
+	}
+	return w.Flush()
+}
+
+// summarizeThread fetches the headers of the messages of t and sums them
+// up: the sender and subject of the first, the date of the latest.
+func summarizeThread(ctx context.Context, a *account, t *gmail.Thread) (*threadSummary, error) {
+	full, err := gmailclient.GetThread(ctx, a.api(), a.user, t.Id, "metadata", "From", "Subject", "Date")
+	if err != nil {
+		return nil, err
+	}
+	s := &threadSummary{Id: t.Id, Messages: len(full.Messages), Snippet: t.Snippet}
+	var senders []string
+	seen := map[string]bool{}
+	for i, msg := range full.Messages {
+		m, err := gmailclient.ParseMetadata(msg)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			s.Subject = m.Subject
+		}
+		if m.Date.After(s.Date) {
+			s.Date = m.Date
+		}
+		for _, from := range m.From {
+			name := from.Name
+			if name == "" {
+				name = from.Address
+			}
+			if !seen[name] {
+				seen[name] = true
+				senders = append(senders, name)
+			}
+		}
+	}
+	s.From = strings.Join(senders, ", ")
+	return s, nil
+}
+
+// printThread prints the messages of thread id oldest first, each with
+// its headers and body.
+func printThread(ctx context.Context, a *account, id, prefer, output string) error {
+	t, err := gmailclient.GetThread(ctx, a.api(), a.user, id, "full")
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve thread %v: %w", id, err)
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	msgs, err := gmailclient.ParseThread(ctx, client, t, a.user)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(msgs)
+	}
+	for i, m := range msgs {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("--- Message %d of %d (%s) ---\n", i+1, len(msgs), m.Id)
+		fmt.Printf("From: %s\nTo: %s\n", gmailclient.FormatAddressList(m.From), gmailclient.FormatAddressList(m.To))
+		if len(m.Cc) > 0 {
+			fmt.Printf("Cc: %s\n", gmailclient.FormatAddressList(m.Cc))
+		}
+		fmt.Printf("Date: %s\nSubject: %s\n", m.Date.Format(time.RFC1123Z), m.Subject)
+		for _, att := range m.Attachments {
+			fmt.Printf("Attachment: %s\n", att.Filename)
+		}
+		body, _ := m.Body(prefer)
+		fmt.Printf("\n%s\n", strings.TrimRight(body, "\n"))
+	}
+	return nil
+}
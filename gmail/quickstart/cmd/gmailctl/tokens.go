@@ -0,0 +1,110 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/term"
+)
+
+var (
+	// tokenStoreKind is set by the -token-store global flag.
+	tokenStoreKind string
+
+	// tokenKeySource is set by the -token-key global flag.
+	tokenKeySource string
+)
+
+const (
+	// tokenEnv is the environment variable read by the "env" token store.
+	tokenEnv = "GMAIL_TOKEN_JSON"
+
+	// keyringService is the service name of keyring entries.
+	keyringService = "gmailtool"
+
+	// passphraseEnv is the environment variable holding the passphrase of
+	// the "encrypted" token store.
+	passphraseEnv = "GMAIL_TOKEN_PASSPHRASE"
+)
+
+// newTokenStore returns the token store of the given kind for the named
+// account. path is the token file used by the "file" store.
+func newTokenStore(kind, account, path string) (gmailclient.TokenStore, error) {
+	switch kind {
+	case "", "file":
+		return gmailclient.NewFileTokenStore(path), nil
+	case "keyring":
+		if account == "" {
+			account = "default"
+		}
+		return gmailclient.NewKeyringTokenStore(keyringService, account), nil
+	case "encrypted":
+		return gmailclient.NewEncryptedFileTokenStore(path, tokenPassphrase(account)), nil
+	case "env":
+		return gmailclient.NewEnvTokenStore(tokenEnv), nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q (want file, encrypted, keyring or env)", kind)
+	}
+}
+
+// tokenPassphrase returns the passphrase source of the encrypted token store:
+// $GMAIL_TOKEN_PASSPHRASE if set, a random key kept in the OS keyring with
+// -token-key keyring, or else a prompt on the terminal. The passphrase is
+// asked for at most once.
+func tokenPassphrase(account string) gmailclient.PassphraseFunc {
+	if account == "" {
+		account = "default"
+	}
+	var (
+		once       sync.Once
+		passphrase string
+		err        error
+	)
+	return func() (string, error) {
+		once.Do(func() {
+			if p := os.Getenv(passphraseEnv); p != "" {
+				passphrase = p
+				return
+			}
+			switch tokenKeySource {
+			case "keyring":
+				passphrase, err = gmailclient.KeyringPassphrase(keyringService, account+".key")()
+			case "", "prompt":
+				passphrase, err = promptPassphrase(account)
+			default:
+				err = fmt.Errorf("unknown token key source %q (want prompt or keyring)", tokenKeySource)
+			}
+		})
+		return passphrase, err
+	}
+}
+
+func promptPassphrase(account string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to read the token passphrase from; set $%s", passphraseEnv)
+	}
+	fmt.Fprintf(os.Stderr, "Token passphrase for %s: ", account)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
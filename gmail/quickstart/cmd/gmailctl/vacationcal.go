@@ -0,0 +1,228 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+	"google.golang.org/api/gmail/v1"
+)
+
+// calendarVacation keeps the vacation responder of an account in step with
+// the out-of-office events of its calendar.
+type calendarVacation struct {
+	calendar     string
+	lookahead    time.Duration
+	tmpl         *gmailclient.MessageTemplate
+	contactsOnly bool
+	domainOnly   bool
+	dryRun       bool
+}
+
+// vacationState is the responder window calendarVacation last set, to tell
+// its own responder from one set by hand.
+type vacationState struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// absenceData are the values the responder templates are rendered with.
+type absenceData struct {
+	Summary    string
+	Start, End time.Time
+	Back       string // the day the absence ends, e.g. Monday, July 15
+}
+
+func runVacationCalendar(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	c := &calendarVacation{}
+	fs.StringVar(&c.calendar, "calendar", "primary", "calendar whose out-of-office events to follow")
+	fs.DurationVar(&c.lookahead, "lookahead", 30*24*time.Hour, "how far ahead to look for the next absence")
+	subject := fs.String("subject", "Out of office", "subject of the automatic reply, a text/template with {{.Summary}} and {{.Back}}")
+	body := fs.String("body", "I am out of the office and back on {{.Back}}.", "plain-text body of the reply, a text/template like -subject")
+	htmlFile := fs.String("html-file", "", "read an html/template for the HTML body of the reply from this file")
+	fs.BoolVar(&c.contactsOnly, "contacts-only", false, "only reply to people in the contacts")
+	fs.BoolVar(&c.domainOnly, "domain-only", false, "only reply to people in the same Google Workspace domain")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "print the changes without making them")
+	watch := fs.Duration("watch", 0, "keep following the calendar, checking this often")
+	state := fs.String("state", "", "the state file (default $XDG_CONFIG_HOME/gmailtool/vacation/<account>.json)")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("settings vacation calendar: unexpected arguments")
+	}
+	var html string
+	if *htmlFile != "" {
+		b, err := ioutil.ReadFile(*htmlFile)
+		if err != nil {
+			return fmt.Errorf("Unable to read -html-file: %w", err)
+		}
+		html = string(b)
+	}
+	var err error
+	if c.tmpl, err = gmailclient.ParseMessageTemplate("Subject: "+*subject+"\n\n"+*body, html); err != nil {
+		return fmt.Errorf("Unable to parse the reply templates: %w", err)
+	}
+
+	accounts, err := openAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	if *state != "" && len(accounts) > 1 {
+		return fmt.Errorf("-state cannot be used with several accounts")
+	}
+	var ticker *time.Ticker
+	if *watch > 0 {
+		ticker = time.NewTicker(*watch)
+		defer ticker.Stop()
+	}
+	for {
+		err := forEachAccount(ctx, accounts, func(a *account) error {
+			path := *state
+			if path == "" {
+				var err error
+				if path, err = config.VacationStatePath(a.name); err != nil {
+					return err
+				}
+			}
+			return c.sync(ctx, a, path, time.Now())
+		})
+		if ticker == nil {
+			return err
+		}
+		if err != nil {
+			// Keep watching; failures have been reported.
+			fmt.Fprintln(os.Stderr, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync sets the vacation responder of a for the absence under way or next
+// on the calendar, or turns off the responder it set before once there is
+// none. A responder set by hand is left alone. Gmail itself turns the
+// responder on and off at the times set, so sync need not run at either.
+func (c *calendarVacation) sync(ctx context.Context, a *account, statePath string, now time.Time) error {
+	absences, err := gmailclient.OutOfOfficeEvents(ctx, a.http, c.calendar, now, now.Add(c.lookahead))
+	if err != nil {
+		return fmt.Errorf("Unable to read calendar (is %s among -scopes?): %w", gmailclient.CalendarScope, err)
+	}
+	next := gmailclient.NextAbsence(absences, now)
+	cur, err := gmailclient.GetVacation(ctx, a.api(), a.user)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve vacation settings: %w", err)
+	}
+	st, err := loadVacationState(statePath)
+	if err != nil {
+		return err
+	}
+	ours := st.End != 0 && cur.StartTime == st.Start && cur.EndTime == st.End
+	over := cur.EndTime != 0 && gmailclient.VacationTimeOf(cur.EndTime).Before(now)
+	if cur.EnableAutoReply && !ours && !over {
+		if next != nil {
+			fmt.Fprintf(os.Stderr, "%s: leaving the vacation responder set by hand alone\n", a.name)
+		}
+		return nil
+	}
+
+	if next == nil {
+		if !ours || !cur.EnableAutoReply {
+			return nil
+		}
+		fmt.Printf("%s: no absence on the calendar; turning the vacation responder off\n", a.name)
+		if c.dryRun {
+			return nil
+		}
+		off := &gmail.VacationSettings{EnableAutoReply: false, ForceSendFields: []string{"EnableAutoReply"}}
+		if _, err := gmailclient.UpdateVacation(ctx, a.api(), a.user, off); err != nil {
+			return fmt.Errorf("Unable to update vacation settings (is gmail.settings.basic among -scopes?): %w", err)
+		}
+		return saveVacationState(statePath, &vacationState{})
+	}
+
+	want := vacationState{Start: gmailclient.VacationTime(next.Start), End: gmailclient.VacationTime(next.End)}
+	if cur.EnableAutoReply && ours && *st == want {
+		return nil
+	}
+	m, err := c.tmpl.Execute(&absenceData{
+		Summary: next.Summary,
+		Start:   next.Start,
+		End:     next.End,
+		Back:    next.End.Local().Format("Monday, January 2"),
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to render the reply: %w", err)
+	}
+	fmt.Printf("%s: vacation responder on from %s to %s (%s)\n", a.name,
+		next.Start.Local().Format("2006-01-02 15:04"), next.End.Local().Format("2006-01-02 15:04"), next.Summary)
+	if c.dryRun {
+		return nil
+	}
+	v := &gmail.VacationSettings{
+		EnableAutoReply:       true,
+		ResponseSubject:       m.Subject,
+		ResponseBodyPlainText: m.Text,
+		ResponseBodyHtml:      m.HTML,
+		RestrictToContacts:    c.contactsOnly,
+		RestrictToDomain:      c.domainOnly,
+		StartTime:             want.Start,
+		EndTime:               want.End,
+	}
+	if _, err := gmailclient.UpdateVacation(ctx, a.api(), a.user, v); err != nil {
+		return fmt.Errorf("Unable to update vacation settings (is gmail.settings.basic among -scopes?): %w", err)
+	}
+	return saveVacationState(statePath, &want)
+}
+
+func loadVacationState(path string) (*vacationState, error) {
+	st := &vacationState{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("Unable to read vacation state %s: %w", path, err)
+	}
+	return st, nil
+}
+
+func saveVacationState(path string, st *vacationState) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, b, 0600)
+}
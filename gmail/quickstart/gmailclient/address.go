@@ -0,0 +1,80 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"net/mail"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+var addressParser = &mail.AddressParser{WordDecoder: wordDecoder}
+
+// ParseAddressList parses an address header such as From or To. Entries that
+// are not valid RFC 5322 addresses are kept with the raw text as the address
+// rather than dropped, so a single malformed recipient does not hide the
+// others.
+func ParseAddressList(value string) []*mail.Address {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	if list, err := addressParser.ParseList(value); err == nil {
+		return list
+	}
+	var list []*mail.Address
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		a, err := addressParser.Parse(s)
+		if err != nil {
+			a = &mail.Address{Address: DecodeHeader(s)}
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+// FormatAddressList formats addresses for display as `Name <addr>, addr`.
+// Unlike mail.Address.String it leaves non-ASCII names readable.
+func FormatAddressList(list []*mail.Address) string {
+	s := make([]string, len(list))
+	for i, a := range list {
+		switch {
+		case a.Name == "":
+			s[i] = a.Address
+		case a.Address == "":
+			s[i] = a.Name
+		default:
+			s[i] = a.Name + " <" + a.Address + ">"
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// findAddresses parses the named address header of part.
+func findAddresses(part *gmail.MessagePart, name string) []*mail.Address {
+	for _, header := range part.Headers {
+		if strings.EqualFold(header.Name, name) {
+			return ParseAddressList(header.Value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestParseAddressList(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"me@example.com", "me@example.com"},
+		{`"Doe, Jane" <jane@example.com>, bob@example.com`, "Doe, Jane <jane@example.com>, bob@example.com"},
+		{"=?UTF-8?Q?Ren=C3=A9e?= <renee@example.com>", "Renée <renee@example.com>"},
+		{"Ann <ann@example.com>, undisclosed-recipients:;, not an address", "Ann <ann@example.com>, undisclosed-recipients:;, not an address"},
+	} {
+		if got := gmailclient.FormatAddressList(gmailclient.ParseAddressList(tt.in)); got != tt.want {
+			t.Errorf("ParseAddressList(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
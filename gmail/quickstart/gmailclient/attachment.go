@@ -0,0 +1,177 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Attachment describes a part of a message that has a filename.
+type Attachment struct {
+	PartId       string `json:"partId"`
+	Filename     string `json:"filename"`
+	MimeType     string `json:"mimeType"`
+	Size         int64  `json:"size"`
+	ContentID    string `json:"contentId,omitempty"`    // without angle brackets; set for inline parts
+	AttachmentId string `json:"attachmentId,omitempty"` // empty if the data is inlined in the message
+
+	// SHA256 is the hex SHA-256 of the decoded data when Gmail included
+	// the data in the message. Parts stored separately must be downloaded
+	// to be hashed, so SHA256 is empty for them.
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Text is the plain text of a PDF or Office attachment, filled in by
+	// ExtractAttachmentText.
+	Text string `json:"text,omitempty"`
+}
+
+// ListAttachments walks the part tree and returns the attachments in
+// depth-first order.
+func ListAttachments(part *gmail.MessagePart) []*Attachment {
+	var list []*Attachment
+	var walk func(*gmail.MessagePart)
+	walk = func(p *gmail.MessagePart) {
+		if p == nil {
+			return
+		}
+		if p.Filename != "" {
+			a := &Attachment{
+				PartId:    p.PartId,
+				Filename:  p.Filename,
+				MimeType:  p.MimeType,
+				ContentID: strings.Trim(FindHeader(p, "Content-ID"), "<> "),
+			}
+			if p.Body != nil {
+				a.Size = p.Body.Size
+				a.AttachmentId = p.Body.AttachmentId
+				if p.Body.AttachmentId == "" && p.Body.Data != "" {
+					if data, err := base64.URLEncoding.DecodeString(p.Body.Data); err == nil {
+						sum := sha256.Sum256(data)
+						a.SHA256 = hex.EncodeToString(sum[:])
+					}
+				}
+			}
+			list = append(list, a)
+		}
+		for _, c := range p.Parts {
+			walk(c)
+		}
+	}
+	walk(part)
+	return list
+}
+
+// AttachmentFilter selects attachments. The zero value matches all of them.
+type AttachmentFilter struct {
+	// Name is a glob, as in path.Match, matched case-insensitively
+	// against the filename.
+	Name string
+
+	// MimeType is a MIME type such as "application/pdf", or a type with a
+	// wildcard subtype such as "image/*".
+	MimeType string
+
+	// MinSize is the smallest size in bytes to match.
+	MinSize int64
+}
+
+// Match reports whether a passes all conditions of f.
+func (f AttachmentFilter) Match(a *Attachment) bool {
+	if f.Name != "" {
+		if ok, _ := path.Match(strings.ToLower(f.Name), strings.ToLower(a.Filename)); !ok {
+			return false
+		}
+	}
+	if f.MimeType != "" {
+		want, got := strings.ToLower(f.MimeType), strings.ToLower(a.MimeType)
+		if strings.HasSuffix(want, "/*") {
+			if !strings.HasPrefix(got, want[:len(want)-1]) {
+				return false
+			}
+		} else if got != want {
+			return false
+		}
+	}
+	return a.Size >= f.MinSize
+}
+
+// GetAttachmentData returns the decoded content of attachment a of
+// gmailMessage, byte for byte; unlike GetMessagePartData it does not convert
+// text to UTF-8. It returns an error wrapping ErrPartNotFound if the message
+// has no part a.PartId.
+func GetAttachmentData(ctx context.Context, srv GmailService, user string, gmailMessage *gmail.Message, a *Attachment) ([]byte, error) {
+	if gmailMessage.Payload == nil {
+		return nil, ErrNoPayload
+	}
+	part := findPartByID(gmailMessage.Payload, a.PartId)
+	if part == nil || part.Body == nil {
+		return nil, fmt.Errorf("%w: part %s", ErrPartNotFound, a.PartId)
+	}
+	data, err := partData(ctx, srv, user, gmailMessage.Id, part)
+	if err != nil {
+		return nil, fmt.Errorf("GetAttachmentData %w", err)
+	}
+	return data, nil
+}
+
+// WriteAttachment writes the decoded content of attachment a of
+// gmailMessage to w and returns the number of bytes written. The API returns
+// the data base64-encoded in a single response, which is held while it is
+// decoded straight into w, so a large attachment is not also buffered in its
+// decoded form as with GetAttachmentData.
+func WriteAttachment(ctx context.Context, srv GmailService, user string, gmailMessage *gmail.Message, a *Attachment, w io.Writer) (int64, error) {
+	if gmailMessage.Payload == nil {
+		return 0, ErrNoPayload
+	}
+	part := findPartByID(gmailMessage.Payload, a.PartId)
+	if part == nil || part.Body == nil {
+		return 0, fmt.Errorf("%w: part %s", ErrPartNotFound, a.PartId)
+	}
+	r, err := partReader(ctx, srv, user, gmailMessage.Id, part)
+	if err != nil {
+		return 0, fmt.Errorf("WriteAttachment %w", err)
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return n, fmt.Errorf("WriteAttachment decode: %w", err)
+	}
+	return n, nil
+}
+
+// findPartByID returns the part of the tree under part with the given ID, or
+// nil.
+func findPartByID(part *gmail.MessagePart, id string) *gmail.MessagePart {
+	if part.PartId == id {
+		return part
+	}
+	for _, p := range part.Parts {
+		if found := findPartByID(p, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestListAttachments(t *testing.T) {
+	payload := &gmail.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts: []*gmail.MessagePart{
+			{MimeType: "text/plain", Body: &gmail.MessagePartBody{Data: "aGk="}},
+			{
+				PartId:   "1",
+				MimeType: "text/csv",
+				Filename: "report.csv",
+				Body:     &gmail.MessagePartBody{Size: 3, Data: base64.URLEncoding.EncodeToString([]byte("abc"))},
+			},
+			{
+				PartId:   "2",
+				MimeType: "application/pdf",
+				Filename: "invoice.pdf",
+				Body:     &gmail.MessagePartBody{Size: 52000, AttachmentId: "att-pdf"},
+			},
+		},
+	}
+	list := gmailclient.ListAttachments(payload)
+	if len(list) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(list))
+	}
+	csv, pdf := list[0], list[1]
+	if csv.Filename != "report.csv" || csv.Size != 3 ||
+		csv.SHA256 != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("csv = %+v", csv)
+	}
+	if pdf.AttachmentId != "att-pdf" || pdf.MimeType != "application/pdf" || pdf.SHA256 != "" {
+		t.Errorf("pdf = %+v", pdf)
+	}
+}
+
+func TestAttachmentFilter(t *testing.T) {
+	a := &gmailclient.Attachment{Filename: "Invoice-May.PDF", MimeType: "application/pdf", Size: 52000}
+	tests := []struct {
+		f    gmailclient.AttachmentFilter
+		want bool
+	}{
+		{gmailclient.AttachmentFilter{}, true},
+		{gmailclient.AttachmentFilter{Name: "invoice-*.pdf"}, true},
+		{gmailclient.AttachmentFilter{Name: "*.csv"}, false},
+		{gmailclient.AttachmentFilter{MimeType: "application/*"}, true},
+		{gmailclient.AttachmentFilter{MimeType: "image/*"}, false},
+		{gmailclient.AttachmentFilter{MimeType: "application/pdf", MinSize: 50000}, true},
+		{gmailclient.AttachmentFilter{MinSize: 100000}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Match(a); got != tt.want {
+			t.Errorf("%+v.Match = %v, want %v", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestWriteAttachment(t *testing.T) {
+	data := bytes.Repeat([]byte{0, 1, 2, 0xff}, 100000)
+	srv := gmailclienttest.New()
+	srv.AddAttachment("att-bin", &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString(data)})
+	msg := &gmail.Message{
+		Id: "17a1",
+		Payload: &gmail.MessagePart{
+			MimeType: "multipart/mixed",
+			Parts: []*gmail.MessagePart{
+				{PartId: "1", MimeType: "application/octet-stream", Filename: "blob.bin", Body: &gmail.MessagePartBody{Size: int64(len(data)), AttachmentId: "att-bin"}},
+			},
+		},
+	}
+	att := gmailclient.ListAttachments(msg.Payload)[0]
+	var buf bytes.Buffer
+	n, err := gmailclient.WriteAttachment(context.Background(), srv, "me", msg, att, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("wrote %d bytes, want %d identical bytes", n, len(data))
+	}
+}
@@ -0,0 +1,221 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"golang.org/x/oauth2"
+)
+
+// AuthOptions configures GetClient.
+type AuthOptions struct {
+	// Flow gets a token from the user when there is no usable one; nil
+	// means GetTokenFromLoopback.
+	Flow AuthFlow
+
+	// Notify, if set, is told why a stored token is being replaced before
+	// Flow runs again.
+	Notify func(msg string)
+}
+
+func (o AuthOptions) notify(format string, args ...interface{}) {
+	if o.Notify != nil {
+		o.Notify(fmt.Sprintf(format, args...))
+	}
+}
+
+// GetClient retrieves a token from store, or runs opts.Flow to get one from
+// the user if the store is empty, saves it, and returns the generated client.
+//
+// If the stored token was granted fewer scopes than config asks for, the flow
+// runs again to get the user's consent for the new ones; if that fails the
+// error wraps ErrInsufficientScopes.
+//
+// An expired access token is refreshed right away and the refreshed token
+// saved in store. If the refresh token has been revoked or has expired, the
+// stored token is discarded and the flow runs again; if that fails too the
+// error wraps ErrTokenRevoked.
+func GetClient(ctx context.Context, config *oauth2.Config, store TokenStore, opts AuthOptions) (*http.Client, error) {
+	flow := opts.Flow
+	if flow == nil {
+		flow = GetTokenFromLoopback
+	}
+	tok, err := store.Load()
+	if errors.Is(err, ErrNoToken) {
+		if tok, err = authorize(ctx, config, store, flow); err != nil {
+			return nil, err
+		}
+		return config.Client(ctx, tok), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if missing := MissingScopes(tok, config.Scopes); len(missing) > 0 {
+		opts.notify("The saved token lacks the scopes %s; authorizing again.", strings.Join(missing, " "))
+		if tok, err = authorize(ctx, config, store, flow); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInsufficientScopes, err)
+		}
+		return config.Client(ctx, tok), nil
+	}
+
+	fresh, err := config.TokenSource(ctx, tok).Token()
+	if IsInvalidGrant(err) {
+		if err := store.Delete(); err != nil {
+			return nil, fmt.Errorf("GetClient discard revoked token: %w", err)
+		}
+		opts.notify("The saved token has expired or been revoked; authorizing again.")
+		if fresh, err = authorize(ctx, config, store, flow); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTokenRevoked, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("GetClient refresh token: %w", err)
+	} else if fresh.AccessToken != tok.AccessToken {
+		// The token endpoint may leave out the scopes and, if it rotated
+		// it, the old refresh token is gone, so the refreshed token
+		// replaces the stored one with the scopes carried over.
+		if len(GrantedScopes(fresh)) == 0 {
+			if granted := GrantedScopes(tok); len(granted) > 0 {
+				fresh = WithScopes(fresh, granted)
+			}
+		}
+		if err := store.Save(fresh); err != nil {
+			return nil, fmt.Errorf("GetClient save refreshed token: %w", err)
+		}
+	}
+	return config.Client(ctx, fresh), nil
+}
+
+// authorize runs flow and saves the resulting token in store.
+func authorize(ctx context.Context, config *oauth2.Config, store TokenStore, flow AuthFlow) (*oauth2.Token, error) {
+	tok, err := flow(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Save(tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// IsInvalidGrant reports whether err is the token endpoint rejecting a
+// refresh token because it expired or was revoked.
+func IsInvalidGrant(err error) bool {
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) {
+		return false
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	return json.Unmarshal(re.Body, &body) == nil && body.Error == "invalid_grant"
+}
+
+// NoAuthFlow is an AuthFlow for non-interactive use, such as cron jobs. It
+// always fails with ErrAuthRequired.
+func NoAuthFlow(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	return nil, ErrAuthRequired
+}
+
+// GetTokenFromWeb requests a token from the web by having the user paste the
+// authorization code, then returns the retrieved token. Waiting for the
+// authorization code is abandoned when ctx is done. The code is bound to this
+// request with PKCE.
+func GetTokenFromWeb(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	state, err := newState()
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenFromWeb: %w", err)
+	}
+	verifier, err := newCodeVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenFromWeb: %w", err)
+	}
+	opts := append([]oauth2.AuthCodeOption{oauth2.AccessTypeOffline}, verifier.authParams()...)
+	authURL := config.AuthCodeURL(state, opts...)
+	fmt.Fprintf(os.Stderr, "Go to the following link in your browser then type the "+
+		"authorization code: \n%v\n", authURL)
+
+	type scanResult struct {
+		code string
+		err  error
+	}
+	scanned := make(chan scanResult, 1)
+	go func() {
+		var r scanResult
+		_, r.err = fmt.Scan(&r.code)
+		scanned <- r
+	}()
+
+	var authCode string
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-scanned:
+		if r.err != nil {
+			return nil, fmt.Errorf("GetTokenFromWeb read authorization code: %w", r.err)
+		}
+		authCode = r.code
+	}
+
+	tok, err := config.Exchange(ctx, authCode, verifier.exchangeParam())
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenFromWeb exchange authorization code: %w", err)
+	}
+	return tok, nil
+}
+
+// TokenFromFile retrieves a token from a local file. It returns an error
+// wrapping ErrNoToken if the file does not exist.
+func TokenFromFile(file string) (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %v", ErrNoToken, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("TokenFromFile: %w", err)
+	}
+	tok, err := decodeToken(b)
+	if err != nil {
+		return nil, fmt.Errorf("TokenFromFile decode %s: %w", file, err)
+	}
+	return tok, nil
+}
+
+// SaveToken saves a token to a file path. The file is replaced atomically, so
+// an interrupted save never leaves a truncated token behind.
+func SaveToken(path string, token *oauth2.Token) error {
+	b, err := encodeToken(token)
+	if err != nil {
+		return fmt.Errorf("SaveToken encode oauth token: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("SaveToken create token directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, b, 0600); err != nil {
+		return fmt.Errorf("SaveToken cache oauth token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/oauth2"
+)
+
+func revokedTokenServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant", "error_description": "Token has been expired or revoked."}`))
+	}))
+}
+
+func expiredToken() *oauth2.Token {
+	return &oauth2.Token{AccessToken: "old", RefreshToken: "revoked", Expiry: time.Now().Add(-time.Hour)}
+}
+
+func TestGetClientReauthorizesRevokedToken(t *testing.T) {
+	ts := revokedTokenServer()
+	defer ts.Close()
+	config := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: ts.URL}}
+	store := gmailclient.NewMemoryTokenStore(expiredToken())
+
+	flowRan := false
+	flow := func(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+		flowRan = true
+		return &oauth2.Token{AccessToken: "new", RefreshToken: "new", Expiry: time.Now().Add(time.Hour)}, nil
+	}
+	var notes []string
+	opts := gmailclient.AuthOptions{Flow: flow, Notify: func(msg string) { notes = append(notes, msg) }}
+	if _, err := gmailclient.GetClient(context.Background(), config, store, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !flowRan {
+		t.Error("auth flow did not run for a revoked token")
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "revoked") {
+		t.Errorf("notifications = %q, want one about the revoked token", notes)
+	}
+	if tok, err := store.Load(); err != nil || tok.RefreshToken != "new" {
+		t.Errorf("stored token = %+v, %v; want the new token", tok, err)
+	}
+}
+
+func TestGetClientRevokedNonInteractive(t *testing.T) {
+	ts := revokedTokenServer()
+	defer ts.Close()
+	config := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: ts.URL}}
+	store := gmailclient.NewMemoryTokenStore(expiredToken())
+
+	_, err := gmailclient.GetClient(context.Background(), config, store, gmailclient.AuthOptions{Flow: gmailclient.NoAuthFlow})
+	if !errors.Is(err, gmailclient.ErrTokenRevoked) {
+		t.Errorf("err = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := store.Load(); !errors.Is(err, gmailclient.ErrNoToken) {
+		t.Errorf("revoked token was not discarded: %v", err)
+	}
+}
+
+func TestGetClientSavesRefreshedToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "refreshed", "refresh_token": "rotated", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer ts.Close()
+	config := &oauth2.Config{Scopes: []string{"a"}, Endpoint: oauth2.Endpoint{TokenURL: ts.URL}}
+	store := gmailclient.NewMemoryTokenStore(gmailclient.WithScopes(expiredToken(), []string{"a"}))
+
+	if _, err := gmailclient.GetClient(context.Background(), config, store, gmailclient.AuthOptions{Flow: gmailclient.NoAuthFlow}); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := store.Load()
+	if err != nil || tok.AccessToken != "refreshed" || tok.RefreshToken != "rotated" {
+		t.Fatalf("stored token = %+v, %v; want the refreshed token", tok, err)
+	}
+	if got := gmailclient.GrantedScopes(tok); len(got) != 1 || got[0] != "a" {
+		t.Errorf("stored scopes = %q, want the ones granted before the refresh", got)
+	}
+}
+
+func TestGetClientReconsentsForNewScopes(t *testing.T) {
+	config := &oauth2.Config{Scopes: []string{"a", "b"}}
+	granted := gmailclient.WithScopes(&oauth2.Token{AccessToken: "old", Expiry: time.Now().Add(time.Hour)}, []string{"a"})
+	store := gmailclient.NewMemoryTokenStore(granted)
+
+	flow := func(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+		tok := &oauth2.Token{AccessToken: "new", Expiry: time.Now().Add(time.Hour)}
+		return gmailclient.WithScopes(tok, config.Scopes), nil
+	}
+	var notes []string
+	opts := gmailclient.AuthOptions{Flow: flow, Notify: func(msg string) { notes = append(notes, msg) }}
+	if _, err := gmailclient.GetClient(context.Background(), config, store, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "lacks the scopes b") {
+		t.Errorf("notifications = %q, want one about scope b", notes)
+	}
+	tok, err := store.Load()
+	if err != nil || tok.AccessToken != "new" {
+		t.Fatalf("stored token = %+v, %v; want the new token", tok, err)
+	}
+	if missing := gmailclient.MissingScopes(tok, config.Scopes); len(missing) > 0 {
+		t.Errorf("new token lacks %v", missing)
+	}
+
+	_, err = gmailclient.GetClient(context.Background(), &oauth2.Config{Scopes: []string{"c"}}, store, gmailclient.AuthOptions{Flow: gmailclient.NoAuthFlow})
+	if !errors.Is(err, gmailclient.ErrInsufficientScopes) {
+		t.Errorf("err = %v, want ErrInsufficientScopes", err)
+	}
+}
@@ -0,0 +1,194 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// BigQueryScope is the scope BigQueryTable needs to create and fill tables.
+const BigQueryScope = bigquery.BigqueryScope
+
+// BigQuerySchema is the schema of the rows BigQueryRow returns. Tables are
+// created with it, partitioned by day on date. The recipients are in
+// to_addrs rather than to, which is a reserved word in BigQuery SQL.
+var BigQuerySchema = &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+	{Name: "account", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "thread_id", Type: "STRING"},
+	{Name: "date", Type: "TIMESTAMP"},
+	{Name: "sender", Type: "STRING"},
+	{Name: "sender_address", Type: "STRING"},
+	{Name: "to_addrs", Type: "STRING"},
+	{Name: "cc", Type: "STRING"},
+	{Name: "subject", Type: "STRING"},
+	{Name: "size", Type: "INTEGER"},
+	{Name: "labels", Type: "STRING", Mode: "REPEATED"},
+	{Name: "has_attachments", Type: "BOOLEAN"},
+	{Name: "attachments", Type: "STRING", Mode: "REPEATED"},
+	{Name: "message_id", Type: "STRING"},
+	{Name: "body_plain", Type: "STRING"},
+	{Name: "body_html", Type: "STRING"},
+	{Name: "attachment_text", Type: "STRING"},
+}}
+
+// BigQueryRow returns m as a row of BigQuerySchema for the mailbox account.
+// The bodies are only set if bodies is true, and attachment_text only if
+// text was extracted from the attachments.
+func BigQueryRow(account string, m *Message, bodies bool) map[string]bigquery.JsonValue {
+	row := map[string]bigquery.JsonValue{
+		"account":         account,
+		"id":              m.Id,
+		"thread_id":       m.ThreadId,
+		"sender":          FormatAddressList(m.From),
+		"to_addrs":        FormatAddressList(m.To),
+		"cc":              FormatAddressList(m.Cc),
+		"subject":         m.Subject,
+		"size":            m.Size,
+		"labels":          append([]string{}, m.LabelIds...),
+		"has_attachments": len(m.Attachments) > 0,
+		"message_id":      m.Headers.Get("Message-ID"),
+	}
+	if !m.Date.IsZero() {
+		row["date"] = m.Date.UTC().Format(time.RFC3339)
+	}
+	if len(m.From) > 0 {
+		row["sender_address"] = strings.ToLower(m.From[0].Address)
+	}
+	attachments := []string{}
+	for _, a := range m.Attachments {
+		attachments = append(attachments, a.Filename)
+	}
+	row["attachments"] = attachments
+	if bodies {
+		row["body_plain"] = m.BodyPlain
+		row["body_html"] = m.BodyHtml
+	}
+	if text := m.AttachmentText(); text != "" {
+		row["attachment_text"] = text
+	}
+	return row
+}
+
+// BigQueryTable streams messages into a BigQuery table.
+type BigQueryTable struct {
+	srv     *bigquery.Service
+	project string
+	dataset string
+	table   string
+}
+
+// ParseTableID splits a table ID of the form project.dataset.table, or
+// project:dataset.table as the bq tool writes it.
+func ParseTableID(id string) (project, dataset, table string, err error) {
+	parts := strings.Split(strings.Replace(id, ":", ".", 1), ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid BigQuery table %q (want project.dataset.table)", id)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// OpenBigQueryTable returns the table with the given ID (see ParseTableID),
+// creating the dataset and the table with BigQuerySchema if they do not
+// exist. client must be authorized for BigQueryScope, for example a
+// DefaultClient.
+func OpenBigQueryTable(ctx context.Context, client *http.Client, id string) (*BigQueryTable, error) {
+	project, dataset, table, err := ParseTableID(id)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := bigquery.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("OpenBigQueryTable create client: %w", err)
+	}
+	t := &BigQueryTable{srv: srv, project: project, dataset: dataset, table: table}
+
+	_, err = srv.Tables.Get(project, dataset, table).Context(ctx).Do()
+	if err == nil {
+		return t, nil
+	}
+	if !isNotFound(err) {
+		return nil, fmt.Errorf("OpenBigQueryTable get table: %w", err)
+	}
+	_, err = srv.Datasets.Insert(project, &bigquery.Dataset{
+		DatasetReference: &bigquery.DatasetReference{ProjectId: project, DatasetId: dataset},
+	}).Context(ctx).Do()
+	if err != nil && !isConflict(err) {
+		return nil, fmt.Errorf("OpenBigQueryTable create dataset: %w", err)
+	}
+	_, err = srv.Tables.Insert(project, dataset, &bigquery.Table{
+		TableReference:   &bigquery.TableReference{ProjectId: project, DatasetId: dataset, TableId: table},
+		Schema:           BigQuerySchema,
+		TimePartitioning: &bigquery.TimePartitioning{Type: "DAY", Field: "date"},
+	}).Context(ctx).Do()
+	if err != nil && !isConflict(err) {
+		return nil, fmt.Errorf("OpenBigQueryTable create table: %w", err)
+	}
+	return t, nil
+}
+
+// Insert streams rows made by BigQueryRow into the table. Each row is keyed
+// by its account and message ID, so BigQuery drops rows retried within a
+// minute or so. Rows BigQuery rejects are reported in the error.
+func (t *BigQueryTable) Insert(ctx context.Context, rows []map[string]bigquery.JsonValue) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	req := &bigquery.TableDataInsertAllRequest{}
+	for _, row := range rows {
+		req.Rows = append(req.Rows, &bigquery.TableDataInsertAllRequestRows{
+			InsertId: fmt.Sprint(row["account"], "/", row["id"]),
+			Json:     row,
+		})
+	}
+	resp, err := t.srv.Tabledata.InsertAll(t.project, t.dataset, t.table, req).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("BigQueryTable.Insert: %w", err)
+	}
+	if len(resp.InsertErrors) > 0 {
+		e := resp.InsertErrors[0]
+		msg := "unknown error"
+		if len(e.Errors) > 0 {
+			msg = e.Errors[0].Message
+		}
+		return fmt.Errorf("BigQueryTable.Insert: %d of %d rows rejected, first (message %v): %s", len(resp.InsertErrors), len(rows), rows[e.Index]["id"], msg)
+	}
+	return nil
+}
+
+// isNotFound reports whether err is an HTTP 404 from a Google API.
+func isNotFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
+
+// isConflict reports whether err is an HTTP 409 from a Google API, which
+// BigQuery returns when something being created already exists.
+func isConflict(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusConflict
+}
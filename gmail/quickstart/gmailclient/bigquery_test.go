@@ -0,0 +1,71 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+)
+
+func TestBigQueryRow(t *testing.T) {
+	msg, err := gmailclienttest.New().LoadMessage("testdata/multipart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := gmailclient.ParseMetadata(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := gmailclient.BigQueryRow("support@example.com", m, false)
+
+	columns := make(map[string]bool)
+	for _, f := range gmailclient.BigQuerySchema.Fields {
+		columns[f.Name] = true
+	}
+	for name := range row {
+		if !columns[name] {
+			t.Errorf("row has column %q, which is not in BigQuerySchema", name)
+		}
+	}
+	if got := row["date"]; got != "2021-05-03T10:00:00Z" {
+		t.Errorf("date = %v", got)
+	}
+	if got := row["sender_address"]; got != "hi@vimtricks.com" {
+		t.Errorf("sender_address = %v", got)
+	}
+	if got := row["to_addrs"]; got != "me@example.com" {
+		t.Errorf("to_addrs = %v", got)
+	}
+	if _, ok := row["body_plain"]; ok {
+		t.Error("row has body_plain without bodies")
+	}
+}
+
+func TestParseTableID(t *testing.T) {
+	for _, id := range []string{"prj.mail.messages", "prj:mail.messages"} {
+		p, d, tb, err := gmailclient.ParseTableID(id)
+		if err != nil || p != "prj" || d != "mail" || tb != "messages" {
+			t.Errorf("ParseTableID(%q) = %q, %q, %q, %v", id, p, d, tb, err)
+		}
+	}
+	if _, _, _, err := gmailclient.ParseTableID("mail.messages"); err == nil {
+		t.Error("ParseTableID accepted a table ID without a project")
+	}
+}
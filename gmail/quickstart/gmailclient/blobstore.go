@@ -0,0 +1,110 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// BlobStore is a content-addressed directory: every file is stored once,
+// named by the hex SHA-256 of its content under a subdirectory of the first
+// two hex digits, as in blobs/ab/abcdef....
+type BlobStore struct {
+	Path string
+}
+
+// OpenBlobStore returns the BlobStore at path, creating it if needed.
+func OpenBlobStore(path string) (*BlobStore, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("OpenBlobStore: %w", err)
+	}
+	return &BlobStore{Path: path}, nil
+}
+
+// BlobPath returns the path of the blob with the given hex SHA-256.
+func (s *BlobStore) BlobPath(sum string) string {
+	return filepath.Join(s.Path, sum[:2], sum)
+}
+
+// Store calls write to produce a file, hashing it as it is written, and
+// keeps it unless the store already has the same content. It returns the
+// hex SHA-256 and size of the content, and whether it was new. Concurrent
+// calls storing the same content are safe.
+func (s *BlobStore) Store(write func(w io.Writer) error) (sum string, size int64, isNew bool, err error) {
+	f, err := ioutil.TempFile(s.Path, ".blob.tmp*")
+	if err != nil {
+		return "", 0, false, fmt.Errorf("BlobStore.Store: %w", err)
+	}
+	defer os.Remove(f.Name())
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(f, h)}
+	err = write(cw)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", 0, false, fmt.Errorf("BlobStore.Store: %w", err)
+	}
+
+	sum = hex.EncodeToString(h.Sum(nil))
+	path := s.BlobPath(sum)
+	if _, err := os.Stat(path); err == nil {
+		return sum, cw.n, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, false, fmt.Errorf("BlobStore.Store: %w", err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return "", 0, false, fmt.Errorf("BlobStore.Store: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", 0, false, fmt.Errorf("BlobStore.Store: %w", err)
+	}
+	return sum, cw.n, true, nil
+}
+
+// Link makes the blob with the given hex SHA-256 appear at path as a hard
+// link, replacing any file there. It fails where hard links are not
+// supported, such as across file systems.
+func (s *BlobStore) Link(sum, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("BlobStore.Link: %w", err)
+	}
+	if err := os.Link(s.BlobPath(sum), path); err != nil {
+		return fmt.Errorf("BlobStore.Link: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
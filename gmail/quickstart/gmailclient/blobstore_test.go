@@ -0,0 +1,64 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestBlobStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := gmailclient.OpenBlobStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "abc")
+		return err
+	}
+	sum, size, isNew, err := s.Store(write)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if sum != want || size != 3 || !isNew {
+		t.Errorf("Store = %s, %d, %v; want %s, 3, true", sum, size, isNew, want)
+	}
+	if _, _, isNew, err = s.Store(write); err != nil || isNew {
+		t.Errorf("second Store: isNew = %v, err = %v; want false, nil", isNew, err)
+	}
+
+	link := filepath.Join(dir, "17a1", "abc.txt")
+	os.MkdirAll(filepath.Dir(link), 0755)
+	if err := s.Link(sum, link); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(link); err != nil || string(b) != "abc" {
+		t.Errorf("linked file = %q, %v", b, err)
+	}
+	entries, _ := ioutil.ReadDir(filepath.Join(dir, "blobs"))
+	if len(entries) != 1 || entries[0].Name() != want[:2] {
+		t.Errorf("blobs directory holds %d entries, want only %s", len(entries), want[:2])
+	}
+}
@@ -0,0 +1,247 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cache keeps fetched Gmail messages in a local SQLite database, so
+// that repeated runs do not fetch the same messages again.
+//
+// Wrap a GmailService with Wrap to make message fetches cache-aware:
+//
+//	c, err := cache.Open("messages.db")
+//	...
+//	srv := cache.Wrap(gmailclient.NewClient(gsrv), c, "me@example.com")
+//
+// Message contents never change, but labels do; callers that track changes
+// with gmailclient.Sync should Delete the messages it reports as deleted or
+// relabeled, and callers that change labels themselves should Delete the
+// messages, or DeleteThreads the threads, they changed.
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS messages (
+	account       TEXT NOT NULL,
+	id            TEXT NOT NULL,
+	thread_id     TEXT NOT NULL,
+	internal_date INTEGER NOT NULL,
+	labels        TEXT NOT NULL,
+	headers       TEXT NOT NULL,
+	message       TEXT NOT NULL,
+	fetched_at    INTEGER NOT NULL,
+	PRIMARY KEY (account, id)
+);
+CREATE INDEX IF NOT EXISTS messages_fetched_at ON messages (fetched_at);
+`
+
+// Cache is a message cache in a SQLite database, keyed by account and message
+// ID. It is safe for concurrent use.
+type Cache struct {
+	db *sql.DB
+}
+
+// Open opens the cache database at path, creating it if necessary.
+func Open(path string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("cache: create directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("cache: open %s: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cache: create schema in %s: %w", path, err)
+	}
+	return &Cache{db: db}, nil
+}
+
+// Close closes the database.
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Get returns the cached full message id of account, or nil if it is not
+// cached.
+func (c *Cache) Get(ctx context.Context, account, id string) (*gmail.Message, error) {
+	var b string
+	err := c.db.QueryRowContext(ctx, `SELECT message FROM messages WHERE account = ? AND id = ?`, account, id).Scan(&b)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cache: get %s: %w", id, err)
+	}
+	m := &gmail.Message{}
+	if err := json.Unmarshal([]byte(b), m); err != nil {
+		return nil, fmt.Errorf("cache: decode %s: %w", id, err)
+	}
+	return m, nil
+}
+
+// Put stores m, which must have been fetched in the "full" format.
+func (c *Cache) Put(ctx context.Context, account string, m *gmail.Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var headers []*gmail.MessagePartHeader
+	if m.Payload != nil {
+		headers = m.Payload.Headers
+	}
+	h, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, `INSERT OR REPLACE INTO messages
+		(account, id, thread_id, internal_date, labels, headers, message, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		account, m.Id, m.ThreadId, m.InternalDate, strings.Join(m.LabelIds, ","), string(h), string(b), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("cache: put %s: %w", m.Id, err)
+	}
+	return nil
+}
+
+// Delete removes the given messages of account from the cache.
+func (c *Cache) Delete(ctx context.Context, account string, ids ...string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE account = ? AND id = ?`, account, id); err != nil {
+			return fmt.Errorf("cache: delete %s: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteThreads removes the messages of the given threads of account from
+// the cache.
+func (c *Cache) DeleteThreads(ctx context.Context, account string, threadIDs ...string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range threadIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE account = ? AND thread_id = ?`, account, id); err != nil {
+			return fmt.Errorf("cache: delete thread %s: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Purge removes the messages fetched before olderThan, or all of them if it
+// is zero, and returns how many were removed. An empty account purges every
+// account.
+func (c *Cache) Purge(ctx context.Context, account string, olderThan time.Time) (int64, error) {
+	query := `DELETE FROM messages WHERE 1`
+	var args []interface{}
+	if account != "" {
+		query += ` AND account = ?`
+		args = append(args, account)
+	}
+	if !olderThan.IsZero() {
+		query += ` AND fetched_at < ?`
+		args = append(args, olderThan.Unix())
+	}
+	r, err := c.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("cache: purge: %w", err)
+	}
+	return r.RowsAffected()
+}
+
+// Each calls fn with every cached message of account, or of every account
+// if account is empty, in the order Gmail received them. It stops at the
+// first error fn returns.
+func (c *Cache) Each(ctx context.Context, account string, fn func(account string, m *gmail.Message) error) error {
+	query := `SELECT account, message FROM messages`
+	var args []interface{}
+	if account != "" {
+		query += ` WHERE account = ?`
+		args = append(args, account)
+	}
+	rows, err := c.db.QueryContext(ctx, query+` ORDER BY account, internal_date`, args...)
+	if err != nil {
+		return fmt.Errorf("cache: list: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var acct, b string
+		if err := rows.Scan(&acct, &b); err != nil {
+			return fmt.Errorf("cache: list: %w", err)
+		}
+		m := &gmail.Message{}
+		if err := json.Unmarshal([]byte(b), m); err != nil {
+			return fmt.Errorf("cache: decode: %w", err)
+		}
+		if err := fn(acct, m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Service is a GmailService that answers full message fetches from a Cache
+// and stores the messages it fetches there. Other calls go to the wrapped
+// service.
+type Service struct {
+	gmailclient.GmailService
+	Cache   *Cache
+	Account string
+}
+
+// Wrap returns srv made cache-aware for the messages of account.
+func Wrap(srv gmailclient.GmailService, c *Cache, account string) *Service {
+	return &Service{GmailService: srv, Cache: c, Account: account}
+}
+
+// GetMessage returns full messages from the cache when possible. Other formats
+// and partial fetches are passed through uncached.
+func (s *Service) GetMessage(ctx context.Context, user, id, format string, fields ...googleapi.Field) (*gmail.Message, error) {
+	if format != "full" || len(fields) > 0 {
+		return s.GmailService.GetMessage(ctx, user, id, format, fields...)
+	}
+	m, err := s.Cache.Get(ctx, s.Account, id)
+	if err != nil || m != nil {
+		return m, err
+	}
+	m, err = s.GmailService.GetMessage(ctx, user, id, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Cache.Put(ctx, s.Account, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
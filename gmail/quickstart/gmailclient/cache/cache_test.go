@@ -0,0 +1,114 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/cache"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// countingService counts the messages fetched from the wrapped service.
+type countingService struct {
+	gmailclient.GmailService
+	gets int
+}
+
+func (s *countingService) GetMessage(ctx context.Context, user, id, format string, fields ...googleapi.Field) (*gmail.Message, error) {
+	s.gets++
+	return s.GmailService.GetMessage(ctx, user, id, format, fields...)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	c, err := cache.Open(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	fake := gmailclienttest.New()
+	if _, err := fake.LoadMessage("../testdata/multipart.json"); err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingService{GmailService: fake}
+	srv := cache.Wrap(counting, c, "me@example.com")
+
+	for i := 0; i < 2; i++ {
+		m, err := srv.GetMessage(ctx, "me", "17a1", "full")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := gmailclient.FindHeader(m.Payload, "Subject"); got != "Weekly tips" {
+			t.Errorf("Subject = %q", got)
+		}
+	}
+	if counting.gets != 1 {
+		t.Errorf("fetched %d times, want once", counting.gets)
+	}
+
+	if _, err := srv.GetMessage(ctx, "me", "17a1", "metadata"); err != nil {
+		t.Fatal(err)
+	}
+	if counting.gets != 2 {
+		t.Errorf("metadata fetch was served from the cache")
+	}
+
+	fake.Messages[0].LabelIds = []string{"INBOX"}
+	for _, drop := range []func() error{
+		func() error { return c.Delete(ctx, "me@example.com", "17a1") },
+		func() error { return c.DeleteThreads(ctx, "me@example.com", fake.Messages[0].ThreadId) },
+	} {
+		if err := drop(); err != nil {
+			t.Fatal(err)
+		}
+		if m, err := c.Get(ctx, "me@example.com", "17a1"); err != nil || m != nil {
+			t.Errorf("Get after Delete = %v, %v; want a miss", m, err)
+		}
+		m, err := srv.GetMessage(ctx, "me", "17a1", "full")
+		if err != nil || len(m.LabelIds) != 1 || m.LabelIds[0] != "INBOX" {
+			t.Errorf("GetMessage after Delete = %v, %v; want the new labels", m, err)
+		}
+	}
+
+	var ids []string
+	err = c.Each(ctx, "", func(account string, m *gmail.Message) error {
+		ids = append(ids, account+"/"+m.Id)
+		return nil
+	})
+	if err != nil || len(ids) != 1 || ids[0] != "me@example.com/17a1" {
+		t.Errorf("Each = %v, %v; want [me@example.com/17a1]", ids, err)
+	}
+
+	if n, err := c.Purge(ctx, "", time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("Purge of old entries = %d, %v; want 0", n, err)
+	}
+	if n, err := c.Purge(ctx, "me@example.com", time.Time{}); err != nil || n != 1 {
+		t.Errorf("Purge = %d, %v; want 1", n, err)
+	}
+	if m, err := c.Get(ctx, "me@example.com", "17a1"); err != nil || m != nil {
+		t.Errorf("Get after Purge = %v, %v; want a miss", m, err)
+	}
+}
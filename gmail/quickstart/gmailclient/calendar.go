@@ -0,0 +1,110 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// CalendarScope is the scope OutOfOfficeEvents needs.
+const CalendarScope = calendar.CalendarEventsReadonlyScope
+
+// An Absence is a stretch of time the calendar owner is out of office.
+type Absence struct {
+	Summary    string
+	Start, End time.Time
+}
+
+// OutOfOfficeEvents returns the out-of-office events of calendar calendarID
+// that overlap from to to, in order of start. Recurring events are expanded.
+// client must be authorized for CalendarScope.
+func OutOfOfficeEvents(ctx context.Context, client *http.Client, calendarID string, from, to time.Time) ([]*Absence, error) {
+	srv, err := calendar.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("OutOfOfficeEvents create calendar client: %w", err)
+	}
+	var absences []*Absence
+	err = srv.Events.List(calendarID).
+		SingleEvents(true).
+		OrderBy("startTime").
+		TimeMin(from.Format(time.RFC3339)).
+		TimeMax(to.Format(time.RFC3339)).
+		Pages(ctx, func(r *calendar.Events) error {
+			for _, e := range r.Items {
+				if e.EventType != "outOfOffice" || e.Status == "cancelled" {
+					continue
+				}
+				start, err := eventTime(e.Start)
+				if err != nil {
+					return err
+				}
+				end, err := eventTime(e.End)
+				if err != nil {
+					return err
+				}
+				absences = append(absences, &Absence{Summary: e.Summary, Start: start, End: end})
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("OutOfOfficeEvents: %w", err)
+	}
+	return absences, nil
+}
+
+// eventTime returns the time of t; an all-day date is local midnight.
+func eventTime(t *calendar.EventDateTime) (time.Time, error) {
+	if t == nil {
+		return time.Time{}, fmt.Errorf("event without a time")
+	}
+	if t.DateTime != "" {
+		return time.Parse(time.RFC3339, t.DateTime)
+	}
+	return time.ParseInLocation("2006-01-02", t.Date, time.Local)
+}
+
+// NextAbsence returns the absence that is under way at now, or failing
+// that the next one, or nil if there is none. Absences that overlap or
+// follow on from one another, such as a week off and the weekend after
+// it, are joined into one, with the summary of the first.
+func NextAbsence(absences []*Absence, now time.Time) *Absence {
+	var next *Absence
+	for _, a := range absences {
+		switch {
+		case next != nil && !a.Start.After(next.End):
+			if a.End.After(next.End) {
+				next.End = a.End
+			}
+		case next != nil && next.End.After(now):
+			return next
+		default:
+			copy := *a
+			next = &copy
+		}
+	}
+	if next != nil && next.End.After(now) {
+		return next
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestNextAbsence(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 7, d, 0, 0, 0, 0, time.UTC) }
+	absences := []*gmailclient.Absence{
+		{Summary: "Conference", Start: day(1), End: day(3)},
+		{Summary: "Holiday", Start: day(8), End: day(13)},
+		{Summary: "Weekend", Start: day(13), End: day(15)},
+		{Summary: "Dentist", Start: day(20), End: day(21)},
+	}
+	tests := []struct {
+		now                time.Time
+		summary            string
+		wantStart, wantEnd time.Time
+	}{
+		{day(2), "Conference", day(1), day(3)},
+		{day(3), "Holiday", day(8), day(15)},
+		{day(14), "Holiday", day(8), day(15)},
+		{day(16), "Dentist", day(20), day(21)},
+	}
+	for _, tt := range tests {
+		a := gmailclient.NextAbsence(absences, tt.now)
+		if a == nil || a.Summary != tt.summary || !a.Start.Equal(tt.wantStart) || !a.End.Equal(tt.wantEnd) {
+			t.Errorf("NextAbsence(%v) = %+v", tt.now, a)
+		}
+	}
+	if a := gmailclient.NextAbsence(absences, day(22)); a != nil {
+		t.Errorf("NextAbsence after the last = %+v", a)
+	}
+	if absences[1].End != day(13) {
+		t.Error("NextAbsence changed its argument")
+	}
+}
@@ -0,0 +1,82 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"google.golang.org/api/gmail/v1"
+)
+
+// charsetReader returns a reader converting input from the named charset to
+// UTF-8. It knows every charset of the WHATWG Encoding Standard, which covers
+// the labels mail clients use in practice.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// DecodeHeader decodes the RFC 2047 encoded-words in a header value, such as
+// "=?UTF-8?B?...?=" or "=?ISO-8859-1?Q?...?=", to UTF-8. If the value is
+// malformed or uses an unknown charset it is returned unchanged.
+func DecodeHeader(value string) string {
+	s, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return s
+}
+
+// partCharset returns the charset parameter of part's Content-Type header,
+// or "" if there is none.
+func partCharset(part *gmail.MessagePart) string {
+	_, params, err := mime.ParseMediaType(FindHeader(part, "Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
+
+// toUTF8 converts data from charset to UTF-8. Data in UTF-8, US-ASCII, no
+// charset or a charset we do not know is returned as is.
+func toUTF8(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return string(data)
+	}
+	r, err := charsetReader(charset, bytes.NewReader(data))
+	if err != nil {
+		return string(data)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return string(data)
+	}
+	return string(b)
+}
@@ -0,0 +1,72 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestDecodeHeader(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"Weekly tips", "Weekly tips"},
+		{"=?UTF-8?B?xZtyb2RhIHdpZWN6b3JlbQ==?=", "środa wieczorem"},
+		{"=?ISO-8859-1?Q?Caf=E9_cr=E8me?= <cafe@example.com>", "Café crème <cafe@example.com>"},
+		{"=?windows-1251?B?z/Do4uXy?=", "Привет"},
+		{"=?UTF-8?Q?a?= =?UTF-8?Q?b?=", "ab"},
+		{"=?x-unknown?Q?raw?=", "=?x-unknown?Q?raw?="},
+	} {
+		if got := gmailclient.DecodeHeader(tt.in); got != tt.want {
+			t.Errorf("DecodeHeader(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetMessagePartDataCharset(t *testing.T) {
+	for _, tt := range []struct {
+		contentType string
+		data        []byte
+		want        string
+	}{
+		{`text/plain; charset="UTF-8"`, []byte("Grüße"), "Grüße"},
+		{`text/plain; charset=ISO-8859-1`, []byte("Gr\xfc\xdfe"), "Grüße"},
+		{`text/plain; charset=windows-1251`, []byte("\xcf\xf0\xe8\xe2\xe5\xf2"), "Привет"},
+		{`text/plain; charset=Shift_JIS`, []byte("\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd"), "こんにちは"},
+		{`text/plain; charset=x-unknown`, []byte("raw"), "raw"},
+	} {
+		part := &gmail.MessagePart{
+			MimeType: "text/plain",
+			Headers:  []*gmail.MessagePartHeader{{Name: "Content-Type", Value: tt.contentType}},
+			Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString(tt.data)},
+		}
+		got, err := gmailclient.GetMessagePartData(context.Background(), gmailclienttest.New(), "me", "1", part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+)
+
+// A Checkpoint records the progress of a long run over a MessageIterator so
+// that it can be resumed after a crash: the query, the page the iterator was
+// on and the messages of that page already processed. Earlier pages are not
+// remembered, since resuming starts at PageToken.
+type Checkpoint struct {
+	Query     string   `json:"query"`
+	PageToken string   `json:"page_token"`
+	Done      []string `json:"done"`
+
+	done map[string]bool
+}
+
+// LoadCheckpoint reads the checkpoint at path. The error satisfies
+// os.IsNotExist if there is none.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("LoadCheckpoint decode %s: %w", path, err)
+	}
+	c.done = map[string]bool{}
+	for _, id := range c.Done {
+		c.done[id] = true
+	}
+	return c, nil
+}
+
+// IsDone reports whether the message id was marked done.
+func (c *Checkpoint) IsDone(id string) bool {
+	return c.done[id]
+}
+
+// MarkDone records that message id, listed on the page with pageToken, has
+// been processed. Moving to a new page forgets the messages of the old one.
+func (c *Checkpoint) MarkDone(pageToken, id string) {
+	if pageToken != c.PageToken || c.done == nil {
+		c.PageToken = pageToken
+		c.Done = nil
+		c.done = map[string]bool{}
+	}
+	c.Done = append(c.Done, id)
+	c.done[id] = true
+}
+
+// Save writes the checkpoint to path, replacing it atomically.
+func (c *Checkpoint) Save(path string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, b, 0600)
+}
+
+// RemoveCheckpoint deletes the checkpoint at path once a run has completed.
+// A missing checkpoint is not an error.
+func RemoveCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
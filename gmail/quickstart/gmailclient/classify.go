@@ -0,0 +1,86 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SenderDomain returns the lowercased domain of the first From address of
+// m, or "" if it has none. Unless full is set, subdomains are dropped, so
+// that mail from mail.example.co.uk and example.co.uk both yield
+// example.co.uk.
+func SenderDomain(m *Message, full bool) string {
+	if len(m.From) == 0 {
+		return ""
+	}
+	addr := m.From[0].Address
+	i := strings.LastIndex(addr, "@")
+	if i < 0 {
+		return ""
+	}
+	domain := strings.TrimSuffix(strings.ToLower(addr[i+1:]), ".")
+	if full {
+		return domain
+	}
+	if d, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		return d
+	}
+	return domain
+}
+
+// A SubjectRule labels the messages whose subject matches Pattern.
+type SubjectRule struct {
+	Pattern *regexp.Regexp
+
+	// Label is the label name, in which $1 or ${name} stand for the
+	// pattern's submatches, as in regexp.Regexp.Expand.
+	Label string
+}
+
+// ParseSubjectRule parses a rule written as pattern=label. The label is
+// after the last "=", so patterns may contain "=" but labels may not.
+func ParseSubjectRule(s string) (*SubjectRule, error) {
+	i := strings.LastIndex(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return nil, fmt.Errorf("ParseSubjectRule: %q is not pattern=label", s)
+	}
+	re, err := regexp.Compile(s[:i])
+	if err != nil {
+		return nil, fmt.Errorf("ParseSubjectRule: %w", err)
+	}
+	return &SubjectRule{Pattern: re, Label: s[i+1:]}, nil
+}
+
+// ClassifySubject returns the label of the first rule matching subject,
+// with the submatches filled in, or "" if none matches.
+func ClassifySubject(rules []*SubjectRule, subject string) string {
+	for _, r := range rules {
+		match := r.Pattern.FindStringSubmatchIndex(subject)
+		if match == nil {
+			continue
+		}
+		label := r.Pattern.ExpandString(nil, r.Label, subject, match)
+		return strings.TrimSpace(string(label))
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"net/mail"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestSenderDomain(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		full bool
+		want string
+	}{
+		{"news@Mail.Example.co.uk", false, "example.co.uk"},
+		{"news@Mail.Example.co.uk", true, "mail.example.co.uk"},
+		{"noreply@github.com", false, "github.com"},
+		{"root@localhost", false, "localhost"},
+		{"undisclosed", false, ""},
+	} {
+		m := &gmailclient.Message{From: []*mail.Address{{Address: tt.addr}}}
+		if got := gmailclient.SenderDomain(m, tt.full); got != tt.want {
+			t.Errorf("SenderDomain(%q, %v) = %q, want %q", tt.addr, tt.full, got, tt.want)
+		}
+	}
+	if got := gmailclient.SenderDomain(&gmailclient.Message{}, false); got != "" {
+		t.Errorf("SenderDomain without From = %q", got)
+	}
+}
+
+func TestClassifySubject(t *testing.T) {
+	var rules []*gmailclient.SubjectRule
+	for _, s := range []string{`(?i)^\[JIRA\] \(([A-Z]+)-\d+\)=Tickets/$1`, `(?i)invoice|receipt=Receipts`} {
+		r, err := gmailclient.ParseSubjectRule(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, r)
+	}
+	for subject, want := range map[string]string{
+		"[JIRA] (OPS-142) Disk full": "Tickets/OPS",
+		"Your receipt from Example":  "Receipts",
+		"Lunch?":                     "",
+	} {
+		if got := gmailclient.ClassifySubject(rules, subject); got != want {
+			t.Errorf("ClassifySubject(%q) = %q, want %q", subject, got, want)
+		}
+	}
+	for _, s := range []string{"no-label", "=Label", "pattern=", "(=Label"} {
+		if _, err := gmailclient.ParseSubjectRule(s); err == nil {
+			t.Errorf("ParseSubjectRule(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gmailclient contains the reusable parts of the Gmail quickstart:
+// OAuth client construction, token caching and message parsing.
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// ConfigFromFile reads the client secret stored in credentialsFile and returns
+// the OAuth2 config for the given scopes.
+func ConfigFromFile(credentialsFile string, scopes ...string) (*oauth2.Config, error) {
+	b, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("ConfigFromFile read client secret file: %w", err)
+	}
+
+	config, err := google.ConfigFromJSON(b, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("ConfigFromFile parse client secret file: %w", err)
+	}
+	return config, nil
+}
+
+// NewService builds a Gmail service from the client secret stored in
+// credentialsFile, caching the user's token in store. flow is used to
+// authorize the user when store is empty; nil means GetTokenFromLoopback.
+// Calls are throttled to DefaultQuota and retried according to
+// DefaultRetryPolicy.
+func NewService(ctx context.Context, credentialsFile string, store TokenStore, flow AuthFlow, scopes ...string) (*gmail.Service, error) {
+	config, err := ConfigFromFile(credentialsFile, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	client, err := GetClient(ctx, config, store, AuthOptions{Flow: flow})
+	if err != nil {
+		return nil, err
+	}
+	return NewServiceFromClient(ctx, withDefaults(client))
+}
+
+// NewServiceFromClient builds a Gmail service that issues its calls through
+// client, which must already be authorized.
+func NewServiceFromClient(ctx context.Context, client *http.Client) (*gmail.Service, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("NewServiceFromClient create gmail client: %w", err)
+	}
+	return srv, nil
+}
+
+// withDefaults adds the default rate limit and retries to client.
+func withDefaults(client *http.Client) *http.Client {
+	return WithRetry(WithRateLimit(client, DefaultQuota), DefaultRetryPolicy)
+}
+
+// ServiceAccountClient returns an HTTP client authorized as the service
+// account in keyFile impersonating subject, which requires domain-wide
+// delegation to be granted to the service account for scopes.
+func ServiceAccountClient(ctx context.Context, keyFile, subject string, scopes ...string) (*http.Client, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("ServiceAccountClient read key file: %w", err)
+	}
+	config, err := google.JWTConfigFromJSON(b, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("ServiceAccountClient parse key file: %w", err)
+	}
+	config.Subject = subject
+	return config.Client(ctx), nil
+}
+
+// NewServiceAccountService builds a Gmail service for subject's mailbox using
+// the service account key in keyFile.
+func NewServiceAccountService(ctx context.Context, keyFile, subject string, scopes ...string) (*gmail.Service, error) {
+	client, err := ServiceAccountClient(ctx, keyFile, subject, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	return NewServiceFromClient(ctx, withDefaults(client))
+}
+
+// DefaultClient returns an HTTP client authorized with Application Default
+// Credentials: the key file named by $GOOGLE_APPLICATION_CREDENTIALS, the
+// gcloud user credentials, or the metadata server on GCE, GKE and Cloud Run.
+// If subject is set the credentials must be a service account key, which then
+// impersonates subject with domain-wide delegation.
+func DefaultClient(ctx context.Context, subject string, scopes ...string) (*http.Client, error) {
+	creds, err := google.FindDefaultCredentials(ctx, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("DefaultClient find credentials: %w", err)
+	}
+	if subject == "" {
+		return oauth2.NewClient(ctx, creds.TokenSource), nil
+	}
+	if creds.JSON == nil {
+		return nil, fmt.Errorf("DefaultClient: impersonating %s needs a service account key in $GOOGLE_APPLICATION_CREDENTIALS", subject)
+	}
+	config, err := google.JWTConfigFromJSON(creds.JSON, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("DefaultClient parse service account key: %w", err)
+	}
+	config.Subject = subject
+	return config.Client(ctx), nil
+}
+
+// NewDefaultService builds a Gmail service from Application Default
+// Credentials; see DefaultClient.
+func NewDefaultService(ctx context.Context, subject string, scopes ...string) (*gmail.Service, error) {
+	client, err := DefaultClient(ctx, subject, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	return NewServiceFromClient(ctx, withDefaults(client))
+}
+
+// ExpandScopes turns a comma-separated list of scopes into full scope URLs.
+// Entries that are not URLs are taken as short names relative to
+// https://www.googleapis.com/auth/, so "gmail.readonly" and
+// gmail.GmailReadonlyScope are equivalent.
+func ExpandScopes(list string) []string {
+	var scopes []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.HasPrefix(s, "https://") {
+			s = "https://www.googleapis.com/auth/" + s
+		}
+		scopes = append(scopes, s)
+	}
+	return scopes
+}
@@ -0,0 +1,491 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1" // for verifying older signatures
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// This file implements the part of CMS (RFC 5652) that S/MIME signatures
+// need: detached SignedData with one signer, signed attributes and the
+// signer's certificates.
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// digestAlgorithms maps the digest OIDs CMS signatures use to hashes.
+var digestAlgorithms = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{oidSHA1, crypto.SHA1},
+	{oidSHA256, crypto.SHA256},
+	{oidSHA384, crypto.SHA384},
+	{oidSHA512, crypto.SHA512},
+}
+
+// DER building blocks.
+
+func derElement(class, tag int, compound bool, content ...[]byte) []byte {
+	b, err := asn1.Marshal(asn1.RawValue{Class: class, Tag: tag, IsCompound: compound, Bytes: bytes.Join(content, nil)})
+	if err != nil {
+		panic(err) // only fails for invalid tags
+	}
+	return b
+}
+
+func derSequence(elems ...[]byte) []byte {
+	return derElement(asn1.ClassUniversal, asn1.TagSequence, true, elems...)
+}
+
+// derSet encodes a SET OF, sorting the elements as DER requires.
+func derSet(elems ...[]byte) []byte {
+	sorted := append([][]byte(nil), elems...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+	return derElement(asn1.ClassUniversal, asn1.TagSet, true, sorted...)
+}
+
+func derMarshal(v interface{}) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		panic(err) // only called with values asn1 can encode
+	}
+	return b
+}
+
+func derAlgorithm(oid asn1.ObjectIdentifier, nullParams bool) []byte {
+	if nullParams {
+		return derSequence(derMarshal(oid), asn1.NullBytes)
+	}
+	return derSequence(derMarshal(oid))
+}
+
+func derAttribute(oid asn1.ObjectIdentifier, value []byte) []byte {
+	return derSequence(derMarshal(oid), derSet(value))
+}
+
+// signCMS returns a detached CMS SignedData over content, signed by key
+// with SHA-256, carrying cert and chain.
+func signCMS(content []byte, cert *x509.Certificate, chain []*x509.Certificate, key crypto.Signer, now time.Time) ([]byte, error) {
+	var sigAlg []byte
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = derAlgorithm(oidRSAEncryption, true)
+	case *ecdsa.PublicKey:
+		sigAlg = derAlgorithm(oidECDSAWithSHA256, false)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.Public())
+	}
+	digest := crypto.SHA256.New()
+	digest.Write(content)
+	attrs := derSet(
+		derAttribute(oidContentType, derMarshal(oidData)),
+		derAttribute(oidSigningTime, derMarshal(now.UTC())),
+		derAttribute(oidMessageDigest, derMarshal(digest.Sum(nil))),
+	)
+	// The signature covers the attributes encoded as a SET; the SignerInfo
+	// carries them with the implicit tag [0] instead.
+	h := crypto.SHA256.New()
+	h.Write(attrs)
+	sig, err := key.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	signedAttrs := append([]byte{0xa0}, attrs[1:]...)
+
+	signerInfo := derSequence(
+		derMarshal(1),
+		derSequence(cert.RawIssuer, derMarshal(cert.SerialNumber)),
+		derAlgorithm(oidSHA256, true),
+		signedAttrs,
+		sigAlg,
+		derMarshal(sig),
+	)
+	certs := [][]byte{cert.Raw}
+	for _, c := range chain {
+		certs = append(certs, c.Raw)
+	}
+	signedData := derSequence(
+		derMarshal(1),
+		derSet(derAlgorithm(oidSHA256, true)),
+		derSequence(derMarshal(oidData)),
+		derElement(asn1.ClassContextSpecific, 0, true, certs...),
+		derSet(signerInfo),
+	)
+	return derSequence(
+		derMarshal(oidSignedData),
+		derElement(asn1.ClassContextSpecific, 0, true, signedData),
+	), nil
+}
+
+// A cmsSignature is a parsed detached SignedData.
+type cmsSignature struct {
+	certs   []*x509.Certificate
+	signers []*cmsSigner
+}
+
+// A cmsSigner is a parsed SignerInfo. The signer's certificate is named by
+// issuer and serial number, or by subjectKeyID.
+type cmsSigner struct {
+	issuer       []byte // DER Name
+	serial       *big.Int
+	subjectKeyID []byte
+	digest       crypto.Hash
+	signedAttrs  []byte // re-tagged as a SET, nil if absent
+	signature    []byte
+}
+
+// errCMS reports malformed CMS data.
+var errCMS = errors.New("malformed CMS signature")
+
+// rawElements returns the elements inside the constructed value b.
+func rawElements(b []byte) ([]asn1.RawValue, error) {
+	var elems []asn1.RawValue
+	for len(b) > 0 {
+		var v asn1.RawValue
+		rest, err := asn1.Unmarshal(b, &v)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v)
+		b = rest
+	}
+	return elems, nil
+}
+
+// parseCMS parses a SignedData ContentInfo, BER or DER encoded.
+func parseCMS(b []byte) (*cmsSignature, error) {
+	der, err := berToDER(b)
+	if err != nil {
+		return nil, err
+	}
+	var ci struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue // [0] EXPLICIT SignedData
+	}
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCMS, err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: content type %v is not signed data", errCMS, ci.ContentType)
+	}
+	var sd asn1.RawValue
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, errCMS
+	}
+	fields, err := rawElements(sd.Bytes)
+	if err != nil || len(fields) < 4 {
+		return nil, errCMS
+	}
+	s := &cmsSignature{}
+	// version, digestAlgorithms, encapContentInfo, then the optional
+	// [0] certificates and [1] crls, and the signerInfos.
+	for _, f := range fields[3 : len(fields)-1] {
+		if f.Class != asn1.ClassContextSpecific || f.Tag != 0 {
+			continue
+		}
+		certs, err := rawElements(f.Bytes)
+		if err != nil {
+			return nil, errCMS
+		}
+		for _, c := range certs {
+			if cert, err := x509.ParseCertificate(c.FullBytes); err == nil {
+				s.certs = append(s.certs, cert)
+			}
+		}
+	}
+	infos, err := rawElements(fields[len(fields)-1].Bytes)
+	if err != nil {
+		return nil, errCMS
+	}
+	for _, info := range infos {
+		signer, err := parseSignerInfo(info.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		s.signers = append(s.signers, signer)
+	}
+	if len(s.signers) == 0 {
+		return nil, fmt.Errorf("%w: no signers", errCMS)
+	}
+	return s, nil
+}
+
+func parseSignerInfo(b []byte) (*cmsSigner, error) {
+	fields, err := rawElements(b)
+	if err != nil || len(fields) < 5 {
+		return nil, errCMS
+	}
+	s := &cmsSigner{}
+	sid := fields[1]
+	switch {
+	case sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence:
+		ias, err := rawElements(sid.Bytes)
+		if err != nil || len(ias) != 2 {
+			return nil, errCMS
+		}
+		s.issuer = ias[0].FullBytes
+		if _, err := asn1.Unmarshal(ias[1].FullBytes, &s.serial); err != nil {
+			return nil, errCMS
+		}
+	case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0:
+		s.subjectKeyID = sid.Bytes
+	default:
+		return nil, errCMS
+	}
+	var digestAlg struct {
+		Algorithm asn1.ObjectIdentifier
+		Params    asn1.RawValue `asn1:"optional"`
+	}
+	if _, err := asn1.Unmarshal(fields[2].FullBytes, &digestAlg); err != nil {
+		return nil, errCMS
+	}
+	for _, a := range digestAlgorithms {
+		if a.oid.Equal(digestAlg.Algorithm) {
+			s.digest = a.hash
+		}
+	}
+	if s.digest == 0 {
+		return nil, fmt.Errorf("unsupported digest algorithm %v", digestAlg.Algorithm)
+	}
+	rest := fields[3:]
+	if rest[0].Class == asn1.ClassContextSpecific && rest[0].Tag == 0 {
+		s.signedAttrs = append([]byte{0x31}, rest[0].FullBytes[1:]...)
+		rest = rest[1:]
+	}
+	if len(rest) < 2 {
+		return nil, errCMS
+	}
+	if _, err := asn1.Unmarshal(rest[1].FullBytes, &s.signature); err != nil {
+		return nil, errCMS
+	}
+	return s, nil
+}
+
+// certificate returns the certificate of the signer among certs.
+func (s *cmsSigner) certificate(certs []*x509.Certificate) *x509.Certificate {
+	for _, c := range certs {
+		if s.subjectKeyID != nil {
+			if bytes.Equal(c.SubjectKeyId, s.subjectKeyID) {
+				return c
+			}
+		} else if bytes.Equal(c.RawIssuer, s.issuer) && c.SerialNumber.Cmp(s.serial) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// verify checks the signature of s over content with cert, and returns the
+// signing time from the signed attributes, if any.
+func (s *cmsSigner) verify(content []byte, cert *x509.Certificate) (time.Time, error) {
+	h := s.digest.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+	signed := content
+	var signingTime time.Time
+	if s.signedAttrs != nil {
+		var set asn1.RawValue
+		if _, err := asn1.Unmarshal(s.signedAttrs, &set); err != nil {
+			return signingTime, errCMS
+		}
+		attrs, err := rawElements(set.Bytes)
+		if err != nil {
+			return signingTime, errCMS
+		}
+		var found bool
+		for _, a := range attrs {
+			var attr struct {
+				Type   asn1.ObjectIdentifier
+				Values asn1.RawValue
+			}
+			if _, err := asn1.Unmarshal(a.FullBytes, &attr); err != nil {
+				return signingTime, errCMS
+			}
+			values, err := rawElements(attr.Values.Bytes)
+			if err != nil || len(values) == 0 {
+				return signingTime, errCMS
+			}
+			switch {
+			case attr.Type.Equal(oidMessageDigest):
+				var md []byte
+				if _, err := asn1.Unmarshal(values[0].FullBytes, &md); err != nil {
+					return signingTime, errCMS
+				}
+				if !bytes.Equal(md, digest) {
+					return signingTime, errors.New("the message was changed after it was signed")
+				}
+				found = true
+			case attr.Type.Equal(oidSigningTime):
+				asn1.Unmarshal(values[0].FullBytes, &signingTime)
+			}
+		}
+		if !found {
+			return signingTime, fmt.Errorf("%w: no message digest", errCMS)
+		}
+		signed = s.signedAttrs
+	}
+	var alg x509.SignatureAlgorithm
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		alg = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA1: x509.SHA1WithRSA, crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA,
+		}[s.digest]
+	case x509.ECDSA:
+		alg = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA1: x509.ECDSAWithSHA1, crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384, crypto.SHA512: x509.ECDSAWithSHA512,
+		}[s.digest]
+	default:
+		return signingTime, fmt.Errorf("unsupported signing key %v", cert.PublicKeyAlgorithm)
+	}
+	if err := cert.CheckSignature(alg, signed, s.signature); err != nil {
+		return signingTime, fmt.Errorf("bad signature: %w", err)
+	}
+	return signingTime, nil
+}
+
+// berToDER re-encodes the indefinite lengths that BER allows, and some
+// S/MIME clients use, as definite lengths so encoding/asn1 can parse b.
+// Values are left as they are otherwise.
+func berToDER(b []byte) ([]byte, error) {
+	out, rest, err := berElement(b, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCMS, err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", errCMS)
+	}
+	return out, nil
+}
+
+// berElement converts the element at the start of b and returns it with the
+// bytes after it.
+func berElement(b []byte, depth int) ([]byte, []byte, error) {
+	if depth > 50 {
+		return nil, nil, errors.New("nested too deeply")
+	}
+	if len(b) < 2 {
+		return nil, nil, errors.New("truncated")
+	}
+	i := 1
+	if b[0]&0x1f == 0x1f { // high tag number form
+		for i < len(b) && b[i]&0x80 != 0 {
+			i++
+		}
+		i++
+	}
+	if i >= len(b) {
+		return nil, nil, errors.New("truncated")
+	}
+	tag := b[:i]
+	compound := b[0]&0x20 != 0
+	l := int(b[i])
+	i++
+	if l == 0x80 { // indefinite length: elements up to an end-of-contents
+		if !compound {
+			return nil, nil, errors.New("indefinite length of a primitive value")
+		}
+		var content []byte
+		rest := b[i:]
+		for {
+			if len(rest) < 2 {
+				return nil, nil, errors.New("truncated")
+			}
+			if rest[0] == 0 && rest[1] == 0 {
+				rest = rest[2:]
+				break
+			}
+			elem, r, err := berElement(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			content = append(content, elem...)
+			rest = r
+		}
+		return append(append(append([]byte(nil), tag...), derLength(len(content))...), content...), rest, nil
+	}
+	if l&0x80 != 0 {
+		n := l & 0x7f
+		if n > 4 || i+n > len(b) {
+			return nil, nil, errors.New("bad length")
+		}
+		l = 0
+		for _, c := range b[i : i+n] {
+			l = l<<8 | int(c)
+		}
+		i += n
+	}
+	if l < 0 || i+l > len(b) {
+		return nil, nil, errors.New("truncated")
+	}
+	content := b[i : i+l]
+	if compound {
+		var conv []byte
+		for rest := content; len(rest) > 0; {
+			elem, r, err := berElement(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			conv = append(conv, elem...)
+			rest = r
+		}
+		content = conv
+	}
+	return append(append(append([]byte(nil), tag...), derLength(len(content))...), content...), b[i+l:], nil
+}
+
+// derLength encodes a DER length.
+func derLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
@@ -0,0 +1,380 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Limits Gmail sets on outgoing mail. Attachments count towards
+// MaxAttachmentSize before encoding; the encoded message must stay within
+// MaxMessageSize.
+const (
+	MaxAttachmentSize = 25 << 20
+	MaxMessageSize    = 35 << 20
+)
+
+// OutgoingAttachment is a file attached to an OutgoingMessage.
+type OutgoingAttachment struct {
+	Filename string
+	MimeType string // empty to guess from the filename extension
+	Data     []byte
+}
+
+// AttachFile reads the file at path for attaching under its base name.
+func AttachFile(path string) (*OutgoingAttachment, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("AttachFile: %w", err)
+	}
+	return &OutgoingAttachment{Filename: filepath.Base(path), Data: data}, nil
+}
+
+// OutgoingMessage is a message to send. With both Text and HTML set the
+// bodies are sent as multipart/alternative, so the recipient's client picks
+// one; attachments put the body and the files in a multipart/mixed.
+type OutgoingMessage struct {
+	From    *mail.Address // nil lets Gmail fill in the account's address
+	To      []*mail.Address
+	Cc      []*mail.Address
+	Bcc     []*mail.Address // Gmail delivers to them and drops the header
+	ReplyTo []*mail.Address
+	Subject string
+	Text    string
+	HTML    string
+
+	// Date is the Date header; zero means the time Bytes is called.
+	Date time.Time
+
+	// Header holds extra header fields, such as In-Reply-To. Values are
+	// encoded as RFC 2047 words if they are not ASCII.
+	Header Header
+
+	Attachments []*OutgoingAttachment
+
+	// Wrapper, if not nil, wraps the content of the message, the body with
+	// the attachments, before it is sent; PGP uses it to sign and encrypt
+	// messages.
+	Wrapper Wrapper
+}
+
+// A Wrapper transforms the content of a message: it receives the MIME
+// header and the encoded body of the content, and returns those of the
+// entity to send instead, such as a multipart/signed or multipart/encrypted
+// holding the original. The header holds Content-Type and possibly
+// Content-Transfer-Encoding.
+type Wrapper interface {
+	Wrap(header textproto.MIMEHeader, body []byte) (textproto.MIMEHeader, []byte, error)
+}
+
+// Bytes returns m as an RFC 5322 message with CRLF line endings. Bodies are
+// UTF-8, quoted-printable encoded, and attachments base64 encoded. It returns
+// ErrNoRecipients if m has no To, Cc or Bcc address, an error wrapping
+// ErrInvalidAddress for an address net/mail cannot parse or holding a line
+// break, and an error wrapping ErrMessageTooLarge if it exceeds
+// MaxAttachmentSize or MaxMessageSize.
+func (m *OutgoingMessage) Bytes() ([]byte, error) {
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return nil, ErrNoRecipients
+	}
+	if err := m.checkAddresses(); err != nil {
+		return nil, err
+	}
+	var size int64
+	for _, a := range m.Attachments {
+		size += int64(len(a.Data))
+	}
+	if size > MaxAttachmentSize {
+		return nil, fmt.Errorf("%w: attachments total %d bytes, over the limit of %d", ErrMessageTooLarge, size, MaxAttachmentSize)
+	}
+	var b bytes.Buffer
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	if m.From != nil {
+		writeAddressHeader(&b, "From", []*mail.Address{m.From})
+	}
+	writeAddressHeader(&b, "To", m.To)
+	writeAddressHeader(&b, "Cc", m.Cc)
+	writeAddressHeader(&b, "Bcc", m.Bcc)
+	writeAddressHeader(&b, "Reply-To", m.ReplyTo)
+	writeHeader(&b, "Subject", encodeHeaderValue(m.Subject))
+	writeHeader(&b, "Date", date.Format(time.RFC1123Z))
+	for _, f := range m.Header {
+		writeHeader(&b, f.Name, encodeHeaderValue(f.Value))
+	}
+	writeHeader(&b, "MIME-Version", "1.0")
+	h, body, err := m.content()
+	if err == nil && m.Wrapper != nil {
+		h, body, err = m.Wrapper.Wrap(h, body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("OutgoingMessage.Bytes: %w", err)
+	}
+	writeEntity(&b, h, body)
+	if b.Len() > MaxMessageSize {
+		return nil, fmt.Errorf("%w: %d bytes encoded, over the limit of %d", ErrMessageTooLarge, b.Len(), MaxMessageSize)
+	}
+	return b.Bytes(), nil
+}
+
+// checkAddresses makes sure every address of m can be written to its header
+// field as it is. writeAddressHeader writes bare addresses unquoted, so a
+// line break in one would start a new field, such as a Bcc the sender never
+// asked for.
+func (m *OutgoingMessage) checkAddresses() error {
+	lists := [][]*mail.Address{m.To, m.Cc, m.Bcc, m.ReplyTo}
+	if m.From != nil {
+		lists = append(lists, []*mail.Address{m.From})
+	}
+	for _, list := range lists {
+		for _, a := range list {
+			if strings.ContainsAny(a.Name+a.Address, "\r\n") {
+				return fmt.Errorf("%w: %q contains a line break", ErrInvalidAddress, a.Address)
+			}
+			if _, err := mail.ParseAddress(a.Address); err != nil {
+				return fmt.Errorf("%w: %q: %v", ErrInvalidAddress, a.Address, err)
+			}
+		}
+	}
+	return nil
+}
+
+// content returns the header and encoded content of the message: the body
+// and the attachments as a multipart/mixed, or just the body if there are no
+// attachments.
+func (m *OutgoingMessage) content() (textproto.MIMEHeader, []byte, error) {
+	h, body, err := m.bodyPart()
+	if err != nil || len(m.Attachments) == 0 {
+		return h, body, err
+	}
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	pw, err := w.CreatePart(h)
+	if err != nil {
+		return nil, nil, err
+	}
+	pw.Write(body)
+	for _, a := range m.Attachments {
+		if err := writeAttachmentPart(w, a); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	mh := textproto.MIMEHeader{}
+	mh.Set("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": w.Boundary()}))
+	return mh, b.Bytes(), nil
+}
+
+// writeEntity writes the MIME header h, the blank line ending it and body.
+// Content-Type and Content-Transfer-Encoding come first, the rest of the
+// fields in sorted order, so that the output is the same every time; signed
+// entities depend on it.
+func writeEntity(b *bytes.Buffer, h textproto.MIMEHeader, body []byte) {
+	first := []string{"Content-Type", "Content-Transfer-Encoding"}
+	for _, k := range first {
+		for _, v := range h[k] {
+			writeHeader(b, k, v)
+		}
+	}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		if k != first[0] && k != first[1] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			writeHeader(b, k, v)
+		}
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+}
+
+// bodyPart returns the header and encoded content of the body: a text part,
+// or a multipart/alternative of both bodies.
+func (m *OutgoingMessage) bodyPart() (textproto.MIMEHeader, []byte, error) {
+	var b bytes.Buffer
+	if m.Text == "" || m.HTML == "" {
+		mimeType, body := "text/plain", m.Text
+		if m.HTML != "" {
+			mimeType, body = "text/html", m.HTML
+		}
+		err := writeQuotedPrintable(&b, body)
+		return textHeader(mimeType), b.Bytes(), err
+	}
+	w := multipart.NewWriter(&b)
+	if err := writeAlternatives(w, m.Text, m.HTML); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": w.Boundary()}))
+	return h, b.Bytes(), nil
+}
+
+// writeAttachmentPart adds a to w, base64 encoded in lines of 76
+// characters. Attached messages (message/rfc822) cannot be encoded, so they
+// are written as they are, with CRLF line endings.
+func writeAttachmentPart(w *multipart.Writer, a *OutgoingAttachment) error {
+	mimeType := a.MimeType
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(path.Ext(a.Filename))
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	name := a.Filename
+	if name == "" {
+		name = "attachment"
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType(mimeType, map[string]string{"name": name}))
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	if strings.EqualFold(mimeType, "message/rfc822") {
+		data := bytes.Replace(a.Data, []byte("\r\n"), []byte("\n"), -1)
+		data = bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+		h.Set("Content-Transfer-Encoding", "7bit")
+		for _, c := range data {
+			if c >= 0x80 {
+				h.Set("Content-Transfer-Encoding", "8bit")
+				break
+			}
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		_, err = pw.Write(data)
+		return err
+	}
+	h.Set("Content-Transfer-Encoding", "base64")
+	pw, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	enc := base64.StdEncoding.EncodeToString(a.Data)
+	for len(enc) > 76 {
+		io.WriteString(pw, enc[:76]+"\r\n")
+		enc = enc[76:]
+	}
+	_, err = io.WriteString(pw, enc+"\r\n")
+	return err
+}
+
+// writeAlternatives adds the plain-text and HTML bodies to w, in that
+// order, from the plainest to the richest.
+func writeAlternatives(w *multipart.Writer, text, html string) error {
+	for _, alt := range []struct{ mimeType, body string }{{"text/plain", text}, {"text/html", html}} {
+		pw, err := w.CreatePart(textHeader(alt.mimeType))
+		if err != nil {
+			return err
+		}
+		if err := writeQuotedPrintable(pw, alt.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// textHeader returns the header of a UTF-8, quoted-printable text part.
+func textHeader(mimeType string) textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType(mimeType, map[string]string{"charset": "utf-8"}))
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	return h
+}
+
+// writeQuotedPrintable writes body quoted-printable encoded, with CRLF line
+// endings.
+func writeQuotedPrintable(w io.Writer, body string) error {
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	body = strings.Replace(body, "\n", "\r\n", -1)
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeHeader writes one header field. Empty values are left out.
+func writeHeader(b *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(b, "%s: %s\r\n", name, value)
+}
+
+// writeAddressHeader writes an address list, one address per line after the
+// first so long lists stay within the line length limit.
+func writeAddressHeader(b *bytes.Buffer, name string, list []*mail.Address) {
+	s := make([]string, len(list))
+	for i, a := range list {
+		s[i] = a.String()
+		if a.Name == "" {
+			s[i] = a.Address
+		}
+	}
+	writeHeader(b, name, strings.Join(s, ",\r\n "))
+}
+
+// encodeHeaderValue encodes s as RFC 2047 words if it is not ASCII.
+func encodeHeaderValue(s string) string {
+	for _, r := range s {
+		if r >= 0x80 || r < 0x20 && r != '\t' {
+			return mime.QEncoding.Encode("utf-8", s)
+		}
+	}
+	return s
+}
+
+// SendMessage sends the RFC 5322 message raw from user's mailbox, which
+// needs the gmail.send scope or a broader one. If threadId is set the
+// message is added to that thread, provided its headers refer to it as a
+// reply. raw is uploaded as media, so it may be up to Gmail's 35 MB limit.
+func SendMessage(ctx context.Context, srv SendService, user string, raw []byte, threadId string) (*gmail.Message, error) {
+	m, err := srv.SendMessage(ctx, user, raw, threadId)
+	if err != nil {
+		return nil, fmt.Errorf("SendMessage: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,143 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestOutgoingMessage(t *testing.T) {
+	m := &gmailclient.OutgoingMessage{
+		To:      gmailclient.ParseAddressList("José Pérez <jose@example.com>, ana@example.com"),
+		Bcc:     gmailclient.ParseAddressList("audit@example.com"),
+		Subject: "Reunión mañana",
+		Text:    "Hola,\nnos vemos mañana.",
+		HTML:    "<p>Hola,</p><p>nos vemos mañana.</p>",
+	}
+	raw, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(mime.WordDecoder)
+	if subject, _ := dec.DecodeHeader(msg.Header.Get("Subject")); subject != m.Subject {
+		t.Errorf("Subject = %q, want %q", subject, m.Subject)
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 2 || to[0].Name != "José Pérez" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	if msg.Header.Get("Bcc") != "audit@example.com" {
+		t.Errorf("Bcc = %q", msg.Header.Get("Bcc"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ mimeType, body string }{{"text/plain", "Hola,\r\nnos vemos mañana."}, {"text/html", m.HTML}} {
+		p, err := r.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); ct != want.mimeType {
+			t.Errorf("part type %q, want %q", ct, want.mimeType)
+		}
+		// NextPart undoes the quoted-printable encoding.
+		body, _ := ioutil.ReadAll(p)
+		if string(body) != want.body {
+			t.Errorf("%s body = %q, want %q", want.mimeType, body, want.body)
+		}
+	}
+
+	if _, err := (&gmailclient.OutgoingMessage{Subject: "x"}).Bytes(); !errors.Is(err, gmailclient.ErrNoRecipients) {
+		t.Errorf("Bytes without recipients: %v, want ErrNoRecipients", err)
+	}
+
+	for _, a := range []*mail.Address{
+		{Address: "ana@example.com\r\nBcc: eve@example.com"},
+		{Name: "Ana\nBcc: eve@example.com", Address: "ana@example.com"},
+		{Address: "not an address"},
+	} {
+		m := &gmailclient.OutgoingMessage{To: []*mail.Address{a}, Subject: "x"}
+		if _, err := m.Bytes(); !errors.Is(err, gmailclient.ErrInvalidAddress) {
+			t.Errorf("Bytes to %q: %v, want ErrInvalidAddress", a, err)
+		}
+	}
+}
+
+func TestOutgoingMessageAttachments(t *testing.T) {
+	m := &gmailclient.OutgoingMessage{
+		To:      gmailclient.ParseAddressList("ana@example.com"),
+		Subject: "Invoice",
+		Text:    "Attached.",
+		Attachments: []*gmailclient.OutgoingAttachment{
+			{Filename: "factura año.pdf", Data: bytes.Repeat([]byte{0, 1, 2, 0xff}, 100)},
+		},
+	}
+	raw, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, want multipart/mixed", mediaType)
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	if p, err := r.NextPart(); err != nil {
+		t.Fatal(err)
+	} else if body, _ := ioutil.ReadAll(p); string(body) != "Attached." {
+		t.Errorf("body = %q", body)
+	}
+	p, err := r.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FileName() != "factura año.pdf" {
+		t.Errorf("filename = %q", p.FileName())
+	}
+	if ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); ct != "application/pdf" {
+		t.Errorf("attachment type = %q, want application/pdf", ct)
+	}
+	data, _ := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+	if !bytes.Equal(data, m.Attachments[0].Data) {
+		t.Errorf("attachment data does not round-trip")
+	}
+
+	m.Attachments[0].Data = make([]byte, gmailclient.MaxAttachmentSize+1)
+	if _, err := m.Bytes(); !errors.Is(err, gmailclient.ErrMessageTooLarge) {
+		t.Errorf("Bytes with a large attachment: %v, want ErrMessageTooLarge", err)
+	}
+}
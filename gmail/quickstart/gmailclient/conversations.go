@@ -0,0 +1,170 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// A ConversationLink places a message in its conversation tree, as rebuilt
+// from the Message-ID, In-Reply-To and References headers rather than from
+// Gmail's thread IDs, so that trees from several mailboxes, or from mail
+// Gmail did not thread, can be joined. Messages are named by Message-ID,
+// without the angle brackets.
+type ConversationLink struct {
+	ID        string `json:"id"` // Gmail message ID
+	ThreadID  string `json:"thread_id,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+
+	// Parent is the message this one replies to, which may not be among
+	// the messages added.
+	Parent string `json:"parent,omitempty"`
+
+	// Children are the added messages that reply to this one, in the
+	// order they were added.
+	Children []string `json:"children,omitempty"`
+
+	// Root is the first message of the conversation that the headers
+	// name, which may not be among the messages added either. Messages of
+	// one conversation share it.
+	Root string `json:"root"`
+}
+
+// Conversations rebuilds conversation trees from message headers, in the
+// manner of the JWZ threading algorithm: a message's parent is the last
+// entry of its References, or failing that its In-Reply-To, and the
+// References list the ancestors in order. The zero value is not usable;
+// call NewConversations.
+type Conversations struct {
+	links   []*ConversationLink
+	parents map[string]string
+	own     map[string]bool // message IDs whose parent their own headers gave
+}
+
+// NewConversations returns an empty set of conversations.
+func NewConversations() *Conversations {
+	return &Conversations{parents: map[string]string{}, own: map[string]bool{}}
+}
+
+var messageIDRE = regexp.MustCompile(`<([^<>\s]+)>`)
+
+// messageIDs returns the message IDs in a Message-ID, In-Reply-To or
+// References header value, without angle brackets.
+func messageIDs(v string) []string {
+	var ids []string
+	for _, m := range messageIDRE.FindAllStringSubmatch(v, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+// Add adds the message with Gmail ID id in thread threadID and header h.
+func (c *Conversations) Add(id, threadID string, h Header) {
+	l := &ConversationLink{ID: id, ThreadID: threadID}
+	if ids := messageIDs(h.Get("Message-ID")); len(ids) > 0 {
+		l.MessageID = ids[0]
+	}
+	refs := messageIDs(h.Get("References"))
+	if len(refs) == 0 {
+		if ids := messageIDs(h.Get("In-Reply-To")); len(ids) > 0 {
+			refs = ids[:1]
+		}
+	}
+	// The References give the ancestors' links too, unless their own
+	// headers have been seen or are later.
+	for i := 1; i < len(refs); i++ {
+		if !c.own[refs[i]] && refs[i] != refs[i-1] {
+			c.parents[refs[i]] = refs[i-1]
+		}
+	}
+	if len(refs) > 0 && refs[len(refs)-1] != l.MessageID {
+		l.Parent = refs[len(refs)-1]
+	}
+	if l.MessageID != "" {
+		c.parents[l.MessageID] = l.Parent
+		c.own[l.MessageID] = true
+	}
+	c.links = append(c.links, l)
+}
+
+// Links returns the links of the messages added, in the order they were
+// added.
+func (c *Conversations) Links() []*ConversationLink {
+	children := map[string][]string{}
+	for _, l := range c.links {
+		if l.Parent != "" && l.MessageID != "" {
+			children[l.Parent] = append(children[l.Parent], l.MessageID)
+		}
+	}
+	for _, l := range c.links {
+		l.Children = children[l.MessageID]
+		l.Root = c.root(l)
+	}
+	return c.links
+}
+
+// root follows the parents of l up to the top of its tree. Headers can
+// form a loop; the walk stops where it would go round.
+func (c *Conversations) root(l *ConversationLink) string {
+	id := l.MessageID
+	parent := l.Parent
+	seen := map[string]bool{id: true}
+	for parent != "" && !seen[parent] {
+		seen[parent] = true
+		id, parent = parent, c.parents[parent]
+	}
+	if id == "" {
+		// A message without Message-ID that replies to nothing.
+		return "gmail:" + l.ID
+	}
+	return id
+}
+
+// MessageHeader returns the header of m, fetched in the "raw" format or in
+// one with a payload.
+func MessageHeader(m *gmail.Message) (Header, error) {
+	if m.Payload != nil {
+		return partHeader(m.Payload), nil
+	}
+	raw, err := RawMessage(m)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("MessageHeader %s: %w", m.Id, err)
+	}
+	names := make([]string, 0, len(msg.Header))
+	for name := range msg.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var h Header
+	for _, name := range names {
+		for _, v := range msg.Header[name] {
+			h = append(h, HeaderField{Name: name, Value: v})
+		}
+	}
+	return h, nil
+}
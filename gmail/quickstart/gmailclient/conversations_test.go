@@ -0,0 +1,96 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestConversations(t *testing.T) {
+	header := func(fields ...string) gmailclient.Header {
+		var h gmailclient.Header
+		for i := 0; i < len(fields); i += 2 {
+			h = append(h, gmailclient.HeaderField{Name: fields[i], Value: fields[i+1]})
+		}
+		return h
+	}
+	c := gmailclient.NewConversations()
+	// The reply to a reply comes first, as it might from another mailbox.
+	c.Add("3", "t2", header("Message-ID", "<c@x>", "References", "<a@x>\r\n <b@x>"))
+	c.Add("1", "t1", header("Message-ID", "<a@x>"))
+	c.Add("2", "t1", header("Message-ID", "<b@x>", "In-Reply-To", "<a@x>"))
+	// Both reply to a message that was not exported.
+	c.Add("4", "t3", header("Message-Id", "<d@x>", "References", "<lost@x> <gone@x>"))
+	c.Add("5", "t4", header("In-Reply-To", "<gone@x> (Alice's message)"))
+	c.Add("6", "t5", header("Subject", "No Message-ID"))
+
+	type link struct {
+		parent   string
+		children []string
+		root     string
+	}
+	want := map[string]link{
+		"3": {"b@x", nil, "a@x"},
+		"1": {"", []string{"b@x"}, "a@x"},
+		"2": {"a@x", []string{"c@x"}, "a@x"},
+		"4": {"gone@x", nil, "lost@x"},
+		"5": {"gone@x", nil, "lost@x"},
+		"6": {"", nil, "gmail:6"},
+	}
+	links := c.Links()
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d", len(links), len(want))
+	}
+	for _, l := range links {
+		got := link{l.Parent, l.Children, l.Root}
+		if !reflect.DeepEqual(got, want[l.ID]) {
+			t.Errorf("message %s: got %+v, want %+v", l.ID, got, want[l.ID])
+		}
+	}
+}
+
+func TestConversationsLoop(t *testing.T) {
+	c := gmailclient.NewConversations()
+	c.Add("1", "", gmailclient.Header{{Name: "Message-ID", Value: "<a@x>"}, {Name: "In-Reply-To", Value: "<b@x>"}})
+	c.Add("2", "", gmailclient.Header{{Name: "Message-ID", Value: "<b@x>"}, {Name: "In-Reply-To", Value: "<a@x>"}})
+	for _, l := range c.Links() {
+		if l.Root == "" {
+			t.Errorf("message %s has no root", l.ID)
+		}
+	}
+}
+
+func TestMessageHeaderRaw(t *testing.T) {
+	raw := "Message-ID: <a@x>\r\nReferences: <r@x>\r\nSubject: Hi\r\n\r\nBody\r\n"
+	m := &gmail.Message{Id: "1", Raw: base64.URLEncoding.EncodeToString([]byte(raw))}
+	h, err := gmailclient.MessageHeader(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Get("Message-ID"); got != "<a@x>" {
+		t.Errorf("Message-ID = %q, want <a@x>", got)
+	}
+	if got := h.Get("References"); got != "<r@x>" {
+		t.Errorf("References = %q, want <r@x>", got)
+	}
+}
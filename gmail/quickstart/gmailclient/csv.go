@@ -0,0 +1,47 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVHeader names the columns of CSVRecord.
+var CSVHeader = []string{"date", "from", "to", "subject", "size", "labels", "has_attachments", "message_id"}
+
+// CSVRecord returns the metadata of m as a row for a spreadsheet: the date in
+// RFC 3339, the addresses and label IDs separated by "; ", and
+// has_attachments as true or false.
+func CSVRecord(m *Message) []string {
+	date := ""
+	if !m.Date.IsZero() {
+		date = m.Date.Format(time.RFC3339)
+	}
+	return []string{
+		date,
+		strings.Replace(FormatAddressList(m.From), ", ", "; ", -1),
+		strings.Replace(FormatAddressList(m.To), ", ", "; ", -1),
+		m.Subject,
+		strconv.FormatInt(m.Size, 10),
+		strings.Join(m.LabelIds, "; "),
+		strconv.FormatBool(len(m.Attachments) > 0),
+		m.Headers.Get("Message-ID"),
+	}
+}
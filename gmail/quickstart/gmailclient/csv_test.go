@@ -0,0 +1,50 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+)
+
+func TestCSVRecord(t *testing.T) {
+	msg, err := gmailclienttest.New().LoadMessage("testdata/multipart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := gmailclient.ParseMetadata(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"2021-05-03T10:00:00Z",
+		"Vim Tricks <hi@vimtricks.com>",
+		"me@example.com",
+		"Weekly tips",
+		"2048",
+		"INBOX; UNREAD",
+		"false",
+		"<abc@vimtricks.com>",
+	}
+	if got := gmailclient.CSVRecord(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("CSVRecord = %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// dateLayouts are the malformed Date header formats seen in the wild that
+// net/mail rejects.
+var dateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700 MST",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 January 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04:05 MST",
+	"Mon Jan 2 15:04:05 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+	time.RFC3339,
+}
+
+// dateComment matches a trailing comment such as "(UTC)" or "(CEST)".
+var dateComment = regexp.MustCompile(`\s*\([^)]*\)\s*$`)
+
+// ParseDate parses the value of a Date header. It accepts RFC 5322 dates,
+// including the obsolete forms, and a few common malformed variants.
+func ParseDate(s string) (time.Time, error) {
+	s = strings.Join(strings.Fields(s), " ")
+	if t, err := mail.ParseDate(s); err == nil {
+		return t, nil
+	}
+	s = dateComment.ReplaceAllString(s, "")
+	if t, err := mail.ParseDate(s); err == nil {
+		return t, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("ParseDate: unrecognized date %q", s)
+}
+
+// messageDate returns the date of m from its Date header, falling back to
+// the time Gmail received it when the header is missing or unparseable.
+func messageDate(m *gmail.Message) time.Time {
+	if t, err := ParseDate(FindHeader(m.Payload, "Date")); err == nil {
+		return t
+	}
+	if m.InternalDate != 0 {
+		return receivedAt(m)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,70 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC)
+	for _, s := range []string{
+		"Mon, 3 May 2021 10:00:00 +0000",
+		"3 May 2021 10:00:00 GMT",
+		"Mon, 3 May 2021 10:00:00 +0000 (UTC)",
+		"Mon,  3 May 2021 12:00:00 +0200",
+		"Mon, 3 May 2021 10:00:00 +0000 UTC",
+		"Mon, 3 May 21 10:00:00 +0000",
+		"Mon May 3 10:00:00 2021",
+		"2021-05-03T10:00:00Z",
+	} {
+		got, err := gmailclient.ParseDate(s)
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", s, err)
+		} else if !got.Equal(want) {
+			t.Errorf("ParseDate(%q) = %v, want %v", s, got, want)
+		}
+	}
+	if _, err := gmailclient.ParseDate("yesterday"); err == nil {
+		t.Error("ParseDate(yesterday) succeeded")
+	}
+}
+
+func TestParseMessageDate(t *testing.T) {
+	srv := gmailclienttest.New()
+	msg := &gmail.Message{
+		Id:           "1",
+		InternalDate: 1620036000000,
+		Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{{Name: "Date", Value: "not a date"}},
+		},
+	}
+	m, err := gmailclient.ParseMessage(context.Background(), srv, msg, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1620036000, 0); !m.Date.Equal(want) {
+		t.Errorf("Date = %v, want internalDate %v", m.Date, want)
+	}
+}
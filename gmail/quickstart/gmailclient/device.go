@@ -0,0 +1,162 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DeviceAuthURL is Google's device authorization endpoint.
+var DeviceAuthURL = "https://oauth2.googleapis.com/device/code"
+
+// deviceCode is the response of the device authorization endpoint.
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURL string `json:"verification_url"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Interval        int64  `json:"interval"`
+	Error           string `json:"error"`
+	ErrorDesc       string `json:"error_description"`
+}
+
+// deviceToken is the response of the token endpoint while polling.
+type deviceToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// GetTokenFromDevice runs the OAuth device authorization flow (RFC 8628) for
+// machines without a browser: it prints a URL and a short code to enter on
+// another device, then polls until the user approves. The OAuth client must
+// be of the "TVs and Limited Input devices" type.
+func GetTokenFromDevice(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	var dc deviceCode
+	status, err := postForm(ctx, DeviceAuthURL, url.Values{
+		"client_id": {config.ClientID},
+		"scope":     {strings.Join(config.Scopes, " ")},
+	}, &dc)
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenFromDevice request device code: %w", err)
+	}
+	if status/100 != 2 {
+		return nil, fmt.Errorf("GetTokenFromDevice request device code: HTTP %d: %s: %s", status, dc.Error, dc.ErrorDesc)
+	}
+	verification := dc.VerificationURL
+	if verification == "" {
+		verification = dc.VerificationURI
+	}
+	if dc.DeviceCode == "" || verification == "" {
+		return nil, fmt.Errorf("GetTokenFromDevice request device code: the response has no device code or verification URL")
+	}
+	fmt.Fprintf(os.Stderr, "To authorize, visit %s on any device and enter the code: %s\n",
+		verification, dc.UserCode)
+
+	interval := time.Duration(dc.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	expiresIn := time.Duration(dc.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		// Google's codes last 30 minutes; assume as much when the
+		// endpoint does not say.
+		expiresIn = 30 * time.Minute
+	}
+	deadline := time.Now().Add(expiresIn)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("GetTokenFromDevice: the code expired before it was approved")
+		}
+
+		var dt deviceToken
+		_, err := postForm(ctx, config.Endpoint.TokenURL, url.Values{
+			"client_id":     {config.ClientID},
+			"client_secret": {config.ClientSecret},
+			"device_code":   {dc.DeviceCode},
+			"grant_type":    {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, &dt)
+		if err != nil {
+			return nil, fmt.Errorf("GetTokenFromDevice poll: %w", err)
+		}
+		switch dt.Error {
+		case "":
+			tok := &oauth2.Token{
+				AccessToken:  dt.AccessToken,
+				TokenType:    dt.TokenType,
+				RefreshToken: dt.RefreshToken,
+			}
+			if dt.ExpiresIn > 0 {
+				tok.Expiry = time.Now().Add(time.Duration(dt.ExpiresIn) * time.Second)
+			}
+			if dt.Scope != "" {
+				tok = WithScopes(tok, strings.Fields(dt.Scope))
+			}
+			return tok, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, fmt.Errorf("GetTokenFromDevice: %s: %s", dt.Error, dt.ErrorDesc)
+		}
+	}
+}
+
+// postForm posts form to endpoint, decodes the JSON response into v and
+// returns the HTTP status code. Error responses with a JSON body are decoded
+// too, so callers can inspect the OAuth error code.
+func postForm(ctx context.Context, endpoint string, form url.Values, v interface{}) (int, error) {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return 0, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,119 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/oauth2"
+)
+
+func TestGetTokenFromDevice(t *testing.T) {
+	for _, expiresIn := range []string{`"expires_in": 60,`, "", `"expires_in": 0,`} {
+		testGetTokenFromDevice(t, expiresIn)
+	}
+}
+
+// testGetTokenFromDevice runs the flow against a device endpoint answering
+// with the expires_in field expiresIn, which may be left out.
+func testGetTokenFromDevice(t *testing.T, expiresIn string) {
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "client" || r.FormValue("scope") != "a b" {
+			t.Errorf("device code request form = %v", r.Form)
+		}
+		w.Write([]byte(`{"device_code": "dev", "user_code": "ABCD-EFGH",
+			"verification_url": "https://www.google.com/device", ` + expiresIn + ` "interval": 1}`))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("device_code") != "dev" {
+			t.Errorf("token request form = %v", r.Form)
+		}
+		polls++
+		if polls < 2 {
+			w.WriteHeader(http.StatusPreconditionRequired)
+			w.Write([]byte(`{"error": "authorization_pending"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "at", "refresh_token": "rt", "token_type": "Bearer", "expires_in": 3600}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	defer func(u string) { gmailclient.DeviceAuthURL = u }(gmailclient.DeviceAuthURL)
+	gmailclient.DeviceAuthURL = ts.URL + "/device/code"
+	config := &oauth2.Config{
+		ClientID: "client",
+		Scopes:   []string{"a", "b"},
+		Endpoint: oauth2.Endpoint{TokenURL: ts.URL + "/token"},
+	}
+	tok, err := gmailclient.GetTokenFromDevice(context.Background(), config)
+	if err != nil {
+		t.Fatalf("with %q: %v", expiresIn, err)
+	}
+	if tok.AccessToken != "at" || tok.RefreshToken != "rt" || polls != 2 {
+		t.Errorf("with %q: token = %+v after %d polls", expiresIn, tok, polls)
+	}
+}
+
+func TestGetTokenFromDeviceErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"invalid scope", http.StatusBadRequest, `{"error": "invalid_scope", "error_description": "Invalid device flow scope: https://mail.google.com/"}`, "HTTP 400: invalid_scope: Invalid device flow scope"},
+		{"invalid client", http.StatusUnauthorized, `{"error": "invalid_client", "error_description": "The OAuth client was not found."}`, "HTTP 401: invalid_client"},
+		{"server error", http.StatusInternalServerError, `{}`, "HTTP 500"},
+		{"no device code", http.StatusOK, `{"user_code": "ABCD-EFGH", "verification_url": "https://www.google.com/device"}`, "no device code"},
+		{"no verification URL", http.StatusOK, `{"device_code": "dev", "user_code": "ABCD-EFGH"}`, "no device code or verification URL"},
+		{"not JSON", http.StatusBadGateway, `<html>Bad Gateway</html>`, "502 Bad Gateway"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			polled := false
+			mux := http.NewServeMux()
+			mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+				polled = true
+			})
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+
+			defer func(u string) { gmailclient.DeviceAuthURL = u }(gmailclient.DeviceAuthURL)
+			gmailclient.DeviceAuthURL = ts.URL + "/device/code"
+			config := &oauth2.Config{ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: ts.URL + "/token"}}
+			_, err := gmailclient.GetTokenFromDevice(context.Background(), config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+			if polled {
+				t.Error("the token endpoint was polled after a failed device code request")
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/option"
+)
+
+// DirectoryScope is the scope DomainUsers needs.
+const DirectoryScope = admin.AdminDirectoryUserReadonlyScope
+
+// DomainUsers returns the primary addresses of the active users in domain, or
+// of the whole Workspace account if domain is empty, using the Admin SDK
+// Directory API. client must act for a Workspace admin with DirectoryScope,
+// for example a ServiceAccountClient impersonating one. Suspended and archived
+// users are skipped since their mailboxes cannot be read.
+func DomainUsers(ctx context.Context, client *http.Client, domain string) ([]string, error) {
+	srv, err := admin.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("DomainUsers create directory client: %w", err)
+	}
+	call := srv.Users.List().OrderBy("email").MaxResults(500)
+	if domain != "" {
+		call = call.Domain(domain)
+	} else {
+		call = call.Customer("my_customer")
+	}
+	var users []string
+	err = call.Pages(ctx, func(r *admin.Users) error {
+		for _, u := range r.Users {
+			if !u.Suspended && !u.Archived {
+				users = append(users, u.PrimaryEmail)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DomainUsers list users: %w", err)
+	}
+	return users, nil
+}
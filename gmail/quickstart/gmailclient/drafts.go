@@ -0,0 +1,91 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// The draft functions need the gmail.compose scope, or gmail.modify.
+
+// CreateDraft saves the RFC 5322 message raw as a new draft in user's
+// mailbox, in the thread threadId if it is set.
+func CreateDraft(ctx context.Context, srv DraftService, user string, raw []byte, threadId string) (*gmail.Draft, error) {
+	d, err := srv.CreateDraft(ctx, user, raw, threadId)
+	if err != nil {
+		return nil, fmt.Errorf("CreateDraft: %w", err)
+	}
+	return d, nil
+}
+
+// UpdateDraft replaces the message of draft id with raw.
+func UpdateDraft(ctx context.Context, srv DraftService, user, id string, raw []byte, threadId string) (*gmail.Draft, error) {
+	d, err := srv.UpdateDraft(ctx, user, id, raw, threadId)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateDraft: %w", err)
+	}
+	return d, nil
+}
+
+// GetDraft fetches draft id with its message in the given format, as for
+// GmailService.GetMessage.
+func GetDraft(ctx context.Context, srv DraftService, user, id, format string) (*gmail.Draft, error) {
+	d, err := srv.GetDraft(ctx, user, id, format)
+	if err != nil {
+		return nil, fmt.Errorf("GetDraft: %w", err)
+	}
+	return d, nil
+}
+
+// ListDrafts returns the drafts whose messages match query, or all drafts
+// if query is empty. Only their IDs and message IDs are filled in.
+func ListDrafts(ctx context.Context, srv DraftService, user, query string) ([]*gmail.Draft, error) {
+	var drafts []*gmail.Draft
+	pageToken := ""
+	for {
+		r, err := srv.ListDrafts(ctx, user, query, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("ListDrafts: %w", err)
+		}
+		drafts = append(drafts, r.Drafts...)
+		if r.NextPageToken == "" {
+			return drafts, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// SendDraft sends draft id, which removes it from the drafts.
+func SendDraft(ctx context.Context, srv DraftService, user, id string) (*gmail.Message, error) {
+	m, err := srv.SendDraft(ctx, user, id)
+	if err != nil {
+		return nil, fmt.Errorf("SendDraft: %w", err)
+	}
+	return m, nil
+}
+
+// DeleteDraft deletes draft id for good; it does not go to the trash.
+func DeleteDraft(ctx context.Context, srv DraftService, user, id string) error {
+	if err := srv.DeleteDraft(ctx, user, id); err != nil {
+		return fmt.Errorf("DeleteDraft: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+)
+
+func TestDrafts(t *testing.T) {
+	ctx := context.Background()
+	s := gmailclienttest.New()
+	d, err := gmailclient.CreateDraft(ctx, s, "me", []byte("Subject: one\r\n\r\nfirst"), "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := gmailclient.CreateDraft(ctx, s, "me", []byte("Subject: two\r\n\r\nsecond"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drafts, err := gmailclient.ListDrafts(ctx, s, "me", ""); err != nil || len(drafts) != 2 {
+		t.Fatalf("ListDrafts = %v, %v; want both drafts", drafts, err)
+	}
+
+	if _, err := gmailclient.UpdateDraft(ctx, s, "me", d.Id, []byte("Subject: one\r\n\r\nedited"), "t1"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := gmailclient.GetDraft(ctx, s, "me", d.Id, "raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := gmailclient.RawMessage(got.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "Subject: one\r\n\r\nedited" || got.Message.ThreadId != "t1" {
+		t.Errorf("updated draft = %q in thread %q", raw, got.Message.ThreadId)
+	}
+
+	m, err := gmailclient.SendDraft(ctx, s, "me", d.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ThreadId != "t1" {
+		t.Errorf("sent message is in thread %q, want t1", m.ThreadId)
+	}
+	if _, err := gmailclient.GetDraft(ctx, s, "me", d.Id, "raw"); err == nil {
+		t.Error("draft still there after it was sent")
+	}
+	if _, err := gmailclient.SendDraft(ctx, s, "me", d.Id); err == nil {
+		t.Error("SendDraft sent a draft that was already sent")
+	}
+
+	if err := gmailclient.DeleteDraft(ctx, s, "me", other.Id); err != nil {
+		t.Fatal(err)
+	}
+	if drafts, err := gmailclient.ListDrafts(ctx, s, "me", ""); err != nil || len(drafts) != 0 {
+		t.Errorf("ListDrafts after sending and deleting = %v, %v; want none", drafts, err)
+	}
+	if err := gmailclient.DeleteDraft(ctx, s, "me", other.Id); err == nil {
+		t.Error("DeleteDraft deleted a missing draft")
+	}
+}
@@ -0,0 +1,124 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	drive "google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// DriveScope is the scope DriveFolder needs. It only grants access to files
+// and folders the application created itself.
+const DriveScope = drive.DriveFileScope
+
+// driveFolderType is the MIME type of Drive folders.
+const driveFolderType = "application/vnd.google-apps.folder"
+
+// DriveFolder uploads files into a folder of My Drive and its subfolders,
+// creating folders as needed. It is safe for concurrent use.
+type DriveFolder struct {
+	srv *drive.Service
+	id  string
+
+	mu      sync.Mutex
+	folders map[string]string // folder ID by parent ID and name
+}
+
+// OpenDriveFolder returns the folder at path, such as "Mail/Invoices", below
+// the root of My Drive, creating its missing parts. client must be authorized
+// for DriveScope; since that scope only sees the application's own files, a
+// folder created by hand is not found and a new one of the same name is
+// created instead.
+func OpenDriveFolder(ctx context.Context, client *http.Client, path string) (*DriveFolder, error) {
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("OpenDriveFolder create drive client: %w", err)
+	}
+	f := &DriveFolder{srv: srv, id: "root", folders: make(map[string]string)}
+	id, err := f.folder(ctx, "root", strings.Split(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("OpenDriveFolder: %w", err)
+	}
+	f.id = id
+	return f, nil
+}
+
+// Upload stores the content read from r as a file called name in the
+// subfolder dirs of f, such as ["hi@vimtricks.com", "2021-05-03"], and
+// returns its ID. Large files are sent in chunks with a resumable upload.
+func (f *DriveFolder) Upload(ctx context.Context, dirs []string, name, mimeType string, r io.Reader) (string, error) {
+	parent, err := f.folder(ctx, f.id, dirs)
+	if err != nil {
+		return "", fmt.Errorf("DriveFolder.Upload: %w", err)
+	}
+	file, err := f.srv.Files.Create(&drive.File{Name: name, MimeType: mimeType, Parents: []string{parent}}).
+		Media(r).Fields("id").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("DriveFolder.Upload: %w", err)
+	}
+	return file.Id, nil
+}
+
+// folder returns the ID of the folder reached from parent through the names
+// in path, creating the folders that do not exist. Empty names are skipped.
+func (f *DriveFolder) folder(ctx context.Context, parent string, path []string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range path {
+		if name == "" {
+			continue
+		}
+		key := parent + "/" + name
+		if id, ok := f.folders[key]; ok {
+			parent = id
+			continue
+		}
+		q := fmt.Sprintf("name = '%s' and '%s' in parents and mimeType = '%s' and trashed = false",
+			driveQuote(name), parent, driveFolderType)
+		list, err := f.srv.Files.List().Q(q).Fields("files(id)").PageSize(1).Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("find folder %q: %w", name, err)
+		}
+		var id string
+		if len(list.Files) > 0 {
+			id = list.Files[0].Id
+		} else {
+			created, err := f.srv.Files.Create(&drive.File{Name: name, MimeType: driveFolderType, Parents: []string{parent}}).
+				Fields("id").Context(ctx).Do()
+			if err != nil {
+				return "", fmt.Errorf("create folder %q: %w", name, err)
+			}
+			id = created.Id
+		}
+		f.folders[key] = id
+		parent = id
+	}
+	return parent, nil
+}
+
+// driveQuote escapes s for a string literal in a Drive search query.
+func driveQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
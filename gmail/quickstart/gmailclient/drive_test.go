@@ -0,0 +1,185 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	drive "google.golang.org/api/drive/v3"
+)
+
+// fakeDrive is a minimal Drive server, supporting the folder searches,
+// folder creation and uploads of DriveFolder.
+type fakeDrive struct {
+	mu         sync.Mutex
+	files      []*drive.File
+	content    map[string]string // by file ID
+	calls      []string
+	failList   bool
+	failUpload bool
+}
+
+// driveFolderQuery matches the search DriveFolder runs for a folder.
+var driveFolderQuery = regexp.MustCompile(`^name = '((?:[^'\\]|\\.)*)' and '([^']*)' in parents and mimeType = 'application/vnd.google-apps.folder' and trashed = false$`)
+
+func (f *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/drive/v3/files":
+		m := driveFolderQuery.FindStringSubmatch(r.URL.Query().Get("q"))
+		if m == nil || f.failList {
+			http.Error(w, `{"error": {"code": 400, "message": "bad query"}}`, http.StatusBadRequest)
+			return
+		}
+		name := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(m[1])
+		f.calls = append(f.calls, "list "+name)
+		resp := &drive.FileList{}
+		for _, file := range f.files {
+			if file.Name == name && file.MimeType == "application/vnd.google-apps.folder" && file.Parents[0] == m[2] {
+				resp.Files = append(resp.Files, &drive.File{Id: file.Id})
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.Method == "POST" && r.URL.Path == "/drive/v3/files":
+		file := &drive.File{}
+		json.NewDecoder(r.Body).Decode(file)
+		f.calls = append(f.calls, "create "+file.Name)
+		f.add(file)
+		json.NewEncoder(w).Encode(&drive.File{Id: file.Id})
+	case r.Method == "POST" && r.URL.Path == "/upload/drive/v3/files":
+		if f.failUpload {
+			http.Error(w, `{"error": {"code": 403, "message": "storage quota exceeded"}}`, http.StatusForbidden)
+			return
+		}
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		file := &drive.File{}
+		p, _ := mr.NextPart()
+		json.NewDecoder(p).Decode(file)
+		p, _ = mr.NextPart()
+		b, _ := ioutil.ReadAll(p)
+		f.calls = append(f.calls, "upload "+file.Name)
+		f.add(file)
+		f.content[file.Id] = string(b)
+		json.NewEncoder(w).Encode(&drive.File{Id: file.Id})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDrive) add(file *drive.File) {
+	file.Id = fmt.Sprintf("f%d", len(f.files)+1)
+	f.files = append(f.files, file)
+}
+
+// path returns the names of the folders from the root down to file id.
+func (f *fakeDrive) path(id string) string {
+	for _, file := range f.files {
+		if file.Id == id {
+			return f.path(file.Parents[0]) + "/" + file.Name
+		}
+	}
+	return ""
+}
+
+// redirectTransport sends every request to the server at u, for clients
+// with fixed endpoints.
+type redirectTransport struct{ u *url.URL }
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = t.u.Scheme, t.u.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newFakeDrive(t *testing.T) (*fakeDrive, *http.Client) {
+	fake := &fakeDrive{content: map[string]string{}}
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	u, _ := url.Parse(ts.URL)
+	return fake, &http.Client{Transport: redirectTransport{u}}
+}
+
+func TestDriveFolder(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeDrive(t)
+	// Mail exists already and must be reused; the file called Invoices is
+	// not a folder.
+	fake.files = []*drive.File{
+		{Id: "mail", Name: "Mail", MimeType: "application/vnd.google-apps.folder", Parents: []string{"root"}},
+		{Id: "inv", Name: "Invoices", MimeType: "text/plain", Parents: []string{"mail"}},
+	}
+
+	folder, err := gmailclient.OpenDriveFolder(ctx, client, "Mail/Invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"a.pdf", "b.pdf"} {
+		id, err := folder.Upload(ctx, []string{"o'brien@example.com", "", "2021-05-03"}, name, "application/pdf", strings.NewReader("%PDF "+name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := fake.path(id), "/Mail/Invoices/o'brien@example.com/2021-05-03/"+name; got != want {
+			t.Errorf("upload %d went to %s, want %s", i, got, want)
+		}
+		if got := fake.content[id]; got != "%PDF "+name {
+			t.Errorf("upload %d content = %q", i, got)
+		}
+	}
+	want := []string{
+		"list Mail", "list Invoices", "create Invoices",
+		"list o'brien@example.com", "create o'brien@example.com", "list 2021-05-03", "create 2021-05-03", "upload a.pdf",
+		"upload b.pdf",
+	}
+	if got := strings.Join(fake.calls, ", "); got != strings.Join(want, ", ") {
+		t.Errorf("calls = %s\nwant %s", got, strings.Join(want, ", "))
+	}
+}
+
+func TestDriveFolderErrors(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeDrive(t)
+	fake.failList = true
+	if _, err := gmailclient.OpenDriveFolder(ctx, client, "Mail"); err == nil || !strings.Contains(err.Error(), `find folder "Mail"`) {
+		t.Errorf("OpenDriveFolder with a failing search: %v", err)
+	}
+
+	fake.failList = false
+	folder, err := gmailclient.OpenDriveFolder(ctx, client, "Mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.failUpload = true
+	if _, err := folder.Upload(ctx, nil, "a.pdf", "application/pdf", strings.NewReader("%PDF")); err == nil || !strings.Contains(err.Error(), "storage quota exceeded") {
+		t.Errorf("Upload with a failing server: %v", err)
+	}
+}
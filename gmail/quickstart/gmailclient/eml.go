@@ -0,0 +1,71 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"unicode"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// maxSubjectInName bounds the subject part of EMLFilename, in runes.
+const maxSubjectInName = 60
+
+// EMLFilename returns a file name for a message fetched in the "raw" format,
+// of the form 2021-05-03_Weekly-tips_17a1.eml: the message date, the subject
+// reduced to letters, digits and dashes, and the Gmail ID that keeps the
+// name unique.
+func EMLFilename(m *gmail.Message) string {
+	date := receivedAt(m)
+	var subject string
+	if raw, err := RawMessage(m); err == nil {
+		if msg, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+			if t, err := ParseDate(msg.Header.Get("Date")); err == nil {
+				date = t
+			}
+			subject = DecodeHeader(msg.Header.Get("Subject"))
+		}
+	}
+
+	var b strings.Builder
+	n, dash := 0, false
+	for _, r := range subject {
+		if n == maxSubjectInName {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+				n++
+			}
+			b.WriteRune(r)
+			n++
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	name := date.Format("2006-01-02") + "_"
+	if b.Len() > 0 {
+		name += b.String() + "_"
+	}
+	return name + m.Id + ".eml"
+}
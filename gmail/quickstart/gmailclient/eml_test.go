@@ -0,0 +1,45 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestEMLFilename(t *testing.T) {
+	for _, tt := range []struct {
+		raw, want string
+	}{
+		{"Date: Mon, 3 May 2021 10:00:00 +0000\r\nSubject: Weekly tips: #42!\r\n\r\n", "2021-05-03_Weekly-tips-42_17a1.eml"},
+		{"Subject: =?UTF-8?Q?R=C3=A9sum=C3=A9?=\r\n\r\n", "2021-05-03_Résumé_17a1.eml"},
+		{"Subject: ???\r\n\r\n", "2021-05-03_17a1.eml"},
+	} {
+		m := &gmail.Message{
+			Id:           "17a1",
+			InternalDate: 1620036000000,
+			Raw:          base64.URLEncoding.EncodeToString([]byte(tt.raw)),
+		}
+		if got := gmailclient.EMLFilename(m); got != tt.want {
+			t.Errorf("EMLFilename = %q, want %q", got, tt.want)
+		}
+	}
+}
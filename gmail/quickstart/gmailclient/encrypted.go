@@ -0,0 +1,197 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+)
+
+// ErrBadPassphrase is returned when an encrypted token cannot be decrypted
+// with the given passphrase.
+var ErrBadPassphrase = errors.New("gmailclient: wrong passphrase or corrupted token file")
+
+// A PassphraseFunc returns the passphrase protecting a token. It is called at
+// most once per Load or Save, only when the token file is actually read or
+// written.
+type PassphraseFunc func() (string, error)
+
+// EncryptedFileTokenStore keeps the token in a file encrypted with AES-256-GCM
+// under a key derived from a passphrase with scrypt.
+type EncryptedFileTokenStore struct {
+	Path       string
+	Passphrase PassphraseFunc
+}
+
+// NewEncryptedFileTokenStore returns a store for the encrypted token file at
+// path.
+func NewEncryptedFileTokenStore(path string, passphrase PassphraseFunc) *EncryptedFileTokenStore {
+	return &EncryptedFileTokenStore{Path: path, Passphrase: passphrase}
+}
+
+// encryptedToken is the on-disk format of an EncryptedFileTokenStore.
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// scrypt parameters recommended for interactive logins.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+func (s *EncryptedFileTokenStore) Load() (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %v", ErrNoToken, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("EncryptedFileTokenStore: %w", err)
+	}
+	var et encryptedToken
+	if err := json.Unmarshal(b, &et); err != nil {
+		return nil, fmt.Errorf("EncryptedFileTokenStore decode %s: %w", s.Path, err)
+	}
+	if et.Version != 1 || et.KDF != "scrypt" {
+		return nil, fmt.Errorf("EncryptedFileTokenStore: unsupported format in %s", s.Path)
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(et.Salt)
+	nonce, err2 := base64.StdEncoding.DecodeString(et.Nonce)
+	ciphertext, err3 := base64.StdEncoding.DecodeString(et.Ciphertext)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, fmt.Errorf("EncryptedFileTokenStore: malformed %s", s.Path)
+	}
+
+	gcm, err := s.cipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("EncryptedFileTokenStore: malformed %s", s.Path)
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	tok, err := decodeToken(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("EncryptedFileTokenStore decode token: %w", err)
+	}
+	return tok, nil
+}
+
+func (s *EncryptedFileTokenStore) Save(tok *oauth2.Token) error {
+	plaintext, err := encodeToken(tok)
+	if err != nil {
+		return fmt.Errorf("EncryptedFileTokenStore encode oauth token: %w", err)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	gcm, err := s.cipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(encryptedToken{
+		Version:    1,
+		KDF:        "scrypt",
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return fmt.Errorf("EncryptedFileTokenStore create token directory: %w", err)
+	}
+	return atomicfile.WriteFile(s.Path, b, 0600)
+}
+
+func (s *EncryptedFileTokenStore) Delete() error {
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// cipher derives the AES-GCM cipher for salt from the passphrase.
+func (s *EncryptedFileTokenStore) cipher(salt []byte) (cipher.AEAD, error) {
+	passphrase, err := s.Passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("EncryptedFileTokenStore passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("EncryptedFileTokenStore: empty passphrase")
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyringPassphrase returns a PassphraseFunc that keeps a random passphrase
+// in the OS keyring under service and account, creating it on first use. The
+// token file is then useless without access to the user's keyring.
+func KeyringPassphrase(service, account string) PassphraseFunc {
+	return func() (string, error) {
+		p, err := keyring.Get(service, account)
+		if err == nil {
+			return p, nil
+		}
+		if !errors.Is(err, keyring.ErrNotFound) {
+			return "", err
+		}
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		p = base64.StdEncoding.EncodeToString(b)
+		if err := keyring.Set(service, account, p); err != nil {
+			return "", err
+		}
+		return p, nil
+	}
+}
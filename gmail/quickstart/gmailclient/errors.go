@@ -0,0 +1,65 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import "errors"
+
+var (
+	// ErrNoToken is returned when no cached OAuth token is available.
+	ErrNoToken = errors.New("gmailclient: no cached token")
+
+	// ErrNoPayload is returned when a message was fetched without a payload,
+	// for example with the "minimal" or "raw" format.
+	ErrNoPayload = errors.New("gmailclient: no payload in gmail message")
+
+	// ErrTokenRevoked is returned when the cached refresh token has expired or
+	// been revoked and a new one could not be obtained.
+	ErrTokenRevoked = errors.New("gmailclient: token expired or revoked")
+
+	// ErrInsufficientScopes is returned when the cached token was granted
+	// fewer scopes than requested and new consent could not be obtained.
+	ErrInsufficientScopes = errors.New("gmailclient: token lacks requested scopes")
+
+	// ErrAuthRequired is returned by NoAuthFlow, which is used when the user
+	// cannot be asked to authorize interactively.
+	ErrAuthRequired = errors.New("gmailclient: authorization required")
+
+	// ErrPartNotFound is returned when a message has no part of the requested
+	// MIME type.
+	ErrPartNotFound = errors.New("gmailclient: message part not found")
+
+	// ErrUnsupportedFormat is returned by ExtractText for file types it
+	// cannot read.
+	ErrUnsupportedFormat = errors.New("gmailclient: unsupported file format")
+
+	// ErrNoRecipients is returned by OutgoingMessage.Bytes for a message
+	// without any recipient.
+	ErrNoRecipients = errors.New("gmailclient: message has no recipients")
+
+	// ErrMessageTooLarge is returned by OutgoingMessage.Bytes for messages
+	// over Gmail's size limits.
+	ErrMessageTooLarge = errors.New("gmailclient: message too large")
+
+	// ErrInvalidAddress is returned by OutgoingMessage.Bytes for an address
+	// that cannot be written to a header field as it is.
+	ErrInvalidAddress = errors.New("gmailclient: invalid address")
+
+	// ErrLabelNotFound is returned by FindLabel for a name or ID that
+	// matches no label.
+	ErrLabelNotFound = errors.New("gmailclient: label not found")
+)
@@ -0,0 +1,127 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// MIME types of the Office formats ExtractText reads.
+const (
+	docxType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	xlsxType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// MaxExtractSize is the largest attachment ExtractAttachmentText reads.
+const MaxExtractSize = 25 << 20
+
+// CanExtractText reports whether ExtractText reads files of the given MIME
+// type or, since mail clients often send application/octet-stream, with the
+// given filename extension.
+func CanExtractText(mimeType, filename string) bool {
+	return extractKind(mimeType, filename) != ""
+}
+
+func extractKind(mimeType, filename string) string {
+	switch strings.ToLower(mimeType) {
+	case "application/pdf":
+		return "pdf"
+	case docxType:
+		return "docx"
+	case xlsxType:
+		return "xlsx"
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".pdf":
+		return "pdf"
+	case ".docx":
+		return "docx"
+	case ".xlsx":
+		return "xlsx"
+	}
+	return ""
+}
+
+// ExtractText returns the plain text of a PDF, Word (.docx) or Excel (.xlsx)
+// file. Spreadsheet cells are separated by tabs and rows by newlines.
+// Scanned PDFs have no text to extract, and PDF text in fonts without a
+// Unicode mapping may come out garbled. It returns an error wrapping
+// ErrUnsupportedFormat for other types. The files usually come from
+// strangers, so a parser panic on a malformed one is returned as an error.
+func ExtractText(mimeType, filename string, data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("ExtractText %s: malformed file: %v", filename, r)
+		}
+	}()
+	switch extractKind(mimeType, filename) {
+	case "pdf":
+		text, err = pdfText(data)
+	case "docx":
+		text, err = docxText(data)
+	case "xlsx":
+		text, err = xlsxText(data)
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, mimeType)
+	}
+	if err != nil {
+		return "", fmt.Errorf("ExtractText %s: %w", filename, err)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n")), nil
+}
+
+// ExtractAttachmentText fills in the Text of the attachments of m, parsed
+// from gmailMessage, that ExtractText can read, downloading them as needed.
+// Attachments larger than MaxExtractSize are skipped. A file that cannot be
+// parsed is left without text rather than failing the message.
+func ExtractAttachmentText(ctx context.Context, srv GmailService, user string, gmailMessage *gmail.Message, m *Message) error {
+	for _, a := range m.Attachments {
+		if !CanExtractText(a.MimeType, a.Filename) || a.Size > MaxExtractSize {
+			continue
+		}
+		data, err := GetAttachmentData(ctx, srv, user, gmailMessage, a)
+		if err != nil {
+			return fmt.Errorf("ExtractAttachmentText: %w", err)
+		}
+		if text, err := ExtractText(a.MimeType, a.Filename, data); err == nil {
+			a.Text = text
+		}
+	}
+	return nil
+}
+
+// AttachmentText returns the text extracted from the attachments of m, each
+// introduced by a line with its filename, or "" if there is none.
+func (m *Message) AttachmentText() string {
+	var b strings.Builder
+	for _, a := range m.Attachments {
+		if a.Text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "--- %s ---\n%s", a.Filename, a.Text)
+	}
+	return b.String()
+}
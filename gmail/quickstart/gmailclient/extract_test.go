@@ -0,0 +1,163 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/pdf"
+)
+
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractText(t *testing.T) {
+	var doc bytes.Buffer
+	m := &gmailclient.Message{
+		From:      gmailclient.ParseAddressList("billing@example.com"),
+		Subject:   "Invoice 2021-042",
+		Date:      time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+		BodyPlain: "Amount due: 120.00 EUR",
+	}
+	if err := pdf.Render(&doc, m, pdf.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	docx := zipFiles(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			`<w:p><w:r><w:t>Service</w:t></w:r><w:r><w:t xml:space="preserve"> agreement</w:t></w:r></w:p>` +
+			`<w:p><w:r><w:t>Term:</w:t><w:tab/><w:t>12 months</w:t></w:r></w:p></w:body></w:document>`,
+	})
+	xlsx := zipFiles(t, map[string]string{
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>Item</t></si><si><t>Total</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>` +
+			`<row r="2"><c r="A2" t="inlineStr"><is><t>Hosting</t></is></c><c r="B2"><v>120</v></c></row></sheetData></worksheet>`,
+	})
+
+	tests := []struct {
+		mimeType, filename string
+		data               []byte
+		want               []string
+	}{
+		{"application/pdf", "invoice.pdf", doc.Bytes(), []string{"Invoice 2021-042", "Amount due: 120.00 EUR"}},
+		{"application/octet-stream", "contract.docx", docx, []string{"Service agreement\nTerm:\t12 months"}},
+		{"application/octet-stream", "totals.xlsx", xlsx, []string{"Item\tTotal\nHosting\t120"}},
+	}
+	for _, tt := range tests {
+		got, err := gmailclient.ExtractText(tt.mimeType, tt.filename, tt.data)
+		if err != nil {
+			t.Errorf("ExtractText(%s): %v", tt.filename, err)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("ExtractText(%s) = %q, want it to contain %q", tt.filename, got, w)
+			}
+		}
+	}
+
+	if _, err := gmailclient.ExtractText("image/png", "logo.png", nil); !errors.Is(err, gmailclient.ErrUnsupportedFormat) {
+		t.Errorf("ExtractText(image/png) error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+// pdfFile returns a PDF file of the given objects, numbered from 1, with
+// object 1 as the catalog.
+func pdfFile(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, o := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func pdfStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtractTextMalformedPDF(t *testing.T) {
+	pages := "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"
+	page := func(contents string) string {
+		return "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents " + contents + " >>"
+	}
+	content := pdfStream("", []byte("BT /F1 12 Tf (ABC) Tj ET"))
+
+	// A 1-byte bfrange target counts up from its only byte.
+	cmap := []byte("1 begincodespacerange <00> <FF> endcodespacerange\n1 beginbfrange <41> <43> <61> endbfrange")
+	shortRange := pdfFile("<< /Type /Catalog /Pages 2 0 R >>", pages, page("4 0 R"), content,
+		"<< /Type /Font /Subtype /Type1 /ToUnicode 6 0 R >>", pdfStream("", cmap))
+
+	// An object stream whose offsets point before its data.
+	objStm := pdfStream("/Type /ObjStm /N 1 /First 4", []byte("7 -9 << >>"))
+	negativeOffset := pdfFile("<< /Type /Catalog /Pages 2 0 R >>", pages, page("4 0 R"), content,
+		"<< /Type /Font /Subtype /Type1 >>", objStm)
+
+	// A content stream that inflates far past the limit, next to a sane one.
+	bomb := pdfStream("/Filter /FlateDecode", deflate(t, make([]byte, 80<<20)))
+	deflateBomb := pdfFile("<< /Type /Catalog /Pages 2 0 R >>", pages, page("[6 0 R 4 0 R]"), content,
+		"<< /Type /Font /Subtype /Type1 >>", bomb)
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"short bfrange target", shortRange, "abc"},
+		{"negative object stream offset", negativeOffset, "ABC"},
+		{"deflate bomb", deflateBomb, "ABC"},
+	} {
+		got, err := gmailclient.ExtractText("application/pdf", "bad.pdf", tt.data)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: ExtractText = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// FetchOptions selects the messages FetchMessages, ParseMessages and Messages
+// retrieve.
+type FetchOptions struct {
+	// Query is a Gmail search query; empty matches every message.
+	Query string
+
+	// MaxResults caps the number of messages; zero means no limit.
+	MaxResults int64
+
+	// Format is the message format to fetch, "full" if empty.
+	Format string
+
+	// MetadataHeaders, if set, fetches messages in the "metadata" format
+	// with just these headers, regardless of Format. Use it when bodies
+	// are not needed.
+	MetadataHeaders []string
+
+	// Fields, if set, limits FetchMessages to these parts of each message,
+	// for example HeaderFields. ParseMessages always needs the full payload
+	// and ignores it.
+	Fields []googleapi.Field
+
+	// Concurrency is the number of messages fetched in parallel; values
+	// below one mean one.
+	Concurrency int
+
+	// PageToken, if set, makes Messages start listing at this page.
+	PageToken string
+}
+
+// FetchMessages lists the messages matching opts and fetches them with a pool
+// of opts.Concurrency workers. fn is called from the calling goroutine in the
+// order the messages were listed, so the output is the same as a serial loop.
+// The first error, from the API or from fn, stops the fetch and is returned.
+func FetchMessages(ctx context.Context, srv GmailService, user string, opts FetchOptions, fn func(m *gmail.Message) error) error {
+	work := func(ctx context.Context, id string) (interface{}, error) {
+		return fetchMessage(ctx, srv, user, id, opts)
+	}
+	return fetchOrdered(ctx, srv, user, opts, work, func(v interface{}) error {
+		return fn(v.(*gmail.Message))
+	})
+}
+
+// ParseMessages is like FetchMessages but also parses every message in the
+// worker pool, which includes fetching bodies stored as attachments.
+func ParseMessages(ctx context.Context, srv GmailService, user string, opts FetchOptions, fn func(m *gmail.Message, parsed *Message) error) error {
+	type parsedMessage struct {
+		m      *gmail.Message
+		parsed *Message
+	}
+	work := func(ctx context.Context, id string) (interface{}, error) {
+		m, err := srv.GetMessage(ctx, user, id, "full")
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := ParseMessage(ctx, srv, m, user)
+		if err != nil {
+			return nil, err
+		}
+		return parsedMessage{m, parsed}, nil
+	}
+	return fetchOrdered(ctx, srv, user, opts, work, func(v interface{}) error {
+		p := v.(parsedMessage)
+		return fn(p.m, p.parsed)
+	})
+}
+
+// fetchMessage fetches one message as selected by opts.
+func fetchMessage(ctx context.Context, srv GmailService, user, id string, opts FetchOptions) (*gmail.Message, error) {
+	if len(opts.MetadataHeaders) > 0 {
+		return srv.GetMessageMetadata(ctx, user, id, opts.MetadataHeaders, opts.Fields...)
+	}
+	format := opts.Format
+	if format == "" {
+		format = "full"
+	}
+	return srv.GetMessage(ctx, user, id, format, opts.Fields...)
+}
+
+// A fetchJob is one listed message on its way through the worker pool.
+type fetchJob struct {
+	id   string
+	done chan fetchResult
+}
+
+type fetchResult struct {
+	v   interface{}
+	err error
+}
+
+// fetchOrdered runs work for every listed message in a bounded pool and
+// passes the results to emit in listing order. At most opts.Concurrency
+// messages are in flight or waiting to be emitted at any time.
+func fetchOrdered(ctx context.Context, srv GmailService, user string, opts FetchOptions,
+	work func(ctx context.Context, id string) (interface{}, error), emit func(v interface{}) error) error {
+	n := opts.Concurrency
+	if n < 1 {
+		n = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *fetchJob)
+	order := make(chan *fetchJob, n)
+	var listErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(order)
+		defer close(jobs)
+		listErr = ListAllMessages(ctx, srv, user, opts.Query, opts.MaxResults, func(m *gmail.Message) error {
+			j := &fetchJob{id: m.Id, done: make(chan fetchResult, 1)}
+			select {
+			case order <- j:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				v, err := work(ctx, j.id)
+				j.done <- fetchResult{v, err}
+			}
+		}()
+	}
+
+	var err error
+	for j := range order {
+		var r fetchResult
+		select {
+		case r = <-j.done:
+		case <-ctx.Done():
+			r.err = ctx.Err()
+		}
+		if r.err == nil {
+			r.err = emit(r.v)
+		}
+		if r.err != nil {
+			err = r.err
+			break
+		}
+	}
+	cancel()
+	wg.Wait()
+	if err == nil {
+		err = listErr
+	}
+	return err
+}
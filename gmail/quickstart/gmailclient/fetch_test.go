@@ -0,0 +1,86 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestFetchMessagesOrder(t *testing.T) {
+	srv := gmailclienttest.New()
+	srv.PageSize = 3
+	for i := 0; i < 20; i++ {
+		srv.AddMessage(&gmail.Message{Id: fmt.Sprint(i)})
+	}
+	opts := gmailclient.FetchOptions{Concurrency: 4}
+	var ids []string
+	err := gmailclient.FetchMessages(context.Background(), srv, "me", opts, func(m *gmail.Message) error {
+		ids = append(ids, m.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 20 {
+		t.Fatalf("fetched %d messages, want 20", len(ids))
+	}
+	for i, id := range ids {
+		if id != fmt.Sprint(i) {
+			t.Fatalf("messages out of order: %v", ids)
+		}
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = gmailclient.FetchMessages(context.Background(), srv, "me", opts, func(m *gmail.Message) error {
+		if n++; n == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 5 {
+		t.Errorf("err = %v after %d messages, want stop after 5", err, n)
+	}
+}
+
+func TestFetchMessagesMetadata(t *testing.T) {
+	srv := gmailclienttest.New()
+	if _, err := srv.LoadMessage("testdata/multipart.json"); err != nil {
+		t.Fatal(err)
+	}
+	opts := gmailclient.FetchOptions{MetadataHeaders: []string{"Subject"}}
+	err := gmailclient.FetchMessages(context.Background(), srv, "me", opts, func(m *gmail.Message) error {
+		if got := gmailclient.FindHeader(m.Payload, "Subject"); got != "Weekly tips" {
+			t.Errorf("Subject = %q", got)
+		}
+		if len(m.Payload.Headers) != 1 || len(m.Payload.Parts) != 0 {
+			t.Errorf("metadata fetch returned %d headers and %d parts", len(m.Payload.Headers), len(m.Payload.Parts))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,221 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"google.golang.org/api/gmail/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// The filter functions need the gmail.settings.basic scope; filters that
+// forward mail also need gmail.settings.sharing.
+
+// ListFilters returns the filters of user's mailbox.
+func ListFilters(ctx context.Context, srv FilterService, user string) ([]*gmail.Filter, error) {
+	filters, err := srv.ListFilters(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("ListFilters: %w", err)
+	}
+	return filters, nil
+}
+
+// CreateFilter creates f and returns it with its ID. The API cannot change
+// a filter; delete it and create a new one instead.
+func CreateFilter(ctx context.Context, srv FilterService, user string, f *gmail.Filter) (*gmail.Filter, error) {
+	created, err := srv.CreateFilter(ctx, user, f)
+	if err != nil {
+		return nil, fmt.Errorf("CreateFilter: %w", err)
+	}
+	return created, nil
+}
+
+// DeleteFilter deletes the filter with the given ID.
+func DeleteFilter(ctx context.Context, srv FilterService, user, id string) error {
+	if err := srv.DeleteFilter(ctx, user, id); err != nil {
+		return fmt.Errorf("DeleteFilter: %w", err)
+	}
+	return nil
+}
+
+// FilterSpec is a filter as written in a filters file: the filter's
+// criteria and actions, with labels by name instead of ID so that the file
+// can be read and moved between mailboxes.
+type FilterSpec struct {
+	ID             string   `yaml:"id,omitempty" json:"id,omitempty"`
+	From           string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To             string   `yaml:"to,omitempty" json:"to,omitempty"`
+	Subject        string   `yaml:"subject,omitempty" json:"subject,omitempty"`
+	Query          string   `yaml:"query,omitempty" json:"query,omitempty"`
+	NegatedQuery   string   `yaml:"negated_query,omitempty" json:"negated_query,omitempty"`
+	HasAttachment  bool     `yaml:"has_attachment,omitempty" json:"has_attachment,omitempty"`
+	ExcludeChats   bool     `yaml:"exclude_chats,omitempty" json:"exclude_chats,omitempty"`
+	Size           int64    `yaml:"size,omitempty" json:"size,omitempty"`
+	SizeComparison string   `yaml:"size_comparison,omitempty" json:"size_comparison,omitempty"`
+	AddLabels      []string `yaml:"add_labels,omitempty" json:"add_labels,omitempty"`
+	RemoveLabels   []string `yaml:"remove_labels,omitempty" json:"remove_labels,omitempty"`
+	Forward        string   `yaml:"forward,omitempty" json:"forward,omitempty"`
+}
+
+// filtersFile is the layout of a filters file.
+type filtersFile struct {
+	Filters []*FilterSpec `yaml:"filters"`
+}
+
+// NewFilterSpec returns the spec of f, naming its labels from labels.
+// Labels missing from labels keep their IDs.
+func NewFilterSpec(f *gmail.Filter, labels []*gmail.Label) *FilterSpec {
+	s := &FilterSpec{ID: f.Id}
+	if c := f.Criteria; c != nil {
+		s.From, s.To, s.Subject = c.From, c.To, c.Subject
+		s.Query, s.NegatedQuery = c.Query, c.NegatedQuery
+		s.HasAttachment, s.ExcludeChats = c.HasAttachment, c.ExcludeChats
+		s.Size, s.SizeComparison = c.Size, c.SizeComparison
+	}
+	if a := f.Action; a != nil {
+		s.AddLabels = labelNames(labels, a.AddLabelIds)
+		s.RemoveLabels = labelNames(labels, a.RemoveLabelIds)
+		s.Forward = a.Forward
+	}
+	return s
+}
+
+// labelNames returns the names of the labels with the given IDs.
+func labelNames(labels []*gmail.Label, ids []string) []string {
+	var names []string
+	for _, id := range ids {
+		name := id
+		if l, err := FindLabel(labels, id); err == nil {
+			name = l.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Filter returns the Gmail filter s describes, looking its labels up in
+// labels. It returns an error wrapping ErrLabelNotFound for a label that
+// does not exist.
+func (s *FilterSpec) Filter(labels []*gmail.Label) (*gmail.Filter, error) {
+	add, err := LabelIDs(labels, s.AddLabels)
+	if err != nil {
+		return nil, err
+	}
+	remove, err := LabelIDs(labels, s.RemoveLabels)
+	if err != nil {
+		return nil, err
+	}
+	return &gmail.Filter{
+		Id: s.ID,
+		Criteria: &gmail.FilterCriteria{
+			From:           s.From,
+			To:             s.To,
+			Subject:        s.Subject,
+			Query:          s.Query,
+			NegatedQuery:   s.NegatedQuery,
+			HasAttachment:  s.HasAttachment,
+			ExcludeChats:   s.ExcludeChats,
+			Size:           s.Size,
+			SizeComparison: s.SizeComparison,
+		},
+		Action: &gmail.FilterAction{
+			AddLabelIds:    add,
+			RemoveLabelIds: remove,
+			Forward:        s.Forward,
+		},
+	}, nil
+}
+
+// ReadFilterSpecs reads a filters file: a YAML document with a list of
+// filters under "filters".
+func ReadFilterSpecs(r io.Reader) ([]*FilterSpec, error) {
+	var f filtersFile
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("ReadFilterSpecs: %w", err)
+	}
+	return f.Filters, nil
+}
+
+// WriteFilterSpecs writes specs to w as a filters file.
+func WriteFilterSpecs(w io.Writer, specs []*FilterSpec) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(filtersFile{Filters: specs}); err != nil {
+		return fmt.Errorf("WriteFilterSpecs: %w", err)
+	}
+	return enc.Close()
+}
+
+// PlanFilters compares the filters a mailbox has with the filters it should
+// have and returns those of want to create and those of have to delete.
+// Filters match when their criteria and actions are the same; IDs are
+// ignored, and label order does not matter.
+func PlanFilters(have, want []*gmail.Filter) (create, remove []*gmail.Filter) {
+	count := map[string]int{}
+	for _, f := range have {
+		count[filterKey(f)]++
+	}
+	for _, f := range want {
+		k := filterKey(f)
+		if count[k] > 0 {
+			count[k]--
+			continue
+		}
+		create = append(create, f)
+	}
+	for _, f := range have {
+		k := filterKey(f)
+		if count[k] > 0 {
+			count[k]--
+			remove = append(remove, f)
+		}
+	}
+	return create, remove
+}
+
+// filterKey returns a string identifying the criteria and actions of f.
+func filterKey(f *gmail.Filter) string {
+	c := gmail.FilterCriteria{}
+	if f.Criteria != nil {
+		c = *f.Criteria
+	}
+	a := gmail.FilterAction{}
+	if f.Action != nil {
+		a = *f.Action
+	}
+	a.AddLabelIds = sortedCopy(a.AddLabelIds)
+	a.RemoveLabelIds = sortedCopy(a.RemoveLabelIds)
+	b, _ := json.Marshal(struct {
+		C gmail.FilterCriteria
+		A gmail.FilterAction
+	}{c, a})
+	return string(b)
+}
+
+func sortedCopy(s []string) []string {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	return s
+}
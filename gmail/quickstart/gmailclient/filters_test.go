@@ -0,0 +1,86 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestFilterSpecRoundTrip(t *testing.T) {
+	labels := []*gmail.Label{
+		{Id: "INBOX", Name: "INBOX", Type: "system"},
+		{Id: "Label_1", Name: "Receipts"},
+	}
+	f := &gmail.Filter{
+		Id:       "ANe1",
+		Criteria: &gmail.FilterCriteria{From: "billing@example.com", HasAttachment: true},
+		Action:   &gmail.FilterAction{AddLabelIds: []string{"Label_1"}, RemoveLabelIds: []string{"INBOX"}},
+	}
+
+	var buf bytes.Buffer
+	if err := gmailclient.WriteFilterSpecs(&buf, []*gmailclient.FilterSpec{gmailclient.NewFilterSpec(f, labels)}); err != nil {
+		t.Fatal(err)
+	}
+	want := `filters:
+  - id: ANe1
+    from: billing@example.com
+    has_attachment: true
+    add_labels:
+      - Receipts
+    remove_labels:
+      - INBOX
+`
+	if buf.String() != want {
+		t.Errorf("WriteFilterSpecs =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	specs, err := gmailclient.ReadFilterSpecs(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := specs[0].Filter(labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if create, remove := gmailclient.PlanFilters([]*gmail.Filter{f}, []*gmail.Filter{got}); len(create) != 0 || len(remove) != 0 {
+		t.Errorf("PlanFilters after a round trip = %v, %v", create, remove)
+	}
+
+	if _, err := gmailclient.ReadFilterSpecs(bytes.NewBufferString("filters:\n  - form: typo@example.com\n")); err == nil {
+		t.Error("ReadFilterSpecs accepted an unknown field")
+	}
+}
+
+func TestPlanFilters(t *testing.T) {
+	keep := &gmail.Filter{Id: "1", Criteria: &gmail.FilterCriteria{From: "a@example.com"}, Action: &gmail.FilterAction{AddLabelIds: []string{"L1", "L2"}}}
+	old := &gmail.Filter{Id: "2", Criteria: &gmail.FilterCriteria{From: "b@example.com"}, Action: &gmail.FilterAction{RemoveLabelIds: []string{"INBOX"}}}
+	same := &gmail.Filter{Criteria: &gmail.FilterCriteria{From: "a@example.com"}, Action: &gmail.FilterAction{AddLabelIds: []string{"L2", "L1"}}}
+	added := &gmail.Filter{Criteria: &gmail.FilterCriteria{Subject: "invoice"}, Action: &gmail.FilterAction{AddLabelIds: []string{"L1"}}}
+
+	create, remove := gmailclient.PlanFilters([]*gmail.Filter{keep, old}, []*gmail.Filter{same, added})
+	if len(create) != 1 || create[0] != added {
+		t.Errorf("create = %v, want the new filter", create)
+	}
+	if len(remove) != 1 || remove[0] != old {
+		t.Errorf("remove = %v, want the old filter", remove)
+	}
+}
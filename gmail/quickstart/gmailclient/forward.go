@@ -0,0 +1,120 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// NewForward returns a forward of orig with the subject filled in. The
+// recipients and bodies are left for the caller: either attach the original
+// with AttachMessage, or include it with ForwardedText, ForwardedHTML and
+// ForwardAttachments.
+func NewForward(orig *Message) *OutgoingMessage {
+	subject := orig.Subject
+	if len(subject) < 4 || !strings.EqualFold(subject[:4], "fwd:") {
+		subject = "Fwd: " + subject
+	}
+	return &OutgoingMessage{Subject: subject}
+}
+
+// AttachMessage attaches the raw original to m as a message/rfc822 part,
+// which the recipient's client shows as a message of its own with its
+// headers and attachments intact.
+func (m *OutgoingMessage) AttachMessage(orig *Message, raw []byte) {
+	m.Attachments = append(m.Attachments, &OutgoingAttachment{
+		Filename: forwardFilename(orig.Subject),
+		MimeType: "message/rfc822",
+		Data:     raw,
+	})
+}
+
+// forwardFilename returns a file name for an attached message.
+func forwardFilename(subject string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(subject))
+	if name == "" {
+		name = "message"
+	}
+	return name + ".eml"
+}
+
+// forwardHeader returns the header block Gmail puts above a forwarded
+// message, as lines of "Name: value".
+func forwardHeader(orig *Message) []string {
+	lines := []string{"From: " + FormatAddressList(orig.From)}
+	if !orig.Date.IsZero() {
+		lines = append(lines, "Date: "+orig.Date.Format("Mon, Jan 2, 2006 at 3:04 PM"))
+	}
+	lines = append(lines, "Subject: "+orig.Subject, "To: "+FormatAddressList(orig.To))
+	if len(orig.Cc) > 0 {
+		lines = append(lines, "Cc: "+FormatAddressList(orig.Cc))
+	}
+	return lines
+}
+
+const forwardSeparator = "---------- Forwarded message ---------"
+
+// ForwardedText returns orig as the plain-text body of a forward: its
+// headers under a separator line, then its plain-text body, or the text of
+// its HTML body.
+func ForwardedText(orig *Message) string {
+	body := orig.BodyPlain
+	if body == "" && orig.BodyHtml != "" {
+		body, _ = HTMLToText(orig.BodyHtml)
+	}
+	return forwardSeparator + "\n" + strings.Join(forwardHeader(orig), "\n") + "\n\n" + body
+}
+
+// ForwardedHTML is ForwardedText for the HTML body.
+func ForwardedHTML(orig *Message) string {
+	body := orig.BodyHtml
+	if body == "" {
+		body = "<pre>" + html.EscapeString(orig.BodyPlain) + "</pre>"
+	}
+	var b strings.Builder
+	b.WriteString(`<div class="gmail_quote">` + html.EscapeString(forwardSeparator) + "<br>\n")
+	for _, l := range forwardHeader(orig) {
+		b.WriteString(html.EscapeString(l) + "<br>\n")
+	}
+	b.WriteString("<br>\n" + body + "</div>\n")
+	return b.String()
+}
+
+// ForwardAttachments downloads the attachments of orig, parsed from
+// gmailMessage, for carrying them along in an inline forward.
+func ForwardAttachments(ctx context.Context, srv GmailService, user string, gmailMessage *gmail.Message, orig *Message) ([]*OutgoingAttachment, error) {
+	var list []*OutgoingAttachment
+	for _, a := range orig.Attachments {
+		data, err := GetAttachmentData(ctx, srv, user, gmailMessage, a)
+		if err != nil {
+			return nil, fmt.Errorf("ForwardAttachments %s: %w", a.Filename, err)
+		}
+		list = append(list, &OutgoingAttachment{Filename: a.Filename, MimeType: a.MimeType, Data: data})
+	}
+	return list, nil
+}
@@ -0,0 +1,73 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestForward(t *testing.T) {
+	orig := &gmailclient.Message{
+		From:      gmailclient.ParseAddressList("Ana Lima <ana@example.com>"),
+		To:        gmailclient.ParseAddressList("me@example.com"),
+		Subject:   "Contract draft",
+		BodyPlain: "See attached.",
+	}
+	raw := []byte("From: ana@example.com\r\nSubject: Contract draft\r\n\r\nSee attached.\r\n")
+
+	f := gmailclient.NewForward(orig)
+	if f.Subject != "Fwd: Contract draft" {
+		t.Errorf("Subject = %q", f.Subject)
+	}
+	if text := gmailclient.ForwardedText(orig); !strings.Contains(text, "Forwarded message") || !strings.HasSuffix(text, "\n\nSee attached.") {
+		t.Errorf("ForwardedText = %q", text)
+	}
+
+	f.To = gmailclient.ParseAddressList("legal@example.com")
+	f.Text = "FYI"
+	f.AttachMessage(orig, raw)
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	r.NextPart()
+	p, err := r.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); ct != "message/rfc822" || p.FileName() != "Contract draft.eml" {
+		t.Errorf("attached part %q named %q", ct, p.FileName())
+	}
+	if data, _ := ioutil.ReadAll(p); !bytes.Equal(data, raw) {
+		t.Errorf("attached message = %q, want %q", data, raw)
+	}
+}
@@ -0,0 +1,349 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclienttest
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// modifyLabels adds the labels add to m and removes the labels remove.
+func modifyLabels(m *gmail.Message, add, remove []string) {
+	for _, id := range add {
+		if !hasString(m.LabelIds, id) {
+			m.LabelIds = append(m.LabelIds, id)
+		}
+	}
+	m.LabelIds = removeStrings(m.LabelIds, remove...)
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeStrings(list []string, remove ...string) []string {
+	var kept []string
+	for _, v := range list {
+		if !hasString(remove, v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func (s *Service) ModifyMessage(ctx context.Context, user, id string, req *gmail.ModifyMessageRequest) (*gmail.Message, error) {
+	m, err := s.GetMessage(ctx, user, id, "minimal")
+	if err != nil {
+		return nil, err
+	}
+	modifyLabels(m, req.AddLabelIds, req.RemoveLabelIds)
+	return m, nil
+}
+
+// BatchModifyMessages fails without changing anything if a message does not
+// exist.
+func (s *Service) BatchModifyMessages(ctx context.Context, user string, req *gmail.BatchModifyMessagesRequest) error {
+	var msgs []*gmail.Message
+	for _, id := range req.Ids {
+		m, err := s.GetMessage(ctx, user, id, "minimal")
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, m)
+	}
+	for _, m := range msgs {
+		modifyLabels(m, req.AddLabelIds, req.RemoveLabelIds)
+	}
+	return nil
+}
+
+// BatchDeleteMessages removes the messages ids; like the API, it ignores IDs
+// it does not know.
+func (s *Service) BatchDeleteMessages(ctx context.Context, user string, ids []string) error {
+	var kept []*gmail.Message
+	for _, m := range s.Messages {
+		if !hasString(ids, m.Id) {
+			kept = append(kept, m)
+		}
+	}
+	s.Messages = kept
+	return nil
+}
+
+func (s *Service) TrashMessage(ctx context.Context, user, id string) (*gmail.Message, error) {
+	return s.ModifyMessage(ctx, user, id, &gmail.ModifyMessageRequest{AddLabelIds: []string{"TRASH"}})
+}
+
+func (s *Service) UntrashMessage(ctx context.Context, user, id string) (*gmail.Message, error) {
+	return s.ModifyMessage(ctx, user, id, &gmail.ModifyMessageRequest{RemoveLabelIds: []string{"TRASH"}})
+}
+
+// SendMessage adds raw to Messages with the SENT label, in the thread
+// threadId if it is set and a new one otherwise.
+func (s *Service) SendMessage(ctx context.Context, user string, raw []byte, threadId string) (*gmail.Message, error) {
+	m := &gmail.Message{Id: s.newID("sent"), ThreadId: threadId, LabelIds: []string{"SENT"}, Raw: base64.URLEncoding.EncodeToString(raw)}
+	if m.ThreadId == "" {
+		m.ThreadId = m.Id
+	}
+	s.AddMessage(m)
+	return &gmail.Message{Id: m.Id, ThreadId: m.ThreadId, LabelIds: m.LabelIds}, nil
+}
+
+// threadIDs returns the IDs of the threads of Messages, in the order their
+// first messages were added.
+func (s *Service) threadIDs() []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, m := range s.Messages {
+		if !seen[m.ThreadId] {
+			seen[m.ThreadId] = true
+			ids = append(ids, m.ThreadId)
+		}
+	}
+	return ids
+}
+
+// ListThreads pages through the threads of Messages, grouped by ThreadId,
+// regardless of the query. Page tokens are offsets, as for ListMessages.
+func (s *Service) ListThreads(ctx context.Context, user, query, pageToken string, maxResults int64) (*gmail.ListThreadsResponse, error) {
+	ids := s.threadIDs()
+	start := 0
+	if pageToken != "" {
+		var err error
+		if start, err = strconv.Atoi(pageToken); err != nil {
+			return nil, fmt.Errorf("gmailclienttest: bad page token %q", pageToken)
+		}
+	}
+	end := len(ids)
+	if maxResults > 0 && start+int(maxResults) < end {
+		end = start + int(maxResults)
+	}
+	if s.PageSize > 0 && start+s.PageSize < end {
+		end = start + s.PageSize
+	}
+	r := &gmail.ListThreadsResponse{ResultSizeEstimate: int64(len(ids))}
+	for _, id := range ids[start:end] {
+		r.Threads = append(r.Threads, &gmail.Thread{Id: id})
+	}
+	if end < len(ids) {
+		r.NextPageToken = strconv.Itoa(end)
+	}
+	return r, nil
+}
+
+// GetThread returns the messages of thread id as they were added; format
+// and headers are ignored.
+func (s *Service) GetThread(ctx context.Context, user, id, format string, headers ...string) (*gmail.Thread, error) {
+	t := &gmail.Thread{Id: id}
+	for _, m := range s.Messages {
+		if m.ThreadId == id {
+			t.Messages = append(t.Messages, m)
+		}
+	}
+	if len(t.Messages) == 0 {
+		return nil, notFound("thread", id)
+	}
+	return t, nil
+}
+
+func (s *Service) ModifyThread(ctx context.Context, user, id string, req *gmail.ModifyThreadRequest) (*gmail.Thread, error) {
+	t, err := s.GetThread(ctx, user, id, "minimal")
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range t.Messages {
+		modifyLabels(m, req.AddLabelIds, req.RemoveLabelIds)
+	}
+	return t, nil
+}
+
+// ListLabels returns copies of Labels, as the API returns new values.
+func (s *Service) ListLabels(ctx context.Context, user string) ([]*gmail.Label, error) {
+	var labels []*gmail.Label
+	for _, l := range s.Labels {
+		c := *l
+		labels = append(labels, &c)
+	}
+	return labels, nil
+}
+
+func (s *Service) GetLabel(ctx context.Context, user, id string) (*gmail.Label, error) {
+	l, err := s.label(id)
+	if err != nil {
+		return nil, err
+	}
+	c := *l
+	return &c, nil
+}
+
+func (s *Service) label(id string) (*gmail.Label, error) {
+	for _, l := range s.Labels {
+		if l.Id == id {
+			return l, nil
+		}
+	}
+	return nil, notFound("label", id)
+}
+
+// CreateLabel fails, like the API, if a label of that name exists.
+func (s *Service) CreateLabel(ctx context.Context, user string, l *gmail.Label) (*gmail.Label, error) {
+	for _, o := range s.Labels {
+		if strings.EqualFold(o.Name, l.Name) {
+			return nil, fmt.Errorf("gmailclienttest: label %s exists", l.Name)
+		}
+	}
+	c := *l
+	c.Id, c.Type = s.newID("Label_"), "user"
+	s.Labels = append(s.Labels, &c)
+	created := c
+	return &created, nil
+}
+
+// PatchLabel changes the name, visibility and color of label id where set
+// in l.
+func (s *Service) PatchLabel(ctx context.Context, user, id string, l *gmail.Label) (*gmail.Label, error) {
+	o, err := s.label(id)
+	if err != nil {
+		return nil, err
+	}
+	if l.Name != "" {
+		o.Name = l.Name
+	}
+	if l.LabelListVisibility != "" {
+		o.LabelListVisibility = l.LabelListVisibility
+	}
+	if l.MessageListVisibility != "" {
+		o.MessageListVisibility = l.MessageListVisibility
+	}
+	if l.Color != nil {
+		o.Color = l.Color
+	}
+	c := *o
+	return &c, nil
+}
+
+// DeleteLabel deletes label id and takes it off the messages.
+func (s *Service) DeleteLabel(ctx context.Context, user, id string) error {
+	if _, err := s.label(id); err != nil {
+		return err
+	}
+	var kept []*gmail.Label
+	for _, l := range s.Labels {
+		if l.Id != id {
+			kept = append(kept, l)
+		}
+	}
+	s.Labels = kept
+	for _, m := range s.Messages {
+		m.LabelIds = removeStrings(m.LabelIds, id)
+	}
+	return nil
+}
+
+func (s *Service) CreateDraft(ctx context.Context, user string, raw []byte, threadId string) (*gmail.Draft, error) {
+	d := &gmail.Draft{Id: s.newID("r")}
+	d.Message = &gmail.Message{Id: s.newID("draft"), ThreadId: threadId, LabelIds: []string{"DRAFT"}, Raw: base64.URLEncoding.EncodeToString(raw)}
+	s.Drafts = append(s.Drafts, d)
+	return d, nil
+}
+
+func (s *Service) UpdateDraft(ctx context.Context, user, id string, raw []byte, threadId string) (*gmail.Draft, error) {
+	d, err := s.GetDraft(ctx, user, id, "raw")
+	if err != nil {
+		return nil, err
+	}
+	d.Message.ThreadId, d.Message.Raw = threadId, base64.URLEncoding.EncodeToString(raw)
+	return d, nil
+}
+
+// GetDraft returns the draft with its message as stored; format is ignored.
+func (s *Service) GetDraft(ctx context.Context, user, id, format string) (*gmail.Draft, error) {
+	for _, d := range s.Drafts {
+		if d.Id == id {
+			return d, nil
+		}
+	}
+	return nil, notFound("draft", id)
+}
+
+// ListDrafts returns all drafts in one page, regardless of the query.
+func (s *Service) ListDrafts(ctx context.Context, user, query, pageToken string) (*gmail.ListDraftsResponse, error) {
+	r := &gmail.ListDraftsResponse{ResultSizeEstimate: int64(len(s.Drafts))}
+	for _, d := range s.Drafts {
+		r.Drafts = append(r.Drafts, &gmail.Draft{Id: d.Id, Message: &gmail.Message{Id: d.Message.Id, ThreadId: d.Message.ThreadId}})
+	}
+	return r, nil
+}
+
+// SendDraft sends the message of draft id as SendMessage does and deletes
+// the draft.
+func (s *Service) SendDraft(ctx context.Context, user, id string) (*gmail.Message, error) {
+	d, err := s.GetDraft(ctx, user, id, "raw")
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.URLEncoding.DecodeString(d.Message.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DeleteDraft(ctx, user, id); err != nil {
+		return nil, err
+	}
+	return s.SendMessage(ctx, user, raw, d.Message.ThreadId)
+}
+
+func (s *Service) DeleteDraft(ctx context.Context, user, id string) error {
+	for i, d := range s.Drafts {
+		if d.Id == id {
+			s.Drafts = append(s.Drafts[:i:i], s.Drafts[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("draft", id)
+}
+
+func (s *Service) ListFilters(ctx context.Context, user string) ([]*gmail.Filter, error) {
+	return append([]*gmail.Filter(nil), s.Filters...), nil
+}
+
+func (s *Service) CreateFilter(ctx context.Context, user string, f *gmail.Filter) (*gmail.Filter, error) {
+	c := *f
+	c.Id = s.newID("filter")
+	s.Filters = append(s.Filters, &c)
+	return &c, nil
+}
+
+func (s *Service) DeleteFilter(ctx context.Context, user, id string) error {
+	for i, f := range s.Filters {
+		if f.Id == id {
+			s.Filters = append(s.Filters[:i:i], s.Filters[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("filter", id)
+}
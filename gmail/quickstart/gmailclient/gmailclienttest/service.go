@@ -0,0 +1,203 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gmailclienttest provides an in-memory gmailclient.GmailService,
+// which also implements the feature interfaces such as LabelService, for
+// tests.
+package gmailclienttest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Service is an in-memory fake of gmailclient.GmailService and the feature
+// interfaces. Messages are returned by ListMessages in the order they were
+// added, regardless of the query.
+type Service struct {
+	Messages    []*gmail.Message
+	Attachments map[string]*gmail.MessagePartBody
+
+	// PageSize caps the number of messages per ListMessages page, like the
+	// API's own limit of 500. Zero means no cap.
+	PageSize int
+
+	// History is returned by ListHistory, and HistoryId by GetProfile and
+	// ListHistory. ListHistory fails with a 404 error, as for expired
+	// history, when asked to start before OldestHistoryId.
+	History         []*gmail.History
+	HistoryId       uint64
+	OldestHistoryId uint64
+
+	// Labels, Drafts and Filters are the mailbox's labels, drafts and
+	// filters, changed by the calls that create, change and delete them.
+	// Sent and drafted messages go to Messages and Drafts with their
+	// raw source in Raw.
+	Labels  []*gmail.Label
+	Drafts  []*gmail.Draft
+	Filters []*gmail.Filter
+
+	// The settings, returned and replaced by the settings calls. The
+	// single settings read as empty until set.
+	Vacation            *gmail.VacationSettings
+	AutoForwarding      *gmail.AutoForwarding
+	Imap                *gmail.ImapSettings
+	Pop                 *gmail.PopSettings
+	Language            *gmail.LanguageSettings
+	SendAs              []*gmail.SendAs
+	ForwardingAddresses []*gmail.ForwardingAddress
+	Delegates           []*gmail.Delegate
+	SmimeInfo           map[string][]*gmail.SmimeInfo // by send-as address
+
+	lastID int // for the IDs of created messages, labels and so on
+}
+
+// New returns an empty Service.
+func New() *Service {
+	return &Service{Attachments: map[string]*gmail.MessagePartBody{}, SmimeInfo: map[string][]*gmail.SmimeInfo{}}
+}
+
+// newID returns a fresh ID starting with prefix.
+func (s *Service) newID(prefix string) string {
+	s.lastID++
+	return prefix + strconv.Itoa(s.lastID)
+}
+
+func notFound(kind, id string) error {
+	return fmt.Errorf("gmailclienttest: %s %s not found", kind, id)
+}
+
+// AddMessage adds m to the fake mailbox.
+func (s *Service) AddMessage(m *gmail.Message) {
+	s.Messages = append(s.Messages, m)
+}
+
+// AddAttachment registers the body returned for attachmentId.
+func (s *Service) AddAttachment(attachmentId string, body *gmail.MessagePartBody) {
+	s.Attachments[attachmentId] = body
+}
+
+// LoadMessage reads a JSON encoded gmail.Message fixture, as returned by the
+// API for the "full" format, and adds it to the fake mailbox.
+func (s *Service) LoadMessage(path string) (*gmail.Message, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &gmail.Message{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("LoadMessage %s: %w", path, err)
+	}
+	s.AddMessage(m)
+	return m, nil
+}
+
+// GetMessage returns the message as it was added; format and fields are
+// ignored.
+func (s *Service) GetMessage(ctx context.Context, user, id, format string, fields ...googleapi.Field) (*gmail.Message, error) {
+	for _, m := range s.Messages {
+		if m.Id == id {
+			return m, nil
+		}
+	}
+	return nil, notFound("message", id)
+}
+
+// GetMessageMetadata returns a copy of the message without parts or body,
+// keeping only the named headers.
+func (s *Service) GetMessageMetadata(ctx context.Context, user, id string, headers []string, fields ...googleapi.Field) (*gmail.Message, error) {
+	m, err := s.GetMessage(ctx, user, id, "metadata")
+	if err != nil {
+		return nil, err
+	}
+	c := *m
+	c.Payload = &gmail.MessagePart{}
+	if m.Payload != nil {
+		c.Payload.MimeType = m.Payload.MimeType
+		for _, h := range m.Payload.Headers {
+			for _, name := range headers {
+				if strings.EqualFold(h.Name, name) {
+					c.Payload.Headers = append(c.Payload.Headers, h)
+					break
+				}
+			}
+		}
+	}
+	return &c, nil
+}
+
+// ListMessages pages through the fake mailbox. Page tokens are offsets into
+// Messages.
+func (s *Service) ListMessages(ctx context.Context, user, query, pageToken string, maxResults int64) (*gmail.ListMessagesResponse, error) {
+	start := 0
+	if pageToken != "" {
+		var err error
+		if start, err = strconv.Atoi(pageToken); err != nil {
+			return nil, fmt.Errorf("gmailclienttest: bad page token %q", pageToken)
+		}
+	}
+	end := len(s.Messages)
+	if maxResults > 0 && start+int(maxResults) < end {
+		end = start + int(maxResults)
+	}
+	if s.PageSize > 0 && start+s.PageSize < end {
+		end = start + s.PageSize
+	}
+	r := &gmail.ListMessagesResponse{ResultSizeEstimate: int64(len(s.Messages))}
+	for _, m := range s.Messages[start:end] {
+		r.Messages = append(r.Messages, &gmail.Message{Id: m.Id, ThreadId: m.ThreadId})
+	}
+	if end < len(s.Messages) {
+		r.NextPageToken = strconv.Itoa(end)
+	}
+	return r, nil
+}
+
+func (s *Service) GetAttachment(ctx context.Context, user, messageId, attachmentId string) (*gmail.MessagePartBody, error) {
+	body, ok := s.Attachments[attachmentId]
+	if !ok {
+		return nil, notFound("attachment", attachmentId)
+	}
+	return body, nil
+}
+
+func (s *Service) GetProfile(ctx context.Context, user string) (*gmail.Profile, error) {
+	return &gmail.Profile{EmailAddress: user, HistoryId: s.HistoryId, MessagesTotal: int64(len(s.Messages))}, nil
+}
+
+// ListHistory returns the History records after startHistoryId in a single
+// page.
+func (s *Service) ListHistory(ctx context.Context, user string, startHistoryId uint64, pageToken string) (*gmail.ListHistoryResponse, error) {
+	if startHistoryId < s.OldestHistoryId {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "Requested entity was not found."}
+	}
+	r := &gmail.ListHistoryResponse{HistoryId: s.HistoryId}
+	for _, h := range s.History {
+		if h.Id > startHistoryId {
+			r.History = append(r.History, h)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,227 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclienttest
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func (s *Service) GetVacation(ctx context.Context, user string) (*gmail.VacationSettings, error) {
+	if s.Vacation == nil {
+		return &gmail.VacationSettings{}, nil
+	}
+	return s.Vacation, nil
+}
+
+func (s *Service) UpdateVacation(ctx context.Context, user string, v *gmail.VacationSettings) (*gmail.VacationSettings, error) {
+	s.Vacation = v
+	return v, nil
+}
+
+func (s *Service) GetAutoForwarding(ctx context.Context, user string) (*gmail.AutoForwarding, error) {
+	if s.AutoForwarding == nil {
+		return &gmail.AutoForwarding{}, nil
+	}
+	return s.AutoForwarding, nil
+}
+
+func (s *Service) UpdateAutoForwarding(ctx context.Context, user string, f *gmail.AutoForwarding) (*gmail.AutoForwarding, error) {
+	s.AutoForwarding = f
+	return f, nil
+}
+
+func (s *Service) GetImap(ctx context.Context, user string) (*gmail.ImapSettings, error) {
+	if s.Imap == nil {
+		return &gmail.ImapSettings{}, nil
+	}
+	return s.Imap, nil
+}
+
+func (s *Service) UpdateImap(ctx context.Context, user string, i *gmail.ImapSettings) (*gmail.ImapSettings, error) {
+	s.Imap = i
+	return i, nil
+}
+
+func (s *Service) GetPop(ctx context.Context, user string) (*gmail.PopSettings, error) {
+	if s.Pop == nil {
+		return &gmail.PopSettings{}, nil
+	}
+	return s.Pop, nil
+}
+
+func (s *Service) UpdatePop(ctx context.Context, user string, p *gmail.PopSettings) (*gmail.PopSettings, error) {
+	s.Pop = p
+	return p, nil
+}
+
+func (s *Service) GetLanguage(ctx context.Context, user string) (*gmail.LanguageSettings, error) {
+	if s.Language == nil {
+		return &gmail.LanguageSettings{}, nil
+	}
+	return s.Language, nil
+}
+
+func (s *Service) UpdateLanguage(ctx context.Context, user string, l *gmail.LanguageSettings) (*gmail.LanguageSettings, error) {
+	s.Language = l
+	return l, nil
+}
+
+func (s *Service) ListSendAs(ctx context.Context, user string) ([]*gmail.SendAs, error) {
+	return append([]*gmail.SendAs(nil), s.SendAs...), nil
+}
+
+func (s *Service) GetSendAs(ctx context.Context, user, email string) (*gmail.SendAs, error) {
+	for _, a := range s.SendAs {
+		if strings.EqualFold(a.SendAsEmail, email) {
+			return a, nil
+		}
+	}
+	return nil, notFound("send-as alias", email)
+}
+
+// CreateSendAs adds the alias as accepted; the fake sends no verification
+// mail.
+func (s *Service) CreateSendAs(ctx context.Context, user string, a *gmail.SendAs) (*gmail.SendAs, error) {
+	c := *a
+	c.VerificationStatus = "accepted"
+	s.SendAs = append(s.SendAs, &c)
+	return &c, nil
+}
+
+// PatchSendAs changes the display name, reply-to address and signature of
+// the alias where set in a, or listed in a.ForceSendFields.
+func (s *Service) PatchSendAs(ctx context.Context, user, email string, a *gmail.SendAs) (*gmail.SendAs, error) {
+	o, err := s.GetSendAs(ctx, user, email)
+	if err != nil {
+		return nil, err
+	}
+	force := func(field string) bool { return hasString(a.ForceSendFields, field) }
+	if a.DisplayName != "" || force("DisplayName") {
+		o.DisplayName = a.DisplayName
+	}
+	if a.ReplyToAddress != "" || force("ReplyToAddress") {
+		o.ReplyToAddress = a.ReplyToAddress
+	}
+	if a.Signature != "" || force("Signature") {
+		o.Signature = a.Signature
+	}
+	if a.IsDefault {
+		for _, other := range s.SendAs {
+			other.IsDefault = false
+		}
+		o.IsDefault = true
+	}
+	return o, nil
+}
+
+func (s *Service) DeleteSendAs(ctx context.Context, user, email string) error {
+	for i, a := range s.SendAs {
+		if strings.EqualFold(a.SendAsEmail, email) {
+			s.SendAs = append(s.SendAs[:i:i], s.SendAs[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("send-as alias", email)
+}
+
+func (s *Service) VerifySendAs(ctx context.Context, user, email string) error {
+	_, err := s.GetSendAs(ctx, user, email)
+	return err
+}
+
+func (s *Service) ListForwardingAddresses(ctx context.Context, user string) ([]*gmail.ForwardingAddress, error) {
+	return append([]*gmail.ForwardingAddress(nil), s.ForwardingAddresses...), nil
+}
+
+// CreateForwardingAddress adds the address as accepted, as for CreateSendAs.
+func (s *Service) CreateForwardingAddress(ctx context.Context, user string, f *gmail.ForwardingAddress) (*gmail.ForwardingAddress, error) {
+	c := *f
+	c.VerificationStatus = "accepted"
+	s.ForwardingAddresses = append(s.ForwardingAddresses, &c)
+	return &c, nil
+}
+
+func (s *Service) DeleteForwardingAddress(ctx context.Context, user, email string) error {
+	for i, f := range s.ForwardingAddresses {
+		if strings.EqualFold(f.ForwardingEmail, email) {
+			s.ForwardingAddresses = append(s.ForwardingAddresses[:i:i], s.ForwardingAddresses[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("forwarding address", email)
+}
+
+func (s *Service) ListDelegates(ctx context.Context, user string) ([]*gmail.Delegate, error) {
+	return append([]*gmail.Delegate(nil), s.Delegates...), nil
+}
+
+func (s *Service) CreateDelegate(ctx context.Context, user string, d *gmail.Delegate) (*gmail.Delegate, error) {
+	c := *d
+	c.VerificationStatus = "accepted"
+	s.Delegates = append(s.Delegates, &c)
+	return &c, nil
+}
+
+func (s *Service) DeleteDelegate(ctx context.Context, user, email string) error {
+	for i, d := range s.Delegates {
+		if strings.EqualFold(d.DelegateEmail, email) {
+			s.Delegates = append(s.Delegates[:i:i], s.Delegates[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("delegate", email)
+}
+
+func (s *Service) ListSmimeInfo(ctx context.Context, user, email string) ([]*gmail.SmimeInfo, error) {
+	return append([]*gmail.SmimeInfo(nil), s.SmimeInfo[email]...), nil
+}
+
+// InsertSmimeInfo stores info as it is; the fake does not read the PKCS#12
+// file.
+func (s *Service) InsertSmimeInfo(ctx context.Context, user, email string, info *gmail.SmimeInfo) (*gmail.SmimeInfo, error) {
+	c := *info
+	c.Id = s.newID("smime")
+	s.SmimeInfo[email] = append(s.SmimeInfo[email], &c)
+	return &c, nil
+}
+
+func (s *Service) SetDefaultSmimeInfo(ctx context.Context, user, email, id string) error {
+	found := false
+	for _, info := range s.SmimeInfo[email] {
+		info.IsDefault = info.Id == id
+		found = found || info.IsDefault
+	}
+	if !found {
+		return notFound("S/MIME certificate", id)
+	}
+	return nil
+}
+
+func (s *Service) DeleteSmimeInfo(ctx context.Context, user, email, id string) error {
+	infos := s.SmimeInfo[email]
+	for i, info := range infos {
+		if info.Id == id {
+			s.SmimeInfo[email] = append(infos[:i:i], infos[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("S/MIME certificate", id)
+}
@@ -0,0 +1,98 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GrepOptions are the settings of GrepMessage.
+type GrepOptions struct {
+	// Context is the number of lines to include before and after each
+	// matching line.
+	Context int
+
+	// Headers also searches the header values, each as one line.
+	Headers bool
+
+	// HTML searches the HTML body, links and all, instead of the
+	// plain-text body or the text of the HTML one.
+	HTML bool
+}
+
+// A GrepMatch is a line of a message that matches a regular expression.
+type GrepMatch struct {
+	Part    string   `json:"part"` // "body", or the name of a header
+	Line    int      `json:"line"` // counted from 1 within the part
+	Text    string   `json:"text"`
+	Matches []string `json:"matches"` // the matching parts of Text
+	Before  []string `json:"before,omitempty"`
+	After   []string `json:"after,omitempty"`
+}
+
+// GrepMessage returns the lines of m that match re: the lines of the
+// plain-text body, or failing that of the text of the HTML body, and with
+// opts.Headers the header values. Lines are searched one at a time, so re
+// cannot match across lines. Embedded messages are not searched.
+func GrepMessage(m *Message, re *regexp.Regexp, opts GrepOptions) []*GrepMatch {
+	var matches []*GrepMatch
+	if opts.Headers {
+		for _, f := range m.Headers {
+			v := DecodeHeader(f.Value)
+			if found := re.FindAllString(v, -1); found != nil {
+				matches = append(matches, &GrepMatch{Part: f.Name, Line: 1, Text: v, Matches: found})
+			}
+		}
+	}
+	body := m.BodyPlain
+	switch {
+	case opts.HTML && m.BodyHtml != "":
+		body = m.BodyHtml
+	case body == "" && m.BodyHtml != "":
+		if text, err := HTMLToText(m.BodyHtml); err == nil {
+			body = text
+		}
+	}
+	body = strings.TrimRight(strings.Replace(body, "\r\n", "\n", -1), "\n")
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		found := re.FindAllString(line, -1)
+		if found == nil {
+			continue
+		}
+		g := &GrepMatch{Part: "body", Line: i + 1, Text: line, Matches: found}
+		if opts.Context > 0 {
+			from, to := i-opts.Context, i+opts.Context+1
+			if from < 0 {
+				from = 0
+			}
+			if to > len(lines) {
+				to = len(lines)
+			}
+			if from < i {
+				g.Before = lines[from:i]
+			}
+			if i+1 < to {
+				g.After = lines[i+1 : to]
+			}
+		}
+		matches = append(matches, g)
+	}
+	return matches
+}
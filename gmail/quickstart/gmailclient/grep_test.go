@@ -0,0 +1,55 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestGrepMessage(t *testing.T) {
+	m := &gmailclient.Message{
+		Headers:   gmailclient.Header{{Name: "Subject", Value: "=?UTF-8?Q?Your_code_AB-1234?="}},
+		BodyPlain: "Hello,\r\nyour reset code is AB-1234.\r\nIt expires soon.\r\nOld codes: AB-0001, AB-0002\r\n",
+		BodyHtml:  `<p>Hello,</p><p>your reset code is <a href="https://example.com/r?t=AB-9999">AB-1234</a>.</p>`,
+	}
+	re := regexp.MustCompile(`AB-\d+`)
+
+	got := gmailclient.GrepMessage(m, re, gmailclient.GrepOptions{Context: 1, Headers: true})
+	want := []*gmailclient.GrepMatch{
+		{Part: "Subject", Line: 1, Text: "Your code AB-1234", Matches: []string{"AB-1234"}},
+		{Part: "body", Line: 2, Text: "your reset code is AB-1234.", Matches: []string{"AB-1234"},
+			Before: []string{"Hello,"}, After: []string{"It expires soon."}},
+		{Part: "body", Line: 4, Text: "Old codes: AB-0001, AB-0002", Matches: []string{"AB-0001", "AB-0002"},
+			Before: []string{"It expires soon."}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for _, g := range got {
+			t.Logf("%+v", g)
+		}
+		t.Errorf("GrepMessage did not return the expected matches")
+	}
+
+	got = gmailclient.GrepMessage(m, re, gmailclient.GrepOptions{HTML: true})
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Matches, []string{"AB-9999", "AB-1234"}) {
+		t.Errorf("GrepMessage with HTML = %+v, want the link and the text", got)
+	}
+}
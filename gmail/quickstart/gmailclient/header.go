@@ -0,0 +1,66 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// A HeaderField is one header line of a message, with the value as sent.
+type HeaderField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Header holds all header fields of a message in their original order.
+// Names may repeat, as Received and DKIM-Signature usually do.
+type Header []HeaderField
+
+// Get returns the first value of the named header with its RFC 2047
+// encoded-words decoded, or "" if it is missing. Names are matched
+// case-insensitively.
+func (h Header) Get(name string) string {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return DecodeHeader(f.Value)
+		}
+	}
+	return ""
+}
+
+// Values returns the decoded values of every header with the given name.
+func (h Header) Values(name string) []string {
+	var v []string
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			v = append(v, DecodeHeader(f.Value))
+		}
+	}
+	return v
+}
+
+// partHeader returns the headers of part.
+func partHeader(part *gmail.MessagePart) Header {
+	h := make(Header, len(part.Headers))
+	for i, f := range part.Headers {
+		h[i] = HeaderField{Name: f.Name, Value: f.Value}
+	}
+	return h
+}
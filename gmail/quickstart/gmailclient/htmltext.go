@@ -0,0 +1,118 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// textBlocks lists the elements that start a new line in HTMLToText.
+var textBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Tr: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Blockquote: true, atom.Pre: true, atom.Hr: true,
+	atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Dt: true, atom.Dd: true,
+}
+
+// blankLines matches runs of blank lines.
+var blankLines = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+
+// HTMLToText returns the readable text of an HTML body, for renderers that
+// cannot lay out HTML. Block elements start new lines, list items get a
+// bullet, links keep their URL in parentheses, and scripts and styles are
+// dropped.
+func HTMLToText(s string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return "", fmt.Errorf("HTMLToText: %w", err)
+	}
+	var b strings.Builder
+	var walk func(n *html.Node, pre bool)
+	walk = func(n *html.Node, pre bool) {
+		switch n.Type {
+		case html.TextNode:
+			if pre {
+				b.WriteString(n.Data)
+			} else if t := strings.Join(strings.Fields(n.Data), " "); t != "" {
+				if strings.TrimLeft(n.Data, " \t\r\n") != n.Data && !strings.HasSuffix(b.String(), "\n") {
+					b.WriteByte(' ')
+				}
+				b.WriteString(t)
+				if strings.TrimRight(n.Data, " \t\r\n") != n.Data {
+					b.WriteByte(' ')
+				}
+			}
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Head, atom.Title, atom.Noscript, atom.Template:
+				return
+			case atom.Pre:
+				pre = true
+			case atom.Td, atom.Th:
+				b.WriteByte('\t')
+			}
+			if textBlocks[n.DataAtom] {
+				b.WriteByte('\n')
+			}
+			if n.DataAtom == atom.Li {
+				b.WriteString("• ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, pre)
+		}
+		if n.Type == html.ElementNode {
+			if n.DataAtom == atom.A {
+				for _, a := range n.Attr {
+					if a.Key == "href" && safeURL(a.Val, "http:", "https:") && !strings.Contains(textOf(n), a.Val) {
+						fmt.Fprintf(&b, " (%s)", a.Val)
+					}
+				}
+			}
+			if textBlocks[n.DataAtom] && n.DataAtom != atom.Br && n.DataAtom != atom.Li {
+				b.WriteByte('\n')
+			}
+		}
+	}
+	walk(doc, false)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(strings.TrimLeft(l, " "), " \t")
+	}
+	text := blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text) + "\n", nil
+}
+
+// textOf returns the text content of n.
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var s strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.WriteString(textOf(c))
+	}
+	return s.String()
+}
@@ -0,0 +1,41 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestHTMLToText(t *testing.T) {
+	in := `<html><head><style>p { color: red }</style></head><body>
+<h1>Weekly  tips</h1>
+<p>Hello <b>there</b>,<br>read <a href="https://example.com/post">the post</a>.</p>
+<ul><li>one</li><li>two</li></ul>
+<script>track()</script>
+</body></html>`
+	want := "Weekly tips\n\nHello there,\nread the post (https://example.com/post).\n\n• one\n• two\n"
+	got, err := gmailclient.HTMLToText(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
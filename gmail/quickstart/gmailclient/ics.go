@@ -0,0 +1,383 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Event is a VEVENT of an iCalendar (text/calendar) part, such as a meeting
+// invitation.
+type Event struct {
+	// Method is the METHOD of the calendar: REQUEST for an invitation,
+	// REPLY for an answer to one, CANCEL for a cancellation.
+	Method string `json:"method,omitempty"`
+
+	UID         string        `json:"uid,omitempty"`
+	Sequence    int           `json:"sequence,omitempty"`
+	Status      string        `json:"status,omitempty"` // CONFIRMED, TENTATIVE or CANCELLED
+	Summary     string        `json:"summary"`
+	Description string        `json:"description,omitempty"`
+	Location    string        `json:"location,omitempty"`
+	Start       time.Time     `json:"start"`
+	End         time.Time     `json:"end"`
+	AllDay      bool          `json:"allDay,omitempty"` // Start and End are dates, End exclusive
+	Organizer   *mail.Address `json:"organizer,omitempty"`
+	Attendees   []*Attendee   `json:"attendees,omitempty"`
+	Recurrence  string        `json:"recurrence,omitempty"` // the RRULE, as written
+}
+
+// Attendee is an ATTENDEE of an Event.
+type Attendee struct {
+	Address *mail.Address `json:"address"`
+	Role    string        `json:"role,omitempty"`   // REQ-PARTICIPANT, OPT-PARTICIPANT, ...
+	Status  string        `json:"status,omitempty"` // PARTSTAT: NEEDS-ACTION, ACCEPTED, DECLINED, TENTATIVE
+	RSVP    bool          `json:"rsvp,omitempty"`
+}
+
+// icsProperty is a content line of an iCalendar file or a vCard.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseICS returns the events of an iCalendar file. Times with a TZID that
+// is not an IANA zone name, as Outlook writes them, are taken as UTC, and
+// floating times as local time.
+func ParseICS(data []byte) ([]*Event, error) {
+	var events []*Event
+	var method string
+	var ev *Event
+	seenCalendar := false
+	for _, line := range unfoldICS(data) {
+		p, ok := parseICSLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCALENDAR"):
+			seenCalendar = true
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			ev = &Event{Method: method}
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			if ev != nil {
+				if ev.End.IsZero() {
+					ev.End = ev.Start
+					if ev.AllDay {
+						ev.End = ev.Start.AddDate(0, 0, 1)
+					}
+				}
+				events = append(events, ev)
+			}
+			ev = nil
+		case p.name == "METHOD" && ev == nil:
+			method = strings.ToUpper(p.value)
+		case ev != nil:
+			ev.set(p)
+		}
+	}
+	if !seenCalendar {
+		return nil, errors.New("ParseICS: no VCALENDAR")
+	}
+	return events, nil
+}
+
+// set fills in the field of e for property p. Nested components such as
+// VALARM also end up here; their properties do not overlap with the ones
+// kept.
+func (e *Event) set(p icsProperty) {
+	switch p.name {
+	case "UID":
+		e.UID = p.value
+	case "SEQUENCE":
+		e.Sequence, _ = strconv.Atoi(p.value)
+	case "STATUS":
+		e.Status = strings.ToUpper(p.value)
+	case "SUMMARY":
+		e.Summary = unescapeICS(p.value)
+	case "DESCRIPTION":
+		e.Description = unescapeICS(p.value)
+	case "LOCATION":
+		e.Location = unescapeICS(p.value)
+	case "DTSTART":
+		e.Start, e.AllDay = parseICSTime(p)
+	case "DTEND":
+		e.End, _ = parseICSTime(p)
+	case "DURATION":
+		if d, ok := parseICSDuration(p.value); ok && !e.Start.IsZero() {
+			e.End = e.Start.Add(d)
+		}
+	case "RRULE":
+		e.Recurrence = p.value
+	case "ORGANIZER":
+		e.Organizer = icsAddress(p)
+	case "ATTENDEE":
+		a := &Attendee{
+			Address: icsAddress(p),
+			Role:    strings.ToUpper(p.params["ROLE"]),
+			Status:  strings.ToUpper(p.params["PARTSTAT"]),
+			RSVP:    strings.EqualFold(p.params["RSVP"], "TRUE"),
+		}
+		e.Attendees = append(e.Attendees, a)
+	}
+}
+
+// unfoldICS splits data into content lines, joining the continuation lines
+// that start with a space or tab.
+func unfoldICS(data []byte) []string {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	var lines []string
+	for _, l := range strings.Split(string(data), "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimRight(l, "\r"))
+	}
+	return lines
+}
+
+// parseICSLine parses "NAME;PARAM=value;...:value". Parameter values may be
+// quoted and contain ':' and ';'. A group prefix such as "item1." is dropped
+// from the name, and a parameter without a name, as vCard 2.1 writes types
+// ("TEL;WORK:..."), is added to TYPE.
+func parseICSLine(line string) (icsProperty, bool) {
+	p := icsProperty{params: make(map[string]string)}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, false
+	}
+	p.name = strings.ToUpper(line[:i])
+	if dot := strings.LastIndexByte(p.name, '.'); dot >= 0 {
+		p.name = p.name[dot+1:]
+	}
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if end := strings.IndexAny(rest, ";:"); eq < 0 || (end >= 0 && end < eq) {
+			if end < 0 {
+				return p, false
+			}
+			p.params["TYPE"] = strings.TrimPrefix(p.params["TYPE"]+","+rest[:end], ",")
+			i = len(line) - len(rest) + end
+			continue
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return p, false
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return p, false
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		p.params[name] = value
+		i = len(line) - len(rest)
+		if i >= len(line) {
+			return p, false
+		}
+	}
+	p.value = line[i+1:]
+	return p, true
+}
+
+// unescapeICS undoes the backslash escapes of a TEXT value.
+func unescapeICS(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseICSTime parses a DATE or DATE-TIME value, reporting whether it is a
+// date.
+func parseICSTime(p icsProperty) (time.Time, bool) {
+	v := p.value
+	if p.params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, time.Local)
+		return t, err == nil
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, _ := time.Parse("20060102T150405Z", v)
+		return t, false
+	}
+	loc := time.Local
+	if tzid := p.params["TZID"]; tzid != "" {
+		loc = time.UTC
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+	t, _ := time.ParseInLocation("20060102T150405", v, loc)
+	return t, false
+}
+
+// parseICSDuration parses a DURATION such as "PT1H30M" or "P1D".
+func parseICSDuration(s string) (time.Duration, bool) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	if !strings.HasPrefix(s, "P") {
+		return 0, false
+	}
+	var d time.Duration
+	n := 0
+	for _, c := range s[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+			continue
+		case c == 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case c == 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case c == 'H':
+			d += time.Duration(n) * time.Hour
+		case c == 'M':
+			d += time.Duration(n) * time.Minute
+		case c == 'S':
+			d += time.Duration(n) * time.Second
+		case c == 'T':
+		default:
+			return 0, false
+		}
+		n = 0
+	}
+	if neg {
+		d = -d
+	}
+	return d, true
+}
+
+// icsAddress returns the address of an ORGANIZER or ATTENDEE, a mailto: URI
+// with the name in the CN parameter.
+func icsAddress(p icsProperty) *mail.Address {
+	addr := p.value
+	if len(addr) >= 7 && strings.EqualFold(addr[:7], "mailto:") {
+		addr = addr[7:]
+	}
+	return &mail.Address{Name: p.params["CN"], Address: addr}
+}
+
+// splitICS splits a structured value such as N or ADR at the ';' that are
+// not escaped, and unescapes the fields.
+func splitICS(s string) []string {
+	var fields []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ';':
+			fields = append(fields, unescapeICS(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(fields, unescapeICS(s[start:]))
+}
+
+// findParts returns the parts under part, outside of embedded messages, for
+// which match returns true.
+func findParts(part *gmail.MessagePart, match func(*gmail.MessagePart) bool) []*gmail.MessagePart {
+	var list []*gmail.MessagePart
+	var walk func(*gmail.MessagePart)
+	walk = func(p *gmail.MessagePart) {
+		switch {
+		case match(p):
+			list = append(list, p)
+		case strings.HasPrefix(p.MimeType, "multipart/"):
+			for _, c := range p.Parts {
+				walk(c)
+			}
+		}
+	}
+	walk(part)
+	return list
+}
+
+// calendarParts returns the text/calendar parts under part. When there are
+// none, .ics attachments of other types are returned instead.
+func calendarParts(part *gmail.MessagePart) []*gmail.MessagePart {
+	cal := findParts(part, func(p *gmail.MessagePart) bool {
+		return strings.EqualFold(p.MimeType, "text/calendar")
+	})
+	if len(cal) > 0 {
+		return cal
+	}
+	return findParts(part, func(p *gmail.MessagePart) bool {
+		return strings.EqualFold(p.MimeType, "application/ics") || strings.HasSuffix(strings.ToLower(p.Filename), ".ics")
+	})
+}
+
+// parseEvents returns the events of the calendar parts of gmailMessage.
+// Invitations usually carry the same calendar twice, so events already seen
+// with the same UID and start are dropped. Calendars that do not parse are
+// skipped.
+func parseEvents(ctx context.Context, srv GmailService, gmailMessage *gmail.Message, user string) ([]*Event, error) {
+	var events []*Event
+	seen := make(map[string]bool)
+	for _, part := range calendarParts(gmailMessage.Payload) {
+		data, err := GetMessagePartData(ctx, srv, user, gmailMessage.Id, part)
+		if err != nil {
+			return nil, fmt.Errorf("parseEvents: %w", err)
+		}
+		list, err := ParseICS([]byte(data))
+		if err != nil {
+			continue
+		}
+		for _, e := range list {
+			key := e.UID + "\x00" + e.Start.String()
+			if e.UID != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
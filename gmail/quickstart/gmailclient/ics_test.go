@@ -0,0 +1,91 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+const invite = `BEGIN:VCALENDAR
+PRODID:-//Google Inc//Google Calendar 70.9054//EN
+VERSION:2.0
+METHOD:REQUEST
+BEGIN:VEVENT
+DTSTART;TZID=Europe/Berlin:20210510T140000
+DTEND;TZID=Europe/Berlin:20210510T150000
+ORGANIZER;CN=Ana Lima:mailto:ana@example.com
+UID:7kukuqrfedlm2f9t0vr42q2qc8@google.com
+ATTENDEE;CUTYPE=INDIVIDUAL;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=
+ TRUE;CN="Lee, Sam";X-NUM-GUESTS=0:mailto:sam@example.com
+SEQUENCE:1
+STATUS:CONFIRMED
+SUMMARY:Contract review\, round 2
+DESCRIPTION:Agenda:\n1. Pricing\n2. Term
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-P0DT0H10M0S
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICS(t *testing.T) {
+	events, err := gmailclient.ParseICS([]byte(strings.ReplaceAll(invite, "\n", "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	e := events[0]
+	if e.Method != "REQUEST" || e.Summary != "Contract review, round 2" || e.Sequence != 1 || e.Status != "CONFIRMED" {
+		t.Errorf("got event %+v", e)
+	}
+	if e.Description != "Agenda:\n1. Pricing\n2. Term" {
+		t.Errorf("Description = %q", e.Description)
+	}
+	start := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	if !e.Start.Equal(start) || !e.End.Equal(start.Add(time.Hour)) {
+		t.Errorf("Start, End = %v, %v; want %v and an hour later", e.Start, e.End, start)
+	}
+	if e.Organizer == nil || e.Organizer.Name != "Ana Lima" || e.Organizer.Address != "ana@example.com" {
+		t.Errorf("Organizer = %v", e.Organizer)
+	}
+	if len(e.Attendees) != 1 {
+		t.Fatalf("got %d attendees, want 1", len(e.Attendees))
+	}
+	a := e.Attendees[0]
+	if a.Address.Name != "Lee, Sam" || a.Address.Address != "sam@example.com" || a.Status != "NEEDS-ACTION" || !a.RSVP {
+		t.Errorf("got attendee %+v", a)
+	}
+}
+
+func TestParseICSAllDay(t *testing.T) {
+	events, err := gmailclient.ParseICS([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20210524\nSUMMARY:Holiday\nEND:VEVENT\nEND:VCALENDAR\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := events[0]
+	if !e.AllDay || e.End.Sub(e.Start) != 24*time.Hour {
+		t.Errorf("got %+v, want a one-day event", e)
+	}
+}
@@ -0,0 +1,228 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package index keeps a full-text index of messages in a local SQLite
+// database, so that cached and exported mail can be searched offline,
+// including body text Gmail's search operators cannot reach in raw exports:
+//
+//	ix, err := index.Open("search.db")
+//	...
+//	err = ix.Add(ctx, index.NewDocument("me@example.com", "", m))
+//	results, err := ix.Search(ctx, "invoice 2023", 20)
+//
+// Queries use the SQLite FTS4 syntax: words are ANDed, "quoted phrases"
+// match exactly, prefix* matches word starts, OR and NOT combine terms, and
+// a word can be restricted to a field with from:, to:, subject:, body: or
+// attachments:.
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS docs (
+	docid   INTEGER PRIMARY KEY,
+	key     TEXT NOT NULL UNIQUE,
+	account TEXT NOT NULL,
+	id      TEXT NOT NULL,
+	source  TEXT NOT NULL,
+	date    INTEGER NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS text USING fts4(
+	"from", "to", subject, body, attachments,
+	tokenize=unicode61
+);
+`
+
+// A Document is the searchable text of one message.
+type Document struct {
+	Account string // the mailbox the message came from, if known
+	ID      string // the Gmail message ID, if known
+	Source  string // where the message was read from, if not from Gmail
+	Date    time.Time
+
+	From, To    string
+	Subject     string
+	Body        string
+	Attachments string // file names and any text extracted from them
+}
+
+// key identifies the message of d in the index: by Gmail ID where there is
+// one, else by where it was read from.
+func (d *Document) key() string {
+	if d.ID != "" {
+		return d.Account + "\x00" + d.ID
+	}
+	return "\x00" + d.Source
+}
+
+// NewDocument returns the document of m, which needs its bodies, from
+// account or read from source. HTML-only messages are indexed as text.
+func NewDocument(account, source string, m *gmailclient.Message) *Document {
+	d := &Document{
+		Account: account,
+		ID:      m.Id,
+		Source:  source,
+		Date:    m.Date,
+		From:    gmailclient.FormatAddressList(m.From),
+		To:      gmailclient.FormatAddressList(append(append([]*mail.Address(nil), m.To...), m.Cc...)),
+		Subject: m.Subject,
+		Body:    m.BodyPlain,
+	}
+	if d.Body == "" && m.BodyHtml != "" {
+		if text, err := gmailclient.HTMLToText(m.BodyHtml); err == nil {
+			d.Body = text
+		}
+	}
+	var names []string
+	for _, a := range m.Attachments {
+		names = append(names, a.Filename)
+	}
+	d.Attachments = strings.Join(names, "\n")
+	return d
+}
+
+// Index is a full-text index of messages in a SQLite database. It is safe
+// for concurrent use.
+type Index struct {
+	db *sql.DB
+}
+
+// Open opens the index database at path, creating it if necessary.
+func Open(path string) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("index: create directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("index: open %s: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("index: create schema in %s: %w", path, err)
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the database.
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// Add indexes d, replacing what was indexed for the same message before.
+func (ix *Index) Add(ctx context.Context, d *Document) error {
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var docid int64
+	err = tx.QueryRowContext(ctx, `SELECT docid FROM docs WHERE key = ?`, d.key()).Scan(&docid)
+	switch {
+	case err == sql.ErrNoRows:
+		r, err := tx.ExecContext(ctx, `INSERT INTO docs (key, account, id, source, date) VALUES (?, ?, ?, ?, ?)`,
+			d.key(), d.Account, d.ID, d.Source, d.Date.Unix())
+		if err != nil {
+			return fmt.Errorf("index: add %s: %w", d.key(), err)
+		}
+		if docid, err = r.LastInsertId(); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("index: add %s: %w", d.key(), err)
+	default:
+		_, err := tx.ExecContext(ctx, `UPDATE docs SET account = ?, id = ?, source = ?, date = ? WHERE docid = ?`,
+			d.Account, d.ID, d.Source, d.Date.Unix(), docid)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `DELETE FROM text WHERE docid = ?`, docid)
+		}
+		if err != nil {
+			return fmt.Errorf("index: add %s: %w", d.key(), err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO text (docid, "from", "to", subject, body, attachments) VALUES (?, ?, ?, ?, ?, ?)`,
+		docid, d.From, d.To, d.Subject, d.Body, d.Attachments)
+	if err != nil {
+		return fmt.Errorf("index: add %s: %w", d.key(), err)
+	}
+	return tx.Commit()
+}
+
+// A Result is a message matching a search.
+type Result struct {
+	Account string    `json:"account,omitempty"`
+	ID      string    `json:"id,omitempty"`
+	Source  string    `json:"source,omitempty"`
+	Date    time.Time `json:"date"`
+	From    string    `json:"from"`
+	Subject string    `json:"subject"`
+
+	// Snippet is the best matching passage, with the matches in [brackets].
+	Snippet string `json:"snippet"`
+}
+
+// Search returns up to limit messages matching query, newest first; a limit
+// of 0 returns all of them.
+func (ix *Index) Search(ctx context.Context, query string, limit int) ([]*Result, error) {
+	q := `SELECT d.account, d.id, d.source, d.date, t."from", t.subject, snippet(text, '[', ']', '…', -1, 16)
+		FROM text t JOIN docs d ON d.docid = t.docid
+		WHERE text MATCH ? ORDER BY d.date DESC`
+	args := []interface{}{query}
+	if limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := ix.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("index: search %q: %w", query, err)
+	}
+	defer rows.Close()
+	var results []*Result
+	for rows.Next() {
+		r := &Result{}
+		var date int64
+		if err := rows.Scan(&r.Account, &r.ID, &r.Source, &date, &r.From, &r.Subject, &r.Snippet); err != nil {
+			return nil, fmt.Errorf("index: search %q: %w", query, err)
+		}
+		r.Date = time.Unix(date, 0)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("index: search %q: %w", query, err)
+	}
+	return results, nil
+}
+
+// Count returns how many messages are indexed.
+func (ix *Index) Count(ctx context.Context) (int64, error) {
+	var n int64
+	if err := ix.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM docs`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("index: count: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,101 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/index"
+)
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	ix, err := index.Open(filepath.Join(t.TempDir(), "search.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+
+	docs := []*index.Document{
+		{Account: "me", ID: "1", Date: time.Unix(100, 0), From: "Ann <ann@example.com>", Subject: "Invoice", Body: "Your invoice for 2023 is attached.", Attachments: "invoice-2023.pdf"},
+		{Account: "me", ID: "2", Date: time.Unix(200, 0), From: "Bob <bob@example.com>", Subject: "Lunch", Body: "Are you free on Friday?"},
+		{Source: "old/messages.mbox:3", Date: time.Unix(300, 0), From: "Ann <ann@example.com>", Subject: "Re: Invoice", Body: "The 2023 invoice was paid."},
+	}
+	for _, d := range docs {
+		if err := ix.Add(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Indexing a message again replaces it.
+	docs[1].Body = "Are you free on Saturday?"
+	if err := ix.Add(ctx, docs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ix.Count(ctx); err != nil || n != 3 {
+		t.Errorf("Count = %d, %v; want 3", n, err)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"invoice 2023", []string{"old/messages.mbox:3", "1"}},
+		{`"invoice was paid"`, []string{"old/messages.mbox:3"}},
+		{"friday", nil},
+		{"saturday", []string{"2"}},
+		{"from:bob", []string{"2"}},
+		{"subject:invoice NOT subject:re", []string{"1"}},
+		{"attachments:pdf", []string{"1"}},
+		{"paid OR lunch", []string{"old/messages.mbox:3", "2"}},
+	} {
+		results, err := ix.Search(ctx, tt.query, 0)
+		if err != nil {
+			t.Errorf("Search(%q): %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, r := range results {
+			if r.ID != "" {
+				got = append(got, r.ID)
+			} else {
+				got = append(got, r.Source)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+
+	results, err := ix.Search(ctx, "paid", 1)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search(paid) = %v, %v", results, err)
+	}
+	if want := "The 2023 invoice was [paid]."; results[0].Snippet != want {
+		t.Errorf("Snippet = %q, want %q", results[0].Snippet, want)
+	}
+}
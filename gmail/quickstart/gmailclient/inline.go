@@ -0,0 +1,98 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// An InlinePart is a part referenced from an HTML body by its Content-ID,
+// usually an image in a multipart/related message.
+type InlinePart struct {
+	ContentID string
+	MimeType  string
+	Filename  string
+	Data      []byte
+}
+
+// DataURI returns the part as a data: URI.
+func (p *InlinePart) DataURI() string {
+	return "data:" + p.MimeType + ";base64," + base64.StdEncoding.EncodeToString(p.Data)
+}
+
+// GetInlineParts downloads every part of gmailMessage that has a Content-ID,
+// keyed by that ID without its angle brackets.
+func GetInlineParts(ctx context.Context, srv GmailService, gmailMessage *gmail.Message, user string) (map[string]*InlinePart, error) {
+	if gmailMessage.Payload == nil {
+		return nil, ErrNoPayload
+	}
+	parts := make(map[string]*InlinePart)
+	var walk func(*gmail.MessagePart) error
+	walk = func(part *gmail.MessagePart) error {
+		for _, p := range part.Parts {
+			if err := walk(p); err != nil {
+				return err
+			}
+		}
+		cid := strings.Trim(FindHeader(part, "Content-ID"), "<> ")
+		if cid == "" || len(part.Parts) > 0 || part.Body == nil {
+			return nil
+		}
+		data, err := GetMessagePartData(ctx, srv, user, gmailMessage.Id, part)
+		if err != nil {
+			return fmt.Errorf("GetInlineParts %s: %w", cid, err)
+		}
+		parts[cid] = &InlinePart{
+			ContentID: cid,
+			MimeType:  part.MimeType,
+			Filename:  part.Filename,
+			Data:      []byte(data),
+		}
+		return nil
+	}
+	if err := walk(gmailMessage.Payload); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// cidURL matches a cid: URL in an HTML attribute or CSS url().
+var cidURL = regexp.MustCompile(`(?i)cid:([^"'\s)>]+)`)
+
+// ResolveCIDs rewrites the cid: URLs in html that refer to one of parts to
+// the URL returned by link, such as the part's DataURI or the path it was
+// saved to. References to unknown parts are left alone.
+func ResolveCIDs(html string, parts map[string]*InlinePart, link func(*InlinePart) string) string {
+	return cidURL.ReplaceAllStringFunc(html, func(m string) string {
+		cid := m[len("cid:"):]
+		if u, err := url.PathUnescape(cid); err == nil {
+			cid = u
+		}
+		if p, ok := parts[cid]; ok {
+			return link(p)
+		}
+		return m
+	})
+}
@@ -0,0 +1,64 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestResolveCIDs(t *testing.T) {
+	srv := gmailclienttest.New()
+	srv.AddAttachment("att-logo", &gmail.MessagePartBody{
+		Data: base64.URLEncoding.EncodeToString([]byte("PNG")),
+	})
+	msg := &gmail.Message{
+		Id: "1",
+		Payload: &gmail.MessagePart{
+			MimeType: "multipart/related",
+			Parts: []*gmail.MessagePart{
+				{MimeType: "text/html", Body: &gmail.MessagePartBody{}},
+				{
+					MimeType: "image/png",
+					Filename: "logo.png",
+					Headers:  []*gmail.MessagePartHeader{{Name: "Content-ID", Value: "<logo@example>"}},
+					Body:     &gmail.MessagePartBody{AttachmentId: "att-logo"},
+				},
+			},
+		},
+	}
+	parts, err := gmailclient.GetInlineParts(context.Background(), srv, msg, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := parts["logo@example"]; p == nil || string(p.Data) != "PNG" {
+		t.Fatalf("parts = %v", parts)
+	}
+
+	html := `<img src="cid:logo@example"><img src="CID:logo%40example"><img src="cid:missing">`
+	got := gmailclient.ResolveCIDs(html, parts, (*gmailclient.InlinePart).DataURI)
+	want := `<img src="data:image/png;base64,UE5H"><img src="data:image/png;base64,UE5H"><img src="cid:missing">`
+	if got != want {
+		t.Errorf("ResolveCIDs = %s\nwant %s", got, want)
+	}
+}
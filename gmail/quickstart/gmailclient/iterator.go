@@ -0,0 +1,159 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/iterator"
+)
+
+// A MessageIterator fetches the messages matching a query one page at a time,
+// so that arbitrarily large mailboxes can be processed in constant memory.
+// It is not safe for concurrent use.
+//
+//	it := gmailclient.Messages(ctx, client, "me", gmailclient.FetchOptions{Query: q})
+//	defer it.Close()
+//	for {
+//		m, err := it.Next()
+//		if err == iterator.Done {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+type MessageIterator struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	srv    GmailService
+	user   string
+	opts   FetchOptions
+
+	ids       []string         // listed but not yet fetched
+	buf       []*gmail.Message // fetched but not yet returned
+	pageToken string           // token of the page ids came from
+	nextToken string
+	lastPage  bool
+	listed    int64
+	err       error
+}
+
+// Messages returns an iterator over the messages matching opts, fetched in the
+// given format with up to opts.Concurrency requests in flight. Listing starts
+// at opts.PageToken if set.
+func Messages(ctx context.Context, srv GmailService, user string, opts FetchOptions) *MessageIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	return &MessageIterator{ctx: ctx, cancel: cancel, srv: srv, user: user, opts: opts, nextToken: opts.PageToken}
+}
+
+// Next returns the next message. It returns iterator.Done when there are no
+// more messages, and the same error on every call after a failure.
+func (it *MessageIterator) Next() (*gmail.Message, error) {
+	for it.err == nil && len(it.buf) == 0 {
+		if len(it.ids) == 0 {
+			if it.lastPage {
+				it.err = iterator.Done
+				break
+			}
+			it.err = it.nextPage()
+			continue
+		}
+		it.err = it.fetchBatch()
+	}
+	if it.err != nil {
+		return nil, it.err
+	}
+	m := it.buf[0]
+	it.buf[0] = nil
+	it.buf = it.buf[1:]
+	return m, nil
+}
+
+// PageToken returns the token of the page the message last returned by Next
+// was listed on. A new iterator started with this token resumes from there,
+// though messages of that page that were already returned are returned again;
+// a Checkpoint keeps track of those.
+func (it *MessageIterator) PageToken() string {
+	return it.pageToken
+}
+
+// Close stops the iterator and cancels any request in flight. Next returns
+// iterator.Done afterwards.
+func (it *MessageIterator) Close() {
+	it.cancel()
+	if it.err == nil {
+		it.err = iterator.Done
+	}
+}
+
+// nextPage lists the next page of message IDs.
+func (it *MessageIterator) nextPage() error {
+	size := int64(maxPageSize)
+	if max := it.opts.MaxResults; max > 0 && max-it.listed < size {
+		size = max - it.listed
+	}
+	r, err := it.srv.ListMessages(it.ctx, it.user, it.opts.Query, it.nextToken, size)
+	if err != nil {
+		return err
+	}
+	it.pageToken, it.nextToken = it.nextToken, r.NextPageToken
+	it.lastPage = r.NextPageToken == ""
+	for _, m := range r.Messages {
+		it.ids = append(it.ids, m.Id)
+	}
+	it.listed += int64(len(r.Messages))
+	if max := it.opts.MaxResults; max > 0 && it.listed >= max {
+		it.ids = it.ids[:int64(len(it.ids))-(it.listed-max)]
+		it.lastPage = true
+	}
+	return nil
+}
+
+// fetchBatch fetches the next opts.Concurrency listed messages in parallel.
+func (it *MessageIterator) fetchBatch() error {
+	n := it.opts.Concurrency
+	if n > len(it.ids) {
+		n = len(it.ids)
+	}
+	batch := make([]*gmail.Message, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i, id := range it.ids[:n] {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			batch[i], errs[i] = fetchMessage(it.ctx, it.srv, it.user, id, it.opts)
+		}(i, id)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	it.ids = it.ids[n:]
+	it.buf = batch
+	return nil
+}
@@ -0,0 +1,114 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/iterator"
+)
+
+func TestMessageIterator(t *testing.T) {
+	srv := gmailclienttest.New()
+	srv.PageSize = 4
+	for i := 0; i < 10; i++ {
+		srv.AddMessage(&gmail.Message{Id: fmt.Sprint(i)})
+	}
+
+	it := gmailclient.Messages(context.Background(), srv, "me", gmailclient.FetchOptions{MaxResults: 9, Concurrency: 3})
+	defer it.Close()
+	var ids []string
+	for {
+		m, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) == 5 && it.PageToken() != "4" {
+			t.Errorf("PageToken on the second page = %q, want 4", it.PageToken())
+		}
+		ids = append(ids, m.Id)
+	}
+	if fmt.Sprint(ids) != "[0 1 2 3 4 5 6 7 8]" {
+		t.Errorf("iterated %v", ids)
+	}
+
+	it = gmailclient.Messages(context.Background(), srv, "me", gmailclient.FetchOptions{PageToken: "8"})
+	if m, err := it.Next(); err != nil || m.Id != "8" {
+		t.Errorf("resumed at %v, %v; want message 8", m, err)
+	}
+	it.Close()
+	if _, err := it.Next(); err != iterator.Done {
+		t.Errorf("Next after Close: err = %v, want iterator.Done", err)
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	srv := gmailclienttest.New()
+	srv.PageSize = 3
+	for i := 0; i < 7; i++ {
+		srv.AddMessage(&gmail.Message{Id: fmt.Sprint(i)})
+	}
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	// Process five messages, then "crash".
+	cp := &gmailclient.Checkpoint{Query: "q"}
+	it := gmailclient.Messages(context.Background(), srv, "me", gmailclient.FetchOptions{Query: "q"})
+	for i := 0; i < 5; i++ {
+		m, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cp.MarkDone(it.PageToken(), m.Id)
+		if err := cp.Save(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	it.Close()
+
+	cp, err := gmailclient.LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it = gmailclient.Messages(context.Background(), srv, "me", gmailclient.FetchOptions{Query: cp.Query, PageToken: cp.PageToken})
+	defer it.Close()
+	var rest []string
+	for {
+		m, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cp.IsDone(m.Id) {
+			rest = append(rest, m.Id)
+		}
+	}
+	if fmt.Sprint(rest) != "[5 6]" {
+		t.Errorf("resumed run processed %v, want [5 6]", rest)
+	}
+}
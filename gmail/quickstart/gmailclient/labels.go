@@ -0,0 +1,240 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// The label functions need the gmail.labels scope, or gmail.modify, except
+// ListLabels and GetLabel, which gmail.readonly allows.
+//
+// Gmail nests labels by name: "Projects/Apollo" shows under "Projects". The
+// API does not know about the nesting, so it neither creates the parents of
+// a new label nor renames or deletes the labels under one; CreateLabel,
+// RenameLabel and DeleteLabel do that here.
+
+// Label visibility values, for Label.LabelListVisibility and
+// Label.MessageListVisibility.
+const (
+	LabelShow         = "labelShow"
+	LabelShowIfUnread = "labelShowIfUnread"
+	LabelHide         = "labelHide"
+	MessageListShow   = "show"
+	MessageListHide   = "hide"
+)
+
+const (
+	labelTypeSystem    = "system"
+	labelNameSeparator = "/"
+)
+
+// ListLabels returns the labels of user's mailbox, system labels such as
+// INBOX included, sorted by name. Message counts are not filled in; see
+// GetLabel.
+func ListLabels(ctx context.Context, srv LabelService, user string) ([]*gmail.Label, error) {
+	labels, err := srv.ListLabels(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("ListLabels: %w", err)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels, nil
+}
+
+// GetLabel fetches label id with its message and thread counts.
+func GetLabel(ctx context.Context, srv LabelService, user, id string) (*gmail.Label, error) {
+	l, err := srv.GetLabel(ctx, user, id)
+	if err != nil {
+		return nil, fmt.Errorf("GetLabel: %w", err)
+	}
+	return l, nil
+}
+
+// FindLabel returns the label among labels with the given ID or name.
+// Names are matched case-insensitively, as Gmail does. It returns an error
+// wrapping ErrLabelNotFound if there is none.
+func FindLabel(labels []*gmail.Label, nameOrID string) (*gmail.Label, error) {
+	for _, l := range labels {
+		if l.Id == nameOrID {
+			return l, nil
+		}
+	}
+	for _, l := range labels {
+		if strings.EqualFold(l.Name, nameOrID) {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrLabelNotFound, nameOrID)
+}
+
+// SubLabels returns the labels nested under the label called name, at any
+// depth, sorted by name.
+func SubLabels(labels []*gmail.Label, name string) []*gmail.Label {
+	prefix := strings.ToLower(name + labelNameSeparator)
+	var subs []*gmail.Label
+	for _, l := range labels {
+		if strings.HasPrefix(strings.ToLower(l.Name), prefix) {
+			subs = append(subs, l)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Name < subs[j].Name })
+	return subs
+}
+
+var labelColorRE = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// checkLabel validates the settings of l that Gmail would otherwise reject
+// with a less helpful error. Gmail only accepts colors from its palette,
+// which is not checked here.
+func checkLabel(l *gmail.Label) error {
+	if l.Name != "" {
+		for _, part := range strings.Split(l.Name, labelNameSeparator) {
+			if strings.TrimSpace(part) == "" {
+				return fmt.Errorf("invalid label name %q: empty level", l.Name)
+			}
+		}
+	}
+	switch l.LabelListVisibility {
+	case "", LabelShow, LabelShowIfUnread, LabelHide:
+	default:
+		return fmt.Errorf("invalid label list visibility %q", l.LabelListVisibility)
+	}
+	switch l.MessageListVisibility {
+	case "", MessageListShow, MessageListHide:
+	default:
+		return fmt.Errorf("invalid message list visibility %q", l.MessageListVisibility)
+	}
+	if c := l.Color; c != nil {
+		if !labelColorRE.MatchString(c.BackgroundColor) || !labelColorRE.MatchString(c.TextColor) {
+			return fmt.Errorf("invalid label color %q on %q: want both as #rrggbb", c.TextColor, c.BackgroundColor)
+		}
+		c.BackgroundColor, c.TextColor = strings.ToLower(c.BackgroundColor), strings.ToLower(c.TextColor)
+	}
+	return nil
+}
+
+// CreateLabel creates the label l, of which Name must be set. With parents,
+// the missing labels above a nested name are created first, like
+// mkdir -p, with default settings.
+func CreateLabel(ctx context.Context, srv LabelService, user string, l *gmail.Label, parents bool) (*gmail.Label, error) {
+	if err := checkLabel(l); err != nil {
+		return nil, fmt.Errorf("CreateLabel: %w", err)
+	}
+	if parents {
+		labels, err := ListLabels(ctx, srv, user)
+		if err != nil {
+			return nil, err
+		}
+		levels := strings.Split(l.Name, labelNameSeparator)
+		for i := 1; i < len(levels); i++ {
+			name := strings.Join(levels[:i], labelNameSeparator)
+			if _, err := FindLabel(labels, name); err == nil {
+				continue
+			}
+			if _, err := srv.CreateLabel(ctx, user, &gmail.Label{Name: name}); err != nil {
+				return nil, fmt.Errorf("CreateLabel %s: %w", name, err)
+			}
+		}
+	}
+	created, err := srv.CreateLabel(ctx, user, l)
+	if err != nil {
+		return nil, fmt.Errorf("CreateLabel %s: %w", l.Name, err)
+	}
+	return created, nil
+}
+
+// UpdateLabel changes the settings of label id that are set in change; a
+// zero field is left alone.
+func UpdateLabel(ctx context.Context, srv LabelService, user, id string, change *gmail.Label) (*gmail.Label, error) {
+	if err := checkLabel(change); err != nil {
+		return nil, fmt.Errorf("UpdateLabel: %w", err)
+	}
+	l, err := srv.PatchLabel(ctx, user, id, change)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateLabel: %w", err)
+	}
+	return l, nil
+}
+
+// RenameLabel renames the label with the given name or ID to name, and the
+// labels nested under it along with it, so that "Old/Sub" becomes
+// "New/Sub". System labels cannot be renamed.
+func RenameLabel(ctx context.Context, srv LabelService, user, nameOrID, name string) error {
+	if err := checkLabel(&gmail.Label{Name: name}); err != nil {
+		return fmt.Errorf("RenameLabel: %w", err)
+	}
+	labels, err := ListLabels(ctx, srv, user)
+	if err != nil {
+		return err
+	}
+	l, err := FindLabel(labels, nameOrID)
+	if err != nil {
+		return fmt.Errorf("RenameLabel: %w", err)
+	}
+	if l.Type == labelTypeSystem {
+		return fmt.Errorf("RenameLabel: %s is a system label", l.Name)
+	}
+	subs := SubLabels(labels, l.Name)
+	if _, err := srv.PatchLabel(ctx, user, l.Id, &gmail.Label{Name: name}); err != nil {
+		return fmt.Errorf("RenameLabel %s: %w", l.Name, err)
+	}
+	for _, s := range subs {
+		newName := name + s.Name[len(l.Name):]
+		if _, err := srv.PatchLabel(ctx, user, s.Id, &gmail.Label{Name: newName}); err != nil {
+			return fmt.Errorf("RenameLabel %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// DeleteLabel deletes the label with the given name or ID. Messages keep their other labels; none are
+// deleted. With recursive, the labels nested under it are deleted too,
+// deepest first; otherwise they are left in place as top-level labels.
+// System labels cannot be deleted.
+func DeleteLabel(ctx context.Context, srv LabelService, user, nameOrID string, recursive bool) error {
+	labels, err := ListLabels(ctx, srv, user)
+	if err != nil {
+		return err
+	}
+	l, err := FindLabel(labels, nameOrID)
+	if err != nil {
+		return fmt.Errorf("DeleteLabel: %w", err)
+	}
+	if l.Type == labelTypeSystem {
+		return fmt.Errorf("DeleteLabel: %s is a system label", l.Name)
+	}
+	var victims []*gmail.Label
+	if recursive {
+		victims = SubLabels(labels, l.Name)
+		sort.SliceStable(victims, func(i, j int) bool {
+			return strings.Count(victims[i].Name, labelNameSeparator) > strings.Count(victims[j].Name, labelNameSeparator)
+		})
+	}
+	for _, v := range append(victims, l) {
+		if err := srv.DeleteLabel(ctx, user, v.Id); err != nil {
+			return fmt.Errorf("DeleteLabel %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestFindLabel(t *testing.T) {
+	labels := []*gmail.Label{
+		{Id: "INBOX", Name: "INBOX", Type: "system"},
+		{Id: "Label_1", Name: "Projects"},
+		{Id: "Label_2", Name: "Projects/Apollo"},
+		{Id: "Label_3", Name: "Projects/Apollo/Specs"},
+		{Id: "Label_4", Name: "Projects-old"},
+	}
+	if l, err := gmailclient.FindLabel(labels, "projects/apollo"); err != nil || l.Id != "Label_2" {
+		t.Errorf("FindLabel by name = %v, %v", l, err)
+	}
+	if l, err := gmailclient.FindLabel(labels, "Label_4"); err != nil || l.Name != "Projects-old" {
+		t.Errorf("FindLabel by ID = %v, %v", l, err)
+	}
+	if _, err := gmailclient.FindLabel(labels, "Receipts"); !errors.Is(err, gmailclient.ErrLabelNotFound) {
+		t.Errorf("FindLabel(missing) = %v, want ErrLabelNotFound", err)
+	}
+
+	subs := gmailclient.SubLabels(labels, "Projects")
+	if len(subs) != 2 || subs[0].Id != "Label_2" || subs[1].Id != "Label_3" {
+		t.Errorf("SubLabels = %v", subs)
+	}
+}
+
+func labelNames(t *testing.T, srv gmailclient.LabelService) string {
+	labels, err := gmailclient.ListLabels(context.Background(), srv, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+	return fmt.Sprint(names)
+}
+
+func TestNestedLabels(t *testing.T) {
+	ctx := context.Background()
+	srv := gmailclienttest.New()
+	srv.Labels = []*gmail.Label{{Id: "INBOX", Name: "INBOX", Type: "system"}}
+
+	if _, err := gmailclient.CreateLabel(ctx, srv, "me", &gmail.Label{Name: "Projects/Apollo/Docs"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gmailclient.CreateLabel(ctx, srv, "me", &gmail.Label{Name: "projects/Gemini"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := labelNames(t, srv), "[INBOX Projects Projects/Apollo Projects/Apollo/Docs projects/Gemini]"; got != want {
+		t.Errorf("after CreateLabel: %s, want %s", got, want)
+	}
+
+	if err := gmailclient.RenameLabel(ctx, srv, "me", "projects", "Work"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := labelNames(t, srv), "[INBOX Work Work/Apollo Work/Apollo/Docs Work/Gemini]"; got != want {
+		t.Errorf("after RenameLabel: %s, want %s", got, want)
+	}
+
+	if err := gmailclient.DeleteLabel(ctx, srv, "me", "Work/Apollo", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := labelNames(t, srv), "[INBOX Work Work/Gemini]"; got != want {
+		t.Errorf("after DeleteLabel: %s, want %s", got, want)
+	}
+	if err := gmailclient.DeleteLabel(ctx, srv, "me", "INBOX", false); err == nil {
+		t.Error("DeleteLabel(INBOX) succeeded")
+	}
+}
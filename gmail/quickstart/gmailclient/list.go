@@ -0,0 +1,115 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// maxPageSize is the largest page Users.Messages.List returns.
+const maxPageSize = 500
+
+// ListAllMessages calls fn for every message matching query, following
+// NextPageToken across pages. At most max messages are visited; zero means
+// no limit. The messages only carry their Id and ThreadId. An error from fn
+// stops the listing and is returned.
+func ListAllMessages(ctx context.Context, srv GmailService, user, query string, max int64, fn func(m *gmail.Message) error) error {
+	var seen int64
+	pageToken := ""
+	for {
+		size := int64(maxPageSize)
+		if max > 0 && max-seen < size {
+			size = max - seen
+		}
+		r, err := srv.ListMessages(ctx, user, query, pageToken, size)
+		if err != nil {
+			return fmt.Errorf("ListAllMessages: %w", err)
+		}
+		for _, m := range r.Messages {
+			if err := fn(m); err != nil {
+				return err
+			}
+			seen++
+			if max > 0 && seen >= max {
+				return nil
+			}
+		}
+		if r.NextPageToken == "" {
+			return nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// A MessageCount is how many messages a query matches.
+type MessageCount struct {
+	// Estimate is Gmail's own estimate, which can be far off for large
+	// or complex queries.
+	Estimate int64 `json:"estimate"`
+
+	// Count is the number of messages counted by paging through the
+	// matches, and Pages the number of pages that took. Both are zero
+	// unless counting was asked for.
+	Count int64 `json:"count,omitempty"`
+	Pages int   `json:"pages,omitempty"`
+
+	// Capped is set when counting stopped at the limit, so there may be
+	// more matches than Count.
+	Capped bool `json:"capped,omitempty"`
+}
+
+// CountMessages returns how many messages match query. Without count it
+// asks Gmail for its estimate alone, which takes one request. With count it
+// also lists the IDs of the matches, 500 to a page, and counts them, up to
+// max if max is above zero.
+func CountMessages(ctx context.Context, srv GmailService, user, query string, count bool, max int64) (*MessageCount, error) {
+	if !count {
+		r, err := srv.ListMessages(ctx, user, query, "", 1)
+		if err != nil {
+			return nil, fmt.Errorf("CountMessages: %w", err)
+		}
+		return &MessageCount{Estimate: r.ResultSizeEstimate}, nil
+	}
+	c := &MessageCount{}
+	pageToken := ""
+	for {
+		r, err := srv.ListMessages(ctx, user, query, pageToken, maxPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("CountMessages: %w", err)
+		}
+		if c.Pages == 0 {
+			c.Estimate = r.ResultSizeEstimate
+		}
+		c.Pages++
+		c.Count += int64(len(r.Messages))
+		if max > 0 && c.Count >= max {
+			c.Capped = c.Count > max || r.NextPageToken != ""
+			if c.Count > max {
+				c.Count = max
+			}
+			return c, nil
+		}
+		if r.NextPageToken == "" {
+			return c, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
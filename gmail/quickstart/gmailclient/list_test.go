@@ -0,0 +1,79 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestListAllMessages(t *testing.T) {
+	srv := gmailclienttest.New()
+	srv.PageSize = 2
+	for i := 0; i < 5; i++ {
+		srv.AddMessage(&gmail.Message{Id: fmt.Sprint(i)})
+	}
+	for _, tt := range []struct {
+		max  int64
+		want int
+	}{{0, 5}, {3, 3}, {10, 5}} {
+		var ids []string
+		err := gmailclient.ListAllMessages(context.Background(), srv, "me", "", tt.max, func(m *gmail.Message) error {
+			ids = append(ids, m.Id)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != tt.want {
+			t.Errorf("max %d: listed %v, want %d messages", tt.max, ids, tt.want)
+		}
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	ctx := context.Background()
+	srv := gmailclienttest.New()
+	srv.PageSize = 2
+	for i := 0; i < 5; i++ {
+		srv.AddMessage(&gmail.Message{Id: fmt.Sprint(i)})
+	}
+	for _, tt := range []struct {
+		count bool
+		max   int64
+		want  gmailclient.MessageCount
+	}{
+		{false, 0, gmailclient.MessageCount{Estimate: 5}},
+		{true, 0, gmailclient.MessageCount{Estimate: 5, Count: 5, Pages: 3}},
+		{true, 3, gmailclient.MessageCount{Estimate: 5, Count: 3, Pages: 2, Capped: true}},
+		{true, 5, gmailclient.MessageCount{Estimate: 5, Count: 5, Pages: 3}},
+	} {
+		got, err := gmailclient.CountMessages(ctx, srv, "me", "", tt.count, tt.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != tt.want {
+			t.Errorf("count %v, max %d: got %+v, want %+v", tt.count, tt.max, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/browser"
+	"golang.org/x/oauth2"
+)
+
+// An AuthFlow obtains a new token from the user for config.
+type AuthFlow func(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error)
+
+// GetTokenFromLoopback runs the installed-app flow with a loopback redirect:
+// it listens on a random localhost port, opens the consent page in the
+// default browser and exchanges the code Google redirects back with. If no
+// browser can be started the URL is printed instead. The request carries a
+// random state, which the redirect must echo, and a PKCE code challenge.
+// Requests without the state are turned away, and the flow waits for the
+// redirect until ctx is done.
+func GetTokenFromLoopback(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenFromLoopback listen: %w", err)
+	}
+	defer l.Close()
+
+	cfg := *config
+	cfg.RedirectURL = fmt.Sprintf("http://%s/", l.Addr())
+	state, err := newState()
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenFromLoopback: %w", err)
+	}
+	verifier, err := newCodeVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenFromLoopback: %w", err)
+	}
+
+	results := make(chan loopbackResult, 1)
+	srv := &http.Server{Handler: loopbackHandler(state, results)}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	opts := append([]oauth2.AuthCodeOption{oauth2.AccessTypeOffline}, verifier.authParams()...)
+	authURL := cfg.AuthCodeURL(state, opts...)
+	if err := browser.Open(authURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Go to the following link in your browser: \n%v\n", authURL)
+	} else {
+		fmt.Fprintf(os.Stderr, "Your browser has been opened to visit:\n%v\n", authURL)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		tok, err := cfg.Exchange(ctx, res.code, verifier.exchangeParam())
+		if err != nil {
+			return nil, fmt.Errorf("GetTokenFromLoopback exchange authorization code: %w", err)
+		}
+		return tok, nil
+	}
+}
+
+// loopbackResult is the outcome of the redirect back from the consent page:
+// an authorization code or an error.
+type loopbackResult struct {
+	code string
+	err  error
+}
+
+// loopbackHandler serves the redirect back from the consent page and sends
+// its outcome to results. Only a request echoing state is taken for the
+// redirect: any other one, such as the browser asking for a favicon or a
+// stray request from another local process, gets a 404 or 400 and the flow
+// keeps waiting. The state does not say who sent the request, only that they
+// saw the consent URL.
+func loopbackHandler(state string, results chan<- loopbackResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code") == "" && q.Get("error") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(state)) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<p>Unexpected redirect: the state does not match.</p>")
+			return
+		}
+		var res loopbackResult
+		if q.Get("error") != "" {
+			res.err = fmt.Errorf("GetTokenFromLoopback: authorization failed: %s", q.Get("error"))
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "<p>Authorization failed: %s</p>", html.EscapeString(q.Get("error")))
+		} else {
+			res.code = q.Get("code")
+			fmt.Fprint(w, "<p>Authorization complete. You can close this window.</p>")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+}
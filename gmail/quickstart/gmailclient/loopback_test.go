@@ -0,0 +1,73 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoopbackHandler(t *testing.T) {
+	results := make(chan loopbackResult, 1)
+	ts := httptest.NewServer(loopbackHandler("s1", results))
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		path   string
+		status int
+		code   string
+		err    string // in the error sent, if any
+		none   bool   // nothing is sent
+	}{
+		{path: "/favicon.ico", status: http.StatusNotFound, none: true},
+		{path: "/?state=s1", status: http.StatusNotFound, none: true},
+		{path: "/?error=access_denied&state=s2", status: http.StatusBadRequest, none: true},
+		{path: "/?error=access_denied&state=s1", status: http.StatusBadRequest, err: "access_denied"},
+		{path: "/?code=c1", status: http.StatusBadRequest, none: true},
+		{path: "/?code=c1&state=", status: http.StatusBadRequest, none: true},
+		{path: "/?code=c1&state=s2", status: http.StatusBadRequest, none: true},
+		{path: "/?code=c1&state=s1x", status: http.StatusBadRequest, none: true},
+		{path: "/?code=c1&state=s1", status: http.StatusOK, code: "c1"},
+	} {
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+		select {
+		case res := <-results:
+			switch {
+			case tt.none:
+				t.Errorf("%s: sent %q, %v; want nothing", tt.path, res.code, res.err)
+			case tt.err == "" && (res.err != nil || res.code != tt.code):
+				t.Errorf("%s: sent %q, %v; want code %q", tt.path, res.code, res.err, tt.code)
+			case tt.err != "" && (res.err == nil || !strings.Contains(res.err.Error(), tt.err)):
+				t.Errorf("%s: sent %q, %v; want an error with %q", tt.path, res.code, res.err, tt.err)
+			}
+		default:
+			if !tt.none {
+				t.Errorf("%s: sent nothing", tt.path)
+			}
+		}
+	}
+}
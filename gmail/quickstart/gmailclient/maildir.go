@@ -0,0 +1,119 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Maildir is a mail directory with the cur, new and tmp subdirectories of
+// the Maildir format, as read by Dovecot, notmuch and mu.
+type Maildir struct {
+	Path string
+}
+
+// OpenMaildir returns the Maildir at path, creating it if needed.
+func OpenMaildir(path string) (*Maildir, error) {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(path, sub), 0700); err != nil {
+			return nil, fmt.Errorf("OpenMaildir: %w", err)
+		}
+	}
+	return &Maildir{Path: path}, nil
+}
+
+// MaildirFlags returns the Maildir info flags for Gmail labels: S (seen)
+// unless UNREAD, F for STARRED, T for TRASH and D for DRAFT, in the ASCII
+// order the format requires.
+func MaildirFlags(labels []string) string {
+	flags := []string{"S"}
+	for _, l := range labels {
+		switch l {
+		case "UNREAD":
+			flags[0] = ""
+		case "STARRED":
+			flags = append(flags, "F")
+		case "TRASH":
+			flags = append(flags, "T")
+		case "DRAFT":
+			flags = append(flags, "D")
+		}
+	}
+	sort.Strings(flags)
+	return strings.Join(flags, "")
+}
+
+// maildirHost is the host part of Maildir file names, with the characters
+// the format reserves replaced.
+var maildirHost = func() string {
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		h = "localhost"
+	}
+	return strings.NewReplacer("/", `\057`, ":", `\072`).Replace(h)
+}()
+
+// maildirInfo starts the info part of Maildir file names, which holds the
+// flags. The format has a colon, which Windows does not allow in file names;
+// there it is a semicolon, as mbsync uses.
+var maildirInfo = func() string {
+	if runtime.GOOS == "windows" {
+		return ";2,"
+	}
+	return ":2,"
+}()
+
+// WriteMessage stores a message fetched in the "raw" format in cur with
+// flags derived from its labels, and returns the file's path. The file name
+// is derived from the Gmail message ID, so writing a message again replaces
+// the earlier copy instead of duplicating it.
+func (d *Maildir) WriteMessage(m *gmail.Message) (string, error) {
+	raw, err := RawMessage(m)
+	if err != nil {
+		return "", err
+	}
+	unique := fmt.Sprintf("%d.G%s.%s", receivedAt(m).Unix(), m.Id, maildirHost)
+	tmp := filepath.Join(d.Path, "tmp", unique)
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return "", fmt.Errorf("Maildir write %s: %w", m.Id, err)
+	}
+	if err := os.Chtimes(tmp, receivedAt(m), receivedAt(m)); err != nil {
+		return "", err
+	}
+
+	// Drop copies with other flags from an earlier export.
+	old, _ := filepath.Glob(filepath.Join(d.Path, "cur", unique+maildirInfo+"*"))
+	path := filepath.Join(d.Path, "cur", unique+maildirInfo+MaildirFlags(m.LabelIds))
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("Maildir deliver %s: %w", m.Id, err)
+	}
+	for _, p := range old {
+		if p != path {
+			os.Remove(p)
+		}
+	}
+	return path, nil
+}
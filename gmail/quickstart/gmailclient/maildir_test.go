@@ -0,0 +1,82 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestMaildirFlags(t *testing.T) {
+	for _, tt := range []struct {
+		labels []string
+		want   string
+	}{
+		{nil, "S"},
+		{[]string{"INBOX", "UNREAD"}, ""},
+		{[]string{"STARRED", "INBOX"}, "FS"},
+		{[]string{"TRASH", "UNREAD", "STARRED"}, "FT"},
+		{[]string{"DRAFT"}, "DS"},
+	} {
+		if got := gmailclient.MaildirFlags(tt.labels); got != tt.want {
+			t.Errorf("MaildirFlags(%v) = %q, want %q", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestMaildirWriteMessage(t *testing.T) {
+	d, err := gmailclient.OpenMaildir(filepath.Join(t.TempDir(), "Maildir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &gmail.Message{
+		Id:           "17a1",
+		InternalDate: 1620036000000,
+		LabelIds:     []string{"INBOX", "UNREAD"},
+		Raw:          base64.URLEncoding.EncodeToString([]byte("Subject: Hi\r\n\r\nHello\r\n")),
+	}
+	if _, err := d.WriteMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	m.LabelIds = []string{"INBOX", "STARRED"}
+	path, err := d.WriteMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := ":2,FS"
+	if runtime.GOOS == "windows" {
+		info = ";2,FS"
+	}
+	if !strings.HasPrefix(filepath.Base(path), "1620036000.G17a1.") || !strings.HasSuffix(path, info) {
+		t.Errorf("path = %s", path)
+	}
+	files, _ := filepath.Glob(filepath.Join(d.Path, "cur", "*"))
+	if len(files) != 1 {
+		t.Errorf("cur holds %d files, want 1: %v", len(files), files)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "Subject: Hi\r\n\r\nHello\r\n" {
+		t.Errorf("contents = %q", b)
+	}
+}
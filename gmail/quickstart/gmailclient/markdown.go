@@ -0,0 +1,215 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// markdownBlocks lists the elements rendered as separate paragraphs. Layout
+// tables, which most newsletters are built from, become a plain sequence of
+// paragraphs.
+var markdownBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Table: true, atom.Tbody: true,
+	atom.Thead: true, atom.Tfoot: true, atom.Tr: true, atom.Td: true,
+	atom.Th: true, atom.Center: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Main: true, atom.Dl: true,
+	atom.Dt: true, atom.Dd: true, atom.Figure: true, atom.Caption: true,
+}
+
+// manyNewlines matches three or more newlines with only spaces between.
+var manyNewlines = regexp.MustCompile(`\n[ \t]*\n(\s*\n)+`)
+
+// HTMLToMarkdown converts an HTML body to Markdown for note-taking tools such
+// as Obsidian. Headings, emphasis, links, images, lists, quotes, code and rules
+// are kept; layout markup, scripts, styles and tracking pixels are dropped.
+func HTMLToMarkdown(s string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return "", fmt.Errorf("HTMLToMarkdown: %w", err)
+	}
+	md := markdownChildren(doc)
+	md = manyNewlines.ReplaceAllString(md, "\n\n")
+	lines := strings.Split(md, "\n")
+	for i, l := range lines {
+		l = strings.TrimLeft(l, " \t")
+		if !strings.HasSuffix(l, "  ") {
+			l = strings.TrimRight(l, " \t")
+		}
+		if l = strings.Replace(l, indent, " ", -1); strings.TrimSpace(l) == "" {
+			l = ""
+		}
+		lines[i] = l
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n", nil
+}
+
+// indent stands for indentation that must survive the trimming of stray
+// white space at the start of lines, as in list items and code blocks.
+const indent = "\x00"
+
+func markdownChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(markdownNode(c))
+	}
+	return b.String()
+}
+
+func markdownNode(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return collapseSpace(n.Data)
+	case html.DocumentNode:
+		return markdownChildren(n)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Title, atom.Noscript, atom.Template:
+		return ""
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + inlineText(markdownChildren(n)) + "\n\n"
+	case atom.B, atom.Strong:
+		return wrapInline(markdownChildren(n), "**")
+	case atom.I, atom.Em:
+		return wrapInline(markdownChildren(n), "_")
+	case atom.Code, atom.Tt, atom.Kbd:
+		return wrapInline(textOf(n), "`")
+	case atom.Br:
+		return "  \n"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.A:
+		text := inlineText(markdownChildren(n))
+		href := attr(n, "href")
+		if text == "" || !safeURL(href, "http:", "https:", "mailto:") {
+			return text
+		}
+		if text == href {
+			return "<" + href + ">"
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		src := attr(n, "src")
+		if !safeURL(src, "http:", "https:") || attr(n, "width") == "1" || attr(n, "height") == "1" {
+			return ""
+		}
+		return "![" + attr(n, "alt") + "](" + src + ")"
+	case atom.Pre:
+		code := strings.Split(strings.Trim(textOf(n), "\n"), "\n")
+		for i, l := range code {
+			t := strings.TrimLeft(l, " ")
+			code[i] = strings.Repeat(indent, len(l)-len(t)) + t
+		}
+		return "\n\n```\n" + strings.Join(code, "\n") + "\n```\n\n"
+	case atom.Blockquote:
+		inner := strings.TrimSpace(manyNewlines.ReplaceAllString(markdownChildren(n), "\n\n"))
+		return "\n\n" + prefixLines(inner, "> ", "> ") + "\n\n"
+	case atom.Ul, atom.Ol:
+		var b strings.Builder
+		i := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+				continue
+			}
+			i++
+			marker := "- "
+			if n.DataAtom == atom.Ol {
+				marker = strconv.Itoa(i) + ". "
+			}
+			item := strings.TrimSpace(manyNewlines.ReplaceAllString(markdownChildren(c), "\n"))
+			b.WriteString(prefixLines(item, marker, strings.Repeat(indent, len(marker))) + "\n")
+		}
+		return "\n\n" + b.String() + "\n"
+	}
+	if markdownBlocks[n.DataAtom] {
+		return "\n\n" + markdownChildren(n) + "\n\n"
+	}
+	return markdownChildren(n)
+}
+
+// collapseSpace replaces runs of white space with single spaces.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == ' ' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// inlineText joins s onto one line.
+func inlineText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// wrapInline wraps s in marker, keeping surrounding spaces outside it.
+func wrapInline(s, marker string) string {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, t)]
+	trail := s[strings.Index(s, t)+len(t):]
+	return lead + marker + inlineText(t) + marker + trail
+}
+
+// prefixLines prefixes the first line of s with first and the others with
+// rest.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		lines[i] = strings.TrimRight(p+lines[i], " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// attr returns the value of n's attribute key.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
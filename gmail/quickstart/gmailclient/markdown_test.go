@@ -0,0 +1,56 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	in := `<html><head><style>td { color: red }</style></head><body>
+<table><tr><td>
+<h2>Weekly   tips</h2>
+<p>Hello <b>there</b>, read <a href="https://example.com/post">the post</a>.<br>
+Or <em>don't</em>.</p>
+<ul><li>one</li><li>two <code>:wq</code></li></ul>
+<ol><li>first</li><li>second</li></ol>
+<blockquote><p>Quoted</p><p>text</p></blockquote>
+<pre>:set number
+:set hlsearch</pre>
+<img src="https://t.example/open.gif" width="1" height="1">
+<img src="https://example.com/logo.png" alt="Logo">
+</td></tr></table>
+</body></html>`
+	want := "## Weekly tips\n\n" +
+		"Hello **there**, read [the post](https://example.com/post).  \n" +
+		"Or _don't_.\n\n" +
+		"- one\n- two `:wq`\n\n" +
+		"1. first\n2. second\n\n" +
+		"> Quoted\n>\n> text\n\n" +
+		"```\n:set number\n:set hlsearch\n```\n\n" +
+		"![Logo](https://example.com/logo.png)\n"
+	got, err := gmailclient.HTMLToMarkdown(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
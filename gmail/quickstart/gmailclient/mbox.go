@@ -0,0 +1,157 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// RawMessage returns the RFC 822 bytes of a message fetched in the "raw"
+// format.
+func RawMessage(m *gmail.Message) ([]byte, error) {
+	if m.Raw == "" {
+		return nil, fmt.Errorf("RawMessage %s: message was not fetched in the raw format", m.Id)
+	}
+	raw, err := base64.URLEncoding.DecodeString(m.Raw)
+	if err != nil {
+		if raw, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(m.Raw, "=")); err != nil {
+			return nil, fmt.Errorf("RawMessage %s base64 decode: %w", m.Id, err)
+		}
+	}
+	return raw, nil
+}
+
+// receivedAt returns the time Gmail received m.
+func receivedAt(m *gmail.Message) time.Time {
+	return time.Unix(0, m.InternalDate*int64(time.Millisecond))
+}
+
+// MboxWriter writes messages to an mbox file in the mboxrd variant: body
+// lines that start with "From ", after any number of ">", get one more ">"
+// so that readers can undo the quoting exactly.
+type MboxWriter struct {
+	w *bufio.Writer
+}
+
+// NewMboxWriter returns an MboxWriter appending to w.
+func NewMboxWriter(w io.Writer) *MboxWriter {
+	return &MboxWriter{w: bufio.NewWriter(w)}
+}
+
+// fromLine matches the lines mboxrd quotes.
+var fromLine = regexp.MustCompile(`^>*From `)
+
+// Write appends one message with the given envelope sender and date. raw may
+// use CRLF or LF line endings; the mbox file always uses LF.
+func (w *MboxWriter) Write(raw []byte, sender string, date time.Time) error {
+	if sender == "" {
+		sender = "MAILER-DAEMON"
+	}
+	fmt.Fprintf(w.w, "From %s %s\n", sender, date.UTC().Format(time.ANSIC))
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if fromLine.Match(line) {
+			w.w.WriteByte('>')
+		}
+		w.w.Write(line)
+		w.w.WriteByte('\n')
+	}
+	w.w.WriteByte('\n')
+	return w.w.Flush()
+}
+
+// WriteMessage appends a message fetched in the "raw" format, using its From
+// address as the envelope sender and the time Gmail received it as the date.
+func (w *MboxWriter) WriteMessage(m *gmail.Message) error {
+	raw, err := RawMessage(m)
+	if err != nil {
+		return err
+	}
+	var sender string
+	if msg, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		if from := ParseAddressList(msg.Header.Get("From")); len(from) > 0 && !strings.ContainsAny(from[0].Address, " \t") {
+			sender = from[0].Address
+		}
+	}
+	return w.Write(raw, sender, receivedAt(m))
+}
+
+// MboxReader reads the messages of an mbox file, undoing the mboxrd quoting
+// MboxWriter adds. Files in the older mboxo variant read the same, except
+// that a quoted ">From " line in a body loses its ">".
+type MboxReader struct {
+	r    *bufio.Reader
+	next []byte // the From line starting the next message
+}
+
+// NewMboxReader returns an MboxReader reading from r.
+func NewMboxReader(r io.Reader) *MboxReader {
+	return &MboxReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next message with LF line endings, or io.EOF after the
+// last one.
+func (r *MboxReader) Next() ([]byte, error) {
+	var msg bytes.Buffer
+	started := r.next != nil
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				if started {
+					r.next = line
+					return bytes.TrimSuffix(msg.Bytes(), []byte("\n")), nil
+				}
+				started = true
+			case !started:
+				if len(bytes.TrimSpace(line)) > 0 {
+					return nil, fmt.Errorf("MboxReader: not an mbox file")
+				}
+			default:
+				if fromLine.Match(line) {
+					line = line[1:]
+				}
+				msg.Write(line)
+				msg.WriteByte('\n')
+			}
+		}
+		if err == io.EOF {
+			r.next = nil
+			if !started {
+				return nil, io.EOF
+			}
+			return bytes.TrimSuffix(msg.Bytes(), []byte("\n")), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("MboxReader: %w", err)
+		}
+	}
+}
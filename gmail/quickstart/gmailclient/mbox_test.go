@@ -0,0 +1,76 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestMboxWriter(t *testing.T) {
+	raw := "From: Ann <ann@example.com>\r\nSubject: Hi\r\n\r\nFrom here on\r\n>From quoted\r\nFromage\r\n"
+	var buf bytes.Buffer
+	w := gmailclient.NewMboxWriter(&buf)
+	err := w.WriteMessage(&gmail.Message{
+		Id:           "1",
+		InternalDate: 1620036000000,
+		Raw:          base64.URLEncoding.EncodeToString([]byte(raw)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "From ann@example.com Mon May  3 10:00:00 2021\n" +
+		"From: Ann <ann@example.com>\nSubject: Hi\n\n" +
+		">From here on\n>>From quoted\nFromage\n\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestMboxReader(t *testing.T) {
+	msgs := []string{
+		"From: Ann <ann@example.com>\nSubject: Hi\n\nFrom here on\n>From quoted\nFromage\n",
+		"From: Bob <bob@example.com>\nSubject: Re: Hi\n\n\nBlank lines around\n\n",
+	}
+	var buf bytes.Buffer
+	w := gmailclient.NewMboxWriter(&buf)
+	for _, m := range msgs {
+		if err := w.Write([]byte(m), "", time.Unix(0, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := gmailclient.NewMboxReader(&buf)
+	for i, want := range msgs {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if string(got) != want {
+			t.Errorf("message %d = %q, want %q", i, got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("after the last message got %v, want io.EOF", err)
+	}
+}
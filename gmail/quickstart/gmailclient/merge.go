@@ -0,0 +1,121 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MergeRow is one row of a mail merge.
+type MergeRow struct {
+	// Line is the line of the CSV file the row starts on, for reporting.
+	// Quoted values may span lines, so it is not simply the row number.
+	Line int
+
+	// Values maps each column name to the row's value, for use as
+	// MessageTemplate data.
+	Values map[string]string
+}
+
+// ReadMergeCSV reads the rows of a mail merge from CSV: the first record
+// names the columns, and each further record becomes a MergeRow. A leading
+// UTF-8 byte order mark, as written by spreadsheets, is skipped, and column
+// names are trimmed of spaces. Column names must be unique and not empty.
+func ReadMergeCSV(r io.Reader) ([]MergeRow, error) {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(3); err == nil && bytes.Equal(b, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	line := 0
+	_, header, err := readMergeRecord(br, &line)
+	if err == io.EOF {
+		return nil, fmt.Errorf("ReadMergeCSV: no header row")
+	} else if err != nil {
+		return nil, fmt.Errorf("ReadMergeCSV: %w", err)
+	}
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return nil, fmt.Errorf("ReadMergeCSV: column %d: empty or duplicate name %q", i+1, name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	var rows []MergeRow
+	for {
+		start, record, err := readMergeRecord(br, &line)
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("ReadMergeCSV: %w", err)
+		}
+		if len(record) != len(header) {
+			return nil, fmt.Errorf("ReadMergeCSV: line %d: %d fields, want %d", start, len(record), len(header))
+		}
+		row := MergeRow{Line: start, Values: make(map[string]string, len(header))}
+		for i, name := range header {
+			row.Values[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readMergeRecord reads the next CSV record from br and returns the line it
+// starts on; *line counts the lines read so far. encoding/csv does not say
+// where a record starts, so the lines of one are gathered here until their
+// quotes balance, and then parsed on their own. Blank lines are skipped, as
+// encoding/csv does.
+func readMergeRecord(br *bufio.Reader, line *int) (int, []string, error) {
+	var text string
+	start := 0
+	for {
+		s, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		if s == "" {
+			if text == "" {
+				return 0, nil, io.EOF
+			}
+			break // an unterminated quote, which csv reports
+		}
+		*line++
+		if text == "" && strings.TrimRight(s, "\r\n") == "" {
+			continue
+		}
+		if text == "" {
+			start = *line
+		}
+		text += s
+		if strings.Count(text, `"`)%2 == 0 || err == io.EOF {
+			break
+		}
+	}
+	record, err := csv.NewReader(strings.NewReader(text)).Read()
+	if pe, ok := err.(*csv.ParseError); ok {
+		pe.StartLine += start - 1
+		pe.Line += start - 1
+	}
+	return start, record, err
+}
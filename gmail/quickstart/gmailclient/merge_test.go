@@ -0,0 +1,71 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestReadMergeCSV(t *testing.T) {
+	rows, err := gmailclient.ReadMergeCSV(strings.NewReader("\xef\xbb\xbfemail, name\nana@example.com,Ana\n\"bo@example.com\",\"Bo, Jr.\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Values["email"] != "ana@example.com" || rows[1].Values["name"] != "Bo, Jr." {
+		t.Errorf("rows = %v", rows)
+	}
+
+	for _, in := range []string{"", "email,email\na,b\n", "email,name\nana@example.com\n", "email,name\n\"ana@example.com,Ana\n"} {
+		if _, err := gmailclient.ReadMergeCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadMergeCSV(%q) succeeded", in)
+		}
+	}
+}
+
+func TestReadMergeCSVLines(t *testing.T) {
+	in := "email,address\r\n" +
+		"ana@example.com,\"1 Main St\r\nSpringfield\"\r\n" +
+		"\r\n" +
+		"bo@example.com,\"Flat \"\"B\"\"\n2 High St\nLeeds\"\n" +
+		"cy@example.com,3 Low Rd"
+	rows, err := gmailclient.ReadMergeCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, r := range rows {
+		lines = append(lines, r.Line)
+	}
+	if len(rows) != 3 || lines[0] != 2 || lines[1] != 5 || lines[2] != 8 {
+		t.Errorf("lines = %v, want [2 5 8]", lines)
+	}
+	if got := rows[1].Values["address"]; got != "Flat \"B\"\n2 High St\nLeeds" {
+		t.Errorf("address = %q", got)
+	}
+	if got := rows[2].Values["address"]; got != "3 Low Rd" {
+		t.Errorf("address = %q", got)
+	}
+
+	_, err = gmailclient.ReadMergeCSV(strings.NewReader("email,name\nana@example.com,Ana\n\nbo@example.com,B\"o\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("bare quote: %v, want an error on line 4", err)
+	}
+}
@@ -0,0 +1,374 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Message is the parsed form of a Gmail message. It marshals to JSON with
+// lower-case keys.
+type Message struct {
+	Id        string          `json:"id"`
+	ThreadId  string          `json:"threadId,omitempty"`
+	LabelIds  []string        `json:"labelIds,omitempty"`
+	Size      int64           `json:"size,omitempty"` // Gmail's estimate, in bytes
+	From      []*mail.Address `json:"from,omitempty"`
+	To        []*mail.Address `json:"to,omitempty"`
+	Cc        []*mail.Address `json:"cc,omitempty"`
+	Bcc       []*mail.Address `json:"bcc,omitempty"`
+	ReplyTo   []*mail.Address `json:"replyTo,omitempty"`
+	Subject   string          `json:"subject"`
+	BodyPlain string          `json:"bodyPlain,omitempty"`
+	BodyHtml  string          `json:"bodyHtml,omitempty"`
+
+	// Headers holds every header of the message, including those with
+	// fields of their own above.
+	Headers Header `json:"headers"`
+
+	// Attachments lists the parts that have a filename, so callers can
+	// decide what to download without walking the payload.
+	Attachments []*Attachment `json:"attachments,omitempty"`
+
+	// Embedded holds the messages attached as message/rfc822 parts, such
+	// as forwarded messages and the originals in bounce reports.
+	Embedded []*Message `json:"embedded,omitempty"`
+
+	// Events holds the events of the text/calendar parts, such as meeting
+	// invitations and their updates.
+	Events []*Event `json:"events,omitempty"`
+
+	// Contacts holds the contact cards of the vCard parts.
+	Contacts []*Contact `json:"contacts,omitempty"`
+
+	// Signature is set for signed messages, S/MIME or PGP/MIME. Checking it
+	// needs the raw message; see VerifySMIME.
+	Signature *Signature `json:"signature,omitempty"`
+
+	// Date is parsed from the Date header, or is the time Gmail received
+	// the message if that header is missing or unparseable.
+	Date time.Time `json:"date"`
+
+	// Preferred is the MIME type of the body the sender ranked best:
+	// multipart/alternative lists its parts from the plainest to the
+	// richest, so it is the last text/plain or text/html alternative.
+	Preferred string `json:"preferred,omitempty"`
+}
+
+// Body returns the body to display and its MIME type. prefer is "plain",
+// "html", or "" or "auto" to follow Preferred. If the preferred body is empty
+// the other one is returned.
+func (m *Message) Body(prefer string) (body, mimeType string) {
+	if prefer == "" || prefer == "auto" {
+		prefer = "html"
+		if m.Preferred == "text/plain" {
+			prefer = "plain"
+		}
+	}
+	if m.BodyHtml == "" || (prefer == "plain" && m.BodyPlain != "") {
+		return m.BodyPlain, "text/plain"
+	}
+	return m.BodyHtml, "text/html"
+}
+
+// FindHeader returns the value of the named header with its RFC 2047
+// encoded-words decoded, or "" if it is missing. Names are matched
+// case-insensitively.
+func FindHeader(messagePart *gmail.MessagePart, name string) string {
+	return partHeader(messagePart).Get(name)
+}
+
+// FindMessagePartByMimeType walks the part tree depth-first and returns the
+// first part with the given MIME type, or nil. Attachments are skipped, so
+// that an attached notes.txt is not taken for the body.
+func FindMessagePartByMimeType(messagePart *gmail.MessagePart, mimeType string) *gmail.MessagePart {
+	if isAttachmentPart(messagePart) {
+		return nil
+	}
+	if messagePart.MimeType == mimeType {
+		return messagePart
+	}
+	if strings.HasPrefix(messagePart.MimeType, "multipart") {
+		for _, part := range messagePart.Parts {
+			if mp := FindMessagePartByMimeType(part, mimeType); mp != nil {
+				return mp
+			}
+		}
+	}
+	return nil
+}
+
+// GetMessagePartData returns the decoded body of a message part, fetching it
+// as an attachment when it is not inlined in the message. Gmail removes the
+// base64 transfer encoding but can leave quoted-printable in place, so that is
+// undone here. Text parts are converted to UTF-8 from the charset in their
+// Content-Type.
+func GetMessagePartData(ctx context.Context, srv GmailService, user, messageId string, messagePart *gmail.MessagePart) (string, error) {
+	data, err := partData(ctx, srv, user, messageId, messagePart)
+	if err != nil {
+		return "", fmt.Errorf("GetMessagePartData %w", err)
+	}
+	if strings.HasPrefix(messagePart.MimeType, "text/") {
+		return toUTF8(data, partCharset(messagePart)), nil
+	}
+	return string(data), nil
+}
+
+// partData returns the body of a message part with its transfer encoding
+// undone, fetching it as an attachment when it is not inlined in the message.
+func partData(ctx context.Context, srv GmailService, user, messageId string, messagePart *gmail.MessagePart) ([]byte, error) {
+	r, err := partReader(ctx, srv, user, messageId, messagePart)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return data, nil
+}
+
+// partReader is like partData but decodes the body as it is read, so the
+// decoded data is never held in memory as a whole.
+func partReader(ctx context.Context, srv GmailService, user, messageId string, messagePart *gmail.MessagePart) (io.Reader, error) {
+	var dataBase64 string
+
+	if messagePart.Body.AttachmentId != "" {
+		body, err := srv.GetAttachment(ctx, user, messageId, messagePart.Body.AttachmentId)
+		if err != nil {
+			return nil, fmt.Errorf("get attachment: %w", err)
+		}
+
+		dataBase64 = body.Data
+	} else {
+		dataBase64 = messagePart.Body.Data
+	}
+
+	r := base64.NewDecoder(base64.URLEncoding, strings.NewReader(dataBase64))
+	if strings.EqualFold(FindHeader(messagePart, "Content-Transfer-Encoding"), "quoted-printable") {
+		r = quotedprintable.NewReader(r)
+	}
+	return r, nil
+}
+
+// GetMessageBody returns the decoded data of the first part of gmailMessage
+// with the given MIME type. It returns an error wrapping ErrPartNotFound if
+// there is no such part.
+func GetMessageBody(ctx context.Context, srv GmailService, gmailMessage *gmail.Message, user, mimeType string) (string, error) {
+	if gmailMessage.Payload == nil {
+		return "", ErrNoPayload
+	}
+	part := FindMessagePartByMimeType(gmailMessage.Payload, mimeType)
+	if part == nil {
+		return "", fmt.Errorf("%w: %s", ErrPartNotFound, mimeType)
+	}
+	return GetMessagePartData(ctx, srv, user, gmailMessage.Id, part)
+}
+
+// isAttachmentPart reports whether part is an attachment rather than a body:
+// it has a filename or is marked "Content-Disposition: attachment".
+func isAttachmentPart(part *gmail.MessagePart) bool {
+	if part.Filename != "" {
+		return true
+	}
+	disposition, _, _ := mime.ParseMediaType(FindHeader(part, "Content-Disposition"))
+	return disposition == "attachment"
+}
+
+// preferredType returns the MIME type of the best text body under part: the
+// last usable alternative of a multipart/alternative, or the first text body
+// of any other multipart. Attachments are skipped.
+func preferredType(part *gmail.MessagePart) string {
+	switch {
+	case isAttachmentPart(part):
+	case part.MimeType == "text/plain" || part.MimeType == "text/html":
+		return part.MimeType
+	case part.MimeType == "multipart/alternative":
+		for i := len(part.Parts) - 1; i >= 0; i-- {
+			if t := preferredType(part.Parts[i]); t != "" {
+				return t
+			}
+		}
+	case strings.HasPrefix(part.MimeType, "multipart/"):
+		for _, p := range part.Parts {
+			if t := preferredType(p); t != "" {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+// ParseMessage converts a message fetched in "full" format into a Message.
+func ParseMessage(ctx context.Context, srv GmailService, gmailMessage *gmail.Message, user string) (*Message, error) {
+	return parseMessage(ctx, srv, gmailMessage, user, 0)
+}
+
+func parseMessage(ctx context.Context, srv GmailService, gmailMessage *gmail.Message, user string, depth int) (*Message, error) {
+	if gmailMessage.Payload == nil {
+		return nil, ErrNoPayload
+	}
+	if depth > maxMIMEDepth {
+		return nil, fmt.Errorf("ParseMessage: messages embedded more than %d levels deep", maxMIMEDepth)
+	}
+
+	message, err := ParseMetadata(gmailMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	plainMessage, err := GetMessageBody(ctx, srv, gmailMessage, user, "text/plain")
+	if err != nil && !errors.Is(err, ErrPartNotFound) {
+		return nil, fmt.Errorf("ParseMessage plain: %w", err)
+	}
+	message.BodyPlain = plainMessage
+
+	htmlMessage, err := GetMessageBody(ctx, srv, gmailMessage, user, "text/html")
+	if err != nil && !errors.Is(err, ErrPartNotFound) {
+		return nil, fmt.Errorf("ParseMessage html: %w", err)
+	}
+	message.BodyHtml = htmlMessage
+
+	if message.Events, err = parseEvents(ctx, srv, gmailMessage, user); err != nil {
+		return nil, fmt.Errorf("ParseMessage calendar: %w", err)
+	}
+	if message.Contacts, err = MessageContacts(ctx, srv, gmailMessage, user); err != nil {
+		return nil, fmt.Errorf("ParseMessage contacts: %w", err)
+	}
+
+	for _, part := range embeddedParts(gmailMessage.Payload) {
+		root, err := embeddedRoot(ctx, srv, gmailMessage, user, part, depth)
+		if err != nil {
+			return nil, fmt.Errorf("ParseMessage embedded message: %w", err)
+		}
+		embedded, err := parseMessage(ctx, srv, &gmail.Message{Id: gmailMessage.Id, Payload: root}, user, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		message.Embedded = append(message.Embedded, embedded)
+	}
+
+	return message, nil
+}
+
+// ParseMetadata converts the headers and part structure of gmailMessage into
+// a Message without fetching anything, leaving the bodies and embedded
+// messages empty. It suits messages fetched with StructureFields or in the
+// "metadata" format.
+func ParseMetadata(gmailMessage *gmail.Message) (*Message, error) {
+	if gmailMessage.Payload == nil {
+		return nil, ErrNoPayload
+	}
+	return &Message{
+		Id:          gmailMessage.Id,
+		ThreadId:    gmailMessage.ThreadId,
+		LabelIds:    gmailMessage.LabelIds,
+		Size:        gmailMessage.SizeEstimate,
+		From:        findAddresses(gmailMessage.Payload, "From"),
+		To:          findAddresses(gmailMessage.Payload, "To"),
+		Cc:          findAddresses(gmailMessage.Payload, "Cc"),
+		Bcc:         findAddresses(gmailMessage.Payload, "Bcc"),
+		ReplyTo:     findAddresses(gmailMessage.Payload, "Reply-To"),
+		Subject:     FindHeader(gmailMessage.Payload, "Subject"),
+		Headers:     partHeader(gmailMessage.Payload),
+		Attachments: ListAttachments(gmailMessage.Payload),
+		Date:        messageDate(gmailMessage),
+		Preferred:   preferredType(gmailMessage.Payload),
+		Signature:   messageSignature(gmailMessage.Payload),
+	}, nil
+}
+
+// embeddedParts returns the message/rfc822 parts of the multipart tree under
+// part, without looking inside them.
+func embeddedParts(part *gmail.MessagePart) []*gmail.MessagePart {
+	var list []*gmail.MessagePart
+	for _, p := range part.Parts {
+		switch {
+		case p.MimeType == "message/rfc822":
+			list = append(list, p)
+		case strings.HasPrefix(p.MimeType, "multipart/"):
+			list = append(list, embeddedParts(p)...)
+		}
+	}
+	return list
+}
+
+// embeddedRoot returns the root part of the message embedded in part. Gmail
+// usually parses embedded messages itself; when it leaves one as an opaque
+// body, the raw message is downloaded and parsed here.
+func embeddedRoot(ctx context.Context, srv GmailService, gmailMessage *gmail.Message, user string, part *gmail.MessagePart, depth int) (*gmail.MessagePart, error) {
+	if len(part.Parts) == 1 {
+		return part.Parts[0], nil
+	}
+	raw, err := GetMessagePartData(ctx, srv, user, gmailMessage.Id, part)
+	if err != nil {
+		return nil, err
+	}
+	return parseRFC822([]byte(raw), depth+1)
+}
+
+// jsonAddress is the JSON form of a mail.Address.
+type jsonAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+func jsonAddresses(list []*mail.Address) []jsonAddress {
+	if list == nil {
+		return nil
+	}
+	out := make([]jsonAddress, len(list))
+	for i, a := range list {
+		out[i] = jsonAddress{Name: a.Name, Address: a.Address}
+	}
+	return out
+}
+
+// MarshalJSON encodes m with the addresses as {"name", "address"} objects,
+// matching the lower-case keys of the other fields.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	return json.Marshal(struct {
+		*message
+		From    []jsonAddress `json:"from,omitempty"`
+		To      []jsonAddress `json:"to,omitempty"`
+		Cc      []jsonAddress `json:"cc,omitempty"`
+		Bcc     []jsonAddress `json:"bcc,omitempty"`
+		ReplyTo []jsonAddress `json:"replyTo,omitempty"`
+	}{
+		message: (*message)(m),
+		From:    jsonAddresses(m.From),
+		To:      jsonAddresses(m.To),
+		Cc:      jsonAddresses(m.Cc),
+		Bcc:     jsonAddresses(m.Bcc),
+		ReplyTo: jsonAddresses(m.ReplyTo),
+	})
+}
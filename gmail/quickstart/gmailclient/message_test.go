@@ -0,0 +1,228 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestParseMessage(t *testing.T) {
+	srv := gmailclienttest.New()
+	msg, err := srv.LoadMessage("testdata/multipart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.AddAttachment("att-html", &gmail.MessagePartBody{
+		Data: base64.URLEncoding.EncodeToString([]byte("<p>Hello there</p>")),
+	})
+
+	m, err := gmailclient.ParseMessage(context.Background(), srv, msg, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.From) != 1 || m.From[0].Name != "Vim Tricks" || m.From[0].Address != "hi@vimtricks.com" {
+		t.Errorf("From = %v", m.From)
+	}
+	if m.Subject != "Weekly tips" {
+		t.Errorf("Subject = %q", m.Subject)
+	}
+	if m.BodyHtml != "<p>Hello there</p>" {
+		t.Errorf("BodyHtml = %q", m.BodyHtml)
+	}
+	if got := m.Headers.Get("message-id"); got != "<abc@vimtricks.com>" {
+		t.Errorf("Headers.Get(message-id) = %q", got)
+	}
+	if len(m.Headers) != 6 {
+		t.Errorf("len(Headers) = %d, want 6", len(m.Headers))
+	}
+}
+
+func TestParseMessageNoPayload(t *testing.T) {
+	_, err := gmailclient.ParseMessage(context.Background(), gmailclienttest.New(), &gmail.Message{Id: "1"}, "me")
+	if !errors.Is(err, gmailclient.ErrNoPayload) {
+		t.Errorf("err = %v, want ErrNoPayload", err)
+	}
+}
+
+func TestGetMessageBodyPartNotFound(t *testing.T) {
+	srv := gmailclienttest.New()
+	msg, err := srv.LoadMessage("testdata/multipart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = gmailclient.GetMessageBody(context.Background(), srv, msg, "me", "text/calendar")
+	if !errors.Is(err, gmailclient.ErrPartNotFound) {
+		t.Errorf("err = %v, want ErrPartNotFound", err)
+	}
+}
+
+func TestMessageBody(t *testing.T) {
+	srv := gmailclienttest.New()
+	msg, err := srv.LoadMessage("testdata/multipart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.AddAttachment("att-html", &gmail.MessagePartBody{
+		Data: base64.URLEncoding.EncodeToString([]byte("<p>Hello there</p>")),
+	})
+	m, err := gmailclient.ParseMessage(context.Background(), srv, msg, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.BodyPlain != "Hello there\n" {
+		t.Errorf("BodyPlain = %q", m.BodyPlain)
+	}
+	if m.Preferred != "text/html" {
+		t.Errorf("Preferred = %q, want text/html", m.Preferred)
+	}
+
+	for _, tt := range []struct {
+		prefer, body, mimeType string
+	}{
+		{"auto", "<p>Hello there</p>", "text/html"},
+		{"html", "<p>Hello there</p>", "text/html"},
+		{"plain", "Hello there\n", "text/plain"},
+	} {
+		body, mimeType := m.Body(tt.prefer)
+		if body != tt.body || mimeType != tt.mimeType {
+			t.Errorf("Body(%q) = %q, %q; want %q, %q", tt.prefer, body, mimeType, tt.body, tt.mimeType)
+		}
+	}
+
+	m.BodyHtml = ""
+	if _, mimeType := m.Body("html"); mimeType != "text/plain" {
+		t.Errorf("Body(html) without an HTML body = %q, want text/plain", mimeType)
+	}
+}
+
+func TestParseMessageSkipsTextAttachments(t *testing.T) {
+	text := func(mimeType, data string, headers ...*gmail.MessagePartHeader) *gmail.MessagePart {
+		return &gmail.MessagePart{MimeType: mimeType, Headers: headers,
+			Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(data))}}
+	}
+	notes := text("text/plain", "attached notes")
+	notes.Filename = "notes.txt"
+	page := text("text/html", "<p>attached page</p>",
+		&gmail.MessagePartHeader{Name: "Content-Disposition", Value: "attachment"})
+	msg := &gmail.Message{Id: "1", Payload: &gmail.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts:    []*gmail.MessagePart{notes, page, text("text/html", "<p>the body</p>")},
+	}}
+
+	m, err := gmailclient.ParseMessage(context.Background(), gmailclienttest.New(), msg, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.BodyPlain != "" || m.BodyHtml != "<p>the body</p>" || m.Preferred != "text/html" {
+		t.Errorf("BodyPlain = %q, BodyHtml = %q, Preferred = %q; want only the HTML body", m.BodyPlain, m.BodyHtml, m.Preferred)
+	}
+	if len(m.Attachments) != 1 || m.Attachments[0].Filename != "notes.txt" {
+		t.Errorf("Attachments = %+v, want notes.txt", m.Attachments)
+	}
+}
+
+func TestGetMessagePartDataQuotedPrintable(t *testing.T) {
+	part := &gmail.MessagePart{
+		MimeType: "text/plain",
+		Headers: []*gmail.MessagePartHeader{
+			{Name: "Content-Type", Value: "text/plain; charset=ISO-8859-1"},
+			{Name: "Content-Transfer-Encoding", Value: "Quoted-Printable"},
+		},
+		Body: &gmail.MessagePartBody{
+			Data: base64.URLEncoding.EncodeToString([]byte("It=92s a caf=E9 with a very long line that is soft=\r\n-wrapped.")),
+		},
+	}
+	got, err := gmailclient.GetMessagePartData(context.Background(), gmailclienttest.New(), "me", "1", part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "It’s a café with a very long line that is soft-wrapped."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseMessageEmbedded(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/forwarded.eml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Gmail leaves the embedded message as an opaque attachment here, so
+	// ParseMessage has to download and parse it.
+	srv := gmailclienttest.New()
+	srv.AddAttachment("att-eml", &gmail.MessagePartBody{
+		Data: base64.URLEncoding.EncodeToString(raw[bytes.Index(raw, []byte("From: Carol")):bytes.LastIndex(raw, []byte("\n--outer--"))]),
+	})
+	msg := &gmail.Message{
+		Id: "1",
+		Payload: &gmail.MessagePart{
+			MimeType: "multipart/mixed",
+			Headers:  []*gmail.MessagePartHeader{{Name: "Subject", Value: "Fwd: Lunch"}},
+			Parts: []*gmail.MessagePart{
+				{MimeType: "text/plain", Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("See below."))}},
+				{MimeType: "message/rfc822", Filename: "Lunch.eml", Body: &gmail.MessagePartBody{AttachmentId: "att-eml"}},
+			},
+		},
+	}
+	m, err := gmailclient.ParseMessage(context.Background(), srv, msg, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.BodyPlain != "See below." {
+		t.Errorf("BodyPlain = %q", m.BodyPlain)
+	}
+	if len(m.Embedded) != 1 {
+		t.Fatalf("len(Embedded) = %d, want 1", len(m.Embedded))
+	}
+	e := m.Embedded[0]
+	if e.Subject != "Lunch à midi" || gmailclient.FormatAddressList(e.From) != "Carol <carol@example.com>" {
+		t.Errorf("embedded Subject = %q, From = %v", e.Subject, e.From)
+	}
+	if e.BodyPlain != "Rendez-vous au café." {
+		t.Errorf("embedded BodyPlain = %q", e.BodyPlain)
+	}
+}
+
+func TestMessageJSON(t *testing.T) {
+	m := &gmailclient.Message{
+		Id:      "17a1",
+		From:    gmailclient.ParseAddressList("Vim Tricks <hi@vimtricks.com>"),
+		Subject: "Weekly tips",
+		Headers: gmailclient.Header{{Name: "Subject", Value: "Weekly tips"}},
+		Date:    time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"17a1","subject":"Weekly tips","headers":[{"name":"Subject","value":"Weekly tips"}],` +
+		`"date":"2021-05-03T10:00:00Z","from":[{"name":"Vim Tricks","address":"hi@vimtricks.com"}]}`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+}
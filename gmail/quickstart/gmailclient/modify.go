@@ -0,0 +1,150 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// MaxBatchModify is the most messages Users.Messages.BatchModify changes in
+// one call.
+const MaxBatchModify = 1000
+
+// BatchModify adds the labels add to the messages ids and removes the labels
+// remove from them, in calls of at most MaxBatchModify messages. Labels are
+// given by ID; see LabelIDs. Archiving is removing INBOX. If progress is not
+// nil it is called after every call with the number of messages changed so
+// far. It needs the gmail.modify scope.
+func BatchModify(ctx context.Context, srv ModifyService, user string, ids, add, remove []string, progress func(done int)) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	err := inBatches(ids, MaxBatchModify, progress, func(batch []string) error {
+		req := &gmail.BatchModifyMessagesRequest{Ids: batch, AddLabelIds: add, RemoveLabelIds: remove}
+		return srv.BatchModifyMessages(ctx, user, req)
+	})
+	if err != nil {
+		return fmt.Errorf("BatchModify: %w", err)
+	}
+	return nil
+}
+
+// BatchDelete permanently deletes the messages ids, skipping the trash, in
+// calls of at most MaxBatchModify messages. progress is as for BatchModify.
+// It needs the full https://mail.google.com/ scope.
+func BatchDelete(ctx context.Context, srv ModifyService, user string, ids []string, progress func(done int)) error {
+	err := inBatches(ids, MaxBatchModify, progress, func(batch []string) error {
+		return srv.BatchDeleteMessages(ctx, user, batch)
+	})
+	if err != nil {
+		return fmt.Errorf("BatchDelete: %w", err)
+	}
+	return nil
+}
+
+// TrashMessages moves the messages ids to the trash, where Gmail deletes
+// them after 30 days. The API has no batch call for this, so it makes one
+// request per message. progress is as for BatchModify. It needs the
+// gmail.modify scope.
+func TrashMessages(ctx context.Context, srv ModifyService, user string, ids []string, progress func(done int)) error {
+	err := inBatches(ids, 1, progress, func(batch []string) error {
+		_, err := srv.TrashMessage(ctx, user, batch[0])
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("TrashMessages: %w", err)
+	}
+	return nil
+}
+
+// UntrashMessages moves the messages ids out of the trash, one request per
+// message, like TrashMessages.
+func UntrashMessages(ctx context.Context, srv ModifyService, user string, ids []string, progress func(done int)) error {
+	err := inBatches(ids, 1, progress, func(batch []string) error {
+		_, err := srv.UntrashMessage(ctx, user, batch[0])
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("UntrashMessages: %w", err)
+	}
+	return nil
+}
+
+// inBatches calls fn with consecutive slices of at most size ids, reporting
+// the number done to progress after each. An error says how far it got.
+func inBatches(ids []string, size int, progress func(done int), fn func(batch []string) error) error {
+	for done := 0; done < len(ids); {
+		n := len(ids) - done
+		if n > size {
+			n = size
+		}
+		if err := fn(ids[done : done+n]); err != nil {
+			return fmt.Errorf("%d of %d messages done: %w", done, len(ids), err)
+		}
+		done += n
+		if progress != nil {
+			progress(done)
+		}
+	}
+	return nil
+}
+
+// LabelIDs returns the IDs of the labels called names, matched by ID or
+// name as in FindLabel. System labels such as INBOX, UNREAD or STARRED go
+// by their IDs.
+func LabelIDs(labels []*gmail.Label, names []string) ([]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		l, err := FindLabel(labels, name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, l.Id)
+	}
+	return ids, nil
+}
+
+// Snooze moves the thread threadID out of the inbox under the label labelID,
+// as Gmail's snooze does; the API cannot use Gmail's own snooze. Something
+// must call Unsnooze when the time comes; see package snooze. It needs the
+// gmail.modify scope.
+func Snooze(ctx context.Context, srv ModifyService, user, threadID, labelID string) error {
+	req := &gmail.ModifyThreadRequest{AddLabelIds: []string{labelID}, RemoveLabelIds: []string{"INBOX"}}
+	if _, err := srv.ModifyThread(ctx, user, threadID, req); err != nil {
+		return fmt.Errorf("Snooze: %w", err)
+	}
+	return nil
+}
+
+// Unsnooze moves the thread threadID back to the inbox, removes the label
+// labelID and marks the message messageID unread, so that it shows up as
+// new.
+func Unsnooze(ctx context.Context, srv ModifyService, user, threadID, messageID, labelID string) error {
+	req := &gmail.ModifyThreadRequest{AddLabelIds: []string{"INBOX"}, RemoveLabelIds: []string{labelID}}
+	if _, err := srv.ModifyThread(ctx, user, threadID, req); err != nil {
+		return fmt.Errorf("Unsnooze: %w", err)
+	}
+	mreq := &gmail.ModifyMessageRequest{AddLabelIds: []string{"UNREAD"}}
+	if _, err := srv.ModifyMessage(ctx, user, messageID, mreq); err != nil {
+		return fmt.Errorf("Unsnooze: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestBatchModifyChunks(t *testing.T) {
+	var sizes []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gmail.BatchModifyMessagesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if len(req.AddLabelIds) != 1 || req.AddLabelIds[0] != "Label_1" || len(req.RemoveLabelIds) != 1 || req.RemoveLabelIds[0] != "INBOX" {
+			t.Errorf("labels = %v, %v", req.AddLabelIds, req.RemoveLabelIds)
+		}
+		sizes = append(sizes, len(req.Ids))
+	}))
+	defer ts.Close()
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(ts.URL), option.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 2500)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("m%d", i)
+	}
+	var done []int
+	err = gmailclient.BatchModify(context.Background(), gmailclient.NewClient(srv), "me", ids, []string{"Label_1"}, []string{"INBOX"}, func(n int) {
+		done = append(done, n)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(sizes) != "[1000 1000 500]" {
+		t.Errorf("batch sizes = %v", sizes)
+	}
+	if fmt.Sprint(done) != "[1000 2000 2500]" {
+		t.Errorf("progress = %v", done)
+	}
+}
+
+func TestLabelIDs(t *testing.T) {
+	labels := []*gmail.Label{
+		{Id: "INBOX", Name: "INBOX", Type: "system"},
+		{Id: "Label_1", Name: "Receipts"},
+	}
+	ids, err := gmailclient.LabelIDs(labels, []string{"receipts", "INBOX"})
+	if err != nil || fmt.Sprint(ids) != "[Label_1 INBOX]" {
+		t.Errorf("LabelIDs = %v, %v", ids, err)
+	}
+	if _, err := gmailclient.LabelIDs(labels, []string{"Travel"}); err == nil {
+		t.Error("LabelIDs(missing) succeeded")
+	}
+}
+
+func TestTrashMessagesStopsAtError(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if len(paths) == 2 {
+			http.Error(w, `{"error": {"code": 404, "message": "Not Found"}}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(ts.URL), option.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gmailclient.TrashMessages(context.Background(), gmailclient.NewClient(srv), "me", []string{"m1", "m2", "m3"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 messages done") {
+		t.Errorf("TrashMessages = %v, want an error after one message", err)
+	}
+	if len(paths) != 2 || !strings.HasSuffix(paths[0], "/messages/m1/trash") {
+		t.Errorf("requests = %v", paths)
+	}
+}
+
+func TestSnooze(t *testing.T) {
+	ctx := context.Background()
+	srv := gmailclienttest.New()
+	srv.AddMessage(&gmail.Message{Id: "m1", ThreadId: "t1", LabelIds: []string{"INBOX"}})
+	srv.AddMessage(&gmail.Message{Id: "m2", ThreadId: "t1", LabelIds: []string{"INBOX", "UNREAD"}})
+	srv.AddMessage(&gmail.Message{Id: "m3", ThreadId: "t2", LabelIds: []string{"INBOX"}})
+	labels := func() string {
+		var s []string
+		for _, m := range srv.Messages {
+			s = append(s, fmt.Sprintf("%s%v", m.Id, m.LabelIds))
+		}
+		return strings.Join(s, " ")
+	}
+
+	if err := gmailclient.Snooze(ctx, srv, "me", "t1", "Label_9"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := labels(), "m1[Label_9] m2[UNREAD Label_9] m3[INBOX]"; got != want {
+		t.Errorf("after Snooze: %s, want %s", got, want)
+	}
+	if err := gmailclient.Unsnooze(ctx, srv, "me", "t1", "m1", "Label_9"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := labels(), "m1[INBOX UNREAD] m2[UNREAD INBOX] m3[INBOX]"; got != want {
+		t.Errorf("after Unsnooze: %s, want %s", got, want)
+	}
+	if err := gmailclient.Snooze(ctx, srv, "me", "t9", "Label_9"); err == nil {
+		t.Error("Snooze of a missing thread succeeded")
+	}
+}
+
+func TestTrashAndDeleteMessages(t *testing.T) {
+	ctx := context.Background()
+	srv := gmailclienttest.New()
+	for _, id := range []string{"m1", "m2", "m3"} {
+		srv.AddMessage(&gmail.Message{Id: id, ThreadId: id, LabelIds: []string{"INBOX"}})
+	}
+	if err := gmailclient.TrashMessages(ctx, srv, "me", []string{"m1", "m2"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := gmailclient.UntrashMessages(ctx, srv, "me", []string{"m2"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(srv.Messages[0].LabelIds, srv.Messages[1].LabelIds); got != "[INBOX TRASH] [INBOX]" {
+		t.Errorf("labels after trash and untrash = %s", got)
+	}
+	if err := gmailclient.BatchDelete(ctx, srv, "me", []string{"m1", "m3"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.Messages) != 1 || srv.Messages[0].Id != "m2" {
+		t.Errorf("messages after BatchDelete = %v", srv.Messages)
+	}
+}
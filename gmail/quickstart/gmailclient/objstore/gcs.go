@@ -0,0 +1,73 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
+)
+
+// GCSScope is the scope a GCS bucket needs.
+const GCSScope = gcs.DevstorageReadWriteScope
+
+// GCS is a Google Cloud Storage bucket.
+type GCS struct {
+	srv    *gcs.Service
+	bucket string
+}
+
+// OpenGCS returns the Cloud Storage bucket with the given name, reached
+// through client.
+func OpenGCS(ctx context.Context, client *http.Client, bucket string) (*GCS, error) {
+	srv, err := gcs.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("OpenGCS create storage client: %w", err)
+	}
+	return &GCS{srv: srv, bucket: bucket}, nil
+}
+
+// Put uploads r with a resumable upload in chunks of PartSize, each retried
+// by the client library.
+func (b *GCS) Put(ctx context.Context, key, contentType string, r io.Reader) error {
+	_, err := b.srv.Objects.Insert(b.bucket, &gcs.Object{Name: key, ContentType: contentType}).
+		Media(r, googleapi.ChunkSize(PartSize), googleapi.ContentType(contentType)).
+		Fields("name").Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("GCS.Put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *GCS) Size(ctx context.Context, key string) (int64, bool, error) {
+	obj, err := b.srv.Objects.Get(b.bucket, key).Fields("size").Context(ctx).Do()
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("GCS.Size %s: %w", key, err)
+	}
+	return int64(obj.Size), true, nil
+}
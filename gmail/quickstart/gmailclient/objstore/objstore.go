@@ -0,0 +1,87 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package objstore uploads files to cloud object storage: Google Cloud
+// Storage (gs:// URLs) and Amazon S3 or S3-compatible stores (s3:// URLs).
+//
+//	b, prefix, err := objstore.Open(ctx, "s3://backups/mail", nil)
+//	...
+//	err = b.Put(ctx, prefix+"17a1.eml", "message/rfc822", r)
+//
+// Uploads stream from the reader in parts, so large files are never held in
+// memory whole, and each part is retried on its own.
+package objstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+// A Bucket stores objects by key.
+type Bucket interface {
+	// Put uploads the content read from r as the object key, replacing
+	// any existing object.
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+
+	// Size returns the size of the object key, and false if it does not
+	// exist.
+	Size(ctx context.Context, key string) (int64, bool, error)
+}
+
+// ParseURL splits a URL such as s3://bucket/some/prefix into its scheme,
+// bucket and key prefix. A non-empty prefix always ends in a slash.
+func ParseURL(s string) (scheme, bucket, prefix string, err error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", "", fmt.Errorf("objstore: %w", err)
+	}
+	if (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return "", "", "", fmt.Errorf("objstore: invalid bucket URL %q (want s3://bucket/prefix or gs://bucket/prefix)", s)
+	}
+	prefix = strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return u.Scheme, u.Host, prefix, nil
+}
+
+// Open returns the bucket of a gs:// or s3:// URL and the key prefix in it.
+// gs:// buckets are reached through client, which must be authorized for
+// GCSScope. s3:// buckets use the configuration from S3ConfigFromEnv, with
+// requests retried as by gmailclient.WithRetry, and ignore client.
+func Open(ctx context.Context, rawurl string, client *http.Client) (Bucket, string, error) {
+	scheme, bucket, prefix, err := ParseURL(rawurl)
+	if err != nil {
+		return nil, "", err
+	}
+	if scheme == "gs" {
+		b, err := OpenGCS(ctx, client, bucket)
+		return b, prefix, err
+	}
+	cfg, err := S3ConfigFromEnv()
+	if err != nil {
+		return nil, "", err
+	}
+	cfg.Client = gmailclient.WithRetry(&http.Client{}, gmailclient.DefaultRetryPolicy)
+	return NewS3(bucket, cfg), prefix, nil
+}
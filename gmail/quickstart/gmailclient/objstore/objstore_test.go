@@ -0,0 +1,133 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objstore_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/objstore"
+)
+
+// fakeS3 is a minimal S3 server for one bucket, supporting plain and
+// multipart uploads.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	parts   map[string][]byte // by upload ID and part number
+	calls   []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	q := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == "HEAD":
+		f.calls = append(f.calls, "head")
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(obj)))
+	case r.Method == "POST" && q["uploads"] != nil:
+		f.calls = append(f.calls, "create")
+		fmt.Fprint(w, "<InitiateMultipartUploadResult><UploadId>up1</UploadId></InitiateMultipartUploadResult>")
+	case r.Method == "PUT" && q.Get("uploadId") != "":
+		f.calls = append(f.calls, "part "+q.Get("partNumber"))
+		f.parts[q.Get("uploadId")+"/"+q.Get("partNumber")] = body
+		w.Header().Set("ETag", `"etag`+q.Get("partNumber")+`"`)
+	case r.Method == "POST" && q.Get("uploadId") != "":
+		f.calls = append(f.calls, "complete")
+		var keys []string
+		for k := range f.parts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var obj []byte
+		for _, k := range keys {
+			obj = append(obj, f.parts[k]...)
+		}
+		f.objects[key] = obj
+		fmt.Fprint(w, "<CompleteMultipartUploadResult/>")
+	case r.Method == "PUT":
+		f.calls = append(f.calls, "put")
+		f.objects[key] = body
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestS3(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, parts: map[string][]byte{}}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	b := objstore.NewS3("bucket", objstore.S3Config{AccessKeyID: "AKID", SecretAccessKey: "secret", Region: "eu-west-1", Endpoint: ts.URL})
+	b.PartSize = 4
+	ctx := context.Background()
+
+	if err := b.Put(ctx, "mail/small.eml", "message/rfc822", strings.NewReader("abc")); err != nil {
+		t.Fatal(err)
+	}
+	big := "0123456789"
+	if err := b.Put(ctx, "mail/big file.bin", "application/octet-stream", strings.NewReader(big)); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(fake.objects["mail/big file.bin"]); got != big {
+		t.Errorf("multipart object = %q, want %q", got, big)
+	}
+	want := "put create part 1 part 2 part 3 complete"
+	if got := strings.Join(fake.calls, " "); got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+
+	if size, ok, err := b.Size(ctx, "mail/small.eml"); err != nil || !ok || size != 3 {
+		t.Errorf("Size(small.eml) = %d, %v, %v; want 3, true, nil", size, ok, err)
+	}
+	if _, ok, err := b.Size(ctx, "missing"); err != nil || ok {
+		t.Errorf("Size(missing) = %v, %v; want false, nil", ok, err)
+	}
+	if !bytes.Equal(fake.objects["mail/small.eml"], []byte("abc")) {
+		t.Errorf("small object = %q", fake.objects["mail/small.eml"])
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	scheme, bucket, prefix, err := objstore.ParseURL("gs://backups/mail/2021")
+	if err != nil || scheme != "gs" || bucket != "backups" || prefix != "mail/2021/" {
+		t.Errorf("ParseURL = %q, %q, %q, %v", scheme, bucket, prefix, err)
+	}
+	if _, _, _, err := objstore.ParseURL("ftp://host/x"); err == nil {
+		t.Error("ParseURL accepted an ftp:// URL")
+	}
+}
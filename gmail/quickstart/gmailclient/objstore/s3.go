@@ -0,0 +1,364 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PartSize is the size of the parts uploads are split into. S3 requires
+// every part but the last to be at least 5 MiB.
+const PartSize = 8 << 20
+
+// S3Config holds the credentials and location of S3 buckets.
+type S3Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // for temporary credentials
+	Region          string
+
+	// Endpoint is the URL of an S3-compatible store such as MinIO, whose
+	// buckets are addressed by path. Empty means AWS.
+	Endpoint string
+
+	// Client issues the requests. It must not add credentials of its own.
+	// Nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// S3ConfigFromEnv reads an S3Config from the variables the AWS tools use:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION or
+// AWS_DEFAULT_REGION (us-east-1 if neither is set) and AWS_ENDPOINT_URL.
+func S3ConfigFromEnv() (S3Config, error) {
+	cfg := S3Config{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          os.Getenv("AWS_REGION"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return cfg, fmt.Errorf("objstore: s3:// needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return cfg, nil
+}
+
+// S3 is an Amazon S3 bucket, or one of an S3-compatible store. Requests are
+// signed with AWS Signature Version 4.
+type S3 struct {
+	bucket string
+	cfg    S3Config
+
+	// PartSize overrides the package's PartSize, for tests.
+	PartSize int
+
+	now func() time.Time
+}
+
+// NewS3 returns the bucket with the given name.
+func NewS3(bucket string, cfg S3Config) *S3 {
+	return &S3{bucket: bucket, cfg: cfg, PartSize: PartSize, now: time.Now}
+}
+
+// Put uploads r in one request if it fits in a single part, and otherwise
+// as a multipart upload, which is aborted if a part fails.
+func (b *S3) Put(ctx context.Context, key, contentType string, r io.Reader) error {
+	buf := make([]byte, b.PartSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		h := http.Header{"Content-Type": {contentType}}
+		_, err := b.do(ctx, "PUT", key, nil, h, buf[:n])
+		if err != nil {
+			return fmt.Errorf("S3.Put %s: %w", key, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("S3.Put %s: %w", key, err)
+	}
+
+	uploadID, err := b.createMultipart(ctx, key, contentType)
+	if err != nil {
+		return fmt.Errorf("S3.Put %s: %w", key, err)
+	}
+	var parts []completedPart
+	for num := 1; n > 0; num++ {
+		q := url.Values{"partNumber": {strconv.Itoa(num)}, "uploadId": {uploadID}}
+		resp, err := b.do(ctx, "PUT", key, q, nil, buf[:n])
+		if err != nil {
+			b.do(context.Background(), "DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil)
+			return fmt.Errorf("S3.Put %s part %d: %w", key, num, err)
+		}
+		parts = append(parts, completedPart{PartNumber: num, ETag: resp.Header.Get("ETag")})
+
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			b.do(context.Background(), "DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil)
+			return fmt.Errorf("S3.Put %s: %w", key, err)
+		}
+	}
+	if err := b.completeMultipart(ctx, key, uploadID, parts); err != nil {
+		b.do(context.Background(), "DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil)
+		return fmt.Errorf("S3.Put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *S3) Size(ctx context.Context, key string) (int64, bool, error) {
+	resp, err := b.do(ctx, "HEAD", key, nil, nil, nil)
+	if e, ok := err.(*S3Error); ok && e.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("S3.Size %s: %w", key, err)
+	}
+	return resp.ContentLength, true, nil
+}
+
+func (b *S3) createMultipart(ctx context.Context, key, contentType string) (string, error) {
+	resp, err := b.do(ctx, "POST", key, url.Values{"uploads": {""}}, http.Header{"Content-Type": {contentType}}, nil)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp.body, &result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("create multipart upload: unexpected response %q", resp.body)
+	}
+	return result.UploadID, nil
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (b *S3) completeMultipart(ctx context.Context, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(ctx, "POST", key, url.Values{"uploadId": {uploadID}}, nil, body)
+	if err != nil {
+		return err
+	}
+	// S3 can report a failed completion in the body of a 200 response.
+	if bytes.Contains(resp.body, []byte("<Error>")) {
+		return parseS3Error(resp.StatusCode, resp.body)
+	}
+	return nil
+}
+
+// S3Error is an error response from S3.
+type S3Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+func parseS3Error(status int, body []byte) error {
+	e := &S3Error{StatusCode: status}
+	xml.Unmarshal(body, &struct {
+		Code    *string `xml:"Code"`
+		Message *string `xml:"Message"`
+	}{&e.Code, &e.Message})
+	return e
+}
+
+// s3Response is a successful response with its body read.
+type s3Response struct {
+	*http.Response
+	body []byte
+}
+
+// do sends a signed request for key in the bucket and returns the response,
+// or an *S3Error for a non-2xx status.
+func (b *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*s3Response, error) {
+	u, err := b.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.cfg.SessionToken)
+	}
+	signV4(req, payloadHash, b.cfg.AccessKeyID, b.cfg.SecretAccessKey, b.cfg.Region, "s3", b.now())
+
+	client := b.cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, parseS3Error(resp.StatusCode, respBody)
+	}
+	return &s3Response{resp, respBody}, nil
+}
+
+// objectURL returns the URL of key: virtual-hosted style on AWS, path style
+// on a custom endpoint.
+func (b *S3) objectURL(key string) (*url.URL, error) {
+	if b.cfg.Endpoint == "" {
+		return url.Parse("https://" + b.bucket + ".s3." + b.cfg.Region + ".amazonaws.com/" + uriEncode(key, false))
+	}
+	return url.Parse(strings.TrimSuffix(b.cfg.Endpoint, "/") + "/" + uriEncode(b.bucket, false) + "/" + uriEncode(key, false))
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req,
+// signing the Host header, Content-Type and all X-Amz-* headers.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonURI := req.URL.EscapedPath()
+	if canonURI == "" {
+		canonURI = "/"
+	}
+	canonRequest := strings.Join([]string{
+		req.Method,
+		canonURI,
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	crHash := sha256.Sum256([]byte(canonRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes q with its keys sorted and the strict escaping
+// Signature Version 4 requires.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters
+// A-Z, a-z, 0-9, '-', '.', '_' and '~', and '/' unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
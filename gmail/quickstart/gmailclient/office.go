@@ -0,0 +1,187 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// docxText returns the text of the main document of a .docx file, one
+// paragraph per line.
+func docxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = walkZipXML(zr, "word/document.xml", func(d *xml.Decoder, t xml.Token) error {
+		switch t := t.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				var s string
+				if err := d.DecodeElement(&s, &t); err != nil {
+					return err
+				}
+				b.WriteString(s)
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			if t.Name.Local == "p" {
+				b.WriteByte('\n')
+			}
+		}
+		return nil
+	})
+	return b.String(), err
+}
+
+// xlsxText returns the cells of every worksheet of a .xlsx file, tab
+// separated, with a blank line between sheets.
+func xlsxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	var shared []string
+	var si strings.Builder
+	err = walkZipXML(zr, "xl/sharedStrings.xml", func(d *xml.Decoder, t xml.Token) error {
+		switch t := t.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "t" {
+				var s string
+				if err := d.DecodeElement(&s, &t); err != nil {
+					return err
+				}
+				si.WriteString(s)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "si" {
+				shared = append(shared, si.String())
+				si.Reset()
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errZipEntryMissing {
+		return "", err
+	}
+
+	var sheets []string
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "xl/worksheets/sheet") && strings.HasSuffix(f.Name, ".xml") {
+			sheets = append(sheets, f.Name)
+		}
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheetNumber(sheets[i]) < sheetNumber(sheets[j]) })
+
+	var b strings.Builder
+	for i, name := range sheets {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		var cellType string
+		var row []string
+		err := walkZipXML(zr, name, func(d *xml.Decoder, t xml.Token) error {
+			switch t := t.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "c":
+					cellType = ""
+					for _, a := range t.Attr {
+						if a.Name.Local == "t" {
+							cellType = a.Value
+						}
+					}
+				case "v", "t":
+					var s string
+					if err := d.DecodeElement(&s, &t); err != nil {
+						return err
+					}
+					if cellType == "s" {
+						if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < len(shared) {
+							s = shared[n]
+						}
+					}
+					row = append(row, s)
+				}
+			case xml.EndElement:
+				if t.Name.Local == "row" {
+					b.WriteString(strings.Join(row, "\t"))
+					b.WriteByte('\n')
+					row = row[:0]
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// sheetNumber returns the number in a worksheet name like
+// xl/worksheets/sheet12.xml.
+func sheetNumber(name string) int {
+	n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "xl/worksheets/sheet"), ".xml"))
+	return n
+}
+
+// errZipEntryMissing is returned by walkZipXML for a missing entry.
+var errZipEntryMissing = errors.New("zip entry missing")
+
+// walkZipXML calls fn for every token of the XML file name in zr.
+func walkZipXML(zr *zip.Reader, name string, fn func(d *xml.Decoder, t xml.Token) error) error {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		d := xml.NewDecoder(rc)
+		for {
+			t, err := d.Token()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := fn(d, t); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return errZipEntryMissing
+}
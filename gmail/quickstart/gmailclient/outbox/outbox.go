@@ -0,0 +1,260 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package outbox queues messages to be sent later in a local SQLite
+// database, and dispatches them once they are due.
+//
+//	q, err := outbox.Open("outbox.db")
+//	...
+//	id, err := q.Add(ctx, "work", sendAt, raw, "")
+//	...
+//	err = q.Run(ctx, time.Minute, func(ctx context.Context, e *outbox.Entry) (string, error) {
+//		m, err := gmailclient.SendMessage(ctx, srv, "me", e.Raw, e.ThreadID)
+//		...
+//	}, nil)
+//
+// A message whose send fails is retried with exponential backoff until
+// MaxAttempts sends have failed; it is then marked Failed and left in the
+// queue for inspection.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS outbox (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	account      TEXT NOT NULL,
+	send_at      INTEGER NOT NULL,
+	raw          BLOB NOT NULL,
+	thread_id    TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	next_attempt INTEGER NOT NULL,
+	last_error   TEXT NOT NULL DEFAULT '',
+	message_id   TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS outbox_due ON outbox (status, next_attempt);
+`
+
+// Status values of an Entry.
+const (
+	Pending = "pending"
+	Sent    = "sent"
+	Failed  = "failed"
+)
+
+// An Entry is one queued message.
+type Entry struct {
+	ID       int64
+	Account  string // the account to send from
+	SendAt   time.Time
+	Raw      []byte // the RFC 2822 message
+	ThreadID string // the thread to send into, if any
+
+	Status      string
+	Attempts    int       // failed sends so far
+	NextAttempt time.Time // when a pending entry is next tried
+	LastError   string
+	MessageID   string // the Gmail ID of the sent message
+}
+
+// SendFunc sends e and returns the Gmail ID of the sent message.
+type SendFunc func(ctx context.Context, e *Entry) (string, error)
+
+// Queue is an outbox in a SQLite database. It is safe for concurrent use,
+// also by several processes.
+type Queue struct {
+	db *sql.DB
+
+	// MaxAttempts is the number of failed sends after which an entry is
+	// marked Failed.
+	MaxAttempts int
+
+	// MinBackoff is the delay after the first failed send. It doubles with
+	// every further failure up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Open opens the queue database at path, creating it if necessary.
+func Open(path string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("outbox: create directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("outbox: open %s: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("outbox: create schema in %s: %w", path, err)
+	}
+	return &Queue{db: db, MaxAttempts: 5, MinBackoff: time.Minute, MaxBackoff: time.Hour}, nil
+}
+
+// Close closes the database.
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Add queues raw to be sent from account at sendAt, into the thread threadID
+// if it is not empty, and returns the ID of the new entry.
+func (q *Queue) Add(ctx context.Context, account string, sendAt time.Time, raw []byte, threadID string) (int64, error) {
+	r, err := q.db.ExecContext(ctx, `INSERT INTO outbox
+		(account, send_at, raw, thread_id, status, next_attempt)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		account, sendAt.Unix(), raw, threadID, Pending, sendAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("outbox: add: %w", err)
+	}
+	return r.LastInsertId()
+}
+
+// List returns the entries with the given status, or all of them if status
+// is empty, in the order they are due.
+func (q *Queue) List(ctx context.Context, status string) ([]*Entry, error) {
+	query := `SELECT id, account, send_at, raw, thread_id, status, attempts, next_attempt, last_error, message_id
+		FROM outbox`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	return q.query(ctx, query+` ORDER BY send_at, id`, args...)
+}
+
+// Due returns the pending entries due at now.
+func (q *Queue) Due(ctx context.Context, now time.Time) ([]*Entry, error) {
+	return q.query(ctx, `SELECT id, account, send_at, raw, thread_id, status, attempts, next_attempt, last_error, message_id
+		FROM outbox WHERE status = ? AND next_attempt <= ? ORDER BY next_attempt, id`, Pending, now.Unix())
+}
+
+func (q *Queue) query(ctx context.Context, query string, args ...interface{}) ([]*Entry, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: list: %w", err)
+	}
+	defer rows.Close()
+	var entries []*Entry
+	for rows.Next() {
+		e := &Entry{}
+		var sendAt, next int64
+		if err := rows.Scan(&e.ID, &e.Account, &sendAt, &e.Raw, &e.ThreadID, &e.Status, &e.Attempts, &next, &e.LastError, &e.MessageID); err != nil {
+			return nil, fmt.Errorf("outbox: list: %w", err)
+		}
+		e.SendAt = time.Unix(sendAt, 0)
+		e.NextAttempt = time.Unix(next, 0)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Cancel removes the pending entry id. Sent and failed entries cannot be
+// cancelled; use Remove.
+func (q *Queue) Cancel(ctx context.Context, id int64) error {
+	r, err := q.db.ExecContext(ctx, `DELETE FROM outbox WHERE id = ? AND status = ?`, id, Pending)
+	if err != nil {
+		return fmt.Errorf("outbox: cancel %d: %w", id, err)
+	}
+	if n, _ := r.RowsAffected(); n == 0 {
+		return fmt.Errorf("outbox: no pending message %d", id)
+	}
+	return nil
+}
+
+// Remove removes the entries with the given status, such as Sent, and
+// returns how many were removed.
+func (q *Queue) Remove(ctx context.Context, status string) (int64, error) {
+	r, err := q.db.ExecContext(ctx, `DELETE FROM outbox WHERE status = ?`, status)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: remove: %w", err)
+	}
+	return r.RowsAffected()
+}
+
+// Dispatch sends the entries due at now with send and records the outcome.
+// It returns the entries it tried, updated; a failed send is not an error
+// of Dispatch, but shows in the entry's LastError.
+func (q *Queue) Dispatch(ctx context.Context, now time.Time, send SendFunc) ([]*Entry, error) {
+	due, err := q.Due(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range due {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		id, err := send(ctx, e)
+		if err == nil {
+			e.Status, e.MessageID, e.LastError = Sent, id, ""
+		} else {
+			e.Attempts++
+			e.LastError = err.Error()
+			e.NextAttempt = now.Add(q.backoff(e.Attempts))
+			if e.Attempts >= q.MaxAttempts {
+				e.Status = Failed
+			}
+		}
+		_, err = q.db.ExecContext(ctx, `UPDATE outbox
+			SET status = ?, attempts = ?, next_attempt = ?, last_error = ?, message_id = ?
+			WHERE id = ?`,
+			e.Status, e.Attempts, e.NextAttempt.Unix(), e.LastError, e.MessageID, e.ID)
+		if err != nil {
+			return nil, fmt.Errorf("outbox: update %d: %w", e.ID, err)
+		}
+	}
+	return due, nil
+}
+
+// backoff returns the delay after the given number of failed sends.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.MinBackoff << uint(attempts-1)
+	if d <= 0 || d > q.MaxBackoff {
+		d = q.MaxBackoff
+	}
+	return d
+}
+
+// Run calls Dispatch every interval until ctx is done, passing each batch
+// of tried entries to report if it is not nil.
+func (q *Queue) Run(ctx context.Context, interval time.Duration, send SendFunc, report func([]*Entry)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		tried, err := q.Dispatch(ctx, time.Now(), send)
+		if err != nil {
+			return err
+		}
+		if report != nil && len(tried) > 0 {
+			report(tried)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
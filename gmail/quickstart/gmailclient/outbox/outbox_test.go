@@ -0,0 +1,82 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/outbox"
+)
+
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	q, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	q.MaxAttempts = 2
+
+	now := time.Unix(1719824400, 0)
+	early, _ := q.Add(ctx, "work", now.Add(-time.Minute), []byte("Subject: early\r\n\r\n"), "")
+	late, _ := q.Add(ctx, "work", now.Add(time.Hour), []byte("Subject: late\r\n\r\n"), "")
+	flaky, _ := q.Add(ctx, "home", now, []byte("Subject: flaky\r\n\r\n"), "t1")
+
+	send := func(ctx context.Context, e *outbox.Entry) (string, error) {
+		if e.Account == "home" {
+			return "", errors.New("backend error")
+		}
+		return "m" + string(e.Raw[9:14]), nil
+	}
+	tried, err := q.Dispatch(ctx, now, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tried) != 2 || tried[0].ID != early || tried[0].Status != outbox.Sent || tried[0].MessageID != "mearly" {
+		t.Fatalf("first dispatch = %+v", tried)
+	}
+	if f := tried[1]; f.ID != flaky || f.Status != outbox.Pending || f.Attempts != 1 || f.LastError != "backend error" {
+		t.Fatalf("flaky entry = %+v", f)
+	}
+
+	// The failed entry waits for its backoff; the late one is not due yet.
+	if tried, _ := q.Dispatch(ctx, now.Add(time.Second), send); len(tried) != 0 {
+		t.Errorf("dispatch during backoff tried %d entries", len(tried))
+	}
+	if _, err := q.Dispatch(ctx, now.Add(2*time.Minute), send); err != nil {
+		t.Fatal(err)
+	}
+	failed, _ := q.List(ctx, outbox.Failed)
+	if len(failed) != 1 || failed[0].ID != flaky || failed[0].Attempts != 2 {
+		t.Errorf("failed = %+v", failed)
+	}
+
+	if err := q.Cancel(ctx, late); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Cancel(ctx, early); err == nil {
+		t.Errorf("cancelled a sent message")
+	}
+	if pending, _ := q.List(ctx, outbox.Pending); len(pending) != 0 {
+		t.Errorf("pending = %+v", pending)
+	}
+}
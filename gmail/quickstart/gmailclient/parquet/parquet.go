@@ -0,0 +1,124 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package parquet writes parsed Gmail messages to Parquet files, which
+// DuckDB, Spark and most analytics tools query directly:
+//
+//	duckdb -c "SELECT sender, count(*) FROM 'export/*.parquet' GROUP BY 1 ORDER BY 2 DESC"
+//
+// Each message becomes one row of metadata, optionally with its bodies.
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Row is the schema of a row. Addresses are formatted as in
+// gmailclient.FormatAddressList; SenderAddress holds just the address of the
+// first sender, for grouping.
+type Row struct {
+	ID             string   `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ThreadID       string   `parquet:"name=thread_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Date           int64    `parquet:"name=date, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Sender         string   `parquet:"name=sender, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SenderAddress  string   `parquet:"name=sender_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	To             string   `parquet:"name=to, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Cc             string   `parquet:"name=cc, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Subject        string   `parquet:"name=subject, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Size           int64    `parquet:"name=size, type=INT64"`
+	Labels         []string `parquet:"name=labels, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	HasAttachments bool     `parquet:"name=has_attachments, type=BOOLEAN"`
+	Attachments    []string `parquet:"name=attachments, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	MessageID      string   `parquet:"name=message_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BodyPlain      *string  `parquet:"name=body_plain, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	BodyHtml       *string  `parquet:"name=body_html, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	AttachmentText *string  `parquet:"name=attachment_text, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+}
+
+// NewRow returns the row for m. The bodies are only set if bodies is true;
+// AttachmentText is set if text was extracted from the attachments.
+func NewRow(m *gmailclient.Message, bodies bool) *Row {
+	r := &Row{
+		ID:             m.Id,
+		ThreadID:       m.ThreadId,
+		Sender:         gmailclient.FormatAddressList(m.From),
+		To:             gmailclient.FormatAddressList(m.To),
+		Cc:             gmailclient.FormatAddressList(m.Cc),
+		Subject:        m.Subject,
+		Size:           m.Size,
+		Labels:         m.LabelIds,
+		HasAttachments: len(m.Attachments) > 0,
+		MessageID:      m.Headers.Get("Message-ID"),
+	}
+	if !m.Date.IsZero() {
+		r.Date = m.Date.UnixNano() / 1e6
+	}
+	if len(m.From) > 0 {
+		r.SenderAddress = strings.ToLower(m.From[0].Address)
+	}
+	for _, a := range m.Attachments {
+		r.Attachments = append(r.Attachments, a.Filename)
+	}
+	if bodies {
+		r.BodyPlain, r.BodyHtml = &m.BodyPlain, &m.BodyHtml
+	}
+	if text := m.AttachmentText(); text != "" {
+		r.AttachmentText = &text
+	}
+	return r
+}
+
+// Writer writes rows to a Parquet file. Rows are buffered into row groups,
+// so the file is only complete after Close.
+type Writer struct {
+	pw     *writer.ParquetWriter
+	bodies bool
+}
+
+// NewWriter returns a Writer to w, compressed with Snappy. With bodies the
+// plain-text and HTML bodies are included.
+func NewWriter(w io.Writer, bodies bool) (*Writer, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(Row), 4)
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %w", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	return &Writer{pw: pw, bodies: bodies}, nil
+}
+
+// Write appends m.
+func (w *Writer) Write(m *gmailclient.Message) error {
+	if err := w.pw.Write(NewRow(m, w.bodies)); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return nil
+}
+
+// Close flushes the buffered rows and writes the file footer. It does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.pw.WriteStop(); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parquet_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/parquet"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriter(t *testing.T) {
+	m := &gmailclient.Message{
+		Id:          "17a1",
+		ThreadId:    "17a0",
+		From:        gmailclient.ParseAddressList("Vim Tricks <Hi@vimtricks.com>"),
+		Subject:     "Weekly tips",
+		Date:        time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+		Size:        2048,
+		LabelIds:    []string{"INBOX", "UNREAD"},
+		Attachments: []*gmailclient.Attachment{{Filename: "tips.pdf"}},
+		BodyPlain:   "Hello",
+	}
+	var buf bytes.Buffer
+	w, err := parquet.NewWriter(&buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := buffer.NewBufferFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(f, new(parquet.Row), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if n := pr.GetNumRows(); n != 1 {
+		t.Fatalf("got %d rows, want 1", n)
+	}
+	rows := make([]parquet.Row, 1)
+	if err := pr.Read(&rows); err != nil {
+		t.Fatal(err)
+	}
+	r := rows[0]
+	if r.ID != "17a1" || r.SenderAddress != "hi@vimtricks.com" || r.Size != 2048 {
+		t.Errorf("got row %+v", r)
+	}
+	if r.Date != m.Date.UnixNano()/1e6 {
+		t.Errorf("Date = %d, want %d", r.Date, m.Date.UnixNano()/1e6)
+	}
+	if len(r.Labels) != 2 || r.Labels[1] != "UNREAD" {
+		t.Errorf("Labels = %q", r.Labels)
+	}
+	if !r.HasAttachments || len(r.Attachments) != 1 {
+		t.Errorf("Attachments = %q", r.Attachments)
+	}
+	if r.BodyPlain == nil || *r.BodyPlain != "Hello" {
+		t.Errorf("BodyPlain = %v, want Hello", r.BodyPlain)
+	}
+}
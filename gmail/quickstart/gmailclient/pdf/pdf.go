@@ -0,0 +1,125 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package pdf renders parsed Gmail messages as PDF documents, for workflows
+// that need mail in a fixed, printable form.
+//
+// Each document starts with a header block (From, To, Cc, Date, Subject and
+// attachments) followed by the body. HTML bodies are reduced to their text
+// with gmailclient.HTMLToText; layout, images and colors are not kept.
+//
+//	f, err := os.Create("message.pdf")
+//	...
+//	err = pdf.Render(f, msg, pdf.Options{})
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+// Options control the rendering.
+type Options struct {
+	// Prefer selects the body as for gmailclient.Message.Body.
+	Prefer string
+
+	// FontFile is a TrueType font with the glyphs the messages need. The
+	// built-in Helvetica only covers Western European scripts; other
+	// characters are printed as dots.
+	FontFile string
+
+	// PageSize is "A4" (the default) or "Letter".
+	PageSize string
+}
+
+// Render writes m to w as a PDF document.
+func Render(w io.Writer, m *gmailclient.Message, o Options) error {
+	size := o.PageSize
+	if size == "" {
+		size = "A4"
+	}
+	doc := gofpdf.New("P", "mm", size, "")
+	doc.SetTitle(m.Subject, true)
+	doc.SetCreator("gmailctl", true)
+	doc.SetAutoPageBreak(true, 15)
+
+	family := "Helvetica"
+	tr := func(s string) string { return s }
+	if o.FontFile != "" {
+		family = "Body"
+		doc.AddUTF8Font(family, "", o.FontFile)
+		doc.AddUTF8Font(family, "B", o.FontFile)
+	} else {
+		tr = doc.UnicodeTranslatorFromDescriptor("")
+	}
+	doc.SetFooterFunc(func() {
+		doc.SetY(-12)
+		doc.SetFont(family, "", 8)
+		doc.CellFormat(0, 5, fmt.Sprintf("%d / {nb}", doc.PageNo()), "", 0, "C", false, 0, "")
+	})
+	doc.AliasNbPages("")
+	doc.AddPage()
+
+	header := func(name, value string) {
+		if value == "" {
+			return
+		}
+		doc.SetFont(family, "B", 10)
+		doc.CellFormat(25, 5, tr(name+":"), "", 0, "", false, 0, "")
+		doc.SetFont(family, "", 10)
+		doc.MultiCell(0, 5, tr(value), "", "", false)
+	}
+	header("From", gmailclient.FormatAddressList(m.From))
+	header("To", gmailclient.FormatAddressList(m.To))
+	header("Cc", gmailclient.FormatAddressList(m.Cc))
+	if !m.Date.IsZero() {
+		header("Date", m.Date.Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	}
+	header("Subject", m.Subject)
+	var names []string
+	for _, a := range m.Attachments {
+		names = append(names, a.Filename)
+	}
+	header("Attachments", strings.Join(names, ", "))
+
+	doc.Ln(2)
+	x, y := doc.GetXY()
+	pw, _ := doc.GetPageSize()
+	_, _, right, _ := doc.GetMargins()
+	doc.Line(x, y, pw-right, y)
+	doc.Ln(4)
+
+	body, mimeType := m.Body(o.Prefer)
+	if mimeType == "text/html" {
+		text, err := gmailclient.HTMLToText(body)
+		if err != nil {
+			return err
+		}
+		body = text
+	}
+	doc.SetFont(family, "", 10)
+	doc.MultiCell(0, 5, tr(strings.Replace(body, "\t", "    ", -1)), "", "", false)
+
+	if err := doc.Output(w); err != nil {
+		return fmt.Errorf("pdf: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pdf_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/pdf"
+)
+
+func TestRender(t *testing.T) {
+	m := &gmailclient.Message{
+		From:     gmailclient.ParseAddressList("Vim Tricks <hi@vimtricks.com>"),
+		To:       gmailclient.ParseAddressList("me@example.com"),
+		Subject:  "Weekly tips – café edition",
+		Date:     time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+		BodyHtml: "<p>Hello <b>there</b></p>",
+	}
+	var buf bytes.Buffer
+	if err := pdf.Render(&buf, m, pdf.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("output does not start with a PDF header: %q", buf.Bytes()[:16])
+	}
+}
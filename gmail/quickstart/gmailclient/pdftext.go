@@ -0,0 +1,800 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// This file implements just enough of PDF to pull the text out of the
+// content streams of a document: objects, object streams, the Flate and
+// ASCII85 filters, the page tree and ToUnicode maps. Layout is approximated
+// from the text positioning operators.
+
+// PDF values, as returned by pdfLexer.value. Numbers are float64, arrays
+// []interface{}, booleans bool and null nil.
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfDict    map[pdfName]interface{}
+	pdfRef     struct{ num, gen int }
+)
+
+// A pdfObject is an indirect object, with the raw data of its stream if it
+// has one.
+type pdfObject struct {
+	value  interface{}
+	stream []byte
+}
+
+// pdfDoc is a parsed PDF file.
+type pdfDoc struct {
+	data    []byte
+	objects map[int]*pdfObject
+}
+
+// maxPDFDepth bounds the recursion into the page tree and nested values.
+const maxPDFDepth = 64
+
+// maxPDFStreamSize bounds the decompressed size of a stream, against
+// deflate bombs.
+const maxPDFStreamSize = 64 << 20
+
+var pdfObjHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// pdfText returns the text of the pages of a PDF file, with a blank line
+// between pages.
+func pdfText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+	doc := &pdfDoc{data: data, objects: make(map[int]*pdfObject)}
+	doc.readObjects()
+	if _, ok := doc.objects[0]; ok {
+		delete(doc.objects, 0)
+	}
+
+	root, ok := doc.resolve(doc.catalog()).(pdfDict)
+	if !ok {
+		return "", errors.New("no document catalog")
+	}
+	var pages []string
+	doc.walkPages(doc.resolve(root["Pages"]), nil, 0, func(page pdfDict, resources pdfDict) {
+		pages = append(pages, doc.pageText(page, resources))
+	})
+	if len(pages) == 0 {
+		return "", errors.New("no pages")
+	}
+	return strings.Join(pages, "\n\n"), nil
+}
+
+// readObjects finds every "n g obj" in the file, in file order so that
+// objects redefined by incremental updates take their latest value, and then
+// unpacks the object streams.
+func (doc *pdfDoc) readObjects() {
+	for _, m := range pdfObjHeader.FindAllSubmatchIndex(doc.data, -1) {
+		num, _ := strconv.Atoi(string(doc.data[m[2]:m[3]]))
+		l := &pdfLexer{data: doc.data, pos: m[1]}
+		v, err := l.value(0)
+		if err != nil {
+			continue
+		}
+		obj := &pdfObject{value: v}
+		if d, ok := v.(pdfDict); ok {
+			obj.stream = l.stream(d)
+		}
+		doc.objects[num] = obj
+	}
+
+	for _, obj := range doc.objects {
+		d, ok := obj.value.(pdfDict)
+		if !ok || d["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := doc.decode(obj)
+		if err != nil {
+			continue
+		}
+		n, _ := d["N"].(float64)
+		first, _ := d["First"].(float64)
+		header := &pdfLexer{data: data}
+		for i := 0; i < int(n); i++ {
+			num, err1 := header.value(0)
+			off, err2 := header.value(0)
+			if err1 != nil || err2 != nil {
+				break
+			}
+			objNum, ok1 := num.(float64)
+			offset, ok2 := off.(float64)
+			if !ok1 || !ok2 || first+offset < 0 || int(first+offset) >= len(data) {
+				break
+			}
+			if _, ok := doc.objects[int(objNum)]; ok {
+				continue
+			}
+			l := &pdfLexer{data: data, pos: int(first + offset)}
+			if v, err := l.value(0); err == nil {
+				doc.objects[int(objNum)] = &pdfObject{value: v}
+			}
+		}
+	}
+}
+
+// catalog returns the /Root of the last trailer or cross-reference stream,
+// or failing that any object of type Catalog.
+func (doc *pdfDoc) catalog() interface{} {
+	if i := bytes.LastIndex(doc.data, []byte("trailer")); i >= 0 {
+		l := &pdfLexer{data: doc.data, pos: i + len("trailer")}
+		if v, err := l.value(0); err == nil {
+			if d, ok := v.(pdfDict); ok && d["Root"] != nil {
+				return d["Root"]
+			}
+		}
+	}
+	var catalog interface{}
+	for num, obj := range doc.objects {
+		if d, ok := obj.value.(pdfDict); ok {
+			if d["Type"] == pdfName("XRef") && d["Root"] != nil {
+				return d["Root"]
+			}
+			if d["Type"] == pdfName("Catalog") {
+				catalog = pdfRef{num: num}
+			}
+		}
+	}
+	return catalog
+}
+
+// resolve follows v if it is a reference.
+func (doc *pdfDoc) resolve(v interface{}) interface{} {
+	for i := 0; i < maxPDFDepth; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj, ok := doc.objects[ref.num]
+		if !ok {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+// walkPages calls fn for every page under node, with the resources it
+// inherits.
+func (doc *pdfDoc) walkPages(node interface{}, resources pdfDict, depth int, fn func(page, resources pdfDict)) {
+	d, ok := node.(pdfDict)
+	if !ok || depth > maxPDFDepth {
+		return
+	}
+	if r, ok := doc.resolve(d["Resources"]).(pdfDict); ok {
+		resources = r
+	}
+	kids, ok := doc.resolve(d["Kids"]).([]interface{})
+	if !ok {
+		fn(d, resources)
+		return
+	}
+	for _, k := range kids {
+		doc.walkPages(doc.resolve(k), resources, depth+1, fn)
+	}
+}
+
+// decode returns the stream data of obj with its filters undone.
+func (doc *pdfDoc) decode(obj *pdfObject) ([]byte, error) {
+	d, _ := obj.value.(pdfDict)
+	data := obj.stream
+	var filters []interface{}
+	switch f := doc.resolve(d["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{f}
+	case []interface{}:
+		filters = f
+	}
+	for _, f := range filters {
+		switch doc.resolve(f) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			out, err := ioutil.ReadAll(io.LimitReader(r, maxPDFStreamSize+1))
+			if err != nil && len(out) == 0 {
+				return nil, err
+			}
+			if len(out) > maxPDFStreamSize {
+				return nil, fmt.Errorf("stream larger than %d bytes decompressed", maxPDFStreamSize)
+			}
+			data = out
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			s := bytes.TrimSpace(data)
+			s = bytes.TrimPrefix(s, []byte("<~"))
+			if i := bytes.Index(s, []byte("~>")); i >= 0 {
+				s = s[:i]
+			}
+			out := make([]byte, 4*len(s)/5+4)
+			n, _, err := ascii85.Decode(out, s, true)
+			if err != nil {
+				return nil, err
+			}
+			data = out[:n]
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", f)
+		}
+	}
+	return data, nil
+}
+
+// pdfFont maps the character codes of a font to text.
+type pdfFont struct {
+	toUnicode map[string]string // by code; nil for a simple font without a map
+	codeLen   int
+	composite bool // a Type0 font, whose codes mean nothing without toUnicode
+}
+
+// font returns the font named name in resources.
+func (doc *pdfDoc) font(resources pdfDict, name pdfName) *pdfFont {
+	fonts, _ := doc.resolve(resources["Font"]).(pdfDict)
+	fd, _ := doc.resolve(fonts[name]).(pdfDict)
+	f := &pdfFont{codeLen: 1, composite: fd["Subtype"] == pdfName("Type0")}
+	if f.composite {
+		f.codeLen = 2
+	}
+	if ref, ok := fd["ToUnicode"].(pdfRef); ok {
+		if obj, ok := doc.objects[ref.num]; ok {
+			if data, err := doc.decode(obj); err == nil {
+				f.toUnicode, f.codeLen = parseCMap(data, f.codeLen)
+			}
+		}
+	}
+	return f
+}
+
+// text decodes a string shown in font f.
+func (f *pdfFont) text(s []byte) string {
+	if f.toUnicode == nil {
+		if f.composite {
+			return ""
+		}
+		out, _ := charmap.Windows1252.NewDecoder().Bytes(s)
+		return string(out)
+	}
+	var b strings.Builder
+	for i := 0; i+f.codeLen <= len(s); i += f.codeLen {
+		b.WriteString(f.toUnicode[string(s[i:i+f.codeLen])])
+	}
+	return b.String()
+}
+
+// parseCMap reads the bfchar and bfrange mappings of a ToUnicode CMap. It
+// returns them by code, and the code length from the codespace ranges.
+func parseCMap(data []byte, codeLen int) (map[string]string, int) {
+	m := make(map[string]string)
+	l := &pdfLexer{data: data}
+	var ops []interface{}
+	section := ""
+	for {
+		v, err := l.value(0)
+		if err != nil {
+			break
+		}
+		kw, ok := v.(pdfKeyword)
+		if !ok {
+			if section != "" {
+				ops = append(ops, v)
+			}
+			continue
+		}
+		switch kw {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			section, ops = string(kw), nil
+		case "endcodespacerange":
+			if len(ops) > 0 {
+				if s, ok := ops[0].(pdfString); ok && len(s) > 0 {
+					codeLen = len(s)
+				}
+			}
+			section = ""
+		case "endbfchar":
+			for i := 0; i+1 < len(ops); i += 2 {
+				src, ok1 := ops[i].(pdfString)
+				dst, ok2 := ops[i+1].(pdfString)
+				if ok1 && ok2 {
+					m[string(src)] = utf16BE(dst)
+				}
+			}
+			section = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(ops); i += 3 {
+				lo, ok1 := ops[i].(pdfString)
+				hi, ok2 := ops[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xffff {
+					continue
+				}
+				for c := start; c <= end; c++ {
+					code := codeBytes(c, len(lo))
+					switch dst := ops[i+2].(type) {
+					case pdfString:
+						if len(dst) == 0 {
+							continue
+						}
+						// The last UTF-16 unit, or the only byte of a
+						// malformed 1-byte target, counts up.
+						n := 2
+						if len(dst) < n {
+							n = len(dst)
+						}
+						d := append([]byte(nil), dst...)
+						last := codeValue(d[len(d)-n:]) + (c - start)
+						copy(d[len(d)-n:], codeBytes(last, n))
+						m[string(code)] = utf16BE(d)
+					case []interface{}:
+						if int(c-start) < len(dst) {
+							if s, ok := dst[c-start].(pdfString); ok {
+								m[string(code)] = utf16BE(s)
+							}
+						}
+					}
+				}
+			}
+			section = ""
+		}
+	}
+	return m, codeLen
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func codeBytes(v uint32, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+// utf16BE decodes big-endian UTF-16, the encoding of ToUnicode targets.
+func utf16BE(b []byte) string {
+	if len(b) == 1 {
+		return string(rune(b[0]))
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(u))
+}
+
+// pageText runs the content streams of page and collects the text they
+// show. A change of line position starts a new line and a horizontal move
+// or a wide TJ gap inserts a space.
+func (doc *pdfDoc) pageText(page, resources pdfDict) string {
+	var content []byte
+	contents := doc.resolve(page["Contents"])
+	list, ok := contents.([]interface{})
+	if !ok {
+		list = []interface{}{page["Contents"]}
+	}
+	for _, c := range list {
+		ref, ok := c.(pdfRef)
+		if !ok {
+			continue
+		}
+		if obj, ok := doc.objects[ref.num]; ok {
+			if data, err := doc.decode(obj); err == nil {
+				content = append(append(content, data...), '\n')
+			}
+		}
+	}
+
+	var b strings.Builder
+	fonts := make(map[pdfName]*pdfFont)
+	font := &pdfFont{codeLen: 1}
+	var x, y, leading float64
+	lastX, lastY := math.NaN(), math.NaN()
+	show := func(s pdfString) {
+		t := font.text(s)
+		if t == "" {
+			return
+		}
+		switch {
+		case math.IsNaN(lastY):
+		case math.Abs(y-lastY) > 1:
+			b.WriteByte('\n')
+		case x != lastX && !strings.HasSuffix(b.String(), " ") && !strings.HasPrefix(t, " "):
+			b.WriteByte(' ')
+		}
+		lastX, lastY = x, y
+		b.WriteString(t)
+	}
+	newline := func() { y -= leading }
+
+	l := &pdfLexer{data: content}
+	var ops []interface{}
+	num := func(i int) float64 {
+		if i < len(ops) {
+			f, _ := ops[i].(float64)
+			return f
+		}
+		return 0
+	}
+	for {
+		v, err := l.value(0)
+		if err != nil {
+			break
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			ops = append(ops, v)
+			continue
+		}
+		switch op {
+		case "BT":
+			x, y = 0, 0
+		case "Tf":
+			if len(ops) > 0 {
+				name, _ := ops[0].(pdfName)
+				if fonts[name] == nil {
+					fonts[name] = doc.font(resources, name)
+				}
+				font = fonts[name]
+			}
+		case "TL":
+			leading = num(0)
+		case "Td":
+			x, y = x+num(0), y+num(1)
+		case "TD":
+			x, y, leading = x+num(0), y+num(1), -num(1)
+		case "Tm":
+			x, y = num(4), num(5)
+		case "T*":
+			newline()
+		case "Tj":
+			if len(ops) > 0 {
+				s, _ := ops[0].(pdfString)
+				show(s)
+			}
+		case "'":
+			newline()
+			if len(ops) > 0 {
+				s, _ := ops[0].(pdfString)
+				show(s)
+			}
+		case "\"":
+			newline()
+			if len(ops) > 2 {
+				s, _ := ops[2].(pdfString)
+				show(s)
+			}
+		case "TJ":
+			if len(ops) > 0 {
+				arr, _ := ops[0].([]interface{})
+				for _, e := range arr {
+					switch e := e.(type) {
+					case pdfString:
+						show(e)
+					case float64:
+						if e < -180 && !strings.HasSuffix(b.String(), " ") {
+							b.WriteByte(' ')
+						}
+					}
+				}
+			}
+		case "ID":
+			l.skipInlineImage()
+		}
+		ops = ops[:0]
+	}
+	return b.String()
+}
+
+// pdfLexer reads PDF values from data.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+var errPDFEnd = errors.New("end of data")
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips white space and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// value reads the next value or keyword. An integer followed by another and
+// R is read as a reference.
+func (l *pdfLexer) value(depth int) (interface{}, error) {
+	if depth > maxPDFDepth {
+		return nil, errors.New("nesting too deep")
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEnd
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(unescapeName(l.data[start:l.pos])), nil
+	case c == '(':
+		return l.literalString(), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		d := make(pdfDict)
+		for {
+			l.skipSpace()
+			if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+				l.pos += 2
+				return d, nil
+			}
+			k, err := l.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := k.(pdfName)
+			if !ok {
+				return nil, errors.New("dictionary key is not a name")
+			}
+			v, err := l.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			d[name] = v
+		}
+	case c == '<':
+		return l.hexString(), nil
+	case c == '[':
+		l.pos++
+		var arr []interface{}
+		for {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == ']' {
+				l.pos++
+				return arr, nil
+			}
+			v, err := l.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	tok := string(l.data[start:l.pos])
+	f, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		switch tok {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return pdfKeyword(tok), nil
+	}
+	// Look ahead for "gen R".
+	if f == math.Trunc(f) && f >= 0 {
+		save := l.pos
+		if gen, err := l.value(depth + 1); err == nil {
+			if g, ok := gen.(float64); ok {
+				if r, err := l.value(depth + 1); err == nil && r == pdfKeyword("R") {
+					return pdfRef{num: int(f), gen: int(g)}, nil
+				}
+			}
+		}
+		l.pos = save
+	}
+	return f, nil
+}
+
+// literalString reads a (string) with its escapes and balanced
+// parentheses.
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// hexString reads a <hex string>.
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		b[i] = byte(v)
+	}
+	return b
+}
+
+// stream returns the data of the stream following dictionary d, if the
+// next keyword is "stream".
+func (l *pdfLexer) stream(d pdfDict) []byte {
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		return nil
+	}
+	start := l.pos + len("stream")
+	if start < len(l.data) && l.data[start] == '\r' {
+		start++
+	}
+	if start < len(l.data) && l.data[start] == '\n' {
+		start++
+	}
+	if n, ok := d["Length"].(float64); ok && n >= 0 && start+int(n) <= len(l.data) {
+		end := start + int(n)
+		rest := bytes.TrimLeft(l.data[end:], " \t\r\n")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			l.pos = end
+			return l.data[start:end]
+		}
+	}
+	i := bytes.Index(l.data[start:], []byte("endstream"))
+	if i < 0 {
+		return nil
+	}
+	l.pos = start + i
+	return bytes.TrimRight(l.data[start:start+i], "\r\n")
+}
+
+// skipInlineImage moves past the data of an inline image, to its EI.
+func (l *pdfLexer) skipInlineImage() {
+	for i := l.pos + 1; i+2 < len(l.data); i++ {
+		if l.data[i] == 'E' && l.data[i+1] == 'I' && isPDFSpace(l.data[i-1]) && (i+2 == len(l.data) || isPDFSpace(l.data[i+2])) {
+			l.pos = i + 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// unescapeName undoes the #xx escapes of a name.
+func unescapeName(b []byte) string {
+	if bytes.IndexByte(b, '#') < 0 {
+		return string(b)
+	}
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			if v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,221 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256" // for signing with SHA-256
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	_ "golang.org/x/crypto/ripemd160" // the hash openpgp assumes for keys without preferences
+)
+
+// PGP is a Wrapper that signs and encrypts messages as PGP/MIME (RFC 3156).
+// With only Signer set the content is sent as multipart/signed with a
+// detached signature; with Recipients it is sent as multipart/encrypted, and
+// signed first if Signer is set too. Headers such as Subject stay in the
+// clear.
+type PGP struct {
+	// Signer signs the message. Its private key must be decrypted; see
+	// DecryptPGPKey.
+	Signer *openpgp.Entity
+
+	// Recipients are the keys the message is encrypted to. The Signer is
+	// added when it can encrypt, so that the copy in Sent stays readable.
+	Recipients openpgp.EntityList
+}
+
+// pgpConfig selects SHA-256 signatures; micalg must match it.
+var pgpConfig = &packet.Config{DefaultHash: crypto.SHA256}
+
+// Wrap implements Wrapper.
+func (p *PGP) Wrap(h textproto.MIMEHeader, body []byte) (textproto.MIMEHeader, []byte, error) {
+	if p.Signer == nil && len(p.Recipients) == 0 {
+		return h, body, nil
+	}
+	var err error
+	if p.Signer != nil {
+		if h, body, err = p.sign(h, body); err != nil {
+			return nil, nil, fmt.Errorf("PGP sign: %w", err)
+		}
+	}
+	if len(p.Recipients) > 0 {
+		if h, body, err = p.encrypt(h, body); err != nil {
+			return nil, nil, fmt.Errorf("PGP encrypt: %w", err)
+		}
+	}
+	return h, body, nil
+}
+
+// sign returns a multipart/signed holding the entity and its signature.
+func (p *PGP) sign(h textproto.MIMEHeader, body []byte) (textproto.MIMEHeader, []byte, error) {
+	var entity bytes.Buffer
+	writeEntity(&entity, h, body)
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, p.Signer, bytes.NewReader(entity.Bytes()), pgpConfig); err != nil {
+		return nil, nil, err
+	}
+	sh := textproto.MIMEHeader{}
+	sh.Set("Content-Type", mime.FormatMediaType("application/pgp-signature", map[string]string{"name": "signature.asc"}))
+	sh.Set("Content-Description", "OpenPGP digital signature")
+	sh.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "signature.asc"}))
+	h, body = writeParts("multipart/signed", map[string]string{"micalg": "pgp-sha256", "protocol": "application/pgp-signature"},
+		entity.Bytes(), entityBytes(sh, crlf(sig.Bytes())))
+	return h, body, nil
+}
+
+// encrypt returns a multipart/encrypted holding the entity encrypted to the
+// recipients.
+func (p *PGP) encrypt(h textproto.MIMEHeader, body []byte) (textproto.MIMEHeader, []byte, error) {
+	to := p.Recipients
+	if p.Signer != nil && canEncrypt(p.Signer) {
+		to = append(openpgp.EntityList{p.Signer}, to...)
+	}
+	var enc bytes.Buffer
+	aw, err := armor.Encode(&enc, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	pw, err := openpgp.Encrypt(aw, to, nil, &openpgp.FileHints{IsBinary: true}, pgpConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entity bytes.Buffer
+	writeEntity(&entity, h, body)
+	if _, err := pw.Write(entity.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	if err := pw.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, nil, err
+	}
+	enc.WriteString("\n")
+
+	vh := textproto.MIMEHeader{}
+	vh.Set("Content-Type", "application/pgp-encrypted")
+	vh.Set("Content-Description", "PGP/MIME version identification")
+	eh := textproto.MIMEHeader{}
+	eh.Set("Content-Type", mime.FormatMediaType("application/octet-stream", map[string]string{"name": "encrypted.asc"}))
+	eh.Set("Content-Description", "OpenPGP encrypted message")
+	eh.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": "encrypted.asc"}))
+	h, body = writeParts("multipart/encrypted", map[string]string{"protocol": "application/pgp-encrypted"},
+		entityBytes(vh, []byte("Version: 1\r\n")), entityBytes(eh, crlf(enc.Bytes())))
+	return h, body, nil
+}
+
+// canEncrypt reports whether e has a key usable for encryption.
+func canEncrypt(e *openpgp.Entity) bool {
+	if e.PrimaryKey.PubKeyAlgo.CanEncrypt() {
+		return true
+	}
+	for _, s := range e.Subkeys {
+		if s.Sig.FlagsValid && (s.Sig.FlagEncryptCommunications || s.Sig.FlagEncryptStorage) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeParts returns a multipart entity of the given type holding parts,
+// which are complete entities written as they are.
+func writeParts(mediaType string, params map[string]string, parts ...[]byte) (textproto.MIMEHeader, []byte) {
+	boundary := multipart.NewWriter(nil).Boundary()
+	var b bytes.Buffer
+	for _, part := range parts {
+		b.WriteString("--" + boundary + "\r\n")
+		b.Write(part)
+		b.WriteString("\r\n")
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	p := map[string]string{"boundary": boundary}
+	for k, v := range params {
+		p[k] = v
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType(mediaType, p))
+	return h, b.Bytes()
+}
+
+// entityBytes returns the entity with header h and body.
+func entityBytes(h textproto.MIMEHeader, body []byte) []byte {
+	var b bytes.Buffer
+	writeEntity(&b, h, body)
+	return b.Bytes()
+}
+
+// crlf converts the line endings of b to CRLF.
+func crlf(b []byte) []byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(b, []byte("\n"), []byte("\r\n"), -1)
+}
+
+// ReadPGPKeys reads the OpenPGP keys in path, armored or binary.
+func ReadPGPKeys(path string) (openpgp.EntityList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadPGPKeys: %w", err)
+	}
+	defer f.Close()
+	keys, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		if _, serr := f.Seek(0, io.SeekStart); serr != nil {
+			return nil, fmt.Errorf("ReadPGPKeys: %w", serr)
+		}
+		if keys, err = openpgp.ReadKeyRing(f); err != nil {
+			return nil, fmt.Errorf("ReadPGPKeys %s: %w", path, err)
+		}
+	}
+	return keys, nil
+}
+
+// DecryptPGPKey decrypts the private key of e and its subkeys with
+// passphrase. Keys that are not encrypted are left alone.
+func DecryptPGPKey(e *openpgp.Entity, passphrase []byte) error {
+	if e.PrivateKey == nil {
+		return fmt.Errorf("DecryptPGPKey: %X is a public key", e.PrimaryKey.Fingerprint)
+	}
+	if e.PrivateKey.Encrypted {
+		if err := e.PrivateKey.Decrypt(passphrase); err != nil {
+			return fmt.Errorf("DecryptPGPKey: %w", err)
+		}
+	}
+	for _, s := range e.Subkeys {
+		if s.PrivateKey != nil && s.PrivateKey.Encrypted {
+			if err := s.PrivateKey.Decrypt(passphrase); err != nil {
+				return fmt.Errorf("DecryptPGPKey: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// PGPKeyEncrypted reports whether the private key of e needs a passphrase.
+func PGPKeyEncrypted(e *openpgp.Entity) bool {
+	return e.PrivateKey != nil && e.PrivateKey.Encrypted
+}
@@ -0,0 +1,123 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// readParts returns the raw parts of the multipart entity with the given
+// Content-Type, checking its media type.
+func readParts(t *testing.T, contentType, want string, body []byte) [][]byte {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != want {
+		t.Fatalf("Content-Type = %q, want %s", contentType, want)
+	}
+	delim := []byte("\r\n--" + params["boundary"])
+	chunks := bytes.Split(append([]byte("\r\n"), body...), delim)
+	var parts [][]byte
+	for _, c := range chunks[1 : len(chunks)-1] {
+		parts = append(parts, bytes.TrimPrefix(c, []byte("\r\n")))
+	}
+	return parts
+}
+
+func TestPGPSignAndEncrypt(t *testing.T) {
+	cfg := &packet.Config{RSABits: 1024}
+	sender, err := openpgp.NewEntity("Sender", "", "sender@example.com", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcpt, err := openpgp.NewEntity("Ana", "", "ana@example.com", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &gmailclient.OutgoingMessage{
+		To:      gmailclient.ParseAddressList("ana@example.com"),
+		Subject: "Secret",
+		Text:    "The launch code is 1234.\n",
+		Wrapper: &gmailclient.PGP{Signer: sender, Recipients: openpgp.EntityList{rcpt}},
+	}
+	raw, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("launch code")) {
+		t.Fatal("message body is in the clear")
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(msg.Body)
+	parts := readParts(t, msg.Header.Get("Content-Type"), "multipart/encrypted", body)
+	if len(parts) != 2 || !bytes.Contains(parts[0], []byte("Version: 1")) {
+		t.Fatalf("encrypted parts = %q", parts)
+	}
+	enc, err := mail.ReadMessage(bytes.NewReader(parts[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := armor.Decode(enc.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{rcpt}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, _ := ioutil.ReadAll(md.UnverifiedBody)
+
+	// The decrypted entity is a multipart/signed whose signature covers its
+	// first part byte for byte.
+	signed, err := mail.ReadMessage(bytes.NewReader(inner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbody, _ := ioutil.ReadAll(signed.Body)
+	sparts := readParts(t, signed.Header.Get("Content-Type"), "multipart/signed", sbody)
+	if len(sparts) != 2 {
+		t.Fatalf("signed parts = %q", sparts)
+	}
+	sig, err := mail.ReadMessage(bytes.NewReader(sparts[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{sender}, bytes.NewReader(sparts[0]), sig.Body); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	text, err := multipart.NewReader(bytes.NewReader(append([]byte("--x\r\n"), append(sparts[0], "\r\n--x--\r\n"...)...)), "x").NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(text); !strings.Contains(string(b), "launch code is 1234") {
+		t.Errorf("signed text = %q", b)
+	}
+}
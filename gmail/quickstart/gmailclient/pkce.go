@@ -0,0 +1,67 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// randomString returns n random bytes, base64url-encoded without padding.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newState returns an unguessable OAuth state parameter, which ties the
+// redirect to the authorization request that this process started.
+func newState() (string, error) {
+	return randomString(24)
+}
+
+// A codeVerifier is a PKCE (RFC 7636) code verifier. Its S256 challenge goes
+// into the authorization request and the verifier itself into the code
+// exchange, so an intercepted authorization code is useless on its own.
+type codeVerifier string
+
+func newCodeVerifier() (codeVerifier, error) {
+	s, err := randomString(32)
+	return codeVerifier(s), err
+}
+
+// authParams returns the authorization request parameters carrying the
+// challenge for v.
+func (v codeVerifier) authParams() []oauth2.AuthCodeOption {
+	sum := sha256.Sum256([]byte(v))
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	}
+}
+
+// exchangeParam returns the token request parameter carrying v.
+func (v codeVerifier) exchangeParam() oauth2.AuthCodeOption {
+	return oauth2.SetAuthURLParam("code_verifier", string(v))
+}
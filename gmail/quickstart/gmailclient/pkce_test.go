@@ -0,0 +1,86 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// unreserved matches the characters RFC 7636 allows in a code verifier.
+var unreserved = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+// authURLParams returns the query parameters opts add to an authorization
+// URL.
+func authURLParams(t *testing.T, opts ...oauth2.AuthCodeOption) url.Values {
+	cfg := &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{AuthURL: "https://accounts.example.com/auth"}}
+	u, err := url.Parse(cfg.AuthCodeURL("state", opts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func TestCodeVerifierChallenge(t *testing.T) {
+	// The example of RFC 7636, appendix B.
+	q := authURLParams(t, codeVerifier("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk").authParams()...)
+	if got, want := q.Get("code_challenge"), "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("code_challenge = %q, want %q", got, want)
+	}
+	if got := q.Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+
+	v, err := newCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v) < 43 || len(v) > 128 || !unreserved.MatchString(string(v)) {
+		t.Errorf("verifier %q is not a valid RFC 7636 verifier", v)
+	}
+	sum := sha256.Sum256([]byte(v))
+	if got, want := authURLParams(t, v.authParams()...).Get("code_challenge"), base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("code_challenge = %q, want %q", got, want)
+	}
+	if got := authURLParams(t, v.exchangeParam()).Get("code_verifier"); got != string(v) {
+		t.Errorf("code_verifier = %q, want %q", got, v)
+	}
+}
+
+func TestNewState(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		s, err := newState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 24 random bytes, base64url encoded.
+		if len(s) != 32 || !unreserved.MatchString(s) {
+			t.Errorf("state %q: want 32 URL-safe characters", s)
+		}
+		if seen[s] {
+			t.Fatalf("state %q repeated", s)
+		}
+		seen[s] = true
+	}
+}
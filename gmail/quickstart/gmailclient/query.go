@@ -0,0 +1,238 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Query builds a Gmail search query term by term, quoting values as
+// needed, so that queries need not be put together by hand:
+//
+//	q := NewQuery().Label("newsletter").From("hi@example.com").After(t).String()
+//
+// Terms are ANDed together, as Gmail does. Each method adds a term and
+// returns q, to be chained. The zero value is an empty query, which matches
+// every message.
+type Query struct {
+	terms []string
+}
+
+// NewQuery returns an empty query.
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// String returns the query in Gmail search syntax.
+func (q *Query) String() string {
+	return strings.Join(q.terms, " ")
+}
+
+func (q *Query) add(term string) *Query {
+	q.terms = append(q.terms, term)
+	return q
+}
+
+// quoteQuery returns v as a single search value, in double quotes if it
+// holds spaces or characters Gmail's query syntax treats specially. Gmail
+// has no escape for double quotes, so those are dropped.
+func quoteQuery(v string) string {
+	v = strings.Replace(v, `"`, "", -1)
+	if v == "" || strings.ContainsAny(v, " \t\r\n(){}:-") {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// Term adds the words or the raw query term s, unquoted. It is the way to
+// use search operators the other methods do not cover.
+func (q *Query) Term(s string) *Query { return q.add(s) }
+
+// Phrase adds the exact phrase s.
+func (q *Query) Phrase(s string) *Query { return q.add(`"` + strings.Replace(s, `"`, "", -1) + `"`) }
+
+// From matches messages from the address or name addr.
+func (q *Query) From(addr string) *Query { return q.add("from:" + quoteQuery(addr)) }
+
+// To matches messages to the address or name addr.
+func (q *Query) To(addr string) *Query { return q.add("to:" + quoteQuery(addr)) }
+
+// Cc matches messages copied to the address or name addr.
+func (q *Query) Cc(addr string) *Query { return q.add("cc:" + quoteQuery(addr)) }
+
+// Subject matches messages with s in the subject.
+func (q *Query) Subject(s string) *Query { return q.add("subject:" + quoteQuery(s)) }
+
+// Label matches messages with the label of that name, system or user.
+func (q *Query) Label(name string) *Query { return q.add("label:" + quoteQuery(name)) }
+
+// In matches messages in the mailbox folder name: inbox, sent, trash,
+// spam, anywhere and so on.
+func (q *Query) In(name string) *Query { return q.add("in:" + quoteQuery(name)) }
+
+// Is matches messages in state s: unread, read, starred, important,
+// snoozed and so on.
+func (q *Query) Is(s string) *Query { return q.add("is:" + quoteQuery(s)) }
+
+// After matches messages received after t. Unlike after:YYYY/MM/DD, which
+// Gmail reads in Pacific time, the term holds t to the second.
+func (q *Query) After(t time.Time) *Query {
+	return q.add("after:" + strconv.FormatInt(t.Unix(), 10))
+}
+
+// Before matches messages received before t, to the second like After.
+func (q *Query) Before(t time.Time) *Query {
+	return q.add("before:" + strconv.FormatInt(t.Unix(), 10))
+}
+
+// AfterDate matches messages received on or after the day of t, as
+// after:YYYY/MM/DD. Gmail reads such dates in Pacific time.
+func (q *Query) AfterDate(t time.Time) *Query {
+	return q.add("after:" + t.Format("2006/01/02"))
+}
+
+// BeforeDate matches messages received before the day of t, as
+// before:YYYY/MM/DD, in Pacific time like AfterDate.
+func (q *Query) BeforeDate(t time.Time) *Query {
+	return q.add("before:" + t.Format("2006/01/02"))
+}
+
+// NewerThan matches messages received within d, rounded down to whole days
+// but at least one.
+func (q *Query) NewerThan(d time.Duration) *Query {
+	return q.add("newer_than:" + queryDays(d))
+}
+
+// OlderThan matches messages received more than d ago, rounded down to
+// whole days but at least one.
+func (q *Query) OlderThan(d time.Duration) *Query {
+	return q.add("older_than:" + queryDays(d))
+}
+
+func queryDays(d time.Duration) string {
+	days := int64(d / (24 * time.Hour))
+	if days < 1 {
+		days = 1
+	}
+	return strconv.FormatInt(days, 10) + "d"
+}
+
+// Categories are the inbox categories Category accepts.
+var Categories = []string{"primary", "social", "promotions", "updates", "forums", "reservations", "purchases"}
+
+// Category matches messages in the inbox category name, one of Categories.
+func (q *Query) Category(name string) *Query { return q.add("category:" + quoteQuery(name)) }
+
+// HasAttachment matches messages with an attachment.
+func (q *Query) HasAttachment() *Query { return q.add("has:attachment") }
+
+// Filename matches messages with an attachment named name, or of that
+// extension.
+func (q *Query) Filename(name string) *Query { return q.add("filename:" + quoteQuery(name)) }
+
+// Larger matches messages larger than size bytes.
+func (q *Query) Larger(size int64) *Query {
+	return q.add("larger:" + strconv.FormatInt(size, 10))
+}
+
+// Smaller matches messages smaller than size bytes.
+func (q *Query) Smaller(size int64) *Query {
+	return q.add("smaller:" + strconv.FormatInt(size, 10))
+}
+
+// Or matches messages matching any of qs. Empty queries among qs are
+// skipped; if all are empty, Or adds nothing.
+func (q *Query) Or(qs ...*Query) *Query {
+	var alts []string
+	for _, o := range qs {
+		if s := o.group(); s != "" {
+			alts = append(alts, s)
+		}
+	}
+	switch len(alts) {
+	case 0:
+		return q
+	case 1:
+		return q.add(alts[0])
+	}
+	return q.add("(" + strings.Join(alts, " OR ") + ")")
+}
+
+// Not matches messages that do not match o. If o is empty, Not adds
+// nothing.
+func (q *Query) Not(o *Query) *Query {
+	if s := o.group(); s != "" {
+		return q.add("-" + s)
+	}
+	return q
+}
+
+// group returns q as one term, in parentheses unless it is a single
+// word already.
+func (q *Query) group() string {
+	s := q.String()
+	if strings.ContainsAny(s, " \t") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// ParseSize parses a message size for Larger and Smaller: a number of bytes,
+// optionally followed by K, M or G for kibibytes, mebibytes or gibibytes, as
+// in 500K or 5M. Case and a trailing B are ignored.
+func ParseSize(s string) (int64, error) {
+	v := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	shift := uint(0)
+	switch {
+	case strings.HasSuffix(v, "K"):
+		shift = 10
+	case strings.HasSuffix(v, "M"):
+		shift = 20
+	case strings.HasSuffix(v, "G"):
+		shift = 30
+	}
+	if shift > 0 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)>>shift {
+		return 0, fmt.Errorf("invalid size %q (want bytes, or a number with K, M or G)", s)
+	}
+	return n << shift, nil
+}
+
+// ParseAge parses a message age for newer_than: and older_than: as the
+// number and unit Gmail takes: 30d, 6m or 1y for days, months or years.
+// Weeks, as in 2w, are turned into days, which Gmail has no unit for.
+func ParseAge(s string) (n int, unit string, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) >= 2 {
+		unit = s[len(s)-1:]
+		n, err = strconv.Atoi(s[:len(s)-1])
+	}
+	if len(s) < 2 || err != nil || n < 1 || !strings.Contains("dwmy", unit) {
+		return 0, "", fmt.Errorf("invalid age %q (want a number of days, weeks, months or years, as in 30d, 2w, 6m or 1y)", s)
+	}
+	if unit == "w" {
+		n, unit = n*7, "d"
+	}
+	return n, unit, nil
+}
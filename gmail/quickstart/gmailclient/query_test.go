@@ -0,0 +1,122 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestQuery(t *testing.T) {
+	after := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		q    *gmailclient.Query
+		want string
+	}{
+		{gmailclient.NewQuery(), ""},
+		{
+			gmailclient.NewQuery().Label("newsletter").After(after).From("hi@vimtricks.com"),
+			"label:newsletter after:1619827200 from:hi@vimtricks.com",
+		},
+		{
+			gmailclient.NewQuery().From("John Smith").Subject(`say "hi"`).Label("Work/Project X"),
+			`from:"John Smith" subject:"say hi" label:"Work/Project X"`,
+		},
+		{
+			gmailclient.NewQuery().HasAttachment().Larger(5 << 20).Smaller(10 << 20),
+			"has:attachment larger:5242880 smaller:10485760",
+		},
+		{
+			gmailclient.NewQuery().Or(
+				gmailclient.NewQuery().From("a@example.com"),
+				gmailclient.NewQuery().To("b@example.com").Is("unread"),
+				gmailclient.NewQuery(),
+			),
+			"(from:a@example.com OR (to:b@example.com is:unread))",
+		},
+		{
+			gmailclient.NewQuery().In("inbox").Not(gmailclient.NewQuery().Label("receipts")).Not(gmailclient.NewQuery().Phrase("weekly digest")),
+			`in:inbox -label:receipts -("weekly digest")`,
+		},
+		{
+			gmailclient.NewQuery().Or(gmailclient.NewQuery().Cc("c@example.com")).NewerThan(36 * time.Hour).OlderThan(time.Minute),
+			"cc:c@example.com newer_than:1d older_than:1d",
+		},
+	} {
+		if got := tt.q.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestQueryDatesAndCategories(t *testing.T) {
+	day := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	got := gmailclient.NewQuery().AfterDate(day).BeforeDate(day.AddDate(0, 1, 0)).Category("promotions").String()
+	if want := "after:2024/01/02 before:2024/02/02 category:promotions"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want int64
+	}{
+		{"1024", 1024},
+		{"500K", 500 << 10},
+		{"5M", 5 << 20},
+		{"5mb", 5 << 20},
+		{"2G", 2 << 30},
+		{"0", 0},
+	} {
+		got, err := gmailclient.ParseSize(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tt.s, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{"", "M", "5T", "-1", "1.5M", "99999999999G"} {
+		if _, err := gmailclient.ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		n    int
+		unit string
+	}{
+		{"30d", 30, "d"},
+		{"2w", 14, "d"},
+		{"6M", 6, "m"},
+		{"1y", 1, "y"},
+	} {
+		n, unit, err := gmailclient.ParseAge(tt.s)
+		if err != nil || n != tt.n || unit != tt.unit {
+			t.Errorf("ParseAge(%q) = %d, %q, %v, want %d, %q", tt.s, n, unit, err, tt.n, tt.unit)
+		}
+	}
+	for _, s := range []string{"", "d", "30", "0d", "-1d", "3h", "2024-01-01"} {
+		if _, _, err := gmailclient.ParseAge(s); err == nil {
+			t.Errorf("ParseAge(%q) succeeded, want error", s)
+		}
+	}
+}
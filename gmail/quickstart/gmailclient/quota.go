@@ -0,0 +1,131 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultQuota is Gmail's per-user limit of quota units per second.
+const DefaultQuota = 250
+
+// WithRateLimit returns a copy of client that spends at most unitsPerSecond
+// Gmail quota units per second, waiting before requests that would exceed
+// it. Each request is charged its cost from QuotaCost, so a send counts as
+// much as twenty message fetches. Apply it per mailbox: the quota is per user.
+func WithRateLimit(client *http.Client, unitsPerSecond float64) *http.Client {
+	burst := int(unitsPerSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	c := *client
+	c.Transport = &rateLimitTransport{
+		base:    client.Transport,
+		limiter: rate.NewLimiter(rate.Limit(unitsPerSecond), burst),
+	}
+	return &c
+}
+
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cost := QuotaCost(req)
+	if cost > t.limiter.Burst() {
+		cost = t.limiter.Burst()
+	}
+	if err := t.limiter.WaitN(req.Context(), cost); err != nil {
+		return nil, err
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// QuotaCost returns the quota units the Gmail API charges for req, following
+// https://developers.google.com/gmail/api/reference/quota. Unknown requests
+// cost 5 units.
+func QuotaCost(req *http.Request) int {
+	path := req.URL.Path
+	i := strings.Index(path, "/users/")
+	if i < 0 {
+		return 5
+	}
+	// The path after "/users/{userId}", e.g. ["messages", "send"].
+	parts := strings.Split(strings.Trim(path[i+len("/users/"):], "/"), "/")[1:]
+	if len(parts) == 0 {
+		return 5
+	}
+	get, del := req.Method == "GET", req.Method == "DELETE"
+	last := parts[len(parts)-1]
+	switch parts[0] {
+	case "profile":
+		return 1
+	case "watch":
+		return 100
+	case "stop":
+		return 50
+	case "history":
+		return 2
+	case "labels":
+		if get {
+			return 1
+		}
+		return 5
+	case "drafts":
+		switch {
+		case get:
+			return 1
+		case last == "send":
+			return 100
+		case req.Method == "PUT":
+			return 15
+		}
+		return 10
+	case "threads":
+		if del {
+			return 20
+		}
+		return 10
+	case "settings":
+		if get {
+			return 1
+		}
+		return 5
+	case "messages":
+		switch {
+		case last == "send":
+			return 100
+		case last == "batchModify", last == "batchDelete":
+			return 50
+		case last == "import", len(parts) == 1 && req.Method == "POST":
+			return 25
+		case del:
+			return 10
+		}
+		return 5
+	}
+	return 5
+}
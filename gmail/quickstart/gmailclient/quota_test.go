@@ -0,0 +1,49 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestQuotaCost(t *testing.T) {
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/gmail/v1/users/me/messages", 5},
+		{"GET", "/gmail/v1/users/me/messages/17a1", 5},
+		{"GET", "/gmail/v1/users/me/messages/17a1/attachments/att-html", 5},
+		{"POST", "/gmail/v1/users/me/messages/send", 100},
+		{"POST", "/upload/gmail/v1/users/me/messages/send", 100},
+		{"POST", "/gmail/v1/users/me/messages/batchModify", 50},
+		{"DELETE", "/gmail/v1/users/me/messages/17a1", 10},
+		{"GET", "/gmail/v1/users/me/history", 2},
+		{"GET", "/gmail/v1/users/me/labels", 1},
+		{"GET", "/gmail/v1/users/me/threads/17a1", 10},
+		{"GET", "/gmail/v1/users/me/profile", 1},
+	} {
+		req := httptest.NewRequest(tt.method, "https://gmail.googleapis.com"+tt.path, nil)
+		if got := gmailclient.QuotaCost(req); got != tt.want {
+			t.Errorf("QuotaCost(%s %s) = %d, want %d", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
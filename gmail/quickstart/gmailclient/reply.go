@@ -0,0 +1,130 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+)
+
+// NewReply returns a reply to orig from the mailbox whose address is self,
+// with the recipients, subject and threading headers filled in: it goes to
+// the Reply-To or From addresses, or with all also to the other To and Cc
+// recipients except self. A reply to one's own message goes to its
+// recipients instead. The bodies are left for the caller; see QuoteText and
+// QuoteHTML. Send it with orig.ThreadId so Gmail files it in the same
+// conversation.
+func NewReply(orig *Message, self string, all bool) *OutgoingMessage {
+	r := &OutgoingMessage{Subject: replySubject(orig.Subject)}
+	seen := map[string]bool{strings.ToLower(self): true}
+	add := func(list *[]*mail.Address, addrs []*mail.Address) {
+		for _, a := range addrs {
+			key := strings.ToLower(a.Address)
+			if !seen[key] {
+				seen[key] = true
+				*list = append(*list, a)
+			}
+		}
+	}
+
+	fromSelf := len(orig.From) > 0 && strings.EqualFold(orig.From[0].Address, self)
+	switch {
+	case fromSelf:
+		add(&r.To, orig.To)
+	case len(orig.ReplyTo) > 0:
+		add(&r.To, orig.ReplyTo)
+	default:
+		add(&r.To, orig.From)
+	}
+	if all {
+		if !fromSelf {
+			add(&r.Cc, orig.To)
+		}
+		add(&r.Cc, orig.Cc)
+	}
+	if fromSelf && len(r.To) == 0 {
+		// A note to self.
+		r.To = orig.From
+	}
+
+	if id := orig.Headers.Get("Message-ID"); id != "" {
+		refs := strings.Fields(orig.Headers.Get("References"))
+		if len(refs) == 0 {
+			if parent := orig.Headers.Get("In-Reply-To"); parent != "" {
+				refs = strings.Fields(parent)
+			}
+		}
+		r.Header = append(r.Header,
+			HeaderField{Name: "In-Reply-To", Value: id},
+			HeaderField{Name: "References", Value: strings.Join(append(refs, id), " ")})
+	}
+	return r
+}
+
+// replySubject prefixes subject with "Re: " unless it already is a reply.
+func replySubject(subject string) string {
+	if len(subject) >= 3 && strings.EqualFold(subject[:3], "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// attribution is the line introducing the quoted original.
+func attribution(orig *Message) string {
+	from := "someone"
+	if len(orig.From) > 0 {
+		from = FormatAddressList(orig.From[:1])
+	}
+	if orig.Date.IsZero() {
+		return from + " wrote:"
+	}
+	return fmt.Sprintf("On %s, %s wrote:", orig.Date.Format("Mon, Jan 2, 2006 at 3:04 PM"), from)
+}
+
+// QuoteText returns the plain-text body of orig, or the text of its HTML
+// body, quoted with "> " under an attribution line.
+func QuoteText(orig *Message) string {
+	body := orig.BodyPlain
+	if body == "" && orig.BodyHtml != "" {
+		body, _ = HTMLToText(orig.BodyHtml)
+	}
+	body = strings.TrimRight(strings.Replace(body, "\r\n", "\n", -1), "\n")
+	lines := strings.Split(body, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ">") {
+			lines[i] = ">" + l
+		} else {
+			lines[i] = "> " + l
+		}
+	}
+	return attribution(orig) + "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// QuoteHTML returns the HTML body of orig, or its escaped plain-text body,
+// in a blockquote under an attribution line, as Gmail quotes replies.
+func QuoteHTML(orig *Message) string {
+	body := orig.BodyHtml
+	if body == "" {
+		body = "<pre>" + html.EscapeString(orig.BodyPlain) + "</pre>"
+	}
+	return `<div class="gmail_quote"><div>` + html.EscapeString(attribution(orig)) + "</div>\n" +
+		`<blockquote class="gmail_quote" style="margin:0 0 0 .8ex;border-left:1px #ccc solid;padding-left:1ex">` +
+		body + "</blockquote></div>\n"
+}
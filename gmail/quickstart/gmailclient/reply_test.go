@@ -0,0 +1,68 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestNewReply(t *testing.T) {
+	orig := &gmailclient.Message{
+		ThreadId:  "17a0",
+		From:      gmailclient.ParseAddressList("Ana Lima <ana@example.com>"),
+		To:        gmailclient.ParseAddressList("me@example.com, sam@example.com"),
+		Cc:        gmailclient.ParseAddressList("ops@example.com"),
+		Subject:   "Quarterly numbers",
+		Date:      time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+		BodyPlain: "Numbers attached.\n> earlier quote",
+		Headers: gmailclient.Header{
+			{Name: "Message-ID", Value: "<b@example.com>"},
+			{Name: "References", Value: "<a@example.com>"},
+		},
+	}
+
+	r := gmailclient.NewReply(orig, "Me@example.com", false)
+	if got := gmailclient.FormatAddressList(r.To); got != "Ana Lima <ana@example.com>" || len(r.Cc) != 0 {
+		t.Errorf("reply To = %q, Cc = %v", got, r.Cc)
+	}
+	if r.Subject != "Re: Quarterly numbers" {
+		t.Errorf("Subject = %q", r.Subject)
+	}
+	if r.Header.Get("In-Reply-To") != "<b@example.com>" || r.Header.Get("References") != "<a@example.com> <b@example.com>" {
+		t.Errorf("threading headers = %v", r.Header)
+	}
+
+	all := gmailclient.NewReply(orig, "me@example.com", true)
+	if got := gmailclient.FormatAddressList(all.Cc); got != "sam@example.com, ops@example.com" {
+		t.Errorf("reply-all Cc = %q", got)
+	}
+
+	orig.Subject = "RE: Quarterly numbers"
+	if r := gmailclient.NewReply(orig, "me@example.com", false); r.Subject != orig.Subject {
+		t.Errorf("Subject = %q, want it unchanged", r.Subject)
+	}
+
+	q := gmailclient.QuoteText(orig)
+	if !strings.HasPrefix(q, "On Mon, May 3, 2021 at 10:00 AM, Ana Lima <ana@example.com> wrote:\n> Numbers attached.\n>> earlier quote") {
+		t.Errorf("QuoteText = %q", q)
+	}
+}
@@ -0,0 +1,186 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how WithRetry retries failed requests.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+
+	// MinBackoff is the delay before the first retry. It doubles with every
+	// further retry up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used by NewService and the other service
+// constructors.
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 5, MinBackoff: 500 * time.Millisecond, MaxBackoff: 32 * time.Second}
+
+// WithRetry returns a copy of client that retries requests failing with a
+// network error, 429, 5xx, or a 403 rate limit error, with exponential
+// backoff and full jitter. A Retry-After header from the server takes
+// precedence over the computed delay. Requests whose body cannot be replayed
+// are not retried.
+//
+// POST and PATCH requests, such as sending a message or creating a label, are
+// only retried on 429 and rate limit errors, which the server returns before
+// acting on a request: after a network error or a 5xx the change may have
+// been made, and repeating it would send the message twice.
+func WithRetry(client *http.Client, p RetryPolicy) *http.Client {
+	c := *client
+	c.Transport = &retryTransport{base: client.Transport, policy: p}
+	return &c
+}
+
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if attempt >= t.policy.MaxRetries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+		delay := t.policy.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp); ok {
+				delay = d
+			}
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// backoff returns a random delay up to MinBackoff*2^attempt, capped at
+// MaxBackoff.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff << uint(attempt)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Do calls fn until it succeeds, retrying up to p.MaxRetries times with the
+// same backoff as WithRetry. It is meant for whole operations, such as
+// downloading a file, that can fail after their requests succeeded. It
+// returns the last error, or ctx's error if ctx is done while waiting.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether req, which ended with resp and err, is worth
+// retrying.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return idempotent(req)
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 500:
+		return idempotent(req)
+	case resp.StatusCode == http.StatusForbidden:
+		// Gmail reports per-user rate limits as 403 rateLimitExceeded or
+		// userRateLimitExceeded. Peek at the body and put it back.
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+		return bytes.Contains(b, []byte("RateLimitExceeded")) || bytes.Contains(b, []byte("rateLimitExceeded"))
+	}
+	return false
+}
+
+// idempotent reports whether req can be repeated without changing more than
+// one attempt would.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header of resp, in seconds or as an HTTP
+// date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,142 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestWithRetry(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if b, _ := ioutil.ReadAll(r.Body); string(b) != "payload" {
+			t.Errorf("attempt %d: body = %q", calls, b)
+		}
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": {"errors": [{"reason": "userRateLimitExceeded"}]}}`))
+		case 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer ts.Close()
+
+	p := gmailclient.RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	client := gmailclient.WithRetry(ts.Client(), p)
+	req, err := http.NewRequest("PUT", ts.URL, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 4 {
+		t.Errorf("status %d after %d calls, want 200 after 4", resp.StatusCode, calls)
+	}
+
+	calls = 0
+	p.MaxRetries = 1
+	resp, err = gmailclient.WithRetry(ts.Client(), p).Post(ts.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || calls != 2 {
+		t.Errorf("status %d after %d calls, want 403 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestWithRetryPost(t *testing.T) {
+	var statuses []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		w.WriteHeader(status)
+		if status == http.StatusForbidden {
+			w.Write([]byte(`{"error": {"errors": [{"reason": "rateLimitExceeded"}]}}`))
+		}
+	}))
+	defer ts.Close()
+	p := gmailclient.RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	client := gmailclient.WithRetry(ts.Client(), p)
+
+	for _, tt := range []struct {
+		statuses []int
+		want     int
+		calls    int
+	}{
+		// Rejected before the server acted on them: retried.
+		{[]int{429, 403, 200}, 200, 3},
+		// The server may have acted on them: returned as they are.
+		{[]int{502, 200}, 502, 1},
+		{[]int{500, 200}, 500, 1},
+		{[]int{429, 503, 200}, 503, 2},
+	} {
+		statuses = tt.statuses
+		resp, err := client.Post(ts.URL, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if calls := len(tt.statuses) - len(statuses); resp.StatusCode != tt.want || calls != tt.calls {
+			t.Errorf("POST with responses %v: status %d after %d calls, want %d after %d", tt.statuses, resp.StatusCode, calls, tt.want, tt.calls)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := gmailclient.RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Do = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return errors.New("broken")
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("Do = %v after %d calls, want an error after 3", err, calls)
+	}
+}
@@ -0,0 +1,109 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Google's token revocation and introspection endpoints.
+var (
+	RevokeURL    = "https://oauth2.googleapis.com/revoke"
+	TokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+)
+
+// RevokeToken revokes tok at Google, which invalidates its refresh token and
+// every access token issued from it. A token that is already invalid is not
+// an error.
+func RevokeToken(ctx context.Context, tok *oauth2.Token) error {
+	t := tok.RefreshToken
+	if t == "" {
+		t = tok.AccessToken
+	}
+	req, err := http.NewRequest("POST", RevokeURL, strings.NewReader(url.Values{"token": {t}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("RevokeToken: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error == "invalid_token" {
+		return nil
+	}
+	return fmt.Errorf("RevokeToken: %s: %s", resp.Status, body)
+}
+
+// TokenInfo describes a live access token as reported by Google.
+type TokenInfo struct {
+	Email  string
+	Scopes []string
+	Expiry time.Time
+}
+
+// LookupToken asks Google about the access token of tok.
+func LookupToken(ctx context.Context, tok *oauth2.Token) (*TokenInfo, error) {
+	req, err := http.NewRequest("GET", TokenInfoURL+"?"+url.Values{"access_token": {tok.AccessToken}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("LookupToken: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("LookupToken: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LookupToken: %s: %s", resp.Status, body)
+	}
+	var r struct {
+		Email string `json:"email"`
+		Scope string `json:"scope"`
+		Exp   string `json:"exp"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("LookupToken decode: %w", err)
+	}
+	info := &TokenInfo{Email: r.Email, Scopes: strings.Fields(r.Scope)}
+	if exp, err := strconv.ParseInt(r.Exp, 10, 64); err == nil {
+		info.Expiry = time.Unix(exp, 0)
+	}
+	return info, nil
+}
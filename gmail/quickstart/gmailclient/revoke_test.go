@@ -0,0 +1,55 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/oauth2"
+)
+
+func TestRevokeToken(t *testing.T) {
+	revoked := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok := r.FormValue("token")
+		if revoked[tok] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_token", "error_description": "Token expired or revoked"}`))
+			return
+		}
+		revoked[tok] = true
+	}))
+	defer ts.Close()
+	defer func(u string) { gmailclient.RevokeURL = u }(gmailclient.RevokeURL)
+	gmailclient.RevokeURL = ts.URL
+
+	tok := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+	if err := gmailclient.RevokeToken(context.Background(), tok); err != nil {
+		t.Fatal(err)
+	}
+	if !revoked["refresh"] {
+		t.Error("the refresh token was not revoked")
+	}
+	if err := gmailclient.RevokeToken(context.Background(), tok); err != nil {
+		t.Errorf("revoking twice: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// maxMIMEDepth bounds the nesting of multiparts and embedded messages.
+const maxMIMEDepth = 20
+
+// ParseRawMessage parses a raw RFC 822 message, such as one read from an
+// .eml file or an mbox, into a Message with id as its ID. Everything is in
+// raw, so nothing is fetched.
+func ParseRawMessage(ctx context.Context, id string, raw []byte) (*Message, error) {
+	payload, err := parseRFC822(raw, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ParseRawMessage: %w", err)
+	}
+	return ParseMessage(ctx, nil, &gmail.Message{Id: id, Payload: payload, SizeEstimate: int64(len(raw))}, "")
+}
+
+// parseRFC822 parses a raw RFC 822 message into the part tree Gmail returns
+// for the "full" format, with all bodies inlined, so it can go through
+// ParseMessage like any fetched message.
+func parseRFC822(raw []byte, depth int) (*gmail.MessagePart, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return parseMIMEPart(textproto.MIMEHeader(m.Header), m.Body, "", depth)
+}
+
+// parseMIMEPart converts one MIME entity with header h and body r.
+func parseMIMEPart(h textproto.MIMEHeader, r io.Reader, partID string, depth int) (*gmail.MessagePart, error) {
+	if depth > maxMIMEDepth {
+		return nil, fmt.Errorf("MIME structure nested more than %d levels", maxMIMEDepth)
+	}
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	part := &gmail.MessagePart{
+		PartId:   partID,
+		MimeType: mediaType,
+		Filename: attachmentName(h, params),
+		Body:     &gmail.MessagePartBody{},
+	}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			part.Headers = append(part.Headers, &gmail.MessagePartHeader{Name: name, Value: v})
+		}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for i := 0; ; i++ {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			id := fmt.Sprint(i)
+			if partID != "" {
+				id = partID + "." + id
+			}
+			child, err := parseMIMEPart(p.Header, p, id, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			part.Parts = append(part.Parts, child)
+		}
+		return part, nil
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(h.Get("Content-Transfer-Encoding"), "base64") {
+		clean := strings.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, string(data))
+		if data, err = base64.StdEncoding.DecodeString(clean); err != nil {
+			return nil, fmt.Errorf("part %s: %w", partID, err)
+		}
+	}
+	if mediaType == "message/rfc822" {
+		child, err := parseRFC822(data, depth+1)
+		if err == nil {
+			part.Parts = []*gmail.MessagePart{child}
+		}
+	}
+	part.Body.Size = int64(len(data))
+	part.Body.Data = base64.URLEncoding.EncodeToString(data)
+	return part, nil
+}
+
+// attachmentName returns the filename of a part from its Content-Disposition
+// or, failing that, the name parameter of its Content-Type.
+func attachmentName(h textproto.MIMEHeader, typeParams map[string]string) string {
+	if _, p, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && p["filename"] != "" {
+		return DecodeHeader(p["filename"])
+	}
+	return DecodeHeader(typeParams["name"])
+}
@@ -0,0 +1,237 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+
+	"google.golang.org/api/gmail/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is one rule of a rules file, in the spirit of procmail: a message
+// that meets all of its conditions gets all of its actions. Unlike Gmail
+// filters, rules run locally, so they can match the body with regular
+// expressions and act in ways Gmail cannot, such as saving attachments or
+// calling a webhook.
+type Rule struct {
+	Name string `yaml:"name"`
+
+	// Conditions. From, To, Subject and Body are regular expressions; From
+	// and To match any one address, as "Name <addr>", To also matching Cc.
+	// Body matches the plain text body, or the text of the HTML body. Labels
+	// must all be on the message, by name or ID.
+	From          string   `yaml:"from,omitempty"`
+	To            string   `yaml:"to,omitempty"`
+	Subject       string   `yaml:"subject,omitempty"`
+	Body          string   `yaml:"body,omitempty"`
+	Labels        []string `yaml:"labels,omitempty"`
+	LargerThan    int64    `yaml:"larger_than,omitempty"`
+	SmallerThan   int64    `yaml:"smaller_than,omitempty"`
+	HasAttachment bool     `yaml:"has_attachment,omitempty"`
+
+	// Actions.
+	AddLabels       []string `yaml:"add_labels,omitempty"`
+	RemoveLabels    []string `yaml:"remove_labels,omitempty"`
+	Archive         bool     `yaml:"archive,omitempty"`
+	MarkRead        bool     `yaml:"mark_read,omitempty"`
+	Star            bool     `yaml:"star,omitempty"`
+	Trash           bool     `yaml:"trash,omitempty"`
+	Forward         string   `yaml:"forward,omitempty"`
+	SaveAttachments string   `yaml:"save_attachments,omitempty"`
+	Webhook         string   `yaml:"webhook,omitempty"`
+
+	// Stop keeps the rules after this one from seeing the messages it
+	// matches.
+	Stop bool `yaml:"stop,omitempty"`
+
+	from, to, subject, body *regexp.Regexp
+	forward                 []*mail.Address
+}
+
+// ReadRules reads a rules file: a YAML document with a list of rules under
+// "rules", applied in order. Every rule needs at least one condition and one
+// action.
+func ReadRules(r io.Reader) ([]*Rule, error) {
+	var f struct {
+		Rules []*Rule `yaml:"rules"`
+	}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("ReadRules: %w", err)
+	}
+	for i, rule := range f.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("ReadRules: %s: %w", rule.Name, err)
+		}
+	}
+	return f.Rules, nil
+}
+
+// compile compiles the regular expressions of r, parses the addresses to
+// forward to and checks that it has conditions and actions.
+func (r *Rule) compile() error {
+	for _, re := range []struct {
+		name string
+		expr string
+		dst  **regexp.Regexp
+	}{
+		{"from", r.From, &r.from},
+		{"to", r.To, &r.to},
+		{"subject", r.Subject, &r.subject},
+		{"body", r.Body, &r.body},
+	} {
+		if re.expr == "" {
+			continue
+		}
+		var err error
+		if *re.dst, err = regexp.Compile(re.expr); err != nil {
+			return fmt.Errorf("%s: %w", re.name, err)
+		}
+	}
+	if r.Forward != "" {
+		var err error
+		if r.forward, err = mail.ParseAddressList(r.Forward); err != nil {
+			return fmt.Errorf("forward: %w", err)
+		}
+	}
+	if r.from == nil && r.to == nil && r.subject == nil && r.body == nil && len(r.Labels) == 0 &&
+		r.LargerThan == 0 && r.SmallerThan == 0 && !r.HasAttachment {
+		return fmt.Errorf("no conditions")
+	}
+	add, remove := r.LabelChanges()
+	if len(add) == 0 && len(remove) == 0 && !r.Trash && r.Forward == "" && r.SaveAttachments == "" && r.Webhook == "" {
+		return fmt.Errorf("no actions")
+	}
+	return nil
+}
+
+// ForwardTo returns the addresses r forwards messages to, if any.
+func (r *Rule) ForwardTo() []*mail.Address {
+	return r.forward
+}
+
+// Match reports whether m meets the conditions of r. labels are the
+// mailbox's labels, to look the names in r.Labels up in.
+func (r *Rule) Match(m *Message, labels []*gmail.Label) bool {
+	if r.from != nil && !matchAddress(r.from, m.From) {
+		return false
+	}
+	if r.to != nil && !matchAddress(r.to, m.To) && !matchAddress(r.to, m.Cc) {
+		return false
+	}
+	if r.subject != nil && !r.subject.MatchString(m.Subject) {
+		return false
+	}
+	if r.body != nil && !r.body.MatchString(plainBody(m)) {
+		return false
+	}
+	for _, name := range r.Labels {
+		l, err := FindLabel(labels, name)
+		if err != nil || !hasString(m.LabelIds, l.Id) {
+			return false
+		}
+	}
+	if r.LargerThan > 0 && m.Size <= r.LargerThan {
+		return false
+	}
+	if r.SmallerThan > 0 && m.Size >= r.SmallerThan {
+		return false
+	}
+	if r.HasAttachment && len(m.Attachments) == 0 {
+		return false
+	}
+	return true
+}
+
+// LabelChanges returns the labels r adds and removes, by name, including
+// the system labels behind Archive, MarkRead and Star.
+func (r *Rule) LabelChanges() (add, remove []string) {
+	add = append(add, r.AddLabels...)
+	remove = append(remove, r.RemoveLabels...)
+	if r.Archive {
+		remove = append(remove, "INBOX")
+	}
+	if r.MarkRead {
+		remove = append(remove, "UNREAD")
+	}
+	if r.Star {
+		add = append(add, "STARRED")
+	}
+	return add, remove
+}
+
+// MatchRules returns the rules m matches, in order, up to and including
+// the first matching rule with Stop set.
+func MatchRules(rules []*Rule, m *Message, labels []*gmail.Label) []*Rule {
+	var matched []*Rule
+	for _, r := range rules {
+		if !r.Match(m, labels) {
+			continue
+		}
+		matched = append(matched, r)
+		if r.Stop {
+			break
+		}
+	}
+	return matched
+}
+
+// matchAddress reports whether re matches an address of list, written as
+// "Name <addr>" without encoding the name.
+func matchAddress(re *regexp.Regexp, list []*mail.Address) bool {
+	for _, a := range list {
+		s := a.Address
+		if a.Name != "" {
+			s = a.Name + " <" + a.Address + ">"
+		}
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// plainBody returns the plain text body of m, converting the HTML body if
+// there is no plain one.
+func plainBody(m *Message) string {
+	if m.BodyPlain != "" || m.BodyHtml == "" {
+		return m.BodyPlain
+	}
+	text, err := HTMLToText(m.BodyHtml)
+	if err != nil {
+		return m.BodyHtml
+	}
+	return text
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
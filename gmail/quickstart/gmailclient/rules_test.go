@@ -0,0 +1,109 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+const testRules = `
+rules:
+  - name: invoices
+    from: '(?i)@billing\.example\.com>?$'
+    has_attachment: true
+    add_labels: [Receipts]
+    archive: true
+    save_attachments: /tmp/invoices
+    stop: true
+  - name: big
+    larger_than: 1000000
+    labels: [Receipts]
+    star: true
+  - name: outage
+    subject: '(?i)\boutage\b'
+    body: 'severity: (1|2)'
+    webhook: https://hooks.example.com/outage
+    forward: Ops <ops@example.com>, oncall@example.com
+`
+
+func TestMatchRules(t *testing.T) {
+	rules, err := gmailclient.ReadRules(strings.NewReader(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := []*gmail.Label{{Id: "INBOX", Name: "INBOX"}, {Id: "Label_1", Name: "Receipts"}}
+
+	invoice := &gmailclient.Message{
+		From:        []*mail.Address{{Name: "Billing", Address: "no-reply@Billing.example.com"}},
+		LabelIds:    []string{"INBOX", "Label_1"},
+		Size:        2000000,
+		Attachments: []*gmailclient.Attachment{{Filename: "invoice.pdf"}},
+	}
+	if got := names(gmailclient.MatchRules(rules, invoice, labels)); got != "invoices" {
+		t.Errorf("invoice matched %q, want invoices and then stop", got)
+	}
+	invoice.Attachments = nil
+	if got := names(gmailclient.MatchRules(rules, invoice, labels)); got != "big" {
+		t.Errorf("invoice without attachments matched %q, want big", got)
+	}
+
+	outage := &gmailclient.Message{Subject: "Outage in eu-west", BodyHtml: "<p>severity: 2</p>"}
+	if got := names(gmailclient.MatchRules(rules, outage, labels)); got != "outage" {
+		t.Errorf("outage matched %q, want outage by its HTML body", got)
+	}
+	outage.BodyHtml = "<p>severity: 3</p>"
+	if got := names(gmailclient.MatchRules(rules, outage, labels)); got != "" {
+		t.Errorf("minor outage matched %q", got)
+	}
+
+	add, remove := rules[0].LabelChanges()
+	if strings.Join(add, ",") != "Receipts" || strings.Join(remove, ",") != "INBOX" {
+		t.Errorf("LabelChanges = %v, %v", add, remove)
+	}
+	if to := rules[2].ForwardTo(); len(to) != 2 || to[0].Address != "ops@example.com" || to[1].Address != "oncall@example.com" {
+		t.Errorf("ForwardTo = %v", to)
+	}
+}
+
+func TestReadRulesErrors(t *testing.T) {
+	for _, rules := range []string{
+		"rules:\n  - subject: '('\n    star: true\n",
+		"rules:\n  - star: true\n",
+		"rules:\n  - subject: x\n",
+		"rules:\n  - subject: x\n    stars: true\n",
+		"rules:\n  - subject: x\n    forward: not an address\n",
+		"rules:\n  - subject: x\n    forward: \"ana@example.com\\r\\nBcc: eve@example.com\"\n",
+	} {
+		if _, err := gmailclient.ReadRules(strings.NewReader(rules)); err == nil {
+			t.Errorf("ReadRules(%q) succeeded", rules)
+		}
+	}
+}
+
+func names(rules []*gmailclient.Rule) string {
+	var s []string
+	for _, r := range rules {
+		s = append(s, r.Name)
+	}
+	return strings.Join(s, ",")
+}
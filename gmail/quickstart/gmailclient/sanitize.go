@@ -0,0 +1,157 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// sanitizeDrop lists the elements removed together with their content.
+var sanitizeDrop = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Head: true, atom.Title: true,
+	atom.Iframe: true, atom.Frame: true, atom.Frameset: true, atom.Object: true,
+	atom.Embed: true, atom.Applet: true, atom.Noscript: true, atom.Template: true,
+	atom.Form: true, atom.Input: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Svg: true, atom.Math: true, atom.Link: true,
+	atom.Meta: true, atom.Base: true,
+}
+
+// sanitizeAllow lists the elements kept. Other elements are replaced by their
+// content.
+var sanitizeAllow = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Blockquote: true,
+	atom.Br: true, atom.Caption: true, atom.Center: true, atom.Code: true,
+	atom.Col: true, atom.Colgroup: true, atom.Dd: true, atom.Del: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Em: true,
+	atom.Font: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true,
+	atom.I: true, atom.Img: true, atom.Ins: true, atom.Kbd: true,
+	atom.Li: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Q: true, atom.S: true, atom.Small: true, atom.Span: true,
+	atom.Strike: true, atom.Strong: true, atom.Sub: true, atom.Sup: true,
+	atom.Table: true, atom.Tbody: true, atom.Td: true, atom.Tfoot: true,
+	atom.Th: true, atom.Thead: true, atom.Tr: true, atom.Tt: true,
+	atom.U: true, atom.Ul: true,
+}
+
+// sanitizeAttrs lists the attributes kept on any allowed element, besides
+// href on links and src on images, which are checked separately.
+var sanitizeAttrs = map[string]bool{
+	"align": true, "alt": true, "bgcolor": true, "border": true,
+	"cellpadding": true, "cellspacing": true, "color": true, "colspan": true,
+	"dir": true, "face": true, "height": true, "lang": true, "rowspan": true,
+	"size": true, "start": true, "style": true, "title": true, "valign": true,
+	"width": true,
+}
+
+// SanitizeHTML returns the content of an HTML e-mail body reduced to safe
+// formatting: scripts, styles sheets, frames, forms and event handlers are
+// removed, links may only point to http, https and mailto URLs, and images
+// only to http, https, data:image or relative URLs. The result is a fragment
+// meant to be placed inside another page's body.
+func SanitizeHTML(s string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return "", fmt.Errorf("SanitizeHTML: %w", err)
+	}
+	var b strings.Builder
+	sanitizeNode(&b, doc)
+	return b.String(), nil
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+		if sanitizeDrop[n.DataAtom] {
+			return
+		}
+		if sanitizeAllow[n.DataAtom] {
+			b.WriteByte('<')
+			b.WriteString(n.Data)
+			for _, a := range sanitizeAttributes(n) {
+				fmt.Fprintf(b, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+			}
+			b.WriteByte('>')
+			if isVoid(n.DataAtom) {
+				return
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				sanitizeNode(b, c)
+			}
+			b.WriteString("</" + n.Data + ">")
+			return
+		}
+	case html.CommentNode, html.DoctypeNode:
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c)
+	}
+}
+
+// sanitizeAttributes returns the attributes of n that SanitizeHTML keeps.
+func sanitizeAttributes(n *html.Node) []html.Attribute {
+	var attrs []html.Attribute
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		switch {
+		case a.Namespace != "":
+		case key == "href" && n.DataAtom == atom.A:
+			if safeURL(a.Val, "http:", "https:", "mailto:", "#") {
+				attrs = append(attrs, html.Attribute{Key: key, Val: a.Val},
+					html.Attribute{Key: "rel", Val: "noopener noreferrer"})
+			}
+		case key == "src" && n.DataAtom == atom.Img:
+			if safeURL(a.Val, "http:", "https:", "data:image/") || !strings.Contains(a.Val, ":") {
+				attrs = append(attrs, html.Attribute{Key: key, Val: a.Val})
+			}
+		case key == "style":
+			v := strings.ToLower(a.Val)
+			if !strings.Contains(v, "url(") && !strings.Contains(v, "expression") && !strings.Contains(v, "javascript:") {
+				attrs = append(attrs, html.Attribute{Key: key, Val: a.Val})
+			}
+		case sanitizeAttrs[key]:
+			attrs = append(attrs, html.Attribute{Key: key, Val: a.Val})
+		}
+	}
+	return attrs
+}
+
+// safeURL reports whether u starts with one of the prefixes, ignoring case
+// and leading spaces.
+func safeURL(u string, prefixes ...string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	for _, p := range prefixes {
+		if strings.HasPrefix(u, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVoid reports whether elements of type a have no content or end tag.
+func isVoid(a atom.Atom) bool {
+	return a == atom.Br || a == atom.Hr || a == atom.Img || a == atom.Col
+}
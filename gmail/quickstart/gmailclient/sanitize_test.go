@@ -0,0 +1,48 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{`<p>Hello <b>there</b></p>`, `<p>Hello <b>there</b></p>`},
+		{`<html><head><title>x</title><style>p{}</style></head><body><p>Hi</p></body></html>`, `<p>Hi</p>`},
+		{`<p onclick="evil()">a<script>evil()</script></p>`, `<p>a</p>`},
+		{`<a href="javascript:evil()">x</a>`, `<a>x</a>`},
+		{`<a href="https://example.com/?a=1&b=2">x</a>`, `<a href="https://example.com/?a=1&amp;b=2" rel="noopener noreferrer">x</a>`},
+		{`<img src="data:image/png;base64,AA"><img src="data:text/html,x"><img src="logo.png">`, `<img src="data:image/png;base64,AA"><img><img src="logo.png">`},
+		{`<div style="background:url(https://t.example/p.gif)">x</div>`, `<div>x</div>`},
+		{`<blink>old</blink><!-- hidden -->`, `old`},
+		{`<iframe src="https://example.com"></iframe>1 &lt; 2`, `1 &lt; 2`},
+	} {
+		got, err := gmailclient.SanitizeHTML(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("SanitizeHTML(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
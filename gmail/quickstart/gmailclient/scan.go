@@ -0,0 +1,137 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// A Scanner checks data for malware, such as attachments before they are
+// written to disk.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (ScanResult, error)
+}
+
+// ScanResult is the verdict of a Scanner.
+type ScanResult struct {
+	Infected  bool
+	Signature string // the name of what was found, if Infected
+}
+
+// Clamd is a Scanner that streams data to a ClamAV daemon with its INSTREAM
+// command.
+type Clamd struct {
+	Network string // "unix" or "tcp"
+	Address string
+
+	// Timeout bounds a whole scan when ctx has no earlier deadline. Zero
+	// means a minute.
+	Timeout time.Duration
+}
+
+// NewClamd returns a Clamd for addr: a Unix socket as "unix:///path" or a
+// plain path, or a TCP address as "tcp://host:port" or "host:port".
+func NewClamd(addr string) (*Clamd, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return &Clamd{Network: "unix", Address: strings.TrimPrefix(addr, "unix://")}, nil
+	case strings.HasPrefix(addr, "tcp://"):
+		return &Clamd{Network: "tcp", Address: strings.TrimPrefix(addr, "tcp://")}, nil
+	case strings.HasPrefix(addr, "/"):
+		return &Clamd{Network: "unix", Address: addr}, nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("NewClamd: invalid address %q (want unix:///path or tcp://host:port)", addr)
+	}
+	return &Clamd{Network: "tcp", Address: addr}, nil
+}
+
+// clamdChunk is the size of the chunks sent to clamd. clamd rejects streams
+// longer than its StreamMaxLength, 25 MB by default.
+const clamdChunk = 64 << 10
+
+// Scan sends the data of r to clamd and returns its verdict.
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("Clamd.Scan dial: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return ScanResult{}, fmt.Errorf("Clamd.Scan: %w", err)
+	}
+	buf := make([]byte, 4+clamdChunk)
+	for {
+		n, rerr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection when the stream is
+				// too long; its reply says so.
+				break
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			binary.BigEndian.PutUint32(buf, 0)
+			conn.Write(buf[:4])
+			break
+		}
+		if rerr != nil {
+			return ScanResult{}, fmt.Errorf("Clamd.Scan read: %w", rerr)
+		}
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return ScanResult{}, fmt.Errorf("Clamd.Scan reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply parses "stream: OK", "stream: <signature> FOUND" or
+// "<message> ERROR".
+func parseClamdReply(reply string) (ScanResult, error) {
+	reply = strings.TrimRight(reply, "\x00\n")
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return ScanResult{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return ScanResult{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	}
+	return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+}
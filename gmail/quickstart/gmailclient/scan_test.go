@@ -0,0 +1,94 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+// fakeClamd answers INSTREAM commands, flagging streams that contain
+// "EICAR".
+func fakeClamd(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+				var data []byte
+				for {
+					var n uint32
+					if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+						return
+					}
+					if n == 0 {
+						break
+					}
+					chunk := make([]byte, n)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				if bytes.Contains(data, []byte("EICAR")) {
+					io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+					return
+				}
+				io.WriteString(conn, "stream: OK\x00")
+			}(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestClamd(t *testing.T) {
+	c, err := gmailclient.NewClamd("tcp://" + fakeClamd(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	res, err := c.Scan(ctx, strings.NewReader(strings.Repeat("invoice ", 20000)))
+	if err != nil || res.Infected {
+		t.Errorf("Scan(clean) = %+v, %v; want clean", res, err)
+	}
+	res, err = c.Scan(ctx, strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+	if err != nil || !res.Infected || res.Signature != "Eicar-Test-Signature" {
+		t.Errorf("Scan(EICAR) = %+v, %v; want Eicar-Test-Signature", res, err)
+	}
+}
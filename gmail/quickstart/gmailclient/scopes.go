@@ -0,0 +1,83 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// storedToken is the JSON form of a token in every TokenStore. Besides the
+// oauth2.Token fields it records the scopes the user granted, which the token
+// endpoint only reports when the token is issued.
+type storedToken struct {
+	*oauth2.Token
+	Scope string `json:"scope,omitempty"`
+}
+
+// encodeToken returns the JSON form of tok, including its granted scopes.
+func encodeToken(tok *oauth2.Token) ([]byte, error) {
+	return json.Marshal(storedToken{Token: tok, Scope: strings.Join(GrantedScopes(tok), " ")})
+}
+
+// decodeToken parses a token written by encodeToken. Tokens saved before the
+// scopes were recorded decode without them.
+func decodeToken(b []byte) (*oauth2.Token, error) {
+	st := storedToken{Token: &oauth2.Token{}}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, err
+	}
+	if st.Scope == "" {
+		return st.Token, nil
+	}
+	return WithScopes(st.Token, strings.Fields(st.Scope)), nil
+}
+
+// GrantedScopes returns the scopes granted to tok, or nil if they are
+// unknown.
+func GrantedScopes(tok *oauth2.Token) []string {
+	s, _ := tok.Extra("scope").(string)
+	return strings.Fields(s)
+}
+
+// WithScopes returns a copy of tok recording scopes as granted.
+func WithScopes(tok *oauth2.Token, scopes []string) *oauth2.Token {
+	return tok.WithExtra(map[string]interface{}{"scope": strings.Join(scopes, " ")})
+}
+
+// MissingScopes returns the scopes in want that were not granted to tok. It
+// returns nil if the granted scopes are unknown.
+func MissingScopes(tok *oauth2.Token, want []string) []string {
+	granted := GrantedScopes(tok)
+	if granted == nil {
+		return nil
+	}
+	have := map[string]bool{}
+	for _, s := range granted {
+		have[s] = true
+	}
+	var missing []string
+	for _, s := range want {
+		if !have[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,479 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bytes"
+	"context"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// GmailService is the part of the Gmail API used to read a mailbox. The
+// functions that change messages, labels, drafts, filters and settings take
+// the smaller interfaces below instead, each covering one feature. All of
+// them are satisfied by *Client for live calls and by gmailclienttest.Service
+// for tests.
+type GmailService interface {
+	// GetMessage fetches a message in the given format ("full", "metadata",
+	// "minimal" or "raw"). If fields are given only those parts of the
+	// message are returned, as with the API's fields parameter.
+	GetMessage(ctx context.Context, user, id, format string, fields ...googleapi.Field) (*gmail.Message, error)
+
+	// GetMessageMetadata fetches a message in the "metadata" format with
+	// only the named headers, which is much cheaper than the full message.
+	GetMessageMetadata(ctx context.Context, user, id string, headers []string, fields ...googleapi.Field) (*gmail.Message, error)
+
+	// ListMessages returns one page of messages matching query.
+	ListMessages(ctx context.Context, user, query, pageToken string, maxResults int64) (*gmail.ListMessagesResponse, error)
+
+	// GetAttachment fetches the body of an attachment.
+	GetAttachment(ctx context.Context, user, messageId, attachmentId string) (*gmail.MessagePartBody, error)
+
+	// GetProfile returns the mailbox profile, including its current
+	// history ID.
+	GetProfile(ctx context.Context, user string) (*gmail.Profile, error)
+
+	// ListHistory returns one page of the changes to the mailbox after
+	// startHistoryId. It fails with a 404 *googleapi.Error if
+	// startHistoryId is too old.
+	ListHistory(ctx context.Context, user string, startHistoryId uint64, pageToken string) (*gmail.ListHistoryResponse, error)
+}
+
+// ModifyService changes the labels of messages and threads, and trashes and
+// deletes messages.
+type ModifyService interface {
+	ModifyMessage(ctx context.Context, user, id string, req *gmail.ModifyMessageRequest) (*gmail.Message, error)
+	BatchModifyMessages(ctx context.Context, user string, req *gmail.BatchModifyMessagesRequest) error
+	BatchDeleteMessages(ctx context.Context, user string, ids []string) error
+	TrashMessage(ctx context.Context, user, id string) (*gmail.Message, error)
+	UntrashMessage(ctx context.Context, user, id string) (*gmail.Message, error)
+	ModifyThread(ctx context.Context, user, id string, req *gmail.ModifyThreadRequest) (*gmail.Thread, error)
+}
+
+// SendService sends messages uploaded as RFC 5322 raw, in the thread
+// threadId if it is set.
+type SendService interface {
+	SendMessage(ctx context.Context, user string, raw []byte, threadId string) (*gmail.Message, error)
+}
+
+// ThreadService lists and fetches threads. ListThreads returns one page of
+// threads matching query, and GetThread only includes the named headers in
+// the "metadata" format, or all of them if there are none.
+type ThreadService interface {
+	ListThreads(ctx context.Context, user, query, pageToken string, maxResults int64) (*gmail.ListThreadsResponse, error)
+	GetThread(ctx context.Context, user, id, format string, headers ...string) (*gmail.Thread, error)
+}
+
+// LabelService manages the labels of a mailbox. PatchLabel changes the
+// fields set in l.
+type LabelService interface {
+	ListLabels(ctx context.Context, user string) ([]*gmail.Label, error)
+	GetLabel(ctx context.Context, user, id string) (*gmail.Label, error)
+	CreateLabel(ctx context.Context, user string, l *gmail.Label) (*gmail.Label, error)
+	PatchLabel(ctx context.Context, user, id string, l *gmail.Label) (*gmail.Label, error)
+	DeleteLabel(ctx context.Context, user, id string) error
+}
+
+// DraftService manages drafts, whose messages are uploaded as RFC 5322 raw.
+// ListDrafts returns one page of drafts matching query, all of them if it is
+// empty.
+type DraftService interface {
+	CreateDraft(ctx context.Context, user string, raw []byte, threadId string) (*gmail.Draft, error)
+	UpdateDraft(ctx context.Context, user, id string, raw []byte, threadId string) (*gmail.Draft, error)
+	GetDraft(ctx context.Context, user, id, format string) (*gmail.Draft, error)
+	ListDrafts(ctx context.Context, user, query, pageToken string) (*gmail.ListDraftsResponse, error)
+	SendDraft(ctx context.Context, user, id string) (*gmail.Message, error)
+	DeleteDraft(ctx context.Context, user, id string) error
+}
+
+// FilterService manages the filters of a mailbox.
+type FilterService interface {
+	ListFilters(ctx context.Context, user string) ([]*gmail.Filter, error)
+	CreateFilter(ctx context.Context, user string, f *gmail.Filter) (*gmail.Filter, error)
+	DeleteFilter(ctx context.Context, user, id string) error
+}
+
+// VacationService reads and changes the vacation responder.
+type VacationService interface {
+	GetVacation(ctx context.Context, user string) (*gmail.VacationSettings, error)
+	UpdateVacation(ctx context.Context, user string, v *gmail.VacationSettings) (*gmail.VacationSettings, error)
+}
+
+// SendAsService manages the send-as aliases of a mailbox. PatchSendAs
+// changes the fields set in s.
+type SendAsService interface {
+	ListSendAs(ctx context.Context, user string) ([]*gmail.SendAs, error)
+	PatchSendAs(ctx context.Context, user, email string, s *gmail.SendAs) (*gmail.SendAs, error)
+	GetSendAs(ctx context.Context, user, email string) (*gmail.SendAs, error)
+	CreateSendAs(ctx context.Context, user string, s *gmail.SendAs) (*gmail.SendAs, error)
+	DeleteSendAs(ctx context.Context, user, email string) error
+	VerifySendAs(ctx context.Context, user, email string) error
+}
+
+// ForwardingService manages the forwarding addresses of a mailbox and
+// automatic forwarding to one of them.
+type ForwardingService interface {
+	GetAutoForwarding(ctx context.Context, user string) (*gmail.AutoForwarding, error)
+	UpdateAutoForwarding(ctx context.Context, user string, f *gmail.AutoForwarding) (*gmail.AutoForwarding, error)
+	ListForwardingAddresses(ctx context.Context, user string) ([]*gmail.ForwardingAddress, error)
+	CreateForwardingAddress(ctx context.Context, user string, f *gmail.ForwardingAddress) (*gmail.ForwardingAddress, error)
+	DeleteForwardingAddress(ctx context.Context, user, email string) error
+}
+
+// ImapPopService reads and changes the IMAP and POP settings.
+type ImapPopService interface {
+	GetImap(ctx context.Context, user string) (*gmail.ImapSettings, error)
+	UpdateImap(ctx context.Context, user string, s *gmail.ImapSettings) (*gmail.ImapSettings, error)
+	GetPop(ctx context.Context, user string) (*gmail.PopSettings, error)
+	UpdatePop(ctx context.Context, user string, s *gmail.PopSettings) (*gmail.PopSettings, error)
+}
+
+// DelegateService manages the delegates of a mailbox.
+type DelegateService interface {
+	ListDelegates(ctx context.Context, user string) ([]*gmail.Delegate, error)
+	CreateDelegate(ctx context.Context, user string, d *gmail.Delegate) (*gmail.Delegate, error)
+	DeleteDelegate(ctx context.Context, user, email string) error
+}
+
+// LanguageService reads and changes the display language.
+type LanguageService interface {
+	GetLanguage(ctx context.Context, user string) (*gmail.LanguageSettings, error)
+	UpdateLanguage(ctx context.Context, user string, l *gmail.LanguageSettings) (*gmail.LanguageSettings, error)
+}
+
+// SmimeService manages the S/MIME certificates of the send-as aliases.
+type SmimeService interface {
+	ListSmimeInfo(ctx context.Context, user, email string) ([]*gmail.SmimeInfo, error)
+	InsertSmimeInfo(ctx context.Context, user, email string, info *gmail.SmimeInfo) (*gmail.SmimeInfo, error)
+	SetDefaultSmimeInfo(ctx context.Context, user, email, id string) error
+	DeleteSmimeInfo(ctx context.Context, user, email, id string) error
+}
+
+// HeaderFields is a fields projection for listings that need the headers,
+// labels and snippet of a message but not its body.
+var HeaderFields = []googleapi.Field{"id", "threadId", "labelIds", "snippet", "internalDate", "payload/headers"}
+
+// StructureFields is a fields projection for the "full" format that keeps
+// the headers and the part tree, four levels deep, but no body data. It is
+// enough for ParseMessage to fill in everything but the bodies.
+var StructureFields = []googleapi.Field{"id", "threadId", "labelIds", "sizeEstimate", "internalDate",
+	"payload(mimeType,filename,headers,body/size,body/attachmentId," +
+		"parts(mimeType,filename,headers,body/size,body/attachmentId," +
+		"parts(mimeType,filename,headers,body/size,body/attachmentId," +
+		"parts(mimeType,filename,headers,body/size,body/attachmentId))))"}
+
+// Client implements GmailService and the feature interfaces on top of a
+// *gmail.Service.
+type Client struct {
+	Srv *gmail.Service
+}
+
+// NewClient returns a Client that issues calls through srv.
+func NewClient(srv *gmail.Service) *Client {
+	return &Client{Srv: srv}
+}
+
+func (c *Client) GetMessage(ctx context.Context, user, id, format string, fields ...googleapi.Field) (*gmail.Message, error) {
+	call := c.Srv.Users.Messages.Get(user, id).Format(format)
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (c *Client) GetMessageMetadata(ctx context.Context, user, id string, headers []string, fields ...googleapi.Field) (*gmail.Message, error) {
+	call := c.Srv.Users.Messages.Get(user, id).Format("metadata").MetadataHeaders(headers...)
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (c *Client) ListMessages(ctx context.Context, user, query, pageToken string, maxResults int64) (*gmail.ListMessagesResponse, error) {
+	call := c.Srv.Users.Messages.List(user).Q(query)
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	if maxResults > 0 {
+		call = call.MaxResults(maxResults)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (c *Client) GetAttachment(ctx context.Context, user, messageId, attachmentId string) (*gmail.MessagePartBody, error) {
+	return c.Srv.Users.Messages.Attachments.Get(user, messageId, attachmentId).Context(ctx).Do()
+}
+
+func (c *Client) GetProfile(ctx context.Context, user string) (*gmail.Profile, error) {
+	return c.Srv.Users.GetProfile(user).Context(ctx).Do()
+}
+
+func (c *Client) ListHistory(ctx context.Context, user string, startHistoryId uint64, pageToken string) (*gmail.ListHistoryResponse, error) {
+	call := c.Srv.Users.History.List(user).StartHistoryId(startHistoryId).MaxResults(maxPageSize)
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (c *Client) ModifyMessage(ctx context.Context, user, id string, req *gmail.ModifyMessageRequest) (*gmail.Message, error) {
+	return c.Srv.Users.Messages.Modify(user, id, req).Context(ctx).Do()
+}
+
+func (c *Client) BatchModifyMessages(ctx context.Context, user string, req *gmail.BatchModifyMessagesRequest) error {
+	return c.Srv.Users.Messages.BatchModify(user, req).Context(ctx).Do()
+}
+
+func (c *Client) BatchDeleteMessages(ctx context.Context, user string, ids []string) error {
+	return c.Srv.Users.Messages.BatchDelete(user, &gmail.BatchDeleteMessagesRequest{Ids: ids}).Context(ctx).Do()
+}
+
+func (c *Client) TrashMessage(ctx context.Context, user, id string) (*gmail.Message, error) {
+	return c.Srv.Users.Messages.Trash(user, id).Context(ctx).Do()
+}
+
+func (c *Client) UntrashMessage(ctx context.Context, user, id string) (*gmail.Message, error) {
+	return c.Srv.Users.Messages.Untrash(user, id).Context(ctx).Do()
+}
+
+// SendMessage uploads raw as media, so it may be up to Gmail's 35 MB limit.
+func (c *Client) SendMessage(ctx context.Context, user string, raw []byte, threadId string) (*gmail.Message, error) {
+	return c.Srv.Users.Messages.Send(user, &gmail.Message{ThreadId: threadId}).
+		Media(bytes.NewReader(raw), googleapi.ContentType("message/rfc822")).
+		Context(ctx).Do()
+}
+
+func (c *Client) ListThreads(ctx context.Context, user, query, pageToken string, maxResults int64) (*gmail.ListThreadsResponse, error) {
+	call := c.Srv.Users.Threads.List(user).Q(query)
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	if maxResults > 0 {
+		call = call.MaxResults(maxResults)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (c *Client) GetThread(ctx context.Context, user, id, format string, headers ...string) (*gmail.Thread, error) {
+	call := c.Srv.Users.Threads.Get(user, id).Format(format)
+	if len(headers) > 0 {
+		call = call.MetadataHeaders(headers...)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (c *Client) ModifyThread(ctx context.Context, user, id string, req *gmail.ModifyThreadRequest) (*gmail.Thread, error) {
+	return c.Srv.Users.Threads.Modify(user, id, req).Context(ctx).Do()
+}
+
+func (c *Client) ListLabels(ctx context.Context, user string) ([]*gmail.Label, error) {
+	r, err := c.Srv.Users.Labels.List(user).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return r.Labels, nil
+}
+
+func (c *Client) GetLabel(ctx context.Context, user, id string) (*gmail.Label, error) {
+	return c.Srv.Users.Labels.Get(user, id).Context(ctx).Do()
+}
+
+func (c *Client) CreateLabel(ctx context.Context, user string, l *gmail.Label) (*gmail.Label, error) {
+	return c.Srv.Users.Labels.Create(user, l).Context(ctx).Do()
+}
+
+func (c *Client) PatchLabel(ctx context.Context, user, id string, l *gmail.Label) (*gmail.Label, error) {
+	return c.Srv.Users.Labels.Patch(user, id, l).Context(ctx).Do()
+}
+
+func (c *Client) DeleteLabel(ctx context.Context, user, id string) error {
+	return c.Srv.Users.Labels.Delete(user, id).Context(ctx).Do()
+}
+
+func (c *Client) CreateDraft(ctx context.Context, user string, raw []byte, threadId string) (*gmail.Draft, error) {
+	return c.Srv.Users.Drafts.Create(user, &gmail.Draft{Message: &gmail.Message{ThreadId: threadId}}).
+		Media(bytes.NewReader(raw), googleapi.ContentType("message/rfc822")).
+		Context(ctx).Do()
+}
+
+func (c *Client) UpdateDraft(ctx context.Context, user, id string, raw []byte, threadId string) (*gmail.Draft, error) {
+	return c.Srv.Users.Drafts.Update(user, id, &gmail.Draft{Id: id, Message: &gmail.Message{ThreadId: threadId}}).
+		Media(bytes.NewReader(raw), googleapi.ContentType("message/rfc822")).
+		Context(ctx).Do()
+}
+
+func (c *Client) GetDraft(ctx context.Context, user, id, format string) (*gmail.Draft, error) {
+	return c.Srv.Users.Drafts.Get(user, id).Format(format).Context(ctx).Do()
+}
+
+func (c *Client) ListDrafts(ctx context.Context, user, query, pageToken string) (*gmail.ListDraftsResponse, error) {
+	call := c.Srv.Users.Drafts.List(user).MaxResults(maxPageSize)
+	if query != "" {
+		call = call.Q(query)
+	}
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (c *Client) SendDraft(ctx context.Context, user, id string) (*gmail.Message, error) {
+	return c.Srv.Users.Drafts.Send(user, &gmail.Draft{Id: id}).Context(ctx).Do()
+}
+
+func (c *Client) DeleteDraft(ctx context.Context, user, id string) error {
+	return c.Srv.Users.Drafts.Delete(user, id).Context(ctx).Do()
+}
+
+func (c *Client) ListFilters(ctx context.Context, user string) ([]*gmail.Filter, error) {
+	r, err := c.Srv.Users.Settings.Filters.List(user).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return r.Filter, nil
+}
+
+func (c *Client) CreateFilter(ctx context.Context, user string, f *gmail.Filter) (*gmail.Filter, error) {
+	return c.Srv.Users.Settings.Filters.Create(user, f).Context(ctx).Do()
+}
+
+func (c *Client) DeleteFilter(ctx context.Context, user, id string) error {
+	return c.Srv.Users.Settings.Filters.Delete(user, id).Context(ctx).Do()
+}
+
+func (c *Client) GetVacation(ctx context.Context, user string) (*gmail.VacationSettings, error) {
+	return c.Srv.Users.Settings.GetVacation(user).Context(ctx).Do()
+}
+
+func (c *Client) UpdateVacation(ctx context.Context, user string, v *gmail.VacationSettings) (*gmail.VacationSettings, error) {
+	return c.Srv.Users.Settings.UpdateVacation(user, v).Context(ctx).Do()
+}
+
+func (c *Client) GetAutoForwarding(ctx context.Context, user string) (*gmail.AutoForwarding, error) {
+	return c.Srv.Users.Settings.GetAutoForwarding(user).Context(ctx).Do()
+}
+
+func (c *Client) UpdateAutoForwarding(ctx context.Context, user string, f *gmail.AutoForwarding) (*gmail.AutoForwarding, error) {
+	return c.Srv.Users.Settings.UpdateAutoForwarding(user, f).Context(ctx).Do()
+}
+
+func (c *Client) GetImap(ctx context.Context, user string) (*gmail.ImapSettings, error) {
+	return c.Srv.Users.Settings.GetImap(user).Context(ctx).Do()
+}
+
+func (c *Client) UpdateImap(ctx context.Context, user string, s *gmail.ImapSettings) (*gmail.ImapSettings, error) {
+	return c.Srv.Users.Settings.UpdateImap(user, s).Context(ctx).Do()
+}
+
+func (c *Client) GetPop(ctx context.Context, user string) (*gmail.PopSettings, error) {
+	return c.Srv.Users.Settings.GetPop(user).Context(ctx).Do()
+}
+
+func (c *Client) UpdatePop(ctx context.Context, user string, s *gmail.PopSettings) (*gmail.PopSettings, error) {
+	return c.Srv.Users.Settings.UpdatePop(user, s).Context(ctx).Do()
+}
+
+func (c *Client) GetLanguage(ctx context.Context, user string) (*gmail.LanguageSettings, error) {
+	return c.Srv.Users.Settings.GetLanguage(user).Context(ctx).Do()
+}
+
+func (c *Client) UpdateLanguage(ctx context.Context, user string, l *gmail.LanguageSettings) (*gmail.LanguageSettings, error) {
+	return c.Srv.Users.Settings.UpdateLanguage(user, l).Context(ctx).Do()
+}
+
+func (c *Client) ListSendAs(ctx context.Context, user string) ([]*gmail.SendAs, error) {
+	r, err := c.Srv.Users.Settings.SendAs.List(user).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return r.SendAs, nil
+}
+
+func (c *Client) GetSendAs(ctx context.Context, user, email string) (*gmail.SendAs, error) {
+	return c.Srv.Users.Settings.SendAs.Get(user, email).Context(ctx).Do()
+}
+
+func (c *Client) CreateSendAs(ctx context.Context, user string, s *gmail.SendAs) (*gmail.SendAs, error) {
+	return c.Srv.Users.Settings.SendAs.Create(user, s).Context(ctx).Do()
+}
+
+func (c *Client) PatchSendAs(ctx context.Context, user, email string, s *gmail.SendAs) (*gmail.SendAs, error) {
+	return c.Srv.Users.Settings.SendAs.Patch(user, email, s).Context(ctx).Do()
+}
+
+func (c *Client) DeleteSendAs(ctx context.Context, user, email string) error {
+	return c.Srv.Users.Settings.SendAs.Delete(user, email).Context(ctx).Do()
+}
+
+func (c *Client) VerifySendAs(ctx context.Context, user, email string) error {
+	return c.Srv.Users.Settings.SendAs.Verify(user, email).Context(ctx).Do()
+}
+
+func (c *Client) ListForwardingAddresses(ctx context.Context, user string) ([]*gmail.ForwardingAddress, error) {
+	r, err := c.Srv.Users.Settings.ForwardingAddresses.List(user).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return r.ForwardingAddresses, nil
+}
+
+func (c *Client) CreateForwardingAddress(ctx context.Context, user string, f *gmail.ForwardingAddress) (*gmail.ForwardingAddress, error) {
+	return c.Srv.Users.Settings.ForwardingAddresses.Create(user, f).Context(ctx).Do()
+}
+
+func (c *Client) DeleteForwardingAddress(ctx context.Context, user, email string) error {
+	return c.Srv.Users.Settings.ForwardingAddresses.Delete(user, email).Context(ctx).Do()
+}
+
+func (c *Client) ListDelegates(ctx context.Context, user string) ([]*gmail.Delegate, error) {
+	r, err := c.Srv.Users.Settings.Delegates.List(user).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return r.Delegates, nil
+}
+
+func (c *Client) CreateDelegate(ctx context.Context, user string, d *gmail.Delegate) (*gmail.Delegate, error) {
+	return c.Srv.Users.Settings.Delegates.Create(user, d).Context(ctx).Do()
+}
+
+func (c *Client) DeleteDelegate(ctx context.Context, user, email string) error {
+	return c.Srv.Users.Settings.Delegates.Delete(user, email).Context(ctx).Do()
+}
+
+func (c *Client) ListSmimeInfo(ctx context.Context, user, email string) ([]*gmail.SmimeInfo, error) {
+	r, err := c.Srv.Users.Settings.SendAs.SmimeInfo.List(user, email).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return r.SmimeInfo, nil
+}
+
+func (c *Client) InsertSmimeInfo(ctx context.Context, user, email string, info *gmail.SmimeInfo) (*gmail.SmimeInfo, error) {
+	return c.Srv.Users.Settings.SendAs.SmimeInfo.Insert(user, email, info).Context(ctx).Do()
+}
+
+func (c *Client) SetDefaultSmimeInfo(ctx context.Context, user, email, id string) error {
+	return c.Srv.Users.Settings.SendAs.SmimeInfo.SetDefault(user, email, id).Context(ctx).Do()
+}
+
+func (c *Client) DeleteSmimeInfo(ctx context.Context, user, email, id string) error {
+	return c.Srv.Users.Settings.SendAs.SmimeInfo.Delete(user, email, id).Context(ctx).Do()
+}
@@ -0,0 +1,56 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestClientGetMessageFields(t *testing.T) {
+	var fields string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields = r.URL.Query().Get("fields")
+		w.Write([]byte(`{"id": "17a1"}`))
+	}))
+	defer ts.Close()
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(ts.URL), option.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := gmailclient.NewClient(srv)
+	if _, err := c.GetMessage(context.Background(), "me", "17a1", "metadata", gmailclient.HeaderFields...); err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,threadId,labelIds,snippet,internalDate,payload/headers"; fields != want {
+		t.Errorf("fields = %q, want %q", fields, want)
+	}
+	if _, err := c.GetMessage(context.Background(), "me", "17a1", "full"); err != nil {
+		t.Fatal(err)
+	}
+	if fields != "" {
+		t.Errorf("fields = %q without a projection", fields)
+	}
+}
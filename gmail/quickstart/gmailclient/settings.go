@@ -0,0 +1,348 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// The settings functions read with the gmail.readonly scope and change
+// settings with gmail.settings.basic; forwarding addresses and automatic
+// forwarding need gmail.settings.sharing instead.
+
+// GetVacation returns the vacation responder settings of user.
+func GetVacation(ctx context.Context, srv VacationService, user string) (*gmail.VacationSettings, error) {
+	v, err := srv.GetVacation(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("GetVacation: %w", err)
+	}
+	return v, nil
+}
+
+// UpdateVacation replaces the vacation responder settings of user with v
+// and returns them as saved.
+func UpdateVacation(ctx context.Context, srv VacationService, user string, v *gmail.VacationSettings) (*gmail.VacationSettings, error) {
+	v, err := srv.UpdateVacation(ctx, user, v)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateVacation: %w", err)
+	}
+	return v, nil
+}
+
+// VacationTime converts a time to the milliseconds since the epoch that
+// VacationSettings.StartTime and EndTime hold; the zero time is 0, meaning
+// no limit.
+func VacationTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// VacationTimeOf converts VacationSettings.StartTime or EndTime back to a
+// time, the zero time for 0.
+func VacationTimeOf(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// ListSendAs returns the send-as aliases of user, the primary address
+// included.
+func ListSendAs(ctx context.Context, srv SendAsService, user string) ([]*gmail.SendAs, error) {
+	aliases, err := srv.ListSendAs(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("ListSendAs: %w", err)
+	}
+	return aliases, nil
+}
+
+// FindSendAs returns the alias among aliases with the given address,
+// matched case-insensitively, or the primary address if email is empty.
+func FindSendAs(aliases []*gmail.SendAs, email string) (*gmail.SendAs, error) {
+	for _, s := range aliases {
+		if (email == "" && s.IsPrimary) || (email != "" && strings.EqualFold(s.SendAsEmail, email)) {
+			return s, nil
+		}
+	}
+	if email == "" {
+		return nil, fmt.Errorf("FindSendAs: no primary address")
+	}
+	return nil, fmt.Errorf("FindSendAs: no send-as alias %s", email)
+}
+
+// SetSignature sets the HTML signature of the send-as alias email of user;
+// an empty signature removes it.
+func SetSignature(ctx context.Context, srv SendAsService, user, email, signature string) error {
+	s := &gmail.SendAs{Signature: signature, ForceSendFields: []string{"Signature"}}
+	if _, err := srv.PatchSendAs(ctx, user, email, s); err != nil {
+		return fmt.Errorf("SetSignature: %w", err)
+	}
+	return nil
+}
+
+// ForwardingDispositions are the things Gmail can do with a message after
+// forwarding it automatically.
+var ForwardingDispositions = []string{"leaveInInbox", "archive", "trash", "markRead"}
+
+// ListForwardingAddresses returns the forwarding addresses of user, verified
+// or not.
+func ListForwardingAddresses(ctx context.Context, srv ForwardingService, user string) ([]*gmail.ForwardingAddress, error) {
+	addrs, err := srv.ListForwardingAddresses(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("ListForwardingAddresses: %w", err)
+	}
+	return addrs, nil
+}
+
+// CreateForwardingAddress adds email to the forwarding addresses of user.
+// Unless the account is a Workspace one forwarding within its domain, Gmail
+// mails email a confirmation link and the address stays pending until it is
+// followed.
+func CreateForwardingAddress(ctx context.Context, srv ForwardingService, user, email string) (*gmail.ForwardingAddress, error) {
+	f, err := srv.CreateForwardingAddress(ctx, user, &gmail.ForwardingAddress{ForwardingEmail: email})
+	if err != nil {
+		return nil, fmt.Errorf("CreateForwardingAddress: %w", err)
+	}
+	return f, nil
+}
+
+// DeleteForwardingAddress removes email from the forwarding addresses of
+// user, turning automatic forwarding off if it went there.
+func DeleteForwardingAddress(ctx context.Context, srv ForwardingService, user, email string) error {
+	if err := srv.DeleteForwardingAddress(ctx, user, email); err != nil {
+		return fmt.Errorf("DeleteForwardingAddress: %w", err)
+	}
+	return nil
+}
+
+// GetAutoForwarding returns the automatic forwarding settings of user.
+func GetAutoForwarding(ctx context.Context, srv ForwardingService, user string) (*gmail.AutoForwarding, error) {
+	f, err := srv.GetAutoForwarding(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("GetAutoForwarding: %w", err)
+	}
+	return f, nil
+}
+
+// UpdateAutoForwarding replaces the automatic forwarding settings of user
+// with f. Forwarding can only be turned on to a verified forwarding address.
+func UpdateAutoForwarding(ctx context.Context, srv ForwardingService, user string, f *gmail.AutoForwarding) (*gmail.AutoForwarding, error) {
+	f, err := srv.UpdateAutoForwarding(ctx, user, f)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateAutoForwarding: %w", err)
+	}
+	return f, nil
+}
+
+// GetImap returns the IMAP settings of user.
+func GetImap(ctx context.Context, srv ImapPopService, user string) (*gmail.ImapSettings, error) {
+	s, err := srv.GetImap(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("GetImap: %w", err)
+	}
+	return s, nil
+}
+
+// UpdateImap replaces the IMAP settings of user with s.
+func UpdateImap(ctx context.Context, srv ImapPopService, user string, s *gmail.ImapSettings) (*gmail.ImapSettings, error) {
+	s, err := srv.UpdateImap(ctx, user, s)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateImap: %w", err)
+	}
+	return s, nil
+}
+
+// GetPop returns the POP settings of user.
+func GetPop(ctx context.Context, srv ImapPopService, user string) (*gmail.PopSettings, error) {
+	s, err := srv.GetPop(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("GetPop: %w", err)
+	}
+	return s, nil
+}
+
+// UpdatePop replaces the POP settings of user with s.
+func UpdatePop(ctx context.Context, srv ImapPopService, user string, s *gmail.PopSettings) (*gmail.PopSettings, error) {
+	s, err := srv.UpdatePop(ctx, user, s)
+	if err != nil {
+		return nil, fmt.Errorf("UpdatePop: %w", err)
+	}
+	return s, nil
+}
+
+// ListDelegates returns the delegates of user. Delegation is only
+// available to Workspace accounts, through a service account with
+// domain-wide authority.
+func ListDelegates(ctx context.Context, srv DelegateService, user string) ([]*gmail.Delegate, error) {
+	delegates, err := srv.ListDelegates(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("ListDelegates: %w", err)
+	}
+	return delegates, nil
+}
+
+// CreateDelegate lets email read, send and delete the mail of user. Both
+// must be in the same Workspace organization; the delegate is accepted
+// straight away.
+func CreateDelegate(ctx context.Context, srv DelegateService, user, email string) (*gmail.Delegate, error) {
+	d, err := srv.CreateDelegate(ctx, user, &gmail.Delegate{DelegateEmail: email})
+	if err != nil {
+		return nil, fmt.Errorf("CreateDelegate: %w", err)
+	}
+	return d, nil
+}
+
+// DeleteDelegate removes email from the delegates of user.
+func DeleteDelegate(ctx context.Context, srv DelegateService, user, email string) error {
+	if err := srv.DeleteDelegate(ctx, user, email); err != nil {
+		return fmt.Errorf("DeleteDelegate: %w", err)
+	}
+	return nil
+}
+
+// CreateSendAs adds the send-as alias s to user; s.SmtpMsa, if set, sends
+// the alias's mail through that SMTP server instead of Gmail's. Aliases of
+// addresses outside the account's domain start out pending: Gmail mails the
+// address a verification link, whose status WaitSendAsVerified follows.
+// Creating aliases needs gmail.settings.sharing.
+func CreateSendAs(ctx context.Context, srv SendAsService, user string, s *gmail.SendAs) (*gmail.SendAs, error) {
+	s, err := srv.CreateSendAs(ctx, user, s)
+	if err != nil {
+		return nil, fmt.Errorf("CreateSendAs: %w", err)
+	}
+	return s, nil
+}
+
+// UpdateSendAs changes the send-as alias email of user to s. Fields listed
+// in s.ForceSendFields are changed even when empty.
+func UpdateSendAs(ctx context.Context, srv SendAsService, user, email string, s *gmail.SendAs) (*gmail.SendAs, error) {
+	s, err := srv.PatchSendAs(ctx, user, email, s)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateSendAs: %w", err)
+	}
+	return s, nil
+}
+
+// DeleteSendAs removes the send-as alias email of user.
+func DeleteSendAs(ctx context.Context, srv SendAsService, user, email string) error {
+	if err := srv.DeleteSendAs(ctx, user, email); err != nil {
+		return fmt.Errorf("DeleteSendAs: %w", err)
+	}
+	return nil
+}
+
+// VerifySendAs mails the send-as alias email of user a new verification
+// link.
+func VerifySendAs(ctx context.Context, srv SendAsService, user, email string) error {
+	if err := srv.VerifySendAs(ctx, user, email); err != nil {
+		return fmt.Errorf("VerifySendAs: %w", err)
+	}
+	return nil
+}
+
+// WaitSendAsVerified polls the send-as alias email of user every interval
+// until its verification is no longer pending, and returns it. It stops
+// with ctx's error when ctx is done first.
+func WaitSendAsVerified(ctx context.Context, srv SendAsService, user, email string, interval time.Duration) (*gmail.SendAs, error) {
+	for {
+		s, err := srv.GetSendAs(ctx, user, email)
+		if err != nil {
+			return nil, fmt.Errorf("WaitSendAsVerified: %w", err)
+		}
+		if s.VerificationStatus != "pending" {
+			return s, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("WaitSendAsVerified: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// GetLanguage returns the display language of user, as an RFC 3166 tag
+// such as "en-GB".
+func GetLanguage(ctx context.Context, srv LanguageService, user string) (string, error) {
+	l, err := srv.GetLanguage(ctx, user)
+	if err != nil {
+		return "", fmt.Errorf("GetLanguage: %w", err)
+	}
+	return l.DisplayLanguage, nil
+}
+
+// UpdateLanguage sets the display language of user and returns the one
+// Gmail chose: a language it does not support falls back to the closest
+// one it does.
+func UpdateLanguage(ctx context.Context, srv LanguageService, user, language string) (string, error) {
+	l, err := srv.UpdateLanguage(ctx, user, &gmail.LanguageSettings{DisplayLanguage: language})
+	if err != nil {
+		return "", fmt.Errorf("UpdateLanguage: %w", err)
+	}
+	return l.DisplayLanguage, nil
+}
+
+// ListSmimeInfo returns the S/MIME certificates of the send-as alias email
+// of user.
+func ListSmimeInfo(ctx context.Context, srv SmimeService, user, email string) ([]*gmail.SmimeInfo, error) {
+	infos, err := srv.ListSmimeInfo(ctx, user, email)
+	if err != nil {
+		return nil, fmt.Errorf("ListSmimeInfo: %w", err)
+	}
+	return infos, nil
+}
+
+// InsertSmimeInfo uploads the PKCS#12 file p12, holding a certificate and
+// its private key protected by password, for the send-as alias email of
+// user. Gmail checks that the certificate is for email and issued by a CA
+// it trusts.
+func InsertSmimeInfo(ctx context.Context, srv SmimeService, user, email string, p12 []byte, password string) (*gmail.SmimeInfo, error) {
+	info := &gmail.SmimeInfo{
+		Pkcs12:               base64.URLEncoding.EncodeToString(p12),
+		EncryptedKeyPassword: password,
+	}
+	info, err := srv.InsertSmimeInfo(ctx, user, email, info)
+	if err != nil {
+		return nil, fmt.Errorf("InsertSmimeInfo: %w", err)
+	}
+	return info, nil
+}
+
+// SetDefaultSmimeInfo makes certificate id the one Gmail signs the mail of
+// the send-as alias email with.
+func SetDefaultSmimeInfo(ctx context.Context, srv SmimeService, user, email, id string) error {
+	if err := srv.SetDefaultSmimeInfo(ctx, user, email, id); err != nil {
+		return fmt.Errorf("SetDefaultSmimeInfo: %w", err)
+	}
+	return nil
+}
+
+// DeleteSmimeInfo removes certificate id from the send-as alias email.
+func DeleteSmimeInfo(ctx context.Context, srv SmimeService, user, email, id string) error {
+	if err := srv.DeleteSmimeInfo(ctx, user, email, id); err != nil {
+		return fmt.Errorf("DeleteSmimeInfo: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestVacationTime(t *testing.T) {
+	start := time.Date(2024, 7, 1, 9, 30, 0, 0, time.UTC)
+	ms := gmailclient.VacationTime(start)
+	if ms != 1719826200000 {
+		t.Errorf("VacationTime = %d", ms)
+	}
+	if got := gmailclient.VacationTimeOf(ms); !got.Equal(start) {
+		t.Errorf("VacationTimeOf = %v, want %v", got, start)
+	}
+	if gmailclient.VacationTime(time.Time{}) != 0 || !gmailclient.VacationTimeOf(0).IsZero() {
+		t.Error("the zero time does not map to 0")
+	}
+}
+
+func TestFindSendAs(t *testing.T) {
+	aliases := []*gmail.SendAs{
+		{SendAsEmail: "ana@example.com", IsPrimary: true},
+		{SendAsEmail: "Support@example.com"},
+	}
+	if s, err := gmailclient.FindSendAs(aliases, ""); err != nil || s.SendAsEmail != "ana@example.com" {
+		t.Errorf("FindSendAs(primary) = %v, %v", s, err)
+	}
+	if s, err := gmailclient.FindSendAs(aliases, "support@example.com"); err != nil || s.SendAsEmail != "Support@example.com" {
+		t.Errorf("FindSendAs(alias) = %v, %v", s, err)
+	}
+	if _, err := gmailclient.FindSendAs(aliases, "sales@example.com"); err == nil {
+		t.Error("FindSendAs(missing) succeeded")
+	}
+}
+
+func TestWaitSendAsVerified(t *testing.T) {
+	polls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/settings/sendAs/bo@example.org") {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		polls++
+		status := "pending"
+		if polls == 3 {
+			status = "accepted"
+		}
+		json.NewEncoder(w).Encode(&gmail.SendAs{SendAsEmail: "bo@example.org", VerificationStatus: status})
+	}))
+	defer ts.Close()
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(ts.URL), option.WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := gmailclient.WaitSendAsVerified(context.Background(), gmailclient.NewClient(srv), "me", "bo@example.org", time.Millisecond)
+	if err != nil || s.VerificationStatus != "accepted" || polls != 3 {
+		t.Errorf("WaitSendAsVerified = %v, %v after %d polls", s, err, polls)
+	}
+}
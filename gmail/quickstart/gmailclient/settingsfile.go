@@ -0,0 +1,184 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// MailboxSettings is a mailbox's configuration as written in a settings
+// file, with labels by name so that it can be applied to other mailboxes.
+// A section left out of the file is left alone when applying it.
+type MailboxSettings struct {
+	Labels     []*LabelSpec    `yaml:"labels,omitempty"`
+	Filters    []*FilterSpec   `yaml:"filters,omitempty"`
+	Forwarding *ForwardingSpec `yaml:"forwarding,omitempty"`
+	Vacation   *VacationSpec   `yaml:"vacation,omitempty"`
+	SendAs     []*SendAsSpec   `yaml:"send_as,omitempty"`
+
+	// Language is the display language, such as en-GB.
+	Language string `yaml:"language,omitempty"`
+}
+
+// LabelSpec is a user label in a settings file.
+type LabelSpec struct {
+	Name                  string `yaml:"name"`
+	LabelListVisibility   string `yaml:"label_list_visibility,omitempty"`
+	MessageListVisibility string `yaml:"message_list_visibility,omitempty"`
+	TextColor             string `yaml:"text_color,omitempty"`
+	BackgroundColor       string `yaml:"background_color,omitempty"`
+}
+
+// ForwardingSpec is the forwarding addresses and automatic forwarding of a
+// mailbox in a settings file.
+type ForwardingSpec struct {
+	Addresses   []string `yaml:"addresses,omitempty"`
+	Enabled     bool     `yaml:"enabled"`
+	To          string   `yaml:"to,omitempty"`
+	Disposition string   `yaml:"disposition,omitempty"`
+}
+
+// VacationSpec is the vacation responder in a settings file.
+type VacationSpec struct {
+	Enabled      bool      `yaml:"enabled"`
+	Subject      string    `yaml:"subject,omitempty"`
+	Body         string    `yaml:"body,omitempty"`
+	HTML         string    `yaml:"html,omitempty"`
+	ContactsOnly bool      `yaml:"contacts_only,omitempty"`
+	DomainOnly   bool      `yaml:"domain_only,omitempty"`
+	Start        time.Time `yaml:"start,omitempty"`
+	End          time.Time `yaml:"end,omitempty"`
+}
+
+// SendAsSpec is a send-as address in a settings file. SMTP relay settings
+// are not included: the API never returns their password.
+type SendAsSpec struct {
+	Email        string `yaml:"email"`
+	DisplayName  string `yaml:"display_name,omitempty"`
+	ReplyTo      string `yaml:"reply_to,omitempty"`
+	Signature    string `yaml:"signature,omitempty"`
+	Default      bool   `yaml:"default,omitempty"`
+	TreatAsAlias bool   `yaml:"treat_as_alias,omitempty"`
+}
+
+// NewLabelSpec returns the spec of the label l.
+func NewLabelSpec(l *gmail.Label) *LabelSpec {
+	s := &LabelSpec{
+		Name:                  l.Name,
+		LabelListVisibility:   l.LabelListVisibility,
+		MessageListVisibility: l.MessageListVisibility,
+	}
+	if l.Color != nil {
+		s.TextColor, s.BackgroundColor = l.Color.TextColor, l.Color.BackgroundColor
+	}
+	return s
+}
+
+// Label returns the Gmail label s describes.
+func (s *LabelSpec) Label() *gmail.Label {
+	l := &gmail.Label{
+		Name:                  s.Name,
+		LabelListVisibility:   s.LabelListVisibility,
+		MessageListVisibility: s.MessageListVisibility,
+	}
+	if s.TextColor != "" || s.BackgroundColor != "" {
+		l.Color = &gmail.LabelColor{TextColor: s.TextColor, BackgroundColor: s.BackgroundColor}
+	}
+	return l
+}
+
+// NewVacationSpec returns the spec of the vacation settings v.
+func NewVacationSpec(v *gmail.VacationSettings) *VacationSpec {
+	return &VacationSpec{
+		Enabled:      v.EnableAutoReply,
+		Subject:      v.ResponseSubject,
+		Body:         v.ResponseBodyPlainText,
+		HTML:         v.ResponseBodyHtml,
+		ContactsOnly: v.RestrictToContacts,
+		DomainOnly:   v.RestrictToDomain,
+		Start:        VacationTimeOf(v.StartTime),
+		End:          VacationTimeOf(v.EndTime),
+	}
+}
+
+// Settings returns the Gmail vacation settings s describes.
+func (s *VacationSpec) Settings() *gmail.VacationSettings {
+	return &gmail.VacationSettings{
+		EnableAutoReply:       s.Enabled,
+		ResponseSubject:       s.Subject,
+		ResponseBodyPlainText: s.Body,
+		ResponseBodyHtml:      s.HTML,
+		RestrictToContacts:    s.ContactsOnly,
+		RestrictToDomain:      s.DomainOnly,
+		StartTime:             VacationTime(s.Start),
+		EndTime:               VacationTime(s.End),
+		ForceSendFields:       []string{"EnableAutoReply"},
+	}
+}
+
+// NewSendAsSpec returns the spec of the send-as alias s.
+func NewSendAsSpec(s *gmail.SendAs) *SendAsSpec {
+	return &SendAsSpec{
+		Email:        s.SendAsEmail,
+		DisplayName:  s.DisplayName,
+		ReplyTo:      s.ReplyToAddress,
+		Signature:    s.Signature,
+		Default:      s.IsDefault,
+		TreatAsAlias: s.TreatAsAlias,
+	}
+}
+
+// SendAs returns the Gmail send-as alias s describes.
+func (s *SendAsSpec) SendAs() *gmail.SendAs {
+	return &gmail.SendAs{
+		SendAsEmail:     s.Email,
+		DisplayName:     s.DisplayName,
+		ReplyToAddress:  s.ReplyTo,
+		Signature:       s.Signature,
+		IsDefault:       s.Default,
+		TreatAsAlias:    s.TreatAsAlias,
+		ForceSendFields: []string{"DisplayName", "ReplyToAddress", "Signature", "TreatAsAlias"},
+	}
+}
+
+// ReadMailboxSettings reads a settings file, a YAML document with the
+// sections of MailboxSettings.
+func ReadMailboxSettings(r io.Reader) (*MailboxSettings, error) {
+	var s MailboxSettings
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("ReadMailboxSettings: %w", err)
+	}
+	return &s, nil
+}
+
+// WriteMailboxSettings writes s to w as a settings file.
+func WriteMailboxSettings(w io.Writer, s *MailboxSettings) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return fmt.Errorf("WriteMailboxSettings: %w", err)
+	}
+	return enc.Close()
+}
@@ -0,0 +1,77 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestMailboxSettingsRoundTrip(t *testing.T) {
+	end := time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC)
+	s := &gmailclient.MailboxSettings{
+		Labels: []*gmailclient.LabelSpec{gmailclient.NewLabelSpec(&gmail.Label{
+			Name:  "Receipts",
+			Color: &gmail.LabelColor{TextColor: "#ffffff", BackgroundColor: "#16a765"},
+		})},
+		Vacation: gmailclient.NewVacationSpec(&gmail.VacationSettings{
+			EnableAutoReply: true,
+			ResponseSubject: "Away",
+			EndTime:         gmailclient.VacationTime(end),
+		}),
+	}
+	var buf bytes.Buffer
+	if err := gmailclient.WriteMailboxSettings(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	want := `labels:
+  - name: Receipts
+    text_color: '#ffffff'
+    background_color: '#16a765'
+vacation:
+  enabled: true
+  subject: Away
+  end: 2024-07-15T00:00:00Z
+`
+	if buf.String() != want {
+		t.Errorf("WriteMailboxSettings =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	got, err := gmailclient.ReadMailboxSettings(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := got.Labels[0].Label(); l.Color == nil || l.Color.BackgroundColor != "#16a765" {
+		t.Errorf("label = %+v", l)
+	}
+	v := got.Vacation.Settings()
+	if !v.EnableAutoReply || v.StartTime != 0 || v.EndTime != gmailclient.VacationTime(end) {
+		t.Errorf("vacation = %+v", v)
+	}
+	if got.Forwarding != nil || got.Filters != nil {
+		t.Errorf("sections left out of the file = %+v, %+v", got.Forwarding, got.Filters)
+	}
+
+	if _, err := gmailclient.ReadMailboxSettings(bytes.NewBufferString("vacation:\n  enable: true\n")); err == nil {
+		t.Error("ReadMailboxSettings accepted an unknown field")
+	}
+}
@@ -0,0 +1,354 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+	"google.golang.org/api/gmail/v1"
+)
+
+// SMIME is a Wrapper that signs messages with S/MIME (RFC 8551): the
+// content is sent as multipart/signed with a detached CMS signature, which
+// carries the certificate so recipients can check it.
+type SMIME struct {
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate // intermediate certificates to include
+	Key         crypto.Signer       // RSA or ECDSA
+}
+
+// LoadSMIME reads an S/MIME identity from a PKCS#12 file (.p12 or .pfx)
+// protected by password, or from a PEM file holding the private key and
+// certificates. The certificate matching the key is the signing
+// certificate; the others are included as the chain. PKCS#12 files must use
+// the legacy 3DES encryption (openssl pkcs12 -export -legacy).
+func LoadSMIME(path, password string) (*SMIME, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadSMIME: %w", err)
+	}
+	var blocks []*pem.Block
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		for rest := data; ; {
+			var b *pem.Block
+			if b, rest = pem.Decode(rest); b == nil {
+				break
+			}
+			blocks = append(blocks, b)
+		}
+	} else if blocks, err = pkcs12.ToPEM(data, password); err != nil {
+		return nil, fmt.Errorf("LoadSMIME %s: %w", path, err)
+	}
+
+	s := &SMIME{}
+	var certs []*x509.Certificate
+	for _, b := range blocks {
+		switch {
+		case b.Type == "CERTIFICATE":
+			c, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("LoadSMIME %s: %w", path, err)
+			}
+			certs = append(certs, c)
+		case strings.HasSuffix(b.Type, "PRIVATE KEY"):
+			if s.Key, err = parsePrivateKey(b.Bytes); err != nil {
+				return nil, fmt.Errorf("LoadSMIME %s: %w", path, err)
+			}
+		}
+	}
+	if s.Key == nil {
+		return nil, fmt.Errorf("LoadSMIME %s: no private key", path)
+	}
+	for _, c := range certs {
+		if s.Certificate == nil && publicKeyEqual(c.PublicKey, s.Key.Public()) {
+			s.Certificate = c
+		} else {
+			s.Chain = append(s.Chain, c)
+		}
+	}
+	if s.Certificate == nil {
+		return nil, fmt.Errorf("LoadSMIME %s: no certificate for the private key", path)
+	}
+	return s, nil
+}
+
+// parsePrivateKey parses a PKCS#1, SEC 1 or PKCS#8 private key.
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := k.(crypto.Signer); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", k)
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	switch a := a.(type) {
+	case *rsa.PublicKey:
+		b, ok := b.(*rsa.PublicKey)
+		return ok && a.N.Cmp(b.N) == 0 && a.E == b.E
+	case *ecdsa.PublicKey:
+		b, ok := b.(*ecdsa.PublicKey)
+		return ok && a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+	}
+	return false
+}
+
+// Wrap implements Wrapper.
+func (s *SMIME) Wrap(h textproto.MIMEHeader, body []byte) (textproto.MIMEHeader, []byte, error) {
+	entity := entityBytes(h, body)
+	sig, err := signCMS(entity, s.Certificate, s.Chain, s.Key, time.Now())
+	if err != nil {
+		return nil, nil, fmt.Errorf("S/MIME sign: %w", err)
+	}
+	sh := textproto.MIMEHeader{}
+	sh.Set("Content-Type", mime.FormatMediaType("application/pkcs7-signature", map[string]string{"name": "smime.p7s"}))
+	sh.Set("Content-Transfer-Encoding", "base64")
+	sh.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "smime.p7s"}))
+	sh.Set("Content-Description", "S/MIME Cryptographic Signature")
+	var enc bytes.Buffer
+	b64 := base64.StdEncoding.EncodeToString(sig)
+	for len(b64) > 76 {
+		enc.WriteString(b64[:76] + "\r\n")
+		b64 = b64[76:]
+	}
+	enc.WriteString(b64 + "\r\n")
+	h, body = writeParts("multipart/signed", map[string]string{"micalg": "sha-256", "protocol": "application/pkcs7-signature"},
+		entity, entityBytes(sh, enc.Bytes()))
+	return h, body, nil
+}
+
+// A Signature describes the signature of a signed message. ParseMessage
+// only fills in Protocol; VerifySMIME checks S/MIME signatures, which needs
+// the raw message.
+type Signature struct {
+	Protocol string `json:"protocol"` // "S/MIME" or "PGP"
+
+	// Verified reports whether the signature was checked, and Valid whether
+	// it is good: it matches the content and the certificate chains to a
+	// trusted root and is valid for email now. Error says why it is not.
+	Verified bool   `json:"verified"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+
+	// Signer is the email address of the signing certificate, or its
+	// common name if it has none, and SignedAt the signing time it claims.
+	// The signer sets SignedAt, so it proves nothing and plays no part in
+	// Valid.
+	Signer      string            `json:"signer,omitempty"`
+	SignedAt    time.Time         `json:"signedAt,omitempty"`
+	Certificate *x509.Certificate `json:"-"`
+}
+
+// messageSignature returns the unverified Signature of the first
+// multipart/signed part under part, or nil if there is none.
+func messageSignature(part *gmail.MessagePart) *Signature {
+	signed := findParts(part, func(p *gmail.MessagePart) bool {
+		return strings.EqualFold(p.MimeType, "multipart/signed")
+	})
+	if len(signed) == 0 {
+		return nil
+	}
+	_, params, _ := mime.ParseMediaType(partHeader(signed[0]).Get("Content-Type"))
+	switch strings.ToLower(params["protocol"]) {
+	case "application/pkcs7-signature", "application/x-pkcs7-signature":
+		return &Signature{Protocol: "S/MIME"}
+	case "application/pgp-signature":
+		return &Signature{Protocol: "PGP"}
+	}
+	return nil
+}
+
+// VerifySMIME checks the S/MIME signature of the raw message. Certificates
+// are verified against roots, or the system roots if it is nil. It returns
+// nil if the message has no S/MIME signature. A signature that does not
+// verify is not an error, but a Signature with Valid false.
+func VerifySMIME(raw []byte, roots *x509.CertPool) (*Signature, error) {
+	raw = crlf(raw)
+	content, sigData, err := findSMIMESigned(raw, 0)
+	if err != nil {
+		return nil, fmt.Errorf("VerifySMIME: %w", err)
+	}
+	if content == nil {
+		return nil, nil
+	}
+	s := &Signature{Protocol: "S/MIME", Verified: true}
+	s.Error = verifySMIME(s, content, sigData, roots)
+	s.Valid = s.Error == ""
+
+	if h, _ := splitEntity(raw); s.Valid {
+		if !certificateHasEmail(s.Certificate, ParseAddressList(h.Get("From"))) {
+			s.Valid = false
+			s.Error = fmt.Sprintf("signed by %s, not the sender", s.Signer)
+		}
+	}
+	return s, nil
+}
+
+func verifySMIME(s *Signature, content, sigData []byte, roots *x509.CertPool) string {
+	cms, err := parseCMS(sigData)
+	if err != nil {
+		return err.Error()
+	}
+	signer := cms.signers[0]
+	cert := signer.certificate(cms.certs)
+	if cert == nil {
+		return "the signing certificate is not included"
+	}
+	s.Certificate = cert
+	s.Signer = cert.Subject.CommonName
+	if len(cert.EmailAddresses) > 0 {
+		s.Signer = cert.EmailAddresses[0]
+	}
+	if s.SignedAt, err = signer.verify(content, cert); err != nil {
+		return err.Error()
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range cms.certs {
+		intermediates.AddCert(c)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		// The chain is checked now, not at the signing time, which the
+		// holder of an expired or compromised key could backdate.
+		CurrentTime: time.Now(),
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Sprintf("untrusted certificate: %v", err)
+	}
+	return ""
+}
+
+// certificateHasEmail reports whether cert names one of the addresses.
+func certificateHasEmail(cert *x509.Certificate, addrs []*mail.Address) bool {
+	for _, a := range addrs {
+		for _, e := range cert.EmailAddresses {
+			if strings.EqualFold(e, a.Address) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findSMIMESigned finds the first S/MIME multipart/signed entity in the
+// CRLF-terminated entity raw, and returns the signed content and the
+// decoded signature.
+func findSMIMESigned(raw []byte, depth int) (content, sig []byte, err error) {
+	if depth > maxMIMEDepth {
+		return nil, nil, errors.New("MIME parts nested too deeply")
+	}
+	h, body := splitEntity(raw)
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, nil, nil
+	}
+	parts := splitMultipart(body, params["boundary"])
+	if mediaType == "multipart/signed" {
+		p := strings.ToLower(params["protocol"])
+		if (p == "application/pkcs7-signature" || p == "application/x-pkcs7-signature") && len(parts) == 2 {
+			sh, sbody := splitEntity(parts[1])
+			if strings.EqualFold(sh.Get("Content-Transfer-Encoding"), "base64") {
+				sbody, err = ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(stripSpace(sbody))))
+				if err != nil {
+					return nil, nil, fmt.Errorf("signature: %w", err)
+				}
+			}
+			return parts[0], sbody, nil
+		}
+	}
+	for _, part := range parts {
+		if content, sig, err := findSMIMESigned(part, depth+1); content != nil || err != nil {
+			return content, sig, err
+		}
+	}
+	return nil, nil, nil
+}
+
+// splitEntity splits a CRLF-terminated entity into its header and body.
+func splitEntity(raw []byte) (textproto.MIMEHeader, []byte) {
+	h := textproto.MIMEHeader{}
+	head, body := raw, []byte(nil)
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+		head, body = raw[:i+2], raw[i+4:]
+	} else if bytes.HasPrefix(raw, []byte("\r\n")) {
+		head, body = nil, raw[2:]
+	}
+	// head is copied so that the blank line appended to it cannot be
+	// written into raw.
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(append([]byte(nil), head...), "\r\n"...))))
+	if mh, err := r.ReadMIMEHeader(); err == nil || len(mh) > 0 {
+		h = mh
+	}
+	return h, body
+}
+
+// splitMultipart returns the parts of a multipart body exactly as they
+// appear between the delimiter lines.
+func splitMultipart(body []byte, boundary string) [][]byte {
+	delim := []byte("\r\n--" + boundary)
+	chunks := bytes.Split(append([]byte("\r\n"), body...), delim)
+	var parts [][]byte
+	for i := 1; i < len(chunks); i++ {
+		c := chunks[i]
+		if bytes.HasPrefix(c, []byte("--")) {
+			break
+		}
+		// The rest of the delimiter line: optional whitespace and CRLF.
+		if j := bytes.Index(c, []byte("\r\n")); j >= 0 {
+			parts = append(parts, c[j+2:])
+		}
+	}
+	return parts
+}
+
+// stripSpace removes the whitespace from base64 text.
+func stripSpace(b []byte) []byte {
+	return bytes.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, b)
+}
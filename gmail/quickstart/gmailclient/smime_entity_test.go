@@ -0,0 +1,34 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import "testing"
+
+func TestSplitEntityKeepsRaw(t *testing.T) {
+	// raw ends without a blank line, in the middle of buf, as an entity
+	// sliced out of a larger message does.
+	buf := []byte("Content-Type: text/plain\r\nrest of the message")
+	raw := buf[:len("Content-Type: text/plain\r\n")]
+	h, body := splitEntity(raw)
+	if h.Get("Content-Type") != "text/plain" || len(body) != 0 {
+		t.Errorf("splitEntity = %v, %q", h, body)
+	}
+	if string(buf) != "Content-Type: text/plain\r\nrest of the message" {
+		t.Errorf("splitEntity changed the bytes after raw: %q", buf)
+	}
+}
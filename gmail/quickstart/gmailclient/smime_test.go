@@ -0,0 +1,98 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+// The testdata was made with OpenSSL: smime-signed.eml with
+// "openssl smime -sign -stream", which writes BER, by the certificate in
+// smime-ana.p12 (password "secret") issued by smime-ca.pem.
+
+func smimeRoots(t *testing.T) *x509.CertPool {
+	t.Helper()
+	b, err := ioutil.ReadFile("testdata/smime-ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		t.Fatal("no certificate in smime-ca.pem")
+	}
+	return roots
+}
+
+func TestVerifySMIME(t *testing.T) {
+	roots := smimeRoots(t)
+	raw, err := ioutil.ReadFile("testdata/smime-signed.eml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := gmailclient.VerifySMIME(raw, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil || !s.Valid || s.Signer != "ana@example.com" || s.SignedAt.IsZero() {
+		t.Fatalf("VerifySMIME = %+v", s)
+	}
+
+	tampered := bytes.Replace(raw, []byte("Hello from openssl"), []byte("Hello from mallory"), 1)
+	if s, err := gmailclient.VerifySMIME(tampered, roots); err != nil || s.Valid || !strings.Contains(s.Error, "changed") {
+		t.Errorf("VerifySMIME(tampered) = %+v, %v", s, err)
+	}
+	if s, err := gmailclient.VerifySMIME(raw, x509.NewCertPool()); err != nil || s.Valid || !strings.Contains(s.Error, "untrusted") {
+		t.Errorf("VerifySMIME without the root = %+v, %v", s, err)
+	}
+	if s, err := gmailclient.VerifySMIME([]byte("To: a@example.com\r\n\r\nhi\r\n"), roots); s != nil || err != nil {
+		t.Errorf("VerifySMIME(unsigned) = %+v, %v", s, err)
+	}
+}
+
+func TestSMIMESign(t *testing.T) {
+	id, err := gmailclient.LoadSMIME("testdata/smime-ana.p12", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &gmailclient.OutgoingMessage{
+		From:    gmailclient.ParseAddressList("Ana <ana@example.com>")[0],
+		To:      gmailclient.ParseAddressList("bo@example.com"),
+		Subject: "Signed",
+		Text:    "Grüße\n",
+		Wrapper: id,
+	}
+	raw, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := gmailclient.VerifySMIME(raw, smimeRoots(t)); err != nil || s == nil || !s.Valid {
+		t.Errorf("VerifySMIME = %+v, %v", s, err)
+	}
+
+	m.From = gmailclient.ParseAddressList("eve@example.com")[0]
+	raw, _ = m.Bytes()
+	if s, _ := gmailclient.VerifySMIME(raw, smimeRoots(t)); s == nil || s.Valid {
+		t.Errorf("VerifySMIME with another sender = %+v", s)
+	}
+}
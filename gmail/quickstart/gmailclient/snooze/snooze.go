@@ -0,0 +1,223 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package snooze keeps track of snoozed messages in a local SQLite database
+// and wakes them once they are due. The Gmail API cannot snooze messages
+// itself, so gmailctl snoozes a message's thread by moving it out of the
+// inbox under a label of its own, records here when it should return, and
+// moves it back when the time comes.
+//
+//	s, err := snooze.Open("snooze.db")
+//	...
+//	err = s.Add(ctx, "work", messageID, threadID, until)
+//	...
+//	err = s.Run(ctx, time.Minute, func(ctx context.Context, e *snooze.Entry) error {
+//		return gmailclient.Unsnooze(ctx, srv, "me", e.ThreadID, e.MessageID, labelID)
+//	}, nil)
+//
+// An entry whose wake fails stays due and is tried again on the next run.
+package snooze
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS snoozed (
+	account    TEXT NOT NULL,
+	message_id TEXT NOT NULL,
+	thread_id  TEXT NOT NULL,
+	until      INTEGER NOT NULL,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (account, message_id)
+);
+CREATE INDEX IF NOT EXISTS snoozed_until ON snoozed (until);
+`
+
+// An Entry is one snoozed message.
+type Entry struct {
+	Account   string
+	MessageID string
+	ThreadID  string
+	Until     time.Time // when the message returns to the inbox
+
+	Attempts  int // failed wakes so far
+	LastError string
+}
+
+// WakeFunc moves e back to the inbox.
+type WakeFunc func(ctx context.Context, e *Entry) error
+
+// Store is a database of snoozed messages. It is safe for concurrent use,
+// also by several processes.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it if necessary.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("snooze: create directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("snooze: open %s: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("snooze: create schema in %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records that the message messageID of account, in thread threadID,
+// is snoozed until the given time. Snoozing a snoozed message again moves
+// its time.
+func (s *Store) Add(ctx context.Context, account, messageID, threadID string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO snoozed
+		(account, message_id, thread_id, until) VALUES (?, ?, ?, ?)`,
+		account, messageID, threadID, until.Unix())
+	if err != nil {
+		return fmt.Errorf("snooze: add: %w", err)
+	}
+	return nil
+}
+
+// Get returns the entry of message messageID of account, or nil if it is
+// not snoozed.
+func (s *Store) Get(ctx context.Context, account, messageID string) (*Entry, error) {
+	entries, err := s.query(ctx, `SELECT account, message_id, thread_id, until, attempts, last_error
+		FROM snoozed WHERE account = ? AND message_id = ?`, account, messageID)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// List returns the snoozed messages of account, or of every account if it
+// is empty, in the order they wake.
+func (s *Store) List(ctx context.Context, account string) ([]*Entry, error) {
+	query := `SELECT account, message_id, thread_id, until, attempts, last_error FROM snoozed`
+	var args []interface{}
+	if account != "" {
+		query += ` WHERE account = ?`
+		args = append(args, account)
+	}
+	return s.query(ctx, query+` ORDER BY until, account, message_id`, args...)
+}
+
+// Due returns the entries due to wake at now.
+func (s *Store) Due(ctx context.Context, now time.Time) ([]*Entry, error) {
+	return s.query(ctx, `SELECT account, message_id, thread_id, until, attempts, last_error
+		FROM snoozed WHERE until <= ? ORDER BY until, account, message_id`, now.Unix())
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) ([]*Entry, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("snooze: list: %w", err)
+	}
+	defer rows.Close()
+	var entries []*Entry
+	for rows.Next() {
+		e := &Entry{}
+		var until int64
+		if err := rows.Scan(&e.Account, &e.MessageID, &e.ThreadID, &until, &e.Attempts, &e.LastError); err != nil {
+			return nil, fmt.Errorf("snooze: list: %w", err)
+		}
+		e.Until = time.Unix(until, 0)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Remove forgets the snoozed message messageID of account, without waking
+// it.
+func (s *Store) Remove(ctx context.Context, account, messageID string) error {
+	r, err := s.db.ExecContext(ctx, `DELETE FROM snoozed WHERE account = ? AND message_id = ?`, account, messageID)
+	if err != nil {
+		return fmt.Errorf("snooze: remove %s: %w", messageID, err)
+	}
+	if n, _ := r.RowsAffected(); n == 0 {
+		return fmt.Errorf("snooze: message %s is not snoozed", messageID)
+	}
+	return nil
+}
+
+// Wake calls wake for the entries due at now and forgets those it woke. It
+// returns the entries it tried; a failed wake is not an error of Wake, but
+// shows in the entry's LastError, and the entry is tried again next time.
+func (s *Store) Wake(ctx context.Context, now time.Time, wake WakeFunc) ([]*Entry, error) {
+	due, err := s.Due(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range due {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := wake(ctx, e); err != nil {
+			e.Attempts++
+			e.LastError = err.Error()
+			_, err = s.db.ExecContext(ctx, `UPDATE snoozed SET attempts = ?, last_error = ?
+				WHERE account = ? AND message_id = ?`, e.Attempts, e.LastError, e.Account, e.MessageID)
+		} else {
+			e.LastError = ""
+			// Only forget the entry if it was not snoozed again meanwhile.
+			_, err = s.db.ExecContext(ctx, `DELETE FROM snoozed WHERE account = ? AND message_id = ? AND until = ?`,
+				e.Account, e.MessageID, e.Until.Unix())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("snooze: update %s: %w", e.MessageID, err)
+		}
+	}
+	return due, nil
+}
+
+// Run calls Wake every interval until ctx is done, passing each batch of
+// tried entries to report if it is not nil.
+func (s *Store) Run(ctx context.Context, interval time.Duration, wake WakeFunc, report func([]*Entry)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		tried, err := s.Wake(ctx, time.Now(), wake)
+		if err != nil {
+			return err
+		}
+		if report != nil && len(tried) > 0 {
+			report(tried)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snooze_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/snooze"
+)
+
+func TestWake(t *testing.T) {
+	ctx := context.Background()
+	s, err := snooze.Open(filepath.Join(t.TempDir(), "snooze.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Unix(1719824400, 0)
+	s.Add(ctx, "work", "m1", "t1", now.Add(-time.Minute))
+	s.Add(ctx, "work", "m2", "t2", now.Add(time.Hour))
+	s.Add(ctx, "home", "m3", "t3", now)
+	// Snoozing again moves the time.
+	s.Add(ctx, "work", "m2", "t2", now.Add(2*time.Hour))
+
+	wake := func(ctx context.Context, e *snooze.Entry) error {
+		if e.Account == "home" {
+			return errors.New("backend error")
+		}
+		return nil
+	}
+	tried, err := s.Wake(ctx, now, wake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tried) != 2 || tried[0].MessageID != "m1" || tried[1].MessageID != "m3" || tried[1].LastError != "backend error" {
+		t.Fatalf("woke %+v", tried)
+	}
+
+	left, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0].MessageID != "m3" || left[0].Attempts != 1 || left[1].MessageID != "m2" || !left[1].Until.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("left = %+v", left)
+	}
+
+	if e, _ := s.Get(ctx, "work", "m2"); e == nil || e.ThreadID != "t2" {
+		t.Errorf("Get(m2) = %+v", e)
+	}
+	if err := s.Remove(ctx, "work", "m2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(ctx, "work", "m1"); err == nil {
+		t.Error("removed a message that is not snoozed")
+	}
+}
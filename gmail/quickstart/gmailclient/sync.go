@@ -0,0 +1,164 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/atomicfile"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Changes is the result of a Sync: the messages that changed since the
+// previous sync, by message ID.
+type Changes struct {
+	// Full is set when every message was listed instead, because there was
+	// no previous sync or its history has expired. Added then holds every
+	// message in the mailbox and the caller should drop anything else it
+	// knows about.
+	Full bool
+
+	Added         []string
+	Deleted       []string
+	LabelsChanged []string
+
+	// HistoryId is the mailbox state these changes bring the caller up to.
+	// Pass it to the next Sync.
+	HistoryId uint64
+}
+
+// Sync returns the changes to the mailbox since historyId, using the History
+// API. A zero historyId, or one too old for Gmail to still have its history,
+// results in a full listing instead.
+func Sync(ctx context.Context, srv GmailService, user string, historyId uint64) (*Changes, error) {
+	if historyId == 0 {
+		return fullSync(ctx, srv, user)
+	}
+	added := map[string]bool{}
+	deleted := map[string]bool{}
+	relabeled := map[string]bool{}
+	c := &Changes{}
+	pageToken := ""
+	for {
+		r, err := srv.ListHistory(ctx, user, historyId, pageToken)
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+			return fullSync(ctx, srv, user)
+		} else if err != nil {
+			return nil, fmt.Errorf("Sync list history: %w", err)
+		}
+		for _, h := range r.History {
+			for _, m := range h.MessagesAdded {
+				added[m.Message.Id] = true
+				delete(deleted, m.Message.Id)
+			}
+			for _, m := range h.MessagesDeleted {
+				deleted[m.Message.Id] = true
+				delete(added, m.Message.Id)
+			}
+			for _, l := range h.LabelsAdded {
+				relabeled[l.Message.Id] = true
+			}
+			for _, l := range h.LabelsRemoved {
+				relabeled[l.Message.Id] = true
+			}
+		}
+		c.HistoryId = r.HistoryId
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	for id := range relabeled {
+		if added[id] || deleted[id] {
+			delete(relabeled, id)
+		}
+	}
+	c.Added, c.Deleted, c.LabelsChanged = sortedKeys(added), sortedKeys(deleted), sortedKeys(relabeled)
+	return c, nil
+}
+
+// fullSync lists every message. The history ID is read first, so that changes
+// made during the listing are picked up by the next Sync.
+func fullSync(ctx context.Context, srv GmailService, user string) (*Changes, error) {
+	p, err := srv.GetProfile(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("Sync get profile: %w", err)
+	}
+	c := &Changes{Full: true, HistoryId: p.HistoryId}
+	err = ListAllMessages(ctx, srv, user, "", 0, func(m *gmail.Message) error {
+		c.Added = append(c.Added, m.Id)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Sync: %w", err)
+	}
+	return c, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SyncState is the state Sync needs between runs, as stored by
+// LoadSyncState and SaveSyncState.
+type SyncState struct {
+	HistoryId uint64 `json:"history_id,string"`
+}
+
+// LoadSyncState reads the sync state at path. A missing file yields the zero
+// state, which makes the next Sync a full one.
+func LoadSyncState(path string) (*SyncState, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &SyncState{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	s := &SyncState{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("LoadSyncState decode %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// SaveSyncState writes s to path, replacing it atomically.
+func SaveSyncState(path string, s *SyncState) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("SaveSyncState create directory: %w", err)
+	}
+	return atomicfile.WriteFile(path, b, 0600)
+}
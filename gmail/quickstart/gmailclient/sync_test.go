@@ -0,0 +1,67 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestSync(t *testing.T) {
+	srv := gmailclienttest.New()
+	srv.AddMessage(&gmail.Message{Id: "a"})
+	srv.AddMessage(&gmail.Message{Id: "b"})
+	srv.HistoryId = 100
+	ctx := context.Background()
+
+	c, err := gmailclient.Sync(ctx, srv, "me", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Full || fmt.Sprint(c.Added) != "[a b]" || c.HistoryId != 100 {
+		t.Errorf("first sync = %+v, want a full sync of [a b] at 100", c)
+	}
+
+	msg := func(id string) *gmail.Message { return &gmail.Message{Id: id} }
+	srv.History = []*gmail.History{
+		{Id: 101, MessagesAdded: []*gmail.HistoryMessageAdded{{Message: msg("c")}, {Message: msg("d")}}},
+		{Id: 102, LabelsAdded: []*gmail.HistoryLabelAdded{{Message: msg("a"), LabelIds: []string{"STARRED"}}}},
+		{Id: 103, MessagesDeleted: []*gmail.HistoryMessageDeleted{{Message: msg("b")}, {Message: msg("d")}}},
+	}
+	srv.HistoryId = 103
+	c, err = gmailclient.Sync(ctx, srv, "me", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(c.Full, c.Added, c.Deleted, c.LabelsChanged, c.HistoryId); got != "false [c] [b d] [a] 103" {
+		t.Errorf("incremental sync = %s", got)
+	}
+
+	srv.OldestHistoryId = 102
+	if c, err = gmailclient.Sync(ctx, srv, "me", 100); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Full {
+		t.Errorf("sync from expired history = %+v, want a full sync", c)
+	}
+}
@@ -0,0 +1,157 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// MessageTemplate renders OutgoingMessages from Go templates.
+//
+// The text template is a message: header fields such as Subject and To,
+// a blank line, and the plain-text body. A template without header fields
+// starts with the blank line.
+//
+//	Subject: Welcome to {{.Team}}, {{.Name}}
+//	To: {{.Name}} <{{.Email}}>
+//
+//	Hi {{.Name}},
+//	...
+//
+// The HTML template is a body only, rendered with html/template so that
+// values are escaped. Referring to a missing key of a map is an error
+// rather than "<no value>", to catch typos in the data.
+type MessageTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// ParseMessageTemplate parses the text and HTML templates; either may be
+// empty, but not both.
+func ParseMessageTemplate(text, html string) (*MessageTemplate, error) {
+	if text == "" && html == "" {
+		return nil, fmt.Errorf("ParseMessageTemplate: no template")
+	}
+	t := &MessageTemplate{}
+	var err error
+	if text != "" {
+		if t.text, err = texttemplate.New("text").Option("missingkey=error").Parse(text); err != nil {
+			return nil, fmt.Errorf("ParseMessageTemplate: %w", err)
+		}
+	}
+	if html != "" {
+		if t.html, err = htmltemplate.New("html").Option("missingkey=error").Parse(html); err != nil {
+			return nil, fmt.Errorf("ParseMessageTemplate: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// ParseMessageTemplateFiles is ParseMessageTemplate for templates stored in
+// files. Either name may be empty.
+func ParseMessageTemplateFiles(textFile, htmlFile string) (*MessageTemplate, error) {
+	var text, html []byte
+	var err error
+	if textFile != "" {
+		if text, err = ioutil.ReadFile(textFile); err != nil {
+			return nil, fmt.Errorf("ParseMessageTemplateFiles: %w", err)
+		}
+	}
+	if htmlFile != "" {
+		if html, err = ioutil.ReadFile(htmlFile); err != nil {
+			return nil, fmt.Errorf("ParseMessageTemplateFiles: %w", err)
+		}
+	}
+	return ParseMessageTemplate(string(text), string(html))
+}
+
+// Execute renders the templates with data and returns the message they
+// describe. Subject, From, To, Cc, Bcc and Reply-To set the fields of the
+// same name; other header fields go to Header.
+func (t *MessageTemplate) Execute(data interface{}) (*OutgoingMessage, error) {
+	m := &OutgoingMessage{}
+	if t.text != nil {
+		var b bytes.Buffer
+		if err := t.text.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("MessageTemplate.Execute: %w", err)
+		}
+		r := textproto.NewReader(bufio.NewReader(&b))
+		h, err := r.ReadMIMEHeader()
+		if err != nil {
+			return nil, fmt.Errorf("MessageTemplate.Execute header: %w", err)
+		}
+		body, _ := ioutil.ReadAll(r.R)
+		m.Text = string(body)
+		if err := m.setHeader(h); err != nil {
+			return nil, fmt.Errorf("MessageTemplate.Execute: %w", err)
+		}
+	}
+	if t.html != nil {
+		var b bytes.Buffer
+		if err := t.html.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("MessageTemplate.Execute: %w", err)
+		}
+		m.HTML = b.String()
+	}
+	return m, nil
+}
+
+// setHeader fills in m from the header fields of a rendered template.
+func (m *OutgoingMessage) setHeader(h textproto.MIMEHeader) error {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := h[name]
+		value := strings.Join(values, ", ")
+		switch name {
+		case "Subject":
+			m.Subject = value
+		case "From":
+			from, err := mail.ParseAddress(value)
+			if err != nil {
+				return fmt.Errorf("From %q: %w", value, err)
+			}
+			m.From = from
+		case "To":
+			m.To = ParseAddressList(value)
+		case "Cc":
+			m.Cc = ParseAddressList(value)
+		case "Bcc":
+			m.Bcc = ParseAddressList(value)
+		case "Reply-To":
+			m.ReplyTo = ParseAddressList(value)
+		default:
+			for _, v := range values {
+				m.Header = append(m.Header, HeaderField{Name: name, Value: v})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestMessageTemplate(t *testing.T) {
+	tmpl, err := gmailclient.ParseMessageTemplate(
+		"Subject: Welcome to {{.Team}}, {{.Name}}\nTo: {{.Name}} <{{.Email}}>\n\nHi {{.Name}},\nyour account is ready.\n",
+		"<p>Hi {{.Name}},</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]string{"Name": "Sam & Co", "Email": "sam@example.com", "Team": "Ops"}
+	m, err := tmpl.Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "Welcome to Ops, Sam & Co" {
+		t.Errorf("Subject = %q", m.Subject)
+	}
+	if len(m.To) != 1 || m.To[0].Address != "sam@example.com" {
+		t.Errorf("To = %v", m.To)
+	}
+	if m.Text != "Hi Sam & Co,\nyour account is ready.\n" {
+		t.Errorf("Text = %q", m.Text)
+	}
+	if m.HTML != "<p>Hi Sam &amp; Co,</p>" {
+		t.Errorf("HTML = %q", m.HTML)
+	}
+
+	delete(data, "Team")
+	if _, err := tmpl.Execute(data); err == nil {
+		t.Errorf("Execute with a missing key succeeded")
+	}
+}
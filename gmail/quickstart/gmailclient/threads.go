@@ -0,0 +1,83 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// ListThreads calls fn with the threads matching query, newest first, up to
+// max of them or all if max is 0. Listed threads only have their ID,
+// snippet and history ID; see GetThread.
+func ListThreads(ctx context.Context, srv ThreadService, user, query string, max int64, fn func(t *gmail.Thread) error) error {
+	var n int64
+	pageToken := ""
+	for {
+		size := int64(maxPageSize)
+		if max > 0 && max-n < size {
+			size = max - n
+		}
+		r, err := srv.ListThreads(ctx, user, query, pageToken, size)
+		if err != nil {
+			return fmt.Errorf("ListThreads: %w", err)
+		}
+		for _, t := range r.Threads {
+			if err := fn(t); err != nil {
+				return err
+			}
+			if n++; max > 0 && n >= max {
+				return nil
+			}
+		}
+		if r.NextPageToken == "" {
+			return nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// GetThread fetches thread id with its messages in the given format
+// ("full", "metadata" or "minimal"). With "metadata", only the headers
+// named in headers are included, or all of them if there are none.
+func GetThread(ctx context.Context, srv ThreadService, user, id, format string, headers ...string) (*gmail.Thread, error) {
+	t, err := srv.GetThread(ctx, user, id, format, headers...)
+	if err != nil {
+		return nil, fmt.Errorf("GetThread: %w", err)
+	}
+	return t, nil
+}
+
+// ParseThread parses the messages of t, fetched in the "full" format, and
+// returns them in the order they were received, oldest first.
+func ParseThread(ctx context.Context, srv GmailService, t *gmail.Thread, user string) ([]*Message, error) {
+	msgs := append([]*gmail.Message(nil), t.Messages...)
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].InternalDate < msgs[j].InternalDate })
+	parsed := make([]*Message, 0, len(msgs))
+	for _, msg := range msgs {
+		m, err := ParseMessage(ctx, srv, msg, user)
+		if err != nil {
+			return nil, fmt.Errorf("ParseThread %s: %w", msg.Id, err)
+		}
+		parsed = append(parsed, m)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,88 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient/gmailclienttest"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestParseThreadOrder(t *testing.T) {
+	msg := func(id string, received int64) *gmail.Message {
+		return &gmail.Message{
+			Id:           id,
+			InternalDate: received,
+			Payload: &gmail.MessagePart{
+				MimeType: "text/plain",
+				Headers:  []*gmail.MessagePartHeader{{Name: "Subject", Value: "Lunch?"}},
+				Body:     &gmail.MessagePartBody{},
+			},
+		}
+	}
+	thread := &gmail.Thread{Id: "t1", Messages: []*gmail.Message{msg("b", 2000), msg("a", 1000), msg("c", 3000)}}
+	msgs, err := gmailclient.ParseThread(context.Background(), gmailclienttest.New(), thread, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids string
+	for _, m := range msgs {
+		ids += m.Id
+	}
+	if ids != "abc" {
+		t.Errorf("ParseThread order = %s, want abc", ids)
+	}
+	if thread.Messages[0].Id != "b" {
+		t.Error("ParseThread reordered the thread")
+	}
+}
+
+func TestListThreads(t *testing.T) {
+	srv := gmailclienttest.New()
+	srv.PageSize = 2
+	for i := 0; i < 7; i++ {
+		srv.AddMessage(&gmail.Message{Id: fmt.Sprint("m", i), ThreadId: fmt.Sprint("t", i/2)})
+	}
+	for _, tt := range []struct {
+		max  int64
+		want string
+	}{
+		{0, "[t0 t1 t2 t3]"},
+		{3, "[t0 t1 t2]"},
+	} {
+		var ids []string
+		err := gmailclient.ListThreads(context.Background(), srv, "me", "", tt.max, func(th *gmail.Thread) error {
+			ids = append(ids, th.Id)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("max %d: threads = %s, want %s", tt.max, got, tt.want)
+		}
+	}
+	th, err := gmailclient.GetThread(context.Background(), srv, "me", "t1", "full")
+	if err != nil || len(th.Messages) != 2 {
+		t.Errorf("GetThread = %v, %v", th, err)
+	}
+}
@@ -0,0 +1,181 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/keyring"
+	"golang.org/x/oauth2"
+)
+
+// A TokenStore persists the OAuth token of one account.
+type TokenStore interface {
+	// Load returns the stored token, or an error wrapping ErrNoToken if
+	// there is none.
+	Load() (*oauth2.Token, error)
+
+	// Save replaces the stored token.
+	Save(tok *oauth2.Token) error
+
+	// Delete removes the stored token. Deleting a missing token is not an
+	// error.
+	Delete() error
+}
+
+// FileTokenStore keeps the token as JSON in a file.
+type FileTokenStore struct {
+	Path string
+}
+
+// NewFileTokenStore returns a store backed by the file at path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+func (s *FileTokenStore) Load() (*oauth2.Token, error) {
+	return TokenFromFile(s.Path)
+}
+
+func (s *FileTokenStore) Save(tok *oauth2.Token) error {
+	return SaveToken(s.Path, tok)
+}
+
+func (s *FileTokenStore) Delete() error {
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// MemoryTokenStore keeps the token in memory. It is safe for concurrent use.
+type MemoryTokenStore struct {
+	mu  sync.Mutex
+	tok *oauth2.Token
+}
+
+// NewMemoryTokenStore returns a store holding tok, which may be nil.
+func NewMemoryTokenStore(tok *oauth2.Token) *MemoryTokenStore {
+	return &MemoryTokenStore{tok: tok}
+}
+
+func (s *MemoryTokenStore) Load() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok == nil {
+		return nil, ErrNoToken
+	}
+	tok := *s.tok
+	return &tok, nil
+}
+
+func (s *MemoryTokenStore) Save(tok *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := *tok
+	s.tok = &t
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tok = nil
+	return nil
+}
+
+// EnvTokenStore reads the token as JSON from an environment variable, which
+// suits servers and CI jobs that receive secrets through the environment.
+// Save and Delete only change the variable for the current process.
+type EnvTokenStore struct {
+	Name string
+}
+
+// NewEnvTokenStore returns a store backed by the environment variable name.
+func NewEnvTokenStore(name string) *EnvTokenStore {
+	return &EnvTokenStore{Name: name}
+}
+
+func (s *EnvTokenStore) Load() (*oauth2.Token, error) {
+	v := os.Getenv(s.Name)
+	if v == "" {
+		return nil, fmt.Errorf("%w: $%s is not set", ErrNoToken, s.Name)
+	}
+	tok, err := decodeToken([]byte(v))
+	if err != nil {
+		return nil, fmt.Errorf("EnvTokenStore decode $%s: %w", s.Name, err)
+	}
+	return tok, nil
+}
+
+func (s *EnvTokenStore) Save(tok *oauth2.Token) error {
+	b, err := encodeToken(tok)
+	if err != nil {
+		return fmt.Errorf("EnvTokenStore encode oauth token: %w", err)
+	}
+	return os.Setenv(s.Name, string(b))
+}
+
+func (s *EnvTokenStore) Delete() error {
+	return os.Unsetenv(s.Name)
+}
+
+// KeyringTokenStore keeps the token in the operating system's credential
+// store (macOS Keychain, Secret Service on Linux, Windows Credential Manager)
+// under the given service and account names.
+type KeyringTokenStore struct {
+	Service string
+	Account string
+}
+
+// NewKeyringTokenStore returns a store for account under service.
+func NewKeyringTokenStore(service, account string) *KeyringTokenStore {
+	return &KeyringTokenStore{Service: service, Account: account}
+}
+
+func (s *KeyringTokenStore) Load() (*oauth2.Token, error) {
+	v, err := keyring.Get(s.Service, s.Account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("%w: no keyring entry for %s/%s", ErrNoToken, s.Service, s.Account)
+	} else if err != nil {
+		return nil, err
+	}
+	tok, err := decodeToken([]byte(v))
+	if err != nil {
+		return nil, fmt.Errorf("KeyringTokenStore decode %s/%s: %w", s.Service, s.Account, err)
+	}
+	return tok, nil
+}
+
+func (s *KeyringTokenStore) Save(tok *oauth2.Token) error {
+	b, err := encodeToken(tok)
+	if err != nil {
+		return fmt.Errorf("KeyringTokenStore encode oauth token: %w", err)
+	}
+	return keyring.Set(s.Service, s.Account, string(b))
+}
+
+func (s *KeyringTokenStore) Delete() error {
+	if err := keyring.Delete(s.Service, s.Account); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokenstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("GMAILCLIENT_TEST_TOKEN")
+
+	stores := map[string]gmailclient.TokenStore{
+		"file":   gmailclient.NewFileTokenStore(filepath.Join(dir, "sub", "token.json")),
+		"memory": gmailclient.NewMemoryTokenStore(nil),
+		"env":    gmailclient.NewEnvTokenStore("GMAILCLIENT_TEST_TOKEN"),
+		"encrypted": gmailclient.NewEncryptedFileTokenStore(filepath.Join(dir, "enc.json"),
+			func() (string, error) { return "correct horse", nil }),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Load(); !errors.Is(err, gmailclient.ErrNoToken) {
+				t.Fatalf("Load on empty store: err = %v, want ErrNoToken", err)
+			}
+			want := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"}
+			want = gmailclient.WithScopes(want, []string{"https://www.googleapis.com/auth/gmail.readonly"})
+			if err := store.Save(want); err != nil {
+				t.Fatal(err)
+			}
+			got, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.AccessToken != want.AccessToken || got.RefreshToken != want.RefreshToken {
+				t.Errorf("Load = %+v, want %+v", got, want)
+			}
+			if scopes := gmailclient.GrantedScopes(got); len(scopes) != 1 {
+				t.Errorf("GrantedScopes after Load = %v, want the saved scope", scopes)
+			}
+			if err := store.Delete(); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete(); err != nil {
+				t.Errorf("second Delete: %v", err)
+			}
+			if _, err := store.Load(); !errors.Is(err, gmailclient.ErrNoToken) {
+				t.Errorf("Load after Delete: err = %v, want ErrNoToken", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedTokenStoreWrongPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokenstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+
+	store := gmailclient.NewEncryptedFileTokenStore(path, func() (string, error) { return "right", nil })
+	if err := store.Save(&oauth2.Token{RefreshToken: "secret-refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret-refresh") {
+		t.Error("refresh token stored in plaintext")
+	}
+
+	store.Passphrase = func() (string, error) { return "wrong", nil }
+	if _, err := store.Load(); !errors.Is(err, gmailclient.ErrBadPassphrase) {
+		t.Errorf("Load with wrong passphrase: err = %v, want ErrBadPassphrase", err)
+	}
+}
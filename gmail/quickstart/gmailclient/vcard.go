@@ -0,0 +1,179 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Contact is a contact card (vCard) sent by mail.
+type Contact struct {
+	Name         string   `json:"name"` // FN, or built from N if missing
+	GivenName    string   `json:"givenName,omitempty"`
+	FamilyName   string   `json:"familyName,omitempty"`
+	Organization string   `json:"organization,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Emails       []string `json:"emails,omitempty"`
+	Phones       []string `json:"phones,omitempty"`
+	Addresses    []string `json:"addresses,omitempty"` // ADR fields joined by ", "
+	URLs         []string `json:"urls,omitempty"`
+	Birthday     string   `json:"birthday,omitempty"` // as written, usually YYYY-MM-DD
+	Note         string   `json:"note,omitempty"`
+}
+
+// ParseVCard returns the contacts of a vCard file, of version 2.1, 3.0 or
+// 4.0. Quoted-printable values of version 2.1 are decoded.
+func ParseVCard(data []byte) ([]*Contact, error) {
+	var contacts []*Contact
+	var c *Contact
+	lines := unfoldICS(data)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// Quoted-printable values continue on lines after a soft break.
+		if strings.Contains(strings.ToUpper(line), "QUOTED-PRINTABLE") {
+			for strings.HasSuffix(line, "=") && i+1 < len(lines) {
+				i++
+				line = line[:len(line)-1] + lines[i]
+			}
+		}
+		p, ok := parseICSLine(line)
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(p.params["ENCODING"], "QUOTED-PRINTABLE") {
+			b, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(p.value)))
+			if err == nil {
+				p.value = toUTF8(b, p.params["CHARSET"])
+			}
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			c = &Contact{}
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			if c != nil {
+				if c.Name == "" {
+					c.Name = strings.TrimSpace(c.GivenName + " " + c.FamilyName)
+				}
+				contacts = append(contacts, c)
+			}
+			c = nil
+		case c != nil:
+			c.set(p)
+		}
+	}
+	if contacts == nil {
+		return nil, fmt.Errorf("ParseVCard: no VCARD")
+	}
+	return contacts, nil
+}
+
+// set fills in the field of c for property p.
+func (c *Contact) set(p icsProperty) {
+	value := func() string { return unescapeICS(p.value) }
+	switch p.name {
+	case "FN":
+		c.Name = value()
+	case "N":
+		n := splitICS(p.value)
+		c.FamilyName = n[0]
+		if len(n) > 1 {
+			c.GivenName = n[1]
+		}
+	case "ORG":
+		c.Organization = strings.TrimSpace(strings.Join(splitICS(p.value), " "))
+	case "TITLE":
+		c.Title = value()
+	case "EMAIL":
+		c.Emails = append(c.Emails, strings.TrimPrefix(value(), "mailto:"))
+	case "TEL":
+		c.Phones = append(c.Phones, strings.TrimPrefix(value(), "tel:"))
+	case "ADR":
+		var parts []string
+		for _, f := range splitICS(p.value) {
+			if f = strings.TrimSpace(f); f != "" {
+				parts = append(parts, strings.Replace(f, "\n", ", ", -1))
+			}
+		}
+		if len(parts) > 0 {
+			c.Addresses = append(c.Addresses, strings.Join(parts, ", "))
+		}
+	case "URL":
+		c.URLs = append(c.URLs, value())
+	case "BDAY":
+		c.Birthday = value()
+	case "NOTE":
+		c.Note = value()
+	}
+}
+
+// ContactCSVHeader names the columns of ContactCSVRecord.
+var ContactCSVHeader = []string{"name", "given_name", "family_name", "organization", "title", "emails", "phones", "addresses", "urls", "birthday", "note"}
+
+// ContactCSVRecord returns c as a row for a spreadsheet, with lists
+// separated by "; ".
+func ContactCSVRecord(c *Contact) []string {
+	return []string{
+		c.Name,
+		c.GivenName,
+		c.FamilyName,
+		c.Organization,
+		c.Title,
+		strings.Join(c.Emails, "; "),
+		strings.Join(c.Phones, "; "),
+		strings.Join(c.Addresses, "; "),
+		strings.Join(c.URLs, "; "),
+		c.Birthday,
+		c.Note,
+	}
+}
+
+// isVCardPart reports whether p holds contact cards.
+func isVCardPart(p *gmail.MessagePart) bool {
+	switch strings.ToLower(p.MimeType) {
+	case "text/vcard", "text/x-vcard", "text/directory":
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(p.Filename), ".vcf")
+}
+
+// MessageContacts returns the contacts of the vCard parts of gmailMessage,
+// fetched in "full" format. Cards that do not parse are skipped.
+func MessageContacts(ctx context.Context, srv GmailService, gmailMessage *gmail.Message, user string) ([]*Contact, error) {
+	if gmailMessage.Payload == nil {
+		return nil, ErrNoPayload
+	}
+	var contacts []*Contact
+	for _, part := range findParts(gmailMessage.Payload, isVCardPart) {
+		data, err := GetMessagePartData(ctx, srv, user, gmailMessage.Id, part)
+		if err != nil {
+			return nil, fmt.Errorf("MessageContacts: %w", err)
+		}
+		list, err := ParseVCard([]byte(data))
+		if err != nil {
+			continue
+		}
+		contacts = append(contacts, list...)
+	}
+	return contacts, nil
+}
@@ -0,0 +1,61 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gmailclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/gmailclient"
+)
+
+func TestParseVCard(t *testing.T) {
+	const cards = "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Ana Lima\r\nN:Lima;Ana;;;\r\nORG:Example\\, Inc.;Sales\r\n" +
+		"TITLE:Account manager\r\nitem1.EMAIL;type=INTERNET;type=pref:ana@example.com\r\nTEL;TYPE=CELL:+49 30 1234567\r\n" +
+		"ADR;TYPE=WORK:;;Hauptstr. 1;Berlin;;10115;Germany\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:2.1\r\nN;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE:M=C3=BCller;J=\r\n=C3=BCrgen\r\n" +
+		"TEL;WORK;VOICE:030 555\r\nEND:VCARD\r\n"
+	contacts, err := gmailclient.ParseVCard([]byte(cards))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*gmailclient.Contact{
+		{
+			Name:         "Ana Lima",
+			GivenName:    "Ana",
+			FamilyName:   "Lima",
+			Organization: "Example, Inc. Sales",
+			Title:        "Account manager",
+			Emails:       []string{"ana@example.com"},
+			Phones:       []string{"+49 30 1234567"},
+			Addresses:    []string{"Hauptstr. 1, Berlin, 10115, Germany"},
+		},
+		{
+			Name:       "Jürgen Müller",
+			GivenName:  "Jürgen",
+			FamilyName: "Müller",
+			Phones:     []string{"030 555"},
+		},
+	}
+	if !reflect.DeepEqual(contacts, want) {
+		for i, c := range contacts {
+			t.Logf("contact %d: %+v", i, c)
+		}
+		t.Errorf("ParseVCard returned %d contacts, not the expected ones", len(contacts))
+	}
+}
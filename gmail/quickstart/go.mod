@@ -3,6 +3,16 @@ module github.com/pathcl/go-samples/gmail/quickstart
 go 1.15
 
 require (
-	github.com/pkg/errors v0.9.1 // indirect
-	google.golang.org/api v0.45.0 // indirect
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/mattn/go-sqlite3 v1.14.7
+	github.com/xitongsys/parquet-go v1.6.0
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/net v0.0.0-20210316092652-d523dce5a7f4
+	golang.org/x/oauth2 v0.0.0-20210413134643-5e61552d6c78
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	golang.org/x/text v0.3.5
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
+	google.golang.org/api v0.45.0
+	gopkg.in/yaml.v3 v3.0.1
 )
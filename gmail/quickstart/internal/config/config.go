@@ -0,0 +1,248 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package config loads the gmailctl configuration file, which holds named
+// profiles of credentials, token and default settings.
+//
+// A minimal file looks like:
+//
+//	default_profile: personal
+//	profiles:
+//	  personal:
+//	    credentials: ~/.config/gmailtool/personal-credentials.json
+//	    token: ~/.config/gmailtool/personal-token.json
+//	  work:
+//	    credentials: ~/.config/gmailtool/work-credentials.json
+//	    token: ~/.config/gmailtool/work-token.json
+//	    scopes: [gmail.readonly]
+//	    query: "label:inbox is:unread"
+//	    output_dir: ~/Mail/work
+//	    token_store: keyring
+//	searches:
+//	  newsletters: "label:newsletter after:{{daysAgo 30}}"
+//	  from: "from:{{.who}} newer_than:7d"
+//
+// Every profile is also an account: profiles without a token setting keep
+// their token in $XDG_CONFIG_HOME/gmailtool/tokens/<name>.json, so several
+// mailboxes can be authorized side by side.
+//
+// Searches are named Gmail queries, which may also be given per profile;
+// see ExpandSearch for the substitutions they may hold.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is one named set of gmailctl settings. Empty fields fall back to the
+// command-line defaults.
+type Profile struct {
+	Name           string   `yaml:"-"`
+	Credentials    string   `yaml:"credentials"`
+	Token          string   `yaml:"token"`
+	TokenStore     string   `yaml:"token_store"`
+	ServiceAccount string   `yaml:"service_account"`
+	ADC            bool     `yaml:"adc"`
+	User           string   `yaml:"user"`
+	Scopes         []string `yaml:"scopes"`
+	Query          string   `yaml:"query"`
+	OutputDir      string   `yaml:"output_dir"`
+
+	// Searches are the saved searches of the profile, including those of
+	// the file's searches the profile does not redefine.
+	Searches map[string]string `yaml:"searches"`
+}
+
+// Config is the contents of the configuration file.
+type Config struct {
+	DefaultProfile string              `yaml:"default_profile"`
+	Profiles       map[string]*Profile `yaml:"profiles"`
+	Searches       map[string]string   `yaml:"searches"`
+}
+
+// DefaultPath returns the location of the configuration file,
+// $XDG_CONFIG_HOME/gmailtool/config.yaml or its platform equivalent.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path. Paths inside profiles have a
+// leading "~/" expanded to the user's home directory.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	for name, p := range c.Profiles {
+		if p == nil {
+			p = &Profile{}
+			c.Profiles[name] = p
+		}
+		p.Name = name
+		p.Credentials = ExpandHome(p.Credentials)
+		p.Token = ExpandHome(p.Token)
+		p.ServiceAccount = ExpandHome(p.ServiceAccount)
+		p.OutputDir = ExpandHome(p.OutputDir)
+		for search, query := range c.Searches {
+			if _, ok := p.Searches[search]; ok {
+				continue
+			}
+			if p.Searches == nil {
+				p.Searches = map[string]string{}
+			}
+			p.Searches[search] = query
+		}
+	}
+	return c, nil
+}
+
+// TokenFile returns the token file of p: its token setting, or TokenPath of
+// its name if it has none.
+func (p *Profile) TokenFile() (string, error) {
+	if p.Token != "" {
+		return p.Token, nil
+	}
+	return TokenPath(p.Name)
+}
+
+// TokenPath returns the default token file of the named account.
+func TokenPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "tokens", name+".json"), nil
+}
+
+// SyncPath returns the default sync state file of the named account.
+func SyncPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "sync", name+".json"), nil
+}
+
+// OutboxPath returns the default database of messages scheduled for later
+// sending.
+func OutboxPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "outbox.db"), nil
+}
+
+// SnoozePath returns the default database of snoozed messages.
+func SnoozePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "snooze.db"), nil
+}
+
+// SearchIndexPath returns the default full-text index of search local.
+func SearchIndexPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "search.db"), nil
+}
+
+// RulesPath returns the default rules file of apply-rules.
+func RulesPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "rules.yaml"), nil
+}
+
+// RulesStatePath returns the default file in which apply-rules -watch
+// records how far it has got in the named account.
+func RulesStatePath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "rules", name+".json"), nil
+}
+
+// VacationStatePath returns the default file in which settings vacation
+// calendar records the responder it set in the named account.
+func VacationStatePath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmailtool", "vacation", name+".json"), nil
+}
+
+// Profile returns the named profile, or the default profile if name is empty.
+// If neither is set an empty profile is returned.
+func (c *Config) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return &Profile{Searches: c.Searches}, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("config: no profile %q (have %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+// ProfileNames returns the names of all profiles in sorted order.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandHome replaces a leading "~/" in path with the user's home directory.
+func ExpandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,90 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// searchDate is the date format of Gmail's after: and before: operators.
+const searchDate = "2006/01/02"
+
+// ExpandSearch returns the saved search query with its substitutions made.
+// A query is a text/template: {{.name}} is replaced by params[name], and
+// these functions give dates relative to now in the form after: and before:
+// take:
+//
+//	today, yesterday
+//	daysAgo n, weeksAgo n, monthsAgo n, yearsAgo n
+//	startOfWeek, startOfMonth, startOfYear
+//
+// so that "label:newsletter after:{{daysAgo 30}}" always covers the last
+// thirty days. A parameter the query uses but params lacks is an error.
+func ExpandSearch(query string, params map[string]string, now time.Time) (string, error) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	date := func(t time.Time) string { return t.Format(searchDate) }
+	funcs := template.FuncMap{
+		"today":     func() string { return date(day) },
+		"yesterday": func() string { return date(day.AddDate(0, 0, -1)) },
+		"daysAgo":   func(n int) string { return date(day.AddDate(0, 0, -n)) },
+		"weeksAgo":  func(n int) string { return date(day.AddDate(0, 0, -7*n)) },
+		"monthsAgo": func(n int) string { return date(day.AddDate(0, -n, 0)) },
+		"yearsAgo":  func(n int) string { return date(day.AddDate(-n, 0, 0)) },
+		"startOfWeek": func() string {
+			// Weeks start on Monday.
+			return date(day.AddDate(0, 0, -(int(day.Weekday())+6)%7))
+		},
+		"startOfMonth": func() string { return date(day.AddDate(0, 0, 1-day.Day())) },
+		"startOfYear":  func() string { return date(day.AddDate(0, 0, 1-day.YearDay())) },
+	}
+	t, err := template.New("search").Funcs(funcs).Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", fmt.Errorf("config: search %q: %w", query, err)
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, params); err != nil {
+		return "", fmt.Errorf("config: search %q: %w", query, err)
+	}
+	return b.String(), nil
+}
+
+// Search returns the saved search of p called name, expanded by
+// ExpandSearch.
+func (p *Profile) Search(name string, params map[string]string, now time.Time) (string, error) {
+	query, ok := p.Searches[name]
+	if !ok {
+		names := make([]string, 0, len(p.Searches))
+		for n := range p.Searches {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return "", fmt.Errorf("config: no saved search %q (none are configured)", name)
+		}
+		return "", fmt.Errorf("config: no saved search %q (have %s)", name, strings.Join(names, ", "))
+	}
+	return ExpandSearch(query, params, now)
+}
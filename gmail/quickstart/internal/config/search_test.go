@@ -0,0 +1,89 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/config"
+)
+
+func TestExpandSearch(t *testing.T) {
+	// A Sunday that is also New Year's Day, a Wednesday in a leap year's
+	// March, and a Monday.
+	sunday := time.Date(2023, 1, 1, 23, 59, 0, 0, time.UTC)
+	wednesday := time.Date(2024, 3, 13, 15, 4, 5, 0, time.UTC)
+	monday := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		now   time.Time
+		query string
+		want  string
+	}{
+		{sunday, "after:{{today}}", "after:2023/01/01"},
+		{sunday, "after:{{yesterday}}", "after:2022/12/31"},
+		{sunday, "after:{{daysAgo 30}}", "after:2022/12/02"},
+		{sunday, "after:{{weeksAgo 1}}", "after:2022/12/25"},
+		{sunday, "after:{{monthsAgo 1}}", "after:2022/12/01"},
+		{sunday, "after:{{yearsAgo 1}}", "after:2022/01/01"},
+		{sunday, "after:{{startOfWeek}}", "after:2022/12/26"},
+		{sunday, "after:{{startOfMonth}}", "after:2023/01/01"},
+		{sunday, "after:{{startOfYear}}", "after:2023/01/01"},
+		{wednesday, "after:{{daysAgo 13}} before:{{today}}", "after:2024/02/29 before:2024/03/13"},
+		{wednesday, "after:{{startOfWeek}}", "after:2024/03/11"},
+		{wednesday, "after:{{startOfMonth}}", "after:2024/03/01"},
+		{wednesday, "after:{{startOfYear}}", "after:2024/01/01"},
+		{monday, "after:{{startOfWeek}}", "after:2024/03/11"},
+		{monday, "from:{{.sender}} after:{{daysAgo 0}}", "from:bo@example.com after:2024/03/11"},
+	} {
+		got, err := config.ExpandSearch(tt.query, map[string]string{"sender": "bo@example.com"}, tt.now)
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandSearch(%q) at %s = %q, %v; want %q", tt.query, tt.now.Format("Mon 2006-01-02"), got, err, tt.want)
+		}
+	}
+
+	for _, query := range []string{"from:{{.sender}}", "after:{{daysAgo}}", "after:{{tomorrow}}", "{{"} {
+		if got, err := config.ExpandSearch(query, nil, sunday); err == nil {
+			t.Errorf("ExpandSearch(%q) = %q, want an error", query, got)
+		}
+	}
+}
+
+func TestProfileSearch(t *testing.T) {
+	now := time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)
+	p := &config.Profile{Searches: map[string]string{
+		"news":   "label:newsletter after:{{daysAgo 7}}",
+		"sender": "from:{{.from}}",
+	}}
+	if got, err := p.Search("news", nil, now); err != nil || got != "label:newsletter after:2024/03/06" {
+		t.Errorf("Search(news) = %q, %v", got, err)
+	}
+	if got, err := p.Search("sender", map[string]string{"from": "bo@example.com"}, now); err != nil || got != "from:bo@example.com" {
+		t.Errorf("Search(sender) = %q, %v", got, err)
+	}
+	if _, err := p.Search("sender", nil, now); err == nil {
+		t.Error("Search(sender) without from succeeded")
+	}
+	if _, err := p.Search("old", nil, now); err == nil || !strings.Contains(err.Error(), "have news, sender") {
+		t.Errorf("Search(old) = %v, want an error listing the searches", err)
+	}
+	if _, err := (&config.Profile{}).Search("news", nil, now); err == nil || !strings.Contains(err.Error(), "none are configured") {
+		t.Errorf("Search without searches = %v", err)
+	}
+}
@@ -0,0 +1,554 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package expr evaluates the small expression language of the -where and
+// -select flags over decoded JSON values, such as
+//
+//	from contains "@vimtricks.com" && size > 100000
+//
+// Names are the JSON fields of the value, matched regardless of case if
+// there is no exact match, and reached into with dots and indexes, as in
+// from[0].address; negative indexes count from the end. A name starts with a
+// letter or _ and goes on with letters, digits, _ and -. There is no
+// arithmetic, so - never stands for subtraction: x-mailer is one name, and so
+// is size-1. Fields with other characters are reached with a string index,
+// as in headers["content type"]. Literals are
+// numbers, "strings", true, false and null. The operators, loosest first:
+//
+//	||
+//	&&
+//	!
+//	== != < <= > >= contains matches
+//
+// Numbers compare as numbers and strings as strings, so RFC 3339 dates
+// compare in time order. contains looks for a string, ignoring case, in a
+// string, or in any element or field of an array or object; matches takes a
+// regular expression. Values of different types are never equal and never
+// ordered. len(x) is the length of a string, array or object. Used as a
+// condition, null, false, 0, "" and empty arrays and objects are false.
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An Expr is a parsed expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the source of e.
+func (e *Expr) String() string { return e.src }
+
+// Eval returns the value of e for v, a value decoded by encoding/json into an
+// interface{}.
+func (e *Expr) Eval(v interface{}) interface{} { return e.root.eval(v) }
+
+// Match reports whether e is true for v.
+func (e *Expr) Match(v interface{}) bool { return truthy(e.root.eval(v)) }
+
+// Parse parses s.
+func Parse(s string) (*Expr, error) {
+	p := &parser{src: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, p.errorf("unexpected %s", p.toks[p.pos].text)
+	}
+	return &Expr{src: strings.TrimSpace(s), root: n}, nil
+}
+
+// ParseList parses a comma-separated list of expressions, as -select takes.
+// Commas inside parentheses, brackets and strings do not separate.
+func ParseList(s string) ([]*Expr, error) {
+	var list []*Expr
+	depth, start := 0, 0
+	inString := false
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch c := s[i]; {
+			case inString && c == '\\':
+				i++
+				continue
+			case c == '"':
+				inString = !inString
+				continue
+			case inString:
+				continue
+			case c == '(' || c == '[':
+				depth++
+				continue
+			case c == ')' || c == ']':
+				depth--
+				continue
+			case c != ',' || depth > 0:
+				continue
+			}
+		}
+		e, err := Parse(s[start:i])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		start = i + 1
+	}
+	return list, nil
+}
+
+type tokKind int
+
+const (
+	tokName tokKind = iota
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	num  float64
+}
+
+type parser struct {
+	src  string
+	toks []token
+	pos  int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("expr: %s in %q", fmt.Sprintf(format, args...), p.src)
+}
+
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ".", ","}
+
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return p.errorf("unterminated string")
+			}
+			v, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return p.errorf("bad string %s", s[i:j+1])
+			}
+			p.toks = append(p.toks, token{kind: tokString, text: v})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || s[j] == 'e' || s[j] == 'E') {
+				if s[j] == 'e' || s[j] == 'E' {
+					if j+1 < len(s) && (s[j+1] == '+' || s[j+1] == '-') {
+						j++
+					}
+				}
+				j++
+			}
+			n, err := strconv.ParseFloat(s[i:j], 64)
+			if err != nil {
+				return p.errorf("bad number %s", s[i:j])
+			}
+			p.toks = append(p.toks, token{kind: tokNumber, text: s[i:j], num: n})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + size
+			for j < len(s) {
+				r, n := utf8.DecodeRuneInString(s[j:])
+				if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += n
+			}
+			p.toks = append(p.toks, token{kind: tokName, text: s[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return p.errorf("unexpected %q", c)
+			}
+			p.toks = append(p.toks, token{kind: tokOp, text: op})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+// accept consumes the next token if it is the operator or keyword s.
+func (p *parser) accept(s string) bool {
+	if p.pos < len(p.toks) && (p.toks[p.pos].kind == tokOp || p.toks[p.pos].kind == tokName) && p.toks[p.pos].text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	l, err := p.and()
+	for err == nil && p.accept("||") {
+		var r node
+		if r, err = p.and(); err == nil {
+			l = orNode{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) and() (node, error) {
+	l, err := p.not()
+	for err == nil && p.accept("&&") {
+		var r node
+		if r, err = p.not(); err == nil {
+			l = andNode{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) not() (node, error) {
+	if p.accept("!") {
+		n, err := p.not()
+		return notNode{n}, err
+	}
+	return p.cmp()
+}
+
+var comparisons = []string{"==", "!=", "<=", ">=", "<", ">", "contains", "matches"}
+
+func (p *parser) cmp() (node, error) {
+	l, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range comparisons {
+		if !p.accept(op) {
+			continue
+		}
+		r, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "matches" {
+			lit, ok := r.(literal)
+			s, isString := lit.v.(string)
+			if !ok || !isString {
+				return nil, p.errorf("matches needs a string literal")
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			return matchNode{l, re}, nil
+		}
+		return cmpNode{op, l, r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) primary() (node, error) {
+	if p.pos >= len(p.toks) {
+		return nil, p.errorf("unexpected end")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokNumber:
+		return literal{t.num}, nil
+	case tokString:
+		return literal{t.text}, nil
+	case tokName:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		case "len":
+			if p.accept("(") {
+				arg, err := p.or()
+				if err != nil {
+					return nil, err
+				}
+				if !p.accept(")") {
+					return nil, p.errorf("missing ) after len(")
+				}
+				return lenNode{arg}, nil
+			}
+		}
+		return p.path(field{t.text})
+	case tokOp:
+		if t.text == "(" {
+			n, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, p.errorf("missing )")
+			}
+			return n, nil
+		}
+	}
+	return nil, p.errorf("unexpected %s", t.text)
+}
+
+// path parses the .name and [index] steps following a name.
+func (p *parser) path(steps ...step) (node, error) {
+	for {
+		switch {
+		case p.accept("."):
+			if p.pos >= len(p.toks) {
+				return nil, p.errorf("missing name after .")
+			}
+			t := p.toks[p.pos]
+			p.pos++
+			if t.kind != tokName {
+				return nil, p.errorf("unexpected %s after .", t.text)
+			}
+			steps = append(steps, field{t.text})
+		case p.accept("["):
+			if p.pos >= len(p.toks) || p.toks[p.pos].kind == tokOp {
+				return nil, p.errorf("missing index after [")
+			}
+			t := p.toks[p.pos]
+			p.pos++
+			if t.kind == tokNumber {
+				steps = append(steps, index{int(t.num)})
+			} else {
+				steps = append(steps, field{t.text})
+			}
+			if !p.accept("]") {
+				return nil, p.errorf("missing ]")
+			}
+		default:
+			return pathNode(steps), nil
+		}
+	}
+}
+
+type node interface {
+	eval(v interface{}) interface{}
+}
+
+type step interface {
+	get(v interface{}) interface{}
+}
+
+type field struct{ name string }
+
+func (f field) get(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if x, ok := m[f.name]; ok {
+		return x
+	}
+	// Go structs without JSON tags encode with capitalized names.
+	for k, x := range m {
+		if strings.EqualFold(k, f.name) {
+			return x
+		}
+	}
+	return nil
+}
+
+type index struct{ i int }
+
+func (ix index) get(v interface{}) interface{} {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	i := ix.i
+	if i < 0 {
+		i += len(a)
+	}
+	if i < 0 || i >= len(a) {
+		return nil
+	}
+	return a[i]
+}
+
+type pathNode []step
+
+func (p pathNode) eval(v interface{}) interface{} {
+	for _, s := range p {
+		if v = s.get(v); v == nil {
+			return nil
+		}
+	}
+	return v
+}
+
+type literal struct{ v interface{} }
+
+func (l literal) eval(interface{}) interface{} { return l.v }
+
+type orNode struct{ l, r node }
+
+func (n orNode) eval(v interface{}) interface{} { return truthy(n.l.eval(v)) || truthy(n.r.eval(v)) }
+
+type andNode struct{ l, r node }
+
+func (n andNode) eval(v interface{}) interface{} { return truthy(n.l.eval(v)) && truthy(n.r.eval(v)) }
+
+type notNode struct{ n node }
+
+func (n notNode) eval(v interface{}) interface{} { return !truthy(n.n.eval(v)) }
+
+type lenNode struct{ n node }
+
+func (n lenNode) eval(v interface{}) interface{} {
+	switch x := n.n.eval(v).(type) {
+	case string:
+		return float64(len([]rune(x)))
+	case []interface{}:
+		return float64(len(x))
+	case map[string]interface{}:
+		return float64(len(x))
+	}
+	return float64(0)
+}
+
+type matchNode struct {
+	n  node
+	re *regexp.Regexp
+}
+
+func (n matchNode) eval(v interface{}) interface{} {
+	s, ok := n.n.eval(v).(string)
+	return ok && n.re.MatchString(s)
+}
+
+type cmpNode struct {
+	op   string
+	l, r node
+}
+
+func (n cmpNode) eval(v interface{}) interface{} {
+	l, r := n.l.eval(v), n.r.eval(v)
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(l, r)
+	case "!=":
+		return !reflect.DeepEqual(l, r)
+	case "contains":
+		s, ok := r.(string)
+		return ok && contains(l, strings.ToLower(s))
+	}
+	var c int
+	switch x := l.(type) {
+	case float64:
+		y, ok := r.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	case string:
+		y, ok := r.(string)
+		if !ok {
+			return false
+		}
+		c = strings.Compare(x, y)
+	default:
+		return false
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// contains reports whether v holds the lower-case string s.
+func contains(v interface{}, s string) bool {
+	switch x := v.(type) {
+	case string:
+		return strings.Contains(strings.ToLower(x), s)
+	case float64:
+		return strings.Contains(strconv.FormatFloat(x, 'f', -1, 64), s)
+	case []interface{}:
+		for _, e := range x {
+			if contains(e, s) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range x {
+			if contains(e, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	case []interface{}:
+		return len(x) > 0
+	case map[string]interface{}:
+		return len(x) > 0
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package expr_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pathcl/go-samples/gmail/quickstart/internal/expr"
+)
+
+const message = `{
+	"id": "17a",
+	"from": [{"name": "Vim Tricks", "address": "hi@vimtricks.com"}],
+	"subject": "Weekly tips",
+	"size": 120000,
+	"labelIds": ["INBOX", "UNREAD"],
+	"date": "2021-05-03T10:00:00Z",
+	"Snippet": "Résumé",
+	"größe": 2.5e-5,
+	"x-mailer": "mutt",
+	"empty": "",
+	"none": null
+}`
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestEval(t *testing.T) {
+	v := decode(t, message)
+	for _, tt := range []struct {
+		expr string
+		want interface{}
+	}{
+		// Literals, numbers with exponents among them.
+		{`42`, 42.0},
+		{`-1.5`, -1.5},
+		{`1e3`, 1000.0},
+		{`1e-5`, 1e-5},
+		{`2.5E+2`, 250.0},
+		{`"a \"b\""`, `a "b"`},
+		{`true`, true},
+		{`null`, nil},
+
+		// Paths.
+		{`id`, "17a"},
+		{`from[0].address`, "hi@vimtricks.com"},
+		{`labelIds[-1]`, "UNREAD"},
+		{`labelIds[5]`, nil},
+		{`from[0]["name"]`, "Vim Tricks"},
+		{`snippet`, "Résumé"},
+		{`größe`, 2.5e-5},
+		{`x-mailer`, "mutt"},
+		{`size-1`, nil},
+		{`missing.field`, nil},
+
+		// Comparisons.
+		{`size > 100000`, true},
+		{`size <= 1e5`, false},
+		{`größe < 1e-4`, true},
+		{`subject == "Weekly tips"`, true},
+		{`subject != "Weekly tips"`, false},
+		{`date >= "2021-05-01"`, true},
+		{`size == "120000"`, false},
+		{`size < "2"`, false},
+		{`from contains "VIMTRICKS"`, true},
+		{`labelIds contains "unread"`, true},
+		{`size contains "1200"`, true},
+		{`subject matches "^Week"`, true},
+		{`size matches "1"`, false},
+
+		// Logic and truthiness.
+		{`!empty`, true},
+		{`!none && labelIds`, true},
+		{`empty || size`, true},
+		{`!(size > 1 && subject contains "daily")`, true},
+		{`size > 1 || subject contains "daily" && false`, true},
+
+		// len.
+		{`len(subject)`, 11.0},
+		{`len(snippet)`, 6.0},
+		{`len(labelIds)`, 2.0},
+		{`len(from[0])`, 2.0},
+		{`len(size)`, 0.0},
+	} {
+		e, err := expr.Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", tt.expr, err)
+			continue
+		}
+		if got := e.Eval(v); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		expr, want string
+	}{
+		{`"open`, "unterminated string"},
+		{`"bad \q"`, "bad string"},
+		{`1e`, "bad number"},
+		{`1.2.3`, "bad number"},
+		{`size # 2`, `unexpected '#'`},
+		{`size > €`, `unexpected '€'`},
+		{`size >`, "unexpected end"},
+		{`size 2`, "unexpected 2"},
+		{`size -1`, "unexpected -1"},
+		{`(size`, "missing )"},
+		{`len(size`, "missing ) after len("},
+		{`from.`, "missing name after ."},
+		{`from.1`, "unexpected 1 after ."},
+		{`from[`, "missing index after ["},
+		{`from[0`, "missing ]"},
+		{`subject matches size`, "matches needs a string literal"},
+		{`subject matches "("`, "missing closing )"},
+		{``, "unexpected end"},
+	} {
+		_, err := expr.Parse(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%s) error = %v, want it to contain %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	v := decode(t, message)
+	for _, tt := range []struct {
+		expr string
+		want bool
+	}{
+		{`size`, true},
+		{`empty`, false},
+		{`none`, false},
+		{`labelIds`, true},
+		{`from[0]`, true},
+		{`0`, false},
+	} {
+		e, err := expr.Parse(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Match(v); got != tt.want {
+			t.Errorf("Match(%s) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	list, err := expr.ParseList(` id, from[0].address , len(labelIds), "a,b" , subject matches "x{1,2}"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range list {
+		got = append(got, e.String())
+	}
+	want := []string{"id", "from[0].address", "len(labelIds)", `"a,b"`, `subject matches "x{1,2}"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseList = %q, want %q", got, want)
+	}
+	if _, err := expr.ParseList("id,,subject"); err == nil {
+		t.Error("ParseList with an empty entry succeeded, want error")
+	}
+}
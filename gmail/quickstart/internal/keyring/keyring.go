@@ -0,0 +1,43 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package keyring stores small secrets in the operating system's credential
+// store: the login Keychain on macOS, the Secret Service (through
+// secret-tool) on Linux and other Unix systems, and the Credential Manager on
+// Windows.
+package keyring
+
+import "errors"
+
+// ErrNotFound is returned by Get and Delete when no secret is stored for the
+// service and account.
+var ErrNotFound = errors.New("keyring: secret not found")
+
+// Get returns the secret stored for service and account.
+func Get(service, account string) (string, error) {
+	return get(service, account)
+}
+
+// Set stores secret for service and account, replacing any previous value.
+func Set(service, account, secret string) error {
+	return set(service, account, secret)
+}
+
+// Delete removes the secret stored for service and account.
+func Delete(service, account string) error {
+	return del(service, account)
+}
@@ -0,0 +1,85 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keyring
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const securityCmd = "/usr/bin/security"
+
+// errItemNotFound is the exit status of security(1) for a missing item.
+const errItemNotFound = 44
+
+func get(service, account string) (string, error) {
+	out, err := exec.Command(securityCmd, "find-generic-password",
+		"-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", securityError(err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func set(service, account, secret string) error {
+	// The command goes to security -i on stdin, and the secret in hex, so
+	// that it never shows up in the argument list other users can see with
+	// ps. -U updates an existing item instead of failing.
+	line := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		securityQuote(service), securityQuote(account), hex.EncodeToString([]byte(secret)))
+	cmd := exec.Command(securityCmd, "-i")
+	cmd.Stdin = strings.NewReader(line)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	// Interactive mode reports failed commands on stderr but may still
+	// exit 0.
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("keyring: security: %s", msg)
+	}
+	return securityError(err)
+}
+
+// securityQuote quotes s as one argument of a security -i command line.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func del(service, account string) error {
+	// Output rather than Run, so that the error carries stderr.
+	_, err := exec.Command(securityCmd, "delete-generic-password",
+		"-s", service, "-a", account).Output()
+	return securityError(err)
+}
+
+// securityError maps the exit status of security(1) for a missing item to
+// ErrNotFound; other failures carry the stderr that Output captures.
+func securityError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == errItemNotFound {
+			return ErrNotFound
+		}
+		return fmt.Errorf("keyring: security: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
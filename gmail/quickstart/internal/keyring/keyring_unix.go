@@ -0,0 +1,81 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The Secret Service is reached through secret-tool(1) from libsecret, which
+// is installed alongside GNOME Keyring and KWallet's Secret Service bridge.
+const secretTool = "secret-tool"
+
+func get(service, account string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(secretTool, "lookup", "service", service, "account", account)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// secret-tool exits with status 1 and no output for a missing item.
+		if errors.As(err, &exitErr) && len(out) == 0 && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", secretToolError(err, &stderr)
+	}
+	return string(out), nil
+}
+
+func set(service, account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(secretTool, "store", "--label", service+" ("+account+")",
+		"service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = &stderr
+	return secretToolError(cmd.Run(), &stderr)
+}
+
+func del(service, account string) error {
+	if _, err := get(service, account); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(secretTool, "clear", "service", service, "account", account)
+	cmd.Stderr = &stderr
+	return secretToolError(cmd.Run(), &stderr)
+}
+
+func secretToolError(err error, stderr *bytes.Buffer) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("keyring: %s not found; install libsecret-tools: %w", secretTool, err)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("keyring: %s: %s", secretTool, msg)
+	}
+	return fmt.Errorf("keyring: %s: %w", secretTool, err)
+}
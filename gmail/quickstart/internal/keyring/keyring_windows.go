@@ -0,0 +1,119 @@
+/**
+ * @license
+ * Copyright Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keyring
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredRead   = advapi32.NewProc("CredReadW")
+	procCredWrite  = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential mirrors the Win32 CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func target(service, account string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + ":" + account)
+}
+
+func get(service, account string) (string, error) {
+	name, err := target(service, account)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0,
+		uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return "", credError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	blob := (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+	return string(blob), nil
+}
+
+func set(service, account, secret string) error {
+	name, err := target(service, account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         name,
+		UserName:           user,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	r, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+func del(service, account string) error {
+	name, err := target(service, account)
+	if err != nil {
+		return err
+	}
+	r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0)
+	if r == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+func credError(err error) error {
+	if err == errorNotFound {
+		return ErrNotFound
+	}
+	return err
+}